@@ -0,0 +1,24 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/portainer/portainer/api/scheduler"
+
+	"github.com/rs/zerolog/log"
+)
+
+const purgeInterval = time.Hour
+
+// StartExpiredAPIKeysPurge schedules the deletion of the expired API keys.
+// The expired API keys are rejected on use, the purge only removes them from the database.
+func StartExpiredAPIKeysPurge(scheduler *scheduler.Scheduler, service APIKeyService) {
+	scheduler.StartJobEvery(purgeInterval, func() error {
+		err := service.DeleteExpiredAPIKeys(time.Now())
+		if err != nil {
+			log.Error().Err(err).Msg("unable to delete the expired API keys")
+		}
+
+		return nil
+	})
+}
@@ -39,6 +39,12 @@ func (a *apiKeyService) HashRaw(rawKey string) []byte {
 // GenerateApiKey generates a raw API key for a user (for one-time display).
 // The generated API key is stored in the cache and database.
 func (a *apiKeyService) GenerateApiKey(user portainer.User, description string) (string, *portainer.APIKey, error) {
+	return a.GenerateScopedApiKey(user, description, 0, nil)
+}
+
+// GenerateScopedApiKey generates a raw API key for a user, restricted to a scope and expiring at expiresAt (Unix).
+// A nil scope gives the full rights of the user to the API key and a zero expiresAt never expires it.
+func (a *apiKeyService) GenerateScopedApiKey(user portainer.User, description string, expiresAt int64, scope *portainer.APIKeyScope) (string, *portainer.APIKey, error) {
 	randKey := generateRandomKey(32)
 	encodedRawAPIKey := base64.StdEncoding.EncodeToString(randKey)
 	prefixedAPIKey := portainerAPIKeyPrefix + encodedRawAPIKey
@@ -51,6 +57,8 @@ func (a *apiKeyService) GenerateApiKey(user portainer.User, description string)
 		Prefix:      prefixedAPIKey[:7],
 		DateCreated: time.Now().Unix(),
 		Digest:      hashDigest,
+		ExpiresAt:   expiresAt,
+		Scope:       scope,
 	}
 
 	err := a.apiKeyRepository.CreateAPIKey(apiKey)
@@ -125,3 +133,24 @@ func (a *apiKeyService) DeleteAPIKey(apiKeyID portainer.APIKeyID) error {
 func (a *apiKeyService) InvalidateUserKeyCache(userId portainer.UserID) bool {
 	return a.cache.InvalidateUserKeyCache(userId)
 }
+
+// DeleteExpiredAPIKeys deletes the API keys expired at the specified time.
+func (a *apiKeyService) DeleteExpiredAPIKeys(now time.Time) error {
+	apiKeys, err := a.apiKeyRepository.GetAPIKeys()
+	if err != nil {
+		return errors.Wrap(err, "Unable to retrieve API keys")
+	}
+
+	for _, apiKey := range apiKeys {
+		if !IsExpired(apiKey, now) {
+			continue
+		}
+
+		err = a.DeleteAPIKey(apiKey.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -308,3 +308,36 @@ func Test_InvalidateUserKeyCache(t *testing.T) {
 		is.True(ok)
 	})
 }
+
+func Test_DeleteExpiredAPIKeys(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	service := NewAPIKeyService(store.APIKeyRepository(), store.User())
+
+	user := portainer.User{ID: 1}
+	store.User().Create(&user)
+
+	now := time.Now()
+
+	_, expiredAPIKey, err := service.GenerateScopedApiKey(user, "expired", now.Add(-time.Minute).Unix(), nil)
+	is.NoError(err)
+
+	_, validAPIKey, err := service.GenerateScopedApiKey(user, "valid", now.Add(time.Hour).Unix(), nil)
+	is.NoError(err)
+
+	_, permanentAPIKey, err := service.GenerateApiKey(user, "permanent")
+	is.NoError(err)
+
+	err = service.DeleteExpiredAPIKeys(now)
+	is.NoError(err)
+
+	apiKeys, err := service.GetAPIKeys(user.ID)
+	is.NoError(err)
+	is.ElementsMatch([]portainer.APIKey{*validAPIKey, *permanentAPIKey}, apiKeys)
+
+	_, _, ok := service.cache.Get(expiredAPIKey.Digest)
+	is.False(ok)
+}
@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/scheduler"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// retentionInterval is the delay between two runs of the audit log retention policy
+	retentionInterval = 1 * time.Hour
+	// forwardInterval is the delay between two deliveries of the audit logs to the forwarding destinations
+	forwardInterval = 5 * time.Second
+)
+
+// Service records the operations performed through the API in the audit log,
+// and forwards them to the destinations defined in the settings
+type Service struct {
+	dataStore dataservices.DataStore
+	forwarder *forwarder
+}
+
+// NewService returns a new audit log service. The audit logs waiting for delivery
+// to the forwarding destinations are kept in bufferPath.
+func NewService(dataStore dataservices.DataStore, bufferPath string) *Service {
+	return &Service{
+		dataStore: dataStore,
+		forwarder: newForwarder(bufferPath),
+	}
+}
+
+// Start schedules the retention policy of the audit log and the delivery to the forwarding destinations
+func (service *Service) Start(scheduler *scheduler.Scheduler) {
+	scheduler.StartJobEvery(retentionInterval, func() error {
+		err := service.ApplyRetention(time.Now())
+		if err != nil {
+			log.Error().Err(err).Msg("unable to apply the audit log retention policy")
+		}
+
+		return nil
+	})
+
+	scheduler.StartJobEvery(forwardInterval, func() error {
+		service.Forward()
+		return nil
+	})
+}
+
+// Record saves an audit log and queues it for delivery to the forwarding destinations
+func (service *Service) Record(auditLog *portainer.AuditLog) error {
+	settings, err := service.settings()
+	if err != nil {
+		return err
+	}
+
+	return service.record(settings, auditLog)
+}
+
+func (service *Service) record(settings portainer.AuditLogSettings, auditLog *portainer.AuditLog) error {
+	if ParseRetention(settings.Retention) != 0 {
+		err := service.dataStore.AuditLog().Create(auditLog)
+		if err != nil {
+			return err
+		}
+	}
+
+	return service.forwarder.enqueue(settings.Forwarders, auditLog)
+}
+
+// Forward delivers the queued audit logs to the forwarding destinations
+func (service *Service) Forward() {
+	settings, err := service.settings()
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to retrieve the audit log settings")
+		return
+	}
+
+	service.forwarder.forward(settings.Forwarders)
+}
+
+// ApplyRetention removes the audit logs older than the retention period
+func (service *Service) ApplyRetention(now time.Time) error {
+	settings, err := service.settings()
+	if err != nil {
+		return err
+	}
+
+	retention := ParseRetention(settings.Retention)
+	if retention == 0 {
+		return nil
+	}
+
+	return service.dataStore.AuditLog().DeleteAuditLogsBefore(now.Add(-retention).Unix())
+}
+
+func (service *Service) settings() (portainer.AuditLogSettings, error) {
+	settings, err := service.dataStore.Settings().Settings()
+	if err != nil {
+		return portainer.AuditLogSettings{}, err
+	}
+
+	return settings.AuditLog, nil
+}
+
+// isEnabled returns true when the audit logs are kept or forwarded
+func isEnabled(settings portainer.AuditLogSettings) bool {
+	return ParseRetention(settings.Retention) != 0 || len(settings.Forwarders) > 0
+}
+
+// ParseRetention parses the audit log retention, falling back to the default value
+// for an empty or invalid retention
+func ParseRetention(value string) time.Duration {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		duration, _ = time.ParseDuration(portainer.DefaultAuditLogRetention)
+	}
+
+	return duration
+}
@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/pkg/errors"
+)
+
+// maxBufferedAuditLogs is the maximum number of audit logs waiting for delivery to a destination,
+// the oldest ones are dropped beyond
+const maxBufferedAuditLogs = 100000
+
+// diskBuffer keeps the audit logs waiting for delivery to a destination in a JSON Lines file,
+// so that they survive the collector outages and the restarts
+type diskBuffer struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newDiskBuffer(path string) *diskBuffer {
+	return &diskBuffer{path: path}
+}
+
+// append adds an audit log at the end of the buffer
+func (buffer *diskBuffer) append(auditLog *portainer.AuditLog) error {
+	data, err := json.Marshal(auditLog)
+	if err != nil {
+		return err
+	}
+
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+
+	f, err := os.OpenFile(buffer.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// flush sends the buffered audit logs in order, by batches of batchSize. It stops at the first batch
+// that cannot be sent and keeps it, along with the following ones, for the next flush.
+// The buffer is not locked while sending, the audit logs appended meanwhile are kept for the next flush.
+func (buffer *diskBuffer) flush(batchSize int, send func(auditLogs []portainer.AuditLog) error) error {
+	buffer.mu.Lock()
+	auditLogs, err := buffer.read()
+	buffer.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	for sent < len(auditLogs) {
+		end := sent + batchSize
+		if end > len(auditLogs) {
+			end = len(auditLogs)
+		}
+
+		err = send(auditLogs[sent:end])
+		if err != nil {
+			break
+		}
+
+		sent = end
+	}
+
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+
+	if dropErr := buffer.drop(sent); dropErr != nil {
+		return dropErr
+	}
+
+	return err
+}
+
+func (buffer *diskBuffer) read() ([]portainer.AuditLog, error) {
+	f, err := os.Open(buffer.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer f.Close()
+
+	auditLogs := make([]portainer.AuditLog, 0)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var auditLog portainer.AuditLog
+		if err := json.Unmarshal(scanner.Bytes(), &auditLog); err != nil {
+			continue
+		}
+
+		auditLogs = append(auditLogs, auditLog)
+	}
+
+	return auditLogs, scanner.Err()
+}
+
+// drop removes the first count audit logs of the buffer, and the oldest ones beyond maxBufferedAuditLogs
+func (buffer *diskBuffer) drop(count int) error {
+	auditLogs, err := buffer.read()
+	if err != nil {
+		return err
+	}
+
+	if count > len(auditLogs) {
+		count = len(auditLogs)
+	}
+
+	if len(auditLogs)-count > maxBufferedAuditLogs {
+		count = len(auditLogs) - maxBufferedAuditLogs
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	tmpPath := buffer.path + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(f)
+	encoder := json.NewEncoder(writer)
+	for i := count; i < len(auditLogs); i++ {
+		if err = encoder.Encode(&auditLogs[i]); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "unable to rewrite the audit log buffer")
+	}
+
+	return os.Rename(tmpPath, buffer.path)
+}
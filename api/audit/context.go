@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+
+	portainer "github.com/portainer/portainer/api"
+)
+
+type contextKey int
+
+const auditLogKey contextKey = iota
+
+// Resource represents the resource targeted by an audited request
+type Resource struct {
+	Type      string
+	ID        string
+	Operation string
+}
+
+func withAuditLog(r *http.Request, auditLog *portainer.AuditLog) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), auditLogKey, auditLog))
+}
+
+func auditLogFromRequest(r *http.Request) *portainer.AuditLog {
+	auditLog, _ := r.Context().Value(auditLogKey).(*portainer.AuditLog)
+	return auditLog
+}
+
+// SetUser records the user performing the request, when the request is audited
+func SetUser(r *http.Request, userID portainer.UserID, username string) {
+	auditLog := auditLogFromRequest(r)
+	if auditLog == nil {
+		return
+	}
+
+	auditLog.UserID = userID
+	auditLog.Username = username
+	if auditLog.AuthMethod == "" {
+		auditLog.AuthMethod = portainer.AuditLogAuthMethodJWT
+	}
+}
+
+// SetAuthMethod records the credential used to authenticate the request, when the request is audited
+func SetAuthMethod(r *http.Request, authMethod portainer.AuditLogAuthMethod) {
+	auditLog := auditLogFromRequest(r)
+	if auditLog == nil {
+		return
+	}
+
+	auditLog.AuthMethod = authMethod
+}
+
+// SetAPIKey records the API key used to authenticate the request, when the request is audited
+func SetAPIKey(r *http.Request, apiKeyID portainer.APIKeyID) {
+	auditLog := auditLogFromRequest(r)
+	if auditLog == nil {
+		return
+	}
+
+	auditLog.AuthMethod = portainer.AuditLogAuthMethodAPIKey
+	auditLog.APIKeyID = apiKeyID
+}
+
+// SetResource replaces the resource of the request guessed from its path, when the request is audited
+func SetResource(r *http.Request, resource Resource) {
+	auditLog := auditLogFromRequest(r)
+	if auditLog == nil {
+		return
+	}
+
+	auditLog.ResourceType = resource.Type
+	auditLog.ResourceID = resource.ID
+	auditLog.Operation = resource.Operation
+}
+
+// WithResource sets the resource of the audited requests served by next, for the paths
+// that do not follow the resource type and identifier layout
+func WithResource(resource Resource, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetResource(r, resource)
+		next.ServeHTTP(w, r)
+	})
+}
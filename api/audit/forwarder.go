@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/rs/zerolog/log"
+)
+
+const bufferFileExtension = ".jsonl"
+
+// forwarder delivers the audit logs to the forwarding destinations. The audit logs are first
+// appended to a buffer file per destination, then delivered in the background.
+type forwarder struct {
+	bufferPath string
+	mu         sync.Mutex
+	buffers    map[string]*diskBuffer
+	sending    sync.Mutex
+}
+
+func newForwarder(bufferPath string) *forwarder {
+	return &forwarder{
+		bufferPath: bufferPath,
+		buffers:    make(map[string]*diskBuffer),
+	}
+}
+
+// enqueue adds an audit log to the buffer of every destination
+func (f *forwarder) enqueue(destinations []portainer.AuditLogForwarder, auditLog *portainer.AuditLog) error {
+	for _, destination := range destinations {
+		buffer, err := f.buffer(destination.Name)
+		if err != nil {
+			return err
+		}
+
+		err = buffer.append(auditLog)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forward delivers the buffered audit logs of every destination. The audit logs that cannot be delivered
+// are kept for the next run, the buffers of the destinations that no longer exist are removed.
+func (f *forwarder) forward(destinations []portainer.AuditLogForwarder) {
+	if !f.sending.TryLock() {
+		return
+	}
+	defer f.sending.Unlock()
+
+	f.removeBuffers(destinations)
+
+	for _, destination := range destinations {
+		sink, err := newSink(destination)
+		if err != nil {
+			log.Warn().Err(err).Str("destination", destination.Name).Msg("invalid audit log forwarder")
+			continue
+		}
+
+		buffer, err := f.buffer(destination.Name)
+		if err != nil {
+			log.Warn().Err(err).Str("destination", destination.Name).Msg("unable to open the audit log buffer")
+			continue
+		}
+
+		err = buffer.flush(sink.batchSize(), sink.send)
+		if err != nil {
+			log.Warn().Err(err).Str("destination", destination.Name).Msg("unable to forward the audit logs, they will be sent again later")
+		}
+	}
+}
+
+func (f *forwarder) buffer(name string) (*diskBuffer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := f.bufferFilePath(name)
+
+	buffer, ok := f.buffers[path]
+	if ok {
+		return buffer, nil
+	}
+
+	err := os.MkdirAll(f.bufferPath, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer = newDiskBuffer(path)
+	f.buffers[path] = buffer
+
+	return buffer, nil
+}
+
+// removeBuffers removes the buffer files that do not belong to any destination
+func (f *forwarder) removeBuffers(destinations []portainer.AuditLogForwarder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	paths := make(map[string]bool, len(destinations))
+	for _, destination := range destinations {
+		paths[f.bufferFilePath(destination.Name)] = true
+	}
+
+	entries, err := os.ReadDir(f.bufferPath)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(f.bufferPath, entry.Name())
+		if paths[path] || !strings.HasSuffix(path, bufferFileExtension) {
+			continue
+		}
+
+		err := os.Remove(path)
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("unable to remove the audit log buffer")
+		}
+
+		delete(f.buffers, path)
+	}
+}
+
+// bufferFilePath returns the path of the buffer file of a destination, named after a hash of
+// the destination name so that any name can be used
+func (f *forwarder) bufferFilePath(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	return filepath.Join(f.bufferPath, hex.EncodeToString(hash[:8])+bufferFileExtension)
+}
@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Forward_shouldRetryBufferedAuditLogsWithHTTPCollector(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	var mu sync.Mutex
+	available := false
+	var received []portainer.AuditLog
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var batch []portainer.AuditLog
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		assert.LessOrEqual(t, len(batch), 2)
+		received = append(received, batch...)
+	}))
+	defer collector.Close()
+
+	setForwarders(t, store, "0", portainer.AuditLogForwarder{
+		Name: "collector",
+		Type: portainer.HTTPAuditLogForwarder,
+		HTTP: portainer.AuditLogHTTPSettings{URL: collector.URL, AuthorizationHeader: "Bearer secret", BatchSize: 2},
+	})
+
+	bufferPath := t.TempDir()
+	service := NewService(store, bufferPath)
+
+	for i := 1; i <= 3; i++ {
+		err := service.Record(&portainer.AuditLog{Timestamp: int64(i), Operation: "create"})
+		require.NoError(t, err)
+	}
+
+	service.Forward()
+	assert.Empty(t, received)
+
+	// the buffer survives a restart
+	service = NewService(store, bufferPath)
+
+	mu.Lock()
+	available = true
+	mu.Unlock()
+
+	service.Forward()
+
+	require.Len(t, received, 3)
+	for i, auditLog := range received {
+		assert.Equal(t, int64(i+1), auditLog.Timestamp)
+	}
+
+	// with a zero retention, the audit logs are only forwarded
+	auditLogs, err := store.AuditLog().AuditLogs(nil)
+	require.NoError(t, err)
+	assert.Empty(t, auditLogs)
+
+	service.Forward()
+	assert.Len(t, received, 3)
+}
+
+func Test_Forward_shouldSendRFC5424MessagesOverTCP(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	messages := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		for i := 0; i < 2; i++ {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+
+			size, _ := strconv.Atoi(strings.TrimSpace(length))
+			message := make([]byte, size)
+			if _, err := io.ReadFull(reader, message); err != nil {
+				return
+			}
+
+			messages <- string(message)
+		}
+	}()
+
+	setForwarders(t, store, "720h", portainer.AuditLogForwarder{
+		Name:   "siem",
+		Type:   portainer.SyslogAuditLogForwarder,
+		Syslog: portainer.AuditLogSyslogSettings{Protocol: "tcp", Address: listener.Addr().String()},
+	})
+
+	service := NewService(store, t.TempDir())
+
+	err = service.Record(&portainer.AuditLog{Timestamp: 1667300000, Username: "admin", Outcome: portainer.AuditLogOutcomeSuccess})
+	require.NoError(t, err)
+	err = service.Record(&portainer.AuditLog{Timestamp: 1667300001, Username: "bob", Outcome: portainer.AuditLogOutcomeDenied})
+	require.NoError(t, err)
+
+	service.Forward()
+
+	header := regexp.MustCompile(`^<(\d+)>1 (\S+) \S+ portainer - audit - (\{.*\})$`)
+
+	first := header.FindStringSubmatch(<-messages)
+	require.NotNil(t, first)
+	assert.Equal(t, "110", first[1]) // log audit facility, informational
+	assert.Equal(t, "2022-11-01T10:53:20Z", first[2])
+	assert.Contains(t, first[3], `"Username":"admin"`)
+
+	second := header.FindStringSubmatch(<-messages)
+	require.NotNil(t, second)
+	assert.Equal(t, "108", second[1]) // log audit facility, warning
+	assert.Contains(t, second[3], `"Username":"bob"`)
+
+	// the audit logs are also kept
+	auditLogs, err := store.AuditLog().AuditLogs(nil)
+	require.NoError(t, err)
+	assert.Len(t, auditLogs, 2)
+}
+
+func Test_ValidateForwarder(t *testing.T) {
+	tests := []struct {
+		forwarder portainer.AuditLogForwarder
+		valid     bool
+	}{
+		{portainer.AuditLogForwarder{Name: "a", Type: portainer.SyslogAuditLogForwarder, Syslog: portainer.AuditLogSyslogSettings{Protocol: "udp", Address: "10.0.0.1:514"}}, true},
+		{portainer.AuditLogForwarder{Name: "a", Type: portainer.SyslogAuditLogForwarder, Syslog: portainer.AuditLogSyslogSettings{Protocol: "tls", Address: "syslog:6514"}}, true},
+		{portainer.AuditLogForwarder{Name: "a", Type: portainer.SyslogAuditLogForwarder, Syslog: portainer.AuditLogSyslogSettings{Protocol: "http", Address: "syslog:514"}}, false},
+		{portainer.AuditLogForwarder{Name: "a", Type: portainer.SyslogAuditLogForwarder, Syslog: portainer.AuditLogSyslogSettings{Protocol: "tcp", Address: "syslog"}}, false},
+		{portainer.AuditLogForwarder{Name: "a", Type: portainer.HTTPAuditLogForwarder, HTTP: portainer.AuditLogHTTPSettings{URL: "https://collector/logs"}}, true},
+		{portainer.AuditLogForwarder{Name: "a", Type: portainer.HTTPAuditLogForwarder, HTTP: portainer.AuditLogHTTPSettings{URL: "collector/logs"}}, false},
+		{portainer.AuditLogForwarder{Type: portainer.HTTPAuditLogForwarder, HTTP: portainer.AuditLogHTTPSettings{URL: "https://collector/logs"}}, false},
+		{portainer.AuditLogForwarder{Name: "a"}, false},
+	}
+
+	for i, test := range tests {
+		err := ValidateForwarder(test.forwarder)
+		assert.Equal(t, test.valid, err == nil, "case %d: %v", i, err)
+	}
+}
+
+func setForwarders(t *testing.T, store *datastore.Store, retention string, forwarders ...portainer.AuditLogForwarder) {
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+
+	settings.AuditLog.Retention = retention
+	settings.AuditLog.Forwarders = forwarders
+
+	err = store.Settings().UpdateSettings(settings)
+	require.NoError(t, err)
+}
@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/rs/zerolog/log"
+)
+
+// actionSegments are the path segments naming an operation on a collection rather than a resource
+var actionSegments = map[string]bool{
+	"build":  true,
+	"create": true,
+	"init":   true,
+	"join":   true,
+	"leave":  true,
+	"load":   true,
+	"logout": true,
+	"prune":  true,
+	"unlock": true,
+	"update": true,
+}
+
+// Middleware records the requests modifying a resource in the audit log, once they are served.
+// The bouncer and the proxies complete the audit log of the request through SetUser, SetAPIKey and SetResource.
+func (service *Service) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if service == nil || !isAuditedMethod(r.Method) || auditLogFromRequest(r) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		settings, err := service.settings()
+		if err != nil {
+			log.Warn().Err(err).Msg("unable to retrieve the audit log settings")
+		}
+
+		if err != nil || !isEnabled(settings) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		path := requestPath(r)
+		resource := ParseResource(r.Method, strings.TrimPrefix(path, "/api"))
+
+		auditLog := &portainer.AuditLog{
+			Timestamp:    time.Now().Unix(),
+			SourceIP:     sourceIP(r),
+			ResourceType: resource.Type,
+			ResourceID:   resource.ID,
+			Operation:    resource.Operation,
+			Method:       r.Method,
+			Path:         path,
+		}
+
+		if resource.Type == "endpoints" {
+			endpointID, err := strconv.Atoi(resource.ID)
+			if err == nil {
+				auditLog.EndpointID = portainer.EndpointID(endpointID)
+			}
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, withAuditLog(r, auditLog))
+
+		auditLog.StatusCode = recorder.statusCode
+		auditLog.Outcome = outcome(recorder.statusCode)
+
+		err = service.record(settings, auditLog)
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Msg("unable to record the audit log")
+		}
+	})
+}
+
+// ParseResource guesses the resource targeted by a request from its path, where the first segment
+// is the resource type and the second one the resource identifier. The operation is the last
+// segment of an action path or else derives from the method.
+func ParseResource(method, path string) Resource {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	resource := Resource{
+		Type:      segments[0],
+		Operation: Operation(method),
+	}
+
+	if len(segments) == 1 {
+		return resource
+	}
+
+	if actionSegments[segments[1]] {
+		resource.Operation = segments[1]
+		return resource
+	}
+
+	resource.ID = segments[1]
+	if method == http.MethodPost && len(segments) > 2 {
+		resource.Operation = segments[len(segments)-1]
+	}
+
+	return resource
+}
+
+// Operation returns the operation performed by a request method
+func Operation(method string) string {
+	switch method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut, http.MethodPatch:
+		return "update"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return strings.ToLower(method)
+	}
+}
+
+func isAuditedMethod(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch || method == http.MethodDelete
+}
+
+func outcome(statusCode int) portainer.AuditLogOutcome {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return portainer.AuditLogOutcomeDenied
+	case statusCode >= http.StatusBadRequest:
+		return portainer.AuditLogOutcomeFailure
+	default:
+		return portainer.AuditLogOutcomeSuccess
+	}
+}
+
+// requestPath returns the path of the request as sent by the client, before any prefix is stripped by the router
+func requestPath(r *http.Request) string {
+	if r.RequestURI != "" {
+		u, err := url.ParseRequestURI(r.RequestURI)
+		if err == nil {
+			return u.Path
+		}
+	}
+
+	return r.URL.Path
+}
+
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// statusRecorder keeps the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	if !recorder.wroteHeader {
+		recorder.statusCode = statusCode
+		recorder.wroteHeader = true
+	}
+
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (recorder *statusRecorder) Write(data []byte) (int, error) {
+	recorder.wroteHeader = true
+	return recorder.ResponseWriter.Write(data)
+}
+
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (recorder *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := recorder.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not support hijacking")
+	}
+
+	recorder.statusCode = http.StatusSwitchingProtocols
+	recorder.wroteHeader = true
+
+	return hijacker.Hijack()
+}
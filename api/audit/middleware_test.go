@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseResource(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected Resource
+	}{
+		{http.MethodPost, "/stacks", Resource{Type: "stacks", Operation: "create"}},
+		{http.MethodPut, "/stacks/5", Resource{Type: "stacks", ID: "5", Operation: "update"}},
+		{http.MethodPut, "/endpoints/1/settings", Resource{Type: "endpoints", ID: "1", Operation: "update"}},
+		{http.MethodPost, "/stacks/5/stop", Resource{Type: "stacks", ID: "5", Operation: "stop"}},
+		{http.MethodDelete, "/tags/2", Resource{Type: "tags", ID: "2", Operation: "delete"}},
+		{http.MethodPost, "/containers/create", Resource{Type: "containers", Operation: "create"}},
+		{http.MethodPost, "/volumes/prune", Resource{Type: "volumes", Operation: "prune"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, ParseResource(test.method, test.path), "%s %s", test.method, test.path)
+	}
+}
+
+func Test_Middleware_shouldRecordModifyingRequests(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	service := NewService(store, t.TempDir())
+
+	handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetUser(r, 2, "bob")
+		SetAPIKey(r, 3)
+		SetResource(r, Resource{Type: "containers", ID: "abc", Operation: "start"})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/endpoints/1/docker/containers/abc/start", nil)
+	r.RemoteAddr = "10.0.0.10:51234"
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/endpoints/1/docker/containers/json", nil))
+
+	auditLogs, err := store.AuditLog().AuditLogs(nil)
+	require.NoError(t, err)
+	require.Len(t, auditLogs, 1)
+
+	auditLog := auditLogs[0]
+	assert.Equal(t, portainer.UserID(2), auditLog.UserID)
+	assert.Equal(t, "bob", auditLog.Username)
+	assert.Equal(t, portainer.AuditLogAuthMethodAPIKey, auditLog.AuthMethod)
+	assert.Equal(t, portainer.APIKeyID(3), auditLog.APIKeyID)
+	assert.Equal(t, "10.0.0.10", auditLog.SourceIP)
+	assert.Equal(t, portainer.EndpointID(1), auditLog.EndpointID)
+	assert.Equal(t, "containers", auditLog.ResourceType)
+	assert.Equal(t, "abc", auditLog.ResourceID)
+	assert.Equal(t, "start", auditLog.Operation)
+	assert.Equal(t, http.StatusNoContent, auditLog.StatusCode)
+	assert.Equal(t, portainer.AuditLogOutcomeSuccess, auditLog.Outcome)
+}
+
+func Test_Middleware_shouldRecordDeniedRequests(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	service := NewService(store, t.TempDir())
+
+	handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/users/4", nil))
+
+	auditLogs, err := store.AuditLog().AuditLogs(nil)
+	require.NoError(t, err)
+	require.Len(t, auditLogs, 1)
+	assert.Equal(t, "users", auditLogs[0].ResourceType)
+	assert.Equal(t, "4", auditLogs[0].ResourceID)
+	assert.Equal(t, "delete", auditLogs[0].Operation)
+	assert.Equal(t, portainer.AuditLogOutcomeDenied, auditLogs[0].Outcome)
+}
+
+func Test_Middleware_shouldNotRecordWhenDisabled(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	settings.AuditLog.Retention = "0"
+	err = store.Settings().UpdateSettings(settings)
+	require.NoError(t, err)
+
+	service := NewService(store, t.TempDir())
+
+	handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/tags", nil))
+
+	auditLogs, err := store.AuditLog().AuditLogs(nil)
+	require.NoError(t, err)
+	assert.Empty(t, auditLogs)
+}
+
+func Test_ApplyRetention_shouldRemoveExpiredAuditLogs(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	now := time.Now()
+	retention := ParseRetention(portainer.DefaultAuditLogRetention)
+
+	for _, timestamp := range []int64{now.Add(-retention - time.Hour).Unix(), now.Add(-time.Hour).Unix()} {
+		err := store.AuditLog().Create(&portainer.AuditLog{Timestamp: timestamp})
+		require.NoError(t, err)
+	}
+
+	err := NewService(store, t.TempDir()).ApplyRetention(now)
+	require.NoError(t, err)
+
+	auditLogs, err := store.AuditLog().AuditLogs(nil)
+	require.NoError(t, err)
+	require.Len(t, auditLogs, 1)
+	assert.Equal(t, now.Add(-time.Hour).Unix(), auditLogs[0].Timestamp)
+}
@@ -0,0 +1,256 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	sinkTimeout = 10 * time.Second
+	// defaultSyslogFacility is the log audit facility
+	defaultSyslogFacility = 13
+	defaultHTTPBatchSize  = 100
+	// syslogBatchSize is the number of messages sent through a single syslog connection
+	syslogBatchSize = 500
+)
+
+// sink delivers batches of audit logs to a destination
+type sink interface {
+	send(auditLogs []portainer.AuditLog) error
+	batchSize() int
+}
+
+func newSink(destination portainer.AuditLogForwarder) (sink, error) {
+	switch destination.Type {
+	case portainer.SyslogAuditLogForwarder:
+		return newSyslogSink(destination.Syslog)
+	case portainer.HTTPAuditLogForwarder:
+		return newHTTPSink(destination.HTTP)
+	default:
+		return nil, errors.Errorf("unsupported audit log forwarder type %d", destination.Type)
+	}
+}
+
+// ValidateForwarder checks the settings of an audit log forwarding destination
+func ValidateForwarder(destination portainer.AuditLogForwarder) error {
+	if destination.Name == "" {
+		return errors.New("the name is required")
+	}
+
+	_, err := newSink(destination)
+	return err
+}
+
+func tlsConfiguration(caCert string, skipVerify bool) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: skipVerify,
+	}
+
+	if caCert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, errors.New("invalid CA certificate")
+		}
+
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// syslogSink sends RFC5424 messages to a syslog server. The messages are framed with octet counting
+// over TCP and TLS (RFC6587, RFC5425), and sent one per datagram over UDP (RFC5426).
+type syslogSink struct {
+	settings  portainer.AuditLogSyslogSettings
+	tlsConfig *tls.Config
+	hostname  string
+}
+
+func newSyslogSink(settings portainer.AuditLogSyslogSettings) (*syslogSink, error) {
+	if settings.Address == "" {
+		return nil, errors.New("the syslog server address is required")
+	}
+
+	if _, _, err := net.SplitHostPort(settings.Address); err != nil {
+		return nil, errors.Wrap(err, "invalid syslog server address")
+	}
+
+	if settings.Facility < 0 || settings.Facility > 23 {
+		return nil, errors.New("the syslog facility must be between 0 and 23")
+	}
+
+	if settings.Facility == 0 {
+		settings.Facility = defaultSyslogFacility
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &syslogSink{
+		settings: settings,
+		hostname: hostname,
+	}
+
+	switch settings.Protocol {
+	case "tcp", "udp":
+	case "tls":
+		s.tlsConfig, err = tlsConfiguration(settings.CACert, settings.TLSSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unsupported syslog protocol %q, valid values are: tcp, udp, tls", settings.Protocol)
+	}
+
+	return s, nil
+}
+
+func (s *syslogSink) batchSize() int {
+	return syslogBatchSize
+}
+
+func (s *syslogSink) send(auditLogs []portainer.AuditLog) error {
+	dialer := &net.Dialer{Timeout: sinkTimeout}
+
+	var conn net.Conn
+	var err error
+	if s.settings.Protocol == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.settings.Address, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(s.settings.Protocol, s.settings.Address)
+	}
+	if err != nil {
+		return errors.Wrap(err, "unable to connect to the syslog server")
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(sinkTimeout))
+
+	for i := range auditLogs {
+		message, err := s.message(&auditLogs[i])
+		if err != nil {
+			return err
+		}
+
+		if s.settings.Protocol != "udp" {
+			message = append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+		}
+
+		if _, err := conn.Write(message); err != nil {
+			return errors.Wrap(err, "unable to send the audit log to the syslog server")
+		}
+	}
+
+	return nil
+}
+
+// message formats an audit log as an RFC5424 message, the audit log is the JSON message body
+func (s *syslogSink) message(auditLog *portainer.AuditLog) ([]byte, error) {
+	body, err := json.Marshal(auditLog)
+	if err != nil {
+		return nil, err
+	}
+
+	priority := s.settings.Facility*8 + syslogSeverity(auditLog.Outcome)
+	timestamp := time.Unix(auditLog.Timestamp, 0).UTC().Format(time.RFC3339)
+
+	header := fmt.Sprintf("<%d>1 %s %s portainer - audit - ", priority, timestamp, s.hostname)
+
+	return append([]byte(header), body...), nil
+}
+
+func syslogSeverity(outcome portainer.AuditLogOutcome) int {
+	switch outcome {
+	case portainer.AuditLogOutcomeDenied:
+		return 4 // warning
+	case portainer.AuditLogOutcomeFailure:
+		return 5 // notice
+	default:
+		return 6 // informational
+	}
+}
+
+// httpSink posts batches of audit logs as JSON arrays to a collector
+type httpSink struct {
+	settings portainer.AuditLogHTTPSettings
+	client   *http.Client
+}
+
+func newHTTPSink(settings portainer.AuditLogHTTPSettings) (*httpSink, error) {
+	u, err := url.Parse(settings.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("the collector URL must be an http or https URL")
+	}
+
+	if settings.BatchSize < 0 {
+		return nil, errors.New("the batch size must be positive")
+	}
+
+	if settings.BatchSize == 0 {
+		settings.BatchSize = defaultHTTPBatchSize
+	}
+
+	tlsConfig, err := tlsConfiguration(settings.CACert, settings.TLSSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpSink{
+		settings: settings,
+		client: &http.Client{
+			Timeout: sinkTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}, nil
+}
+
+func (s *httpSink) batchSize() int {
+	return s.settings.BatchSize
+}
+
+func (s *httpSink) send(auditLogs []portainer.AuditLog) error {
+	body, err := json.Marshal(auditLogs)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.settings.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.settings.AuthorizationHeader != "" {
+		req.Header.Set("Authorization", s.settings.AuthorizationHeader)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "unable to send the audit logs to the collector")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("the collector responded with the status %d", resp.StatusCode)
+	}
+
+	return nil
+}
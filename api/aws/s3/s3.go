@@ -0,0 +1,59 @@
+package s3
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type (
+	Service struct {
+		bucket string
+		client *s3.Client
+	}
+)
+
+// NewService creates a service storing objects in a bucket of Amazon S3 or, when endpoint is not empty,
+// of the S3-compatible service available at endpoint (MinIO for example).
+func NewService(endpoint, region, bucket, accessKey, secretKey string) *Service {
+	options := s3.Options{
+		Region:      region,
+		Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
+	}
+
+	if endpoint != "" {
+		// S3-compatible services are usually not reachable through virtual hosted-style URLs
+		options.EndpointResolver = s3.EndpointResolverFromURL(endpoint)
+		options.UsePathStyle = true
+	}
+
+	return &Service{
+		bucket: bucket,
+		client: s3.New(options),
+	}
+}
+
+// PutObject stores the content of body under key
+func (s *Service) PutObject(key string, body io.ReadSeeker, size int64) error {
+	_, err := s.client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: size,
+	})
+
+	return err
+}
+
+// DeleteObject removes the object stored under key
+func (s *Service) DeleteObject(key string) error {
+	_, err := s.client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
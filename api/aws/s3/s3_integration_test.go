@@ -0,0 +1,69 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Runs against an S3-compatible service, a local MinIO for example:
+// docker run -p 9000:9000 minio/minio server /data
+// INTEGRATION_TEST=1 S3_ENDPOINT=http://localhost:9000 S3_BUCKET=backups S3_ACCESS_KEY=minioadmin S3_SECRET_KEY=minioadmin go test ./aws/s3/
+func TestService_PutAndDeleteObject(t *testing.T) {
+	ensureIntegrationTest(t)
+
+	service := NewService(
+		os.Getenv("S3_ENDPOINT"),
+		"us-east-1",
+		getRequiredValue(t, "S3_BUCKET"),
+		getRequiredValue(t, "S3_ACCESS_KEY"),
+		getRequiredValue(t, "S3_SECRET_KEY"),
+	)
+
+	key := "portainer-integration-test/object.txt"
+	content := "content"
+
+	err := service.PutObject(key, strings.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+
+	output, err := service.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(service.bucket),
+		Key:    aws.String(key),
+	})
+	require.NoError(t, err)
+	defer output.Body.Close()
+
+	stored, err := io.ReadAll(output.Body)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(stored))
+
+	err = service.DeleteObject(key)
+	assert.NoError(t, err)
+
+	_, err = service.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(service.bucket),
+		Key:    aws.String(key),
+	})
+	assert.Error(t, err, "the object should be removed")
+}
+
+func getRequiredValue(t *testing.T, name string) string {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		t.Fatalf("can't find required env var \"%s\"", name)
+	}
+	return value
+}
+
+func ensureIntegrationTest(t *testing.T) {
+	if _, ok := os.LookupEnv("INTEGRATION_TEST"); !ok {
+		t.Skip("skip an integration test")
+	}
+}
@@ -32,8 +32,9 @@ var filesToBackup = []string{
 	"tls",
 }
 
-// Creates a tar.gz system archive and encrypts it if password is not empty. Returns a path to the archive file.
-func CreateBackupArchive(password string, gate *offlinegate.OfflineGate, datastore dataservices.DataStore, filestorePath string) (string, error) {
+// Creates a tar.gz system archive and encrypts it to the public keys if there are any, or else with the password if it is not empty.
+// Returns a path to the archive file.
+func CreateBackupArchive(password string, publicKeys []string, gate *offlinegate.OfflineGate, datastore dataservices.DataStore, filestorePath string) (string, error) {
 	unlock := gate.Lock()
 	defer unlock()
 
@@ -65,12 +66,21 @@ func CreateBackupArchive(password string, gate *offlinegate.OfflineGate, datasto
 		}
 	}
 
+	if err := writeManifest(backupDirPath); err != nil {
+		return "", errors.Wrap(err, "Failed to create the archive manifest")
+	}
+
 	archivePath, err := archive.TarGzDir(backupDirPath)
 	if err != nil {
 		return "", errors.Wrap(err, "Failed to make an archive")
 	}
 
-	if password != "" {
+	if len(publicKeys) > 0 {
+		archivePath, err = encryptToRecipients(archivePath, publicKeys)
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to encrypt backup to the public keys")
+		}
+	} else if password != "" {
 		archivePath, err = encrypt(archivePath, password)
 		if err != nil {
 			return "", errors.Wrap(err, "Failed to encrypt backup with the password")
@@ -80,6 +90,21 @@ func CreateBackupArchive(password string, gate *offlinegate.OfflineGate, datasto
 	return archivePath, nil
 }
 
+// RecipientPublicKeys returns the public keys of the backup recipients registered in the settings
+func RecipientPublicKeys(datastore dataservices.DataStore) ([]string, error) {
+	settings, err := datastore.Settings().Settings()
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeys := make([]string, 0, len(settings.BackupRecipients))
+	for _, recipient := range settings.BackupRecipients {
+		publicKeys = append(publicKeys, recipient.PublicKey)
+	}
+
+	return publicKeys, nil
+}
+
 func backupDb(backupDirPath string, datastore dataservices.DataStore) error {
 	backupWriter, err := os.Create(filepath.Join(backupDirPath, "portainer.db"))
 	if err != nil {
@@ -108,3 +133,22 @@ func encrypt(path string, passphrase string) (string, error) {
 
 	return outFileName, err
 }
+
+func encryptToRecipients(path string, publicKeys []string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	outFileName := fmt.Sprintf("%s.age", path)
+	out, err := os.Create(outFileName)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	err = crypto.RecipientEncrypt(in, out, publicKeys)
+
+	return outFileName, err
+}
@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/aws/s3"
+	"github.com/portainer/portainer/api/filesystem"
+)
+
+// archiveDestination stores the archives of a backup schedule
+type archiveDestination interface {
+	// store copies the archive to the destination and returns its location
+	store(archivePath string) (string, error)
+	// remove removes the archive stored at location
+	remove(location string) error
+}
+
+func newArchiveDestination(destination portainer.BackupDestination) (archiveDestination, error) {
+	switch destination.Type {
+	case portainer.LocalBackupDestination:
+		return &localDestination{path: destination.LocalPath}, nil
+	case portainer.S3BackupDestination:
+		if destination.S3 == nil {
+			return nil, errors.New("missing S3 settings")
+		}
+
+		settings := destination.S3
+		return &s3Destination{
+			service: s3.NewService(settings.Endpoint, settings.Region, settings.BucketName, settings.AccessKeyID, settings.SecretAccessKey),
+			prefix:  settings.Prefix,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported backup destination type: %d", destination.Type)
+}
+
+type localDestination struct {
+	path string
+}
+
+func (destination *localDestination) store(archivePath string) (string, error) {
+	err := os.MkdirAll(destination.path, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	err = filesystem.CopyPath(archivePath, destination.path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(destination.path, filepath.Base(archivePath)), nil
+}
+
+func (destination *localDestination) remove(location string) error {
+	err := os.Remove(location)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+type s3Destination struct {
+	service *s3.Service
+	prefix  string
+}
+
+func (destination *s3Destination) store(archivePath string) (string, error) {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	info, err := archive.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	key := destination.prefix + filepath.Base(archivePath)
+
+	err = destination.service.PutObject(key, archive, info.Size())
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+func (destination *s3Destination) remove(location string) error {
+	return destination.service.DeleteObject(location)
+}
@@ -0,0 +1,240 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+)
+
+const manifestFileName = "manifest.json"
+
+type (
+	// Manifest describes the content of a backup archive
+	Manifest struct {
+		// Version of the database of the archive
+		DBVersion int `json:"DBVersion"`
+		// Edition of the instance of the archive
+		Edition portainer.SoftwareEdition `json:"Edition"`
+		// Unix timestamp of the archive creation
+		CreatedAt int64 `json:"CreatedAt"`
+		// SHA-256 checksum of each file of the archive, indexed by path relative to the archive root
+		Files map[string]string `json:"Files"`
+	}
+
+	// ArchiveReport is the result of the validation of a backup archive
+	ArchiveReport struct {
+		// Whether the archive can be restored
+		Valid bool `json:"Valid"`
+		// Problems preventing the archive restore
+		Errors []string `json:"Errors,omitempty"`
+		// Problems not preventing the archive restore
+		Warnings []string `json:"Warnings,omitempty"`
+		// Version of the database of the archive
+		DBVersion int `json:"DBVersion"`
+		// Edition of the instance of the archive, 0 when the archive has no manifest
+		Edition portainer.SoftwareEdition `json:"Edition"`
+		// Unix timestamp of the archive creation, 0 when the archive has no manifest
+		CreatedAt int64 `json:"CreatedAt"`
+		// Files and directories of the archive root
+		Files []string `json:"Files"`
+		// Number of objects of each bucket of the database export of the archive
+		Buckets map[string]int `json:"Buckets"`
+	}
+)
+
+// writeManifest lists the files of the backup directory with their checksum in the manifest file of the directory.
+// The database of a running instance is always migrated to the version and edition of the instance.
+func writeManifest(backupDirPath string) error {
+	manifest := Manifest{
+		DBVersion: portainer.DBVersion,
+		Edition:   portainer.PortainerCE,
+		CreatedAt: time.Now().Unix(),
+		Files:     make(map[string]string),
+	}
+
+	err := filepath.WalkDir(backupDirPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(backupDirPath, path)
+		if err != nil {
+			return err
+		}
+
+		_, manifest.Files[filepath.ToSlash(relativePath)], err = checksum(path)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(backupDirPath, manifestFileName), data, 0600)
+}
+
+// validateArchiveContent checks the content of an extracted archive against its manifest and the current database version
+func validateArchiveContent(archivePath string) (*ArchiveReport, error) {
+	report := &ArchiveReport{Buckets: make(map[string]int)}
+
+	entries, err := os.ReadDir(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Name() != manifestFileName {
+			report.Files = append(report.Files, entry.Name())
+		}
+	}
+
+	manifest, err := readManifest(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest == nil {
+		report.Warnings = append(report.Warnings, "the archive has no manifest, the integrity of its files cannot be checked")
+	} else {
+		report.DBVersion = manifest.DBVersion
+		report.Edition = manifest.Edition
+		report.CreatedAt = manifest.CreatedAt
+
+		report.Errors = append(report.Errors, checkManifestFiles(archivePath, manifest)...)
+
+		if manifest.Edition != portainer.PortainerCE {
+			report.Errors = append(report.Errors, fmt.Sprintf("the archive was created by another edition of Portainer (%d)", manifest.Edition))
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(archivePath, "portainer.db")); err != nil {
+		report.Errors = append(report.Errors, "the archive has no database")
+	}
+
+	export, err := readExport(archivePath)
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("the database export of the archive cannot be read: %s", err))
+	} else if export != nil {
+		for bucketName, content := range export {
+			if objects, ok := content.([]interface{}); ok {
+				report.Buckets[bucketName] = len(objects)
+			}
+		}
+
+		if manifest == nil {
+			report.DBVersion = exportDBVersion(export)
+		}
+	}
+
+	if report.DBVersion > portainer.DBVersion {
+		report.Errors = append(report.Errors, fmt.Sprintf("the archive database version %d is newer than the supported version %d", report.DBVersion, portainer.DBVersion))
+	}
+
+	report.Valid = len(report.Errors) == 0
+
+	return report, nil
+}
+
+// readManifest reads the manifest of an extracted archive, returns nil when the archive has no manifest
+func readManifest(archivePath string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(archivePath, manifestFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+func checkManifestFiles(archivePath string, manifest *Manifest) []string {
+	var errors []string
+
+	paths := make([]string, 0, len(manifest.Files))
+	for path := range manifest.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		_, sum, err := checksum(filepath.Join(archivePath, filepath.FromSlash(path)))
+		if os.IsNotExist(err) {
+			errors = append(errors, fmt.Sprintf("the file %s is missing", path))
+		} else if err != nil {
+			errors = append(errors, fmt.Sprintf("the file %s cannot be read: %s", path, err))
+		} else if sum != manifest.Files[path] {
+			errors = append(errors, fmt.Sprintf("the checksum of the file %s does not match the manifest", path))
+		}
+	}
+
+	filepath.WalkDir(archivePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(archivePath, path)
+		if err != nil {
+			return err
+		}
+
+		relativePath = filepath.ToSlash(relativePath)
+		if _, ok := manifest.Files[relativePath]; !ok && relativePath != manifestFileName {
+			errors = append(errors, fmt.Sprintf("the file %s is not listed in the manifest", relativePath))
+		}
+
+		return nil
+	})
+
+	return errors
+}
+
+// exportFilePath returns the path of the database export of an extracted archive, an empty path when there is none
+func exportFilePath(archivePath string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(archivePath, "export-*.json"))
+	if err != nil || len(matches) == 0 {
+		return "", err
+	}
+
+	return matches[0], nil
+}
+
+func readExport(archivePath string) (map[string]interface{}, error) {
+	exportPath, err := exportFilePath(archivePath)
+	if err != nil || exportPath == "" {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var export map[string]interface{}
+	err = json.Unmarshal(data, &export)
+	return export, err
+}
+
+func exportDBVersion(export map[string]interface{}) int {
+	version, _ := export["version"].(map[string]interface{})
+	dbVersion, _ := version["DB_VERSION"].(string)
+
+	v, _ := strconv.Atoi(dbVersion)
+	return v
+}
@@ -1,16 +1,21 @@
 package backup
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/archive"
 	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/database/sqlite"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/filesystem"
 	"github.com/portainer/portainer/api/http/offlinegate"
@@ -18,22 +23,31 @@ import (
 
 var filesToRestore = append(filesToBackup, "portainer.db")
 
+// bucketDirectories are the filestore directories holding the files of the objects of a bucket,
+// in a sub-directory named after the object identifier
+var bucketDirectories = map[string]string{
+	"customtemplates": "custom_templates",
+	"edge_stack":      "edge_stacks",
+	"edgejobs":        "edge_jobs",
+	"stacks":          "compose",
+}
+
 // Restores system state from backup archive, will trigger system shutdown, when finished.
-func RestoreArchive(archive io.Reader, password string, filestorePath string, gate *offlinegate.OfflineGate, datastore dataservices.DataStore, shutdownTrigger context.CancelFunc) error {
-	var err error
-	if password != "" {
-		archive, err = decrypt(archive, password)
-		if err != nil {
-			return errors.Wrap(err, "failed to decrypt the archive")
-		}
+// The archive is validated before the system goes offline.
+func RestoreArchive(archive io.Reader, password, privateKey string, filestorePath string, gate *offlinegate.OfflineGate, datastore dataservices.DataStore, shutdownTrigger context.CancelFunc) error {
+	restorePath, err := extractToRestorePath(archive, password, privateKey, filestorePath)
+	defer os.RemoveAll(restorePath)
+	if err != nil {
+		return err
 	}
 
-	restorePath := filepath.Join(filestorePath, "restore", time.Now().Format("20060102150405"))
-	defer os.RemoveAll(filepath.Dir(restorePath))
-
-	err = extractArchive(archive, restorePath)
+	report, err := validateArchiveContent(restorePath)
 	if err != nil {
-		return errors.Wrap(err, "cannot extract files from the archive. Please ensure the password is correct and try again")
+		return errors.Wrap(err, "failed to validate the archive")
+	}
+
+	if !report.Valid {
+		return errors.Errorf("the archive cannot be restored: %s", strings.Join(report.Errors, ", "))
 	}
 
 	unlock := gate.Lock()
@@ -51,6 +65,131 @@ func RestoreArchive(archive io.Reader, password string, filestorePath string, ga
 	return nil
 }
 
+// ValidateArchive decrypts and extracts a backup archive, then checks its files against its manifest and
+// its database version against the current database version. The report lists the archive content.
+func ValidateArchive(archive io.Reader, password, privateKey string, filestorePath string) (*ArchiveReport, error) {
+	restorePath, err := extractToRestorePath(archive, password, privateKey, filestorePath)
+	defer os.RemoveAll(restorePath)
+	if err != nil {
+		return &ArchiveReport{Errors: []string{err.Error()}}, nil
+	}
+
+	return validateArchiveContent(restorePath)
+}
+
+// RestoreBuckets merges the objects of the given buckets of a backup archive into the running instance, along with
+// their files. The other objects of the instance are kept. The archive must have the current database version.
+// Returns the identifiers of the objects restored in each bucket.
+func RestoreBuckets(archive io.Reader, password, privateKey string, buckets []string, filestorePath string, gate *offlinegate.OfflineGate, datastore dataservices.DataStore) (map[string][]int, error) {
+	restorePath, err := extractToRestorePath(archive, password, privateKey, filestorePath)
+	defer os.RemoveAll(restorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := validateArchiveContent(restorePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to validate the archive")
+	}
+
+	if !report.Valid {
+		return nil, errors.Errorf("the archive cannot be restored: %s", strings.Join(report.Errors, ", "))
+	}
+
+	// the objects of the export are not migrated, they must have the current schema
+	if report.DBVersion != portainer.DBVersion {
+		return nil, errors.Errorf("the archive database version %d differs from the current version %d, only the whole archive can be restored", report.DBVersion, portainer.DBVersion)
+	}
+
+	exportPath, err := exportFilePath(restorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if exportPath == "" {
+		return nil, errors.New("the archive has no database export")
+	}
+
+	unlock := gate.Lock()
+	defer unlock()
+
+	merged, err := datastore.MergeBuckets(exportPath, buckets)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to restore the buckets")
+	}
+
+	for bucketName, ids := range merged {
+		directory, ok := bucketDirectories[bucketName]
+		if !ok {
+			continue
+		}
+
+		for _, id := range ids {
+			err := restoreObjectFiles(filepath.Join(restorePath, directory), filepath.Join(filestorePath, directory), strconv.Itoa(id))
+			if err != nil {
+				return merged, errors.Wrapf(err, "failed to restore the files of the object %d of the %s bucket", id, bucketName)
+			}
+		}
+	}
+
+	return merged, nil
+}
+
+// restoreObjectFiles replaces the files of an object with the files of the archive, when the archive has some
+func restoreObjectFiles(srcDir, destinationDir, objectDirName string) error {
+	if _, err := os.Stat(filepath.Join(srcDir, objectDirName)); os.IsNotExist(err) {
+		return nil
+	}
+
+	err := os.RemoveAll(filepath.Join(destinationDir, objectDirName))
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(destinationDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return filesystem.CopyDir(filepath.Join(srcDir, objectDirName), destinationDir, true)
+}
+
+// extractToRestorePath decrypts the archive with the private key if it is not empty, or else with the password if it is not empty.
+// Then it extracts the archive in a new directory of the filestore, unique to this call, which must be removed once the
+// archive content is no longer needed. The returned path is empty when the directory cannot be created.
+func extractToRestorePath(archive io.Reader, password, privateKey string, filestorePath string) (string, error) {
+	restoreDir := filepath.Join(filestorePath, "restore")
+
+	err := os.MkdirAll(restoreDir, 0700)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create the restore directory")
+	}
+
+	restorePath, err := os.MkdirTemp(restoreDir, time.Now().Format("20060102150405")+"-")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create the restore directory")
+	}
+
+	if privateKey != "" {
+		archive, err = crypto.IdentityDecrypt(archive, privateKey)
+		if err != nil {
+			return restorePath, errors.Wrap(err, "failed to decrypt the archive with the private key")
+		}
+	} else if password != "" {
+		archive, err = decrypt(archive, password)
+		if err != nil {
+			return restorePath, errors.Wrap(err, "failed to decrypt the archive")
+		}
+	}
+
+	err = extractArchive(archive, restorePath)
+	if err != nil {
+		return restorePath, errors.Wrap(err, "cannot extract files from the archive. Please ensure the password or the private key is correct and try again")
+	}
+
+	return restorePath, nil
+}
+
 func decrypt(r io.Reader, password string) (io.Reader, error) {
 	return crypto.AesDecrypt(r, []byte(password))
 }
@@ -72,14 +211,52 @@ func restoreFiles(srcDir string, destinationDir string) error {
 	// Prevent the possibility of having both databases.  Remove any default new instance
 	os.Remove(filepath.Join(destinationDir, boltdb.DatabaseFileName))
 	os.Remove(filepath.Join(destinationDir, boltdb.EncryptedDatabaseFileName))
+	os.Remove(filepath.Join(destinationDir, sqlite.DatabaseFileName))
+	os.Remove(filepath.Join(destinationDir, sqlite.EncryptedDatabaseFileName))
+
+	// The archive always names the database portainer.db, move it back to its SQLite name when needed
+	err := renameSQLiteDatabase(srcDir)
+	if err != nil {
+		return err
+	}
+
+	err = filesystem.CopyPath(filepath.Join(srcDir, sqlite.DatabaseFileName), destinationDir)
+	if err != nil {
+		return err
+	}
 
 	// Now copy the database.  It'll be either portainer.db or portainer.edb
 
 	// Note: CopyPath does not return an error if the source file doesn't exist
-	err := filesystem.CopyPath(filepath.Join(srcDir, boltdb.EncryptedDatabaseFileName), destinationDir)
+	err = filesystem.CopyPath(filepath.Join(srcDir, boltdb.EncryptedDatabaseFileName), destinationDir)
 	if err != nil {
 		return err
 	}
 
 	return filesystem.CopyPath(filepath.Join(srcDir, boltdb.DatabaseFileName), destinationDir)
 }
+
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// renameSQLiteDatabase renames the portainer.db file of the archive to portainer.sqlite
+// when it contains a SQLite database
+func renameSQLiteDatabase(srcDir string) error {
+	databasePath := filepath.Join(srcDir, boltdb.DatabaseFileName)
+
+	f, err := os.Open(databasePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	header := make([]byte, len(sqliteHeader))
+	_, err = io.ReadFull(f, header)
+	f.Close()
+	if err != nil || !bytes.Equal(header, sqliteHeader) {
+		return nil
+	}
+
+	return os.Rename(databasePath, filepath.Join(srcDir, sqlite.DatabaseFileName))
+}
@@ -0,0 +1,187 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/offlinegate"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ValidateArchive(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	filestorePath := t.TempDir()
+	archivePath, err := CreateBackupArchive("secret", nil, offlinegate.NewOfflineGate(), store, filestorePath)
+	require.NoError(t, err)
+
+	archive, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer archive.Close()
+
+	// the directory of a concurrent restore
+	concurrentRestorePath := filepath.Join(filestorePath, "restore", "concurrent")
+	err = os.MkdirAll(concurrentRestorePath, 0700)
+	require.NoError(t, err)
+
+	report, err := ValidateArchive(archive, "secret", "", filestorePath)
+	require.NoError(t, err)
+	assert.True(t, report.Valid, "the archive should be valid, errors: %v", report.Errors)
+	assert.DirExists(t, concurrentRestorePath, "only the directory of the validated archive should be removed")
+
+	restoreDirs, err := os.ReadDir(filepath.Join(filestorePath, "restore"))
+	require.NoError(t, err)
+	assert.Len(t, restoreDirs, 1, "the extracted archive should be removed")
+	assert.Equal(t, portainer.DBVersion, report.DBVersion)
+	assert.Contains(t, report.Files, "portainer.db")
+	assert.Equal(t, 1, report.Buckets["endpoint_groups"], "the archive should contain the default environment group")
+
+	_, err = archive.Seek(0, 0)
+	require.NoError(t, err)
+
+	report, err = ValidateArchive(archive, "terces", "", filestorePath)
+	require.NoError(t, err)
+	assert.False(t, report.Valid, "the archive should not be decrypted with another password")
+}
+
+func Test_ValidateArchive_encryptedToRecipients(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	otherIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	filestorePath := t.TempDir()
+	archivePath, err := CreateBackupArchive("", []string{identity.Recipient().String()}, offlinegate.NewOfflineGate(), store, filestorePath)
+	require.NoError(t, err)
+
+	archive, err := os.ReadFile(archivePath)
+	require.NoError(t, err)
+
+	report, err := ValidateArchive(bytes.NewReader(archive), "", identity.String(), filestorePath)
+	require.NoError(t, err)
+	assert.True(t, report.Valid, "the archive should be decrypted by the private key, errors: %v", report.Errors)
+
+	report, err = ValidateArchive(bytes.NewReader(archive), "", otherIdentity.String(), filestorePath)
+	require.NoError(t, err)
+	assert.False(t, report.Valid, "the archive should not be decrypted by another private key")
+
+	report, err = ValidateArchive(bytes.NewReader(archive), "", "", filestorePath)
+	require.NoError(t, err)
+	assert.False(t, report.Valid, "the archive should not be extracted without the private key")
+}
+
+func Test_validateArchiveContent(t *testing.T) {
+	archivePath := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(archivePath, "portainer.db"), []byte("database"), 0600)
+	require.NoError(t, err)
+
+	err = os.MkdirAll(filepath.Join(archivePath, "tls"), 0700)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(archivePath, "tls", "cert.pem"), []byte("certificate"), 0600)
+	require.NoError(t, err)
+
+	err = writeManifest(archivePath)
+	require.NoError(t, err)
+
+	report, err := validateArchiveContent(archivePath)
+	require.NoError(t, err)
+	assert.True(t, report.Valid)
+	assert.ElementsMatch(t, []string{"portainer.db", "tls"}, report.Files)
+
+	err = os.WriteFile(filepath.Join(archivePath, "tls", "cert.pem"), []byte("corrupted"), 0600)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(archivePath, "extra"), []byte("extra"), 0600)
+	require.NoError(t, err)
+
+	report, err = validateArchiveContent(archivePath)
+	require.NoError(t, err)
+	assert.False(t, report.Valid)
+	assert.Equal(t, []string{
+		"the checksum of the file tls/cert.pem does not match the manifest",
+		"the file extra is not listed in the manifest",
+	}, report.Errors)
+
+	os.Remove(filepath.Join(archivePath, "extra"))
+	err = writeManifest(archivePath)
+	require.NoError(t, err)
+
+	manifest, err := readManifest(archivePath)
+	require.NoError(t, err)
+
+	manifest.DBVersion = portainer.DBVersion + 1
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(archivePath, manifestFileName), data, 0600)
+	require.NoError(t, err)
+
+	report, err = validateArchiveContent(archivePath)
+	require.NoError(t, err)
+	assert.False(t, report.Valid, "an archive of a newer database version should not be restored")
+}
+
+func Test_RestoreBuckets_shouldRestoreStacksWithTheirFiles(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	filestorePath := t.TempDir()
+	composeFilePath := filepath.Join(filestorePath, "compose", "1", "docker-compose.yml")
+
+	err := os.MkdirAll(filepath.Dir(composeFilePath), 0700)
+	require.NoError(t, err)
+
+	err = os.WriteFile(composeFilePath, []byte("version: '3'"), 0600)
+	require.NoError(t, err)
+
+	err = store.Stack().Create(&portainer.Stack{ID: 1, Name: "stack", ProjectPath: filepath.Dir(composeFilePath)})
+	require.NoError(t, err)
+
+	err = store.Tag().Create(&portainer.Tag{Name: "tag"})
+	require.NoError(t, err)
+
+	gate := offlinegate.NewOfflineGate()
+	archivePath, err := CreateBackupArchive("", nil, gate, store, filestorePath)
+	require.NoError(t, err)
+
+	err = store.Stack().DeleteStack(1)
+	require.NoError(t, err)
+
+	err = os.RemoveAll(filepath.Join(filestorePath, "compose", "1"))
+	require.NoError(t, err)
+
+	err = store.Tag().DeleteTag(1)
+	require.NoError(t, err)
+
+	archive, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer archive.Close()
+
+	restored, err := RestoreBuckets(archive, "", "", []string{"stacks"}, filestorePath, gate, store)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]int{"stacks": {1}}, restored)
+
+	stack, err := store.Stack().Stack(1)
+	require.NoError(t, err)
+	assert.Equal(t, "stack", stack.Name)
+	assert.FileExists(t, composeFilePath)
+
+	tags, err := store.Tag().Tags()
+	require.NoError(t, err)
+	assert.Empty(t, tags, "the buckets not selected should not be restored")
+}
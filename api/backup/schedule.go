@@ -0,0 +1,289 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/http/offlinegate"
+	"github.com/portainer/portainer/api/scheduler"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrBackupRunning is returned when a run is requested while the schedule is already running
+var ErrBackupRunning = errors.New("a backup of this schedule is already running")
+
+// ScheduleService runs the backup schedules and keeps track of their runs
+type ScheduleService struct {
+	scheduler     *scheduler.Scheduler
+	dataStore     dataservices.DataStore
+	gate          *offlinegate.OfflineGate
+	filestorePath string
+
+	mu      sync.Mutex
+	jobs    map[portainer.BackupScheduleID]string
+	running map[portainer.BackupScheduleID]bool
+
+	// backups share the backup directory of the filestore, they are made one at a time
+	backupMu sync.Mutex
+}
+
+// NewScheduleService creates a service running the backup schedules with scheduler
+func NewScheduleService(scheduler *scheduler.Scheduler, dataStore dataservices.DataStore, gate *offlinegate.OfflineGate, filestorePath string) *ScheduleService {
+	return &ScheduleService{
+		scheduler:     scheduler,
+		dataStore:     dataStore,
+		gate:          gate,
+		filestorePath: filestorePath,
+		jobs:          make(map[portainer.BackupScheduleID]string),
+		running:       make(map[portainer.BackupScheduleID]bool),
+	}
+}
+
+// Start schedules all the backup schedules of the datastore. The runs left running by
+// a previous instance are marked as failed.
+func (service *ScheduleService) Start() error {
+	runs, err := service.dataStore.BackupRun().BackupRuns()
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch backup runs")
+	}
+
+	for _, run := range runs {
+		if run.Status != portainer.BackupRunRunning {
+			continue
+		}
+
+		run.Status = portainer.BackupRunFailed
+		run.Error = "the backup was interrupted"
+		run.EndTime = time.Now().Unix()
+
+		err := service.dataStore.BackupRun().UpdateBackupRun(run.ID, &run)
+		if err != nil {
+			return errors.Wrap(err, "failed to update backup run")
+		}
+	}
+
+	schedules, err := service.dataStore.BackupSchedule().BackupSchedules()
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch backup schedules")
+	}
+
+	for _, schedule := range schedules {
+		err := service.Schedule(&schedule)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Schedule starts the job of a backup schedule, replacing its current job
+func (service *ScheduleService) Schedule(schedule *portainer.BackupSchedule) error {
+	service.Unschedule(schedule.ID)
+
+	scheduleID := schedule.ID // to be captured by the scheduled function
+	jobID, err := service.scheduler.StartCronJob(schedule.CronExpression, func() error {
+		_, err := service.Run(scheduleID)
+		if err != nil {
+			log.Error().Err(err).Int("schedule_id", int(scheduleID)).Msg("scheduled backup failed")
+		}
+
+		// the job keeps running after a failed backup
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to schedule backup schedule %d", schedule.ID)
+	}
+
+	service.mu.Lock()
+	service.jobs[schedule.ID] = jobID
+	service.mu.Unlock()
+
+	return nil
+}
+
+// Unschedule stops the job of a backup schedule
+func (service *ScheduleService) Unschedule(scheduleID portainer.BackupScheduleID) {
+	service.mu.Lock()
+	jobID, ok := service.jobs[scheduleID]
+	delete(service.jobs, scheduleID)
+	service.mu.Unlock()
+
+	if ok {
+		service.scheduler.StopJob(jobID)
+	}
+}
+
+// Run creates a backup archive of a backup schedule, stores it at the schedule destination and
+// removes the archives exceeding the schedule retention. The returned run is also returned when the backup failed.
+func (service *ScheduleService) Run(scheduleID portainer.BackupScheduleID) (*portainer.BackupRun, error) {
+	service.mu.Lock()
+	if service.running[scheduleID] {
+		service.mu.Unlock()
+		return nil, ErrBackupRunning
+	}
+	service.running[scheduleID] = true
+	service.mu.Unlock()
+
+	defer func() {
+		service.mu.Lock()
+		delete(service.running, scheduleID)
+		service.mu.Unlock()
+	}()
+
+	schedule, err := service.dataStore.BackupSchedule().BackupSchedule(scheduleID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch backup schedule")
+	}
+
+	run := &portainer.BackupRun{
+		ScheduleID: schedule.ID,
+		Status:     portainer.BackupRunRunning,
+		StartTime:  time.Now().Unix(),
+	}
+
+	err = service.dataStore.BackupRun().Create(run)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create backup run")
+	}
+
+	destination, err := newArchiveDestination(schedule.Destination)
+	if err == nil {
+		err = service.backup(schedule, destination, run)
+	}
+
+	run.EndTime = time.Now().Unix()
+	run.Status = portainer.BackupRunSucceeded
+	if err != nil {
+		run.Status = portainer.BackupRunFailed
+		run.Error = err.Error()
+	}
+
+	updateErr := service.dataStore.BackupRun().UpdateBackupRun(run.ID, run)
+	if updateErr != nil {
+		return run, errors.Wrap(updateErr, "failed to update backup run")
+	}
+
+	if destination != nil {
+		retentionErr := service.applyRetention(schedule, destination)
+		if retentionErr != nil {
+			log.Warn().Err(retentionErr).Int("schedule_id", int(schedule.ID)).Msg("failed to apply the backup retention")
+		}
+	}
+
+	return run, err
+}
+
+func (service *ScheduleService) backup(schedule *portainer.BackupSchedule, destination archiveDestination, run *portainer.BackupRun) error {
+	service.backupMu.Lock()
+	defer service.backupMu.Unlock()
+
+	publicKeys, err := RecipientPublicKeys(service.dataStore)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch the backup recipients")
+	}
+
+	// the password is refused when the schedule is saved, but recipients can be registered afterwards
+	if len(publicKeys) > 0 && schedule.Password != "" {
+		log.Warn().
+			Int("schedule_id", int(schedule.ID)).
+			Msg("the backup is encrypted to the registered backup recipients, the password of the schedule is ignored")
+	}
+
+	archivePath, err := CreateBackupArchive(schedule.Password, publicKeys, service.gate, service.dataStore, service.filestorePath)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(filepath.Dir(archivePath))
+
+	// the run identifier keeps the names unique when several backups are made in the same second
+	namedArchivePath := filepath.Join(filepath.Dir(archivePath), fmt.Sprintf("portainer-backup_%d_%s", run.ID, filepath.Base(archivePath)))
+	err = os.Rename(archivePath, namedArchivePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to name the archive")
+	}
+
+	run.Size, run.Checksum, err = checksum(namedArchivePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to compute the archive checksum")
+	}
+
+	run.Location, err = destination.store(namedArchivePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to store the archive")
+	}
+
+	return nil
+}
+
+// applyRetention removes the successful runs, along with their archive, and the failed runs
+// exceeding the schedule retention
+func (service *ScheduleService) applyRetention(schedule *portainer.BackupSchedule, destination archiveDestination) error {
+	if schedule.Retention <= 0 {
+		return nil
+	}
+
+	runs, err := service.dataStore.BackupRun().BackupRunsByScheduleID(schedule.ID)
+	if err != nil {
+		return err
+	}
+
+	succeeded, failed := 0, 0
+	for _, run := range runs {
+		switch run.Status {
+		case portainer.BackupRunSucceeded:
+			succeeded++
+			if succeeded <= schedule.Retention {
+				continue
+			}
+
+			err := destination.remove(run.Location)
+			if err != nil {
+				// the run is kept so that the removal is retried after the next run
+				log.Warn().Err(err).Str("location", run.Location).Msg("failed to remove backup archive")
+				continue
+			}
+		case portainer.BackupRunFailed:
+			failed++
+			if failed <= schedule.Retention {
+				continue
+			}
+		default:
+			continue
+		}
+
+		err := service.dataStore.BackupRun().DeleteBackupRun(run.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checksum returns the size and the hex encoded SHA-256 checksum of a file
+func checksum(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
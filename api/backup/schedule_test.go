@@ -0,0 +1,101 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/offlinegate"
+	"github.com/portainer/portainer/api/scheduler"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ScheduleServiceRun_shouldStoreArchivesAndApplyRetention(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	destinationPath := filepath.Join(t.TempDir(), "backups")
+
+	schedule := &portainer.BackupSchedule{
+		Name:           "nightly",
+		CronExpression: "0 2 * * *",
+		Retention:      2,
+		Destination: portainer.BackupDestination{
+			Type:      portainer.LocalBackupDestination,
+			LocalPath: destinationPath,
+		},
+	}
+	err := store.BackupSchedule().Create(schedule)
+	require.NoError(t, err)
+
+	s := scheduler.NewScheduler(nil)
+	defer s.Shutdown()
+
+	service := NewScheduleService(s, store, offlinegate.NewOfflineGate(), t.TempDir())
+
+	var runs []*portainer.BackupRun
+	for i := 0; i < 3; i++ {
+		run, err := service.Run(schedule.ID)
+		require.NoError(t, err)
+		runs = append(runs, run)
+	}
+
+	for _, run := range runs {
+		assert.Equal(t, portainer.BackupRunSucceeded, run.Status)
+		assert.Equal(t, destinationPath, filepath.Dir(run.Location))
+	}
+
+	// the oldest archive exceeds the retention
+	assert.NoFileExists(t, runs[0].Location)
+	_, err = store.BackupRun().BackupRun(runs[0].ID)
+	assert.True(t, store.IsErrObjectNotFound(err), "the oldest run should be removed")
+
+	for _, run := range runs[1:] {
+		content, err := os.ReadFile(run.Location)
+		require.NoError(t, err)
+
+		checksum := sha256.Sum256(content)
+		assert.Equal(t, hex.EncodeToString(checksum[:]), run.Checksum)
+		assert.Equal(t, int64(len(content)), run.Size)
+	}
+
+	storedRuns, err := store.BackupRun().BackupRunsByScheduleID(schedule.ID)
+	require.NoError(t, err)
+	assert.Len(t, storedRuns, 2)
+}
+
+func Test_ScheduleServiceRun_shouldRecordFailedRuns(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	schedule := &portainer.BackupSchedule{
+		Name:           "s3",
+		CronExpression: "0 2 * * *",
+		Destination: portainer.BackupDestination{
+			Type: portainer.S3BackupDestination,
+		},
+	}
+	err := store.BackupSchedule().Create(schedule)
+	require.NoError(t, err)
+
+	s := scheduler.NewScheduler(nil)
+	defer s.Shutdown()
+
+	service := NewScheduleService(s, store, offlinegate.NewOfflineGate(), t.TempDir())
+
+	run, err := service.Run(schedule.ID)
+	assert.Error(t, err)
+	require.NotNil(t, run)
+	assert.Equal(t, portainer.BackupRunFailed, run.Status)
+	assert.NotEmpty(t, run.Error)
+
+	storedRun, err := store.BackupRun().BackupRun(run.ID)
+	require.NoError(t, err)
+	assert.Equal(t, portainer.BackupRunFailed, storedRun.Status)
+}
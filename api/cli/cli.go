@@ -50,7 +50,14 @@ func (*Service) ParseFlags(version string) (*portainer.CLIFlags, error) {
 		SSLCert:                   kingpin.Flag("sslcert", "Path to the SSL certificate used to secure the Portainer instance").String(),
 		SSLKey:                    kingpin.Flag("sslkey", "Path to the SSL key used to secure the Portainer instance").String(),
 		Rollback:                  kingpin.Flag("rollback", "Rollback the database store to the previous version").Bool(),
+		DatabaseType:              kingpin.Flag("database-type", "Type of the database used to store the data").Default(defaultDatabaseType).Enum("boltdb", "sqlite"),
+		MigrateToSQLite:           kingpin.Flag("migrate-to-sqlite", "Copy the BoltDB database into a new SQLite database and exit").Bool(),
+		CheckStore:                kingpin.Flag("check-store", "Report the broken references between the objects of the database and exit").Bool(),
+		MigrateDryRun:             kingpin.Flag("migrate-dry-run", "Run the pending database migrations against a temporary copy of the database, print the changes and exit").Bool(),
+		RepairStore:               kingpin.Flag("repair-store", "Backup the database, repair the broken references between its objects and exit").Bool(),
 		SnapshotInterval:          kingpin.Flag("snapshot-interval", "Duration between each environment snapshot job").String(),
+		SnapshotConcurrency:       kingpin.Flag("snapshot-concurrency", "Maximum number of environments snapshotted in parallel").Default(defaultSnapshotConcurrency).Int(),
+		SnapshotTimeout:           kingpin.Flag("snapshot-timeout", "Maximum duration of the snapshot of a single environment").Default(defaultSnapshotTimeout).Duration(),
 		AdminPassword:             kingpin.Flag("admin-password", "Set admin password with provided hash").String(),
 		AdminPasswordFile:         kingpin.Flag("admin-password-file", "Path to the file containing the password for the admin user").String(),
 		Labels:                    pairs(kingpin.Flag("hide-label", "Hide containers with a specific label in the UI").Short('l')),
@@ -61,6 +68,7 @@ func (*Service) ParseFlags(version string) (*portainer.CLIFlags, error) {
 		MaxBatchSize:              kingpin.Flag("max-batch-size", "Maximum size of a batch").Int(),
 		MaxBatchDelay:             kingpin.Flag("max-batch-delay", "Maximum delay before a batch starts").Duration(),
 		SecretKeyName:             kingpin.Flag("secret-key-name", "Secret key name for encryption and will be used as /run/secrets/<secret-key-name>.").Default(defaultSecretKeyName).String(),
+		RotateSecretKeyName:       kingpin.Flag("rotate-secret-key-name", "Re-encrypt the database with the secret /run/secrets/<rotate-secret-key-name> and exit").String(),
 		LogLevel:                  kingpin.Flag("log-level", "Set the minimum logging level to show").Default("INFO").Enum("DEBUG", "INFO", "WARN", "ERROR"),
 	}
 
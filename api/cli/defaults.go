@@ -20,4 +20,7 @@ const (
 	defaultSSL                 = "false"
 	defaultBaseURL             = "/"
 	defaultSecretKeyName       = "portainer"
+	defaultSnapshotConcurrency = "10"
+	defaultSnapshotTimeout     = "2m"
+	defaultDatabaseType        = "boltdb"
 )
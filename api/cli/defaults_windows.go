@@ -18,4 +18,7 @@ const (
 	defaultSnapshotInterval    = "5m"
 	defaultBaseURL             = "/"
 	defaultSecretKeyName       = "portainer"
+	defaultSnapshotConcurrency = "10"
+	defaultSnapshotTimeout     = "2m"
+	defaultDatabaseType        = "boltdb"
 )
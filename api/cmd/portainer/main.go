@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
@@ -19,6 +20,7 @@ import (
 	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/database"
 	"github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/database/events"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/datastore"
 	"github.com/portainer/portainer/api/demo"
@@ -40,6 +42,7 @@ import (
 	kubecli "github.com/portainer/portainer/api/kubernetes/cli"
 	"github.com/portainer/portainer/api/ldap"
 	"github.com/portainer/portainer/api/oauth"
+	"github.com/portainer/portainer/api/saml"
 	"github.com/portainer/portainer/api/scheduler"
 	"github.com/portainer/portainer/api/stacks"
 
@@ -70,8 +73,20 @@ func initFileService(dataStorePath string) portainer.FileService {
 	return fileService
 }
 
-func initDataStore(flags *portainer.CLIFlags, secretKey []byte, fileService portainer.FileService, shutdownCtx context.Context) dataservices.DataStore {
-	connection, err := database.NewDatabase("boltdb", *flags.Data, secretKey)
+func initDataStore(flags *portainer.CLIFlags, secretKey []byte, fileService portainer.FileService, eventBus *events.Bus, shutdownCtx context.Context) dataservices.DataStore {
+	if *flags.MigrateToSQLite {
+		err := database.MigrateBoltToSQLite(*flags.Data, secretKey)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed migrating the database to SQLite")
+		}
+
+		log.Info().Msg("exiting database migration, restart Portainer with --database-type=sqlite to use the SQLite database")
+		os.Exit(0)
+
+		return nil
+	}
+
+	connection, err := database.NewDatabase(*flags.DatabaseType, *flags.Data, secretKey)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed creating database connection")
 	}
@@ -80,11 +95,23 @@ func initDataStore(flags *portainer.CLIFlags, secretKey []byte, fileService port
 		bconn.MaxBatchSize = *flags.MaxBatchSize
 		bconn.MaxBatchDelay = *flags.MaxBatchDelay
 		bconn.InitialMmapSize = *flags.InitialMmapSize
-	} else {
-		log.Fatal().Msg("failed creating database connection: expecting a boltdb database type but a different one was received")
 	}
 
+	connection = events.NewConnection(connection, eventBus)
+
 	store := datastore.NewStore(*flags.Data, fileService, connection)
+
+	if *flags.MigrateDryRun {
+		err := migrateDryRun(store, *flags.DatabaseType, secretKey)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed running the migration dry run")
+		}
+
+		log.Info().Msg("exiting migration dry run")
+		os.Exit(0)
+
+		return nil
+	}
 	isNew, err := store.Open()
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed opening store")
@@ -135,6 +162,30 @@ func initDataStore(flags *portainer.CLIFlags, secretKey []byte, fileService port
 		log.Fatal().Err(err).Msg("failed updating settings from flags")
 	}
 
+	if *flags.RotateSecretKeyName != "" {
+		newSecretKey := loadEncryptionSecretKey(*flags.RotateSecretKeyName)
+		if newSecretKey == nil {
+			log.Fatal().Str("filename", *flags.RotateSecretKeyName).Msg("failed loading the new encryption key")
+		}
+
+		err := store.RotateEncryptionKey(secretKey, newSecretKey)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed rotating the database encryption key")
+		}
+
+		log.Info().Msg("exiting encryption key rotation, restart Portainer with --secret-key-name set to the new secret")
+		os.Exit(0)
+
+		return nil
+	}
+
+	if *flags.CheckStore || *flags.RepairStore {
+		checkStoreIntegrity(store, *flags.RepairStore)
+		os.Exit(0)
+
+		return nil
+	}
+
 	// this is for the db restore functionality - needs more tests.
 	go func() {
 		<-shutdownCtx.Done()
@@ -153,6 +204,62 @@ func initDataStore(flags *portainer.CLIFlags, secretKey []byte, fileService port
 	return store
 }
 
+// migrateDryRun runs the pending migrations against a temporary copy of the database
+// and prints the changes they make, the database is not modified
+func migrateDryRun(store *datastore.Store, databaseType string, secretKey []byte) error {
+	dir, err := os.MkdirTemp("", "portainer-migrate-dry-run")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	connection, err := database.NewDatabase(databaseType, dir, secretKey)
+	if err != nil {
+		return err
+	}
+
+	report, err := store.MigrateDryRun(connection)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+	datastore.LogMigrationReport(report)
+
+	return nil
+}
+
+func checkStoreIntegrity(store dataservices.DataStore, repair bool) {
+	check := store.CheckIntegrity
+	if repair {
+		check = store.RepairIntegrity
+	}
+
+	report, err := check()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed checking the integrity of the store")
+	}
+
+	for _, issue := range report.Issues {
+		log.Warn().
+			Str("bucket", issue.Bucket).
+			Str("object", issue.ObjectID).
+			Str("repair", issue.Repair).
+			Msg(issue.Description)
+	}
+
+	log.Info().
+		Int("issues", len(report.Issues)).
+		Bool("repaired", report.Repaired).
+		Str("backup", report.BackupPath).
+		Msg("store integrity checked")
+}
+
 func initComposeStackManager(assetsPath string, configPath string, reverseTunnelService portainer.ReverseTunnelService, proxyManager *proxy.Manager) portainer.ComposeStackManager {
 	composeWrapper, err := exec.NewComposeStackManager(assetsPath, configPath, proxyManager)
 	if err != nil {
@@ -210,6 +317,10 @@ func initOAuthService() portainer.OAuthService {
 	return oauth.NewService()
 }
 
+func initSAMLService() portainer.SAMLService {
+	return saml.NewService()
+}
+
 func initGitService(ctx context.Context) portainer.GitService {
 	return git.NewService(ctx)
 }
@@ -239,11 +350,11 @@ func initKubernetesClientFactory(signatureService portainer.DigitalSignatureServ
 	return kubecli.NewClientFactory(signatureService, reverseTunnelService, instanceID, dataStore)
 }
 
-func initSnapshotService(snapshotIntervalFromFlag string, dataStore dataservices.DataStore, dockerClientFactory *docker.ClientFactory, kubernetesClientFactory *kubecli.ClientFactory, shutdownCtx context.Context) (portainer.SnapshotService, error) {
+func initSnapshotService(flags *portainer.CLIFlags, dataStore dataservices.DataStore, dockerClientFactory *docker.ClientFactory, kubernetesClientFactory *kubecli.ClientFactory, shutdownCtx context.Context) (portainer.SnapshotService, error) {
 	dockerSnapshotter := docker.NewSnapshotter(dockerClientFactory)
 	kubernetesSnapshotter := kubernetes.NewSnapshotter(kubernetesClientFactory)
 
-	snapshotService, err := snapshot.NewService(snapshotIntervalFromFlag, dataStore, dockerSnapshotter, kubernetesSnapshotter, shutdownCtx)
+	snapshotService, err := snapshot.NewService(*flags.SnapshotInterval, *flags.SnapshotConcurrency, *flags.SnapshotTimeout, dataStore, dockerSnapshotter, kubernetesSnapshotter, shutdownCtx)
 	if err != nil {
 		return nil, err
 	}
@@ -549,7 +660,8 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 		log.Info().Msg("proceeding without encryption key")
 	}
 
-	dataStore := initDataStore(flags, encryptionKey, fileService, shutdownCtx)
+	eventBus := events.NewBus()
+	dataStore := initDataStore(flags, encryptionKey, fileService, eventBus, shutdownCtx)
 
 	if err := dataStore.CheckCurrentEdition(); err != nil {
 		log.Fatal().Err(err).Msg("")
@@ -580,6 +692,7 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 	ldapService := initLDAPService()
 
 	oauthService := initOAuthService()
+	samlService := initSAMLService()
 	gitService := initGitService(shutdownCtx)
 
 	openAMTService := openamt.NewService()
@@ -608,7 +721,7 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 	dockerClientFactory := initDockerClientFactory(digitalSignatureService, reverseTunnelService)
 	kubernetesClientFactory := initKubernetesClientFactory(digitalSignatureService, reverseTunnelService, instanceID, dataStore)
 
-	snapshotService, err := initSnapshotService(*flags.SnapshotInterval, dataStore, dockerClientFactory, kubernetesClientFactory, shutdownCtx)
+	snapshotService, err := initSnapshotService(flags, dataStore, dockerClientFactory, kubernetesClientFactory, shutdownCtx)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed initializing snapshot service")
 	}
@@ -713,6 +826,11 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 	stackDeployer := stacks.NewStackDeployer(swarmStackManager, composeStackManager, kubernetesDeployer)
 	stacks.StartStackSchedules(scheduler, stackDeployer, dataStore, gitService)
 
+	ldap.NewGroupSyncService(dataStore, ldapService, apiKeyService).Start(scheduler)
+
+	apikey.StartExpiredAPIKeysPurge(scheduler, apiKeyService)
+	jwt.StartExpiredSessionsPurge(scheduler, jwtService)
+
 	return &http.Server{
 		AuthorizationService:        authorizationService,
 		ReverseTunnelService:        reverseTunnelService,
@@ -722,6 +840,7 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 		HTTPEnabled:                 sslDBSettings.HTTPEnabled,
 		AssetsPath:                  *flags.Assets,
 		DataStore:                   dataStore,
+		EventBus:                    eventBus,
 		SwarmStackManager:           swarmStackManager,
 		ComposeStackManager:         composeStackManager,
 		KubernetesDeployer:          kubernetesDeployer,
@@ -732,6 +851,7 @@ func buildServer(flags *portainer.CLIFlags) portainer.Server {
 		FileService:                 fileService,
 		LDAPService:                 ldapService,
 		OAuthService:                oauthService,
+		SAMLService:                 samlService,
 		GitService:                  gitService,
 		OpenAMTService:              openAMTService,
 		ProxyManager:                proxyManager,
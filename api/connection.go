@@ -20,6 +20,8 @@ type Connection interface {
 	IsEncryptedStore() bool
 	NeedsEncryptionMigration() (bool, error)
 	SetEncrypted(encrypted bool)
+	SetEncryptionKey(key []byte)
+	RotateEncryptionKey(currentKey, newKey []byte) error
 
 	SetServiceName(bucketName string) error
 	GetObject(bucketName string, key []byte, object interface{}) error
@@ -33,8 +35,19 @@ type Connection interface {
 	CreateObjectWithSetSequence(bucketName string, id int, obj interface{}) error
 	GetAll(bucketName string, obj interface{}, append func(o interface{}) (interface{}, error)) error
 	GetAllWithJsoniter(bucketName string, obj interface{}, append func(o interface{}) (interface{}, error)) error
+	GetAllWithKeyPrefix(bucketName string, keyPrefix []byte, obj interface{}, append func(o interface{}) (interface{}, error)) error
 	ConvertToKey(v int) []byte
+	UpdateTx(fn func(tx Transaction) error) error
 
 	BackupMetadata() (map[string]interface{}, error)
 	RestoreMetadata(s map[string]interface{}) error
 }
+
+// Transaction gives access to the objects of the database inside a read-write transaction.
+// The changes are committed together when the function given to UpdateTx returns no error.
+type Transaction interface {
+	GetObject(bucketName string, key []byte, object interface{}) error
+	UpdateObject(bucketName string, key []byte, object interface{}) error
+	DeleteObject(bucketName string, key []byte) error
+	CreateObjectWithId(bucketName string, id int, obj interface{}) error
+}
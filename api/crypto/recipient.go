@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"golang.org/x/crypto/ssh"
+)
+
+// ParseRecipient parses a public key to encrypt to. The key is either an age X25519 recipient (age1...),
+// an SSH public key (ssh-rsa or ssh-ed25519) or a PEM encoded RSA public key.
+func ParseRecipient(publicKey string) (age.Recipient, error) {
+	publicKey = strings.TrimSpace(publicKey)
+
+	switch {
+	case strings.HasPrefix(publicKey, "age1"):
+		return age.ParseX25519Recipient(publicKey)
+	case strings.HasPrefix(publicKey, "ssh-"):
+		return agessh.ParseRecipient(publicKey)
+	case strings.HasPrefix(publicKey, "-----BEGIN"):
+		block, _ := pem.Decode([]byte(publicKey))
+		if block == nil {
+			return nil, errors.New("invalid PEM public key")
+		}
+
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid PEM public key: %w", err)
+			}
+		}
+
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("unsupported PEM public key, only RSA keys are supported")
+		}
+
+		sshKey, err := ssh.NewPublicKey(rsaKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return agessh.NewRSARecipient(sshKey)
+	}
+
+	return nil, errors.New("unsupported public key, expected an age X25519 recipient, an SSH public key or a PEM RSA public key")
+}
+
+// RecipientEncrypt reads from input, encrypts to the public keys in the age format and writes to the output.
+// Any of the matching private keys decrypts the output.
+func RecipientEncrypt(input io.Reader, output io.Writer, publicKeys []string) error {
+	recipients := make([]age.Recipient, 0, len(publicKeys))
+	for _, publicKey := range publicKeys {
+		recipient, err := ParseRecipient(publicKey)
+		if err != nil {
+			return err
+		}
+
+		recipients = append(recipients, recipient)
+	}
+
+	writer, err := age.Encrypt(output, recipients...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(writer, input); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// IdentityDecrypt reads from input, decrypts with the private key and returns the reader to a read decrypted content from.
+// The key is either an age X25519 identity (AGE-SECRET-KEY-1...) or an unencrypted SSH or PEM RSA private key.
+func IdentityDecrypt(input io.Reader, privateKey string) (io.Reader, error) {
+	identity, err := parseIdentity(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return age.Decrypt(input, identity)
+}
+
+func parseIdentity(privateKey string) (age.Identity, error) {
+	privateKey = strings.TrimSpace(privateKey)
+
+	if strings.HasPrefix(privateKey, "AGE-SECRET-KEY-1") {
+		return age.ParseX25519Identity(privateKey)
+	}
+
+	identity, err := agessh.ParseIdentity([]byte(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported private key, expected an age X25519 identity or an SSH private key: %w", err)
+	}
+
+	return identity, nil
+}
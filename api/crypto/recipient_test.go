@@ -0,0 +1,60 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_recipientEncryptAndIdentityDecrypt(t *testing.T) {
+	x25519Identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	rsaPublicKey, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+
+	rsaPublicKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPublicKey}))
+	rsaPrivateKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+
+	otherIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	content := []byte("content")
+
+	var encrypted bytes.Buffer
+	err = RecipientEncrypt(bytes.NewReader(content), &encrypted, []string{x25519Identity.Recipient().String(), rsaPublicKeyPEM})
+	require.NoError(t, err)
+	assert.NotContains(t, encrypted.String(), string(content), "Content wasn't encrypted")
+
+	for name, privateKey := range map[string]string{"x25519": x25519Identity.String(), "rsa": rsaPrivateKeyPEM} {
+		t.Run(name, func(t *testing.T) {
+			reader, err := IdentityDecrypt(bytes.NewReader(encrypted.Bytes()), privateKey)
+			require.NoError(t, err)
+
+			decrypted, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, content, decrypted)
+		})
+	}
+
+	_, err = IdentityDecrypt(bytes.NewReader(encrypted.Bytes()), otherIdentity.String())
+	assert.Error(t, err, "Content shouldn't be decrypted by another private key")
+}
+
+func Test_ParseRecipient_shouldRejectInvalidKeys(t *testing.T) {
+	for _, publicKey := range []string{"", "age1invalid", "ssh-rsa invalid", "-----BEGIN PUBLIC KEY-----\ninvalid\n-----END PUBLIC KEY-----"} {
+		_, err := ParseRecipient(publicKey)
+		assert.Error(t, err, "%q should be rejected", publicKey)
+	}
+}
@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is the number of periods accepted before and after the current one, to allow for clock drift
+	totpSkew = 1
+	// recoveryCodeLength is the number of characters of a recovery code, without the separator
+	recoveryCodeLength = 10
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret generates a random base32 encoded TOTP secret (RFC 6238)
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return base32NoPadding.EncodeToString(secret), nil
+}
+
+// TOTPKeyURI returns the otpauth URI of a TOTP secret, to be displayed as a QR code by the authenticator applications
+func TOTPKeyURI(issuer, accountName, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer + ":" + accountName)
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TOTPCode returns the TOTP code of a secret at the given time
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return totpCode(key, t.Unix()/totpPeriod), nil
+}
+
+// ValidateTOTPCode checks a TOTP code against a secret at the given time, allowing for a small clock drift.
+// The codes of the time steps up to lastTimeStep are rejected so that a code cannot be used twice.
+// It returns the time step of the code when the code is valid.
+func ValidateTOTPCode(secret, code string, t time.Time, lastTimeStep int64) (int64, bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.TrimSpace(code)
+	current := t.Unix() / totpPeriod
+
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastTimeStep {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	return base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+// totpCode computes the HOTP value (RFC 4226) of a time step
+func totpCode(key []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// GenerateRecoveryCodes generates count random one-time recovery codes, formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes(count int) ([]string, error) {
+	codes := make([]string, count)
+
+	for i := range codes {
+		raw := make([]byte, recoveryCodeLength)
+		_, err := rand.Read(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(base32NoPadding.EncodeToString(raw))[:recoveryCodeLength]
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+	}
+
+	return codes, nil
+}
+
+// HashRecoveryCode returns the hash under which a recovery code is stored. The recovery codes are random,
+// a fast hash is enough. The separator and the case of the code are ignored.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,60 @@
+package crypto
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA1 secret of the RFC 6238 test vectors
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func Test_TOTPCode_shouldMatchRFC6238TestVectors(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for timestamp, expected := range vectors {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(timestamp, 0))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", timestamp)
+	}
+}
+
+func Test_ValidateTOTPCode(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	now := time.Unix(1667300000, 0)
+
+	code, err := TOTPCode(secret, now.Add(-30*time.Second))
+	require.NoError(t, err)
+
+	step, ok := ValidateTOTPCode(secret, code, now, 0)
+	assert.True(t, ok, "a code of the previous period is accepted")
+
+	_, ok = ValidateTOTPCode(secret, code, now, step)
+	assert.False(t, ok, "a code cannot be used twice")
+
+	_, ok = ValidateTOTPCode(secret, code, now.Add(2*time.Minute), 0)
+	assert.False(t, ok, "an expired code is rejected")
+}
+
+func Test_GenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	assert.Len(t, codes, 10)
+
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.Equal(t, HashRecoveryCode(code), HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", ""))))
+	}
+}
@@ -1,15 +1,17 @@
 package boltdb
 
 import (
+	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	dserrors "github.com/portainer/portainer/api/dataservices/errors"
 
 	"github.com/rs/zerolog/log"
@@ -34,6 +36,11 @@ type DbConnection struct {
 	EncryptionKey   []byte
 	isEncrypted     bool
 
+	// keyMu guards EncryptionKey, rotationMu is held by the operations encrypting or decrypting
+	// objects so that the key cannot be rotated while they run
+	keyMu      sync.RWMutex
+	rotationMu sync.RWMutex
+
 	*bolt.DB
 }
 
@@ -188,6 +195,8 @@ func (connection *DbConnection) SetServiceName(bucketName string) error {
 
 // GetObject is a generic function used to retrieve an unmarshalled object from a database database.
 func (connection *DbConnection) GetObject(bucketName string, key []byte, object interface{}) error {
+	defer connection.holdEncryptionKey()()
+
 	var data []byte
 
 	err := connection.View(func(tx *bolt.Tx) error {
@@ -215,11 +224,22 @@ func (connection *DbConnection) getEncryptionKey() []byte {
 		return nil
 	}
 
+	connection.keyMu.RLock()
+	defer connection.keyMu.RUnlock()
+
 	return connection.EncryptionKey
 }
 
+// holdEncryptionKey prevents the rotation of the encryption key until the returned function is called
+func (connection *DbConnection) holdEncryptionKey() func() {
+	connection.rotationMu.RLock()
+	return connection.rotationMu.RUnlock
+}
+
 // UpdateObject is a generic function used to update an object inside a database database.
 func (connection *DbConnection) UpdateObject(bucketName string, key []byte, object interface{}) error {
+	defer connection.holdEncryptionKey()()
+
 	data, err := connection.MarshalObject(object)
 	if err != nil {
 		return err
@@ -242,6 +262,8 @@ func (connection *DbConnection) DeleteObject(bucketName string, key []byte) erro
 // DeleteAllObjects delete all objects where matching() returns (id, ok).
 // TODO: think about how to return the error inside (maybe change ok to type err, and use "notfound"?
 func (connection *DbConnection) DeleteAllObjects(bucketName string, matching func(o interface{}) (id int, ok bool)) error {
+	defer connection.holdEncryptionKey()()
+
 	return connection.Batch(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 
@@ -284,6 +306,8 @@ func (connection *DbConnection) GetNextIdentifier(bucketName string) int {
 
 // CreateObject creates a new object in the bucket, using the next bucket sequence id
 func (connection *DbConnection) CreateObject(bucketName string, fn func(uint64) (int, interface{})) error {
+	defer connection.holdEncryptionKey()()
+
 	return connection.Batch(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 
@@ -301,6 +325,8 @@ func (connection *DbConnection) CreateObject(bucketName string, fn func(uint64)
 
 // CreateObjectWithId creates a new object in the bucket, using the specified id
 func (connection *DbConnection) CreateObjectWithId(bucketName string, id int, obj interface{}) error {
+	defer connection.holdEncryptionKey()()
+
 	return connection.Batch(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 		data, err := connection.MarshalObject(obj)
@@ -314,6 +340,8 @@ func (connection *DbConnection) CreateObjectWithId(bucketName string, id int, ob
 
 // CreateObjectWithStringId creates a new object in the bucket, using the specified id
 func (connection *DbConnection) CreateObjectWithStringId(bucketName string, id []byte, obj interface{}) error {
+	defer connection.holdEncryptionKey()()
+
 	return connection.Batch(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 		data, err := connection.MarshalObject(obj)
@@ -328,6 +356,8 @@ func (connection *DbConnection) CreateObjectWithStringId(bucketName string, id [
 // CreateObjectWithSetSequence creates a new object in the bucket, using the specified id, and sets the bucket sequence
 // avoid this :)
 func (connection *DbConnection) CreateObjectWithSetSequence(bucketName string, id int, obj interface{}) error {
+	defer connection.holdEncryptionKey()()
+
 	return connection.Batch(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 
@@ -347,6 +377,8 @@ func (connection *DbConnection) CreateObjectWithSetSequence(bucketName string, i
 }
 
 func (connection *DbConnection) GetAll(bucketName string, obj interface{}, append func(o interface{}) (interface{}, error)) error {
+	defer connection.holdEncryptionKey()()
+
 	err := connection.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 
@@ -370,6 +402,8 @@ func (connection *DbConnection) GetAll(bucketName string, obj interface{}, appen
 
 // TODO: decide which Unmarshal to use, and use one...
 func (connection *DbConnection) GetAllWithJsoniter(bucketName string, obj interface{}, append func(o interface{}) (interface{}, error)) error {
+	defer connection.holdEncryptionKey()()
+
 	err := connection.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketName))
 
@@ -390,6 +424,30 @@ func (connection *DbConnection) GetAllWithJsoniter(bucketName string, obj interf
 	return err
 }
 
+// GetAllWithKeyPrefix iterates, in key order, over the objects of a bucket whose key starts with keyPrefix.
+func (connection *DbConnection) GetAllWithKeyPrefix(bucketName string, keyPrefix []byte, obj interface{}, append func(o interface{}) (interface{}, error)) error {
+	defer connection.holdEncryptionKey()()
+
+	err := connection.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.Seek(keyPrefix); k != nil && bytes.HasPrefix(k, keyPrefix); k, v = cursor.Next() {
+			err := connection.UnmarshalObjectWithJsoniter(v, obj)
+			if err != nil {
+				return err
+			}
+			obj, err = append(obj)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	return err
+}
+
 func (connection *DbConnection) BackupMetadata() (map[string]interface{}, error) {
 	buckets := map[string]interface{}{}
 
@@ -430,3 +488,87 @@ func (connection *DbConnection) RestoreMetadata(s map[string]interface{}) error
 
 	return err
 }
+
+// SetEncryptionKey replaces the key used to encrypt and decrypt the objects
+func (connection *DbConnection) SetEncryptionKey(key []byte) {
+	connection.rotationMu.Lock()
+	defer connection.rotationMu.Unlock()
+
+	connection.setEncryptionKey(key)
+}
+
+func (connection *DbConnection) setEncryptionKey(key []byte) {
+	connection.keyMu.Lock()
+	defer connection.keyMu.Unlock()
+
+	connection.EncryptionKey = key
+}
+
+// RotateEncryptionKey re-encrypts every object of the database with newKey in a single transaction.
+// Every object is decrypted with newKey before the transaction is committed, the database is left
+// untouched if any of them fails. currentKey must match the key the database is encrypted with.
+func (connection *DbConnection) RotateEncryptionKey(currentKey, newKey []byte) error {
+	// the reads and writes wait for the rotation, they would otherwise use the key not matching the stored objects
+	connection.rotationMu.Lock()
+	defer connection.rotationMu.Unlock()
+
+	if !connection.IsEncryptedStore() {
+		return dserrors.ErrDatabaseNotEncrypted
+	}
+
+	if !bytes.Equal(currentKey, connection.getEncryptionKey()) {
+		return dserrors.ErrWrongEncryptionKey
+	}
+
+	err := connection.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			type object struct{ key, value []byte }
+
+			objects := []object{}
+			err := bucket.ForEach(func(k, v []byte) error {
+				if v == nil {
+					return nil
+				}
+
+				data, err := decrypt(v, currentKey)
+				if err != nil {
+					return errors.Wrapf(err, "failed decrypting an object of the %s bucket", name)
+				}
+
+				data, err = encrypt(data, newKey)
+				if err != nil {
+					return err
+				}
+
+				objects = append(objects, object{key: append([]byte{}, k...), value: data})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			// the bucket is updated once iterated, as it must not be modified during the iteration
+			for _, object := range objects {
+				err := bucket.Put(object.key, object.value)
+				if err != nil {
+					return err
+				}
+			}
+
+			return bucket.ForEach(func(k, v []byte) error {
+				if v == nil {
+					return nil
+				}
+
+				_, err := decrypt(v, newKey)
+				return errors.Wrapf(err, "failed decrypting a re-encrypted object of the %s bucket", name)
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	connection.setEncryptionKey(newKey)
+	return nil
+}
@@ -0,0 +1,59 @@
+package boltdb
+
+import (
+	portainer "github.com/portainer/portainer/api"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DbTransaction is a read-write BoltDB transaction
+type DbTransaction struct {
+	connection *DbConnection
+	tx         *bolt.Tx
+}
+
+// UpdateTx runs fn inside a single read-write transaction, the changes are rolled back if fn returns an error
+func (connection *DbConnection) UpdateTx(fn func(tx portainer.Transaction) error) error {
+	defer connection.holdEncryptionKey()()
+
+	return connection.Update(func(tx *bolt.Tx) error {
+		return fn(&DbTransaction{connection: connection, tx: tx})
+	})
+}
+
+// GetObject retrieves an unmarshalled object, including the changes made by the transaction
+func (tx *DbTransaction) GetObject(bucketName string, key []byte, object interface{}) error {
+	bucket := tx.tx.Bucket([]byte(bucketName))
+
+	value := bucket.Get(key)
+	if value == nil {
+		return dserrors.ErrObjectNotFound
+	}
+
+	// the value is only valid during the transaction
+	data := make([]byte, len(value))
+	copy(data, value)
+
+	return tx.connection.UnmarshalObjectWithJsoniter(data, object)
+}
+
+// UpdateObject updates an object inside the transaction
+func (tx *DbTransaction) UpdateObject(bucketName string, key []byte, object interface{}) error {
+	data, err := tx.connection.MarshalObject(object)
+	if err != nil {
+		return err
+	}
+
+	return tx.tx.Bucket([]byte(bucketName)).Put(key, data)
+}
+
+// CreateObjectWithId creates a new object inside the transaction, using the specified id
+func (tx *DbTransaction) CreateObjectWithId(bucketName string, id int, obj interface{}) error {
+	return tx.UpdateObject(bucketName, tx.connection.ConvertToKey(id), obj)
+}
+
+// DeleteObject deletes an object inside the transaction
+func (tx *DbTransaction) DeleteObject(bucketName string, key []byte) error {
+	return tx.tx.Bucket([]byte(bucketName)).Delete(key)
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Operation is the kind of change made to an object of the database
+type Operation string
+
+const (
+	// OperationCreate is emitted when an object is created
+	OperationCreate Operation = "create"
+	// OperationUpdate is emitted when an object is updated
+	OperationUpdate Operation = "update"
+	// OperationDelete is emitted when an object is deleted
+	OperationDelete Operation = "delete"
+)
+
+// Event is a change made to an object of the database.
+// Before is not set for a created object and After is not set for a deleted object.
+type Event struct {
+	Bucket    string          `json:"Bucket" example:"endpoints"`
+	ID        string          `json:"Id" example:"1"`
+	Operation Operation       `json:"Operation" example:"update"`
+	Time      int64           `json:"Time" example:"1665400000"`
+	Before    json.RawMessage `json:"Before,omitempty" swaggertype:"object"`
+	After     json.RawMessage `json:"After,omitempty" swaggertype:"object"`
+}
+
+// Bus dispatches the events published to all of its subscribers.
+// The events are dropped for the subscribers that do not keep up.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[int]chan Event
+	nextID      int
+}
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[int]chan Event)}
+}
+
+// Subscribe returns a channel receiving the events published from now on, buffering up to size events.
+// The returned function must be called to unsubscribe, it closes the channel.
+func (bus *Bus) Subscribe(size int) (<-chan Event, func()) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	id := bus.nextID
+	bus.nextID++
+
+	ch := make(chan Event, size)
+	bus.subscribers[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			bus.mu.Lock()
+			defer bus.mu.Unlock()
+
+			delete(bus.subscribers, id)
+			close(ch)
+		})
+	}
+}
+
+// HasSubscribers returns true if at least one subscriber is listening,
+// it allows the publishers to skip building the events
+func (bus *Bus) HasSubscribers() bool {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+
+	return len(bus.subscribers) > 0
+}
+
+// Publish sends the event to every subscriber without blocking
+func (bus *Bus) Publish(event Event) {
+	if event.Time == 0 {
+		event.Time = time.Now().Unix()
+	}
+
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+
+	for _, ch := range bus.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Debug().Str("bucket", event.Bucket).Str("id", event.ID).Msg("event subscriber is full, dropping event")
+		}
+	}
+}
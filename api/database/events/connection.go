@@ -0,0 +1,273 @@
+package events
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	portainer "github.com/portainer/portainer/api"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+)
+
+// Connection wraps a database connection and publishes an event for every object created, updated or deleted
+// through it. The events are only built when the bus has subscribers.
+type Connection struct {
+	portainer.Connection
+	bus *Bus
+}
+
+// NewConnection returns a connection publishing the changes made through connection to bus
+func NewConnection(connection portainer.Connection, bus *Bus) *Connection {
+	return &Connection{Connection: connection, bus: bus}
+}
+
+// UpdateObject updates the object and publishes an update event
+func (connection *Connection) UpdateObject(bucketName string, key []byte, object interface{}) error {
+	if !connection.bus.HasSubscribers() {
+		return connection.Connection.UpdateObject(bucketName, key, object)
+	}
+
+	before, exists := connection.rawObject(bucketName, key)
+
+	err := connection.Connection.UpdateObject(bucketName, key, object)
+	if err != nil {
+		return err
+	}
+
+	operation := OperationUpdate
+	if !exists {
+		operation = OperationCreate
+	}
+
+	connection.publish(bucketName, key, operation, before, object)
+	return nil
+}
+
+// DeleteObject deletes the object and publishes a delete event
+func (connection *Connection) DeleteObject(bucketName string, key []byte) error {
+	if !connection.bus.HasSubscribers() {
+		return connection.Connection.DeleteObject(bucketName, key)
+	}
+
+	before, exists := connection.rawObject(bucketName, key)
+
+	err := connection.Connection.DeleteObject(bucketName, key)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		connection.publish(bucketName, key, OperationDelete, before, nil)
+	}
+
+	return nil
+}
+
+// DeleteAllObjects deletes the matching objects and publishes a delete event for each of them
+func (connection *Connection) DeleteAllObjects(bucketName string, matching func(o interface{}) (id int, ok bool)) error {
+	if !connection.bus.HasSubscribers() {
+		return connection.Connection.DeleteAllObjects(bucketName, matching)
+	}
+
+	deleted := map[int]interface{}{}
+	err := connection.Connection.DeleteAllObjects(bucketName, func(o interface{}) (int, bool) {
+		id, ok := matching(o)
+		if ok {
+			deleted[id] = o
+		}
+
+		return id, ok
+	})
+	if err != nil {
+		return err
+	}
+
+	for id, object := range deleted {
+		before, err := json.Marshal(object)
+		if err != nil {
+			continue
+		}
+
+		connection.publish(bucketName, connection.ConvertToKey(id), OperationDelete, before, nil)
+	}
+
+	return nil
+}
+
+// CreateObject creates the object and publishes a create event
+func (connection *Connection) CreateObject(bucketName string, fn func(uint64) (int, interface{})) error {
+	if !connection.bus.HasSubscribers() {
+		return connection.Connection.CreateObject(bucketName, fn)
+	}
+
+	var id int
+	var object interface{}
+	err := connection.Connection.CreateObject(bucketName, func(sequence uint64) (int, interface{}) {
+		id, object = fn(sequence)
+		return id, object
+	})
+	if err != nil {
+		return err
+	}
+
+	connection.publish(bucketName, connection.ConvertToKey(id), OperationCreate, nil, object)
+	return nil
+}
+
+// CreateObjectWithId creates the object and publishes a create event
+func (connection *Connection) CreateObjectWithId(bucketName string, id int, obj interface{}) error {
+	err := connection.Connection.CreateObjectWithId(bucketName, id, obj)
+	if err == nil && connection.bus.HasSubscribers() {
+		connection.publish(bucketName, connection.ConvertToKey(id), OperationCreate, nil, obj)
+	}
+
+	return err
+}
+
+// CreateObjectWithStringId creates the object and publishes a create event
+func (connection *Connection) CreateObjectWithStringId(bucketName string, id []byte, obj interface{}) error {
+	err := connection.Connection.CreateObjectWithStringId(bucketName, id, obj)
+	if err == nil && connection.bus.HasSubscribers() {
+		connection.publish(bucketName, id, OperationCreate, nil, obj)
+	}
+
+	return err
+}
+
+// CreateObjectWithSetSequence creates the object and publishes a create event
+func (connection *Connection) CreateObjectWithSetSequence(bucketName string, id int, obj interface{}) error {
+	err := connection.Connection.CreateObjectWithSetSequence(bucketName, id, obj)
+	if err == nil && connection.bus.HasSubscribers() {
+		connection.publish(bucketName, connection.ConvertToKey(id), OperationCreate, nil, obj)
+	}
+
+	return err
+}
+
+// UpdateTx runs fn inside a transaction and publishes the events of its changes once they are committed
+func (connection *Connection) UpdateTx(fn func(tx portainer.Transaction) error) error {
+	if !connection.bus.HasSubscribers() {
+		return connection.Connection.UpdateTx(fn)
+	}
+
+	var pending []Event
+	err := connection.Connection.UpdateTx(func(tx portainer.Transaction) error {
+		pending = nil
+		return fn(&transaction{Transaction: tx, connection: connection.Connection, events: &pending})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, event := range pending {
+		connection.bus.Publish(event)
+	}
+
+	return nil
+}
+
+// transaction records the events of the changes made through it, they are published after the commit
+type transaction struct {
+	portainer.Transaction
+	connection portainer.Connection
+	events     *[]Event
+}
+
+// UpdateObject updates the object and records an update event
+func (tx *transaction) UpdateObject(bucketName string, key []byte, object interface{}) error {
+	before, exists := rawObject(tx.Transaction, bucketName, key)
+
+	err := tx.Transaction.UpdateObject(bucketName, key, object)
+	if err != nil {
+		return err
+	}
+
+	operation := OperationUpdate
+	if !exists {
+		operation = OperationCreate
+	}
+
+	*tx.events = append(*tx.events, newEvent(bucketName, key, operation, before, object))
+	return nil
+}
+
+// CreateObjectWithId creates the object and records a create event
+func (tx *transaction) CreateObjectWithId(bucketName string, id int, obj interface{}) error {
+	err := tx.Transaction.CreateObjectWithId(bucketName, id, obj)
+	if err != nil {
+		return err
+	}
+
+	*tx.events = append(*tx.events, newEvent(bucketName, tx.connection.ConvertToKey(id), OperationCreate, nil, obj))
+	return nil
+}
+
+// DeleteObject deletes the object and records a delete event
+func (tx *transaction) DeleteObject(bucketName string, key []byte) error {
+	before, exists := rawObject(tx.Transaction, bucketName, key)
+
+	err := tx.Transaction.DeleteObject(bucketName, key)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		*tx.events = append(*tx.events, newEvent(bucketName, key, OperationDelete, before, nil))
+	}
+
+	return nil
+}
+
+// rawObject returns the JSON representation of the stored object and whether it exists.
+// The representation is nil when the stored value is not a JSON document.
+func (connection *Connection) rawObject(bucketName string, key []byte) (json.RawMessage, bool) {
+	return rawObject(connection.Connection, bucketName, key)
+}
+
+func rawObject(reader portainer.Transaction, bucketName string, key []byte) (json.RawMessage, bool) {
+	var object interface{}
+	err := reader.GetObject(bucketName, key, &object)
+	if err != nil {
+		return nil, !errors.Is(err, dserrors.ErrObjectNotFound)
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, true
+	}
+
+	return data, true
+}
+
+func (connection *Connection) publish(bucketName string, key []byte, operation Operation, before json.RawMessage, object interface{}) {
+	connection.bus.Publish(newEvent(bucketName, key, operation, before, object))
+}
+
+func newEvent(bucketName string, key []byte, operation Operation, before json.RawMessage, object interface{}) Event {
+	event := Event{
+		Bucket:    bucketName,
+		ID:        keyToID(key),
+		Operation: operation,
+		Before:    before,
+	}
+
+	if object != nil {
+		after, err := json.Marshal(object)
+		if err == nil {
+			event.After = after
+		}
+	}
+
+	return event
+}
+
+// keyToID converts a key back to the identifier it was built from,
+// integer identifiers are stored as 8 bytes big endian keys by ConvertToKey
+func keyToID(key []byte) string {
+	if len(key) == 8 && key[0] == 0 {
+		return strconv.FormatUint(binary.BigEndian.Uint64(key), 10)
+	}
+
+	return string(key)
+}
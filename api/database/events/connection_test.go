@@ -0,0 +1,214 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/boltdb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testObject struct {
+	ID   int
+	Name string
+}
+
+func newTestConnection(t *testing.T) (*Connection, *Bus) {
+	db := &boltdb.DbConnection{Path: t.TempDir()}
+
+	_, err := db.NeedsEncryptionMigration()
+	assert.NoError(t, err)
+
+	err = db.Open()
+	assert.NoError(t, err)
+
+	t.Cleanup(func() { db.Close() })
+
+	err = db.SetServiceName("objects")
+	assert.NoError(t, err)
+
+	bus := NewBus()
+	return NewConnection(db, bus), bus
+}
+
+func receive(t *testing.T, events <-chan Event) Event {
+	select {
+	case event := <-events:
+		return event
+	default:
+		t.Fatal("expected an event")
+		return Event{}
+	}
+}
+
+func Test_Connection_PublishesChanges(t *testing.T) {
+	is := assert.New(t)
+
+	connection, bus := newTestConnection(t)
+
+	events, unsubscribe := bus.Subscribe(10)
+	defer unsubscribe()
+
+	err := connection.CreateObject("objects", func(id uint64) (int, interface{}) {
+		return int(id), &testObject{ID: int(id), Name: "first"}
+	})
+	is.NoError(err)
+
+	event := receive(t, events)
+	is.Equal("objects", event.Bucket)
+	is.Equal("1", event.ID)
+	is.Equal(OperationCreate, event.Operation)
+	is.Nil(event.Before)
+	is.JSONEq(`{"ID":1,"Name":"first"}`, string(event.After))
+	is.NotZero(event.Time)
+
+	err = connection.UpdateObject("objects", connection.ConvertToKey(1), &testObject{ID: 1, Name: "renamed"})
+	is.NoError(err)
+
+	event = receive(t, events)
+	is.Equal(OperationUpdate, event.Operation)
+	is.JSONEq(`{"ID":1,"Name":"first"}`, string(event.Before))
+	is.JSONEq(`{"ID":1,"Name":"renamed"}`, string(event.After))
+
+	err = connection.UpdateObject("objects", []byte("named"), &testObject{Name: "named"})
+	is.NoError(err)
+
+	event = receive(t, events)
+	is.Equal("named", event.ID)
+	is.Equal(OperationCreate, event.Operation)
+
+	err = connection.DeleteObject("objects", connection.ConvertToKey(1))
+	is.NoError(err)
+
+	event = receive(t, events)
+	is.Equal("1", event.ID)
+	is.Equal(OperationDelete, event.Operation)
+	is.JSONEq(`{"ID":1,"Name":"renamed"}`, string(event.Before))
+	is.Nil(event.After)
+}
+
+func Test_Connection_DeleteAllObjects(t *testing.T) {
+	is := assert.New(t)
+
+	connection, bus := newTestConnection(t)
+
+	for i := 1; i <= 3; i++ {
+		err := connection.CreateObjectWithId("objects", i, &testObject{ID: i})
+		is.NoError(err)
+	}
+
+	events, unsubscribe := bus.Subscribe(10)
+	defer unsubscribe()
+
+	err := connection.DeleteAllObjects("objects", func(o interface{}) (int, bool) {
+		var object testObject
+		data, _ := json.Marshal(o)
+		json.Unmarshal(data, &object)
+
+		return object.ID, object.ID != 2
+	})
+	is.NoError(err)
+
+	ids := []string{receive(t, events).ID, receive(t, events).ID}
+	is.ElementsMatch([]string{"1", "3"}, ids)
+	is.Empty(events)
+}
+
+func Test_Connection_UpdateTx(t *testing.T) {
+	is := assert.New(t)
+
+	connection, bus := newTestConnection(t)
+
+	err := connection.CreateObjectWithId("objects", 1, &testObject{ID: 1, Name: "first"})
+	is.NoError(err)
+
+	events, unsubscribe := bus.Subscribe(10)
+	defer unsubscribe()
+
+	err = connection.UpdateTx(func(tx portainer.Transaction) error {
+		err := tx.UpdateObject("objects", connection.ConvertToKey(1), &testObject{ID: 1, Name: "renamed"})
+		if err != nil {
+			return err
+		}
+
+		return errors.New("rollback")
+	})
+	is.Error(err)
+	is.Empty(events, "a rolled back transaction must not publish events")
+
+	var object testObject
+	err = connection.GetObject("objects", connection.ConvertToKey(1), &object)
+	is.NoError(err)
+	is.Equal("first", object.Name)
+
+	err = connection.UpdateTx(func(tx portainer.Transaction) error {
+		err := tx.UpdateObject("objects", connection.ConvertToKey(1), &testObject{ID: 1, Name: "renamed"})
+		if err != nil {
+			return err
+		}
+
+		return tx.DeleteObject("objects", connection.ConvertToKey(1))
+	})
+	is.NoError(err)
+
+	event := receive(t, events)
+	is.Equal(OperationUpdate, event.Operation)
+	is.JSONEq(`{"ID":1,"Name":"first"}`, string(event.Before))
+
+	event = receive(t, events)
+	is.Equal(OperationDelete, event.Operation)
+	is.JSONEq(`{"ID":1,"Name":"renamed"}`, string(event.Before))
+}
+
+func Test_Connection_UpdateTx_PublishesCreates(t *testing.T) {
+	is := assert.New(t)
+
+	connection, bus := newTestConnection(t)
+
+	events, unsubscribe := bus.Subscribe(10)
+	defer unsubscribe()
+
+	err := connection.UpdateTx(func(tx portainer.Transaction) error {
+		err := tx.CreateObjectWithId("objects", 1, &testObject{ID: 1, Name: "first"})
+		if err != nil {
+			return err
+		}
+
+		is.Empty(events, "the events must only be published once the transaction is committed")
+
+		return nil
+	})
+	is.NoError(err)
+
+	event := receive(t, events)
+	is.Equal(OperationCreate, event.Operation)
+	is.Equal("1", event.ID)
+	is.Nil(event.Before)
+	is.JSONEq(`{"ID":1,"Name":"first"}`, string(event.After))
+}
+
+func Test_Bus_DropsEventsOfFullSubscribers(t *testing.T) {
+	is := assert.New(t)
+
+	bus := NewBus()
+	is.False(bus.HasSubscribers())
+
+	events, unsubscribe := bus.Subscribe(1)
+	is.True(bus.HasSubscribers())
+
+	bus.Publish(Event{ID: "1"})
+	bus.Publish(Event{ID: "2"})
+
+	is.Equal("1", receive(t, events).ID)
+	is.Empty(events)
+
+	unsubscribe()
+	unsubscribe()
+	is.False(bus.HasSubscribers())
+
+	_, open := <-events
+	is.False(open)
+}
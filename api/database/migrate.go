@@ -0,0 +1,110 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/database/sqlite"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// MigrateBoltToSQLite copies every bucket of the BoltDB database found in storePath into a new SQLite
+// database, then checks that each bucket holds the same number of objects in both databases.
+// The objects are copied as is, so the SQLite database is encrypted only if the BoltDB one is.
+// The BoltDB database is left untouched, the SQLite database is removed if the migration fails.
+func MigrateBoltToSQLite(storePath string, encryptionKey []byte) error {
+	source := &boltdb.DbConnection{Path: storePath, EncryptionKey: encryptionKey}
+
+	needsEncryption, err := source.NeedsEncryptionMigration()
+	if err != nil {
+		return err
+	}
+
+	if needsEncryption {
+		// the BoltDB database is not encrypted yet, it will be encrypted once opened as a SQLite store
+		source.SetEncrypted(false)
+	}
+
+	if _, err := os.Stat(source.GetDatabaseFilePath()); err != nil {
+		return errors.Wrap(err, "unable to find the BoltDB database")
+	}
+
+	for _, filename := range []string{sqlite.DatabaseFileName, sqlite.EncryptedDatabaseFileName} {
+		if _, err := os.Stat(path.Join(storePath, filename)); err == nil {
+			return fmt.Errorf("a SQLite database already exists: %s", filename)
+		}
+	}
+
+	target := &sqlite.DbConnection{Path: storePath, EncryptionKey: encryptionKey}
+	target.SetEncrypted(source.IsEncryptedStore())
+
+	err = source.Open()
+	if err != nil {
+		return errors.Wrap(err, "unable to open the BoltDB database")
+	}
+	defer source.Close()
+
+	err = target.Open()
+	if err != nil {
+		return errors.Wrap(err, "unable to create the SQLite database")
+	}
+
+	err = copyBoltBuckets(source, target)
+	target.Close()
+	if err != nil {
+		os.Remove(target.GetDatabaseFilePath())
+		return err
+	}
+
+	log.Info().Str("filename", target.GetDatabaseFileName()).Msg("BoltDB database migrated to SQLite")
+
+	return nil
+}
+
+func copyBoltBuckets(source *boltdb.DbConnection, target *sqlite.DbConnection) error {
+	return source.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			bucketName := string(name)
+
+			objects := []sqlite.RawObject{}
+			err := bucket.ForEach(func(k, v []byte) error {
+				if v == nil {
+					// nested buckets are not used by Portainer
+					return nil
+				}
+
+				objects = append(objects, sqlite.RawObject{
+					Key:   append([]byte{}, k...),
+					Value: append([]byte{}, v...),
+				})
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			err = target.ImportBucket(bucketName, int(bucket.Sequence()), objects)
+			if err != nil {
+				return errors.Wrapf(err, "unable to copy the %s bucket", bucketName)
+			}
+
+			count, err := target.CountObjects(bucketName)
+			if err != nil {
+				return errors.Wrapf(err, "unable to count the objects of the %s bucket", bucketName)
+			}
+
+			if count != len(objects) {
+				return fmt.Errorf("object count mismatch in the %s bucket: %d in BoltDB, %d in SQLite", bucketName, len(objects), count)
+			}
+
+			log.Debug().Str("bucket", bucketName).Int("objects", count).Msg("bucket migrated")
+
+			return nil
+		})
+	})
+}
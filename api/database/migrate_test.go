@@ -0,0 +1,80 @@
+package database
+
+import (
+	"crypto/sha256"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/portainer/portainer/api/database/boltdb"
+	"github.com/portainer/portainer/api/database/sqlite"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MigrateBoltToSQLite(t *testing.T) {
+	hash := sha256.Sum256([]byte("secret"))
+
+	for name, key := range map[string][]byte{"unencrypted": nil, "encrypted": hash[:]} {
+		t.Run(name, func(t *testing.T) {
+			is := assert.New(t)
+			dir := t.TempDir()
+
+			source := &boltdb.DbConnection{Path: dir, EncryptionKey: key}
+			_, err := source.NeedsEncryptionMigration()
+			is.NoError(err)
+			is.NoError(source.Open())
+
+			is.NoError(source.SetServiceName("objects"))
+			is.NoError(source.SetServiceName("empty"))
+			for _, name := range []string{"first", "second"} {
+				err := source.CreateObject("objects", func(id uint64) (int, interface{}) {
+					return int(id), map[string]interface{}{"Id": id, "Name": name}
+				})
+				is.NoError(err)
+			}
+			is.NoError(source.Close())
+
+			is.NoError(MigrateBoltToSQLite(dir, key))
+
+			target := &sqlite.DbConnection{Path: dir, EncryptionKey: key}
+			needsEncryption, err := target.NeedsEncryptionMigration()
+			is.NoError(err)
+			is.False(needsEncryption)
+			is.NoError(target.Open())
+			defer target.Close()
+
+			names := []string{}
+			err = target.GetAll("objects", &map[string]interface{}{}, func(o interface{}) (interface{}, error) {
+				object := o.(*map[string]interface{})
+				names = append(names, (*object)["Name"].(string))
+				return &map[string]interface{}{}, nil
+			})
+			is.NoError(err)
+			is.Equal([]string{"first", "second"}, names)
+			is.Equal(3, target.GetNextIdentifier("objects"))
+
+			metadata, err := target.BackupMetadata()
+			is.NoError(err)
+			is.Contains(metadata, "empty")
+
+			_, err = os.Stat(source.GetDatabaseFilePath())
+			is.NoError(err, "the BoltDB database should be kept")
+		})
+	}
+}
+
+func Test_MigrateBoltToSQLite_ExistingDatabase(t *testing.T) {
+	is := assert.New(t)
+	dir := t.TempDir()
+
+	source := &boltdb.DbConnection{Path: dir}
+	is.NoError(source.Open())
+	is.NoError(source.Close())
+
+	f, err := os.Create(path.Join(dir, sqlite.DatabaseFileName))
+	is.NoError(err)
+	f.Close()
+
+	is.Error(MigrateBoltToSQLite(dir, nil))
+}
@@ -0,0 +1,686 @@
+package sqlite
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+
+	"github.com/rs/zerolog/log"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DatabaseFileName          = "portainer.sqlite"
+	EncryptedDatabaseFileName = "portainer.esqlite"
+)
+
+var (
+	ErrHaveEncryptedAndUnencrypted = errors.New("Portainer has detected both an encrypted and un-encrypted database and cannot start.  Only one database should exist")
+	ErrHaveEncryptedWithNoKey      = errors.New("The portainer database is encrypted, but no secret was loaded")
+)
+
+// Every bucket is a row of the buckets table holding the bucket sequence, and every object
+// is a row of the objects table. Keys are stored as blobs so that they sort like BoltDB keys.
+const schema = `
+CREATE TABLE IF NOT EXISTS buckets (
+	name TEXT NOT NULL PRIMARY KEY,
+	sequence INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS objects (
+	bucket TEXT NOT NULL,
+	key BLOB NOT NULL,
+	value BLOB NOT NULL,
+	PRIMARY KEY (bucket, key)
+);`
+
+type DbConnection struct {
+	Path          string
+	EncryptionKey []byte
+	isEncrypted   bool
+
+	// keyMu guards EncryptionKey, rotationMu is held by the operations encrypting or decrypting
+	// objects so that the key cannot be rotated while they run
+	keyMu      sync.RWMutex
+	rotationMu sync.RWMutex
+
+	*sql.DB
+}
+
+// RawObject is a key/value pair as stored in the database, i.e. already marshalled and encrypted
+type RawObject struct {
+	Key   []byte
+	Value []byte
+}
+
+// GetDatabaseFileName get the database filename
+func (connection *DbConnection) GetDatabaseFileName() string {
+	if connection.IsEncryptedStore() {
+		return EncryptedDatabaseFileName
+	}
+
+	return DatabaseFileName
+}
+
+// GetDataseFilePath get the path + filename for the database file
+func (connection *DbConnection) GetDatabaseFilePath() string {
+	return path.Join(connection.Path, connection.GetDatabaseFileName())
+}
+
+// GetStorePath get the filename and path for the database file
+func (connection *DbConnection) GetStorePath() string {
+	return connection.Path
+}
+
+func (connection *DbConnection) SetEncrypted(flag bool) {
+	connection.isEncrypted = flag
+}
+
+// Return true if the database is encrypted
+func (connection *DbConnection) IsEncryptedStore() bool {
+	return connection.getEncryptionKey() != nil
+}
+
+// NeedsEncryptionMigration returns true if database encryption is enabled and
+// we have an un-encrypted DB that requires migration to an encrypted DB.
+// It follows the same rules as the BoltDB connection, using portainer.sqlite and portainer.esqlite.
+func (connection *DbConnection) NeedsEncryptionMigration() (bool, error) {
+	// If we have a loaded encryption key, always set encrypted
+	if connection.EncryptionKey != nil {
+		connection.SetEncrypted(true)
+	}
+
+	_, err := os.Stat(path.Join(connection.Path, DatabaseFileName))
+	haveDbFile := err == nil
+
+	_, err = os.Stat(path.Join(connection.Path, EncryptedDatabaseFileName))
+	haveEdbFile := err == nil
+
+	if haveDbFile && haveEdbFile {
+		return false, ErrHaveEncryptedAndUnencrypted
+	}
+
+	if haveDbFile && connection.EncryptionKey != nil {
+		return true, nil
+	}
+
+	if haveEdbFile && connection.EncryptionKey == nil {
+		return false, ErrHaveEncryptedWithNoKey
+	}
+
+	return false, nil
+}
+
+// Open opens and initializes the SQLite database.
+func (connection *DbConnection) Open() error {
+	log.Info().Str("filename", connection.GetDatabaseFileName()).Msg("loading PortainerDB")
+
+	db, err := sql.Open("sqlite", connection.GetDatabaseFilePath())
+	if err != nil {
+		return err
+	}
+
+	// SQLite only supports a single writer, using a single connection serializes
+	// the writes instead of failing with "database is locked" errors
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	err = os.Chmod(connection.GetDatabaseFilePath(), 0600)
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	connection.DB = db
+	return nil
+}
+
+// Close closes the SQLite database.
+// Safe to being called multiple times.
+func (connection *DbConnection) Close() error {
+	if connection.DB == nil {
+		return nil
+	}
+
+	err := connection.DB.Close()
+	connection.DB = nil
+	return err
+}
+
+// BackupTo backs up db to a provided writer.
+// The database is copied to a temporary file with VACUUM INTO, which doesn't block other database reads
+func (connection *DbConnection) BackupTo(w io.Writer) error {
+	dir, err := ioutil.TempDir("", "portainer-sqlite-backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	backupPath := filepath.Join(dir, DatabaseFileName)
+	_, err = connection.Exec("VACUUM INTO ?", backupPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(backupPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (connection *DbConnection) ExportRaw(filename string) error {
+	databasePath := connection.GetDatabaseFilePath()
+	if _, err := os.Stat(databasePath); err != nil {
+		return fmt.Errorf("stat on %s failed: %s", databasePath, err)
+	}
+
+	b, err := connection.ExportJson(true)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, 0600)
+}
+
+// ConvertToKey returns an 8-byte big endian representation of v.
+// Keys are compared as blobs, so the big endian encoding keeps the objects sorted by ID.
+func (connection *DbConnection) ConvertToKey(v int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	return b
+}
+
+// SetServiceName creates the bucket if it doesn't exist yet.
+func (connection *DbConnection) SetServiceName(bucketName string) error {
+	_, err := connection.Exec("INSERT OR IGNORE INTO buckets (name) VALUES (?)", bucketName)
+	return err
+}
+
+// GetObject is a generic function used to retrieve an unmarshalled object from a database.
+func (connection *DbConnection) GetObject(bucketName string, key []byte, object interface{}) error {
+	defer connection.holdEncryptionKey()()
+
+	var data []byte
+
+	err := connection.QueryRow("SELECT value FROM objects WHERE bucket = ? AND key = ?", bucketName, key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return dserrors.ErrObjectNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return connection.UnmarshalObjectWithJsoniter(data, object)
+}
+
+func (connection *DbConnection) getEncryptionKey() []byte {
+	if !connection.isEncrypted {
+		return nil
+	}
+
+	connection.keyMu.RLock()
+	defer connection.keyMu.RUnlock()
+
+	return connection.EncryptionKey
+}
+
+// holdEncryptionKey prevents the rotation of the encryption key until the returned function is called
+func (connection *DbConnection) holdEncryptionKey() func() {
+	connection.rotationMu.RLock()
+	return connection.rotationMu.RUnlock
+}
+
+// UpdateObject is a generic function used to update an object inside a database.
+func (connection *DbConnection) UpdateObject(bucketName string, key []byte, object interface{}) error {
+	defer connection.holdEncryptionKey()()
+
+	data, err := connection.MarshalObject(object)
+	if err != nil {
+		return err
+	}
+
+	return putObject(connection.DB, bucketName, key, data)
+}
+
+// DeleteObject is a generic function used to delete an object inside a database.
+func (connection *DbConnection) DeleteObject(bucketName string, key []byte) error {
+	_, err := connection.Exec("DELETE FROM objects WHERE bucket = ? AND key = ?", bucketName, key)
+	return err
+}
+
+// DeleteAllObjects delete all objects where matching() returns (id, ok).
+func (connection *DbConnection) DeleteAllObjects(bucketName string, matching func(o interface{}) (id int, ok bool)) error {
+	defer connection.holdEncryptionKey()()
+
+	return connection.update(func(tx *sql.Tx) error {
+		objects, err := listObjects(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		for _, object := range objects {
+			var obj interface{}
+			err := connection.UnmarshalObject(object.Value, &obj)
+			if err != nil {
+				return err
+			}
+
+			if id, ok := matching(obj); ok {
+				_, err := tx.Exec("DELETE FROM objects WHERE bucket = ? AND key = ?", bucketName, connection.ConvertToKey(id))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetNextIdentifier is a generic function that returns the specified bucket identifier incremented by 1.
+func (connection *DbConnection) GetNextIdentifier(bucketName string) int {
+	var identifier int
+
+	connection.update(func(tx *sql.Tx) error {
+		id, err := nextSequence(tx, bucketName)
+		if err != nil {
+			return err
+		}
+		identifier = id
+		return nil
+	})
+
+	return identifier
+}
+
+// CreateObject creates a new object in the bucket, using the next bucket sequence id
+func (connection *DbConnection) CreateObject(bucketName string, fn func(uint64) (int, interface{})) error {
+	defer connection.holdEncryptionKey()()
+
+	return connection.update(func(tx *sql.Tx) error {
+		seqId, err := nextSequence(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		id, obj := fn(uint64(seqId))
+
+		data, err := connection.MarshalObject(obj)
+		if err != nil {
+			return err
+		}
+
+		return putObject(tx, bucketName, connection.ConvertToKey(id), data)
+	})
+}
+
+// CreateObjectWithId creates a new object in the bucket, using the specified id
+func (connection *DbConnection) CreateObjectWithId(bucketName string, id int, obj interface{}) error {
+	return connection.CreateObjectWithStringId(bucketName, connection.ConvertToKey(id), obj)
+}
+
+// CreateObjectWithStringId creates a new object in the bucket, using the specified id
+func (connection *DbConnection) CreateObjectWithStringId(bucketName string, id []byte, obj interface{}) error {
+	defer connection.holdEncryptionKey()()
+
+	data, err := connection.MarshalObject(obj)
+	if err != nil {
+		return err
+	}
+
+	return putObject(connection.DB, bucketName, id, data)
+}
+
+// CreateObjectWithSetSequence creates a new object in the bucket, using the specified id, and sets the bucket sequence
+// avoid this :)
+func (connection *DbConnection) CreateObjectWithSetSequence(bucketName string, id int, obj interface{}) error {
+	defer connection.holdEncryptionKey()()
+
+	data, err := connection.MarshalObject(obj)
+	if err != nil {
+		return err
+	}
+
+	return connection.update(func(tx *sql.Tx) error {
+		err := setSequence(tx, bucketName, id)
+		if err != nil {
+			return err
+		}
+
+		return putObject(tx, bucketName, connection.ConvertToKey(id), data)
+	})
+}
+
+func (connection *DbConnection) GetAll(bucketName string, obj interface{}, append func(o interface{}) (interface{}, error)) error {
+	defer connection.holdEncryptionKey()()
+
+	objects, err := listObjects(connection.DB, bucketName)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		err := connection.UnmarshalObject(object.Value, obj)
+		if err != nil {
+			return err
+		}
+		obj, err = append(obj)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (connection *DbConnection) GetAllWithJsoniter(bucketName string, obj interface{}, append func(o interface{}) (interface{}, error)) error {
+	defer connection.holdEncryptionKey()()
+
+	objects, err := listObjects(connection.DB, bucketName)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		err := connection.UnmarshalObjectWithJsoniter(object.Value, obj)
+		if err != nil {
+			return err
+		}
+		obj, err = append(obj)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAllWithKeyPrefix iterates, in key order, over the objects of a bucket whose key starts with keyPrefix.
+func (connection *DbConnection) GetAllWithKeyPrefix(bucketName string, keyPrefix []byte, obj interface{}, append func(o interface{}) (interface{}, error)) error {
+	defer connection.holdEncryptionKey()()
+
+	objects, err := listObjectsWithKeyPrefix(connection.DB, bucketName, keyPrefix)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		err := connection.UnmarshalObjectWithJsoniter(object.Value, obj)
+		if err != nil {
+			return err
+		}
+		obj, err = append(obj)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (connection *DbConnection) BackupMetadata() (map[string]interface{}, error) {
+	buckets := map[string]interface{}{}
+
+	rows, err := connection.Query("SELECT name, sequence FROM buckets")
+	if err != nil {
+		return buckets, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var sequence int
+		err := rows.Scan(&name, &sequence)
+		if err != nil {
+			return buckets, err
+		}
+
+		buckets[name] = sequence
+	}
+
+	return buckets, rows.Err()
+}
+
+func (connection *DbConnection) RestoreMetadata(s map[string]interface{}) error {
+	var err error
+
+	for bucketName, v := range s {
+		id, ok := v.(float64) // JSON ints are unmarshalled to interface as float64. See: https://pkg.go.dev/encoding/json#Decoder.Decode
+		if !ok {
+			log.Error().Str("bucket", bucketName).Msg("failed to restore metadata to bucket, skipped")
+			continue
+		}
+
+		err = setSequence(connection.DB, bucketName, int(id))
+	}
+
+	return err
+}
+
+// ImportBucket creates the bucket with the given sequence and stores the raw objects as is.
+// It is used to copy a bucket from another database without decrypting its content.
+func (connection *DbConnection) ImportBucket(bucketName string, sequence int, objects []RawObject) error {
+	return connection.update(func(tx *sql.Tx) error {
+		err := setSequence(tx, bucketName, sequence)
+		if err != nil {
+			return err
+		}
+
+		for _, object := range objects {
+			err := putObject(tx, bucketName, object.Key, object.Value)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CountObjects returns the number of objects stored in the bucket
+func (connection *DbConnection) CountObjects(bucketName string) (int, error) {
+	var count int
+	err := connection.QueryRow("SELECT COUNT(*) FROM objects WHERE bucket = ?", bucketName).Scan(&count)
+	return count, err
+}
+
+// SetEncryptionKey replaces the key used to encrypt and decrypt the objects
+func (connection *DbConnection) SetEncryptionKey(key []byte) {
+	connection.rotationMu.Lock()
+	defer connection.rotationMu.Unlock()
+
+	connection.setEncryptionKey(key)
+}
+
+func (connection *DbConnection) setEncryptionKey(key []byte) {
+	connection.keyMu.Lock()
+	defer connection.keyMu.Unlock()
+
+	connection.EncryptionKey = key
+}
+
+// RotateEncryptionKey re-encrypts every object of the database with newKey in a single transaction.
+// Every object is decrypted with newKey before the transaction is committed, the database is left
+// untouched if any of them fails. currentKey must match the key the database is encrypted with.
+func (connection *DbConnection) RotateEncryptionKey(currentKey, newKey []byte) error {
+	// the reads and writes wait for the rotation, they would otherwise use the key not matching the stored objects
+	connection.rotationMu.Lock()
+	defer connection.rotationMu.Unlock()
+
+	if !connection.IsEncryptedStore() {
+		return dserrors.ErrDatabaseNotEncrypted
+	}
+
+	if !bytes.Equal(currentKey, connection.getEncryptionKey()) {
+		return dserrors.ErrWrongEncryptionKey
+	}
+
+	buckets, err := connection.BackupMetadata()
+	if err != nil {
+		return err
+	}
+
+	err = connection.update(func(tx *sql.Tx) error {
+		for bucketName := range buckets {
+			objects, err := listObjects(tx, bucketName)
+			if err != nil {
+				return err
+			}
+
+			for _, object := range objects {
+				data, err := decrypt(object.Value, currentKey)
+				if err != nil {
+					return errors.Wrapf(err, "failed decrypting an object of the %s bucket", bucketName)
+				}
+
+				data, err = encrypt(data, newKey)
+				if err != nil {
+					return err
+				}
+
+				err = putObject(tx, bucketName, object.Key, data)
+				if err != nil {
+					return err
+				}
+			}
+
+			objects, err = listObjects(tx, bucketName)
+			if err != nil {
+				return err
+			}
+
+			for _, object := range objects {
+				_, err := decrypt(object.Value, newKey)
+				if err != nil {
+					return errors.Wrapf(err, "failed decrypting a re-encrypted object of the %s bucket", bucketName)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	connection.setEncryptionKey(newKey)
+	return nil
+}
+
+// update runs fn inside a transaction, committed if fn returns no error
+func (connection *DbConnection) update(fn func(tx *sql.Tx) error) error {
+	tx, err := connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func putObject(db execer, bucketName string, key, value []byte) error {
+	_, err := db.Exec(`INSERT INTO objects (bucket, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value`, bucketName, key, value)
+	return err
+}
+
+// listObjects loads all the objects of the bucket sorted by key. The rows are fully read
+// before returning as the connection is needed by the callers to run other statements.
+func listObjects(db execer, bucketName string) ([]RawObject, error) {
+	return queryObjects(db, "SELECT key, value FROM objects WHERE bucket = ? ORDER BY key", bucketName)
+}
+
+// listObjectsWithKeyPrefix uses a key range rather than LIKE so that the primary key index is used
+// and the binary keys are compared byte by byte.
+func listObjectsWithKeyPrefix(db execer, bucketName string, keyPrefix []byte) ([]RawObject, error) {
+	if len(keyPrefix) == 0 {
+		return listObjects(db, bucketName)
+	}
+
+	end := keyPrefixEnd(keyPrefix)
+	if end == nil {
+		return queryObjects(db, "SELECT key, value FROM objects WHERE bucket = ? AND key >= ? ORDER BY key", bucketName, keyPrefix)
+	}
+
+	return queryObjects(db, "SELECT key, value FROM objects WHERE bucket = ? AND key >= ? AND key < ? ORDER BY key", bucketName, keyPrefix, end)
+}
+
+func queryObjects(db execer, query string, args ...interface{}) ([]RawObject, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	objects := []RawObject{}
+	for rows.Next() {
+		var object RawObject
+		err := rows.Scan(&object.Key, &object.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, object)
+	}
+
+	return objects, rows.Err()
+}
+
+// keyPrefixEnd returns the smallest key greater than every key starting with keyPrefix,
+// or nil when there is none (a prefix made of 0xff bytes only).
+func keyPrefixEnd(keyPrefix []byte) []byte {
+	end := make([]byte, len(keyPrefix))
+	copy(end, keyPrefix)
+
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+
+	return nil
+}
+
+func nextSequence(db execer, bucketName string) (int, error) {
+	var sequence int
+	err := db.QueryRow(`INSERT INTO buckets (name, sequence) VALUES (?, 1)
+		ON CONFLICT (name) DO UPDATE SET sequence = sequence + 1
+		RETURNING sequence`, bucketName).Scan(&sequence)
+	return sequence, err
+}
+
+func setSequence(db execer, bucketName string, sequence int) error {
+	_, err := db.Exec(`INSERT INTO buckets (name, sequence) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET sequence = excluded.sequence`, bucketName, sequence)
+	return err
+}
@@ -0,0 +1,198 @@
+package sqlite
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testObject struct {
+	ID   int
+	Name string
+}
+
+func newTestConnection(t *testing.T, key []byte) *DbConnection {
+	connection := &DbConnection{Path: t.TempDir(), EncryptionKey: key}
+
+	_, err := connection.NeedsEncryptionMigration()
+	assert.NoError(t, err)
+
+	err = connection.Open()
+	assert.NoError(t, err)
+
+	t.Cleanup(func() { connection.Close() })
+
+	return connection
+}
+
+func listTestObjects(t *testing.T, connection *DbConnection, bucketName string) []testObject {
+	objects := []testObject{}
+
+	err := connection.GetAll(bucketName, &testObject{}, func(obj interface{}) (interface{}, error) {
+		object, ok := obj.(*testObject)
+		assert.True(t, ok)
+		objects = append(objects, *object)
+
+		return &testObject{}, nil
+	})
+	assert.NoError(t, err)
+
+	return objects
+}
+
+func Test_ObjectLifecycle(t *testing.T) {
+	hash := sha256.Sum256([]byte("secret"))
+
+	for name, key := range map[string][]byte{"unencrypted": nil, "encrypted": hash[:]} {
+		t.Run(name, func(t *testing.T) {
+			is := assert.New(t)
+			connection := newTestConnection(t, key)
+			is.Equal(key != nil, connection.IsEncryptedStore())
+
+			is.NoError(connection.SetServiceName("objects"))
+			is.NoError(connection.SetServiceName("objects"), "creating an existing bucket should be a no-op")
+
+			for _, name := range []string{"first", "second", "third"} {
+				err := connection.CreateObject("objects", func(id uint64) (int, interface{}) {
+					return int(id), &testObject{ID: int(id), Name: name}
+				})
+				is.NoError(err)
+			}
+
+			var object testObject
+			is.NoError(connection.GetObject("objects", connection.ConvertToKey(2), &object))
+			is.Equal(testObject{ID: 2, Name: "second"}, object)
+
+			err := connection.GetObject("objects", connection.ConvertToKey(42), &object)
+			is.Equal(dserrors.ErrObjectNotFound, err)
+
+			is.NoError(connection.UpdateObject("objects", connection.ConvertToKey(2), &testObject{ID: 2, Name: "updated"}))
+			is.NoError(connection.DeleteObject("objects", connection.ConvertToKey(1)))
+			is.Equal([]testObject{{ID: 2, Name: "updated"}, {ID: 3, Name: "third"}}, listTestObjects(t, connection, "objects"))
+
+			err = connection.DeleteAllObjects("objects", func(o interface{}) (int, bool) {
+				object := o.(map[string]interface{})
+				return int(object["ID"].(float64)), object["Name"] == "third"
+			})
+			is.NoError(err)
+			is.Equal([]testObject{{ID: 2, Name: "updated"}}, listTestObjects(t, connection, "objects"))
+
+			is.Equal(4, connection.GetNextIdentifier("objects"))
+
+			is.NoError(connection.CreateObjectWithSetSequence("objects", 300, &testObject{ID: 300}))
+			is.Equal(301, connection.GetNextIdentifier("objects"))
+
+			var version string
+			is.NoError(connection.SetServiceName("version"))
+			is.NoError(connection.CreateObjectWithStringId("version", []byte("DB_VERSION"), "80"))
+			is.NoError(connection.GetObject("version", []byte("DB_VERSION"), &version))
+			is.Equal("80", version)
+		})
+	}
+}
+
+func Test_UpdateTx(t *testing.T) {
+	is := assert.New(t)
+	connection := newTestConnection(t, nil)
+
+	is.NoError(connection.SetServiceName("objects"))
+	is.NoError(connection.CreateObjectWithId("objects", 1, &testObject{ID: 1, Name: "first"}))
+
+	err := connection.UpdateTx(func(tx portainer.Transaction) error {
+		var object testObject
+		err := tx.GetObject("objects", connection.ConvertToKey(1), &object)
+		if err != nil {
+			return err
+		}
+
+		object.Name = "renamed"
+		err = tx.UpdateObject("objects", connection.ConvertToKey(1), &object)
+		if err != nil {
+			return err
+		}
+
+		return errors.New("rollback")
+	})
+	is.Error(err)
+	is.Equal([]testObject{{ID: 1, Name: "first"}}, listTestObjects(t, connection, "objects"), "the changes must be rolled back")
+
+	err = connection.UpdateTx(func(tx portainer.Transaction) error {
+		err := tx.UpdateObject("objects", connection.ConvertToKey(2), &testObject{ID: 2, Name: "second"})
+		if err != nil {
+			return err
+		}
+
+		var object testObject
+		err = tx.GetObject("objects", connection.ConvertToKey(2), &object)
+		is.NoError(err, "the transaction must read its own changes")
+
+		return tx.DeleteObject("objects", connection.ConvertToKey(1))
+	})
+	is.NoError(err)
+	is.Equal([]testObject{{ID: 2, Name: "second"}}, listTestObjects(t, connection, "objects"))
+}
+
+func Test_ObjectsAreSortedByID(t *testing.T) {
+	is := assert.New(t)
+	connection := newTestConnection(t, nil)
+	is.NoError(connection.SetServiceName("objects"))
+
+	for _, id := range []int{256, 1, 17} {
+		is.NoError(connection.CreateObjectWithId("objects", id, &testObject{ID: id}))
+	}
+
+	is.Equal([]testObject{{ID: 1}, {ID: 17}, {ID: 256}}, listTestObjects(t, connection, "objects"))
+}
+
+func Test_GetAllWithKeyPrefix(t *testing.T) {
+	is := assert.New(t)
+	connection := newTestConnection(t, nil)
+	is.NoError(connection.SetServiceName("objects"))
+
+	keys := map[int][]byte{
+		1: {0x01, 0x00},
+		2: {0x01, 0xff, 0x01},
+		3: {0x02, 0x00},
+		4: {0xff, 0x01},
+		5: {0x01, 0x01},
+	}
+	for id, key := range keys {
+		is.NoError(connection.CreateObjectWithStringId("objects", key, &testObject{ID: id}))
+	}
+
+	list := func(keyPrefix []byte) []testObject {
+		objects := []testObject{}
+
+		err := connection.GetAllWithKeyPrefix("objects", keyPrefix, &testObject{}, func(obj interface{}) (interface{}, error) {
+			objects = append(objects, *obj.(*testObject))
+			return &testObject{}, nil
+		})
+		is.NoError(err)
+
+		return objects
+	}
+
+	is.Equal([]testObject{{ID: 1}, {ID: 5}, {ID: 2}}, list([]byte{0x01}))
+	is.Equal([]testObject{{ID: 2}}, list([]byte{0x01, 0xff}))
+	is.Equal([]testObject{{ID: 4}}, list([]byte{0xff}))
+	is.Equal([]testObject{{ID: 1}, {ID: 5}, {ID: 2}, {ID: 3}, {ID: 4}}, list(nil))
+}
+
+func Test_Metadata(t *testing.T) {
+	is := assert.New(t)
+	connection := newTestConnection(t, nil)
+	is.NoError(connection.SetServiceName("objects"))
+
+	is.NoError(connection.RestoreMetadata(map[string]interface{}{"objects": float64(12), "other": float64(3)}))
+
+	metadata, err := connection.BackupMetadata()
+	is.NoError(err)
+	is.Equal(map[string]interface{}{"objects": 12, "other": 3}, metadata)
+
+	is.Equal(13, connection.GetNextIdentifier("objects"))
+}
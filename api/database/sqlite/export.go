@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"encoding/json"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ExportJson creates a JSON representation from a DbConnection, using the same layout
+// as the BoltDB export. You can include the database's metadata or ignore it.
+// The database is opened for the duration of the export if it is not already open.
+func (connection *DbConnection) ExportJson(metadata bool) ([]byte, error) {
+	log.Debug().Str("databasePath", connection.GetDatabaseFilePath()).Msg("exportJson")
+
+	if connection.DB == nil {
+		err := connection.Open()
+		if err != nil {
+			return []byte("{}"), err
+		}
+		defer connection.Close()
+	}
+
+	backup := make(map[string]interface{})
+	meta, err := connection.BackupMetadata()
+	if err != nil {
+		return []byte("{}"), err
+	}
+
+	if metadata {
+		backup["__metadata"] = meta
+	}
+
+	for bucketName := range meta {
+		objects, err := listObjects(connection.DB, bucketName)
+		if err != nil {
+			return []byte("{}"), err
+		}
+
+		var list []interface{}
+		version := make(map[string]string)
+		for _, object := range objects {
+			var obj interface{}
+			err := connection.UnmarshalObject(object.Value, &obj)
+			if err != nil {
+				log.Error().
+					Str("bucket", bucketName).
+					Str("object", string(object.Value)).
+					Err(err).
+					Msg("failed to unmarshal")
+
+				obj = object.Value
+			}
+
+			if bucketName == "version" {
+				version[string(object.Key)] = string(object.Value)
+			} else {
+				list = append(list, obj)
+			}
+		}
+
+		if bucketName == "version" {
+			backup[bucketName] = version
+			continue
+		}
+
+		if len(list) > 0 {
+			if bucketName == "ssl" ||
+				bucketName == "settings" ||
+				bucketName == "tunnel_server" {
+				backup[bucketName] = list[0]
+				continue
+			}
+			backup[bucketName] = list
+		}
+	}
+
+	return json.MarshalIndent(backup, "", "  ")
+}
@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	portainer "github.com/portainer/portainer/api"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+)
+
+// DbTransaction is a read-write SQLite transaction
+type DbTransaction struct {
+	connection *DbConnection
+	tx         *sql.Tx
+}
+
+// UpdateTx runs fn inside a single read-write transaction, the changes are rolled back if fn returns an error.
+// The database has a single connection, fn must only access the database through the transaction.
+func (connection *DbConnection) UpdateTx(fn func(tx portainer.Transaction) error) error {
+	defer connection.holdEncryptionKey()()
+
+	return connection.update(func(tx *sql.Tx) error {
+		return fn(&DbTransaction{connection: connection, tx: tx})
+	})
+}
+
+// GetObject retrieves an unmarshalled object, including the changes made by the transaction
+func (tx *DbTransaction) GetObject(bucketName string, key []byte, object interface{}) error {
+	var data []byte
+
+	err := tx.tx.QueryRow("SELECT value FROM objects WHERE bucket = ? AND key = ?", bucketName, key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return dserrors.ErrObjectNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.connection.UnmarshalObjectWithJsoniter(data, object)
+}
+
+// UpdateObject updates an object inside the transaction
+func (tx *DbTransaction) UpdateObject(bucketName string, key []byte, object interface{}) error {
+	data, err := tx.connection.MarshalObject(object)
+	if err != nil {
+		return err
+	}
+
+	return putObject(tx.tx, bucketName, key, data)
+}
+
+// CreateObjectWithId creates a new object inside the transaction, using the specified id
+func (tx *DbTransaction) CreateObjectWithId(bucketName string, id int, obj interface{}) error {
+	return tx.UpdateObject(bucketName, tx.connection.ConvertToKey(id), obj)
+}
+
+// DeleteObject deletes an object inside the transaction
+func (tx *DbTransaction) DeleteObject(bucketName string, key []byte) error {
+	_, err := tx.tx.Exec("DELETE FROM objects WHERE bucket = ? AND key = ?", bucketName, key)
+	return err
+}
@@ -0,0 +1,105 @@
+package auditlog
+
+import (
+	"fmt"
+	"sort"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "audit_logs"
+)
+
+// Service represents a service for managing audit log data.
+type Service struct {
+	connection portainer.Connection
+}
+
+func (service *Service) BucketName() string {
+	return BucketName
+}
+
+// NewService creates a new instance of a service.
+func NewService(connection portainer.Connection) (*Service, error) {
+	err := connection.SetServiceName(BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		connection: connection,
+	}, nil
+}
+
+// AuditLogs returns the audit logs matching the filter, most recent first.
+// A nil filter returns all the audit logs.
+func (service *Service) AuditLogs(filter func(auditLog *portainer.AuditLog) bool) ([]portainer.AuditLog, error) {
+	var auditLogs = make([]portainer.AuditLog, 0)
+
+	err := service.connection.GetAllWithJsoniter(
+		BucketName,
+		&portainer.AuditLog{},
+		func(obj interface{}) (interface{}, error) {
+			auditLog, ok := obj.(*portainer.AuditLog)
+			if !ok {
+				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to AuditLog object")
+				return nil, fmt.Errorf("Failed to convert to AuditLog object: %s", obj)
+			}
+
+			if filter == nil || filter(auditLog) {
+				auditLogs = append(auditLogs, *auditLog)
+			}
+
+			return &portainer.AuditLog{}, nil
+		})
+
+	sort.Slice(auditLogs, func(i, j int) bool {
+		if auditLogs[i].Timestamp == auditLogs[j].Timestamp {
+			return auditLogs[i].ID > auditLogs[j].ID
+		}
+
+		return auditLogs[i].Timestamp > auditLogs[j].Timestamp
+	})
+
+	return auditLogs, err
+}
+
+// Create assigns an ID to a new audit log and saves it.
+func (service *Service) Create(auditLog *portainer.AuditLog) error {
+	return service.connection.CreateObject(
+		BucketName,
+		func(id uint64) (int, interface{}) {
+			auditLog.ID = portainer.AuditLogID(id)
+			return int(auditLog.ID), auditLog
+		},
+	)
+}
+
+// DeleteAuditLogsBefore deletes the audit logs recorded before the given unix timestamp.
+func (service *Service) DeleteAuditLogsBefore(timestamp int64) error {
+	return service.connection.DeleteAllObjects(
+		BucketName,
+		func(obj interface{}) (id int, ok bool) {
+			auditLog, ok := obj.(map[string]interface{})
+			if !ok {
+				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to AuditLog object")
+				return -1, false
+			}
+
+			auditLogTimestamp, ok := auditLog["Timestamp"].(float64)
+			if !ok || int64(auditLogTimestamp) >= timestamp {
+				return -1, false
+			}
+
+			auditLogID, ok := auditLog["Id"].(float64)
+			if !ok {
+				return -1, false
+			}
+
+			return int(auditLogID), true
+		})
+}
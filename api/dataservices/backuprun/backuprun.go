@@ -0,0 +1,109 @@
+package backuprun
+
+import (
+	"fmt"
+	"sort"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "backup_runs"
+)
+
+// Service represents a service for managing backup run data.
+type Service struct {
+	connection portainer.Connection
+}
+
+func (service *Service) BucketName() string {
+	return BucketName
+}
+
+// NewService creates a new instance of a service.
+func NewService(connection portainer.Connection) (*Service, error) {
+	err := connection.SetServiceName(BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		connection: connection,
+	}, nil
+}
+
+// BackupRun returns a backup run by ID.
+func (service *Service) BackupRun(ID portainer.BackupRunID) (*portainer.BackupRun, error) {
+	var run portainer.BackupRun
+	identifier := service.connection.ConvertToKey(int(ID))
+
+	err := service.connection.GetObject(BucketName, identifier, &run)
+	if err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// BackupRuns returns an array containing all the backup runs, the most recent first.
+func (service *Service) BackupRuns() ([]portainer.BackupRun, error) {
+	return service.backupRuns(func(run *portainer.BackupRun) bool { return true })
+}
+
+// BackupRunsByScheduleID returns the runs of a backup schedule, the most recent first.
+func (service *Service) BackupRunsByScheduleID(scheduleID portainer.BackupScheduleID) ([]portainer.BackupRun, error) {
+	return service.backupRuns(func(run *portainer.BackupRun) bool { return run.ScheduleID == scheduleID })
+}
+
+func (service *Service) backupRuns(filter func(run *portainer.BackupRun) bool) ([]portainer.BackupRun, error) {
+	var runs = make([]portainer.BackupRun, 0)
+
+	err := service.connection.GetAll(
+		BucketName,
+		&portainer.BackupRun{},
+		func(obj interface{}) (interface{}, error) {
+			run, ok := obj.(*portainer.BackupRun)
+			if !ok {
+				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to BackupRun object")
+				return nil, fmt.Errorf("Failed to convert to BackupRun object: %s", obj)
+			}
+
+			if filter(run) {
+				runs = append(runs, *run)
+			}
+
+			return &portainer.BackupRun{}, nil
+		})
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].ID > runs[j].ID
+	})
+
+	return runs, err
+}
+
+// Create assigns an ID to a new backup run and saves it.
+func (service *Service) Create(run *portainer.BackupRun) error {
+	return service.connection.CreateObject(
+		BucketName,
+		func(id uint64) (int, interface{}) {
+			run.ID = portainer.BackupRunID(id)
+			return int(run.ID), run
+		},
+	)
+}
+
+// UpdateBackupRun updates a backup run.
+func (service *Service) UpdateBackupRun(ID portainer.BackupRunID, run *portainer.BackupRun) error {
+	identifier := service.connection.ConvertToKey(int(ID))
+	return service.connection.UpdateObject(BucketName, identifier, run)
+}
+
+// DeleteBackupRun deletes a backup run.
+func (service *Service) DeleteBackupRun(ID portainer.BackupRunID) error {
+	identifier := service.connection.ConvertToKey(int(ID))
+	return service.connection.DeleteObject(BucketName, identifier)
+}
@@ -0,0 +1,93 @@
+package backupschedule
+
+import (
+	"fmt"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "backup_schedules"
+)
+
+// Service represents a service for managing backup schedule data.
+type Service struct {
+	connection portainer.Connection
+}
+
+func (service *Service) BucketName() string {
+	return BucketName
+}
+
+// NewService creates a new instance of a service.
+func NewService(connection portainer.Connection) (*Service, error) {
+	err := connection.SetServiceName(BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		connection: connection,
+	}, nil
+}
+
+// BackupSchedule returns a backup schedule by ID.
+func (service *Service) BackupSchedule(ID portainer.BackupScheduleID) (*portainer.BackupSchedule, error) {
+	var schedule portainer.BackupSchedule
+	identifier := service.connection.ConvertToKey(int(ID))
+
+	err := service.connection.GetObject(BucketName, identifier, &schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// BackupSchedules returns an array containing all the backup schedules.
+func (service *Service) BackupSchedules() ([]portainer.BackupSchedule, error) {
+	var schedules = make([]portainer.BackupSchedule, 0)
+
+	err := service.connection.GetAll(
+		BucketName,
+		&portainer.BackupSchedule{},
+		func(obj interface{}) (interface{}, error) {
+			schedule, ok := obj.(*portainer.BackupSchedule)
+			if !ok {
+				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to BackupSchedule object")
+				return nil, fmt.Errorf("Failed to convert to BackupSchedule object: %s", obj)
+			}
+
+			schedules = append(schedules, *schedule)
+
+			return &portainer.BackupSchedule{}, nil
+		})
+
+	return schedules, err
+}
+
+// Create assigns an ID to a new backup schedule and saves it.
+func (service *Service) Create(schedule *portainer.BackupSchedule) error {
+	return service.connection.CreateObject(
+		BucketName,
+		func(id uint64) (int, interface{}) {
+			schedule.ID = portainer.BackupScheduleID(id)
+			return int(schedule.ID), schedule
+		},
+	)
+}
+
+// UpdateBackupSchedule updates a backup schedule.
+func (service *Service) UpdateBackupSchedule(ID portainer.BackupScheduleID, schedule *portainer.BackupSchedule) error {
+	identifier := service.connection.ConvertToKey(int(ID))
+	return service.connection.UpdateObject(BucketName, identifier, schedule)
+}
+
+// DeleteBackupSchedule deletes a backup schedule.
+func (service *Service) DeleteBackupSchedule(ID portainer.BackupScheduleID) error {
+	identifier := service.connection.ConvertToKey(int(ID))
+	return service.connection.DeleteObject(BucketName, identifier)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices/revision"
 
 	"github.com/rs/zerolog/log"
 )
@@ -85,7 +86,7 @@ func (service *Service) Create(id portainer.EdgeStackID, edgeStack *portainer.Ed
 // UpdateEdgeStack updates an Edge stack.
 func (service *Service) UpdateEdgeStack(ID portainer.EdgeStackID, edgeStack *portainer.EdgeStack) error {
 	identifier := service.connection.ConvertToKey(int(ID))
-	return service.connection.UpdateObject(BucketName, identifier, edgeStack)
+	return revision.UpdateObject(service.connection, BucketName, identifier, edgeStack, &edgeStack.Revision)
 }
 
 // DeleteEdgeStack deletes an Edge stack.
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices/revision"
 
 	"github.com/rs/zerolog/log"
 )
@@ -50,7 +51,14 @@ func (service *Service) Endpoint(ID portainer.EndpointID) (*portainer.Endpoint,
 // UpdateEndpoint updates an environment(endpoint).
 func (service *Service) UpdateEndpoint(ID portainer.EndpointID, endpoint *portainer.Endpoint) error {
 	identifier := service.connection.ConvertToKey(int(ID))
-	return service.connection.UpdateObject(BucketName, identifier, endpoint)
+	return revision.UpdateObject(service.connection, BucketName, identifier, endpoint, &endpoint.Revision)
+}
+
+// UpdateEndpointStatus updates an environment(endpoint) without changing its revision. It is used for the status,
+// check-in and snapshot updates made in the background, which must not fail the If-Match updates of the users.
+func (service *Service) UpdateEndpointStatus(ID portainer.EndpointID, endpoint *portainer.Endpoint) error {
+	identifier := service.connection.ConvertToKey(int(ID))
+	return revision.UpdateObjectKeepRevision(service.connection, BucketName, identifier, endpoint, &endpoint.Revision)
 }
 
 // DeleteEndpoint deletes an environment(endpoint).
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices/revision"
 
 	"github.com/rs/zerolog/log"
 )
@@ -50,7 +51,7 @@ func (service *Service) EndpointGroup(ID portainer.EndpointGroupID) (*portainer.
 // UpdateEndpointGroup updates an environment(endpoint) group.
 func (service *Service) UpdateEndpointGroup(ID portainer.EndpointGroupID, endpointGroup *portainer.EndpointGroup) error {
 	identifier := service.connection.ConvertToKey(int(ID))
-	return service.connection.UpdateObject(BucketName, identifier, endpointGroup)
+	return revision.UpdateObject(service.connection, BucketName, identifier, endpointGroup, &endpointGroup.Revision)
 }
 
 // DeleteEndpointGroup deletes an environment(endpoint) group.
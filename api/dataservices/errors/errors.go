@@ -7,4 +7,8 @@ var (
 	ErrObjectNotFound = errors.New("object not found inside the database")
 	ErrWrongDBEdition = errors.New("the Portainer database is set for Portainer Business Edition, please follow the instructions in our documentation to downgrade it: https://documentation.portainer.io/v2.0-be/downgrade/be-to-ce/")
 	ErrDBImportFailed = errors.New("importing backup failed")
+	// ErrDatabaseNotEncrypted is returned when an encryption operation is requested on an unencrypted database
+	ErrDatabaseNotEncrypted = errors.New("the Portainer database is not encrypted")
+	// ErrWrongEncryptionKey is returned when the provided encryption key is not the one the database is encrypted with
+	ErrWrongEncryptionKey = errors.New("the secret does not match the secret the Portainer database is encrypted with")
 )
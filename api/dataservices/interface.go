@@ -23,8 +23,15 @@ type (
 		CheckCurrentEdition() error
 		BackupTo(w io.Writer) error
 		Export(filename string) (err error)
+		MergeBuckets(filename string, buckets []string) (map[string][]int, error)
 		IsErrObjectNotFound(err error) bool
+		CheckIntegrity() (*portainer.StoreIntegrityReport, error)
+		RepairIntegrity() (*portainer.StoreIntegrityReport, error)
+		RotateEncryptionKey(currentKey, newKey []byte) error
 
+		AuditLog() AuditLogService
+		BackupRun() BackupRunService
+		BackupSchedule() BackupScheduleService
 		CustomTemplate() CustomTemplateService
 		EdgeGroup() EdgeGroupService
 		EdgeJob() EdgeJobService
@@ -35,11 +42,14 @@ type (
 		EndpointRelation() EndpointRelationService
 		FDOProfile() FDOProfileService
 		HelmUserRepository() HelmUserRepositoryService
+		KubernetesSnapshotRaw() KubernetesSnapshotRawService
 		Registry() RegistryService
 		ResourceControl() ResourceControlService
 		Role() RoleService
 		APIKeyRepository() APIKeyRepository
+		Session() SessionService
 		Settings() SettingsService
+		SnapshotHistory() SnapshotHistoryService
 		SSLSettings() SSLSettingsService
 		Stack() StackService
 		Tag() TagService
@@ -51,6 +61,35 @@ type (
 		Webhook() WebhookService
 	}
 
+	// AuditLogService represents a service for managing audit log data
+	AuditLogService interface {
+		AuditLogs(filter func(auditLog *portainer.AuditLog) bool) ([]portainer.AuditLog, error)
+		Create(auditLog *portainer.AuditLog) error
+		DeleteAuditLogsBefore(timestamp int64) error
+		BucketName() string
+	}
+
+	// BackupRunService represents a service for managing backup run data
+	BackupRunService interface {
+		BackupRun(ID portainer.BackupRunID) (*portainer.BackupRun, error)
+		BackupRuns() ([]portainer.BackupRun, error)
+		BackupRunsByScheduleID(scheduleID portainer.BackupScheduleID) ([]portainer.BackupRun, error)
+		Create(run *portainer.BackupRun) error
+		UpdateBackupRun(ID portainer.BackupRunID, run *portainer.BackupRun) error
+		DeleteBackupRun(ID portainer.BackupRunID) error
+		BucketName() string
+	}
+
+	// BackupScheduleService represents a service for managing backup schedule data
+	BackupScheduleService interface {
+		BackupSchedule(ID portainer.BackupScheduleID) (*portainer.BackupSchedule, error)
+		BackupSchedules() ([]portainer.BackupSchedule, error)
+		Create(schedule *portainer.BackupSchedule) error
+		UpdateBackupSchedule(ID portainer.BackupScheduleID, schedule *portainer.BackupSchedule) error
+		DeleteBackupSchedule(ID portainer.BackupScheduleID) error
+		BucketName() string
+	}
+
 	// CustomTemplateService represents a service to manage custom templates
 	CustomTemplateService interface {
 		GetNextIdentifier() int
@@ -111,6 +150,7 @@ type (
 		Endpoints() ([]portainer.Endpoint, error)
 		Create(endpoint *portainer.Endpoint) error
 		UpdateEndpoint(ID portainer.EndpointID, endpoint *portainer.Endpoint) error
+		UpdateEndpointStatus(ID portainer.EndpointID, endpoint *portainer.Endpoint) error
 		DeleteEndpoint(ID portainer.EndpointID) error
 		GetNextIdentifier() int
 		BucketName() string
@@ -160,10 +200,14 @@ type (
 	// JWTService represents a service for managing JWT tokens
 	JWTService interface {
 		GenerateToken(data *portainer.TokenData) (string, error)
-		GenerateTokenForOAuth(data *portainer.TokenData, expiryTime *time.Time) (string, error)
 		GenerateTokenForKubeconfig(data *portainer.TokenData) (string, error)
+		GeneratePreAuthToken(data *portainer.TokenData) (string, error)
 		ParseAndVerifyToken(token string) (*portainer.TokenData, error)
+		ParsePreAuthToken(token string) (*portainer.TokenData, error)
 		SetUserSessionDuration(userSessionDuration time.Duration)
+		GenerateSessionToken(data *portainer.TokenData, userAgent, ipAddress string) (string, error)
+		RevokeSession(ID string) error
+		DeleteExpiredSessions(now time.Time) error
 	}
 
 	// RegistryService represents a service for managing registry data
@@ -202,10 +246,22 @@ type (
 		GetAPIKey(keyID portainer.APIKeyID) (*portainer.APIKey, error)
 		UpdateAPIKey(key *portainer.APIKey) error
 		DeleteAPIKey(ID portainer.APIKeyID) error
+		GetAPIKeys() ([]portainer.APIKey, error)
 		GetAPIKeysByUserID(userID portainer.UserID) ([]portainer.APIKey, error)
 		GetAPIKeyByDigest(digest []byte) (*portainer.APIKey, error)
 	}
 
+	// SessionService represents a service for managing session data
+	SessionService interface {
+		Session(ID string) (*portainer.Session, error)
+		Sessions(filter func(session *portainer.Session) bool) ([]portainer.Session, error)
+		SessionsByUserID(userID portainer.UserID) ([]portainer.Session, error)
+		Create(session *portainer.Session) error
+		UpdateSession(session *portainer.Session) error
+		DeleteSession(ID string) error
+		BucketName() string
+	}
+
 	// SettingsService represents a service for managing application settings
 	SettingsService interface {
 		Settings() (*portainer.Settings, error)
@@ -214,6 +270,23 @@ type (
 		BucketName() string
 	}
 
+	// KubernetesSnapshotRawService represents a service for managing the raw resources of the Kubernetes snapshots
+	KubernetesSnapshotRawService interface {
+		SnapshotRaw(endpointID portainer.EndpointID) (*portainer.KubernetesSnapshotRaw, error)
+		UpdateSnapshotRaw(endpointID portainer.EndpointID, snapshotRaw *portainer.KubernetesSnapshotRaw) error
+		DeleteSnapshotRaw(endpointID portainer.EndpointID) error
+		BucketName() string
+	}
+
+	// SnapshotHistoryService represents a service for managing environment(endpoint) snapshot history data
+	SnapshotHistoryService interface {
+		EntriesByEndpointID(endpointID portainer.EndpointID, from, to int64) ([]portainer.SnapshotHistoryEntry, error)
+		Create(entry *portainer.SnapshotHistoryEntry) error
+		DeleteEntries(entries []portainer.SnapshotHistoryEntry) error
+		DeleteEntriesByEndpointID(endpointID portainer.EndpointID) error
+		BucketName() string
+	}
+
 	// SSLSettingsService represents a service for managing application settings
 	SSLSettingsService interface {
 		Settings() (*portainer.SSLSettings, error)
@@ -286,6 +359,7 @@ type (
 		UsersByRole(role portainer.UserRole) ([]portainer.User, error)
 		Create(user *portainer.User) error
 		UpdateUser(ID portainer.UserID, user *portainer.User) error
+		UpdateUserFunc(ID portainer.UserID, updateFunc func(user *portainer.User) bool) (*portainer.User, error)
 		DeleteUser(ID portainer.UserID) error
 		BucketName() string
 	}
@@ -0,0 +1,57 @@
+package kubernetessnapshotraw
+
+import (
+	portainer "github.com/portainer/portainer/api"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "kubernetes_snapshot_raw"
+)
+
+// Service represents a service for managing the raw resources of the Kubernetes environment(endpoint) snapshots.
+// They are kept apart from the environments, which are read far more often than them.
+type Service struct {
+	connection portainer.Connection
+}
+
+func (service *Service) BucketName() string {
+	return BucketName
+}
+
+// NewService creates a new instance of a service.
+func NewService(connection portainer.Connection) (*Service, error) {
+	err := connection.SetServiceName(BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		connection: connection,
+	}, nil
+}
+
+// SnapshotRaw returns the raw resources of the latest snapshot of an environment(endpoint)
+func (service *Service) SnapshotRaw(endpointID portainer.EndpointID) (*portainer.KubernetesSnapshotRaw, error) {
+	var snapshotRaw portainer.KubernetesSnapshotRaw
+	identifier := service.connection.ConvertToKey(int(endpointID))
+
+	err := service.connection.GetObject(BucketName, identifier, &snapshotRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshotRaw, nil
+}
+
+// UpdateSnapshotRaw saves the raw resources of the latest snapshot of an environment(endpoint)
+func (service *Service) UpdateSnapshotRaw(endpointID portainer.EndpointID, snapshotRaw *portainer.KubernetesSnapshotRaw) error {
+	identifier := service.connection.ConvertToKey(int(endpointID))
+	return service.connection.UpdateObject(BucketName, identifier, snapshotRaw)
+}
+
+// DeleteSnapshotRaw deletes the raw resources of the snapshot of an environment(endpoint)
+func (service *Service) DeleteSnapshotRaw(endpointID portainer.EndpointID) error {
+	identifier := service.connection.ConvertToKey(int(endpointID))
+	return service.connection.DeleteObject(BucketName, identifier)
+}
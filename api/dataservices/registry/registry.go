@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices/revision"
 
 	"github.com/rs/zerolog/log"
 )
@@ -83,7 +84,7 @@ func (service *Service) Create(registry *portainer.Registry) error {
 // UpdateRegistry updates an registry.
 func (service *Service) UpdateRegistry(ID portainer.RegistryID, registry *portainer.Registry) error {
 	identifier := service.connection.ConvertToKey(int(ID))
-	return service.connection.UpdateObject(BucketName, identifier, registry)
+	return revision.UpdateObject(service.connection, BucketName, identifier, registry, &registry.Revision)
 }
 
 // DeleteRegistry deletes an registry.
@@ -0,0 +1,52 @@
+// Package revision maintains the revision of the resources returned as their ETag header.
+package revision
+
+import (
+	portainer "github.com/portainer/portainer/api"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+)
+
+// UpdateObject updates an object with the revision following the stored revision, so that the revision changes
+// on every update of the object, including the updates made from a copy read before a concurrent update.
+// revision points to the revision field of object.
+func UpdateObject(connection portainer.Connection, bucketName string, key []byte, object interface{}, revision *int) error {
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		return UpdateObjectTx(tx, bucketName, key, object, revision)
+	})
+}
+
+// UpdateObjectTx is UpdateObject inside an existing transaction.
+func UpdateObjectTx(tx portainer.Transaction, bucketName string, key []byte, object interface{}, revision *int) error {
+	var stored struct {
+		Revision int
+	}
+
+	err := tx.GetObject(bucketName, key, &stored)
+	if err != nil && err != dserrors.ErrObjectNotFound {
+		return err
+	}
+
+	*revision = stored.Revision + 1
+
+	return tx.UpdateObject(bucketName, key, object)
+}
+
+// UpdateObjectKeepRevision updates an object with the stored revision, for the updates that are not made by users
+// such as the status and snapshot updates, so that they do not change the ETag of the object.
+// revision points to the revision field of object.
+func UpdateObjectKeepRevision(connection portainer.Connection, bucketName string, key []byte, object interface{}, revision *int) error {
+	return connection.UpdateTx(func(tx portainer.Transaction) error {
+		var stored struct {
+			Revision int
+		}
+
+		err := tx.GetObject(bucketName, key, &stored)
+		if err != nil && err != dserrors.ErrObjectNotFound {
+			return err
+		}
+
+		*revision = stored.Revision
+
+		return tx.UpdateObject(bucketName, key, object)
+	})
+}
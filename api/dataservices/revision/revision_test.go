@@ -0,0 +1,66 @@
+package revision_test
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateObject_shouldIncrementTheStoredRevision(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1, Name: "local"}))
+
+	stale, err := store.Endpoint().Endpoint(1)
+	require.NoError(t, err)
+
+	endpoint, err := store.Endpoint().Endpoint(1)
+	require.NoError(t, err)
+
+	endpoint.Status = portainer.EndpointStatusDown
+	require.NoError(t, store.Endpoint().UpdateEndpoint(endpoint.ID, endpoint))
+	is.Equal(1, endpoint.Revision)
+
+	require.NoError(t, store.Endpoint().UpdateEndpoint(stale.ID, stale))
+	is.Equal(2, stale.Revision, "an update made from a stale copy must still change the revision")
+
+	stored, err := store.Endpoint().Endpoint(1)
+	require.NoError(t, err)
+	is.Equal(2, stored.Revision)
+}
+
+func TestUpdateObjectKeepRevision_shouldKeepTheStoredRevision(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	require.NoError(t, store.Endpoint().Create(&portainer.Endpoint{ID: 1, Name: "local"}))
+
+	endpoint, err := store.Endpoint().Endpoint(1)
+	require.NoError(t, err)
+
+	endpoint.Name = "renamed"
+	require.NoError(t, store.Endpoint().UpdateEndpoint(endpoint.ID, endpoint))
+	is.Equal(1, endpoint.Revision)
+
+	stale, err := store.Endpoint().Endpoint(1)
+	require.NoError(t, err)
+	stale.Revision = 0
+
+	stale.LastCheckInDate = 42
+	require.NoError(t, store.Endpoint().UpdateEndpointStatus(stale.ID, stale))
+	is.Equal(1, stale.Revision, "a status update must keep the stored revision")
+
+	stored, err := store.Endpoint().Endpoint(1)
+	require.NoError(t, err)
+	is.Equal(1, stored.Revision)
+	is.Equal(int64(42), stored.LastCheckInDate)
+}
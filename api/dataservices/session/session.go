@@ -0,0 +1,94 @@
+package session
+
+import (
+	"fmt"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "sessions"
+)
+
+// Service represents a service for managing session data.
+// The sessions are keyed by the JWT identifier of their token.
+type Service struct {
+	connection portainer.Connection
+}
+
+func (service *Service) BucketName() string {
+	return BucketName
+}
+
+// NewService creates a new instance of a service.
+func NewService(connection portainer.Connection) (*Service, error) {
+	err := connection.SetServiceName(BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		connection: connection,
+	}, nil
+}
+
+// Session returns a session by its identifier.
+func (service *Service) Session(ID string) (*portainer.Session, error) {
+	var session portainer.Session
+
+	err := service.connection.GetObject(BucketName, []byte(ID), &session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Sessions returns the sessions matching the filter. A nil filter returns all the sessions.
+func (service *Service) Sessions(filter func(session *portainer.Session) bool) ([]portainer.Session, error) {
+	var sessions = make([]portainer.Session, 0)
+
+	err := service.connection.GetAll(
+		BucketName,
+		&portainer.Session{},
+		func(obj interface{}) (interface{}, error) {
+			session, ok := obj.(*portainer.Session)
+			if !ok {
+				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to Session object")
+				return nil, fmt.Errorf("Failed to convert to Session object: %s", obj)
+			}
+
+			if filter == nil || filter(session) {
+				sessions = append(sessions, *session)
+			}
+
+			return &portainer.Session{}, nil
+		})
+
+	return sessions, err
+}
+
+// SessionsByUserID returns the sessions of a user.
+func (service *Service) SessionsByUserID(userID portainer.UserID) ([]portainer.Session, error) {
+	return service.Sessions(func(session *portainer.Session) bool {
+		return session.UserID == userID
+	})
+}
+
+// Create saves a new session.
+func (service *Service) Create(session *portainer.Session) error {
+	return service.connection.CreateObjectWithStringId(BucketName, []byte(session.ID), session)
+}
+
+// UpdateSession saves a session.
+func (service *Service) UpdateSession(session *portainer.Session) error {
+	return service.connection.UpdateObject(BucketName, []byte(session.ID), session)
+}
+
+// DeleteSession deletes a session.
+func (service *Service) DeleteSession(ID string) error {
+	return service.connection.DeleteObject(BucketName, []byte(ID))
+}
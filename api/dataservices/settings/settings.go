@@ -2,6 +2,7 @@ package settings
 
 import (
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices/revision"
 )
 
 const (
@@ -45,7 +46,7 @@ func (service *Service) Settings() (*portainer.Settings, error) {
 
 // UpdateSettings persists a Settings object.
 func (service *Service) UpdateSettings(settings *portainer.Settings) error {
-	return service.connection.UpdateObject(BucketName, []byte(settingsKey), settings)
+	return revision.UpdateObject(service.connection, BucketName, []byte(settingsKey), settings, &settings.Revision)
 }
 
 func (service *Service) IsFeatureFlagEnabled(feature portainer.Feature) bool {
@@ -0,0 +1,109 @@
+package snapshothistory
+
+import (
+	"fmt"
+	"math"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// BucketName represents the name of the bucket where this service stores data.
+	BucketName = "snapshot_history"
+)
+
+// Service represents a service for managing environment(endpoint) snapshot history data.
+type Service struct {
+	connection portainer.Connection
+}
+
+func (service *Service) BucketName() string {
+	return BucketName
+}
+
+// NewService creates a new instance of a service.
+func NewService(connection portainer.Connection) (*Service, error) {
+	err := connection.SetServiceName(BucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		connection: connection,
+	}, nil
+}
+
+// EntriesByEndpointID returns the snapshot history entries of an environment(endpoint)
+// recorded between from and to (unix timestamps, inclusive), ordered by time.
+func (service *Service) EntriesByEndpointID(endpointID portainer.EndpointID, from, to int64) ([]portainer.SnapshotHistoryEntry, error) {
+	var entries = make([]portainer.SnapshotHistoryEntry, 0)
+
+	// the keys start with the environment identifier followed by the time, so that
+	// the entries of an environment are read in order with a single prefix scan
+	err := service.connection.GetAllWithKeyPrefix(
+		BucketName,
+		service.connection.ConvertToKey(int(endpointID)),
+		&portainer.SnapshotHistoryEntry{},
+		func(obj interface{}) (interface{}, error) {
+			entry, ok := obj.(*portainer.SnapshotHistoryEntry)
+			if !ok {
+				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to SnapshotHistoryEntry object")
+				return nil, fmt.Errorf("Failed to convert to SnapshotHistoryEntry object: %s", obj)
+			}
+
+			if entry.EndpointID == endpointID && entry.Time >= from && entry.Time <= to {
+				entries = append(entries, *entry)
+			}
+
+			return &portainer.SnapshotHistoryEntry{}, nil
+		})
+
+	return entries, err
+}
+
+// Create assigns an ID to a new snapshot history entry and saves it.
+func (service *Service) Create(entry *portainer.SnapshotHistoryEntry) error {
+	entry.ID = portainer.SnapshotHistoryEntryID(service.connection.GetNextIdentifier(BucketName))
+
+	return service.connection.CreateObjectWithStringId(BucketName, service.entryKey(entry), entry)
+}
+
+// DeleteEntries deletes the given snapshot history entries.
+func (service *Service) DeleteEntries(entries []portainer.SnapshotHistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	return service.connection.UpdateTx(func(tx portainer.Transaction) error {
+		for i := range entries {
+			err := tx.DeleteObject(BucketName, service.entryKey(&entries[i]))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteEntriesByEndpointID deletes all the snapshot history entries of an environment(endpoint).
+func (service *Service) DeleteEntriesByEndpointID(endpointID portainer.EndpointID) error {
+	entries, err := service.EntriesByEndpointID(endpointID, math.MinInt64, math.MaxInt64)
+	if err != nil {
+		return err
+	}
+
+	return service.DeleteEntries(entries)
+}
+
+// entryKey builds the key of a snapshot history entry from its environment(endpoint) identifier,
+// its time and its own identifier.
+func (service *Service) entryKey(entry *portainer.SnapshotHistoryEntry) []byte {
+	key := make([]byte, 0, 24)
+	key = append(key, service.connection.ConvertToKey(int(entry.EndpointID))...)
+	key = append(key, service.connection.ConvertToKey(int(entry.Time))...)
+
+	return append(key, service.connection.ConvertToKey(int(entry.ID))...)
+}
@@ -6,6 +6,7 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices/errors"
+	"github.com/portainer/portainer/api/dataservices/revision"
 
 	"github.com/rs/zerolog/log"
 )
@@ -140,7 +141,7 @@ func (service *Service) Create(stack *portainer.Stack) error {
 // UpdateStack updates a stack.
 func (service *Service) UpdateStack(ID portainer.StackID, stack *portainer.Stack) error {
 	identifier := service.connection.ConvertToKey(int(ID))
-	return service.connection.UpdateObject(BucketName, identifier, stack)
+	return revision.UpdateObject(service.connection, BucketName, identifier, stack, &stack.Revision)
 }
 
 // DeleteStack deletes a stack.
@@ -145,18 +145,7 @@ func (service *Service) DeleteTeamMembershipByUserID(userID portainer.UserID) er
 	return service.connection.DeleteAllObjects(
 		BucketName,
 		func(obj interface{}) (id int, ok bool) {
-			membership, ok := obj.(portainer.TeamMembership)
-			if !ok {
-				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to TeamMembership object")
-				//return fmt.Errorf("Failed to convert to TeamMembership object: %s", obj)
-				return -1, false
-			}
-
-			if membership.UserID == userID {
-				return int(membership.ID), true
-			}
-
-			return -1, false
+			return membershipIDMatching(obj, "UserID", int(userID))
 		})
 }
 
@@ -165,17 +154,28 @@ func (service *Service) DeleteTeamMembershipByTeamID(teamID portainer.TeamID) er
 	return service.connection.DeleteAllObjects(
 		BucketName,
 		func(obj interface{}) (id int, ok bool) {
-			membership, ok := obj.(portainer.TeamMembership)
-			if !ok {
-				log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to TeamMembership object")
-				//return fmt.Errorf("Failed to convert to TeamMembership object: %s", obj)
-				return -1, false
-			}
+			return membershipIDMatching(obj, "TeamID", int(teamID))
+		})
+}
 
-			if membership.TeamID == teamID {
-				return int(membership.ID), true
-			}
+// membershipIDMatching returns the identifier of a team membership, given in its generic JSON
+// representation as provided to DeleteAllObjects, when its field is equal to value
+func membershipIDMatching(obj interface{}, field string, value int) (int, bool) {
+	membership, ok := obj.(map[string]interface{})
+	if !ok {
+		log.Debug().Str("obj", fmt.Sprintf("%#v", obj)).Msg("failed to convert to TeamMembership object")
+		return -1, false
+	}
 
-			return -1, false
-		})
+	fieldValue, ok := membership[field].(float64)
+	if !ok || int(fieldValue) != value {
+		return -1, false
+	}
+
+	membershipID, ok := membership["Id"].(float64)
+	if !ok {
+		return -1, false
+	}
+
+	return int(membershipID), true
 }
@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_deleteTeamMemberships(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	memberships := []portainer.TeamMembership{
+		{UserID: 1, TeamID: 1, Role: portainer.TeamMember},
+		{UserID: 1, TeamID: 2, Role: portainer.TeamMember},
+		{UserID: 2, TeamID: 1, Role: portainer.TeamLeader},
+		{UserID: 3, TeamID: 2, Role: portainer.TeamMember},
+	}
+	for i := range memberships {
+		err := store.TeamMembership().Create(&memberships[i])
+		assert.NoError(t, err, "Create should succeed")
+	}
+
+	err := store.TeamMembership().DeleteTeamMembershipByUserID(1)
+	assert.NoError(t, err, "DeleteTeamMembershipByUserID should succeed")
+
+	remaining, err := store.TeamMembership().TeamMemberships()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, memberships[2:], remaining, "only the memberships of the user should be deleted")
+
+	err = store.TeamMembership().DeleteTeamMembershipByTeamID(1)
+	assert.NoError(t, err, "DeleteTeamMembershipByTeamID should succeed")
+
+	remaining, err = store.TeamMembership().TeamMemberships()
+	assert.NoError(t, err)
+	assert.Equal(t, memberships[3:], remaining, "only the memberships of the team should be deleted")
+}
@@ -138,6 +138,33 @@ func (service *Service) UpdateUser(ID portainer.UserID, user *portainer.User) er
 	return service.connection.UpdateObject(BucketName, identifier, user)
 }
 
+// UpdateUserFunc reads a user and applies updateFunc to it inside a single transaction, so that
+// concurrent updates cannot overwrite each other. The user is only saved when updateFunc returns true.
+// It returns the user as read or updated inside the transaction.
+func (service *Service) UpdateUserFunc(ID portainer.UserID, updateFunc func(user *portainer.User) bool) (*portainer.User, error) {
+	var user portainer.User
+	identifier := service.connection.ConvertToKey(int(ID))
+
+	err := service.connection.UpdateTx(func(tx portainer.Transaction) error {
+		err := tx.GetObject(BucketName, identifier, &user)
+		if err != nil {
+			return err
+		}
+
+		if !updateFunc(&user) {
+			return nil
+		}
+
+		user.Username = strings.ToLower(user.Username)
+		return tx.UpdateObject(BucketName, identifier, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
 // CreateUser creates a new user.
 func (service *Service) Create(user *portainer.User) error {
 	return service.connection.CreateObject(
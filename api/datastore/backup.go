@@ -127,6 +127,39 @@ func (store *Store) backupWithOptions(options *BackupOptions) (string, error) {
 	return options.BackupPath, nil
 }
 
+// hotBackup writes a backup of the database to the common backup folder without closing the store,
+// the other database reads and writes are not interrupted
+func (store *Store) hotBackup() (string, error) {
+	log.Info().Msg("creating DB hot backup")
+
+	store.createBackupFolders()
+
+	options := store.setupOptions(nil)
+
+	// the backups are named by the second, a backup made in the same second must not be overwritten
+	backupPath := options.BackupPath
+	f, err := os.OpenFile(backupPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	for i := 1; os.IsExist(err); i++ {
+		backupPath = fmt.Sprintf("%s.%d", options.BackupPath, i)
+		f, err = os.OpenFile(backupPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	}
+	if err != nil {
+		return backupPath, err
+	}
+
+	err = store.BackupTo(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(backupPath)
+		return backupPath, err
+	}
+
+	return backupPath, nil
+}
+
 // RestoreWithOptions previously saved backup for the current Edition  with options
 // Restore strategies:
 // - default: restore latest from current edition
@@ -7,6 +7,7 @@ import (
 	"path"
 	"time"
 
+	"github.com/pkg/errors"
 	portainer "github.com/portainer/portainer/api"
 	portainerErrors "github.com/portainer/portainer/api/dataservices/errors"
 
@@ -176,3 +177,39 @@ func (store *Store) encryptDB() error {
 
 	return nil
 }
+
+// RotateEncryptionKey re-encrypts the database with newKey, currentKey must be the key the database is encrypted with.
+// The database is backed up first and re-encrypted in a single transaction, it is restored from the backup if the
+// re-encryption fails. The new key is not persisted, Portainer must be restarted with the secret replaced by the new secret.
+func (store *Store) RotateEncryptionKey(currentKey, newKey []byte) error {
+	if !store.connection.IsEncryptedStore() {
+		return portainerErrors.ErrDatabaseNotEncrypted
+	}
+
+	backupPath, err := store.hotBackup()
+	if err != nil {
+		return errors.Wrap(err, "failed to backup the database before the encryption key rotation")
+	}
+
+	log.Info().Msg("rotating the database encryption key")
+
+	err = store.connection.RotateEncryptionKey(currentKey, newKey)
+	if errors.Is(err, portainerErrors.ErrWrongEncryptionKey) {
+		return err
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("backup", backupPath).Msg("failed to rotate the database encryption key, restoring the backup")
+
+		restoreErr := store.restoreWithOptions(&BackupOptions{BackupPath: backupPath})
+		if restoreErr != nil {
+			return errors.Wrapf(err, "failed to restore the backup %s: %v", backupPath, restoreErr)
+		}
+
+		return err
+	}
+
+	log.Info().Str("backup", backupPath).Msg("database encryption key rotated, the secret must be replaced with the new secret")
+
+	return nil
+}
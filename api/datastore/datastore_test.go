@@ -61,6 +61,9 @@ func TestStoreFull(t *testing.T) {
 		"Tags": func(t *testing.T) {
 			store.testTags(t)
 		},
+		"Snapshot History": func(t *testing.T) {
+			store.testSnapshotHistory(t)
+		},
 
 		// "Test Title": func(t *testing.T) {
 		// },
@@ -415,3 +418,42 @@ func (store *Store) testTags(t *testing.T) {
 	is.NoError(err, "tag2 should be found")
 	is.Equal(tag2, actual, "tags differ")
 }
+
+func (store *Store) testSnapshotHistory(t *testing.T) {
+	is := assert.New(t)
+
+	history := store.SnapshotHistory()
+
+	entries := []portainer.SnapshotHistoryEntry{
+		{EndpointID: 1, Time: 300},
+		{EndpointID: 2, Time: 100},
+		{EndpointID: 1, Time: 100},
+		{EndpointID: 256, Time: 200},
+		{EndpointID: 1, Time: 200},
+	}
+	for i := range entries {
+		is.NoError(history.Create(&entries[i]), "SnapshotHistory.Create should succeed")
+	}
+
+	actual, err := history.EntriesByEndpointID(1, 0, 1000)
+	is.NoError(err)
+	is.Equal([]portainer.SnapshotHistoryEntry{entries[2], entries[4], entries[0]}, actual, "entries should be ordered by time")
+
+	actual, err = history.EntriesByEndpointID(1, 150, 250)
+	is.NoError(err)
+	is.Equal([]portainer.SnapshotHistoryEntry{entries[4]}, actual)
+
+	is.NoError(history.DeleteEntries([]portainer.SnapshotHistoryEntry{entries[4]}))
+	actual, err = history.EntriesByEndpointID(1, 0, 1000)
+	is.NoError(err)
+	is.Equal([]portainer.SnapshotHistoryEntry{entries[2], entries[0]}, actual)
+
+	is.NoError(history.DeleteEntriesByEndpointID(1))
+	actual, err = history.EntriesByEndpointID(1, 0, 1000)
+	is.NoError(err)
+	is.Empty(actual)
+
+	actual, err = history.EntriesByEndpointID(256, 0, 1000)
+	is.NoError(err)
+	is.Equal([]portainer.SnapshotHistoryEntry{entries[3]}, actual, "the entries of the other environments should be kept")
+}
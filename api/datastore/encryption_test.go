@@ -0,0 +1,154 @@
+package datastore
+
+import (
+	"crypto/sha256"
+	"os"
+	"sync"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database"
+	"github.com/portainer/portainer/api/database/boltdb"
+	portainerErrors "github.com/portainer/portainer/api/dataservices/errors"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestRotateEncryptionKey(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := MustNewTestStore(t, true, true)
+	defer teardown()
+
+	is.NoError(store.Endpoint().Create(&portainer.Endpoint{ID: 1, Name: "local"}))
+
+	currentKey := []byte("apassphrasewhichneedstobe32bytes")
+	hash := sha256.Sum256([]byte("new secret"))
+	newKey := hash[:]
+
+	err := store.RotateEncryptionKey(newKey, newKey)
+	is.ErrorIs(err, portainerErrors.ErrWrongEncryptionKey)
+
+	endpoint, err := store.Endpoint().Endpoint(1)
+	is.NoError(err, "the database should still be readable with the current key")
+	is.Equal("local", endpoint.Name)
+
+	endpointService := store.Endpoint()
+
+	is.NoError(store.RotateEncryptionKey(currentKey, newKey))
+	is.Same(endpointService, store.Endpoint(), "the rotation must not reopen the store")
+
+	endpoint, err = store.Endpoint().Endpoint(1)
+	is.NoError(err)
+	is.Equal("local", endpoint.Name)
+
+	storePath := store.connection.GetStorePath()
+	is.NoError(store.Close())
+
+	files, err := os.ReadDir(storePath)
+	is.NoError(err)
+	for _, file := range files {
+		is.NotContains(file.Name(), "key", "the new key must not be written next to the database")
+	}
+
+	connection, err := database.NewDatabase("boltdb", storePath, newKey)
+	is.NoError(err)
+
+	reopened := NewStore(storePath, store.fileService, connection)
+	_, err = reopened.Open()
+	is.NoError(err)
+	defer reopened.Close()
+
+	endpoint, err = reopened.Endpoint().Endpoint(1)
+	is.NoError(err, "the database should be readable with the new key")
+	is.Equal("local", endpoint.Name)
+}
+
+func TestRotateEncryptionKey_RestoresTheBackupOnFailure(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := MustNewTestStore(t, true, true)
+	defer teardown()
+
+	is.NoError(store.Endpoint().Create(&portainer.Endpoint{ID: 1, Name: "local"}))
+
+	// an object which cannot be decrypted makes the re-encryption fail
+	connection := store.connection.(*boltdb.DbConnection)
+	err := connection.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("endpoints")).Put([]byte("corrupted"), []byte("not encrypted"))
+	})
+	is.NoError(err)
+
+	currentKey := []byte("apassphrasewhichneedstobe32bytes")
+	hash := sha256.Sum256([]byte("new secret"))
+
+	endpointService := store.Endpoint()
+
+	is.Error(store.RotateEncryptionKey(currentKey, hash[:]))
+	is.NotSame(endpointService, store.Endpoint(), "the store should be reopened on the restored backup")
+
+	endpoint, err := store.Endpoint().Endpoint(1)
+	is.NoError(err, "the database should still be readable with the current key")
+	is.Equal("local", endpoint.Name)
+}
+
+func TestRotateEncryptionKey_ConcurrentReads(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := MustNewTestStore(t, true, true)
+	defer teardown()
+
+	is.NoError(store.Endpoint().Create(&portainer.Endpoint{ID: 1, Name: "local"}))
+
+	currentKey := []byte("apassphrasewhichneedstobe32bytes")
+	hash := sha256.Sum256([]byte("new secret"))
+
+	done := make(chan struct{})
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				_, err := store.Endpoint().Endpoint(1)
+				if err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	is.NoError(store.RotateEncryptionKey(currentKey, hash[:]))
+
+	close(done)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		is.NoError(err, "the reads must not fail during the rotation")
+	default:
+	}
+}
+
+func TestRotateEncryptionKey_UnencryptedStore(t *testing.T) {
+	_, store, teardown := MustNewTestStore(t, true, false)
+	defer teardown()
+
+	hash := sha256.Sum256([]byte("new secret"))
+	err := store.RotateEncryptionKey(nil, hash[:])
+	assert.ErrorIs(t, err, portainerErrors.ErrDatabaseNotEncrypted)
+}
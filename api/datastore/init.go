@@ -71,6 +71,8 @@ func (store *Store) checkOrCreateDefaultSettings() error {
 			UserSessionTimeout:       portainer.DefaultUserSessionTimeout,
 			KubeconfigExpiry:         portainer.DefaultKubeconfigExpiry,
 			KubectlShellImage:        portainer.DefaultKubectlShellImage,
+			SnapshotHistory:          defaultSnapshotHistorySettings(),
+			AuditLog:                 portainer.AuditLogSettings{Retention: portainer.DefaultAuditLogRetention},
 		}
 
 		return store.SettingsService.UpdateSettings(defaultSettings)
@@ -79,13 +81,37 @@ func (store *Store) checkOrCreateDefaultSettings() error {
 		return err
 	}
 
+	updated := false
+
 	if settings.UserSessionTimeout == "" {
 		settings.UserSessionTimeout = portainer.DefaultUserSessionTimeout
+		updated = true
+	}
+
+	if settings.SnapshotHistory.Retention == "" {
+		settings.SnapshotHistory = defaultSnapshotHistorySettings()
+		updated = true
+	}
+
+	if settings.AuditLog.Retention == "" {
+		settings.AuditLog.Retention = portainer.DefaultAuditLogRetention
+		updated = true
+	}
+
+	if updated {
 		return store.Settings().UpdateSettings(settings)
 	}
 	return nil
 }
 
+func defaultSnapshotHistorySettings() portainer.SnapshotHistorySettings {
+	return portainer.SnapshotHistorySettings{
+		Retention:            portainer.DefaultSnapshotHistoryRetention,
+		DownsampleAfter:      portainer.DefaultSnapshotHistoryDownsampleAfter,
+		DownsampleResolution: portainer.DefaultSnapshotHistoryDownsampleResolution,
+	}
+}
+
 func (store *Store) checkOrCreateDefaultSSLSettings() error {
 	_, err := store.SSLSettings().Settings()
 
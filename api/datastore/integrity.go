@@ -0,0 +1,659 @@
+package datastore
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices/edgegroup"
+	"github.com/portainer/portainer/api/dataservices/edgestack"
+	"github.com/portainer/portainer/api/dataservices/endpoint"
+	"github.com/portainer/portainer/api/dataservices/endpointgroup"
+	"github.com/portainer/portainer/api/dataservices/endpointrelation"
+	"github.com/portainer/portainer/api/dataservices/resourcecontrol"
+	"github.com/portainer/portainer/api/dataservices/stack"
+	"github.com/portainer/portainer/api/dataservices/tag"
+	"github.com/portainer/portainer/api/dataservices/teammembership"
+	"github.com/portainer/portainer/api/internal/stackutils"
+
+	"github.com/rs/zerolog/log"
+)
+
+// the buckets of the checked objects, named apart from the packages so that they are not shadowed by the objects
+const (
+	edgeGroupBucket        = edgegroup.BucketName
+	edgeStackBucket        = edgestack.BucketName
+	endpointBucket         = endpoint.BucketName
+	endpointGroupBucket    = endpointgroup.BucketName
+	endpointRelationBucket = endpointrelation.BucketName
+	resourceControlBucket  = resourcecontrol.BucketName
+	stackBucket            = stack.BucketName
+	tagBucket              = tag.BucketName
+	teamMembershipBucket   = teammembership.BucketName
+)
+
+// integrityIssue is a broken reference along with the operation repairing it.
+// cleanup removes the files of a deleted object once the repair is committed, it is nil when there is none.
+type integrityIssue struct {
+	portainer.StoreIntegrityIssue
+	repair  func(tx portainer.Transaction) error
+	cleanup func() error
+}
+
+// integrityChecker holds the content of the datastore used to cross-check the references.
+// The repair functions read the objects again inside the repair transaction, so that they
+// apply to the latest version of the objects and several repairs of a same object all persist.
+type integrityChecker struct {
+	store *Store
+
+	endpoints       []portainer.Endpoint
+	endpointGroups  []portainer.EndpointGroup
+	tags            []portainer.Tag
+	users           []portainer.User
+	teams           []portainer.Team
+	memberships     []portainer.TeamMembership
+	relations       []portainer.EndpointRelation
+	edgeGroups      []portainer.EdgeGroup
+	edgeStacks      []portainer.EdgeStack
+	stacks          []portainer.Stack
+	resourceControl []portainer.ResourceControl
+
+	endpointIDs      map[portainer.EndpointID]bool
+	endpointGroupIDs map[portainer.EndpointGroupID]bool
+	tagIDs           map[portainer.TagID]bool
+	userIDs          map[portainer.UserID]bool
+	teamIDs          map[portainer.TeamID]bool
+	edgeGroupIDs     map[portainer.EdgeGroupID]bool
+	edgeStackIDs     map[portainer.EdgeStackID]bool
+	stackResourceIDs map[string]*portainer.Stack
+
+	issues []integrityIssue
+}
+
+// CheckIntegrity cross-checks the references between the objects of the datastore
+// and reports the references to objects that no longer exist.
+func (store *Store) CheckIntegrity() (*portainer.StoreIntegrityReport, error) {
+	issues, err := store.integrityIssues()
+	if err != nil {
+		return nil, err
+	}
+
+	return integrityReport(issues), nil
+}
+
+// RepairIntegrity backs up the database then repairs all the broken references in a single transaction.
+// The backup does not interrupt the other database accesses, and the repairs are applied as a whole.
+func (store *Store) RepairIntegrity() (*portainer.StoreIntegrityReport, error) {
+	issues, err := store.integrityIssues()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(issues) == 0 {
+		return integrityReport(issues), nil
+	}
+
+	backupPath, err := store.hotBackup()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to backup the database before the repair")
+	}
+
+	err = store.connection.UpdateTx(func(tx portainer.Transaction) error {
+		for _, issue := range issues {
+			err := issue.repair(tx)
+			if err != nil {
+				return errors.Wrapf(err, "failed to repair the %s object %s", issue.Bucket, issue.ObjectID)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to repair the datastore, no change was made")
+		return nil, err
+	}
+
+	// the files are only removed once the objects referencing them are deleted
+	for _, issue := range issues {
+		if issue.cleanup == nil {
+			continue
+		}
+
+		err := issue.cleanup()
+		if err != nil {
+			log.Warn().Err(err).Str("bucket", issue.Bucket).Str("object_id", issue.ObjectID).Msg("failed to remove the files of a deleted object")
+		}
+	}
+
+	report := integrityReport(issues)
+	report.Repaired = true
+	report.BackupPath = backupPath
+
+	return report, nil
+}
+
+func integrityReport(issues []integrityIssue) *portainer.StoreIntegrityReport {
+	report := &portainer.StoreIntegrityReport{Issues: make([]portainer.StoreIntegrityIssue, 0, len(issues))}
+	for _, issue := range issues {
+		report.Issues = append(report.Issues, issue.StoreIntegrityIssue)
+	}
+
+	return report
+}
+
+func (store *Store) integrityIssues() ([]integrityIssue, error) {
+	checker := &integrityChecker{store: store}
+
+	err := checker.load()
+	if err != nil {
+		return nil, err
+	}
+
+	checker.checkEndpoints()
+	checker.checkEndpointGroups()
+	checker.checkTags()
+	checker.checkTeamMemberships()
+	checker.checkEndpointRelations()
+	checker.checkEdgeGroups()
+	checker.checkEdgeStacks()
+	checker.checkStacks()
+	checker.checkResourceControls()
+
+	return checker.issues, nil
+}
+
+func (checker *integrityChecker) load() error {
+	var err error
+	store := checker.store
+
+	if checker.endpoints, err = store.Endpoint().Endpoints(); err != nil {
+		return errors.Wrap(err, "failed loading environments")
+	}
+	if checker.endpointGroups, err = store.EndpointGroup().EndpointGroups(); err != nil {
+		return errors.Wrap(err, "failed loading environment groups")
+	}
+	if checker.tags, err = store.Tag().Tags(); err != nil {
+		return errors.Wrap(err, "failed loading tags")
+	}
+	if checker.users, err = store.User().Users(); err != nil {
+		return errors.Wrap(err, "failed loading users")
+	}
+	if checker.teams, err = store.Team().Teams(); err != nil {
+		return errors.Wrap(err, "failed loading teams")
+	}
+	if checker.memberships, err = store.TeamMembership().TeamMemberships(); err != nil {
+		return errors.Wrap(err, "failed loading team memberships")
+	}
+	if checker.relations, err = store.EndpointRelation().EndpointRelations(); err != nil {
+		return errors.Wrap(err, "failed loading environment relations")
+	}
+	if checker.edgeGroups, err = store.EdgeGroup().EdgeGroups(); err != nil {
+		return errors.Wrap(err, "failed loading edge groups")
+	}
+	if checker.edgeStacks, err = store.EdgeStack().EdgeStacks(); err != nil {
+		return errors.Wrap(err, "failed loading edge stacks")
+	}
+	if checker.stacks, err = store.Stack().Stacks(); err != nil {
+		return errors.Wrap(err, "failed loading stacks")
+	}
+	if checker.resourceControl, err = store.ResourceControl().ResourceControls(); err != nil {
+		return errors.Wrap(err, "failed loading resource controls")
+	}
+
+	checker.endpointIDs = make(map[portainer.EndpointID]bool)
+	for _, endpoint := range checker.endpoints {
+		checker.endpointIDs[endpoint.ID] = true
+	}
+
+	checker.endpointGroupIDs = make(map[portainer.EndpointGroupID]bool)
+	for _, group := range checker.endpointGroups {
+		checker.endpointGroupIDs[group.ID] = true
+	}
+
+	checker.tagIDs = make(map[portainer.TagID]bool)
+	for _, tag := range checker.tags {
+		checker.tagIDs[tag.ID] = true
+	}
+
+	checker.userIDs = make(map[portainer.UserID]bool)
+	for _, user := range checker.users {
+		checker.userIDs[user.ID] = true
+	}
+
+	checker.teamIDs = make(map[portainer.TeamID]bool)
+	for _, team := range checker.teams {
+		checker.teamIDs[team.ID] = true
+	}
+
+	checker.edgeGroupIDs = make(map[portainer.EdgeGroupID]bool)
+	for _, group := range checker.edgeGroups {
+		checker.edgeGroupIDs[group.ID] = true
+	}
+
+	checker.edgeStackIDs = make(map[portainer.EdgeStackID]bool)
+	for _, edgeStack := range checker.edgeStacks {
+		checker.edgeStackIDs[edgeStack.ID] = true
+	}
+
+	// stack resource controls were identified by the stack name before being identified by the environment and the name
+	checker.stackResourceIDs = make(map[string]*portainer.Stack)
+	for i := range checker.stacks {
+		stack := &checker.stacks[i]
+		checker.stackResourceIDs[stack.Name] = stack
+		checker.stackResourceIDs[stackutils.ResourceControlID(stack.EndpointID, stack.Name)] = stack
+	}
+
+	return nil
+}
+
+func (checker *integrityChecker) report(bucket string, objectID int, description, repairDescription string, repair func(tx portainer.Transaction) error) {
+	checker.reportWithCleanup(bucket, objectID, description, repairDescription, repair, nil)
+}
+
+func (checker *integrityChecker) reportWithCleanup(bucket string, objectID int, description, repairDescription string, repair func(tx portainer.Transaction) error, cleanup func() error) {
+	checker.issues = append(checker.issues, integrityIssue{
+		StoreIntegrityIssue: portainer.StoreIntegrityIssue{
+			Bucket:      bucket,
+			ObjectID:    strconv.Itoa(objectID),
+			Description: description,
+			Repair:      repairDescription,
+		},
+		repair:  repair,
+		cleanup: cleanup,
+	})
+}
+
+// update applies change to the latest version of an object, read inside the repair transaction.
+// An object deleted since the check has nothing left to repair.
+func (checker *integrityChecker) update(tx portainer.Transaction, bucket string, ID int, object interface{}, change func()) error {
+	key := checker.store.connection.ConvertToKey(ID)
+
+	err := tx.GetObject(bucket, key, object)
+	if checker.store.IsErrObjectNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	change()
+
+	return tx.UpdateObject(bucket, key, object)
+}
+
+func (checker *integrityChecker) delete(tx portainer.Transaction, bucket string, ID int) error {
+	return tx.DeleteObject(bucket, checker.store.connection.ConvertToKey(ID))
+}
+
+func (checker *integrityChecker) checkEndpoints() {
+	for _, endpoint := range checker.endpoints {
+		endpointID := int(endpoint.ID)
+		update := func(tx portainer.Transaction, change func(endpoint *portainer.Endpoint)) error {
+			endpoint := &portainer.Endpoint{}
+			return checker.update(tx, endpointBucket, endpointID, endpoint, func() { change(endpoint) })
+		}
+
+		if !checker.endpointGroupIDs[endpoint.GroupID] {
+			checker.report(endpointBucket, endpointID,
+				fmt.Sprintf("belongs to the environment group %d which does not exist", endpoint.GroupID),
+				"move the environment to the Unassigned group",
+				func(tx portainer.Transaction) error {
+					return update(tx, func(endpoint *portainer.Endpoint) {
+						if !checker.endpointGroupIDs[endpoint.GroupID] {
+							endpoint.GroupID = portainer.EndpointGroupID(1)
+						}
+					})
+				})
+		}
+
+		for _, tagID := range endpoint.TagIDs {
+			if !checker.tagIDs[tagID] {
+				tagID := tagID
+				checker.report(endpointBucket, endpointID,
+					fmt.Sprintf("references the tag %d which does not exist", tagID),
+					"remove the tag from the environment",
+					func(tx portainer.Transaction) error {
+						return update(tx, func(endpoint *portainer.Endpoint) {
+							endpoint.TagIDs = removeTagID(endpoint.TagIDs, tagID)
+						})
+					})
+			}
+		}
+
+		for userID := range endpoint.UserAccessPolicies {
+			if !checker.userIDs[userID] {
+				userID := userID
+				checker.report(endpointBucket, endpointID,
+					fmt.Sprintf("grants access to the user %d which does not exist", userID),
+					"remove the user access policy",
+					func(tx portainer.Transaction) error {
+						return update(tx, func(endpoint *portainer.Endpoint) {
+							delete(endpoint.UserAccessPolicies, userID)
+						})
+					})
+			}
+		}
+
+		for teamID := range endpoint.TeamAccessPolicies {
+			if !checker.teamIDs[teamID] {
+				teamID := teamID
+				checker.report(endpointBucket, endpointID,
+					fmt.Sprintf("grants access to the team %d which does not exist", teamID),
+					"remove the team access policy",
+					func(tx portainer.Transaction) error {
+						return update(tx, func(endpoint *portainer.Endpoint) {
+							delete(endpoint.TeamAccessPolicies, teamID)
+						})
+					})
+			}
+		}
+	}
+}
+
+func (checker *integrityChecker) checkEndpointGroups() {
+	for _, group := range checker.endpointGroups {
+		groupID := int(group.ID)
+		update := func(tx portainer.Transaction, change func(group *portainer.EndpointGroup)) error {
+			group := &portainer.EndpointGroup{}
+			return checker.update(tx, endpointGroupBucket, groupID, group, func() { change(group) })
+		}
+
+		for _, tagID := range group.TagIDs {
+			if !checker.tagIDs[tagID] {
+				tagID := tagID
+				checker.report(endpointGroupBucket, groupID,
+					fmt.Sprintf("references the tag %d which does not exist", tagID),
+					"remove the tag from the environment group",
+					func(tx portainer.Transaction) error {
+						return update(tx, func(group *portainer.EndpointGroup) {
+							group.TagIDs = removeTagID(group.TagIDs, tagID)
+						})
+					})
+			}
+		}
+
+		for userID := range group.UserAccessPolicies {
+			if !checker.userIDs[userID] {
+				userID := userID
+				checker.report(endpointGroupBucket, groupID,
+					fmt.Sprintf("grants access to the user %d which does not exist", userID),
+					"remove the user access policy",
+					func(tx portainer.Transaction) error {
+						return update(tx, func(group *portainer.EndpointGroup) {
+							delete(group.UserAccessPolicies, userID)
+						})
+					})
+			}
+		}
+
+		for teamID := range group.TeamAccessPolicies {
+			if !checker.teamIDs[teamID] {
+				teamID := teamID
+				checker.report(endpointGroupBucket, groupID,
+					fmt.Sprintf("grants access to the team %d which does not exist", teamID),
+					"remove the team access policy",
+					func(tx portainer.Transaction) error {
+						return update(tx, func(group *portainer.EndpointGroup) {
+							delete(group.TeamAccessPolicies, teamID)
+						})
+					})
+			}
+		}
+	}
+}
+
+func (checker *integrityChecker) checkTags() {
+	for _, tag := range checker.tags {
+		tagID := int(tag.ID)
+		update := func(tx portainer.Transaction, change func(tag *portainer.Tag)) error {
+			tag := &portainer.Tag{}
+			return checker.update(tx, tagBucket, tagID, tag, func() { change(tag) })
+		}
+
+		for endpointID := range tag.Endpoints {
+			if !checker.endpointIDs[endpointID] {
+				endpointID := endpointID
+				checker.report(tagBucket, tagID,
+					fmt.Sprintf("references the environment %d which does not exist", endpointID),
+					"remove the environment from the tag",
+					func(tx portainer.Transaction) error {
+						return update(tx, func(tag *portainer.Tag) {
+							delete(tag.Endpoints, endpointID)
+						})
+					})
+			}
+		}
+
+		for groupID := range tag.EndpointGroups {
+			if !checker.endpointGroupIDs[groupID] {
+				groupID := groupID
+				checker.report(tagBucket, tagID,
+					fmt.Sprintf("references the environment group %d which does not exist", groupID),
+					"remove the environment group from the tag",
+					func(tx portainer.Transaction) error {
+						return update(tx, func(tag *portainer.Tag) {
+							delete(tag.EndpointGroups, groupID)
+						})
+					})
+			}
+		}
+	}
+}
+
+func (checker *integrityChecker) checkTeamMemberships() {
+	for _, membership := range checker.memberships {
+		membershipID := int(membership.ID)
+		deleteMembership := func(tx portainer.Transaction) error {
+			return checker.delete(tx, teamMembershipBucket, membershipID)
+		}
+
+		if !checker.userIDs[membership.UserID] {
+			checker.report(teamMembershipBucket, membershipID,
+				fmt.Sprintf("references the user %d which does not exist", membership.UserID),
+				"delete the team membership", deleteMembership)
+			continue
+		}
+
+		if !checker.teamIDs[membership.TeamID] {
+			checker.report(teamMembershipBucket, membershipID,
+				fmt.Sprintf("references the team %d which does not exist", membership.TeamID),
+				"delete the team membership", deleteMembership)
+		}
+	}
+}
+
+func (checker *integrityChecker) checkEndpointRelations() {
+	for _, relation := range checker.relations {
+		endpointID := int(relation.EndpointID)
+
+		if !checker.endpointIDs[relation.EndpointID] {
+			checker.report(endpointRelationBucket, endpointID,
+				fmt.Sprintf("references the environment %d which does not exist", relation.EndpointID),
+				"delete the environment relation",
+				func(tx portainer.Transaction) error { return checker.delete(tx, endpointRelationBucket, endpointID) })
+			continue
+		}
+
+		for edgeStackID := range relation.EdgeStacks {
+			if !checker.edgeStackIDs[edgeStackID] {
+				edgeStackID := edgeStackID
+				checker.report(endpointRelationBucket, endpointID,
+					fmt.Sprintf("references the edge stack %d which does not exist", edgeStackID),
+					"remove the edge stack from the environment relation",
+					func(tx portainer.Transaction) error {
+						relation := &portainer.EndpointRelation{}
+						return checker.update(tx, endpointRelationBucket, endpointID, relation, func() {
+							delete(relation.EdgeStacks, edgeStackID)
+						})
+					})
+			}
+		}
+	}
+}
+
+func (checker *integrityChecker) checkEdgeGroups() {
+	for _, group := range checker.edgeGroups {
+		groupID := int(group.ID)
+
+		for _, endpointID := range group.Endpoints {
+			if !checker.endpointIDs[endpointID] {
+				endpointID := endpointID
+				checker.report(edgeGroupBucket, groupID,
+					fmt.Sprintf("references the environment %d which does not exist", endpointID),
+					"remove the environment from the edge group",
+					func(tx portainer.Transaction) error {
+						group := &portainer.EdgeGroup{}
+						return checker.update(tx, edgeGroupBucket, groupID, group, func() {
+							group.Endpoints = removeEndpointID(group.Endpoints, endpointID)
+						})
+					})
+			}
+		}
+	}
+}
+
+func (checker *integrityChecker) checkEdgeStacks() {
+	for _, edgeStack := range checker.edgeStacks {
+		edgeStackID := int(edgeStack.ID)
+
+		for _, groupID := range edgeStack.EdgeGroups {
+			if !checker.edgeGroupIDs[groupID] {
+				groupID := groupID
+				checker.report(edgeStackBucket, edgeStackID,
+					fmt.Sprintf("references the edge group %d which does not exist", groupID),
+					"remove the edge group from the edge stack",
+					func(tx portainer.Transaction) error {
+						edgeStack := &portainer.EdgeStack{}
+						return checker.update(tx, edgeStackBucket, edgeStackID, edgeStack, func() {
+							edgeStack.EdgeGroups = removeEdgeGroupID(edgeStack.EdgeGroups, groupID)
+						})
+					})
+			}
+		}
+	}
+}
+
+// hasStackEndpoint returns true when the environment of a stack exists. The legacy stacks created
+// before the stacks were associated to an environment have no environment and are left as they are.
+func (checker *integrityChecker) hasStackEndpoint(stack *portainer.Stack) bool {
+	return stack.EndpointID == 0 || checker.endpointIDs[stack.EndpointID]
+}
+
+func (checker *integrityChecker) checkStacks() {
+	for _, stack := range checker.stacks {
+		if checker.hasStackEndpoint(&stack) {
+			continue
+		}
+
+		stackID := int(stack.ID)
+		projectPath := stack.ProjectPath
+
+		var cleanup func() error
+		if projectPath != "" {
+			cleanup = func() error { return checker.store.fileService.RemoveDirectory(projectPath) }
+		}
+
+		checker.reportWithCleanup(stackBucket, stackID,
+			fmt.Sprintf("is deployed on the environment %d which does not exist", stack.EndpointID),
+			"delete the stack and its files",
+			func(tx portainer.Transaction) error { return checker.delete(tx, stackBucket, stackID) },
+			cleanup)
+	}
+}
+
+func (checker *integrityChecker) checkResourceControls() {
+	for _, resourceControl := range checker.resourceControl {
+		resourceControlID := int(resourceControl.ID)
+		update := func(tx portainer.Transaction, change func(resourceControl *portainer.ResourceControl)) error {
+			resourceControl := &portainer.ResourceControl{}
+			return checker.update(tx, resourceControlBucket, resourceControlID, resourceControl, func() { change(resourceControl) })
+		}
+
+		if resourceControl.Type == portainer.StackResourceControl {
+			stack, ok := checker.stackResourceIDs[resourceControl.ResourceID]
+			if !ok || !checker.hasStackEndpoint(stack) {
+				checker.report(resourceControlBucket, resourceControlID,
+					fmt.Sprintf("controls the access to the stack %s which does not exist", resourceControl.ResourceID),
+					"delete the resource control",
+					func(tx portainer.Transaction) error {
+						return checker.delete(tx, resourceControlBucket, resourceControlID)
+					})
+				continue
+			}
+		}
+
+		for _, access := range resourceControl.UserAccesses {
+			if !checker.userIDs[access.UserID] {
+				userID := access.UserID
+				checker.report(resourceControlBucket, resourceControlID,
+					fmt.Sprintf("grants access to the user %d which does not exist", userID),
+					"remove the user access",
+					func(tx portainer.Transaction) error {
+						return update(tx, func(resourceControl *portainer.ResourceControl) {
+							accesses := []portainer.UserResourceAccess{}
+							for _, access := range resourceControl.UserAccesses {
+								if access.UserID != userID {
+									accesses = append(accesses, access)
+								}
+							}
+							resourceControl.UserAccesses = accesses
+						})
+					})
+			}
+		}
+
+		for _, access := range resourceControl.TeamAccesses {
+			if !checker.teamIDs[access.TeamID] {
+				teamID := access.TeamID
+				checker.report(resourceControlBucket, resourceControlID,
+					fmt.Sprintf("grants access to the team %d which does not exist", teamID),
+					"remove the team access",
+					func(tx portainer.Transaction) error {
+						return update(tx, func(resourceControl *portainer.ResourceControl) {
+							accesses := []portainer.TeamResourceAccess{}
+							for _, access := range resourceControl.TeamAccesses {
+								if access.TeamID != teamID {
+									accesses = append(accesses, access)
+								}
+							}
+							resourceControl.TeamAccesses = accesses
+						})
+					})
+			}
+		}
+	}
+}
+
+func removeTagID(tagIDs []portainer.TagID, removedID portainer.TagID) []portainer.TagID {
+	result := []portainer.TagID{}
+	for _, ID := range tagIDs {
+		if ID != removedID {
+			result = append(result, ID)
+		}
+	}
+
+	return result
+}
+
+func removeEndpointID(endpointIDs []portainer.EndpointID, removedID portainer.EndpointID) []portainer.EndpointID {
+	result := []portainer.EndpointID{}
+	for _, ID := range endpointIDs {
+		if ID != removedID {
+			result = append(result, ID)
+		}
+	}
+
+	return result
+}
+
+func removeEdgeGroupID(groupIDs []portainer.EdgeGroupID, removedID portainer.EdgeGroupID) []portainer.EdgeGroupID {
+	result := []portainer.EdgeGroupID{}
+	for _, ID := range groupIDs {
+		if ID != removedID {
+			result = append(result, ID)
+		}
+	}
+
+	return result
+}
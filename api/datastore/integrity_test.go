@@ -0,0 +1,95 @@
+package datastore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreIntegrity(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := MustNewTestStore(t, true, false)
+	defer teardown()
+
+	is.NoError(store.Endpoint().Create(&portainer.Endpoint{ID: 1, GroupID: 1, TagIDs: []portainer.TagID{1, 9}}))
+	is.NoError(store.Tag().Create(&portainer.Tag{
+		ID:             1,
+		Endpoints:      map[portainer.EndpointID]bool{1: true, 2: true},
+		EndpointGroups: map[portainer.EndpointGroupID]bool{},
+	}))
+	is.NoError(store.User().Create(&portainer.User{ID: 1, Username: "admin"}))
+	is.NoError(store.Team().Create(&portainer.Team{ID: 1, Name: "team"}))
+	is.NoError(store.TeamMembership().Create(&portainer.TeamMembership{ID: 1, UserID: 1, TeamID: 1}))
+	is.NoError(store.TeamMembership().Create(&portainer.TeamMembership{ID: 2, UserID: 5, TeamID: 1}))
+	is.NoError(store.EndpointRelation().Create(&portainer.EndpointRelation{EndpointID: 1, EdgeStacks: map[portainer.EdgeStackID]bool{3: true}}))
+	is.NoError(store.Stack().Create(&portainer.Stack{ID: 1, Name: "kept", EndpointID: 1}))
+	orphanProjectPath := filepath.Join(t.TempDir(), "compose", "2")
+	is.NoError(os.MkdirAll(orphanProjectPath, 0755))
+	is.NoError(os.WriteFile(filepath.Join(orphanProjectPath, "docker-compose.yml"), []byte("version: '3'"), 0644))
+	is.NoError(store.Stack().Create(&portainer.Stack{ID: 2, Name: "orphan", EndpointID: 2, ProjectPath: orphanProjectPath}))
+	// the legacy stacks are not associated to an environment
+	is.NoError(store.Stack().Create(&portainer.Stack{ID: 3, Name: "legacy"}))
+	is.NoError(store.ResourceControl().Create(&portainer.ResourceControl{ID: 1, ResourceID: "1_kept", Type: portainer.StackResourceControl}))
+	is.NoError(store.ResourceControl().Create(&portainer.ResourceControl{ID: 2, ResourceID: "1_deleted", Type: portainer.StackResourceControl}))
+	is.NoError(store.ResourceControl().Create(&portainer.ResourceControl{ID: 3, ResourceID: "legacy", Type: portainer.StackResourceControl}))
+
+	report, err := store.CheckIntegrity()
+	is.NoError(err)
+	is.False(report.Repaired)
+
+	issues := map[string][]string{}
+	for _, issue := range report.Issues {
+		issues[issue.Bucket] = append(issues[issue.Bucket], issue.ObjectID)
+	}
+	is.Equal(map[string][]string{
+		"endpoints":          {"1"},
+		"tags":               {"1"},
+		"team_membership":    {"2"},
+		"endpoint_relations": {"1"},
+		"stacks":             {"2"},
+		"resource_control":   {"2"},
+	}, issues)
+
+	endpointService := store.Endpoint()
+
+	report, err = store.RepairIntegrity()
+	is.NoError(err)
+	is.Same(endpointService, store.Endpoint(), "the repair must not reopen the store")
+	is.True(report.Repaired)
+	is.Len(report.Issues, 6)
+	is.FileExists(report.BackupPath)
+
+	endpoint, err := store.Endpoint().Endpoint(1)
+	is.NoError(err)
+	is.Equal([]portainer.TagID{1}, endpoint.TagIDs)
+
+	tag, err := store.Tag().Tag(1)
+	is.NoError(err)
+	is.Equal(map[portainer.EndpointID]bool{1: true}, tag.Endpoints)
+
+	memberships, err := store.TeamMembership().TeamMemberships()
+	is.NoError(err)
+	is.Len(memberships, 1)
+
+	relation, err := store.EndpointRelation().EndpointRelation(1)
+	is.NoError(err)
+	is.Empty(relation.EdgeStacks)
+
+	stacks, err := store.Stack().Stacks()
+	is.NoError(err)
+	is.Len(stacks, 2)
+	is.NoDirExists(orphanProjectPath, "the files of the deleted stack should be removed")
+
+	resourceControls, err := store.ResourceControl().ResourceControls()
+	is.NoError(err)
+	is.Len(resourceControls, 2)
+
+	report, err = store.CheckIntegrity()
+	is.NoError(err)
+	is.Empty(report.Issues)
+}
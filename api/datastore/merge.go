@@ -0,0 +1,301 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices/customtemplate"
+	"github.com/portainer/portainer/api/dataservices/edgegroup"
+	"github.com/portainer/portainer/api/dataservices/edgejob"
+	"github.com/portainer/portainer/api/dataservices/edgestack"
+	"github.com/portainer/portainer/api/dataservices/endpoint"
+	"github.com/portainer/portainer/api/dataservices/endpointgroup"
+	"github.com/portainer/portainer/api/dataservices/endpointrelation"
+	"github.com/portainer/portainer/api/dataservices/helmuserrepository"
+	"github.com/portainer/portainer/api/dataservices/registry"
+	"github.com/portainer/portainer/api/dataservices/resourcecontrol"
+	"github.com/portainer/portainer/api/dataservices/revision"
+	"github.com/portainer/portainer/api/dataservices/role"
+	"github.com/portainer/portainer/api/dataservices/stack"
+	"github.com/portainer/portainer/api/dataservices/tag"
+	"github.com/portainer/portainer/api/dataservices/team"
+	"github.com/portainer/portainer/api/dataservices/teammembership"
+	"github.com/portainer/portainer/api/dataservices/user"
+	"github.com/portainer/portainer/api/dataservices/webhook"
+)
+
+// MergeBuckets imports the objects of the given buckets from an export file into the store. The objects replace
+// the stored objects with the same identifier, the other stored objects are kept. The sequence of each bucket is
+// raised so that the new objects do not reuse the identifiers of the imported objects.
+// The objects of every bucket are written in a single transaction, nothing is imported when a write fails.
+// Returns the identifiers of the imported objects of each bucket.
+func (store *Store) MergeBuckets(filename string, buckets []string) (map[string][]int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var export storeExport
+	err = json.Unmarshal(data, &export)
+	if err != nil {
+		return nil, err
+	}
+
+	// check every bucket first, so that nothing is imported when a bucket is not supported
+	// or when the imported objects would conflict with the stored ones
+	writes := make(map[string][]mergeWrite, len(buckets))
+	for _, bucketName := range buckets {
+		err := store.checkMergeConflicts(&export, bucketName)
+		if err != nil {
+			return nil, err
+		}
+
+		writes[bucketName], err = mergeBucket(&export, bucketName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	currentSequences, err := store.connection.BackupMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	// the sequences are raised before the objects are written, a sequence raised for objects
+	// that are finally not imported only skips some identifiers
+	merged := make(map[string][]int, len(buckets))
+	sequences := make(map[string]interface{}, len(buckets))
+	for _, bucketName := range buckets {
+		ids := make([]int, 0, len(writes[bucketName]))
+		for _, write := range writes[bucketName] {
+			ids = append(ids, write.id)
+		}
+		merged[bucketName] = ids
+
+		sequences[bucketName] = float64(maxSequence(currentSequences[bucketName], export.Metadata[bucketName], ids))
+	}
+
+	err = store.connection.RestoreMetadata(sequences)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update the sequences of the buckets: %w", err)
+	}
+
+	err = store.connection.UpdateTx(func(tx portainer.Transaction) error {
+		for _, bucketName := range buckets {
+			for _, write := range writes[bucketName] {
+				var err error
+				if write.revision != nil {
+					err = revision.UpdateObjectTx(tx, bucketName, store.connection.ConvertToKey(write.id), write.object, write.revision)
+				} else {
+					err = tx.CreateObjectWithId(bucketName, write.id, write.object)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to import the object %d of the %s bucket: %w", write.id, bucketName, err)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// mergeWrite is the write of an imported object, revision points to the revision field of the object
+// when the object has one
+type mergeWrite struct {
+	id       int
+	object   interface{}
+	revision *int
+}
+
+// mergeBucket returns the writes importing the objects of a bucket of the export
+func mergeBucket(export *storeExport, bucketName string) ([]mergeWrite, error) {
+	switch bucketName {
+	case customtemplate.BucketName:
+		return mergeObjects(export.CustomTemplate, func(v *portainer.CustomTemplate) (int, *int) {
+			return int(v.ID), nil
+		}), nil
+	case edgegroup.BucketName:
+		return mergeObjects(export.EdgeGroup, func(v *portainer.EdgeGroup) (int, *int) {
+			return int(v.ID), nil
+		}), nil
+	case edgejob.BucketName:
+		return mergeObjects(export.EdgeJob, func(v *portainer.EdgeJob) (int, *int) {
+			return int(v.ID), nil
+		}), nil
+	case edgestack.BucketName:
+		return mergeObjects(export.EdgeStack, func(v *portainer.EdgeStack) (int, *int) {
+			return int(v.ID), &v.Revision
+		}), nil
+	case endpoint.BucketName:
+		return mergeObjects(export.Endpoint, func(v *portainer.Endpoint) (int, *int) {
+			return int(v.ID), &v.Revision
+		}), nil
+	case endpointgroup.BucketName:
+		return mergeObjects(export.EndpointGroup, func(v *portainer.EndpointGroup) (int, *int) {
+			return int(v.ID), &v.Revision
+		}), nil
+	case endpointrelation.BucketName:
+		return mergeObjects(export.EndpointRelation, func(v *portainer.EndpointRelation) (int, *int) {
+			return int(v.EndpointID), nil
+		}), nil
+	case helmuserrepository.BucketName:
+		return mergeObjects(export.HelmUserRepository, func(v *portainer.HelmUserRepository) (int, *int) {
+			return int(v.ID), nil
+		}), nil
+	case registry.BucketName:
+		return mergeObjects(export.Registry, func(v *portainer.Registry) (int, *int) {
+			return int(v.ID), &v.Revision
+		}), nil
+	case resourcecontrol.BucketName:
+		return mergeObjects(export.ResourceControl, func(v *portainer.ResourceControl) (int, *int) {
+			return int(v.ID), nil
+		}), nil
+	case role.BucketName:
+		return mergeObjects(export.Role, func(v *portainer.Role) (int, *int) {
+			return int(v.ID), nil
+		}), nil
+	case stack.BucketName:
+		return mergeObjects(export.Stack, func(v *portainer.Stack) (int, *int) {
+			return int(v.ID), &v.Revision
+		}), nil
+	case tag.BucketName:
+		return mergeObjects(export.Tag, func(v *portainer.Tag) (int, *int) {
+			return int(v.ID), nil
+		}), nil
+	case teammembership.BucketName:
+		return mergeObjects(export.TeamMembership, func(v *portainer.TeamMembership) (int, *int) {
+			return int(v.ID), nil
+		}), nil
+	case team.BucketName:
+		return mergeObjects(export.Team, func(v *portainer.Team) (int, *int) {
+			return int(v.ID), nil
+		}), nil
+	case user.BucketName:
+		return mergeObjects(export.User, func(v *portainer.User) (int, *int) {
+			v.Username = strings.ToLower(v.Username)
+			return int(v.ID), nil
+		}), nil
+	case webhook.BucketName:
+		return mergeObjects(export.Webhook, func(v *portainer.Webhook) (int, *int) {
+			return int(v.ID), nil
+		}), nil
+	}
+
+	return nil, fmt.Errorf("the %s bucket cannot be imported", bucketName)
+}
+
+// checkMergeConflicts returns an error when importing the objects of a bucket would give the same
+// user name or team name to two different objects
+func (store *Store) checkMergeConflicts(export *storeExport, bucketName string) error {
+	names := map[int]string{}
+	imported := map[int]string{}
+
+	switch bucketName {
+	case user.BucketName:
+		users, err := store.User().Users()
+		if err != nil {
+			return err
+		}
+
+		for _, u := range users {
+			names[int(u.ID)] = u.Username
+		}
+
+		for _, u := range export.User {
+			imported[int(u.ID)] = u.Username
+		}
+	case team.BucketName:
+		teams, err := store.Team().Teams()
+		if err != nil {
+			return err
+		}
+
+		for _, t := range teams {
+			names[int(t.ID)] = t.Name
+		}
+
+		for _, t := range export.Team {
+			imported[int(t.ID)] = t.Name
+		}
+	default:
+		return nil
+	}
+
+	// the imported objects replace the stored objects with the same identifier
+	for id, name := range imported {
+		names[id] = name
+	}
+
+	ids := make([]int, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	owners := make(map[string]int, len(names))
+	conflicts := []string{}
+	for _, id := range ids {
+		name := strings.ToLower(names[id])
+
+		if owner, ok := owners[name]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("%q (%d and %d)", names[id], owner, id))
+			continue
+		}
+
+		owners[name] = id
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("the %s bucket cannot be imported, the following names would be used twice: %s", bucketName, strings.Join(conflicts, ", "))
+	}
+
+	return nil
+}
+
+func mergeObjects[T any](objects []T, prepare func(object *T) (int, *int)) []mergeWrite {
+	writes := make([]mergeWrite, 0, len(objects))
+
+	for i := range objects {
+		id, revision := prepare(&objects[i])
+		writes = append(writes, mergeWrite{id: id, object: &objects[i], revision: revision})
+	}
+
+	return writes
+}
+
+// maxSequence returns the highest of the current sequence, the exported sequence and the imported identifiers
+func maxSequence(current, exported interface{}, ids []int) int {
+	sequence := 0
+
+	for _, value := range []interface{}{current, exported} {
+		switch value := value.(type) {
+		case int:
+			sequence = max(sequence, value)
+		case float64:
+			sequence = max(sequence, int(value))
+		}
+	}
+
+	for _, id := range ids {
+		sequence = max(sequence, id)
+	}
+
+	return sequence
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
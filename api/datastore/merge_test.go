@@ -0,0 +1,110 @@
+package datastore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeBuckets(t *testing.T) {
+	_, store, teardown := MustNewTestStore(t, true, false)
+	defer teardown()
+
+	err := store.Stack().Create(&portainer.Stack{ID: 1, Name: "current", Revision: 3})
+	require.NoError(t, err)
+
+	err = store.Tag().Create(&portainer.Tag{Name: "current"})
+	require.NoError(t, err)
+
+	export := storeExport{
+		Stack: []portainer.Stack{{ID: 1, Name: "restored", Revision: 1}, {ID: 5, Name: "deleted"}},
+		Tag:   []portainer.Tag{{ID: 1, Name: "not restored"}},
+	}
+
+	data, err := json.Marshal(export)
+	require.NoError(t, err)
+
+	exportPath := filepath.Join(t.TempDir(), "export.json")
+	err = os.WriteFile(exportPath, data, 0600)
+	require.NoError(t, err)
+
+	_, err = store.MergeBuckets(exportPath, []string{"stacks", "settings"})
+	assert.Error(t, err, "the settings bucket should not be supported")
+
+	stack, err := store.Stack().Stack(1)
+	require.NoError(t, err)
+	assert.Equal(t, "current", stack.Name, "nothing should be imported when a bucket is not supported")
+
+	merged, err := store.MergeBuckets(exportPath, []string{"stacks"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]int{"stacks": {1, 5}}, merged)
+
+	stacks, err := store.Stack().Stacks()
+	require.NoError(t, err)
+	assert.Len(t, stacks, 2)
+
+	stack, err = store.Stack().Stack(1)
+	require.NoError(t, err)
+	assert.Equal(t, "restored", stack.Name)
+	assert.Equal(t, 4, stack.Revision, "the revision should follow the stored revision")
+
+	tags, err := store.Tag().Tags()
+	require.NoError(t, err)
+	assert.Equal(t, "current", tags[0].Name, "the buckets not selected should not be imported")
+
+	assert.Greater(t, store.Stack().GetNextIdentifier(), 5, "the next identifier should not reuse the imported identifiers")
+}
+
+func TestMergeBuckets_shouldRejectNameConflicts(t *testing.T) {
+	_, store, teardown := MustNewTestStore(t, true, false)
+	defer teardown()
+
+	err := store.User().Create(&portainer.User{Username: "admin"})
+	require.NoError(t, err)
+	err = store.User().Create(&portainer.User{Username: "alice"})
+	require.NoError(t, err)
+	err = store.Team().Create(&portainer.Team{Name: "devs"})
+	require.NoError(t, err)
+
+	writeExport := func(export storeExport) string {
+		data, err := json.Marshal(export)
+		require.NoError(t, err)
+
+		exportPath := filepath.Join(t.TempDir(), "export.json")
+		err = os.WriteFile(exportPath, data, 0600)
+		require.NoError(t, err)
+
+		return exportPath
+	}
+
+	exportPath := writeExport(storeExport{
+		User: []portainer.User{{ID: 5, Username: "Alice"}},
+		Team: []portainer.Team{{ID: 3, Name: "ops"}},
+	})
+
+	_, err = store.MergeBuckets(exportPath, []string{"teams", "users"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"Alice" (2 and 5)`)
+
+	_, err = store.Team().Team(3)
+	assert.True(t, store.IsErrObjectNotFound(err), "nothing should be imported when the names conflict")
+
+	_, err = store.MergeBuckets(writeExport(storeExport{Team: []portainer.Team{{ID: 2, Name: "Devs"}}}), []string{"teams"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"Devs" (1 and 2)`)
+
+	// a stored object keeps its name when it is replaced by the imported object with the same identifier
+	exportPath = writeExport(storeExport{
+		User: []portainer.User{{ID: 2, Username: "bob"}, {ID: 5, Username: "alice"}},
+	})
+
+	merged, err := store.MergeBuckets(exportPath, []string{"users"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]int{"users": {2, 5}}, merged)
+}
@@ -0,0 +1,319 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+
+	portainer "github.com/portainer/portainer/api"
+
+	werrors "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	// MigrationReport lists, for each bucket, the objects changed by the pending migrations
+	MigrationReport struct {
+		FromVersion int                       `json:"FromVersion"`
+		ToVersion   int                       `json:"ToVersion"`
+		Buckets     map[string]*BucketChanges `json:"Buckets"`
+	}
+
+	// BucketChanges lists the identifiers of the objects created, modified and deleted in a bucket
+	BucketChanges struct {
+		Created  []string        `json:"Created,omitempty"`
+		Modified []ObjectChanges `json:"Modified,omitempty"`
+		Deleted  []string        `json:"Deleted,omitempty"`
+	}
+
+	// ObjectChanges lists the values changed in an object
+	ObjectChanges struct {
+		ID      string        `json:"Id"`
+		Changes []ValueChange `json:"Changes"`
+	}
+
+	// ValueChange is a value changed in an object, identified by its JSON path.
+	// Before is not set for a new value and After is not set for a removed value.
+	ValueChange struct {
+		Path   string      `json:"Path"`
+		Before interface{} `json:"Before,omitempty"`
+		After  interface{} `json:"After,omitempty"`
+	}
+)
+
+// buckets holding a single object in the JSON export
+var singleObjectBuckets = map[string]bool{
+	"ssl":           true,
+	"settings":      true,
+	"tunnel_server": true,
+}
+
+// fields identifying the objects of a bucket in the JSON export, in order of preference
+var objectIdentifierFields = []string{"Id", "ID", "EndpointID", "EndpointId"}
+
+// MigrateDryRun runs Init and every pending migration against a copy of the database and reports the changes.
+// The store must not be opened. connection is an unopened connection of the same type as the store connection,
+// pointing to an empty directory where the copy is made.
+func (store *Store) MigrateDryRun(connection portainer.Connection) (*MigrationReport, error) {
+	needsEncryption, err := store.connection.NeedsEncryptionMigration()
+	if err != nil {
+		return nil, err
+	}
+
+	if needsEncryption {
+		// the database is still unencrypted, it is encrypted when the copy is opened
+		store.connection.SetEncrypted(false)
+	}
+
+	databasePath := store.databasePath()
+	if exists, _ := store.fileService.FileExists(databasePath); !exists {
+		return nil, fmt.Errorf("unable to find the database %s", databasePath)
+	}
+
+	// the copy keeps the name of the database file, so that it is opened with the same encryption state
+	err = store.copyDBFile(databasePath, path.Join(connection.GetStorePath(), filepath.Base(databasePath)))
+	if err != nil {
+		return nil, werrors.Wrap(err, "failed to copy the database")
+	}
+
+	copyStore := NewStore(connection.GetStorePath(), store.fileService, connection)
+	_, err = copyStore.Open()
+	if err != nil {
+		return nil, werrors.Wrap(err, "failed to open the database copy")
+	}
+
+	fromVersion, err := copyStore.version()
+	copyStore.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := exportBuckets(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	err = copyStore.migrate(fromVersion)
+	copyStore.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := exportBuckets(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MigrationReport{
+		FromVersion: fromVersion,
+		ToVersion:   portainer.DBVersion,
+		Buckets:     diffBuckets(before, after),
+	}, nil
+}
+
+func (store *Store) migrate(fromVersion int) error {
+	_, err := store.Open()
+	if err != nil {
+		return err
+	}
+
+	err = store.Init()
+	if err != nil {
+		return werrors.Wrap(err, "failed to initialize the database copy")
+	}
+
+	if fromVersion == portainer.DBVersion {
+		return nil
+	}
+
+	return store.MigrateData()
+}
+
+// exportBuckets exports the database and indexes the objects of each bucket by identifier
+func exportBuckets(connection portainer.Connection) (map[string]map[string]interface{}, error) {
+	exportFile, err := os.CreateTemp("", "portainer-export-*.json")
+	if err != nil {
+		return nil, err
+	}
+	exportFile.Close()
+	defer os.Remove(exportFile.Name())
+
+	err = connection.ExportRaw(exportFile.Name())
+	if err != nil {
+		return nil, werrors.Wrap(err, "failed to export the database copy")
+	}
+
+	data, err := os.ReadFile(exportFile.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var export map[string]interface{}
+	err = json.Unmarshal(data, &export)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[string]map[string]interface{})
+	for bucketName, content := range export {
+		if bucketName == "__metadata" {
+			continue
+		}
+
+		objects := make(map[string]interface{})
+
+		switch content := content.(type) {
+		case []interface{}:
+			for i, object := range content {
+				objects[objectIdentifier(object, i)] = object
+			}
+		case map[string]interface{}:
+			if singleObjectBuckets[bucketName] {
+				objects[bucketName] = content
+				break
+			}
+
+			// the version bucket is exported as a map of keys to values
+			for key, value := range content {
+				objects[key] = value
+			}
+		case nil:
+		default:
+			objects[bucketName] = content
+		}
+
+		buckets[bucketName] = objects
+	}
+
+	return buckets, nil
+}
+
+func objectIdentifier(object interface{}, index int) string {
+	if fields, ok := object.(map[string]interface{}); ok {
+		for _, field := range objectIdentifierFields {
+			if id, ok := fields[field]; ok {
+				return fmt.Sprint(id)
+			}
+		}
+	}
+
+	return "#" + strconv.Itoa(index)
+}
+
+func diffBuckets(before, after map[string]map[string]interface{}) map[string]*BucketChanges {
+	report := make(map[string]*BucketChanges)
+
+	for _, bucketName := range sortedKeys(before, after) {
+		changes := &BucketChanges{}
+		beforeObjects := before[bucketName]
+		afterObjects := after[bucketName]
+
+		for _, id := range sortedKeys(beforeObjects, afterObjects) {
+			beforeObject, existedBefore := beforeObjects[id]
+			afterObject, existsAfter := afterObjects[id]
+
+			switch {
+			case !existedBefore:
+				changes.Created = append(changes.Created, id)
+			case !existsAfter:
+				changes.Deleted = append(changes.Deleted, id)
+			default:
+				valueChanges := diffValues("", beforeObject, afterObject, nil)
+				if len(valueChanges) > 0 {
+					changes.Modified = append(changes.Modified, ObjectChanges{ID: id, Changes: valueChanges})
+				}
+			}
+		}
+
+		if len(changes.Created) > 0 || len(changes.Modified) > 0 || len(changes.Deleted) > 0 {
+			report[bucketName] = changes
+		}
+	}
+
+	return report
+}
+
+// diffValues compares two JSON values, objects are compared field by field
+// and arrays are compared item by item when they have the same length
+func diffValues(valuePath string, before, after interface{}, changes []ValueChange) []ValueChange {
+	beforeFields, beforeIsObject := before.(map[string]interface{})
+	afterFields, afterIsObject := after.(map[string]interface{})
+	if beforeIsObject && afterIsObject {
+		for _, field := range sortedKeys(beforeFields, afterFields) {
+			beforeValue, existedBefore := beforeFields[field]
+			afterValue, existsAfter := afterFields[field]
+
+			fieldPath := joinValuePath(valuePath, field)
+			switch {
+			case !existedBefore:
+				changes = append(changes, ValueChange{Path: fieldPath, After: afterValue})
+			case !existsAfter:
+				changes = append(changes, ValueChange{Path: fieldPath, Before: beforeValue})
+			default:
+				changes = diffValues(fieldPath, beforeValue, afterValue, changes)
+			}
+		}
+
+		return changes
+	}
+
+	beforeItems, beforeIsArray := before.([]interface{})
+	afterItems, afterIsArray := after.([]interface{})
+	if beforeIsArray && afterIsArray && len(beforeItems) == len(afterItems) {
+		for i := range beforeItems {
+			changes = diffValues(fmt.Sprintf("%s[%d]", valuePath, i), beforeItems[i], afterItems[i], changes)
+		}
+
+		return changes
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		changes = append(changes, ValueChange{Path: valuePath, Before: before, After: after})
+	}
+
+	return changes
+}
+
+func joinValuePath(valuePath, field string) string {
+	if valuePath == "" {
+		return field
+	}
+
+	return valuePath + "." + field
+}
+
+func sortedKeys[T any](maps ...map[string]T) []string {
+	keys := []string{}
+	seen := make(map[string]bool)
+
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// LogMigrationReport logs a summary of the changes of each bucket
+func LogMigrationReport(report *MigrationReport) {
+	for _, bucketName := range sortedKeys(report.Buckets) {
+		changes := report.Buckets[bucketName]
+
+		log.Info().
+			Str("bucket", bucketName).
+			Int("created", len(changes.Created)).
+			Int("modified", len(changes.Modified)).
+			Int("deleted", len(changes.Deleted)).
+			Msg("migration dry run")
+	}
+}
@@ -0,0 +1,75 @@
+package datastore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateDryRun(t *testing.T) {
+	is := assert.New(t)
+
+	srcJSON, err := os.ReadFile(filepath.Join("test_data", "input_24.json"))
+	is.NoError(err)
+
+	_, store, _ := MustNewTestStore(t, true, false)
+	is.NoError(importJSON(t, bytes.NewReader(srcJSON), store))
+	is.NoError(store.Close())
+
+	storePath := store.connection.GetStorePath()
+	connection, err := database.NewDatabase("boltdb", storePath, nil)
+	is.NoError(err)
+	store = NewStore(storePath, store.fileService, connection)
+
+	copyConnection, err := database.NewDatabase("boltdb", t.TempDir(), nil)
+	is.NoError(err)
+
+	report, err := store.MigrateDryRun(copyConnection)
+	is.NoError(err)
+
+	is.Equal(24, report.FromVersion)
+	is.Equal(portainer.DBVersion, report.ToVersion)
+	is.Contains(report.Buckets, "version")
+	is.Contains(report.Buckets, "settings")
+	is.NotContains(report.Buckets, "__metadata")
+
+	settings := report.Buckets["settings"]
+	is.Len(settings.Modified, 1)
+	is.NotEmpty(settings.Modified[0].Changes)
+
+	_, err = store.Open()
+	is.NoError(err)
+	defer store.Close()
+
+	version, err := store.version()
+	is.NoError(err)
+	is.Equal(24, version, "the migrations should not be applied to the real store")
+}
+
+func Test_diffValues(t *testing.T) {
+	before := map[string]interface{}{
+		"Name":    "local",
+		"Removed": true,
+		"TagIds":  []interface{}{1.0, 2.0},
+		"Nested":  map[string]interface{}{"Value": 1.0},
+	}
+	after := map[string]interface{}{
+		"Name":   "local",
+		"Added":  "value",
+		"TagIds": []interface{}{1.0, 3.0},
+		"Nested": map[string]interface{}{"Value": 2.0},
+	}
+
+	assert.Equal(t, []ValueChange{
+		{Path: "Added", After: "value"},
+		{Path: "Nested.Value", Before: 1.0, After: 2.0},
+		{Path: "Removed", Before: true},
+		{Path: "TagIds[1]", Before: 2.0, After: 3.0},
+	}, diffValues("", before, after, nil))
+}
@@ -9,6 +9,9 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/dataservices/apikeyrepository"
+	"github.com/portainer/portainer/api/dataservices/auditlog"
+	"github.com/portainer/portainer/api/dataservices/backuprun"
+	"github.com/portainer/portainer/api/dataservices/backupschedule"
 	"github.com/portainer/portainer/api/dataservices/customtemplate"
 	"github.com/portainer/portainer/api/dataservices/dockerhub"
 	"github.com/portainer/portainer/api/dataservices/edgegroup"
@@ -21,11 +24,14 @@ import (
 	"github.com/portainer/portainer/api/dataservices/extension"
 	"github.com/portainer/portainer/api/dataservices/fdoprofile"
 	"github.com/portainer/portainer/api/dataservices/helmuserrepository"
+	"github.com/portainer/portainer/api/dataservices/kubernetessnapshotraw"
 	"github.com/portainer/portainer/api/dataservices/registry"
 	"github.com/portainer/portainer/api/dataservices/resourcecontrol"
 	"github.com/portainer/portainer/api/dataservices/role"
 	"github.com/portainer/portainer/api/dataservices/schedule"
+	"github.com/portainer/portainer/api/dataservices/session"
 	"github.com/portainer/portainer/api/dataservices/settings"
+	"github.com/portainer/portainer/api/dataservices/snapshothistory"
 	"github.com/portainer/portainer/api/dataservices/ssl"
 	"github.com/portainer/portainer/api/dataservices/stack"
 	"github.com/portainer/portainer/api/dataservices/tag"
@@ -44,34 +50,40 @@ import (
 type Store struct {
 	connection portainer.Connection
 
-	fileService               portainer.FileService
-	CustomTemplateService     *customtemplate.Service
-	DockerHubService          *dockerhub.Service
-	EdgeGroupService          *edgegroup.Service
-	EdgeJobService            *edgejob.Service
-	EdgeUpdateScheduleService *edgeupdateschedule.Service
-	EdgeStackService          *edgestack.Service
-	EndpointGroupService      *endpointgroup.Service
-	EndpointService           *endpoint.Service
-	EndpointRelationService   *endpointrelation.Service
-	ExtensionService          *extension.Service
-	FDOProfilesService        *fdoprofile.Service
-	HelmUserRepositoryService *helmuserrepository.Service
-	RegistryService           *registry.Service
-	ResourceControlService    *resourcecontrol.Service
-	RoleService               *role.Service
-	APIKeyRepositoryService   *apikeyrepository.Service
-	ScheduleService           *schedule.Service
-	SettingsService           *settings.Service
-	SSLSettingsService        *ssl.Service
-	StackService              *stack.Service
-	TagService                *tag.Service
-	TeamMembershipService     *teammembership.Service
-	TeamService               *team.Service
-	TunnelServerService       *tunnelserver.Service
-	UserService               *user.Service
-	VersionService            *version.Service
-	WebhookService            *webhook.Service
+	fileService                  portainer.FileService
+	AuditLogService              *auditlog.Service
+	BackupRunService             *backuprun.Service
+	BackupScheduleService        *backupschedule.Service
+	CustomTemplateService        *customtemplate.Service
+	DockerHubService             *dockerhub.Service
+	EdgeGroupService             *edgegroup.Service
+	EdgeJobService               *edgejob.Service
+	EdgeUpdateScheduleService    *edgeupdateschedule.Service
+	EdgeStackService             *edgestack.Service
+	EndpointGroupService         *endpointgroup.Service
+	EndpointService              *endpoint.Service
+	EndpointRelationService      *endpointrelation.Service
+	ExtensionService             *extension.Service
+	FDOProfilesService           *fdoprofile.Service
+	HelmUserRepositoryService    *helmuserrepository.Service
+	KubernetesSnapshotRawService *kubernetessnapshotraw.Service
+	RegistryService              *registry.Service
+	ResourceControlService       *resourcecontrol.Service
+	RoleService                  *role.Service
+	APIKeyRepositoryService      *apikeyrepository.Service
+	ScheduleService              *schedule.Service
+	SessionService               *session.Service
+	SettingsService              *settings.Service
+	SnapshotHistoryService       *snapshothistory.Service
+	SSLSettingsService           *ssl.Service
+	StackService                 *stack.Service
+	TagService                   *tag.Service
+	TeamMembershipService        *teammembership.Service
+	TeamService                  *team.Service
+	TunnelServerService          *tunnelserver.Service
+	UserService                  *user.Service
+	VersionService               *version.Service
+	WebhookService               *webhook.Service
 }
 
 func (store *Store) initServices() error {
@@ -81,6 +93,24 @@ func (store *Store) initServices() error {
 	}
 	store.RoleService = authorizationsetService
 
+	auditLogService, err := auditlog.NewService(store.connection)
+	if err != nil {
+		return err
+	}
+	store.AuditLogService = auditLogService
+
+	backupRunService, err := backuprun.NewService(store.connection)
+	if err != nil {
+		return err
+	}
+	store.BackupRunService = backupRunService
+
+	backupScheduleService, err := backupschedule.NewService(store.connection)
+	if err != nil {
+		return err
+	}
+	store.BackupScheduleService = backupScheduleService
+
 	customTemplateService, err := customtemplate.NewService(store.connection)
 	if err != nil {
 		return err
@@ -153,6 +183,12 @@ func (store *Store) initServices() error {
 	}
 	store.HelmUserRepositoryService = helmUserRepositoryService
 
+	kubernetesSnapshotRawService, err := kubernetessnapshotraw.NewService(store.connection)
+	if err != nil {
+		return err
+	}
+	store.KubernetesSnapshotRawService = kubernetesSnapshotRawService
+
 	registryService, err := registry.NewService(store.connection)
 	if err != nil {
 		return err
@@ -171,6 +207,18 @@ func (store *Store) initServices() error {
 	}
 	store.SettingsService = settingsService
 
+	sessionService, err := session.NewService(store.connection)
+	if err != nil {
+		return err
+	}
+	store.SessionService = sessionService
+
+	snapshotHistoryService, err := snapshothistory.NewService(store.connection)
+	if err != nil {
+		return err
+	}
+	store.SnapshotHistoryService = snapshotHistoryService
+
 	sslSettingsService, err := ssl.NewService(store.connection)
 	if err != nil {
 		return err
@@ -240,6 +288,21 @@ func (store *Store) initServices() error {
 	return nil
 }
 
+// AuditLog gives access to the AuditLog data management layer
+func (store *Store) AuditLog() dataservices.AuditLogService {
+	return store.AuditLogService
+}
+
+// BackupRun gives access to the BackupRun data management layer
+func (store *Store) BackupRun() dataservices.BackupRunService {
+	return store.BackupRunService
+}
+
+// BackupSchedule gives access to the BackupSchedule data management layer
+func (store *Store) BackupSchedule() dataservices.BackupScheduleService {
+	return store.BackupScheduleService
+}
+
 // CustomTemplate gives access to the CustomTemplate data management layer
 func (store *Store) CustomTemplate() dataservices.CustomTemplateService {
 	return store.CustomTemplateService
@@ -290,6 +353,11 @@ func (store *Store) HelmUserRepository() dataservices.HelmUserRepositoryService
 	return store.HelmUserRepositoryService
 }
 
+// KubernetesSnapshotRaw gives access to the raw resources of the Kubernetes snapshots
+func (store *Store) KubernetesSnapshotRaw() dataservices.KubernetesSnapshotRawService {
+	return store.KubernetesSnapshotRawService
+}
+
 // Registry gives access to the Registry data management layer
 func (store *Store) Registry() dataservices.RegistryService {
 	return store.RegistryService
@@ -310,11 +378,21 @@ func (store *Store) APIKeyRepository() dataservices.APIKeyRepository {
 	return store.APIKeyRepositoryService
 }
 
+// Session gives access to the Session data management layer
+func (store *Store) Session() dataservices.SessionService {
+	return store.SessionService
+}
+
 // Settings gives access to the Settings data management layer
 func (store *Store) Settings() dataservices.SettingsService {
 	return store.SettingsService
 }
 
+// SnapshotHistory gives access to the SnapshotHistory data management layer
+func (store *Store) SnapshotHistory() dataservices.SnapshotHistoryService {
+	return store.SnapshotHistoryService
+}
+
 // SSLSettings gives access to the SSL Settings data management layer
 func (store *Store) SSLSettings() dataservices.SSLSettingsService {
 	return store.SSLSettingsService
@@ -0,0 +1,93 @@
+// Package etag implements the optimistic concurrency of the resource updates.
+// The revision of a resource is returned as its ETag header, and an update carrying
+// an If-Match header that does not match the current revision is rejected.
+package etag
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	httperror "github.com/portainer/libhttp/error"
+)
+
+var errRevisionMismatch = errors.New("the resource was modified since it was retrieved")
+
+// Set sets the ETag header of the response to the revision of the resource
+func Set(w http.ResponseWriter, revision int) {
+	w.Header().Set("ETag", format(revision))
+}
+
+// CheckIfMatch returns a 412 error when the request carries an If-Match header
+// that does not match the revision of the resource
+func CheckIfMatch(r *http.Request, revision int) *httperror.HandlerError {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || matches(ifMatch, revision) {
+		return nil
+	}
+
+	return httperror.NewError(http.StatusPreconditionFailed, "The resource was modified since it was retrieved, retrieve it again before updating it", errRevisionMismatch)
+}
+
+func format(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// matches uses the strong comparison required for If-Match, weak tags never match
+func matches(ifMatch string, revision int) bool {
+	current := format(revision)
+
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+
+	return false
+}
+
+type lock struct {
+	sync.Mutex
+	holders int
+}
+
+var (
+	mu    sync.Mutex
+	locks = make(map[string]*lock)
+)
+
+// Lock serializes the updates of a resource, so that its revision cannot change between
+// the If-Match check and the update. The returned function releases the lock, it can be
+// called again so that the lock can be released before a deferred call.
+func Lock(resource string, id int) func() {
+	key := resource + "/" + strconv.Itoa(id)
+
+	mu.Lock()
+	l, ok := locks[key]
+	if !ok {
+		l = &lock{}
+		locks[key] = l
+	}
+	l.holders++
+	mu.Unlock()
+
+	l.Lock()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			l.Unlock()
+
+			mu.Lock()
+			l.holders--
+			if l.holders == 0 {
+				delete(locks, key)
+			}
+			mu.Unlock()
+		})
+	}
+}
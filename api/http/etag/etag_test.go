@@ -0,0 +1,80 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CheckIfMatch(t *testing.T) {
+	tests := []struct {
+		ifMatch    string
+		revision   int
+		shouldFail bool
+	}{
+		{ifMatch: "", revision: 3},
+		{ifMatch: `"3"`, revision: 3},
+		{ifMatch: `"1", "3"`, revision: 3},
+		{ifMatch: "*", revision: 3},
+		{ifMatch: `"2"`, revision: 3, shouldFail: true},
+		{ifMatch: `W/"3"`, revision: 3, shouldFail: true},
+		{ifMatch: "3", revision: 3, shouldFail: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ifMatch, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", nil)
+			if test.ifMatch != "" {
+				r.Header.Set("If-Match", test.ifMatch)
+			}
+
+			err := CheckIfMatch(r, test.revision)
+			if !test.shouldFail {
+				assert.Nil(t, err)
+				return
+			}
+
+			if assert.NotNil(t, err) {
+				assert.Equal(t, http.StatusPreconditionFailed, err.StatusCode)
+			}
+		})
+	}
+}
+
+func Test_Set(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Set(rr, 7)
+
+	assert.Equal(t, `"7"`, rr.Header().Get("ETag"))
+}
+
+func Test_Lock(t *testing.T) {
+	is := assert.New(t)
+
+	revision := 0
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unlock := Lock("stacks", 1)
+			defer unlock()
+
+			current := revision
+			revision = current + 1
+		}()
+	}
+	wg.Wait()
+
+	is.Equal(50, revision)
+	is.Empty(locks)
+
+	unlock := Lock("stacks", 2)
+	unlock()
+	unlock()
+	is.Empty(locks, "the lock can be released twice")
+}
@@ -0,0 +1,57 @@
+package auditlogs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	httperror "github.com/portainer/libhttp/error"
+
+	"github.com/rs/zerolog/log"
+)
+
+// @id AuditLogExport
+// @summary Export audit logs
+// @description Export the operations recorded in the audit log as JSON Lines, most recent first.
+// @description Accepts the filters of the audit log list.
+// @description **Access policy**: administrator
+// @tags audit
+// @security ApiKeyAuth
+// @security jwt
+// @produce application/x-ndjson
+// @param userId query int false "Only export the operations of this user"
+// @param username query string false "Only export the operations of the user with this username"
+// @param apiKeyId query int false "Only export the operations authenticated with this API key"
+// @param endpointId query int false "Only export the operations on this environment(endpoint)"
+// @param resourceType query string false "Only export the operations on this type of resource"
+// @param resourceId query string false "Only export the operations on this resource"
+// @param operation query string false "Only export this operation"
+// @param outcome query string false "Only export the operations with this outcome" Enums(success, failure, denied)
+// @param from query int false "Only export the operations performed since this unix timestamp"
+// @param to query int false "Only export the operations performed until this unix timestamp"
+// @success 200 "Success"
+// @failure 500 "Server error"
+// @router /audit/export [get]
+func (handler *Handler) auditLogExport(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	filter := parseAuditLogFilter(r)
+
+	auditLogs, err := handler.DataStore.AuditLog().AuditLogs(filter.match)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve audit logs from the database", err)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=portainer-audit_%s.jsonl", time.Now().Format("2006-01-02_15-04-05")))
+
+	encoder := json.NewEncoder(w)
+	for i := range auditLogs {
+		err := encoder.Encode(&auditLogs[i])
+		if err != nil {
+			log.Warn().Err(err).Msg("unable to write the audit log export")
+			return nil
+		}
+	}
+
+	return nil
+}
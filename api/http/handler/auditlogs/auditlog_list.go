@@ -0,0 +1,72 @@
+package auditlogs
+
+import (
+	"net/http"
+	"strconv"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+)
+
+// @id AuditLogList
+// @summary List audit logs
+// @description List the operations recorded in the audit log, most recent first.
+// @description **Access policy**: administrator
+// @tags audit
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param userId query int false "Only list the operations of this user"
+// @param username query string false "Only list the operations of the user with this username"
+// @param apiKeyId query int false "Only list the operations authenticated with this API key"
+// @param endpointId query int false "Only list the operations on this environment(endpoint)"
+// @param resourceType query string false "Only list the operations on this type of resource"
+// @param resourceId query string false "Only list the operations on this resource"
+// @param operation query string false "Only list this operation"
+// @param outcome query string false "Only list the operations with this outcome" Enums(success, failure, denied)
+// @param from query int false "Only list the operations performed since this unix timestamp"
+// @param to query int false "Only list the operations performed until this unix timestamp"
+// @param start query int false "Start searching from"
+// @param limit query int false "Limit results to this value"
+// @success 200 {array} portainer.AuditLog "Success"
+// @failure 500 "Server error"
+// @router /audit [get]
+func (handler *Handler) auditLogList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	start, _ := request.RetrieveNumericQueryParameter(r, "start", true)
+	if start != 0 {
+		start--
+	}
+
+	limit, _ := request.RetrieveNumericQueryParameter(r, "limit", true)
+
+	filter := parseAuditLogFilter(r)
+
+	auditLogs, err := handler.DataStore.AuditLog().AuditLogs(filter.match)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve audit logs from the database", err)
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(auditLogs)))
+	return response.JSON(w, paginateAuditLogs(auditLogs, start, limit))
+}
+
+func paginateAuditLogs(auditLogs []portainer.AuditLog, start, limit int) []portainer.AuditLog {
+	if limit == 0 {
+		return auditLogs
+	}
+
+	auditLogCount := len(auditLogs)
+
+	if start > auditLogCount {
+		start = auditLogCount
+	}
+
+	end := start + limit
+	if end > auditLogCount {
+		end = auditLogCount
+	}
+
+	return auditLogs[start:end]
+}
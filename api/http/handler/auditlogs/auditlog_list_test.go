@@ -0,0 +1,108 @@
+package auditlogs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/jwt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_auditLogList(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	adminUser := &portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}
+	err := store.User().Create(adminUser)
+	require.NoError(t, err)
+
+	auditLogs := []portainer.AuditLog{
+		{Timestamp: 100, UserID: 1, Username: "admin", EndpointID: 1, ResourceType: "containers", Operation: "start", Outcome: portainer.AuditLogOutcomeSuccess},
+		{Timestamp: 200, UserID: 2, Username: "bob", EndpointID: 1, ResourceType: "containers", Operation: "delete", Outcome: portainer.AuditLogOutcomeDenied},
+		{Timestamp: 300, UserID: 2, Username: "bob", ResourceType: "stacks", Operation: "create", Outcome: portainer.AuditLogOutcomeSuccess},
+	}
+	for i := range auditLogs {
+		err := store.AuditLog().Create(&auditLogs[i])
+		require.NoError(t, err)
+	}
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+
+	h := NewHandler(security.NewRequestBouncer(store, jwtService, apiKeyService, nil))
+	h.DataStore = store
+
+	adminJWT, _ := jwtService.GenerateToken(&portainer.TokenData{ID: adminUser.ID, Username: adminUser.Username, Role: adminUser.Role})
+
+	list := func(query string) ([]portainer.AuditLog, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, "/audit?"+query, nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", adminJWT))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		var result []portainer.AuditLog
+		json.NewDecoder(rr.Body).Decode(&result)
+
+		return result, rr
+	}
+
+	t.Run("lists the audit logs most recent first", func(t *testing.T) {
+		result, rr := list("")
+		is.Equal(http.StatusOK, rr.Code)
+		is.Len(result, 3)
+		is.Equal(int64(300), result[0].Timestamp)
+		is.Equal("3", rr.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("filters the audit logs", func(t *testing.T) {
+		result, _ := list("username=bob&endpointId=1")
+		is.Len(result, 1)
+		is.Equal("delete", result[0].Operation)
+
+		result, _ = list("outcome=success&from=150")
+		is.Len(result, 1)
+		is.Equal("stacks", result[0].ResourceType)
+	})
+
+	t.Run("paginates the audit logs", func(t *testing.T) {
+		result, rr := list("resourceType=containers&start=2&limit=1")
+		is.Len(result, 1)
+		is.Equal(int64(100), result[0].Timestamp)
+		is.Equal("2", rr.Header().Get("X-Total-Count"))
+	})
+
+	t.Run("exports the audit logs as JSON lines", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/audit/export?userId=2", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", adminJWT))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		is.Equal(http.StatusOK, rr.Code)
+		is.Equal("application/x-ndjson", rr.Header().Get("Content-Type"))
+
+		var lines []portainer.AuditLog
+		scanner := bufio.NewScanner(rr.Body)
+		for scanner.Scan() {
+			var auditLog portainer.AuditLog
+			is.NoError(json.Unmarshal(scanner.Bytes(), &auditLog))
+			lines = append(lines, auditLog)
+		}
+
+		is.Len(lines, 2)
+		is.Equal("bob", lines[0].Username)
+	})
+}
@@ -0,0 +1,53 @@
+package auditlogs
+
+import (
+	"net/http"
+
+	"github.com/portainer/libhttp/request"
+	portainer "github.com/portainer/portainer/api"
+)
+
+// auditLogFilter represents the query parameters used to filter the audit logs
+type auditLogFilter struct {
+	userID       int
+	username     string
+	apiKeyID     int
+	endpointID   int
+	resourceType string
+	resourceID   string
+	operation    string
+	outcome      string
+	from         int
+	to           int
+}
+
+func parseAuditLogFilter(r *http.Request) auditLogFilter {
+	filter := auditLogFilter{}
+
+	filter.userID, _ = request.RetrieveNumericQueryParameter(r, "userId", true)
+	filter.username, _ = request.RetrieveQueryParameter(r, "username", true)
+	filter.apiKeyID, _ = request.RetrieveNumericQueryParameter(r, "apiKeyId", true)
+	filter.endpointID, _ = request.RetrieveNumericQueryParameter(r, "endpointId", true)
+	filter.resourceType, _ = request.RetrieveQueryParameter(r, "resourceType", true)
+	filter.resourceID, _ = request.RetrieveQueryParameter(r, "resourceId", true)
+	filter.operation, _ = request.RetrieveQueryParameter(r, "operation", true)
+	filter.outcome, _ = request.RetrieveQueryParameter(r, "outcome", true)
+	filter.from, _ = request.RetrieveNumericQueryParameter(r, "from", true)
+	filter.to, _ = request.RetrieveNumericQueryParameter(r, "to", true)
+
+	return filter
+}
+
+// match returns true when the audit log matches every filter that is set
+func (filter auditLogFilter) match(auditLog *portainer.AuditLog) bool {
+	return (filter.userID == 0 || auditLog.UserID == portainer.UserID(filter.userID)) &&
+		(filter.username == "" || auditLog.Username == filter.username) &&
+		(filter.apiKeyID == 0 || auditLog.APIKeyID == portainer.APIKeyID(filter.apiKeyID)) &&
+		(filter.endpointID == 0 || auditLog.EndpointID == portainer.EndpointID(filter.endpointID)) &&
+		(filter.resourceType == "" || auditLog.ResourceType == filter.resourceType) &&
+		(filter.resourceID == "" || auditLog.ResourceID == filter.resourceID) &&
+		(filter.operation == "" || auditLog.Operation == filter.operation) &&
+		(filter.outcome == "" || string(auditLog.Outcome) == filter.outcome) &&
+		(filter.from == 0 || auditLog.Timestamp >= int64(filter.from)) &&
+		(filter.to == 0 || auditLog.Timestamp <= int64(filter.to))
+}
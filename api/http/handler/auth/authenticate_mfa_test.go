@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/crypto"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/jwt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_authenticateMFA_shouldEnrolAndAuthenticate(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	settings.MFARequirement = portainer.MFARequiredForAdministrators
+	require.NoError(t, store.Settings().UpdateSettings(settings))
+
+	cryptoService := &crypto.Service{}
+	password, err := cryptoService.Hash("password")
+	require.NoError(t, err)
+
+	user := &portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole, Password: password}
+	require.NoError(t, store.User().Create(user))
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+
+	h := NewHandler(bouncer, security.NewRateLimiter(100, 1*time.Second, 1*time.Hour), security.NewPasswordStrengthChecker(store.Settings()))
+	h.DataStore = store
+	h.CryptoService = cryptoService
+	h.JWTService = jwtService
+
+	post := func(path, body string, result interface{}) int {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code == http.StatusOK && result != nil {
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(result))
+		}
+
+		return rr.Code
+	}
+
+	login := func() authenticateResponse {
+		var resp authenticateResponse
+		require.Equal(t, http.StatusOK, post("/auth", `{"Username":"admin","Password":"password"}`, &resp))
+		is.Empty(resp.JWT)
+		is.True(resp.MFARequired)
+		is.NotEmpty(resp.PreAuthToken)
+
+		_, err := jwtService.ParseAndVerifyToken(resp.PreAuthToken)
+		is.Error(err, "the pre-authentication token must not grant access to the API")
+
+		return resp
+	}
+
+	t.Run("mandatory enrolment", func(t *testing.T) {
+		resp := login()
+		is.True(resp.MFAEnrolmentRequired)
+
+		var enrolment struct{ Secret string }
+		is.Equal(http.StatusOK, post("/auth/mfa/enrol", fmt.Sprintf(`{"PreAuthToken":%q}`, resp.PreAuthToken), &enrolment))
+		is.NotEmpty(enrolment.Secret)
+
+		is.Equal(http.StatusUnprocessableEntity, post("/auth/mfa", fmt.Sprintf(`{"PreAuthToken":%q,"Code":"000000"}`, resp.PreAuthToken), nil))
+
+		code, err := crypto.TOTPCode(enrolment.Secret, time.Now())
+		require.NoError(t, err)
+
+		var mfaResp mfaAuthenticateResponse
+		is.Equal(http.StatusOK, post("/auth/mfa", fmt.Sprintf(`{"PreAuthToken":%q,"Code":%q}`, resp.PreAuthToken, code), &mfaResp))
+		is.NotEmpty(mfaResp.RecoveryCodes)
+
+		tokenData, err := jwtService.ParseAndVerifyToken(mfaResp.JWT)
+		require.NoError(t, err)
+		is.Equal(user.ID, tokenData.ID)
+
+		user, err := store.User().User(user.ID)
+		require.NoError(t, err)
+		is.True(user.MFA.Enabled)
+
+		t.Run("recovery code", func(t *testing.T) {
+			resp := login()
+			is.False(resp.MFAEnrolmentRequired)
+
+			is.Equal(http.StatusConflict, post("/auth/mfa/enrol", fmt.Sprintf(`{"PreAuthToken":%q}`, resp.PreAuthToken), nil))
+
+			body := fmt.Sprintf(`{"PreAuthToken":%q,"RecoveryCode":%q}`, resp.PreAuthToken, mfaResp.RecoveryCodes[0])
+			is.Equal(http.StatusOK, post("/auth/mfa", body, nil))
+			is.Equal(http.StatusUnprocessableEntity, post("/auth/mfa", body, nil), "a recovery code must be used once")
+		})
+
+		t.Run("concurrent code replay", func(t *testing.T) {
+			// the code of the enrolment is consumed, the code of the next period is still valid
+			code, err := crypto.TOTPCode(enrolment.Secret, time.Now().Add(30*time.Second))
+			require.NoError(t, err)
+
+			bodies := make([]string, 5)
+			for i := range bodies {
+				bodies[i] = fmt.Sprintf(`{"PreAuthToken":%q,"Code":%q}`, login().PreAuthToken, code)
+			}
+
+			codes := make(chan int, len(bodies))
+			var wg sync.WaitGroup
+			for _, body := range bodies {
+				wg.Add(1)
+				go func(body string) {
+					defer wg.Done()
+					codes <- post("/auth/mfa", body, nil)
+				}(body)
+			}
+			wg.Wait()
+			close(codes)
+
+			accepted := 0
+			for code := range codes {
+				if code == http.StatusOK {
+					accepted++
+				}
+			}
+			is.Equal(1, accepted, "a code must be accepted once")
+		})
+	})
+
+	t.Run("invalid pre-authentication token", func(t *testing.T) {
+		token, err := jwtService.GenerateToken(&portainer.TokenData{ID: user.ID, Username: user.Username, Role: user.Role})
+		require.NoError(t, err)
+
+		is.Equal(http.StatusUnprocessableEntity, post("/auth/mfa", fmt.Sprintf(`{"PreAuthToken":%q,"Code":"000000"}`, token), nil))
+	})
+}
@@ -7,15 +7,25 @@ import (
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/audit"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// oauthLoginCookie is the cookie keeping the OpenID Connect login started by a browser
+	oauthLoginCookie = "portainer_oauth_login"
+	// oauthLoginCookiePath limits the login cookie to the OAuth endpoints
+	oauthLoginCookiePath = "/api/auth/oauth"
+)
+
 type oauthPayload struct {
 	// OAuth code returned from OAuth Provided
 	Code string
+	// OAuth state returned from OAuth Provided, required by OpenID Connect providers
+	State string
 }
 
 func (payload *oauthPayload) Validate(r *http.Request) error {
@@ -25,21 +35,21 @@ func (payload *oauthPayload) Validate(r *http.Request) error {
 	return nil
 }
 
-func (handler *Handler) authenticateOAuth(code string, settings *portainer.OAuthSettings) (string, error) {
+func (handler *Handler) authenticateOAuth(code, state, login string, settings *portainer.OAuthSettings) (*portainer.OAuthInfo, error) {
 	if code == "" {
-		return "", errors.New("Invalid OAuth authorization code")
+		return nil, errors.New("Invalid OAuth authorization code")
 	}
 
 	if settings == nil {
-		return "", errors.New("Invalid OAuth configuration")
+		return nil, errors.New("Invalid OAuth configuration")
 	}
 
-	username, err := handler.OAuthService.Authenticate(code, settings)
+	info, err := handler.OAuthService.Authenticate(code, state, login, settings)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return username, nil
+	return info, nil
 }
 
 // @id ValidateOAuth
@@ -61,6 +71,8 @@ func (handler *Handler) validateOAuth(w http.ResponseWriter, r *http.Request) *h
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	audit.SetAuthMethod(r, portainer.AuditLogAuthMethodOAuth)
+
 	settings, err := handler.DataStore.Settings().Settings()
 	if err != nil {
 		return httperror.InternalServerError("Unable to retrieve settings from the database", err)
@@ -70,47 +82,113 @@ func (handler *Handler) validateOAuth(w http.ResponseWriter, r *http.Request) *h
 		return httperror.Forbidden("OAuth authentication is not enabled", errors.New("OAuth authentication is not enabled"))
 	}
 
-	username, err := handler.authenticateOAuth(payload.Code, &settings.OAuthSettings)
+	login := takeLoginCookie(w, r, oauthLoginCookie, oauthLoginCookiePath)
+
+	info, err := handler.authenticateOAuth(payload.Code, payload.State, login, &settings.OAuthSettings)
 	if err != nil {
 		log.Debug().Err(err).Msg("OAuth authentication error")
 
 		return httperror.InternalServerError("Unable to authenticate through OAuth", httperrors.ErrUnauthorized)
 	}
 
+	audit.SetUser(r, 0, info.Username)
+
+	user, httpErr := handler.externalUser(info.Username, settings.OAuthSettings.OAuthAutoCreateUsers, settings.OAuthSettings.DefaultTeamID)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	err = handler.syncTeamMemberships(user, info.Groups, &settings.OAuthSettings.TeamMemberships, settings.OAuthSettings.DefaultTeamID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to synchronize the team memberships of the user", err)
+	}
+
+	audit.SetUser(r, user.ID, user.Username)
+
+	return handler.writeToken(w, r, user, false)
+}
+
+// externalUser returns the user authenticated by an identity provider, the user is created with the default team
+// when it does not exist and automatic user provisioning is enabled
+func (handler *Handler) externalUser(username string, autoCreateUsers bool, defaultTeamID portainer.TeamID) (*portainer.User, *httperror.HandlerError) {
 	user, err := handler.DataStore.User().UserByUsername(username)
 	if err != nil && !handler.DataStore.IsErrObjectNotFound(err) {
-		return httperror.InternalServerError("Unable to retrieve a user with the specified username from the database", err)
+		return nil, httperror.InternalServerError("Unable to retrieve a user with the specified username from the database", err)
+	}
+
+	if user != nil && user.Disabled {
+		return nil, httperror.Forbidden("The user is disabled", httperrors.ErrUnauthorized)
+	}
+
+	if user == nil && !autoCreateUsers {
+		return nil, httperror.Forbidden("Account not created beforehand in Portainer and automatic user provisioning not enabled", httperrors.ErrUnauthorized)
+	}
+
+	if user != nil {
+		return user, nil
+	}
+
+	user = &portainer.User{
+		Username: username,
+		Role:     portainer.StandardUserRole,
 	}
 
-	if user == nil && !settings.OAuthSettings.OAuthAutoCreateUsers {
-		return httperror.Forbidden("Account not created beforehand in Portainer and automatic user provisioning not enabled", httperrors.ErrUnauthorized)
+	err = handler.DataStore.User().Create(user)
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to persist user inside the database", err)
 	}
 
-	if user == nil {
-		user = &portainer.User{
-			Username: username,
-			Role:     portainer.StandardUserRole,
+	if defaultTeamID != 0 {
+		membership := &portainer.TeamMembership{
+			UserID: user.ID,
+			TeamID: defaultTeamID,
+			Role:   portainer.TeamMember,
 		}
 
-		err = handler.DataStore.User().Create(user)
+		err = handler.DataStore.TeamMembership().Create(membership)
 		if err != nil {
-			return httperror.InternalServerError("Unable to persist user inside the database", err)
+			return nil, httperror.InternalServerError("Unable to persist team membership inside the database", err)
 		}
+	}
 
-		if settings.OAuthSettings.DefaultTeamID != 0 {
-			membership := &portainer.TeamMembership{
-				UserID: user.ID,
-				TeamID: settings.OAuthSettings.DefaultTeamID,
-				Role:   portainer.TeamMember,
-			}
-
-			err = handler.DataStore.TeamMembership().Create(membership)
-			if err != nil {
-				return httperror.InternalServerError("Unable to persist team membership inside the database", err)
-			}
-		}
+	return user, nil
+}
 
+// @id LoginOAuth
+// @summary Start an OpenID Connect login
+// @description Redirect to the authorization endpoint of the OpenID Connect provider. The authorization request
+// @description uses PKCE and a nonce, the code returned along with the state must be sent to /auth/oauth/validate
+// @description by the same browser, the login is kept in a cookie until then.
+// @description **Access policy**: public
+// @tags auth
+// @param state query string true "Opaque value returned to the redirect URI along with the code"
+// @success 302 "Redirect to the OpenID Connect provider"
+// @failure 400 "Invalid request"
+// @failure 403 "OpenID Connect authentication is not enabled"
+// @failure 500 "Server error"
+// @router /auth/oauth/login [get]
+func (handler *Handler) loginOAuth(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	state, err := request.RetrieveQueryParameter(r, "state", false)
+	if err != nil {
+		return httperror.BadRequest("Invalid query parameter: state", err)
 	}
 
-	return handler.writeToken(w, user, false)
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve settings from the database", err)
+	}
+
+	if settings.AuthenticationMethod != portainer.AuthenticationOAuth || !settings.OAuthSettings.OIDC {
+		return httperror.Forbidden("OpenID Connect authentication is not enabled", errors.New("OpenID Connect authentication is not enabled"))
+	}
+
+	loginURL, login, err := handler.OAuthService.LoginURL(state, &settings.OAuthSettings)
+	if err != nil {
+		return httperror.InternalServerError("Unable to start the OpenID Connect login", err)
+	}
+
+	setLoginCookie(w, r, oauthLoginCookie, oauthLoginCookiePath, login)
+
+	http.Redirect(w, r, loginURL, http.StatusFound)
+	return nil
 }
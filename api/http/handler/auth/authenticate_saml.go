@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/audit"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/rs/zerolog/log"
+)
+
+var errSAMLNotEnabled = errors.New("SAML authentication is not enabled")
+
+const (
+	// samlLoginCookie is the cookie keeping the SAML login started by a browser
+	samlLoginCookie = "portainer_saml_login"
+	// samlLoginCookiePath limits the login cookie to the SAML endpoints
+	samlLoginCookiePath = "/api/auth/saml"
+)
+
+type samlPayload struct {
+	// Code returned to Portainer by the assertion consumer service
+	Code string
+}
+
+func (payload *samlPayload) Validate(r *http.Request) error {
+	if govalidator.IsNull(payload.Code) {
+		return errors.New("Invalid SAML code")
+	}
+	return nil
+}
+
+// samlSettings returns the SAML settings, or a forbidden error when SAML authentication is not enabled
+func (handler *Handler) samlSettings() (*portainer.Settings, *httperror.HandlerError) {
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return nil, httperror.InternalServerError("Unable to retrieve settings from the database", err)
+	}
+
+	if settings.AuthenticationMethod != portainer.AuthenticationSAML {
+		return nil, httperror.Forbidden("SAML authentication is not enabled", errSAMLNotEnabled)
+	}
+
+	return settings, nil
+}
+
+// @id SAMLMetadata
+// @summary Retrieve the SAML service provider metadata
+// @description Retrieve the metadata of Portainer as a SAML service provider, to be imported by the identity provider.
+// @description **Access policy**: public
+// @tags auth
+// @produce xml
+// @success 200 "Success"
+// @failure 403 "SAML authentication is not enabled"
+// @failure 500 "Server error"
+// @router /auth/saml/metadata [get]
+func (handler *Handler) samlMetadata(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	settings, httpErr := handler.samlSettings()
+	if httpErr != nil {
+		return httpErr
+	}
+
+	metadata, err := handler.SAMLService.Metadata(&settings.SAMLSettings)
+	if err != nil {
+		return httperror.InternalServerError("Unable to generate the SAML service provider metadata", err)
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.Write(metadata)
+
+	return nil
+}
+
+// @id LoginSAML
+// @summary Start a SAML login
+// @description Redirect to the single sign-on service of the SAML identity provider with a signed authentication request.
+// @description The identity provider posts its response to /auth/saml/acs, which redirects to Portainer with a code
+// @description to send to /auth/saml/validate along with the state, by the same browser. The login is kept in a cookie until then.
+// @description **Access policy**: public
+// @tags auth
+// @param state query string true "Opaque value returned to Portainer along with the code"
+// @success 302 "Redirect to the SAML identity provider"
+// @failure 400 "Invalid request"
+// @failure 403 "SAML authentication is not enabled"
+// @failure 500 "Server error"
+// @router /auth/saml/login [get]
+func (handler *Handler) loginSAML(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	state, err := request.RetrieveQueryParameter(r, "state", false)
+	if err != nil {
+		return httperror.BadRequest("Invalid query parameter: state", err)
+	}
+
+	settings, httpErr := handler.samlSettings()
+	if httpErr != nil {
+		return httpErr
+	}
+
+	loginURL, login, err := handler.SAMLService.LoginURL(state, &settings.SAMLSettings)
+	if err != nil {
+		return httperror.InternalServerError("Unable to start the SAML login", err)
+	}
+
+	setLoginCookie(w, r, samlLoginCookie, samlLoginCookiePath, login)
+
+	http.Redirect(w, r, loginURL, http.StatusFound)
+	return nil
+}
+
+// @id SAMLAssertionConsumerService
+// @summary Receive the response of the SAML identity provider
+// @description Validate the response posted by the SAML identity provider with the HTTP-POST binding, then redirect
+// @description to Portainer with a single use code and the state of the login.
+// @description **Access policy**: public
+// @tags auth
+// @accept x-www-form-urlencoded
+// @param SAMLResponse formData string true "Base64 encoded SAML response"
+// @param RelayState formData string true "State of the login"
+// @success 302 "Redirect to Portainer"
+// @failure 400 "Invalid request"
+// @failure 403 "SAML authentication is not enabled"
+// @failure 422 "Invalid SAML response"
+// @failure 500 "Server error"
+// @router /auth/saml/acs [post]
+func (handler *Handler) samlACS(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	audit.SetAuthMethod(r, portainer.AuditLogAuthMethodSAML)
+
+	err := r.ParseForm()
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	samlResponse := r.PostForm.Get("SAMLResponse")
+	relayState := r.PostForm.Get("RelayState")
+	if samlResponse == "" || relayState == "" {
+		return httperror.BadRequest("Invalid request payload", errors.New("the SAML response and the relay state are required"))
+	}
+
+	settings, httpErr := handler.samlSettings()
+	if httpErr != nil {
+		return httpErr
+	}
+
+	code, err := handler.SAMLService.Authenticate(samlResponse, relayState, &settings.SAMLSettings)
+	if err != nil {
+		log.Debug().Err(err).Msg("SAML authentication error")
+
+		return httperror.NewError(http.StatusUnprocessableEntity, "Unable to authenticate through SAML", httperrors.ErrUnauthorized)
+	}
+
+	redirectURL := strings.TrimSuffix(settings.SAMLSettings.ServiceProviderURL, "/") + "/?code=" + url.QueryEscape(code) + "&state=" + url.QueryEscape(relayState)
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+	return nil
+}
+
+// @id ValidateSAML
+// @summary Authenticate with SAML
+// @description Exchange the code returned by the assertion consumer service for a JWT, the code must be sent by the browser
+// @description which started the login.
+// @description **Access policy**: public
+// @tags auth
+// @accept json
+// @produce json
+// @param body body samlPayload true "Code returned by the assertion consumer service"
+// @success 200 {object} authenticateResponse "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "SAML authentication is not enabled or the user is not allowed"
+// @failure 422 "Invalid code"
+// @failure 500 "Server error"
+// @router /auth/saml/validate [post]
+func (handler *Handler) validateSAML(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload samlPayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	audit.SetAuthMethod(r, portainer.AuditLogAuthMethodSAML)
+
+	settings, httpErr := handler.samlSettings()
+	if httpErr != nil {
+		return httpErr
+	}
+
+	login := takeLoginCookie(w, r, samlLoginCookie, samlLoginCookiePath)
+
+	info, err := handler.SAMLService.Redeem(payload.Code, login)
+	if err != nil {
+		return httperror.NewError(http.StatusUnprocessableEntity, "Invalid SAML code", httperrors.ErrUnauthorized)
+	}
+
+	audit.SetUser(r, 0, info.Username)
+
+	user, httpErr := handler.externalUser(info.Username, settings.SAMLSettings.AutoCreateUsers, settings.SAMLSettings.DefaultTeamID)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	err = handler.syncTeamMemberships(user, info.Groups, &settings.SAMLSettings.TeamMemberships, settings.SAMLSettings.DefaultTeamID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to synchronize the team memberships of the user", err)
+	}
+
+	audit.SetUser(r, user.ID, user.Username)
+
+	return handler.writeToken(w, r, user, false)
+}
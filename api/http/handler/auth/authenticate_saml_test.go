@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/jwt"
+	"github.com/portainer/portainer/api/saml"
+	"github.com/portainer/portainer/api/saml/samltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_authenticateSAML(t *testing.T) {
+	is := assert.New(t)
+
+	idp := samltest.RunIdP()
+	defer idp.Close()
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	team := &portainer.Team{Name: "developers"}
+	require.NoError(t, store.Team().Create(team))
+
+	samlService := saml.NewService()
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	settings.AuthenticationMethod = portainer.AuthenticationSAML
+	settings.SAMLSettings = portainer.SAMLSettings{
+		ServiceProviderURL: "https://portainer.example.com/",
+		IdPMetadataURL:     idp.URL + "/metadata",
+		UsernameAttribute:  "uid",
+		AutoCreateUsers:    true,
+		TeamMemberships: portainer.OAuthTeamMemberships{
+			GroupClaimName: "groups",
+			TeamMappings:   []portainer.OAuthTeamMapping{{ClaimValue: "developers", TeamID: team.ID}},
+		},
+	}
+	require.NoError(t, samlService.Configure(&settings.SAMLSettings))
+	require.NoError(t, store.Settings().UpdateSettings(settings))
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+
+	h := NewHandler(bouncer, security.NewRateLimiter(100, 1*time.Second, 1*time.Hour), security.NewPasswordStrengthChecker(store.Settings()))
+	h.DataStore = store
+	h.JWTService = jwtService
+	h.SAMLService = samlService
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("serves the service provider metadata", func(t *testing.T) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "/auth/saml/metadata", nil))
+		is.Equal(http.StatusOK, rr.Code)
+		is.Equal("application/samlmetadata+xml", rr.Header().Get("Content-Type"))
+		is.Contains(rr.Body.String(), "https://portainer.example.com/api/auth/saml/acs")
+	})
+
+	// startLogin starts a login and returns the code returned to Portainer along with the login cookie of the browser
+	startLogin := func(t *testing.T) (string, *http.Cookie) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "/auth/saml/login?state=login-state", nil))
+		require.Equal(t, http.StatusFound, rr.Code)
+
+		cookies := rr.Result().Cookies()
+		require.Len(t, cookies, 1)
+		is.Equal(samlLoginCookie, cookies[0].Name)
+		is.True(cookies[0].HttpOnly)
+
+		samlResponse, relayState, err := idp.Respond(rr.Header().Get("Location"), settings.SAMLSettings.Certificate)
+		require.NoError(t, err)
+
+		form := url.Values{"SAMLResponse": {samlResponse}, "RelayState": {relayState}}
+		req := httptest.NewRequest(http.MethodPost, "/auth/saml/acs", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr = serve(req)
+		require.Equal(t, http.StatusFound, rr.Code)
+
+		redirect, err := url.Parse(rr.Header().Get("Location"))
+		require.NoError(t, err)
+		is.Equal("portainer.example.com", redirect.Host)
+		is.Equal("login-state", redirect.Query().Get("state"))
+
+		return redirect.Query().Get("code"), cookies[0]
+	}
+
+	validate := func(code string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/saml/validate", bytes.NewBufferString(fmt.Sprintf(`{"Code":%q}`, code)))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+
+		return serve(req)
+	}
+
+	t.Run("logs in through the identity provider", func(t *testing.T) {
+		code, cookie := startLogin(t)
+
+		rr := validate(code, cookie)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var resp authenticateResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+
+		tokenData, err := jwtService.ParseAndVerifyToken(resp.JWT)
+		require.NoError(t, err)
+		is.Equal("alice", tokenData.Username)
+
+		user, err := store.User().UserByUsername("alice")
+		require.NoError(t, err)
+
+		memberships, err := store.TeamMembership().TeamMembershipsByUserID(user.ID)
+		require.NoError(t, err)
+		require.Len(t, memberships, 1)
+		is.Equal(team.ID, memberships[0].TeamID)
+
+		is.Equal(http.StatusUnprocessableEntity, validate(code, cookie).Code, "a code should only be redeemed once")
+	})
+
+	t.Run("refuses a code redeemed by another browser", func(t *testing.T) {
+		code, _ := startLogin(t)
+		_, otherCookie := startLogin(t)
+
+		is.Equal(http.StatusUnprocessableEntity, validate(code, nil).Code)
+
+		code, _ = startLogin(t)
+		is.Equal(http.StatusUnprocessableEntity, validate(code, otherCookie).Code)
+	})
+
+	t.Run("refuses an invalid response", func(t *testing.T) {
+		form := url.Values{"SAMLResponse": {"PHNhbWxwOlJlc3BvbnNlLz4="}, "RelayState": {"login-state"}}
+		req := httptest.NewRequest(http.MethodPost, "/auth/saml/acs", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		is.Equal(http.StatusUnprocessableEntity, serve(req).Code)
+	})
+
+	t.Run("is forbidden when SAML authentication is not enabled", func(t *testing.T) {
+		settings.AuthenticationMethod = portainer.AuthenticationInternal
+		require.NoError(t, store.Settings().UpdateSettings(settings))
+
+		is.Equal(http.StatusForbidden, serve(httptest.NewRequest(http.MethodGet, "/auth/saml/login?state=login-state", nil)).Code)
+	})
+}
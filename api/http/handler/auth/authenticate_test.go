@@ -0,0 +1,374 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/audit"
+	"github.com/portainer/portainer/api/crypto"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/jwt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_authenticate_shouldRecordLoginAttempts(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	cryptoService := &crypto.Service{}
+	password, err := cryptoService.Hash("password")
+	require.NoError(t, err)
+
+	user := &portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole, Password: password}
+	err = store.User().Create(user)
+	require.NoError(t, err)
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, audit.NewService(store, t.TempDir()))
+
+	// a single authentication attempt is allowed per hour
+	rateLimiter := security.NewRateLimiter(1, 1*time.Hour, 1*time.Hour)
+
+	h := NewHandler(bouncer, rateLimiter, security.NewPasswordStrengthChecker(store.Settings()))
+	h.DataStore = store
+	h.CryptoService = cryptoService
+	h.JWTService = jwtService
+
+	login := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString(`{"Username":"admin","Password":"wrong"}`))
+		req.RemoteAddr = "10.0.0.10:51234"
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	is.Equal(http.StatusUnprocessableEntity, login())
+	is.Equal(http.StatusForbidden, login())
+
+	auditLogs, err := store.AuditLog().AuditLogs(nil)
+	require.NoError(t, err)
+	require.Len(t, auditLogs, 2)
+
+	rateLimited, failed := auditLogs[0], auditLogs[1]
+
+	is.Equal("auth", failed.ResourceType)
+	is.Equal("login", failed.Operation)
+	is.Equal(user.ID, failed.UserID)
+	is.Equal("admin", failed.Username)
+	is.Equal(portainer.AuditLogAuthMethodPassword, failed.AuthMethod)
+	is.Equal("10.0.0.10", failed.SourceIP)
+	is.Equal(portainer.AuditLogOutcomeFailure, failed.Outcome)
+
+	is.Equal("login", rateLimited.Operation)
+	is.Equal("10.0.0.10", rateLimited.SourceIP)
+	is.Equal(portainer.AuditLogOutcomeDenied, rateLimited.Outcome)
+}
+
+func Test_authenticate_shouldLockTheAccountAfterTooManyFailedAttempts(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	settings.InternalAuthSettings.MaxFailedAttempts = 2
+	settings.InternalAuthSettings.LockoutDuration = "1h"
+	err = store.Settings().UpdateSettings(settings)
+	require.NoError(t, err)
+
+	cryptoService := &crypto.Service{}
+	password, err := cryptoService.Hash("password")
+	require.NoError(t, err)
+
+	user := &portainer.User{ID: 2, Username: "bob", Role: portainer.StandardUserRole, Password: password}
+	err = store.User().Create(user)
+	require.NoError(t, err)
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
+
+	h := NewHandler(bouncer, rateLimiter, security.NewPasswordStrengthChecker(store.Settings()))
+	h.DataStore = store
+	h.CryptoService = cryptoService
+	h.JWTService = jwtService
+
+	login := func(password string) int {
+		req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString(`{"Username":"bob","Password":"`+password+`"}`))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	is.Equal(http.StatusUnprocessableEntity, login("wrong"))
+	is.Equal(http.StatusOK, login("password"), "a successful authentication resets the failed attempts")
+
+	is.Equal(http.StatusUnprocessableEntity, login("wrong"))
+	is.Equal(http.StatusUnprocessableEntity, login("wrong"))
+	is.Equal(http.StatusUnprocessableEntity, login("password"), "a locked account should fail as with invalid credentials")
+
+	user, err = store.User().User(user.ID)
+	require.NoError(t, err)
+	is.Equal(2, user.FailedLoginAttempts)
+	is.NotZero(user.LockedAt)
+
+	// the lock is lifted once the lockout duration has elapsed
+	user.LockedAt = time.Now().Add(-time.Hour).Unix()
+	err = store.User().UpdateUser(user.ID, user)
+	require.NoError(t, err)
+
+	is.Equal(http.StatusOK, login("password"))
+}
+
+func Test_authenticateInternal_shouldCountTheFailuresOfConcurrentAttempts(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	settings.InternalAuthSettings.MaxFailedAttempts = 3
+	settings.InternalAuthSettings.LockoutDuration = "1h"
+
+	cryptoService := &crypto.Service{}
+	password, err := cryptoService.Hash("password")
+	require.NoError(t, err)
+
+	user := &portainer.User{Username: "bob", Role: portainer.StandardUserRole, Password: password}
+	err = store.User().Create(user)
+	require.NoError(t, err)
+
+	h := &Handler{DataStore: store, CryptoService: cryptoService}
+
+	// every concurrent attempt loaded the user before any failure was recorded
+	for i := 0; i < 3; i++ {
+		staleUser, err := store.User().User(user.ID)
+		require.NoError(t, err)
+		staleUser.FailedLoginAttempts = 0
+
+		herr := h.authenticateInternal(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/auth", nil), staleUser, "wrong", settings)
+		require.NotNil(t, herr)
+		is.Equal(http.StatusUnprocessableEntity, herr.StatusCode)
+	}
+
+	user, err = store.User().User(user.ID)
+	require.NoError(t, err)
+	is.Equal(3, user.FailedLoginAttempts)
+	is.NotZero(user.LockedAt)
+}
+
+func Test_authenticate_shouldForceTheChangeOfAnExpiredPassword(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	settings.InternalAuthSettings.RequiredPasswordLength = 8
+	settings.InternalAuthSettings.PasswordMaxAge = "24h"
+	err = store.Settings().UpdateSettings(settings)
+	require.NoError(t, err)
+
+	cryptoService := &crypto.Service{}
+	password, err := cryptoService.Hash("long-password")
+	require.NoError(t, err)
+
+	user := &portainer.User{ID: 2, Username: "bob", Role: portainer.StandardUserRole, Password: password}
+	err = store.User().Create(user)
+	require.NoError(t, err)
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
+
+	h := NewHandler(bouncer, rateLimiter, security.NewPasswordStrengthChecker(store.Settings()))
+	h.DataStore = store
+	h.CryptoService = cryptoService
+	h.JWTService = jwtService
+
+	// login returns the forceChangePassword claim of the JWT token
+	login := func() bool {
+		req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString(`{"Username":"bob","Password":"long-password"}`))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var resp authenticateResponse
+		err := json.NewDecoder(rr.Body).Decode(&resp)
+		require.NoError(t, err)
+
+		payload, err := base64.RawURLEncoding.DecodeString(strings.Split(resp.JWT, ".")[1])
+		require.NoError(t, err)
+
+		var claims struct {
+			ForceChangePassword bool `json:"forceChangePassword"`
+		}
+		err = json.Unmarshal(payload, &claims)
+		require.NoError(t, err)
+
+		return claims.ForceChangePassword
+	}
+
+	// the password age of the users created before the expiry starts with their first authentication
+	is.False(login())
+
+	user, err = store.User().User(user.ID)
+	require.NoError(t, err)
+	is.NotZero(user.PasswordChangedAt)
+
+	user.PasswordChangedAt = time.Now().Add(-25 * time.Hour).Unix()
+	err = store.User().UpdateUser(user.ID, user)
+	require.NoError(t, err)
+
+	is.True(login())
+}
+
+// ldapMock authenticates every user
+type ldapMock struct {
+	portainer.LDAPService
+}
+
+func (ldapMock) AuthenticateUser(username, password string, settings *portainer.LDAPSettings) error {
+	return nil
+}
+
+func (ldapMock) GetUserGroups(username string, settings *portainer.LDAPSettings) ([]string, error) {
+	return nil, nil
+}
+
+func Test_authenticateLDAP_shouldOnlyEnableTheUsersDisabledByLDAP(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	settings.AuthenticationMethod = portainer.AuthenticationLDAP
+	require.NoError(t, store.Settings().UpdateSettings(settings))
+
+	users := []*portainer.User{
+		{ID: 1, Username: "admin", Role: portainer.AdministratorRole},
+		{ID: 2, Username: "carol", Role: portainer.StandardUserRole, Disabled: true, DisabledBy: portainer.UserDisabledByLDAP},
+		{ID: 3, Username: "dave", Role: portainer.StandardUserRole, Disabled: true, DisabledBy: portainer.UserDisabledBySCIM},
+	}
+	for _, user := range users {
+		require.NoError(t, store.User().Create(user))
+	}
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+
+	h := NewHandler(bouncer, security.NewRateLimiter(100, 1*time.Second, 1*time.Hour), security.NewPasswordStrengthChecker(store.Settings()))
+	h.DataStore = store
+	h.JWTService = jwtService
+	h.LDAPService = ldapMock{}
+
+	login := func(username string) int {
+		req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString(`{"Username":"`+username+`","Password":"password"}`))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	is.Equal(http.StatusOK, login("carol"))
+
+	carol, err := store.User().User(2)
+	require.NoError(t, err)
+	is.False(carol.Disabled)
+	is.Empty(carol.DisabledBy)
+
+	is.Equal(http.StatusForbidden, login("dave"), "a user deactivated through SCIM should not be enabled again by an LDAP login")
+
+	dave, err := store.User().User(3)
+	require.NoError(t, err)
+	is.True(dave.Disabled)
+}
+
+func Test_authenticateLDAP_shouldRequireTheMultiFactorAuthentication(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	settings.AuthenticationMethod = portainer.AuthenticationLDAP
+	settings.MFARequirement = portainer.MFARequiredForAdministrators
+	require.NoError(t, store.Settings().UpdateSettings(settings))
+
+	users := []*portainer.User{
+		{ID: 1, Username: "admin", Role: portainer.AdministratorRole},
+		{ID: 2, Username: "erin", Role: portainer.AdministratorRole},
+		{ID: 3, Username: "frank", Role: portainer.StandardUserRole, MFA: portainer.UserMFA{Enabled: true}},
+		{ID: 4, Username: "grace", Role: portainer.StandardUserRole},
+	}
+	for _, user := range users {
+		require.NoError(t, store.User().Create(user))
+	}
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+
+	h := NewHandler(bouncer, security.NewRateLimiter(100, 1*time.Second, 1*time.Hour), security.NewPasswordStrengthChecker(store.Settings()))
+	h.DataStore = store
+	h.JWTService = jwtService
+	h.LDAPService = ldapMock{}
+
+	login := func(username string) authenticateResponse {
+		req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString(`{"Username":"`+username+`","Password":"password"}`))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, username)
+
+		var resp authenticateResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+
+		return resp
+	}
+
+	for _, username := range []string{"erin", "frank"} {
+		resp := login(username)
+		is.True(resp.MFARequired, username)
+		is.Empty(resp.JWT, username)
+		is.NotEmpty(resp.PreAuthToken, username)
+	}
+
+	resp := login("grace")
+	is.False(resp.MFARequired)
+	is.NotEmpty(resp.JWT)
+}
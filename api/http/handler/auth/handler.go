@@ -6,12 +6,22 @@ import (
 	"github.com/gorilla/mux"
 	httperror "github.com/portainer/libhttp/error"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/audit"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/http/proxy"
 	"github.com/portainer/portainer/api/http/proxy/factory/kubernetes"
 	"github.com/portainer/portainer/api/http/security"
 )
 
+// resources of the authentication attempts in the audit log
+var (
+	loginResource    = audit.Resource{Type: "auth", Operation: "login"}
+	mfaResource      = audit.Resource{Type: "auth", Operation: "mfa"}
+	mfaEnrolResource = audit.Resource{Type: "auth", Operation: "mfa_enrol"}
+	samlResource     = audit.Resource{Type: "auth", Operation: "saml_response"}
+)
+
 // Handler is the HTTP handler used to handle authentication operations.
 type Handler struct {
 	*mux.Router
@@ -20,6 +30,8 @@ type Handler struct {
 	JWTService                  dataservices.JWTService
 	LDAPService                 portainer.LDAPService
 	OAuthService                portainer.OAuthService
+	SAMLService                 portainer.SAMLService
+	APIKeyService               apikey.APIKeyService
 	ProxyManager                *proxy.Manager
 	KubernetesTokenCacheManager *kubernetes.TokenCacheManager
 	passwordStrengthChecker     security.PasswordStrengthChecker
@@ -33,9 +45,29 @@ func NewHandler(bouncer *security.RequestBouncer, rateLimiter *security.RateLimi
 	}
 
 	h.Handle("/auth/oauth/validate",
-		rateLimiter.LimitAccess(bouncer.PublicAccess(httperror.LoggerHandler(h.validateOAuth)))).Methods(http.MethodPost)
+		bouncer.AuditedAccess(audit.WithResource(loginResource,
+			rateLimiter.LimitAccess(bouncer.PublicAccess(httperror.LoggerHandler(h.validateOAuth)))))).Methods(http.MethodPost)
+	h.Handle("/auth/oauth/login",
+		rateLimiter.LimitAccess(bouncer.PublicAccess(httperror.LoggerHandler(h.loginOAuth)))).Methods(http.MethodGet)
+	h.Handle("/auth/saml/metadata",
+		bouncer.PublicAccess(httperror.LoggerHandler(h.samlMetadata))).Methods(http.MethodGet)
+	h.Handle("/auth/saml/login",
+		rateLimiter.LimitAccess(bouncer.PublicAccess(httperror.LoggerHandler(h.loginSAML)))).Methods(http.MethodGet)
+	h.Handle("/auth/saml/acs",
+		bouncer.AuditedAccess(audit.WithResource(samlResource,
+			rateLimiter.LimitAccess(bouncer.PublicAccess(httperror.LoggerHandler(h.samlACS)))))).Methods(http.MethodPost)
+	h.Handle("/auth/saml/validate",
+		bouncer.AuditedAccess(audit.WithResource(loginResource,
+			rateLimiter.LimitAccess(bouncer.PublicAccess(httperror.LoggerHandler(h.validateSAML)))))).Methods(http.MethodPost)
 	h.Handle("/auth",
-		rateLimiter.LimitAccess(bouncer.PublicAccess(httperror.LoggerHandler(h.authenticate)))).Methods(http.MethodPost)
+		bouncer.AuditedAccess(audit.WithResource(loginResource,
+			rateLimiter.LimitAccess(bouncer.PublicAccess(httperror.LoggerHandler(h.authenticate)))))).Methods(http.MethodPost)
+	h.Handle("/auth/mfa",
+		bouncer.AuditedAccess(audit.WithResource(mfaResource,
+			rateLimiter.LimitAccess(bouncer.PublicAccess(httperror.LoggerHandler(h.authenticateMFA)))))).Methods(http.MethodPost)
+	h.Handle("/auth/mfa/enrol",
+		bouncer.AuditedAccess(audit.WithResource(mfaEnrolResource,
+			rateLimiter.LimitAccess(bouncer.PublicAccess(httperror.LoggerHandler(h.enrolMFA)))))).Methods(http.MethodPost)
 	h.Handle("/auth/logout",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.logout))).Methods(http.MethodPost)
 
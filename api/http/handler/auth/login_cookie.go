@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+)
+
+// loginCookieExpiry is the time given to a user to log in on an identity provider
+const loginCookieExpiry = 10 * time.Minute
+
+// setLoginCookie keeps the sealed login started by a browser, so that the login can only be completed by this browser
+func setLoginCookie(w http.ResponseWriter, r *http.Request, name, path, login string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    login,
+		Path:     path,
+		MaxAge:   int(loginCookieExpiry.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// takeLoginCookie returns the sealed login kept by the browser and removes it, a login can only be completed once
+func takeLoginCookie(w http.ResponseWriter, r *http.Request, name, path string) string {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return cookie.Value
+}
@@ -25,5 +25,12 @@ func (handler *Handler) logout(w http.ResponseWriter, r *http.Request) *httperro
 
 	handler.KubernetesTokenCacheManager.RemoveUserFromCache(int(tokenData.ID))
 
+	if tokenData.SessionID != "" {
+		err = handler.JWTService.RevokeSession(tokenData.SessionID)
+		if err != nil {
+			return httperror.InternalServerError("Unable to revoke the session", err)
+		}
+	}
+
 	return response.Empty(w)
 }
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	portainer "github.com/portainer/portainer/api"
+	"github.com/rs/zerolog/log"
+)
+
+// syncTeamMemberships reconciles the team memberships of a user with the teams mapped to its OAuth or SAML groups,
+// the memberships of the other teams are removed, except for the default team. When an admin group is set, the
+// administrator role is granted to the members of the group and revoked from the other users, except from the
+// initial administrator and the last administrator. Nothing is done when no group claim is set.
+func (handler *Handler) syncTeamMemberships(user *portainer.User, groups []string, settings *portainer.OAuthTeamMemberships, defaultTeamID portainer.TeamID) error {
+	if settings.GroupClaimName == "" {
+		return nil
+	}
+
+	teamIDs, err := handler.mapGroupsToTeams(groups, settings)
+	if err != nil {
+		return err
+	}
+
+	if defaultTeamID != 0 {
+		teamIDs[defaultTeamID] = true
+	}
+
+	memberships, err := handler.DataStore.TeamMembership().TeamMembershipsByUserID(user.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to retrieve the team memberships of the user")
+	}
+
+	for _, membership := range memberships {
+		if teamIDs[membership.TeamID] {
+			delete(teamIDs, membership.TeamID)
+			continue
+		}
+
+		err := handler.DataStore.TeamMembership().DeleteTeamMembership(membership.ID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove the user from the team %d", membership.TeamID)
+		}
+	}
+
+	for teamID := range teamIDs {
+		membership := &portainer.TeamMembership{
+			UserID: user.ID,
+			TeamID: teamID,
+			Role:   portainer.TeamMember,
+		}
+
+		err := handler.DataStore.TeamMembership().Create(membership)
+		if err != nil {
+			return errors.Wrapf(err, "failed to add the user to the team %d", teamID)
+		}
+	}
+
+	adminGroup := settings.AdminGroup
+	if adminGroup == "" {
+		return nil
+	}
+
+	role := portainer.StandardUserRole
+	for _, group := range groups {
+		if group == adminGroup {
+			role = portainer.AdministratorRole
+			break
+		}
+	}
+
+	if user.Role == role {
+		return nil
+	}
+
+	if role != portainer.AdministratorRole {
+		demotable, err := handler.isDemotable(user)
+		if err != nil || !demotable {
+			return err
+		}
+	}
+
+	// the tokens carrying the previous role are revoked
+	user.Role = role
+	user.TokenIssueAt = time.Now().Unix()
+
+	err = handler.DataStore.User().UpdateUser(user.ID, user)
+	if err != nil {
+		return err
+	}
+
+	if handler.APIKeyService != nil {
+		handler.APIKeyService.InvalidateUserKeyCache(user.ID)
+	}
+
+	return nil
+}
+
+// isDemotable returns false for the initial administrator and the last administrator, who would otherwise
+// lose the administration of Portainer when missing from the admin group
+func (handler *Handler) isDemotable(user *portainer.User) (bool, error) {
+	if isUserInitialAdmin(user) {
+		log.Warn().Str("username", user.Username).Msg("the initial administrator is not in the admin group, keeping the administrator role")
+
+		return false, nil
+	}
+
+	admins, err := handler.DataStore.User().UsersByRole(portainer.AdministratorRole)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to retrieve the administrators")
+	}
+
+	if len(admins) <= 1 {
+		log.Warn().Str("username", user.Username).Msg("the last administrator is not in the admin group, keeping the administrator role")
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// mapGroupsToTeams returns the teams matched by the groups. A group matching no mapping is matched to the team
+// with the same name when teams are created automatically, the team is created when it does not exist.
+func (handler *Handler) mapGroupsToTeams(groups []string, settings *portainer.OAuthTeamMemberships) (map[portainer.TeamID]bool, error) {
+	teams, err := handler.DataStore.Team().Teams()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the teams")
+	}
+
+	teamsByID := make(map[portainer.TeamID]bool)
+	teamsByName := make(map[string]portainer.TeamID)
+	for _, team := range teams {
+		teamsByID[team.ID] = true
+		teamsByName[strings.ToLower(team.Name)] = team.ID
+	}
+
+	matchers := make([]func(group string) bool, len(settings.TeamMappings))
+	for i, mapping := range settings.TeamMappings {
+		value := mapping.ClaimValue
+
+		if !mapping.Regex {
+			matchers[i] = func(group string) bool { return group == value }
+			continue
+		}
+
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid regular expression %s", value)
+		}
+
+		matchers[i] = re.MatchString
+	}
+
+	teamIDs := make(map[portainer.TeamID]bool)
+	for _, group := range groups {
+		matched := false
+
+		for i, mapping := range settings.TeamMappings {
+			if !matchers[i](group) {
+				continue
+			}
+
+			matched = true
+
+			if !teamsByID[mapping.TeamID] {
+				log.Warn().Int("team_id", int(mapping.TeamID)).Str("group", group).Msg("the team of a group mapping does not exist")
+
+				continue
+			}
+
+			teamIDs[mapping.TeamID] = true
+		}
+
+		if matched || !settings.AutoCreateTeams {
+			continue
+		}
+
+		teamID, ok := teamsByName[strings.ToLower(group)]
+		if !ok {
+			team := &portainer.Team{Name: group}
+
+			err := handler.DataStore.Team().Create(team)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to create the team %s", group)
+			}
+
+			teamID = team.ID
+			teamsByName[strings.ToLower(group)] = teamID
+		}
+
+		teamIDs[teamID] = true
+	}
+
+	return teamIDs, nil
+}
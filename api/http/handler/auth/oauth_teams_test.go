@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"sort"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_syncOAuthTeamMemberships(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	h := &Handler{DataStore: store}
+
+	for _, name := range []string{"default", "developers", "operators", "legacy"} {
+		require.NoError(t, store.Team().Create(&portainer.Team{Name: name}))
+	}
+
+	teamID := func(name string) portainer.TeamID {
+		team, err := store.Team().TeamByName(name)
+		require.NoError(t, err)
+		return team.ID
+	}
+
+	admin := &portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}
+	require.NoError(t, store.User().Create(admin))
+
+	user := &portainer.User{ID: 2, Username: "john", Role: portainer.StandardUserRole}
+	require.NoError(t, store.User().Create(user))
+	require.NoError(t, store.TeamMembership().Create(&portainer.TeamMembership{UserID: user.ID, TeamID: teamID("legacy"), Role: portainer.TeamMember}))
+
+	userTeams := func() []string {
+		memberships, err := store.TeamMembership().TeamMembershipsByUserID(user.ID)
+		require.NoError(t, err)
+
+		names := []string{}
+		for _, membership := range memberships {
+			team, err := store.Team().Team(membership.TeamID)
+			require.NoError(t, err)
+			names = append(names, team.Name)
+		}
+		sort.Strings(names)
+
+		return names
+	}
+
+	settings := &portainer.OAuthSettings{DefaultTeamID: teamID("default")}
+
+	err := h.syncTeamMemberships(user, []string{"dev-frontend"}, &settings.TeamMemberships, settings.DefaultTeamID)
+	require.NoError(t, err)
+	is.Equal([]string{"legacy"}, userTeams(), "the memberships must be kept when no group claim is set")
+
+	settings.TeamMemberships = portainer.OAuthTeamMemberships{
+		GroupClaimName: "groups",
+		TeamMappings: []portainer.OAuthTeamMapping{
+			{ClaimValue: "^dev-.*$", Regex: true, TeamID: teamID("developers")},
+			{ClaimValue: "ops", TeamID: teamID("operators")},
+			{ClaimValue: "missing", TeamID: 100},
+		},
+		AdminGroup: "portainer-admins",
+	}
+
+	err = h.syncTeamMemberships(user, []string{"dev-frontend", "dev-backend", "ops-readonly", "missing", "unmapped"}, &settings.TeamMemberships, settings.DefaultTeamID)
+	require.NoError(t, err)
+	is.Equal([]string{"default", "developers"}, userTeams())
+	is.Equal(portainer.StandardUserRole, user.Role)
+
+	settings.TeamMemberships.AutoCreateTeams = true
+
+	err = h.syncTeamMemberships(user, []string{"ops", "Unmapped", "portainer-admins"}, &settings.TeamMemberships, settings.DefaultTeamID)
+	require.NoError(t, err)
+	is.Equal([]string{"Unmapped", "default", "operators", "portainer-admins"}, userTeams())
+
+	stored, err := store.User().User(user.ID)
+	require.NoError(t, err)
+	is.Equal(portainer.AdministratorRole, stored.Role, "the administrator role must be granted to the admin group members")
+
+	err = h.syncTeamMemberships(user, []string{"unmapped"}, &settings.TeamMemberships, settings.DefaultTeamID)
+	require.NoError(t, err)
+	is.Equal([]string{"Unmapped", "default"}, userTeams(), "the team must be matched regardless of the case")
+
+	stored, err = store.User().User(user.ID)
+	require.NoError(t, err)
+	is.Equal(portainer.StandardUserRole, stored.Role, "the administrator role must be revoked from the users leaving the admin group")
+	is.NotZero(stored.TokenIssueAt, "the tokens carrying the administrator role must be revoked")
+
+	err = h.syncTeamMemberships(admin, []string{"unmapped"}, &settings.TeamMemberships, settings.DefaultTeamID)
+	require.NoError(t, err)
+
+	stored, err = store.User().User(admin.ID)
+	require.NoError(t, err)
+	is.Equal(portainer.AdministratorRole, stored.Role, "the administrator role must not be revoked from the initial administrator")
+
+	lastAdmin := &portainer.User{ID: 3, Username: "jane", Role: portainer.AdministratorRole}
+	require.NoError(t, store.User().Create(lastAdmin))
+
+	admin.Role = portainer.StandardUserRole
+	require.NoError(t, store.User().UpdateUser(admin.ID, admin))
+
+	err = h.syncTeamMemberships(lastAdmin, []string{"unmapped"}, &settings.TeamMemberships, settings.DefaultTeamID)
+	require.NoError(t, err)
+
+	stored, err = store.User().User(lastAdmin.ID)
+	require.NoError(t, err)
+	is.Equal(portainer.AdministratorRole, stored.Role, "the administrator role must not be revoked from the last administrator")
+}
@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -24,6 +25,7 @@ func (p *backupPayload) Validate(r *http.Request) error {
 // @id Backup
 // @summary Creates an archive with a system data snapshot that could be used to restore the system.
 // @description  Creates an archive with a system data snapshot that could be used to restore the system.
+// @description  The archive is encrypted to the backup recipients registered in the settings, or else with the password.
 // @description **Access policy**: admin
 // @tags backup
 // @security ApiKeyAuth
@@ -32,7 +34,7 @@ func (p *backupPayload) Validate(r *http.Request) error {
 // @produce octet-stream
 // @param body body backupPayload false "An object contains the password to encrypt the backup with"
 // @success 200 "Success"
-// @failure 400 "Invalid request"
+// @failure 400 "Invalid request, or a password is given while backup recipients are registered"
 // @failure 500 "Server error"
 // @router /backup [post]
 func (h *Handler) backup(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -42,7 +44,16 @@ func (h *Handler) backup(w http.ResponseWriter, r *http.Request) *httperror.Hand
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
-	archivePath, err := operations.CreateBackupArchive(payload.Password, h.gate, h.dataStore, h.filestorePath)
+	publicKeys, err := operations.RecipientPublicKeys(h.dataStore)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the backup recipients from the database", err)
+	}
+
+	if httpErr := validateBackupPassword(payload.Password, publicKeys); httpErr != nil {
+		return httpErr
+	}
+
+	archivePath, err := operations.CreateBackupArchive(payload.Password, publicKeys, h.gate, h.dataStore, h.filestorePath)
 	if err != nil {
 		return httperror.InternalServerError("Failed to create backup", err)
 	}
@@ -53,3 +64,13 @@ func (h *Handler) backup(w http.ResponseWriter, r *http.Request) *httperror.Hand
 
 	return nil
 }
+
+// validateBackupPassword rejects a password when backup recipients are registered,
+// as the archives are then encrypted to the recipients only
+func validateBackupPassword(password string, publicKeys []string) *httperror.HandlerError {
+	if len(publicKeys) > 0 && password != "" {
+		return httperror.BadRequest("Invalid request payload", errors.New("backups are encrypted to the registered backup recipients, a password cannot be used"))
+	}
+
+	return nil
+}
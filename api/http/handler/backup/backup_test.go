@@ -15,6 +15,7 @@ import (
 	"testing"
 	"time"
 
+	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/adminmonitor"
 	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/demo"
@@ -49,7 +50,7 @@ func Test_backupHandlerWithoutPassword_shouldCreateATarballArchive(t *testing.T)
 	gate := offlinegate.NewOfflineGate()
 	adminMonitor := adminmonitor.New(time.Hour, nil, context.Background())
 
-	handlerErr := NewHandler(nil, i.NewDatastore(), gate, "./test_assets/handler_test", func() {}, adminMonitor, &demo.Service{}).backup(w, r)
+	handlerErr := NewHandler(nil, i.NewDatastore(i.WithSettingsService(&portainer.Settings{})), gate, "./test_assets/handler_test", func() {}, adminMonitor, &demo.Service{}, nil).backup(w, r)
 	assert.Nil(t, handlerErr, "Handler should not fail")
 
 	response := w.Result()
@@ -84,7 +85,7 @@ func Test_backupHandlerWithPassword_shouldCreateEncryptedATarballArchive(t *test
 	gate := offlinegate.NewOfflineGate()
 	adminMonitor := adminmonitor.New(time.Hour, nil, nil)
 
-	handlerErr := NewHandler(nil, i.NewDatastore(), gate, "./test_assets/handler_test", func() {}, adminMonitor, &demo.Service{}).backup(w, r)
+	handlerErr := NewHandler(nil, i.NewDatastore(i.WithSettingsService(&portainer.Settings{})), gate, "./test_assets/handler_test", func() {}, adminMonitor, &demo.Service{}, nil).backup(w, r)
 	assert.Nil(t, handlerErr, "Handler should not fail")
 
 	response := w.Result()
@@ -116,3 +117,35 @@ func Test_backupHandlerWithPassword_shouldCreateEncryptedATarballArchive(t *test
 	assert.NotContains(t, createdFiles, path.Join(tmpdir, "extra_file"))
 	assert.NotContains(t, createdFiles, path.Join(tmpdir, "extra_folder", "file1"))
 }
+
+func Test_backupHandlerWithPassword_shouldFailWhenRecipientsAreRegistered(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"password":"secret"}`))
+	w := httptest.NewRecorder()
+
+	gate := offlinegate.NewOfflineGate()
+	adminMonitor := adminmonitor.New(time.Hour, nil, nil)
+
+	settings := &portainer.Settings{
+		BackupRecipients: []portainer.BackupRecipient{{Name: "ops", PublicKey: "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"}},
+	}
+
+	handlerErr := NewHandler(nil, i.NewDatastore(i.WithSettingsService(settings)), gate, "./test_assets/handler_test", func() {}, adminMonitor, &demo.Service{}, nil).backup(w, r)
+	assert.NotNil(t, handlerErr, "Handler should fail")
+	assert.Equal(t, http.StatusBadRequest, handlerErr.StatusCode)
+}
+
+func Test_backupScheduleCreateWithPassword_shouldFailWhenRecipientsAreRegistered(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Name":"nightly","CronExpression":"0 2 * * *","Password":"secret","Destination":{"Type":1,"LocalPath":"/backups"}}`))
+	w := httptest.NewRecorder()
+
+	gate := offlinegate.NewOfflineGate()
+	adminMonitor := adminmonitor.New(time.Hour, nil, nil)
+
+	settings := &portainer.Settings{
+		BackupRecipients: []portainer.BackupRecipient{{Name: "ops", PublicKey: "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"}},
+	}
+
+	handlerErr := NewHandler(nil, i.NewDatastore(i.WithSettingsService(settings)), gate, "./test_assets/handler_test", func() {}, adminMonitor, &demo.Service{}, nil).backupScheduleCreate(w, r)
+	assert.NotNil(t, handlerErr, "Handler should fail")
+	assert.Equal(t, http.StatusBadRequest, handlerErr.StatusCode)
+}
@@ -0,0 +1,42 @@
+package backup
+
+import (
+	"bytes"
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+	operations "github.com/portainer/portainer/api/backup"
+)
+
+// @id BackupValidate
+// @summary Validate a backup archive
+// @description Decrypt a backup archive, check its files against its manifest and its database version and edition
+// @description against the instance, then list its content. The instance is not modified.
+// @description **Access policy**: administrator
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @accept multipart/form-data
+// @produce json
+// @param file formData file true "Backup archive"
+// @param password formData string false "Password used to encrypt the archive"
+// @param privateKey formData string false "Private key matching one of the public keys the archive is encrypted to"
+// @success 200 {object} operations.ArchiveReport "Success"
+// @failure 400 "Invalid request"
+// @failure 500 "Server error"
+// @router /backup/validate [post]
+func (h *Handler) backupValidate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload restorePayload
+	err := decodeForm(r, &payload)
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	report, err := operations.ValidateArchive(bytes.NewReader(payload.FileContent), payload.Password, payload.PrivateKey, h.filestorePath)
+	if err != nil {
+		return httperror.InternalServerError("Failed to validate the backup", err)
+	}
+
+	return response.JSON(w, report)
+}
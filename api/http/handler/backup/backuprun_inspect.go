@@ -0,0 +1,40 @@
+package backup
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+)
+
+// @id BackupRunInspect
+// @summary Inspect a backup run
+// @description Retrieve details about a run of a backup schedule.
+// @description **Access policy**: administrator
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Backup run identifier"
+// @success 200 {object} portainer.BackupRun "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "Backup run not found"
+// @failure 500 "Server error"
+// @router /backup/runs/{id} [get]
+func (h *Handler) backupRunInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	runID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid backup run identifier route variable", err)
+	}
+
+	run, err := h.dataStore.BackupRun().BackupRun(portainer.BackupRunID(runID))
+	if h.dataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a backup run with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a backup run with the specified identifier inside the database", err)
+	}
+
+	return response.JSON(w, run)
+}
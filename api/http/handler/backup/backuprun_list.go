@@ -0,0 +1,42 @@
+package backup
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+)
+
+// @id BackupRunList
+// @summary List backup runs
+// @description List the runs of the backup schedules, most recent first, with their status, archive size and checksum.
+// @description **Access policy**: administrator
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param scheduleId query int false "Only list the runs of this backup schedule"
+// @success 200 {array} portainer.BackupRun "Success"
+// @failure 400 "Invalid request"
+// @failure 500 "Server error"
+// @router /backup/runs [get]
+func (h *Handler) backupRunList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	scheduleID, err := request.RetrieveNumericQueryParameter(r, "scheduleId", true)
+	if err != nil {
+		return httperror.BadRequest("Invalid query parameter: scheduleId", err)
+	}
+
+	var runs []portainer.BackupRun
+	if scheduleID != 0 {
+		runs, err = h.dataStore.BackupRun().BackupRunsByScheduleID(portainer.BackupScheduleID(scheduleID))
+	} else {
+		runs, err = h.dataStore.BackupRun().BackupRuns()
+	}
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve backup runs from the database", err)
+	}
+
+	return response.JSON(w, runs)
+}
@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"github.com/asaskevich/govalidator"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+	operations "github.com/portainer/portainer/api/backup"
+	"github.com/portainer/portainer/api/scheduler"
+)
+
+type backupScheduleCreatePayload struct {
+	// Name of the backup schedule
+	Name string `example:"nightly" validate:"required"`
+	// Standard 5 fields cron expression of the backup times
+	CronExpression string `example:"0 2 * * *" validate:"required"`
+	// Password used to encrypt the archives, the archives are not encrypted when empty
+	Password string `example:"secret"`
+	// Number of successful backups kept at the destination, every backup is kept when 0
+	Retention int `example:"7"`
+	// Destination of the archives
+	Destination portainer.BackupDestination
+}
+
+func (payload *backupScheduleCreatePayload) Validate(r *http.Request) error {
+	if govalidator.IsNull(payload.Name) {
+		return errors.New("Invalid backup schedule name")
+	}
+
+	return validateScheduleSettings(payload.CronExpression, payload.Retention, payload.Destination)
+}
+
+func validateScheduleSettings(cronExpression string, retention int, destination portainer.BackupDestination) error {
+	if scheduler.ValidateCronExpression(cronExpression) != nil {
+		return errors.New("Invalid cron expression")
+	}
+
+	if retention < 0 {
+		return errors.New("Invalid retention, it must be positive or 0 to keep every backup")
+	}
+
+	switch destination.Type {
+	case portainer.LocalBackupDestination:
+		if !filepath.IsAbs(destination.LocalPath) {
+			return errors.New("Invalid local path, it must be an absolute path")
+		}
+	case portainer.S3BackupDestination:
+		if destination.S3 == nil || govalidator.IsNull(destination.S3.BucketName) {
+			return errors.New("Invalid S3 bucket name")
+		}
+
+		if govalidator.IsNull(destination.S3.AccessKeyID) || govalidator.IsNull(destination.S3.SecretAccessKey) {
+			return errors.New("Invalid S3 credentials")
+		}
+
+		if destination.S3.Endpoint != "" && !govalidator.IsURL(destination.S3.Endpoint) {
+			return errors.New("Invalid S3 endpoint URL")
+		}
+	default:
+		return errors.New("Invalid destination type. Value must be one of: 1 (local directory) or 2 (S3-compatible bucket)")
+	}
+
+	return nil
+}
+
+// @id BackupScheduleCreate
+// @summary Create a backup schedule
+// @description Create a schedule of automatic backups stored in a local directory or an S3-compatible bucket.
+// @description **Access policy**: administrator
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param body body backupScheduleCreatePayload true "Backup schedule details"
+// @success 200 {object} portainer.BackupSchedule "Success"
+// @failure 400 "Invalid request, or a password is given while backup recipients are registered"
+// @failure 500 "Server error"
+// @router /backup/schedules [post]
+func (h *Handler) backupScheduleCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload backupScheduleCreatePayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	publicKeys, err := operations.RecipientPublicKeys(h.dataStore)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the backup recipients from the database", err)
+	}
+
+	if httpErr := validateBackupPassword(payload.Password, publicKeys); httpErr != nil {
+		return httpErr
+	}
+
+	schedule := &portainer.BackupSchedule{
+		Name:           payload.Name,
+		CronExpression: payload.CronExpression,
+		Password:       payload.Password,
+		Retention:      payload.Retention,
+		Destination:    payload.Destination,
+	}
+
+	err = h.dataStore.BackupSchedule().Create(schedule)
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist the backup schedule inside the database", err)
+	}
+
+	err = h.scheduleService.Schedule(schedule)
+	if err != nil {
+		return httperror.InternalServerError("Unable to schedule the backup schedule", err)
+	}
+
+	hideBackupScheduleFields(schedule)
+	return response.JSON(w, schedule)
+}
+
+// hideBackupScheduleFields removes the secrets of a backup schedule
+func hideBackupScheduleFields(schedule *portainer.BackupSchedule) {
+	schedule.Password = ""
+
+	if schedule.Destination.S3 != nil {
+		s3 := *schedule.Destination.S3
+		s3.SecretAccessKey = ""
+		schedule.Destination.S3 = &s3
+	}
+}
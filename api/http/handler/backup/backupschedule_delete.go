@@ -0,0 +1,59 @@
+package backup
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+)
+
+// @id BackupScheduleDelete
+// @summary Remove a backup schedule
+// @description Remove a schedule of automatic backups and the history of its runs.
+// @description The archives stored at the destination are kept.
+// @description **Access policy**: administrator
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @param id path int true "Backup schedule identifier"
+// @success 204 "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "Backup schedule not found"
+// @failure 500 "Server error"
+// @router /backup/schedules/{id} [delete]
+func (h *Handler) backupScheduleDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	scheduleID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid backup schedule identifier route variable", err)
+	}
+
+	schedule, err := h.dataStore.BackupSchedule().BackupSchedule(portainer.BackupScheduleID(scheduleID))
+	if h.dataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a backup schedule with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a backup schedule with the specified identifier inside the database", err)
+	}
+
+	h.scheduleService.Unschedule(schedule.ID)
+
+	runs, err := h.dataStore.BackupRun().BackupRunsByScheduleID(schedule.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve backup runs from the database", err)
+	}
+
+	for _, run := range runs {
+		err = h.dataStore.BackupRun().DeleteBackupRun(run.ID)
+		if err != nil {
+			return httperror.InternalServerError("Unable to remove the backup run from the database", err)
+		}
+	}
+
+	err = h.dataStore.BackupSchedule().DeleteBackupSchedule(schedule.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to remove the backup schedule from the database", err)
+	}
+
+	return response.Empty(w)
+}
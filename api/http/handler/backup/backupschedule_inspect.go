@@ -0,0 +1,41 @@
+package backup
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+)
+
+// @id BackupScheduleInspect
+// @summary Inspect a backup schedule
+// @description Retrieve details about a schedule of automatic backups.
+// @description **Access policy**: administrator
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Backup schedule identifier"
+// @success 200 {object} portainer.BackupSchedule "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "Backup schedule not found"
+// @failure 500 "Server error"
+// @router /backup/schedules/{id} [get]
+func (h *Handler) backupScheduleInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	scheduleID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid backup schedule identifier route variable", err)
+	}
+
+	schedule, err := h.dataStore.BackupSchedule().BackupSchedule(portainer.BackupScheduleID(scheduleID))
+	if h.dataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a backup schedule with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a backup schedule with the specified identifier inside the database", err)
+	}
+
+	hideBackupScheduleFields(schedule)
+	return response.JSON(w, schedule)
+}
@@ -0,0 +1,32 @@
+package backup
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+// @id BackupScheduleList
+// @summary List backup schedules
+// @description List the schedules of automatic backups.
+// @description **Access policy**: administrator
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @success 200 {array} portainer.BackupSchedule "Success"
+// @failure 500 "Server error"
+// @router /backup/schedules [get]
+func (h *Handler) backupScheduleList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	schedules, err := h.dataStore.BackupSchedule().BackupSchedules()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve backup schedules from the database", err)
+	}
+
+	for idx := range schedules {
+		hideBackupScheduleFields(&schedules[idx])
+	}
+
+	return response.JSON(w, schedules)
+}
@@ -0,0 +1,50 @@
+package backup
+
+import (
+	"errors"
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+	operations "github.com/portainer/portainer/api/backup"
+)
+
+// @id BackupScheduleRun
+// @summary Run a backup schedule
+// @description Run a schedule of automatic backups immediately, the response is sent when the backup is over.
+// @description **Access policy**: administrator
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Backup schedule identifier"
+// @success 200 {object} portainer.BackupRun "Success, the run status tells whether the backup failed"
+// @failure 400 "Invalid request"
+// @failure 404 "Backup schedule not found"
+// @failure 409 "A backup of this schedule is already running"
+// @failure 500 "Server error"
+// @router /backup/schedules/{id}/run [post]
+func (h *Handler) backupScheduleRun(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	scheduleID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid backup schedule identifier route variable", err)
+	}
+
+	_, err = h.dataStore.BackupSchedule().BackupSchedule(portainer.BackupScheduleID(scheduleID))
+	if h.dataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a backup schedule with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a backup schedule with the specified identifier inside the database", err)
+	}
+
+	run, err := h.scheduleService.Run(portainer.BackupScheduleID(scheduleID))
+	if errors.Is(err, operations.ErrBackupRunning) {
+		return httperror.NewError(http.StatusConflict, "A backup of this schedule is already running", err)
+	} else if run == nil {
+		return httperror.InternalServerError("Unable to run the backup schedule", err)
+	}
+
+	return response.JSON(w, run)
+}
@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"errors"
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+	operations "github.com/portainer/portainer/api/backup"
+)
+
+type backupScheduleUpdatePayload struct {
+	// Name of the backup schedule
+	Name *string `example:"nightly"`
+	// Standard 5 fields cron expression of the backup times
+	CronExpression *string `example:"0 2 * * *"`
+	// Password used to encrypt the archives, an empty password disables the encryption
+	Password *string `example:"secret"`
+	// Number of successful backups kept at the destination, every backup is kept when 0
+	Retention *int `example:"7"`
+	// Destination of the archives. The current S3 secret access key is kept when the secret access key is empty
+	Destination *portainer.BackupDestination
+}
+
+func (payload *backupScheduleUpdatePayload) Validate(r *http.Request) error {
+	if payload.Name != nil && *payload.Name == "" {
+		return errors.New("Invalid backup schedule name")
+	}
+
+	return nil
+}
+
+// @id BackupScheduleUpdate
+// @summary Update a backup schedule
+// @description Update a schedule of automatic backups.
+// @description **Access policy**: administrator
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "Backup schedule identifier"
+// @param body body backupScheduleUpdatePayload true "Backup schedule details"
+// @success 200 {object} portainer.BackupSchedule "Success"
+// @failure 400 "Invalid request, or a password is given while backup recipients are registered"
+// @failure 404 "Backup schedule not found"
+// @failure 500 "Server error"
+// @router /backup/schedules/{id} [put]
+func (h *Handler) backupScheduleUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	scheduleID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid backup schedule identifier route variable", err)
+	}
+
+	var payload backupScheduleUpdatePayload
+	err = request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	schedule, err := h.dataStore.BackupSchedule().BackupSchedule(portainer.BackupScheduleID(scheduleID))
+	if h.dataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find a backup schedule with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find a backup schedule with the specified identifier inside the database", err)
+	}
+
+	if payload.Name != nil {
+		schedule.Name = *payload.Name
+	}
+
+	if payload.CronExpression != nil {
+		schedule.CronExpression = *payload.CronExpression
+	}
+
+	if payload.Password != nil {
+		publicKeys, err := operations.RecipientPublicKeys(h.dataStore)
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve the backup recipients from the database", err)
+		}
+
+		if httpErr := validateBackupPassword(*payload.Password, publicKeys); httpErr != nil {
+			return httpErr
+		}
+
+		schedule.Password = *payload.Password
+	}
+
+	if payload.Retention != nil {
+		schedule.Retention = *payload.Retention
+	}
+
+	if payload.Destination != nil {
+		destination := *payload.Destination
+		if destination.S3 != nil && destination.S3.SecretAccessKey == "" && schedule.Destination.S3 != nil {
+			s3 := *destination.S3
+			s3.SecretAccessKey = schedule.Destination.S3.SecretAccessKey
+			destination.S3 = &s3
+		}
+
+		schedule.Destination = destination
+	}
+
+	err = validateScheduleSettings(schedule.CronExpression, schedule.Retention, schedule.Destination)
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	err = h.dataStore.BackupSchedule().UpdateBackupSchedule(schedule.ID, schedule)
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist backup schedule changes inside the database", err)
+	}
+
+	err = h.scheduleService.Schedule(schedule)
+	if err != nil {
+		return httperror.InternalServerError("Unable to schedule the backup schedule", err)
+	}
+
+	hideBackupScheduleFields(schedule)
+	return response.JSON(w, schedule)
+}
@@ -8,11 +8,15 @@ import (
 	httperror "github.com/portainer/libhttp/error"
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/adminmonitor"
+	"github.com/portainer/portainer/api/apikey"
+	operations "github.com/portainer/portainer/api/backup"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/demo"
 	"github.com/portainer/portainer/api/http/middlewares"
 	"github.com/portainer/portainer/api/http/offlinegate"
+	"github.com/portainer/portainer/api/http/proxy"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/authorization"
 )
 
 // Handler is an http handler responsible for backup and restore portainer state
@@ -24,6 +28,11 @@ type Handler struct {
 	filestorePath   string
 	shutdownTrigger context.CancelFunc
 	adminMonitor    *adminmonitor.Monitor
+	scheduleService *operations.ScheduleService
+
+	ProxyManager         *proxy.Manager
+	AuthorizationService *authorization.Service
+	APIKeyService        apikey.APIKeyService
 }
 
 // NewHandler creates an new instance of backup handler
@@ -35,7 +44,7 @@ func NewHandler(
 	shutdownTrigger context.CancelFunc,
 	adminMonitor *adminmonitor.Monitor,
 	demoService *demo.Service,
-
+	scheduleService *operations.ScheduleService,
 ) *Handler {
 
 	h := &Handler{
@@ -46,6 +55,7 @@ func NewHandler(
 		filestorePath:   filestorePath,
 		shutdownTrigger: shutdownTrigger,
 		adminMonitor:    adminMonitor,
+		scheduleService: scheduleService,
 	}
 
 	demoRestrictedRouter := h.NewRoute().Subrouter()
@@ -53,6 +63,17 @@ func NewHandler(
 
 	demoRestrictedRouter.Handle("/backup", bouncer.RestrictedAccess(adminAccess(httperror.LoggerHandler(h.backup)))).Methods(http.MethodPost)
 	demoRestrictedRouter.Handle("/restore", bouncer.PublicAccess(httperror.LoggerHandler(h.restore))).Methods(http.MethodPost)
+	demoRestrictedRouter.Handle("/backup/validate", bouncer.AdminAccess(httperror.LoggerHandler(h.backupValidate))).Methods(http.MethodPost)
+	demoRestrictedRouter.Handle("/restore/buckets", bouncer.AdminAccess(httperror.LoggerHandler(h.restoreBuckets))).Methods(http.MethodPost)
+
+	demoRestrictedRouter.Handle("/backup/schedules", bouncer.AdminAccess(httperror.LoggerHandler(h.backupScheduleList))).Methods(http.MethodGet)
+	demoRestrictedRouter.Handle("/backup/schedules", bouncer.AdminAccess(httperror.LoggerHandler(h.backupScheduleCreate))).Methods(http.MethodPost)
+	demoRestrictedRouter.Handle("/backup/schedules/{id}", bouncer.AdminAccess(httperror.LoggerHandler(h.backupScheduleInspect))).Methods(http.MethodGet)
+	demoRestrictedRouter.Handle("/backup/schedules/{id}", bouncer.AdminAccess(httperror.LoggerHandler(h.backupScheduleUpdate))).Methods(http.MethodPut)
+	demoRestrictedRouter.Handle("/backup/schedules/{id}", bouncer.AdminAccess(httperror.LoggerHandler(h.backupScheduleDelete))).Methods(http.MethodDelete)
+	demoRestrictedRouter.Handle("/backup/schedules/{id}/run", bouncer.AdminAccess(httperror.LoggerHandler(h.backupScheduleRun))).Methods(http.MethodPost)
+	demoRestrictedRouter.Handle("/backup/runs", bouncer.AdminAccess(httperror.LoggerHandler(h.backupRunList))).Methods(http.MethodGet)
+	demoRestrictedRouter.Handle("/backup/runs/{id}", bouncer.AdminAccess(httperror.LoggerHandler(h.backupRunInspect))).Methods(http.MethodGet)
 
 	return h
 }
@@ -15,6 +15,7 @@ type restorePayload struct {
 	FileContent []byte
 	FileName    string
 	Password    string
+	PrivateKey  string
 }
 
 // @id Restore
@@ -22,8 +23,10 @@ type restorePayload struct {
 // @description Triggers a system restore using provided backup file
 // @description **Access policy**: public
 // @tags backup
-// @accept json
-// @param restorePayload body restorePayload true "Restore request payload"
+// @accept multipart/form-data
+// @param file formData file true "Backup archive"
+// @param password formData string false "Password used to encrypt the archive"
+// @param privateKey formData string false "Private key matching one of the public keys the archive is encrypted to"
 // @success 200 "Success"
 // @failure 400 "Invalid request"
 // @failure 500 "Server error"
@@ -46,7 +49,7 @@ func (h *Handler) restore(w http.ResponseWriter, r *http.Request) *httperror.Han
 	}
 
 	var archiveReader io.Reader = bytes.NewReader(payload.FileContent)
-	err = operations.RestoreArchive(archiveReader, payload.Password, h.filestorePath, h.gate, h.dataStore, h.shutdownTrigger)
+	err = operations.RestoreArchive(archiveReader, payload.Password, payload.PrivateKey, h.filestorePath, h.gate, h.dataStore, h.shutdownTrigger)
 	if err != nil {
 		return httperror.InternalServerError("Failed to restore the backup", err)
 	}
@@ -64,5 +67,8 @@ func decodeForm(r *http.Request, p *restorePayload) error {
 
 	password, _ := request.RetrieveMultiPartFormValue(r, "password", true)
 	p.Password = password
+
+	privateKey, _ := request.RetrieveMultiPartFormValue(r, "privateKey", true)
+	p.PrivateKey = privateKey
 	return nil
 }
@@ -0,0 +1,112 @@
+package backup
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+	operations "github.com/portainer/portainer/api/backup"
+	"github.com/portainer/portainer/api/dataservices/endpoint"
+	"github.com/portainer/portainer/api/dataservices/team"
+	"github.com/portainer/portainer/api/dataservices/teammembership"
+	"github.com/portainer/portainer/api/dataservices/user"
+)
+
+// @id RestoreBuckets
+// @summary Restore selected buckets of a backup archive
+// @description Restore the objects of the selected buckets of a backup archive, along with their files, into the running instance.
+// @description The restored objects replace the objects with the same identifier, the other objects are kept.
+// @description For example, select stacks to restore the stacks with their compose files, or users, teams and team_membership
+// @description to restore the users and teams. The archive must have the database version of the instance.
+// @description **Access policy**: administrator
+// @tags backup
+// @security ApiKeyAuth
+// @security jwt
+// @accept multipart/form-data
+// @produce json
+// @param file formData file true "Backup archive"
+// @param password formData string false "Password used to encrypt the archive"
+// @param privateKey formData string false "Private key matching one of the public keys the archive is encrypted to"
+// @param buckets formData string true "Comma separated list of the buckets to restore"
+// @success 200 {object} map[string]int "Number of restored objects of each bucket"
+// @failure 400 "Invalid request"
+// @failure 500 "Server error"
+// @router /restore/buckets [post]
+func (h *Handler) restoreBuckets(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload restorePayload
+	err := decodeForm(r, &payload)
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	bucketsValue, err := request.RetrieveMultiPartFormValue(r, "buckets", false)
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	var buckets []string
+	for _, bucketName := range strings.Split(bucketsValue, ",") {
+		if bucketName = strings.TrimSpace(bucketName); bucketName != "" {
+			buckets = append(buckets, bucketName)
+		}
+	}
+
+	if len(buckets) == 0 {
+		return httperror.BadRequest("Invalid request payload", errors.New("no bucket selected"))
+	}
+
+	restored, err := operations.RestoreBuckets(bytes.NewReader(payload.FileContent), payload.Password, payload.PrivateKey, buckets, h.filestorePath, h.gate, h.dataStore)
+
+	// the objects are merged even when their files cannot be restored
+	if restored != nil {
+		reloadErr := h.reloadRestoredObjects(restored)
+		if reloadErr != nil {
+			return httperror.InternalServerError("Unable to reload the restored objects", reloadErr)
+		}
+	}
+
+	if err != nil {
+		return httperror.InternalServerError("Failed to restore the backup", err)
+	}
+
+	counts := make(map[string]int, len(restored))
+	for bucketName, ids := range restored {
+		counts[bucketName] = len(ids)
+	}
+
+	return response.JSON(w, counts)
+}
+
+// reloadRestoredObjects refreshes the environment proxies and the user authorizations and API keys
+// kept in memory for the restored objects, the same way as when the objects are updated
+func (h *Handler) reloadRestoredObjects(restored map[string][]int) error {
+	for _, id := range restored[endpoint.BucketName] {
+		endpoint, err := h.dataStore.Endpoint().Endpoint(portainer.EndpointID(id))
+		if err != nil {
+			return errors.Wrapf(err, "unable to retrieve the environment %d", id)
+		}
+
+		h.ProxyManager.DeleteEndpointProxy(endpoint.ID)
+		_, err = h.ProxyManager.CreateAndRegisterEndpointProxy(endpoint)
+		if err != nil {
+			return errors.Wrapf(err, "unable to register the HTTP proxy of the environment %d", id)
+		}
+	}
+
+	for _, id := range restored[user.BucketName] {
+		h.APIKeyService.InvalidateUserKeyCache(portainer.UserID(id))
+	}
+
+	for _, bucketName := range []string{endpoint.BucketName, team.BucketName, teammembership.BucketName, user.BucketName} {
+		if len(restored[bucketName]) > 0 {
+			return h.AuthorizationService.UpdateUsersAuthorizations()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package backup
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/internal/authorization"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_reloadRestoredObjects_updatesUserAuthorizations(t *testing.T) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	role := &portainer.Role{Name: "role", Priority: 1, Authorizations: portainer.Authorizations{portainer.OperationDockerContainerList: true}}
+	err := store.Role().Create(role)
+	require.NoError(t, err)
+
+	user := &portainer.User{Username: "standard", Role: portainer.StandardUserRole}
+	err = store.User().Create(user)
+	require.NoError(t, err)
+
+	// the restored environment gives access to the user, its authorizations are not computed yet
+	err = store.Endpoint().Create(&portainer.Endpoint{
+		ID:                 1,
+		URL:                "tcp://localhost:2375",
+		UserAccessPolicies: portainer.UserAccessPolicies{user.ID: {RoleID: role.ID}},
+	})
+	require.NoError(t, err)
+
+	h := &Handler{
+		dataStore:            store,
+		AuthorizationService: authorization.NewService(store),
+		APIKeyService:        apikey.NewAPIKeyService(store.APIKeyRepository(), store.User()),
+	}
+
+	err = h.reloadRestoredObjects(map[string][]int{"users": {int(user.ID)}})
+	require.NoError(t, err)
+
+	user, err = store.User().User(user.ID)
+	require.NoError(t, err)
+	assert.True(t, user.EndpointAuthorizations[1][portainer.OperationDockerContainerList])
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/etag"
 )
 
 // @id EdgeStackInspect
@@ -35,5 +36,6 @@ func (handler *Handler) edgeStackInspect(w http.ResponseWriter, r *http.Request)
 		return httperror.InternalServerError("Unable to find an edge stack with the specified identifier inside the database", err)
 	}
 
+	etag.Set(w, edgeStack.Revision)
 	return response.JSON(w, edgeStack)
 }
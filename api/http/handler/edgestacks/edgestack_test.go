@@ -69,7 +69,7 @@ func setupHandler(t *testing.T) (*Handler, string, func()) {
 	}
 
 	handler := NewHandler(
-		security.NewRequestBouncer(store, jwtService, apiKeyService),
+		security.NewRequestBouncer(store, jwtService, apiKeyService, nil),
 		store,
 	)
 
@@ -765,6 +765,71 @@ func TestUpdateWithInvalidPayload(t *testing.T) {
 	}
 }
 
+func TestUpdateWithIfMatch(t *testing.T) {
+	handler, rawAPIKey, teardown := setupHandler(t)
+	defer teardown()
+
+	endpoint := createEndpoint(t, handler.DataStore)
+	edgeStack := createEdgeStack(t, handler.DataStore, endpoint.ID)
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/edge_stacks/%d", edgeStack.ID), nil)
+	if err != nil {
+		t.Fatal("request error:", err)
+	}
+
+	req.Header.Add("x-api-key", rawAPIKey)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	etag := rec.Header().Get("ETag")
+	if etag != `"0"` {
+		t.Fatalf(`expected ETag "0", found: %s`, etag)
+	}
+
+	cases := []struct {
+		Name               string
+		ExpectedStatusCode int
+		ExpectedETag       string
+	}{
+		{"Up to date revision", http.StatusOK, `"1"`},
+		{"Stale revision", http.StatusPreconditionFailed, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			payload := updateEdgeStackPayload{
+				StackFileContent: "update-test",
+				EdgeGroups:       edgeStack.EdgeGroups,
+				DeploymentType:   portainer.EdgeStackDeploymentCompose,
+			}
+
+			jsonPayload, err := json.Marshal(payload)
+			if err != nil {
+				t.Fatal("JSON marshal error:", err)
+			}
+
+			r := bytes.NewBuffer(jsonPayload)
+			req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("/edge_stacks/%d", edgeStack.ID), r)
+			if err != nil {
+				t.Fatal("request error:", err)
+			}
+
+			req.Header.Add("x-api-key", rawAPIKey)
+			req.Header.Add("If-Match", etag)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.ExpectedStatusCode {
+				t.Fatalf("expected a %d response, found: %d", tc.ExpectedStatusCode, rec.Code)
+			}
+
+			if rec.Header().Get("ETag") != tc.ExpectedETag {
+				t.Fatalf("expected ETag %s, found: %s", tc.ExpectedETag, rec.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
 // Update Status
 func TestUpdateStatusAndInspect(t *testing.T) {
 	handler, rawAPIKey, teardown := setupHandler(t)
@@ -11,6 +11,7 @@ import (
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/filesystem"
+	"github.com/portainer/portainer/api/http/etag"
 	"github.com/portainer/portainer/api/internal/edge"
 )
 
@@ -40,10 +41,12 @@ func (payload *updateEdgeStackPayload) Validate(r *http.Request) error {
 // @accept json
 // @produce json
 // @param id path string true "EdgeStack Id"
+// @param If-Match header string false "ETag of the EdgeStack, the update is rejected if the EdgeStack was modified since"
 // @param body body updateEdgeStackPayload true "EdgeStack data"
 // @success 200 {object} portainer.EdgeStack
 // @failure 500
 // @failure 400
+// @failure 412 "EdgeStack modified since the revision of the If-Match header"
 // @failure 503 "Edge compute features are disabled"
 // @router /edge_stacks/{id} [put]
 func (handler *Handler) edgeStackUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -52,6 +55,9 @@ func (handler *Handler) edgeStackUpdate(w http.ResponseWriter, r *http.Request)
 		return httperror.BadRequest("Invalid stack identifier route variable", err)
 	}
 
+	unlock := etag.Lock("edge_stack", stackID)
+	defer unlock()
+
 	stack, err := handler.DataStore.EdgeStack().EdgeStack(portainer.EdgeStackID(stackID))
 	if handler.DataStore.IsErrObjectNotFound(err) {
 		return httperror.NotFound("Unable to find a stack with the specified identifier inside the database", err)
@@ -59,6 +65,10 @@ func (handler *Handler) edgeStackUpdate(w http.ResponseWriter, r *http.Request)
 		return httperror.InternalServerError("Unable to find a stack with the specified identifier inside the database", err)
 	}
 
+	if httpErr := etag.CheckIfMatch(r, stack.Revision); httpErr != nil {
+		return httpErr
+	}
+
 	var payload updateEdgeStackPayload
 	err = request.DecodeAndValidateJSONPayload(r, &payload)
 	if err != nil {
@@ -188,5 +198,6 @@ func (handler *Handler) edgeStackUpdate(w http.ResponseWriter, r *http.Request)
 		return httperror.InternalServerError("Unable to persist the stack changes inside the database", err)
 	}
 
+	etag.Set(w, stack.Revision)
 	return response.JSON(w, stack)
 }
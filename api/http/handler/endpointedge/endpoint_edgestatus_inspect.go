@@ -90,7 +90,7 @@ func (handler *Handler) endpointEdgeStatusInspect(w http.ResponseWriter, r *http
 
 	endpoint.LastCheckInDate = time.Now().Unix()
 
-	err = handler.DataStore.Endpoint().UpdateEndpoint(endpoint.ID, endpoint)
+	err = handler.DataStore.Endpoint().UpdateEndpointStatus(endpoint.ID, endpoint)
 	if err != nil {
 		return httperror.InternalServerError("Unable to Unable to persist environment changes inside the database", err)
 	}
@@ -112,7 +112,7 @@ func setupHandler(t *testing.T) (*Handler, func(), error) {
 	}
 
 	handler := NewHandler(
-		security.NewRequestBouncer(store, jwtService, apiKeyService),
+		security.NewRequestBouncer(store, jwtService, apiKeyService, nil),
 		store,
 		fs,
 		chisel.NewService(store, shutdownCtx),
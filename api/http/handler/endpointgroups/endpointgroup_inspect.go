@@ -7,6 +7,7 @@ import (
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/etag"
 )
 
 // @summary Inspect an Environment(Endpoint) group
@@ -36,5 +37,6 @@ func (handler *Handler) endpointGroupInspect(w http.ResponseWriter, r *http.Requ
 		return httperror.InternalServerError("Unable to find an environment group with the specified identifier inside the database", err)
 	}
 
+	etag.Set(w, endpointGroup.Revision)
 	return response.JSON(w, endpointGroup)
 }
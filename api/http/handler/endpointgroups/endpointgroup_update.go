@@ -8,6 +8,7 @@ import (
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/etag"
 	"github.com/portainer/portainer/api/internal/tag"
 )
 
@@ -36,10 +37,12 @@ func (payload *endpointGroupUpdatePayload) Validate(r *http.Request) error {
 // @accept json
 // @produce json
 // @param id path int true "EndpointGroup identifier"
+// @param If-Match header string false "ETag of the EndpointGroup, the update is rejected if the EndpointGroup was modified since"
 // @param body body endpointGroupUpdatePayload true "EndpointGroup details"
 // @success 200 {object} portainer.EndpointGroup "Success"
 // @failure 400 "Invalid request"
 // @failure 404 "EndpointGroup not found"
+// @failure 412 "EndpointGroup modified since the revision of the If-Match header"
 // @failure 500 "Server error"
 // @router /endpoint_groups/{id} [put]
 func (handler *Handler) endpointGroupUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -54,6 +57,9 @@ func (handler *Handler) endpointGroupUpdate(w http.ResponseWriter, r *http.Reque
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	unlock := etag.Lock("endpoint_groups", endpointGroupID)
+	defer unlock()
+
 	endpointGroup, err := handler.DataStore.EndpointGroup().EndpointGroup(portainer.EndpointGroupID(endpointGroupID))
 	if handler.DataStore.IsErrObjectNotFound(err) {
 		return httperror.NotFound("Unable to find an environment group with the specified identifier inside the database", err)
@@ -61,6 +67,10 @@ func (handler *Handler) endpointGroupUpdate(w http.ResponseWriter, r *http.Reque
 		return httperror.InternalServerError("Unable to find an environment group with the specified identifier inside the database", err)
 	}
 
+	if httpErr := etag.CheckIfMatch(r, endpointGroup.Revision); httpErr != nil {
+		return httpErr
+	}
+
 	if payload.Name != "" {
 		endpointGroup.Name = payload.Name
 	}
@@ -160,5 +170,6 @@ func (handler *Handler) endpointGroupUpdate(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	etag.Set(w, endpointGroup.Revision)
 	return response.JSON(w, endpointGroup)
 }
@@ -5,6 +5,7 @@ import (
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/middlewares"
 	"strconv"
 	"strings"
 
@@ -56,6 +57,7 @@ func (handler *Handler) proxyRequestsToDockerAPI(w http.ResponseWriter, r *http.
 		prefix = "/" + id + "/docker"
 	}
 
-	http.StripPrefix(prefix, proxy).ServeHTTP(w, r)
+	// the transport answers from the snapshot of the environment when it is down
+	http.StripPrefix(prefix, proxy).ServeHTTP(w, middlewares.StoreEndpoint(r, endpoint))
 	return nil
 }
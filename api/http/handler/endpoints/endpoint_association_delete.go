@@ -59,6 +59,11 @@ func (handler *Handler) endpointAssociationDelete(w http.ResponseWriter, r *http
 		return httperror.InternalServerError("Failed persisting environment in database", err)
 	}
 
+	err = handler.DataStore.KubernetesSnapshotRaw().DeleteSnapshotRaw(endpoint.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to remove environment Kubernetes snapshot from the database", err)
+	}
+
 	handler.ReverseTunnelService.SetTunnelStatusToIdle(endpoint.ID)
 
 	return response.Empty(w)
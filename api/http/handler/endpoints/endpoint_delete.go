@@ -61,6 +61,16 @@ func (handler *Handler) endpointDelete(w http.ResponseWriter, r *http.Request) *
 		return httperror.InternalServerError("Unable to remove environment relation from the database", err)
 	}
 
+	err = handler.DataStore.SnapshotHistory().DeleteEntriesByEndpointID(endpoint.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to remove environment snapshot history from the database", err)
+	}
+
+	err = handler.DataStore.KubernetesSnapshotRaw().DeleteSnapshotRaw(endpoint.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to remove environment Kubernetes snapshot from the database", err)
+	}
+
 	for _, tagID := range endpoint.TagIDs {
 		tag, err := handler.DataStore.Tag().Tag(tagID)
 		if err != nil {
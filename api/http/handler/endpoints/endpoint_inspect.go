@@ -7,6 +7,7 @@ import (
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/etag"
 )
 
 // @id EndpointInspect
@@ -44,5 +45,6 @@ func (handler *Handler) endpointInspect(w http.ResponseWriter, r *http.Request)
 	hideFields(endpoint)
 	endpoint.ComposeSyntaxMaxVersion = handler.ComposeStackManager.ComposeSyntaxMaxVersion()
 
+	etag.Set(w, endpoint.Revision)
 	return response.JSON(w, endpoint)
 }
@@ -0,0 +1,68 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/internal/endpointutils"
+)
+
+type kubernetesSnapshotInspectResponse struct {
+	portainer.KubernetesSnapshot
+	// The raw resources are stored apart from the environment
+	SnapshotRaw portainer.KubernetesSnapshotRaw `json:"KubernetesSnapshotRaw"`
+}
+
+// @id EndpointKubernetesSnapshotInspect
+// @summary Inspect the latest snapshot of a Kubernetes environment(endpoint)
+// @description Retrieve the latest snapshot of a Kubernetes environment(endpoint), including the raw resources
+// @description returned by the Kubernetes API. It can be used to browse the cluster while it is unreachable.
+// @description **Access policy**: administrator
+// @tags endpoints
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Environment(Endpoint) identifier"
+// @success 200 {object} kubernetesSnapshotInspectResponse "Success"
+// @failure 400 "Invalid request"
+// @failure 404 "Environment(Endpoint) or snapshot not found"
+// @failure 500 "Server error"
+// @router /endpoints/{id}/kubernetes/snapshot [get]
+func (handler *Handler) endpointKubernetesSnapshotInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid environment identifier route variable", err)
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find an environment with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find an environment with the specified identifier inside the database", err)
+	}
+
+	if !endpointutils.IsKubernetesEndpoint(endpoint) {
+		return httperror.BadRequest("Invalid environment type", errors.New("the environment is not a Kubernetes environment"))
+	}
+
+	if len(endpoint.Kubernetes.Snapshots) == 0 {
+		return httperror.NotFound("Unable to find a snapshot for this environment", errors.New("the environment has not been snapshotted yet"))
+	}
+
+	snapshot := kubernetesSnapshotInspectResponse{KubernetesSnapshot: endpoint.Kubernetes.Snapshots[len(endpoint.Kubernetes.Snapshots)-1]}
+
+	snapshotRaw, err := handler.DataStore.KubernetesSnapshotRaw().SnapshotRaw(endpoint.ID)
+	if err != nil && !handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.InternalServerError("Unable to retrieve the raw snapshot of the environment from the database", err)
+	}
+
+	if snapshotRaw != nil {
+		snapshot.SnapshotRaw = *snapshotRaw
+	}
+
+	return response.JSON(w, snapshot)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/etag"
 )
 
 type endpointSettingsUpdatePayload struct {
@@ -44,10 +45,12 @@ func (payload *endpointSettingsUpdatePayload) Validate(r *http.Request) error {
 // @accept json
 // @produce json
 // @param id path int true "Environment(Endpoint) identifier"
+// @param If-Match header string false "ETag of the environment(endpoint), the update is rejected if the environment(endpoint) was modified since"
 // @param body body endpointSettingsUpdatePayload true "Environment(Endpoint) details"
 // @success 200 {object} portainer.Endpoint "Success"
 // @failure 400 "Invalid request"
 // @failure 404 "Environment(Endpoint) not found"
+// @failure 412 "Environment(Endpoint) modified since the revision of the If-Match header"
 // @failure 500 "Server error"
 // @router /endpoints/{id}/settings [put]
 func (handler *Handler) endpointSettingsUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -62,6 +65,9 @@ func (handler *Handler) endpointSettingsUpdate(w http.ResponseWriter, r *http.Re
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	unlock := etag.Lock("endpoints", endpointID)
+	defer unlock()
+
 	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
 	if handler.DataStore.IsErrObjectNotFound(err) {
 		return httperror.NotFound("Unable to find an environment with the specified identifier inside the database", err)
@@ -69,6 +75,10 @@ func (handler *Handler) endpointSettingsUpdate(w http.ResponseWriter, r *http.Re
 		return httperror.InternalServerError("Unable to find an environment with the specified identifier inside the database", err)
 	}
 
+	if httpErr := etag.CheckIfMatch(r, endpoint.Revision); httpErr != nil {
+		return httpErr
+	}
+
 	securitySettings := endpoint.SecuritySettings
 
 	if payload.AllowBindMountsForRegularUsers != nil {
@@ -114,5 +124,6 @@ func (handler *Handler) endpointSettingsUpdate(w http.ResponseWriter, r *http.Re
 		return httperror.InternalServerError("Failed persisting environment in database", err)
 	}
 
+	etag.Set(w, endpoint.Revision)
 	return response.JSON(w, endpoint)
 }
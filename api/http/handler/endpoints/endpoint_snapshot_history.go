@@ -0,0 +1,117 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+)
+
+var supportedSnapshotHistoryMetrics = []portainer.SnapshotHistoryMetric{
+	portainer.SnapshotHistoryMetricRunningContainers,
+	portainer.SnapshotHistoryMetricStoppedContainers,
+	portainer.SnapshotHistoryMetricHealthyContainers,
+	portainer.SnapshotHistoryMetricUnhealthyContainers,
+	portainer.SnapshotHistoryMetricCPU,
+	portainer.SnapshotHistoryMetricMemory,
+	portainer.SnapshotHistoryMetricImages,
+	portainer.SnapshotHistoryMetricVolumes,
+	portainer.SnapshotHistoryMetricNodes,
+}
+
+// @id EndpointSnapshotHistory
+// @summary Retrieve the snapshot history of an environment(endpoint)
+// @description Retrieve the metrics recorded by the snapshots of an environment(endpoint) over a period of time.
+// @description Entries older than the downsampling age of the snapshot history settings hold the average of each metric over their resolution.
+// @description **Access policy**: restricted
+// @tags endpoints
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "Environment(Endpoint) identifier"
+// @param from query int false "Start of the period (unix timestamp), defaults to 24 hours ago"
+// @param to query int false "End of the period (unix timestamp), defaults to now"
+// @param metric query string false "Only return this metric" Enums(runningContainers, stoppedContainers, healthyContainers, unhealthyContainers, cpu, memory, images, volumes, nodes)
+// @success 200 {array} portainer.SnapshotHistoryEntry "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "Environment(Endpoint) not found"
+// @failure 500 "Server error"
+// @router /endpoints/{id}/snapshots/history [get]
+func (handler *Handler) endpointSnapshotHistory(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	endpointID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid environment identifier route variable", err)
+	}
+
+	now := time.Now()
+
+	from, err := request.RetrieveNumericQueryParameter(r, "from", true)
+	if err != nil {
+		return httperror.BadRequest("Invalid query parameter: from", err)
+	}
+	if from == 0 {
+		from = int(now.Add(-24 * time.Hour).Unix())
+	}
+
+	to, err := request.RetrieveNumericQueryParameter(r, "to", true)
+	if err != nil {
+		return httperror.BadRequest("Invalid query parameter: to", err)
+	}
+	if to == 0 {
+		to = int(now.Unix())
+	}
+
+	if from > to {
+		return httperror.BadRequest("Invalid query parameters", errors.New("from must be before to"))
+	}
+
+	metric, _ := request.RetrieveQueryParameter(r, "metric", true)
+	if metric != "" && !isSupportedSnapshotHistoryMetric(portainer.SnapshotHistoryMetric(metric)) {
+		return httperror.BadRequest("Invalid query parameter: metric", errors.New("unsupported metric"))
+	}
+
+	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.NotFound("Unable to find an environment with the specified identifier inside the database", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to find an environment with the specified identifier inside the database", err)
+	}
+
+	err = handler.requestBouncer.AuthorizedEndpointOperation(r, endpoint)
+	if err != nil {
+		return httperror.Forbidden("Permission denied to access environment", err)
+	}
+
+	entries, err := handler.DataStore.SnapshotHistory().EntriesByEndpointID(endpoint.ID, int64(from), int64(to))
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the environment snapshot history from the database", err)
+	}
+
+	if metric != "" {
+		for i := range entries {
+			value, ok := entries[i].Metrics[portainer.SnapshotHistoryMetric(metric)]
+
+			entries[i].Metrics = map[portainer.SnapshotHistoryMetric]float64{}
+			if ok {
+				entries[i].Metrics[portainer.SnapshotHistoryMetric(metric)] = value
+			}
+		}
+	}
+
+	return response.JSON(w, entries)
+}
+
+func isSupportedSnapshotHistoryMetric(metric portainer.SnapshotHistoryMetric) bool {
+	for _, supportedMetric := range supportedSnapshotHistoryMetrics {
+		if metric == supportedMetric {
+			return true
+		}
+	}
+
+	return false
+}
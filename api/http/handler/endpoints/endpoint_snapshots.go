@@ -60,7 +60,7 @@ func (handler *Handler) endpointSnapshots(w http.ResponseWriter, r *http.Request
 		latestEndpointReference.Kubernetes.Snapshots = endpoint.Kubernetes.Snapshots
 		latestEndpointReference.Agent.Version = endpoint.Agent.Version
 
-		err = handler.DataStore.Endpoint().UpdateEndpoint(latestEndpointReference.ID, latestEndpointReference)
+		err = handler.DataStore.Endpoint().UpdateEndpointStatus(latestEndpointReference.ID, latestEndpointReference)
 		if err != nil {
 			return httperror.InternalServerError("Unable to persist environment changes inside the database", err)
 		}
@@ -1,15 +1,18 @@
 package endpoints
 
 import (
+	"errors"
 	"net/http"
 	"reflect"
 	"strconv"
+	"time"
 
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/http/client"
+	"github.com/portainer/portainer/api/http/etag"
 	"github.com/portainer/portainer/api/internal/edge"
 	"github.com/portainer/portainer/api/internal/tag"
 )
@@ -46,11 +49,20 @@ type endpointUpdatePayload struct {
 	TeamAccessPolicies portainer.TeamAccessPolicies
 	// The check in interval for edge agent (in seconds)
 	EdgeCheckinInterval *int `example:"5"`
+	// The interval in which the environment(endpoint) is snapshotted, an empty value uses the global snapshot interval
+	SnapshotInterval *string `example:"10m"`
 	// Associated Kubernetes data
 	Kubernetes *portainer.KubernetesData
 }
 
 func (payload *endpointUpdatePayload) Validate(r *http.Request) error {
+	if payload.SnapshotInterval != nil && *payload.SnapshotInterval != "" {
+		interval, err := time.ParseDuration(*payload.SnapshotInterval)
+		if err != nil || interval <= 0 {
+			return errors.New("Invalid snapshot interval")
+		}
+	}
+
 	return nil
 }
 
@@ -64,10 +76,12 @@ func (payload *endpointUpdatePayload) Validate(r *http.Request) error {
 // @accept json
 // @produce json
 // @param id path int true "Environment(Endpoint) identifier"
+// @param If-Match header string false "ETag of the environment(endpoint), the update is rejected if the environment(endpoint) was modified since"
 // @param body body endpointUpdatePayload true "Environment(Endpoint) details"
 // @success 200 {object} portainer.Endpoint "Success"
 // @failure 400 "Invalid request"
 // @failure 404 "Environment(Endpoint) not found"
+// @failure 412 "Environment(Endpoint) modified since the revision of the If-Match header"
 // @failure 500 "Server error"
 // @router /endpoints/{id} [put]
 func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -82,6 +96,9 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	unlock := etag.Lock("endpoints", endpointID)
+	defer unlock()
+
 	endpoint, err := handler.DataStore.Endpoint().Endpoint(portainer.EndpointID(endpointID))
 	if handler.DataStore.IsErrObjectNotFound(err) {
 		return httperror.NotFound("Unable to find an environment with the specified identifier inside the database", err)
@@ -89,6 +106,10 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 		return httperror.InternalServerError("Unable to find an environment with the specified identifier inside the database", err)
 	}
 
+	if httpErr := etag.CheckIfMatch(r, endpoint.Revision); httpErr != nil {
+		return httpErr
+	}
+
 	if payload.Name != nil {
 		name := *payload.Name
 		isUnique, err := handler.isNameUnique(name, endpoint.ID)
@@ -120,6 +141,10 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 		endpoint.EdgeCheckinInterval = *payload.EdgeCheckinInterval
 	}
 
+	if payload.SnapshotInterval != nil {
+		endpoint.SnapshotInterval = *payload.SnapshotInterval
+	}
+
 	groupIDChanged := false
 	if payload.GroupID != nil {
 		groupID := portainer.EndpointGroupID(*payload.GroupID)
@@ -329,5 +354,6 @@ func (handler *Handler) endpointUpdate(w http.ResponseWriter, r *http.Request) *
 		}
 	}
 
+	etag.Set(w, endpoint.Revision)
 	return response.JSON(w, endpoint)
 }
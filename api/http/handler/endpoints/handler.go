@@ -80,6 +80,10 @@ func NewHandler(bouncer requestBouncer, demoService *demo.Service) *Handler {
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.endpointDockerhubStatus))).Methods(http.MethodGet)
 	h.Handle("/endpoints/{id}/snapshot",
 		bouncer.AdminAccess(httperror.LoggerHandler(h.endpointSnapshot))).Methods(http.MethodPost)
+	h.Handle("/endpoints/{id}/kubernetes/snapshot",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.endpointKubernetesSnapshotInspect))).Methods(http.MethodGet)
+	h.Handle("/endpoints/{id}/snapshots/history",
+		bouncer.RestrictedAccess(httperror.LoggerHandler(h.endpointSnapshotHistory))).Methods(http.MethodGet)
 	h.Handle("/endpoints/{id}/registries",
 		bouncer.AuthenticatedAccess(httperror.LoggerHandler(h.endpointRegistriesList))).Methods(http.MethodGet)
 	h.Handle("/endpoints/{id}/registries/{registryId}",
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/portainer/portainer/api/http/handler/auditlogs"
 	"github.com/portainer/portainer/api/http/handler/auth"
 	"github.com/portainer/portainer/api/http/handler/backup"
 	"github.com/portainer/portainer/api/http/handler/customtemplates"
@@ -27,10 +28,12 @@ import (
 	"github.com/portainer/portainer/api/http/handler/registries"
 	"github.com/portainer/portainer/api/http/handler/resourcecontrols"
 	"github.com/portainer/portainer/api/http/handler/roles"
+	"github.com/portainer/portainer/api/http/handler/scim"
 	"github.com/portainer/portainer/api/http/handler/settings"
 	"github.com/portainer/portainer/api/http/handler/ssl"
 	"github.com/portainer/portainer/api/http/handler/stacks"
 	"github.com/portainer/portainer/api/http/handler/status"
+	"github.com/portainer/portainer/api/http/handler/store"
 	"github.com/portainer/portainer/api/http/handler/storybook"
 	"github.com/portainer/portainer/api/http/handler/tags"
 	"github.com/portainer/portainer/api/http/handler/teammemberships"
@@ -44,6 +47,7 @@ import (
 
 // Handler is a collection of all the service handlers.
 type Handler struct {
+	AuditLogHandler           *auditlogs.Handler
 	AuthHandler               *auth.Handler
 	BackupHandler             *backup.Handler
 	CustomTemplatesHandler    *customtemplates.Handler
@@ -66,12 +70,14 @@ type Handler struct {
 	RegistryHandler           *registries.Handler
 	ResourceControlHandler    *resourcecontrols.Handler
 	RoleHandler               *roles.Handler
+	SCIMHandler               *scim.Handler
 	SettingsHandler           *settings.Handler
 	SSLHandler                *ssl.Handler
 	OpenAMTHandler            *openamt.Handler
 	FDOHandler                *fdo.Handler
 	StackHandler              *stacks.Handler
 	StatusHandler             *status.Handler
+	StoreHandler              *store.Handler
 	StorybookHandler          *storybook.Handler
 	TagHandler                *tags.Handler
 	TeamMembershipHandler     *teammemberships.Handler
@@ -105,6 +111,8 @@ type Handler struct {
 // @in header
 // @name Authorization
 
+// @tag.name audit
+// @tag.description Browse and export the audit log
 // @tag.name auth
 // @tag.description Authenticate against Portainer HTTP API
 // @tag.name custom_templates
@@ -161,6 +169,8 @@ type Handler struct {
 // ServeHTTP delegates a request to the appropriate subhandler.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/audit"):
+		http.StripPrefix("/api", h.AuditLogHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/auth"):
 		http.StripPrefix("/api", h.AuthHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/backup"):
@@ -217,12 +227,16 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.StripPrefix("/api", h.ResourceControlHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/roles"):
 		http.StripPrefix("/api", h.RoleHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/scim"):
+		http.StripPrefix("/api", h.SCIMHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/settings"):
 		http.StripPrefix("/api", h.SettingsHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/stacks"):
 		http.StripPrefix("/api", h.StackHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/status"):
 		http.StripPrefix("/api", h.StatusHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/store"):
+		http.StripPrefix("/api", h.StoreHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/tags"):
 		http.StripPrefix("/api", h.TagHandler).ServeHTTP(w, r)
 	case strings.HasPrefix(r.URL.Path, "/api/templates/helm"):
@@ -9,6 +9,7 @@ import (
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
 	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/etag"
 )
 
 // @id RegistryInspect
@@ -48,5 +49,6 @@ func (handler *Handler) registryInspect(w http.ResponseWriter, r *http.Request)
 	}
 
 	hideFields(registry, !isAdmin)
+	etag.Set(w, registry.Revision)
 	return response.JSON(w, registry)
 }
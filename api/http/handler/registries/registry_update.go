@@ -11,6 +11,7 @@ import (
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
 	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/etag"
 	"github.com/portainer/portainer/api/http/security"
 )
 
@@ -49,11 +50,13 @@ func (payload *registryUpdatePayload) Validate(r *http.Request) error {
 // @accept json
 // @produce json
 // @param id path int true "Registry identifier"
+// @param If-Match header string false "ETag of the registry, the update is rejected if the registry was modified since"
 // @param body body registryUpdatePayload true "Registry details"
 // @success 200 {object} portainer.Registry "Success"
 // @failure 400 "Invalid request"
 // @failure 404 "Registry not found"
 // @failure 409 "Another registry with the same URL already exists"
+// @failure 412 "Registry modified since the revision of the If-Match header"
 // @failure 500 "Server error"
 // @router /registries/{id} [put]
 func (handler *Handler) registryUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -71,6 +74,9 @@ func (handler *Handler) registryUpdate(w http.ResponseWriter, r *http.Request) *
 		return httperror.BadRequest("Invalid registry identifier route variable", err)
 	}
 
+	unlock := etag.Lock("registries", registryID)
+	defer unlock()
+
 	registry, err := handler.DataStore.Registry().Registry(portainer.RegistryID(registryID))
 	if handler.DataStore.IsErrObjectNotFound(err) {
 		return httperror.NotFound("Unable to find a registry with the specified identifier inside the database", err)
@@ -78,6 +84,10 @@ func (handler *Handler) registryUpdate(w http.ResponseWriter, r *http.Request) *
 		return httperror.InternalServerError("Unable to find a registry with the specified identifier inside the database", err)
 	}
 
+	if httpErr := etag.CheckIfMatch(r, registry.Revision); httpErr != nil {
+		return httpErr
+	}
+
 	registries, err := handler.DataStore.Registry().Registries()
 	if err != nil {
 		return httperror.InternalServerError("Unable to retrieve registries from the database", err)
@@ -180,6 +190,7 @@ func (handler *Handler) registryUpdate(w http.ResponseWriter, r *http.Request) *
 		return httperror.InternalServerError("Unable to persist registry changes inside the database", err)
 	}
 
+	etag.Set(w, registry.Revision)
 	return response.JSON(w, registry)
 }
 
@@ -0,0 +1,366 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// filter is a SCIM filter expression, evaluated against the JSON representation of a resource
+type filter interface {
+	match(resource map[string]interface{}) bool
+}
+
+type (
+	logicalFilter struct {
+		and         bool
+		left, right filter
+	}
+
+	notFilter struct {
+		filter filter
+	}
+
+	comparisonFilter struct {
+		path     []string
+		operator string
+		value    interface{}
+	}
+)
+
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true, "gt": true, "ge": true, "lt": true, "le": true, "pr": true,
+}
+
+func (f *logicalFilter) match(resource map[string]interface{}) bool {
+	if f.and {
+		return f.left.match(resource) && f.right.match(resource)
+	}
+	return f.left.match(resource) || f.right.match(resource)
+}
+
+func (f *notFilter) match(resource map[string]interface{}) bool {
+	return !f.filter.match(resource)
+}
+
+// match is true when one of the values of the attribute satisfies the comparison
+func (f *comparisonFilter) match(resource map[string]interface{}) bool {
+	values := attributeValues(resource, f.path)
+
+	if f.operator == "pr" {
+		return len(values) > 0
+	}
+
+	if f.operator == "ne" {
+		for _, value := range values {
+			if compare(value, "eq", f.value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, value := range values {
+		if compare(value, f.operator, f.value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attributeValues returns the non-null values of an attribute path, multi-valued attributes are flattened
+func attributeValues(value interface{}, path []string) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		values := []interface{}{}
+		for _, item := range list {
+			values = append(values, attributeValues(item, path)...)
+		}
+		return values
+	}
+
+	if len(path) == 0 {
+		if value == nil {
+			return nil
+		}
+		return []interface{}{value}
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	// attribute names are case insensitive
+	for name, attribute := range object {
+		if strings.EqualFold(name, path[0]) {
+			return attributeValues(attribute, path[1:])
+		}
+	}
+
+	return nil
+}
+
+// compare compares a value of a resource with the value of a filter, the strings are compared case insensitively
+func compare(value interface{}, operator string, filterValue interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		s, ok := filterValue.(string)
+		if !ok {
+			return false
+		}
+
+		v, s = strings.ToLower(v), strings.ToLower(s)
+		switch operator {
+		case "eq":
+			return v == s
+		case "co":
+			return strings.Contains(v, s)
+		case "sw":
+			return strings.HasPrefix(v, s)
+		case "ew":
+			return strings.HasSuffix(v, s)
+		case "gt":
+			return v > s
+		case "ge":
+			return v >= s
+		case "lt":
+			return v < s
+		case "le":
+			return v <= s
+		}
+	case float64:
+		n, ok := filterValue.(float64)
+		if !ok {
+			return false
+		}
+
+		switch operator {
+		case "eq":
+			return v == n
+		case "gt":
+			return v > n
+		case "ge":
+			return v >= n
+		case "lt":
+			return v < n
+		case "le":
+			return v <= n
+		}
+	case bool:
+		b, ok := filterValue.(bool)
+		return ok && operator == "eq" && v == b
+	}
+
+	return false
+}
+
+// parseFilter parses a SCIM filter (RFC 7644 section 3.4.2.2). Complex attribute filters are not supported.
+func parseFilter(expression string) (filter, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.position < len(p.tokens) {
+		return nil, errors.Errorf("unexpected %q in the filter", p.tokens[p.position].text)
+	}
+
+	return f, nil
+}
+
+type token struct {
+	text string
+	// quoted is true for the string values
+	quoted bool
+}
+
+func tokenize(expression string) ([]token, error) {
+	tokens := []token{}
+
+	for i := 0; i < len(expression); {
+		c := expression[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(expression) && expression[end] != '"' {
+				if expression[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expression) {
+				return nil, errors.New("unterminated string in the filter")
+			}
+
+			var s string
+			err := json.Unmarshal([]byte(expression[i:end+1]), &s)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid string in the filter")
+			}
+
+			tokens = append(tokens, token{text: s, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(expression) && !unicode.IsSpace(rune(expression[end])) && expression[end] != '(' && expression[end] != ')' {
+				end++
+			}
+
+			tokens = append(tokens, token{text: expression[i:end]})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens   []token
+	position int
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.position < len(p.tokens) && !p.tokens[p.position].quoted && strings.EqualFold(p.tokens[p.position].text, keyword)
+}
+
+func (p *filterParser) next() (token, error) {
+	if p.position >= len(p.tokens) {
+		return token{}, errors.New("unexpected end of the filter")
+	}
+
+	t := p.tokens[p.position]
+	p.position++
+	return t, nil
+}
+
+func (p *filterParser) parseOr() (filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("or") {
+		p.position++
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &logicalFilter{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filter, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("and") {
+		p.position++
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &logicalFilter{and: true, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filter, error) {
+	if !p.peekKeyword("not") {
+		return p.parsePrimary()
+	}
+	p.position++
+
+	f, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &notFilter{filter: f}, nil
+}
+
+func (p *filterParser) parsePrimary() (filter, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if !t.quoted && t.text == "(" {
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		closing, err := p.next()
+		if err != nil || closing.quoted || closing.text != ")" {
+			return nil, errors.New("missing closing parenthesis in the filter")
+		}
+
+		return f, nil
+	}
+
+	if t.quoted || t.text == ")" {
+		return nil, errors.Errorf("expected an attribute in the filter, got %q", t.text)
+	}
+
+	// the schema URN prefix of a fully qualified attribute is ignored
+	path := t.text
+	if i := strings.LastIndex(path, ":"); i != -1 {
+		path = path[i+1:]
+	}
+
+	operator, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &comparisonFilter{path: strings.Split(path, "."), operator: strings.ToLower(operator.text)}
+	if operator.quoted || !comparisonOperators[f.operator] {
+		return nil, errors.Errorf("unsupported filter operator %q", operator.text)
+	}
+
+	if f.operator == "pr" {
+		return f, nil
+	}
+
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if value.quoted {
+		f.value = value.text
+		return f, nil
+	}
+
+	err = json.Unmarshal([]byte(value.text), &f.value)
+	if err != nil {
+		return nil, errors.Errorf("invalid filter value %q", value.text)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,71 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseFilter(t *testing.T) {
+	resource := map[string]interface{}{
+		"id":       "3",
+		"userName": "Bob",
+		"active":   true,
+		"groups": []interface{}{
+			map[string]interface{}{"value": "1", "display": "developers"},
+			map[string]interface{}{"value": "2", "display": "operators"},
+		},
+	}
+
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{`userName eq "bob"`, true},
+		{`USERNAME eq "BOB"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "bob"`, true},
+		{`userName eq "alice"`, false},
+		{`userName ne "alice"`, true},
+		{`userName sw "b"`, true},
+		{`userName ew "ob"`, true},
+		{`userName co "o"`, true},
+		{`userName gt "alice"`, true},
+		{`active eq true`, true},
+		{`active eq false`, false},
+		{`groups.display eq "operators"`, true},
+		{`groups.value eq "4"`, false},
+		{`groups pr`, true},
+		{`emails pr`, false},
+		{`userName eq "bob" and active eq false`, false},
+		{`userName eq "alice" or active eq true`, true},
+		{`not (userName eq "bob")`, false},
+		{`(userName eq "alice" or userName eq "bob") and groups.display eq "developers"`, true},
+		{`userName eq "with \"quotes\""`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.filter, func(t *testing.T) {
+			f, err := parseFilter(test.filter)
+			require.NoError(t, err)
+			assert.Equal(t, test.match, f.match(resource))
+		})
+	}
+}
+
+func Test_parseFilter_shouldFailWithInvalidFilters(t *testing.T) {
+	for _, filter := range []string{
+		`userName`,
+		`userName eq`,
+		`userName is "bob"`,
+		`userName eq "bob`,
+		`(userName eq "bob"`,
+		`userName eq "bob")`,
+		`userName eq bob`,
+		`"userName" eq "bob"`,
+		`userName eq "bob" and`,
+	} {
+		_, err := parseFilter(filter)
+		assert.Error(t, err, filter)
+	}
+}
@@ -0,0 +1,432 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/pkg/errors"
+)
+
+type groupPayload struct {
+	DisplayName string      `json:"displayName"`
+	Members     []reference `json:"members"`
+}
+
+// groupList returns the teams matching the filter, such as displayName eq "developers"
+func (handler *Handler) groupList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	options, httpErr := parseListOptions(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	d, err := handler.loadDirectory()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the teams from the database", err)
+	}
+
+	resources := make([]interface{}, 0, len(d.teams))
+	for i := range d.teams {
+		resources = append(resources, d.groupResource(&d.teams[i]))
+	}
+
+	return options.list(w, resources)
+}
+
+func (handler *Handler) groupInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	team, httpErr := handler.team(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	options, httpErr := parseListOptions(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	d, err := handler.loadDirectory()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the team memberships from the database", err)
+	}
+
+	resource := d.groupResource(team)
+	options.exclude(resource)
+
+	return writeJSON(w, http.StatusOK, resource)
+}
+
+func (handler *Handler) groupCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload groupPayload
+	httpErr := decodePayload(r, &payload)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	payload.DisplayName = strings.TrimSpace(payload.DisplayName)
+	if payload.DisplayName == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "Invalid request payload", errors.New("the displayName attribute is required"))
+	}
+
+	members, httpErr := handler.memberIDs(payload.Members)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	httpErr = handler.checkTeamName(payload.DisplayName)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	team := &portainer.Team{Name: payload.DisplayName}
+
+	err := handler.DataStore.Team().Create(team)
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist the team inside the database", err)
+	}
+
+	httpErr = handler.setMembers(team.ID, members)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	w.Header().Set("Location", groupLocation(team.ID))
+	return handler.writeGroup(w, http.StatusCreated, team)
+}
+
+func (handler *Handler) groupReplace(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	team, httpErr := handler.team(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	var payload groupPayload
+	httpErr = decodePayload(r, &payload)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	members, httpErr := handler.memberIDs(payload.Members)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	httpErr = handler.renameTeam(team, strings.TrimSpace(payload.DisplayName))
+	if httpErr != nil {
+		return httpErr
+	}
+
+	httpErr = handler.setMembers(team.ID, members)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return handler.writeGroup(w, http.StatusOK, team)
+}
+
+// groupPatch applies the operations on the displayName and members attributes. The members to remove can be
+// selected with a value filter, such as members[value eq "2"].
+func (handler *Handler) groupPatch(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	team, httpErr := handler.team(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	operations, httpErr := decodePatch(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	memberships, err := handler.DataStore.TeamMembership().TeamMembershipsByTeamID(team.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the team memberships from the database", err)
+	}
+
+	members := make(map[portainer.UserID]bool, len(memberships))
+	for _, membership := range memberships {
+		members[membership.UserID] = true
+	}
+
+	name := team.Name
+
+	apply := func(op, path string, value json.RawMessage) *httperror.HandlerError {
+		switch {
+		case path == "displayname":
+			if op == "remove" {
+				return mutabilityError("displayName")
+			}
+
+			s, httpErr := decodeString(value)
+			if httpErr != nil {
+				return httpErr
+			}
+			name = strings.TrimSpace(s)
+		case path == "members":
+			if op == "remove" && !hasValue(value) {
+				members = map[portainer.UserID]bool{}
+				return nil
+			}
+
+			references, httpErr := decodeReferences(value)
+			if httpErr != nil {
+				return httpErr
+			}
+
+			// the removed members do not need to exist anymore
+			if op == "remove" {
+				for _, ref := range references {
+					id, err := strconv.Atoi(ref.Value)
+					if err == nil {
+						members[portainer.UserID(id)] = false
+					}
+				}
+				return nil
+			}
+
+			ids, httpErr := handler.memberIDs(references)
+			if httpErr != nil {
+				return httpErr
+			}
+
+			if op == "replace" {
+				members = map[portainer.UserID]bool{}
+			}
+
+			for id := range ids {
+				members[id] = true
+			}
+		case strings.HasPrefix(path, "members[") && strings.HasSuffix(path, "]"):
+			if op != "remove" {
+				return newError(http.StatusBadRequest, "invalidPath", "Invalid PatchOp path", errors.New("a members value filter can only be used to remove members"))
+			}
+
+			f, err := parseFilter(strings.TrimSuffix(strings.TrimPrefix(path, "members["), "]"))
+			if err != nil {
+				return newError(http.StatusBadRequest, "invalidFilter", "Invalid PatchOp path", err)
+			}
+
+			for id := range members {
+				if f.match(map[string]interface{}{"value": strconv.Itoa(int(id))}) {
+					members[id] = false
+				}
+			}
+		default:
+			return newError(http.StatusBadRequest, "invalidPath", "Invalid PatchOp path", errors.Errorf("unsupported attribute %q", path))
+		}
+
+		return nil
+	}
+
+	for _, operation := range operations {
+		if operation.Path != "" {
+			httpErr = apply(operation.Op, attributeName(operation.Path), operation.Value)
+			if httpErr != nil {
+				return httpErr
+			}
+			continue
+		}
+
+		if operation.Op == "remove" {
+			return newError(http.StatusBadRequest, "noTarget", "Invalid PatchOp operation", errors.New("the remove operation requires a path"))
+		}
+
+		attributes, httpErr := operation.attributes()
+		if httpErr != nil {
+			return httpErr
+		}
+
+		for attribute, value := range attributes {
+			httpErr = apply(operation.Op, attribute, value)
+			if httpErr != nil {
+				return httpErr
+			}
+		}
+	}
+
+	httpErr = handler.renameTeam(team, name)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	httpErr = handler.setMembers(team.ID, members)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return handler.writeGroup(w, http.StatusOK, team)
+}
+
+func (handler *Handler) groupDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	team, httpErr := handler.team(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	err := handler.DataStore.Team().DeleteTeam(team.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to delete the team from the database", err)
+	}
+
+	err = handler.DataStore.TeamMembership().DeleteTeamMembershipByTeamID(team.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to delete the associated team memberships from the database", err)
+	}
+
+	err = handler.resetDefaultTeam(team.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to reset default team", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// team returns the team of the id route variable
+func (handler *Handler) team(r *http.Request) (*portainer.Team, *httperror.HandlerError) {
+	teamID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, httperror.NotFound("Unable to find a team with the specified identifier inside the database", err)
+	}
+
+	team, err := handler.DataStore.Team().Team(portainer.TeamID(teamID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return nil, httperror.NotFound("Unable to find a team with the specified identifier inside the database", err)
+	} else if err != nil {
+		return nil, httperror.InternalServerError("Unable to find a team with the specified identifier inside the database", err)
+	}
+
+	return team, nil
+}
+
+func (handler *Handler) checkTeamName(name string) *httperror.HandlerError {
+	team, err := handler.DataStore.Team().TeamByName(name)
+	if err != nil && !handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.InternalServerError("Unable to retrieve teams from the database", err)
+	}
+	if team != nil {
+		return newError(http.StatusConflict, "uniqueness", "A team with the same name already exists", errors.Errorf("the team %s already exists", name))
+	}
+
+	return nil
+}
+
+func (handler *Handler) renameTeam(team *portainer.Team, name string) *httperror.HandlerError {
+	if name == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "Invalid request payload", errors.New("the displayName attribute is required"))
+	}
+
+	if name == team.Name {
+		return nil
+	}
+
+	if !strings.EqualFold(name, team.Name) {
+		httpErr := handler.checkTeamName(name)
+		if httpErr != nil {
+			return httpErr
+		}
+	}
+
+	team.Name = name
+
+	err := handler.DataStore.Team().UpdateTeam(team.ID, team)
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist team changes inside the database", err)
+	}
+
+	return nil
+}
+
+// memberIDs returns the identifiers of the users of member references, which must exist
+func (handler *Handler) memberIDs(references []reference) (map[portainer.UserID]bool, *httperror.HandlerError) {
+	ids := make(map[portainer.UserID]bool, len(references))
+
+	for _, ref := range references {
+		id, err := strconv.Atoi(ref.Value)
+		if err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidValue", "Invalid group member", errors.Errorf("unknown user %q", ref.Value))
+		}
+
+		_, err = handler.DataStore.User().User(portainer.UserID(id))
+		if handler.DataStore.IsErrObjectNotFound(err) {
+			return nil, newError(http.StatusBadRequest, "invalidValue", "Invalid group member", errors.Errorf("unknown user %q", ref.Value))
+		} else if err != nil {
+			return nil, httperror.InternalServerError("Unable to retrieve users from the database", err)
+		}
+
+		ids[portainer.UserID(id)] = true
+	}
+
+	return ids, nil
+}
+
+// setMembers reconciles the memberships of a team with the members, the new members join the team as members.
+// The role of the remaining members is kept.
+func (handler *Handler) setMembers(teamID portainer.TeamID, members map[portainer.UserID]bool) *httperror.HandlerError {
+	memberships, err := handler.DataStore.TeamMembership().TeamMembershipsByTeamID(teamID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the team memberships from the database", err)
+	}
+
+	existing := make(map[portainer.UserID]bool, len(memberships))
+	for _, membership := range memberships {
+		existing[membership.UserID] = true
+
+		if members[membership.UserID] {
+			continue
+		}
+
+		err := handler.DataStore.TeamMembership().DeleteTeamMembership(membership.ID)
+		if err != nil {
+			return httperror.InternalServerError("Unable to remove the team membership from the database", err)
+		}
+	}
+
+	for userID, member := range members {
+		if !member || existing[userID] {
+			continue
+		}
+
+		err := handler.DataStore.TeamMembership().Create(&portainer.TeamMembership{UserID: userID, TeamID: teamID, Role: portainer.TeamMember})
+		if err != nil {
+			return httperror.InternalServerError("Unable to persist team memberships inside the database", err)
+		}
+	}
+
+	return nil
+}
+
+// resetDefaultTeam resets the default team of the external authentication methods when it is removed
+func (handler *Handler) resetDefaultTeam(teamID portainer.TeamID) error {
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch settings")
+	}
+
+	if settings.OAuthSettings.DefaultTeamID != teamID && settings.SAMLSettings.DefaultTeamID != teamID {
+		return nil
+	}
+
+	if settings.OAuthSettings.DefaultTeamID == teamID {
+		settings.OAuthSettings.DefaultTeamID = 0
+	}
+
+	if settings.SAMLSettings.DefaultTeamID == teamID {
+		settings.SAMLSettings.DefaultTeamID = 0
+	}
+
+	err = handler.DataStore.Settings().UpdateSettings(settings)
+	return errors.Wrap(err, "failed to update settings")
+}
+
+func (handler *Handler) writeGroup(w http.ResponseWriter, statusCode int, team *portainer.Team) *httperror.HandlerError {
+	d, err := handler.loadDirectory()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the team memberships from the database", err)
+	}
+
+	return writeJSON(w, statusCode, d.groupResource(team))
+}
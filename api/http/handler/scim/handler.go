@@ -0,0 +1,149 @@
+package scim
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	httperror "github.com/portainer/libhttp/error"
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/audit"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/http/security"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errSCIMNotEnabled = errors.New("SCIM provisioning is not enabled")
+	errInvalidToken   = errors.New("Invalid SCIM bearer token")
+)
+
+// Handler is the HTTP handler of the SCIM 2.0 endpoints, used by an identity provider to provision
+// the users and the teams. The requests are authenticated with the SCIM bearer token of the settings.
+type Handler struct {
+	*mux.Router
+	DataStore     dataservices.DataStore
+	apiKeyService apikey.APIKeyService
+}
+
+// NewHandler creates a handler to serve the SCIM endpoints.
+func NewHandler(bouncer *security.RequestBouncer, apiKeyService apikey.APIKeyService) *Handler {
+	h := &Handler{
+		Router:        mux.NewRouter(),
+		apiKeyService: apiKeyService,
+	}
+
+	router := h.PathPrefix("/scim/v2").Subrouter()
+	// the identity providers provision in bursts, the token is long enough to not need the rate limiter
+	router.Use(bouncer.AuditedAccess, bouncer.PublicAccess, h.authenticate)
+
+	router.Handle("/ServiceProviderConfig", scimHandler(h.serviceProviderConfig)).Methods(http.MethodGet)
+
+	router.Handle("/Users", scimHandler(h.userList)).Methods(http.MethodGet)
+	router.Handle("/Users", auditResource("users", scimHandler(h.userCreate))).Methods(http.MethodPost)
+	router.Handle("/Users/{id}", scimHandler(h.userInspect)).Methods(http.MethodGet)
+	router.Handle("/Users/{id}", auditResource("users", scimHandler(h.userReplace))).Methods(http.MethodPut)
+	router.Handle("/Users/{id}", auditResource("users", scimHandler(h.userPatch))).Methods(http.MethodPatch)
+	router.Handle("/Users/{id}", auditResource("users", scimHandler(h.userDelete))).Methods(http.MethodDelete)
+
+	router.Handle("/Groups", scimHandler(h.groupList)).Methods(http.MethodGet)
+	router.Handle("/Groups", auditResource("teams", scimHandler(h.groupCreate))).Methods(http.MethodPost)
+	router.Handle("/Groups/{id}", scimHandler(h.groupInspect)).Methods(http.MethodGet)
+	router.Handle("/Groups/{id}", auditResource("teams", scimHandler(h.groupReplace))).Methods(http.MethodPut)
+	router.Handle("/Groups/{id}", auditResource("teams", scimHandler(h.groupPatch))).Methods(http.MethodPatch)
+	router.Handle("/Groups/{id}", auditResource("teams", scimHandler(h.groupDelete))).Methods(http.MethodDelete)
+
+	router.NotFoundHandler = scimHandler(func(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+		return httperror.NotFound("Unknown SCIM endpoint", errors.New("unknown SCIM endpoint"))
+	})
+
+	return h
+}
+
+// authenticate rejects the requests without the SCIM bearer token of the settings
+func (handler *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audit.SetAuthMethod(r, portainer.AuditLogAuthMethodSCIM)
+
+		settings, err := handler.DataStore.Settings().Settings()
+		if err != nil {
+			writeError(w, httperror.InternalServerError("Unable to retrieve the settings from the database", err))
+			return
+		}
+
+		if !settings.SCIMSettings.Enabled || len(settings.SCIMSettings.TokenDigest) == 0 {
+			writeError(w, httperror.Forbidden("SCIM provisioning is not enabled", errSCIMNotEnabled))
+			return
+		}
+
+		token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		digest := sha256.Sum256([]byte(token))
+		if token == "" || subtle.ConstantTimeCompare(digest[:], settings.SCIMSettings.TokenDigest) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, httperror.NewError(http.StatusUnauthorized, "Invalid SCIM bearer token", errInvalidToken))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// auditResource records the Portainer resource targeted by a SCIM request in the audit log
+func auditResource(resourceType string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		audit.SetResource(r, audit.Resource{Type: resourceType, ID: mux.Vars(r)["id"], Operation: audit.Operation(r.Method)})
+		next.ServeHTTP(w, r)
+	})
+}
+
+type serviceProviderConfig struct {
+	Schemas               []string          `json:"schemas"`
+	Patch                 supported         `json:"patch"`
+	Bulk                  bulkSupport       `json:"bulk"`
+	Filter                filterSupport     `json:"filter"`
+	ChangePassword        supported         `json:"changePassword"`
+	Sort                  supported         `json:"sort"`
+	ETag                  supported         `json:"etag"`
+	AuthenticationSchemes []authScheme      `json:"authenticationSchemes"`
+	Meta                  map[string]string `json:"meta"`
+}
+
+type supported struct {
+	Supported bool `json:"supported"`
+}
+
+type bulkSupport struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type filterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type authScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// serviceProviderConfig describes the SCIM features supported by Portainer (RFC 7643 section 5)
+func (handler *Handler) serviceProviderConfig(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	return writeJSON(w, http.StatusOK, &serviceProviderConfig{
+		Schemas: []string{serviceProviderConfigSchema},
+		Patch:   supported{Supported: true},
+		Filter:  filterSupport{Supported: true, MaxResults: maxResults},
+		AuthenticationSchemes: []authScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "Bearer token",
+			Description: "Token generated in the Portainer settings",
+		}},
+		Meta: map[string]string{"resourceType": "ServiceProviderConfig", "location": basePath + "/ServiceProviderConfig"},
+	})
+}
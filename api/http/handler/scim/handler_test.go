@@ -0,0 +1,304 @@
+package scim
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/jwt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "scim-test-token"
+
+type testServer struct {
+	t       *testing.T
+	handler *Handler
+	store   dataservices.DataStore
+}
+
+func newTestServer(t *testing.T) (*testServer, func()) {
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+
+	require.NoError(t, store.User().Create(&portainer.User{Username: "admin", Role: portainer.AdministratorRole, Password: "hash"}))
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(testToken))
+	settings.SCIMSettings = portainer.SCIMSettings{Enabled: true, TokenDigest: digest[:]}
+	require.NoError(t, store.Settings().UpdateSettings(settings))
+
+	jwtService, err := jwt.NewService("1h", store)
+	require.NoError(t, err)
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+
+	h := NewHandler(bouncer, apiKeyService)
+	h.DataStore = store
+
+	return &testServer{t: t, handler: h, store: store}, teardown
+}
+
+// do sends a request with the SCIM token and decodes the response into result
+func (s *testServer) do(method, path string, body interface{}, result interface{}) int {
+	var payload bytes.Buffer
+	if body != nil {
+		require.NoError(s.t, json.NewEncoder(&payload).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, "/scim/v2"+path, &payload)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Content-Type", contentType)
+
+	rr := httptest.NewRecorder()
+	s.handler.ServeHTTP(rr, req)
+
+	if result != nil && rr.Body.Len() > 0 {
+		require.NoError(s.t, json.NewDecoder(rr.Body).Decode(result))
+	}
+
+	return rr.Code
+}
+
+func (s *testServer) createUser(userName string) string {
+	var user userResource
+	require.Equal(s.t, http.StatusCreated, s.do(http.MethodPost, "/Users", map[string]interface{}{"schemas": []string{userSchema}, "userName": userName}, &user))
+	return user.ID
+}
+
+func patch(operations ...map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"schemas": []string{patchOpSchema}, "Operations": operations}
+}
+
+func Test_authenticate(t *testing.T) {
+	s, teardown := newTestServer(t)
+	defer teardown()
+
+	send := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/scim/v2/Users", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		s.handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, send(testToken).Code)
+
+	rr := send("")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "Bearer", rr.Header().Get("WWW-Authenticate"))
+
+	var scimErr errorResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&scimErr))
+	assert.Equal(t, []string{errorSchema}, scimErr.Schemas)
+	assert.Equal(t, "401", scimErr.Status)
+
+	assert.Equal(t, http.StatusUnauthorized, send("other-token").Code)
+
+	settings, err := s.store.Settings().Settings()
+	require.NoError(t, err)
+	settings.SCIMSettings.Enabled = false
+	require.NoError(t, s.store.Settings().UpdateSettings(settings))
+
+	assert.Equal(t, http.StatusForbidden, send(testToken).Code)
+}
+
+func Test_users(t *testing.T) {
+	is := assert.New(t)
+
+	s, teardown := newTestServer(t)
+	defer teardown()
+
+	id := s.createUser("bob")
+
+	t.Run("refuses a duplicated user", func(t *testing.T) {
+		var scimErr errorResponse
+		is.Equal(http.StatusConflict, s.do(http.MethodPost, "/Users", map[string]interface{}{"userName": "BOB"}, &scimErr))
+		is.Equal("uniqueness", scimErr.ScimType)
+	})
+
+	t.Run("filters the users", func(t *testing.T) {
+		s.createUser("alice")
+
+		var list struct {
+			TotalResults int
+			Resources    []userResource
+		}
+		is.Equal(http.StatusOK, s.do(http.MethodGet, "/Users?filter="+url.QueryEscape(`userName eq "Bob"`), nil, &list))
+		is.Equal(1, list.TotalResults)
+		require.Len(t, list.Resources, 1)
+		is.Equal(id, list.Resources[0].ID)
+		is.True(list.Resources[0].Active)
+
+		is.Equal(http.StatusOK, s.do(http.MethodGet, "/Users?startIndex=2&count=1", nil, &list))
+		is.Equal(3, list.TotalResults)
+		require.Len(t, list.Resources, 1)
+		is.Equal("bob", list.Resources[0].UserName)
+
+		is.Equal(http.StatusBadRequest, s.do(http.MethodGet, "/Users?filter="+url.QueryEscape(`userName eq`), nil, nil))
+	})
+
+	t.Run("deactivates the user", func(t *testing.T) {
+		var user userResource
+		is.Equal(http.StatusOK, s.do(http.MethodPatch, "/Users/"+id, patch(map[string]interface{}{"op": "Replace", "path": "active", "value": "False"}), &user))
+		is.False(user.Active)
+
+		userID, _ := strconv.Atoi(id)
+		stored, err := s.store.User().User(portainer.UserID(userID))
+		require.NoError(t, err)
+		is.True(stored.Disabled)
+		is.Equal(portainer.UserDisabledBySCIM, stored.DisabledBy)
+		is.NotZero(stored.TokenIssueAt, "the sessions of the user should be revoked")
+
+		is.Equal(http.StatusOK, s.do(http.MethodPatch, "/Users/"+id, patch(map[string]interface{}{"op": "replace", "value": map[string]interface{}{"active": true}}), &user))
+		is.True(user.Active)
+	})
+
+	t.Run("replaces the user", func(t *testing.T) {
+		var user userResource
+		is.Equal(http.StatusOK, s.do(http.MethodPut, "/Users/"+id, map[string]interface{}{"userName": "robert", "active": true}, &user))
+		is.Equal("robert", user.UserName)
+
+		is.Equal(http.StatusConflict, s.do(http.MethodPut, "/Users/"+id, map[string]interface{}{"userName": "alice"}, nil))
+	})
+
+	t.Run("protects the initial admin", func(t *testing.T) {
+		is.Equal(http.StatusBadRequest, s.do(http.MethodPatch, "/Users/1", patch(map[string]interface{}{"op": "replace", "path": "active", "value": false}), nil))
+		is.Equal(http.StatusForbidden, s.do(http.MethodDelete, "/Users/1", nil, nil))
+	})
+
+	t.Run("deletes the user", func(t *testing.T) {
+		userID, _ := strconv.Atoi(id)
+		require.NoError(t, s.store.Team().Create(&portainer.Team{Name: "developers"}))
+		require.NoError(t, s.store.TeamMembership().Create(&portainer.TeamMembership{UserID: portainer.UserID(userID), TeamID: 1, Role: portainer.TeamMember}))
+
+		is.Equal(http.StatusNoContent, s.do(http.MethodDelete, "/Users/"+id, nil, nil))
+		is.Equal(http.StatusNotFound, s.do(http.MethodGet, "/Users/"+id, nil, nil))
+
+		memberships, err := s.store.TeamMembership().TeamMembershipsByUserID(portainer.UserID(userID))
+		require.NoError(t, err)
+		is.Empty(memberships)
+	})
+}
+
+func Test_groups(t *testing.T) {
+	is := assert.New(t)
+
+	s, teardown := newTestServer(t)
+	defer teardown()
+
+	bob, alice, carol := s.createUser("bob"), s.createUser("alice"), s.createUser("carol")
+
+	members := func(group groupResource) []string {
+		values := []string{}
+		for _, member := range group.Members {
+			values = append(values, member.Value)
+		}
+		return values
+	}
+
+	var group groupResource
+	is.Equal(http.StatusCreated, s.do(http.MethodPost, "/Groups", map[string]interface{}{
+		"schemas":     []string{groupSchema},
+		"displayName": "developers",
+		"members":     []map[string]string{{"value": bob}},
+	}, &group))
+	is.Equal("developers", group.DisplayName)
+	is.Equal([]string{bob}, members(group))
+
+	t.Run("refuses a duplicated group or an unknown member", func(t *testing.T) {
+		is.Equal(http.StatusConflict, s.do(http.MethodPost, "/Groups", map[string]interface{}{"displayName": "Developers"}, nil))
+		is.Equal(http.StatusBadRequest, s.do(http.MethodPost, "/Groups", map[string]interface{}{"displayName": "operators", "members": []map[string]string{{"value": "42"}}}, nil))
+	})
+
+	t.Run("adds and removes members", func(t *testing.T) {
+		is.Equal(http.StatusOK, s.do(http.MethodPatch, "/Groups/"+group.ID, patch(
+			map[string]interface{}{"op": "add", "path": "members", "value": []map[string]string{{"value": alice}, {"value": carol}}},
+			map[string]interface{}{"op": "remove", "path": `members[value eq "` + bob + `"]`},
+		), &group))
+		is.ElementsMatch([]string{alice, carol}, members(group))
+
+		is.Equal(http.StatusOK, s.do(http.MethodPatch, "/Groups/"+group.ID, patch(
+			map[string]interface{}{"op": "Remove", "path": "members", "value": []map[string]string{{"value": carol}}},
+		), &group))
+		is.Equal([]string{alice}, members(group))
+
+		var user userResource
+		is.Equal(http.StatusOK, s.do(http.MethodGet, "/Users/"+alice, nil, &user))
+		require.Len(t, user.Groups, 1)
+		is.Equal("developers", user.Groups[0].Display)
+	})
+
+	t.Run("keeps the role of the remaining members", func(t *testing.T) {
+		teamID, _ := strconv.Atoi(group.ID)
+		userID, _ := strconv.Atoi(alice)
+
+		memberships, err := s.store.TeamMembership().TeamMembershipsByTeamID(portainer.TeamID(teamID))
+		require.NoError(t, err)
+		require.Len(t, memberships, 1)
+		memberships[0].Role = portainer.TeamLeader
+		require.NoError(t, s.store.TeamMembership().UpdateTeamMembership(memberships[0].ID, &memberships[0]))
+
+		is.Equal(http.StatusOK, s.do(http.MethodPatch, "/Groups/"+group.ID, patch(
+			map[string]interface{}{"op": "replace", "value": map[string]interface{}{"displayName": "engineering", "members": []map[string]string{{"value": alice}, {"value": bob}}}},
+		), &group))
+		is.Equal("engineering", group.DisplayName)
+		is.ElementsMatch([]string{alice, bob}, members(group))
+
+		memberships, err = s.store.TeamMembership().TeamMembershipsByUserID(portainer.UserID(userID))
+		require.NoError(t, err)
+		require.Len(t, memberships, 1)
+		is.Equal(portainer.TeamLeader, memberships[0].Role)
+	})
+
+	t.Run("filters the groups and excludes the members", func(t *testing.T) {
+		var list struct {
+			TotalResults int
+			Resources    []groupResource
+		}
+		is.Equal(http.StatusOK, s.do(http.MethodGet, "/Groups?excludedAttributes=members&filter="+url.QueryEscape(`displayName eq "engineering"`), nil, &list))
+		is.Equal(1, list.TotalResults)
+		require.Len(t, list.Resources, 1)
+		is.Empty(list.Resources[0].Members)
+	})
+
+	t.Run("replaces the group", func(t *testing.T) {
+		is.Equal(http.StatusOK, s.do(http.MethodPut, "/Groups/"+group.ID, map[string]interface{}{"displayName": "engineering", "members": []map[string]string{{"value": carol}}}, &group))
+		is.Equal([]string{carol}, members(group))
+	})
+
+	t.Run("deletes the group and resets the default team", func(t *testing.T) {
+		teamID, _ := strconv.Atoi(group.ID)
+
+		settings, err := s.store.Settings().Settings()
+		require.NoError(t, err)
+		settings.OAuthSettings.DefaultTeamID = portainer.TeamID(teamID)
+		require.NoError(t, s.store.Settings().UpdateSettings(settings))
+
+		is.Equal(http.StatusNoContent, s.do(http.MethodDelete, "/Groups/"+group.ID, nil, nil))
+		is.Equal(http.StatusNotFound, s.do(http.MethodGet, "/Groups/"+group.ID, nil, nil))
+
+		memberships, err := s.store.TeamMembership().TeamMembershipsByTeamID(portainer.TeamID(teamID))
+		require.NoError(t, err)
+		is.Empty(memberships)
+
+		settings, err = s.store.Settings().Settings()
+		require.NoError(t, err)
+		is.Zero(settings.OAuthSettings.DefaultTeamID)
+	})
+}
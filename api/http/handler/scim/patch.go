@@ -0,0 +1,129 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	httperror "github.com/portainer/libhttp/error"
+
+	"github.com/pkg/errors"
+)
+
+type patchPayload struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// decodePatch decodes a PatchOp request, the operations are lowered to add, replace or remove
+func decodePatch(r *http.Request) ([]patchOperation, *httperror.HandlerError) {
+	var payload patchPayload
+	httpErr := decodePayload(r, &payload)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	if len(payload.Operations) == 0 {
+		return nil, newError(http.StatusBadRequest, "invalidValue", "Invalid request payload", errors.New("the PatchOp request has no operation"))
+	}
+
+	for i := range payload.Operations {
+		operation := &payload.Operations[i]
+		operation.Op = strings.ToLower(operation.Op)
+
+		if operation.Op != "add" && operation.Op != "replace" && operation.Op != "remove" {
+			return nil, newError(http.StatusBadRequest, "invalidSyntax", "Invalid request payload", errors.Errorf("unsupported PatchOp operation %q", operation.Op))
+		}
+	}
+
+	return payload.Operations, nil
+}
+
+// attributes returns the attributes of the value of an operation without path, keyed by their lowered names
+func (operation *patchOperation) attributes() (map[string]json.RawMessage, *httperror.HandlerError) {
+	attributes := map[string]json.RawMessage{}
+
+	err := json.Unmarshal(operation.Value, &attributes)
+	if err != nil {
+		return nil, newError(http.StatusBadRequest, "invalidValue", "Invalid PatchOp value", err)
+	}
+
+	lowered := make(map[string]json.RawMessage, len(attributes))
+	for name, value := range attributes {
+		lowered[attributeName(name)] = value
+	}
+
+	return lowered, nil
+}
+
+// attributeName lowers an attribute path and removes its schema URN
+func attributeName(path string) string {
+	path = strings.ToLower(strings.TrimSpace(path))
+
+	// the brackets of a value filter can hold colons
+	if strings.Contains(path, "[") {
+		return path
+	}
+
+	if i := strings.LastIndex(path, ":"); i != -1 {
+		path = path[i+1:]
+	}
+
+	return path
+}
+
+func hasValue(value json.RawMessage) bool {
+	v := strings.TrimSpace(string(value))
+	return v != "" && v != "null"
+}
+
+func decodeString(value json.RawMessage) (string, *httperror.HandlerError) {
+	var s string
+
+	err := json.Unmarshal(value, &s)
+	if err != nil {
+		return "", newError(http.StatusBadRequest, "invalidValue", "Invalid PatchOp value", err)
+	}
+
+	return s, nil
+}
+
+// decodeBool decodes a boolean, some identity providers send the booleans as strings such as "False"
+func decodeBool(value json.RawMessage) (bool, *httperror.HandlerError) {
+	var b bool
+	if json.Unmarshal(value, &b) == nil {
+		return b, nil
+	}
+
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		b, err := strconv.ParseBool(s)
+		if err == nil {
+			return b, nil
+		}
+	}
+
+	return false, newError(http.StatusBadRequest, "invalidValue", "Invalid PatchOp value", errors.Errorf("%s is not a boolean", value))
+}
+
+func decodeReferences(value json.RawMessage) ([]reference, *httperror.HandlerError) {
+	references := []reference{}
+
+	err := json.Unmarshal(value, &references)
+	if err != nil {
+		return nil, newError(http.StatusBadRequest, "invalidValue", "Invalid PatchOp value", err)
+	}
+
+	return references, nil
+}
+
+func mutabilityError(path string) *httperror.HandlerError {
+	return newError(http.StatusBadRequest, "mutability", "Invalid PatchOp operation", errors.Errorf("the %s attribute cannot be removed", path))
+}
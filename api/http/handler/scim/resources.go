@@ -0,0 +1,381 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	httperror "github.com/portainer/libhttp/error"
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	userSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	groupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	listResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	patchOpSchema               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	errorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	serviceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	contentType = "application/scim+json"
+	// basePath is the path of the SCIM endpoints, the locations of the resources are built from it
+	basePath = "/api/scim/v2"
+)
+
+type (
+	meta struct {
+		ResourceType string `json:"resourceType"`
+		Location     string `json:"location"`
+	}
+
+	// reference is a value of the groups of a user or of the members of a group
+	reference struct {
+		Value   string `json:"value"`
+		Ref     string `json:"$ref,omitempty"`
+		Display string `json:"display,omitempty"`
+	}
+
+	userResource struct {
+		Schemas  []string    `json:"schemas"`
+		ID       string      `json:"id"`
+		UserName string      `json:"userName"`
+		Active   bool        `json:"active"`
+		Groups   []reference `json:"groups,omitempty"`
+		Meta     meta        `json:"meta"`
+	}
+
+	groupResource struct {
+		Schemas     []string    `json:"schemas"`
+		ID          string      `json:"id"`
+		DisplayName string      `json:"displayName"`
+		Members     []reference `json:"members,omitempty"`
+		Meta        meta        `json:"meta"`
+	}
+
+	listResponse struct {
+		Schemas      []string      `json:"schemas"`
+		TotalResults int           `json:"totalResults"`
+		StartIndex   int           `json:"startIndex"`
+		ItemsPerPage int           `json:"itemsPerPage"`
+		Resources    []interface{} `json:"Resources"`
+	}
+
+	errorResponse struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		ScimType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail"`
+	}
+)
+
+// scimError is the cause of an error response with a SCIM error type, such as uniqueness or invalidFilter
+type scimError struct {
+	scimType string
+	err      error
+}
+
+func (e *scimError) Error() string {
+	return e.err.Error()
+}
+
+func (e *scimError) Unwrap() error {
+	return e.err
+}
+
+// newError returns a handler error reported with a SCIM error type
+func newError(statusCode int, scimType, message string, err error) *httperror.HandlerError {
+	return httperror.NewError(statusCode, message, &scimError{scimType: scimType, err: err})
+}
+
+// scimHandler serves a SCIM request, its errors are written with the SCIM error schema
+type scimHandler func(w http.ResponseWriter, r *http.Request) *httperror.HandlerError
+
+func (handler scimHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := handler(w, r)
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err *httperror.HandlerError) {
+	if err.Err == nil {
+		err.Err = errors.New(err.Message)
+	}
+
+	log.Debug().Err(err.Err).Int("status_code", err.StatusCode).Str("msg", err.Message).Msg("SCIM error")
+
+	body := &errorResponse{Schemas: []string{errorSchema}, Status: strconv.Itoa(err.StatusCode), Detail: err.Message}
+
+	var e *scimError
+	if errors.As(err.Err, &e) {
+		body.ScimType = e.scimType
+		body.Detail = err.Message + ": " + e.Error()
+	}
+
+	writeJSON(w, err.StatusCode, body)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) *httperror.HandlerError {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Debug().Err(err).Msg("unable to write the SCIM response")
+	}
+
+	return nil
+}
+
+// decodePayload decodes a JSON request body, the SCIM attribute names are matched case insensitively
+func decodePayload(r *http.Request, payload interface{}) *httperror.HandlerError {
+	err := json.NewDecoder(r.Body).Decode(payload)
+	if err != nil {
+		return newError(http.StatusBadRequest, "invalidSyntax", "Invalid request payload", err)
+	}
+
+	return nil
+}
+
+func userLocation(id portainer.UserID) string {
+	return basePath + "/Users/" + strconv.Itoa(int(id))
+}
+
+func groupLocation(id portainer.TeamID) string {
+	return basePath + "/Groups/" + strconv.Itoa(int(id))
+}
+
+// directory holds the teams and the memberships used to build the user and group resources
+type directory struct {
+	teams       []portainer.Team
+	users       []portainer.User
+	teamsByID   map[portainer.TeamID]portainer.Team
+	usersByID   map[portainer.UserID]portainer.User
+	memberships []portainer.TeamMembership
+}
+
+func (handler *Handler) loadDirectory() (*directory, error) {
+	teams, err := handler.DataStore.Team().Teams()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the teams")
+	}
+
+	users, err := handler.DataStore.User().Users()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the users")
+	}
+
+	memberships, err := handler.DataStore.TeamMembership().TeamMemberships()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the team memberships")
+	}
+
+	d := &directory{
+		teams:       teams,
+		users:       users,
+		teamsByID:   make(map[portainer.TeamID]portainer.Team, len(teams)),
+		usersByID:   make(map[portainer.UserID]portainer.User, len(users)),
+		memberships: memberships,
+	}
+
+	for _, team := range teams {
+		d.teamsByID[team.ID] = team
+	}
+
+	for _, user := range users {
+		d.usersByID[user.ID] = user
+	}
+
+	return d, nil
+}
+
+func (d *directory) userResource(user *portainer.User) *userResource {
+	resource := &userResource{
+		Schemas:  []string{userSchema},
+		ID:       strconv.Itoa(int(user.ID)),
+		UserName: user.Username,
+		Active:   !user.Disabled,
+		Meta:     meta{ResourceType: "User", Location: userLocation(user.ID)},
+	}
+
+	for _, membership := range d.memberships {
+		team, ok := d.teamsByID[membership.TeamID]
+		if membership.UserID != user.ID || !ok {
+			continue
+		}
+
+		resource.Groups = append(resource.Groups, reference{
+			Value:   strconv.Itoa(int(team.ID)),
+			Ref:     groupLocation(team.ID),
+			Display: team.Name,
+		})
+	}
+
+	return resource
+}
+
+func (d *directory) groupResource(team *portainer.Team) *groupResource {
+	resource := &groupResource{
+		Schemas:     []string{groupSchema},
+		ID:          strconv.Itoa(int(team.ID)),
+		DisplayName: team.Name,
+		Meta:        meta{ResourceType: "Group", Location: groupLocation(team.ID)},
+	}
+
+	for _, membership := range d.memberships {
+		user, ok := d.usersByID[membership.UserID]
+		if membership.TeamID != team.ID || !ok {
+			continue
+		}
+
+		resource.Members = append(resource.Members, reference{
+			Value:   strconv.Itoa(int(user.ID)),
+			Ref:     userLocation(user.ID),
+			Display: user.Username,
+		})
+	}
+
+	return resource
+}
+
+// listOptions are the filtering and pagination parameters of a list request
+type listOptions struct {
+	filter             filter
+	startIndex         int
+	count              int
+	excludedAttributes map[string]bool
+}
+
+const maxResults = 200
+
+func parseListOptions(r *http.Request) (*listOptions, *httperror.HandlerError) {
+	query := r.URL.Query()
+
+	options := &listOptions{startIndex: 1, count: maxResults, excludedAttributes: map[string]bool{}}
+
+	if expression := query.Get("filter"); expression != "" {
+		f, err := parseFilter(expression)
+		if err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidFilter", "Invalid filter", err)
+		}
+		options.filter = f
+	}
+
+	// a start index lower than 1 is interpreted as 1 and a negative count as 0 (RFC 7644 section 3.4.2.4)
+	if value := query.Get("startIndex"); value != "" {
+		startIndex, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidValue", "Invalid startIndex", err)
+		}
+		if startIndex > 1 {
+			options.startIndex = startIndex
+		}
+	}
+
+	if value := query.Get("count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidValue", "Invalid count", err)
+		}
+		if count < 0 {
+			count = 0
+		}
+		if count < maxResults {
+			options.count = count
+		}
+	}
+
+	for _, attribute := range splitAttributes(query.Get("excludedAttributes")) {
+		options.excludedAttributes[attribute] = true
+	}
+
+	return options, nil
+}
+
+// list writes the page of the resources matching the filter of the options
+func (options *listOptions) list(w http.ResponseWriter, resources []interface{}) *httperror.HandlerError {
+	matching := []interface{}{}
+	for _, resource := range resources {
+		if options.filter != nil {
+			attributes, err := attributesOf(resource)
+			if err != nil {
+				return httperror.InternalServerError("Unable to filter the resources", err)
+			}
+
+			if !options.filter.match(attributes) {
+				continue
+			}
+		}
+
+		matching = append(matching, resource)
+	}
+
+	response := &listResponse{
+		Schemas:      []string{listResponseSchema},
+		TotalResults: len(matching),
+		StartIndex:   options.startIndex,
+		Resources:    []interface{}{},
+	}
+
+	if start := options.startIndex - 1; start < len(matching) {
+		end := len(matching)
+		if start+options.count < end {
+			end = start + options.count
+		}
+		response.Resources = matching[start:end]
+	}
+	response.ItemsPerPage = len(response.Resources)
+
+	for _, resource := range response.Resources {
+		options.exclude(resource)
+	}
+
+	return writeJSON(w, http.StatusOK, response)
+}
+
+// attributesOf returns the JSON representation of a resource, against which the filters are evaluated
+func attributesOf(resource interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := map[string]interface{}{}
+	err = json.Unmarshal(data, &attributes)
+	return attributes, err
+}
+
+// exclude removes the excluded attributes from a resource, only the multi-valued attributes can be excluded
+func (options *listOptions) exclude(resource interface{}) {
+	switch resource := resource.(type) {
+	case *userResource:
+		if options.excludedAttributes["groups"] {
+			resource.Groups = nil
+		}
+	case *groupResource:
+		if options.excludedAttributes["members"] {
+			resource.Members = nil
+		}
+	}
+}
+
+// splitAttributes splits a comma separated list of attribute names, the names are lowered and their schema URN removed
+func splitAttributes(value string) []string {
+	attributes := []string{}
+	for _, attribute := range strings.Split(value, ",") {
+		attribute = strings.ToLower(strings.TrimSpace(attribute))
+		if i := strings.LastIndex(attribute, ":"); i != -1 {
+			attribute = attribute[i+1:]
+		}
+
+		if attribute != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+
+	return attributes
+}
@@ -0,0 +1,292 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/pkg/errors"
+)
+
+type userPayload struct {
+	UserName string `json:"userName"`
+	Active   *bool  `json:"active"`
+}
+
+// userList returns the users matching the filter, such as userName eq "bob"
+func (handler *Handler) userList(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	options, httpErr := parseListOptions(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	d, err := handler.loadDirectory()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the users from the database", err)
+	}
+
+	resources := make([]interface{}, 0, len(d.users))
+	for i := range d.users {
+		resources = append(resources, d.userResource(&d.users[i]))
+	}
+
+	return options.list(w, resources)
+}
+
+func (handler *Handler) userInspect(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	user, httpErr := handler.user(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return handler.writeUser(w, http.StatusOK, user)
+}
+
+func (handler *Handler) userCreate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload userPayload
+	httpErr := decodePayload(r, &payload)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	payload.UserName = strings.TrimSpace(payload.UserName)
+	if payload.UserName == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "Invalid request payload", errors.New("the userName attribute is required"))
+	}
+
+	existingUser, err := handler.DataStore.User().UserByUsername(payload.UserName)
+	if err != nil && !handler.DataStore.IsErrObjectNotFound(err) {
+		return httperror.InternalServerError("Unable to retrieve users from the database", err)
+	}
+	if existingUser != nil {
+		return newError(http.StatusConflict, "uniqueness", "A user with the same name already exists", errors.Errorf("the user %s already exists", payload.UserName))
+	}
+
+	user := &portainer.User{
+		Username: payload.UserName,
+		Role:     portainer.StandardUserRole,
+		Disabled: payload.Active != nil && !*payload.Active,
+	}
+	if user.Disabled {
+		user.DisabledBy = portainer.UserDisabledBySCIM
+	}
+
+	err = handler.DataStore.User().Create(user)
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist user inside the database", err)
+	}
+
+	w.Header().Set("Location", userLocation(user.ID))
+	return handler.writeUser(w, http.StatusCreated, user)
+}
+
+func (handler *Handler) userReplace(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	user, httpErr := handler.user(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	var payload userPayload
+	httpErr = decodePayload(r, &payload)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	active := !user.Disabled
+	if payload.Active != nil {
+		active = *payload.Active
+	}
+
+	httpErr = handler.updateUser(user, strings.TrimSpace(payload.UserName), active)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return handler.writeUser(w, http.StatusOK, user)
+}
+
+// userPatch applies the operations on the userName and active attributes, the other attributes are not stored
+// by Portainer and their operations are ignored
+func (handler *Handler) userPatch(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	user, httpErr := handler.user(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	operations, httpErr := decodePatch(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	username, active := user.Username, !user.Disabled
+
+	apply := func(op, path string, value json.RawMessage) *httperror.HandlerError {
+		switch path {
+		case "username":
+			if op == "remove" {
+				return mutabilityError("userName")
+			}
+
+			s, httpErr := decodeString(value)
+			if httpErr != nil {
+				return httpErr
+			}
+			username = strings.TrimSpace(s)
+		case "active":
+			if op == "remove" {
+				return mutabilityError("active")
+			}
+
+			b, httpErr := decodeBool(value)
+			if httpErr != nil {
+				return httpErr
+			}
+			active = b
+		}
+
+		return nil
+	}
+
+	for _, operation := range operations {
+		if operation.Path != "" {
+			httpErr = apply(operation.Op, attributeName(operation.Path), operation.Value)
+			if httpErr != nil {
+				return httpErr
+			}
+			continue
+		}
+
+		if operation.Op == "remove" {
+			return newError(http.StatusBadRequest, "noTarget", "Invalid PatchOp operation", errors.New("the remove operation requires a path"))
+		}
+
+		attributes, httpErr := operation.attributes()
+		if httpErr != nil {
+			return httpErr
+		}
+
+		for attribute, value := range attributes {
+			httpErr = apply(operation.Op, attribute, value)
+			if httpErr != nil {
+				return httpErr
+			}
+		}
+	}
+
+	httpErr = handler.updateUser(user, username, active)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return handler.writeUser(w, http.StatusOK, user)
+}
+
+func (handler *Handler) userDelete(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	user, httpErr := handler.user(r)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if user.ID == 1 {
+		return httperror.Forbidden("Cannot remove the initial admin account", errors.New("Cannot remove the initial admin account"))
+	}
+
+	err := handler.DataStore.User().DeleteUser(user.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to remove user from the database", err)
+	}
+
+	err = handler.DataStore.TeamMembership().DeleteTeamMembershipByUserID(user.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to remove user memberships from the database", err)
+	}
+
+	apiKeys, err := handler.apiKeyService.GetAPIKeys(user.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve user API keys from the database", err)
+	}
+	for _, k := range apiKeys {
+		err = handler.apiKeyService.DeleteAPIKey(k.ID)
+		if err != nil {
+			return httperror.InternalServerError("Unable to remove user API key from the database", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// user returns the user of the id route variable
+func (handler *Handler) user(r *http.Request) (*portainer.User, *httperror.HandlerError) {
+	userID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, httperror.NotFound("Unable to find a user with the specified identifier inside the database", err)
+	}
+
+	user, err := handler.DataStore.User().User(portainer.UserID(userID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return nil, httperror.NotFound("Unable to find a user with the specified identifier inside the database", err)
+	} else if err != nil {
+		return nil, httperror.InternalServerError("Unable to find a user with the specified identifier inside the database", err)
+	}
+
+	return user, nil
+}
+
+// updateUser renames, enables or disables a user. The sessions and the API keys of a disabled user are revoked.
+func (handler *Handler) updateUser(user *portainer.User, username string, active bool) *httperror.HandlerError {
+	if username == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "Invalid request payload", errors.New("the userName attribute is required"))
+	}
+
+	if user.ID == 1 && (username != user.Username || !active) {
+		return newError(http.StatusBadRequest, "mutability", "Cannot rename or disable the initial admin account", errors.New("the initial admin account is managed in Portainer"))
+	}
+
+	if !strings.EqualFold(username, user.Username) {
+		existingUser, err := handler.DataStore.User().UserByUsername(username)
+		if err != nil && !handler.DataStore.IsErrObjectNotFound(err) {
+			return httperror.InternalServerError("Unable to retrieve users from the database", err)
+		}
+		if existingUser != nil {
+			return newError(http.StatusConflict, "uniqueness", "A user with the same name already exists", errors.Errorf("the user %s already exists", username))
+		}
+	}
+
+	disable := !active && !user.Disabled
+
+	user.Username = username
+	user.Disabled = !active
+	user.DisabledBy = ""
+	if user.Disabled {
+		user.DisabledBy = portainer.UserDisabledBySCIM
+	}
+	if disable {
+		user.TokenIssueAt = time.Now().Unix()
+	}
+
+	err := handler.DataStore.User().UpdateUser(user.ID, user)
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	if disable {
+		handler.apiKeyService.InvalidateUserKeyCache(user.ID)
+	}
+
+	return nil
+}
+
+func (handler *Handler) writeUser(w http.ResponseWriter, statusCode int, user *portainer.User) *httperror.HandlerError {
+	d, err := handler.loadDirectory()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the teams from the database", err)
+	}
+
+	return writeJSON(w, statusCode, d.userResource(user))
+}
@@ -15,6 +15,12 @@ func hideFields(settings *portainer.Settings) {
 	settings.LDAPSettings.Password = ""
 	settings.OAuthSettings.ClientSecret = ""
 	settings.OAuthSettings.KubeSecretKey = nil
+	settings.SAMLSettings.PrivateKey = ""
+	settings.SCIMSettings.TokenDigest = nil
+
+	for i := range settings.AuditLog.Forwarders {
+		settings.AuditLog.Forwarders[i].HTTP.AuthorizationHeader = ""
+	}
 }
 
 // Handler is the HTTP handler used to handle settings operations.
@@ -24,6 +30,8 @@ type Handler struct {
 	FileService     portainer.FileService
 	JWTService      dataservices.JWTService
 	LDAPService     portainer.LDAPService
+	OAuthService    portainer.OAuthService
+	SAMLService     portainer.SAMLService
 	SnapshotService portainer.SnapshotService
 	demoService     *demo.Service
 }
@@ -38,6 +46,8 @@ func NewHandler(bouncer *security.RequestBouncer, demoService *demo.Service) *Ha
 		bouncer.AdminAccess(httperror.LoggerHandler(h.settingsInspect))).Methods(http.MethodGet)
 	h.Handle("/settings",
 		bouncer.AdminAccess(httperror.LoggerHandler(h.settingsUpdate))).Methods(http.MethodPut)
+	h.Handle("/settings/scim/token",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.settingsSCIMToken))).Methods(http.MethodPost)
 	h.Handle("/settings/public",
 		bouncer.PublicAccess(httperror.LoggerHandler(h.settingsPublic))).Methods(http.MethodGet)
 
@@ -5,6 +5,7 @@ import (
 
 	httperror "github.com/portainer/libhttp/error"
 	"github.com/portainer/libhttp/response"
+	"github.com/portainer/portainer/api/http/etag"
 )
 
 // @id SettingsInspect
@@ -25,5 +26,6 @@ func (handler *Handler) settingsInspect(w http.ResponseWriter, r *http.Request)
 	}
 
 	hideFields(settings)
+	etag.Set(w, settings.Revision)
 	return response.JSON(w, settings)
 }
@@ -84,16 +84,26 @@ func generatePublicSettings(appSettings *portainer.Settings) *publicSettingsResp
 	//if OAuth authentication is on, compose the related fields from application settings
 	if publicSettings.AuthenticationMethod == portainer.AuthenticationOAuth {
 		publicSettings.OAuthLogoutURI = appSettings.OAuthSettings.LogoutURI
-		publicSettings.OAuthLoginURI = fmt.Sprintf("%s?response_type=code&client_id=%s&redirect_uri=%s&scope=%s",
-			appSettings.OAuthSettings.AuthorizationURI,
-			appSettings.OAuthSettings.ClientID,
-			appSettings.OAuthSettings.RedirectURI,
-			appSettings.OAuthSettings.Scopes)
-		//control prompt=login param according to the SSO setting
-		if !appSettings.OAuthSettings.SSO {
-			publicSettings.OAuthLoginURI += "&prompt=login"
+
+		// OpenID Connect logins are started by Portainer, which keeps the PKCE verifier and the nonce of the login
+		if appSettings.OAuthSettings.OIDC {
+			publicSettings.OAuthLoginURI = fmt.Sprintf("api/auth/oauth/login?client_id=%s", appSettings.OAuthSettings.ClientID)
+		} else {
+			publicSettings.OAuthLoginURI = fmt.Sprintf("%s?response_type=code&client_id=%s&redirect_uri=%s&scope=%s",
+				appSettings.OAuthSettings.AuthorizationURI,
+				appSettings.OAuthSettings.ClientID,
+				appSettings.OAuthSettings.RedirectURI,
+				appSettings.OAuthSettings.Scopes)
+			//control prompt=login param according to the SSO setting
+			if !appSettings.OAuthSettings.SSO {
+				publicSettings.OAuthLoginURI += "&prompt=login"
+			}
 		}
 	}
+	// SAML logins are started by Portainer, which signs the authentication request
+	if publicSettings.AuthenticationMethod == portainer.AuthenticationSAML {
+		publicSettings.OAuthLoginURI = "api/auth/saml/login"
+	}
 	//if LDAP authentication is on, compose the related fields from application settings
 	if publicSettings.AuthenticationMethod == portainer.AuthenticationLDAP && appSettings.LDAPSettings.GroupSearchSettings != nil {
 		if len(appSettings.LDAPSettings.GroupSearchSettings) > 0 {
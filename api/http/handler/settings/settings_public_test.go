@@ -68,3 +68,31 @@ func TestGeneratePublicSettingsWithoutSSO(t *testing.T) {
 		t.Errorf("wrong OAuthLogoutURI, want: %s, got: %s", dummyOAuthLogoutURI, publicSettings.OAuthLogoutURI)
 	}
 }
+
+func TestGeneratePublicSettingsWithOIDC(t *testing.T) {
+	setup()
+	mockAppSettings.OAuthSettings.OIDC = true
+	publicSettings := generatePublicSettings(mockAppSettings)
+
+	want := "api/auth/oauth/login?client_id=" + dummyOAuthClientID
+	if publicSettings.OAuthLoginURI != want {
+		t.Errorf("wrong OAuthLoginURI when OIDC is switched on, want: %s, got: %s", want, publicSettings.OAuthLoginURI)
+	}
+	if publicSettings.OAuthLogoutURI != dummyOAuthLogoutURI {
+		t.Errorf("wrong OAuthLogoutURI, want: %s, got: %s", dummyOAuthLogoutURI, publicSettings.OAuthLogoutURI)
+	}
+}
+
+func TestGeneratePublicSettingsWithSAML(t *testing.T) {
+	setup()
+	mockAppSettings.AuthenticationMethod = portainer.AuthenticationSAML
+	publicSettings := generatePublicSettings(mockAppSettings)
+
+	want := "api/auth/saml/login"
+	if publicSettings.OAuthLoginURI != want {
+		t.Errorf("wrong OAuthLoginURI when SAML is on, want: %s, got: %s", want, publicSettings.OAuthLoginURI)
+	}
+	if publicSettings.OAuthLogoutURI != "" {
+		t.Errorf("wrong OAuthLogoutURI when SAML is on, want an empty URI, got: %s", publicSettings.OAuthLogoutURI)
+	}
+}
@@ -0,0 +1,52 @@
+package settings
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+type scimTokenResponse struct {
+	// Bearer token of the SCIM endpoints, it is only returned once
+	Token string `json:"Token" example:"5u0x9y8tQ2sqHFIG0OEFXPo0w2sDk3rJf5wGH7yQwUk"`
+}
+
+// @id SettingsSCIMToken
+// @summary Generate the SCIM bearer token
+// @description Generate the bearer token the identity provider uses to authenticate on the SCIM endpoints.
+// @description The previous token is revoked. The token is only returned by this request, Portainer keeps its digest.
+// @description **Access policy**: administrator
+// @tags settings
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @success 200 {object} scimTokenResponse "Success"
+// @failure 500 "Server error"
+// @router /settings/scim/token [post]
+func (handler *Handler) settingsSCIMToken(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return httperror.InternalServerError("Unable to generate the SCIM token", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve the settings from the database", err)
+	}
+
+	digest := sha256.Sum256([]byte(token))
+	settings.SCIMSettings.TokenDigest = digest[:]
+
+	err = handler.DataStore.Settings().UpdateSettings(settings)
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist settings changes inside the database", err)
+	}
+
+	return response.JSON(w, &scimTokenResponse{Token: token})
+}
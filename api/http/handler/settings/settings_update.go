@@ -2,6 +2,7 @@ package settings
 
 import (
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
@@ -12,7 +13,10 @@ import (
 	"github.com/portainer/libhttp/request"
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/audit"
+	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/filesystem"
+	"github.com/portainer/portainer/api/http/etag"
 	"github.com/portainer/portainer/api/internal/edge"
 )
 
@@ -21,11 +25,12 @@ type settingsUpdatePayload struct {
 	LogoURL *string `example:"https://mycompany.mydomain.tld/logo.png"`
 	// A list of label name & value that will be used to hide containers when querying containers
 	BlackListedLabels []portainer.Pair
-	// Active authentication method for the Portainer instance. Valid values are: 1 for internal, 2 for LDAP, or 3 for oauth
+	// Active authentication method for the Portainer instance. Valid values are: 1 for internal, 2 for LDAP, 3 for oauth or 4 for SAML
 	AuthenticationMethod *int                            `example:"1"`
 	InternalAuthSettings *portainer.InternalAuthSettings `example:""`
 	LDAPSettings         *portainer.LDAPSettings         `example:""`
 	OAuthSettings        *portainer.OAuthSettings        `example:""`
+	SAMLSettings         *portainer.SAMLSettings         `example:""`
 	// The interval in which environment(endpoint) snapshots are created
 	SnapshotInterval *string `example:"5m"`
 	// URL to the templates that will be displayed in the UI when navigating to App Templates
@@ -50,11 +55,21 @@ type settingsUpdatePayload struct {
 	EnforceEdgeID *bool `example:"false"`
 	// EdgePortainerURL is the URL that is exposed to edge agents
 	EdgePortainerURL *string `json:"EdgePortainerURL"`
+	// Retention policy of the environment(endpoint) snapshot history
+	SnapshotHistory *portainer.SnapshotHistorySettings `example:""`
+	// Public keys the backup archives are encrypted to, an empty list restores the password encryption
+	BackupRecipients *[]portainer.BackupRecipient `example:""`
+	// Retention policy of the audit log
+	AuditLog *portainer.AuditLogSettings `example:""`
+	// Whether the identity provider can provision the users and the teams through the SCIM endpoints
+	EnableSCIM *bool `example:"false"`
+	// Users required to authenticate with a TOTP code. Valid values are: 0 (not required), 1 (administrators) or 2 (everyone)
+	MFARequirement *int `example:"1"`
 }
 
 func (payload *settingsUpdatePayload) Validate(r *http.Request) error {
-	if payload.AuthenticationMethod != nil && *payload.AuthenticationMethod != 1 && *payload.AuthenticationMethod != 2 && *payload.AuthenticationMethod != 3 {
-		return errors.New("Invalid authentication method value. Value must be one of: 1 (internal), 2 (LDAP/AD) or 3 (OAuth)")
+	if payload.AuthenticationMethod != nil && (*payload.AuthenticationMethod < 1 || *payload.AuthenticationMethod > 4) {
+		return errors.New("Invalid authentication method value. Value must be one of: 1 (internal), 2 (LDAP/AD), 3 (OAuth) or 4 (SAML)")
 	}
 	if payload.LogoURL != nil && *payload.LogoURL != "" && !govalidator.IsURL(*payload.LogoURL) {
 		return errors.New("Invalid logo URL. Must correspond to a valid URL format")
@@ -78,6 +93,119 @@ func (payload *settingsUpdatePayload) Validate(r *http.Request) error {
 		}
 	}
 
+	if payload.SnapshotHistory != nil {
+		for _, value := range []string{payload.SnapshotHistory.Retention, payload.SnapshotHistory.DownsampleAfter, payload.SnapshotHistory.DownsampleResolution} {
+			duration, err := time.ParseDuration(value)
+			if err != nil || duration < 0 {
+				return errors.New("Invalid snapshot history settings")
+			}
+		}
+	}
+
+	if payload.BackupRecipients != nil {
+		for _, recipient := range *payload.BackupRecipients {
+			if govalidator.IsNull(recipient.Name) {
+				return errors.New("Invalid backup recipient name")
+			}
+
+			_, err := crypto.ParseRecipient(recipient.PublicKey)
+			if err != nil {
+				return errors.Wrapf(err, "Invalid public key of the backup recipient %s", recipient.Name)
+			}
+		}
+	}
+
+	if payload.InternalAuthSettings != nil {
+		internalAuth := payload.InternalAuthSettings
+
+		if internalAuth.MaxFailedAttempts < 0 {
+			return errors.New("Invalid maximum number of failed authentications")
+		}
+
+		if internalAuth.MaxFailedAttempts > 0 || internalAuth.LockoutDuration != "" {
+			duration, err := time.ParseDuration(internalAuth.LockoutDuration)
+			if err != nil || duration <= 0 {
+				return errors.New("Invalid lockout duration. Must be a positive duration when the lockout is enabled")
+			}
+		}
+
+		if internalAuth.PasswordMaxAge != "" {
+			maxAge, err := time.ParseDuration(internalAuth.PasswordMaxAge)
+			if err != nil || maxAge < 0 {
+				return errors.New("Invalid password maximum age")
+			}
+		}
+
+		if internalAuth.PasswordHistorySize < 0 {
+			return errors.New("Invalid password history size")
+		}
+	}
+
+	if payload.AuditLog != nil {
+		duration, err := time.ParseDuration(payload.AuditLog.Retention)
+		if err != nil || duration < 0 {
+			return errors.New("Invalid audit log retention")
+		}
+
+		names := make(map[string]bool)
+		for _, forwarder := range payload.AuditLog.Forwarders {
+			if names[forwarder.Name] {
+				return errors.Errorf("Duplicate audit log forwarder name %s", forwarder.Name)
+			}
+			names[forwarder.Name] = true
+
+			err := audit.ValidateForwarder(forwarder)
+			if err != nil {
+				return errors.Wrapf(err, "Invalid audit log forwarder %s", forwarder.Name)
+			}
+		}
+	}
+
+	if payload.LDAPSettings != nil && payload.LDAPSettings.GroupSync.Interval != "" {
+		interval, err := time.ParseDuration(payload.LDAPSettings.GroupSync.Interval)
+		if err != nil || interval < time.Minute {
+			return errors.New("Invalid LDAP group synchronisation interval, it must be a duration of at least one minute")
+		}
+	}
+
+	if payload.OAuthSettings != nil {
+		for _, mapping := range payload.OAuthSettings.TeamMemberships.TeamMappings {
+			if govalidator.IsNull(mapping.ClaimValue) || mapping.TeamID == 0 {
+				return errors.New("Invalid OAuth team mapping, the claim value and the team are required")
+			}
+
+			if mapping.Regex {
+				_, err := regexp.Compile(mapping.ClaimValue)
+				if err != nil {
+					return errors.Wrapf(err, "Invalid OAuth team mapping regular expression %s", mapping.ClaimValue)
+				}
+			}
+		}
+	}
+
+	if payload.SAMLSettings != nil {
+		if !govalidator.IsURL(payload.SAMLSettings.ServiceProviderURL) {
+			return errors.New("Invalid SAML service provider URL. Must correspond to the public URL of Portainer")
+		}
+
+		for _, mapping := range payload.SAMLSettings.TeamMemberships.TeamMappings {
+			if govalidator.IsNull(mapping.ClaimValue) || mapping.TeamID == 0 {
+				return errors.New("Invalid SAML team mapping, the attribute value and the team are required")
+			}
+
+			if mapping.Regex {
+				_, err := regexp.Compile(mapping.ClaimValue)
+				if err != nil {
+					return errors.Wrapf(err, "Invalid SAML team mapping regular expression %s", mapping.ClaimValue)
+				}
+			}
+		}
+	}
+
+	if payload.MFARequirement != nil && *payload.MFARequirement != int(portainer.MFANotRequired) && *payload.MFARequirement != int(portainer.MFARequiredForAdministrators) && *payload.MFARequirement != int(portainer.MFARequiredForEveryone) {
+		return errors.New("Invalid MFA requirement value. Value must be one of: 0 (not required), 1 (administrators) or 2 (everyone)")
+	}
+
 	if payload.EdgePortainerURL != nil && *payload.EdgePortainerURL != "" {
 		_, err := edge.ParseHostForEdge(*payload.EdgePortainerURL)
 		if err != nil {
@@ -97,9 +225,11 @@ func (payload *settingsUpdatePayload) Validate(r *http.Request) error {
 // @security jwt
 // @accept json
 // @produce json
+// @param If-Match header string false "ETag of the settings, the update is rejected if the settings were modified since"
 // @param body body settingsUpdatePayload true "New settings"
 // @success 200 {object} portainer.Settings "Success"
 // @failure 400 "Invalid request"
+// @failure 412 "Settings modified since the revision of the If-Match header"
 // @failure 500 "Server error"
 // @router /settings [put]
 func (handler *Handler) settingsUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -109,11 +239,18 @@ func (handler *Handler) settingsUpdate(w http.ResponseWriter, r *http.Request) *
 		return httperror.BadRequest("Invalid request payload", err)
 	}
 
+	unlock := etag.Lock("settings", 0)
+	defer unlock()
+
 	settings, err := handler.DataStore.Settings().Settings()
 	if err != nil {
 		return httperror.InternalServerError("Unable to retrieve the settings from the database", err)
 	}
 
+	if httpErr := etag.CheckIfMatch(r, settings.Revision); httpErr != nil {
+		return httpErr
+	}
+
 	if handler.demoService.IsDemo() {
 		payload.EnableTelemetry = nil
 		payload.LogoURL = nil
@@ -155,7 +292,7 @@ func (handler *Handler) settingsUpdate(w http.ResponseWriter, r *http.Request) *
 	}
 
 	if payload.InternalAuthSettings != nil {
-		settings.InternalAuthSettings.RequiredPasswordLength = payload.InternalAuthSettings.RequiredPasswordLength
+		settings.InternalAuthSettings = *payload.InternalAuthSettings
 	}
 
 	if payload.LDAPSettings != nil {
@@ -184,12 +321,39 @@ func (handler *Handler) settingsUpdate(w http.ResponseWriter, r *http.Request) *
 		settings.OAuthSettings = *payload.OAuthSettings
 		settings.OAuthSettings.ClientSecret = clientSecret
 		settings.OAuthSettings.KubeSecretKey = kubeSecret
+
+		if settings.OAuthSettings.OIDC {
+			err := handler.OAuthService.Discover(&settings.OAuthSettings)
+			if err != nil {
+				return httperror.BadRequest("Unable to discover the OpenID Connect configuration of the issuer", err)
+			}
+		}
+	}
+
+	if payload.SAMLSettings != nil {
+		// the key pair of the service provider is kept unless a new one is provided
+		certificate, privateKey := payload.SAMLSettings.Certificate, payload.SAMLSettings.PrivateKey
+		if privateKey == "" {
+			certificate, privateKey = settings.SAMLSettings.Certificate, settings.SAMLSettings.PrivateKey
+		}
+		settings.SAMLSettings = *payload.SAMLSettings
+		settings.SAMLSettings.Certificate = certificate
+		settings.SAMLSettings.PrivateKey = privateKey
+
+		err := handler.SAMLService.Configure(&settings.SAMLSettings)
+		if err != nil {
+			return httperror.BadRequest("Unable to configure the SAML service provider", err)
+		}
 	}
 
 	if payload.EnableEdgeComputeFeatures != nil {
 		settings.EnableEdgeComputeFeatures = *payload.EnableEdgeComputeFeatures
 	}
 
+	if payload.EnableSCIM != nil {
+		settings.SCIMSettings.Enabled = *payload.EnableSCIM
+	}
+
 	if payload.TrustOnFirstConnect != nil {
 		settings.TrustOnFirstConnect = *payload.TrustOnFirstConnect
 	}
@@ -229,6 +393,32 @@ func (handler *Handler) settingsUpdate(w http.ResponseWriter, r *http.Request) *
 		settings.EnableTelemetry = *payload.EnableTelemetry
 	}
 
+	if payload.SnapshotHistory != nil {
+		settings.SnapshotHistory = *payload.SnapshotHistory
+	}
+
+	if payload.BackupRecipients != nil {
+		settings.BackupRecipients = *payload.BackupRecipients
+	}
+
+	if payload.AuditLog != nil {
+		authorizationHeaders := make(map[string]string)
+		for _, forwarder := range settings.AuditLog.Forwarders {
+			authorizationHeaders[forwarder.Name] = forwarder.HTTP.AuthorizationHeader
+		}
+
+		settings.AuditLog = *payload.AuditLog
+		for i, forwarder := range settings.AuditLog.Forwarders {
+			if forwarder.HTTP.AuthorizationHeader == "" {
+				settings.AuditLog.Forwarders[i].HTTP.AuthorizationHeader = authorizationHeaders[forwarder.Name]
+			}
+		}
+	}
+
+	if payload.MFARequirement != nil {
+		settings.MFARequirement = portainer.MFARequirement(*payload.MFARequirement)
+	}
+
 	tlsError := handler.updateTLS(settings)
 	if tlsError != nil {
 		return tlsError
@@ -243,6 +433,7 @@ func (handler *Handler) settingsUpdate(w http.ResponseWriter, r *http.Request) *
 		return httperror.InternalServerError("Unable to persist settings changes inside the database", err)
 	}
 
+	etag.Set(w, settings.Revision)
 	return response.JSON(w, settings)
 }
 
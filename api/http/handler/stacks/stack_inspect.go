@@ -9,6 +9,7 @@ import (
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
 	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/etag"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/internal/stackutils"
 )
@@ -95,5 +96,6 @@ func (handler *Handler) stackInspect(w http.ResponseWriter, r *http.Request) *ht
 		stack.GitConfig.Authentication.Password = ""
 	}
 
+	etag.Set(w, stack.Revision)
 	return response.JSON(w, stack)
 }
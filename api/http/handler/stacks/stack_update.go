@@ -10,6 +10,7 @@ import (
 	"github.com/portainer/libhttp/response"
 	portainer "github.com/portainer/portainer/api"
 	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/etag"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/internal/stackutils"
 
@@ -63,11 +64,13 @@ func (payload *updateSwarmStackPayload) Validate(r *http.Request) error {
 // @produce json
 // @param id path int true "Stack identifier"
 // @param endpointId query int false "Stacks created before version 1.18.0 might not have an associated environment(endpoint) identifier. Use this optional parameter to set the environment(endpoint) identifier used by the stack."
+// @param If-Match header string false "ETag of the stack, the update is rejected if the stack was modified since"
 // @param body body updateSwarmStackPayload true "Stack details"
 // @success 200 {object} portainer.Stack "Success"
 // @failure 400 "Invalid request"
 // @failure 403 "Permission denied"
 // @failure 404 "Not found"
+// @failure 412 "Stack modified since the revision of the If-Match header"
 // @failure 500 "Server error"
 // @router /stacks/{id} [put]
 func (handler *Handler) stackUpdate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
@@ -76,6 +79,9 @@ func (handler *Handler) stackUpdate(w http.ResponseWriter, r *http.Request) *htt
 		return httperror.BadRequest("Invalid stack identifier route variable", err)
 	}
 
+	unlock := etag.Lock("stacks", stackID)
+	defer unlock()
+
 	stack, err := handler.DataStore.Stack().Stack(portainer.StackID(stackID))
 	if handler.DataStore.IsErrObjectNotFound(err) {
 		return httperror.NotFound("Unable to find a stack with the specified identifier inside the database", err)
@@ -137,6 +143,17 @@ func (handler *Handler) stackUpdate(w http.ResponseWriter, r *http.Request) *htt
 		return httperror.Forbidden(errMsg, errors.New(errMsg))
 	}
 
+	if httpErr := etag.CheckIfMatch(r, stack.Revision); httpErr != nil {
+		return httpErr
+	}
+
+	// the lock is not held during the deployment, the updates checking the current revision are rejected instead
+	err = handler.claimStackRevision(stack.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist the stack changes inside the database", err)
+	}
+	unlock()
+
 	updateError := handler.updateAndDeployStack(r, stack, endpoint)
 	if updateError != nil {
 		return updateError
@@ -160,9 +177,20 @@ func (handler *Handler) stackUpdate(w http.ResponseWriter, r *http.Request) *htt
 		stack.GitConfig.Authentication.Password = ""
 	}
 
+	etag.Set(w, stack.Revision)
 	return response.JSON(w, stack)
 }
 
+// claimStackRevision changes the stored revision of a stack without changing the stack
+func (handler *Handler) claimStackRevision(stackID portainer.StackID) error {
+	stack, err := handler.DataStore.Stack().Stack(stackID)
+	if err != nil {
+		return err
+	}
+
+	return handler.DataStore.Stack().UpdateStack(stackID, stack)
+}
+
 func (handler *Handler) updateAndDeployStack(r *http.Request, stack *portainer.Stack, endpoint *portainer.Endpoint) *httperror.HandlerError {
 	if stack.Type == portainer.DockerSwarmStack {
 		return handler.updateSwarmStack(r, stack, endpoint)
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	dserrors "github.com/portainer/portainer/api/dataservices/errors"
+)
+
+type encryptionKeyRotatePayload struct {
+	// Content of the secret the database is currently encrypted with, including any trailing newline of the secret file
+	CurrentSecret string `example:"current secret" validate:"required"`
+	// Content of the secret to encrypt the database with
+	NewSecret string `example:"new secret" validate:"required"`
+}
+
+func (payload *encryptionKeyRotatePayload) Validate(r *http.Request) error {
+	if payload.CurrentSecret == "" {
+		return errors.New("Invalid current secret")
+	}
+
+	if payload.NewSecret == "" || payload.NewSecret == payload.CurrentSecret {
+		return errors.New("Invalid new secret, it must be different from the current secret")
+	}
+
+	return nil
+}
+
+// @id StoreEncryptionKeyRotate
+// @summary Rotate the database encryption key
+// @description Re-encrypts the database with the key derived from the new secret.
+// @description The database is backed up first, then re-encrypted in a single transaction, it is restored from the backup if any object cannot be read with the new key.
+// @description Write requests are put on hold during the rotation and read requests wait for the re-encryption.
+// @description The new key is not stored, the secret file must be replaced by the new secret before Portainer is restarted.
+// @description **Access policy**: administrator
+// @tags store
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @param body body encryptionKeyRotatePayload true "Current and new secrets"
+// @success 204 "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "The current secret does not match the database encryption key"
+// @failure 500 "Server error"
+// @router /store/encryption/rotate [post]
+func (handler *Handler) encryptionKeyRotate(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload encryptionKeyRotatePayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	// the keys are derived from the secrets the same way they are derived from the secret files
+	currentKey := sha256.Sum256([]byte(payload.CurrentSecret))
+	newKey := sha256.Sum256([]byte(payload.NewSecret))
+
+	unlock := handler.gate.Lock()
+	defer unlock()
+
+	err = handler.dataStore.RotateEncryptionKey(currentKey[:], newKey[:])
+	if errors.Is(err, dserrors.ErrDatabaseNotEncrypted) {
+		return httperror.BadRequest("The database is not encrypted", err)
+	}
+	if errors.Is(err, dserrors.ErrWrongEncryptionKey) {
+		return httperror.Forbidden("The current secret does not match the database encryption key", err)
+	}
+	if err != nil {
+		return httperror.InternalServerError("Unable to rotate the database encryption key", err)
+	}
+
+	return response.Empty(w)
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/database/events"
+)
+
+const (
+	eventsBufferSize        = 100
+	eventsKeepAliveInterval = 15 * time.Second
+)
+
+// buckets whose objects hold no credentials. Apart from the redacted buckets, the objects of the other buckets
+// are never streamed, their events only carry the bucket, the identifier and the operation.
+var streamedBuckets = map[string]bool{
+	"backup_runs":        true,
+	"edge_stack":         true,
+	"edgegroups":         true,
+	"endpoint_groups":    true,
+	"endpoint_relations": true,
+	"resource_control":   true,
+	"roles":              true,
+	"snapshot_history":   true,
+	"tags":               true,
+	"team_membership":    true,
+	"teams":              true,
+	"version":            true,
+}
+
+// redactedBuckets holds the buckets whose objects are streamed once their TLS configuration and credentials are removed
+var redactedBuckets = map[string]func(data json.RawMessage) (json.RawMessage, error){
+	"endpoints": redactObject(func(endpoint *portainer.Endpoint) {
+		endpoint.TLSConfig = portainer.TLSConfiguration{}
+		endpoint.TLS = false
+		endpoint.TLSCACertPath = ""
+		endpoint.TLSCertPath = ""
+		endpoint.TLSKeyPath = ""
+		endpoint.AzureCredentials = portainer.AzureCredentials{}
+		endpoint.EdgeKey = ""
+		for i := range endpoint.Snapshots {
+			endpoint.Snapshots[i].SnapshotRaw = portainer.DockerSnapshotRaw{}
+		}
+	}),
+	"stacks": redactObject(func(stack *portainer.Stack) {
+		stack.Env = nil
+		if stack.GitConfig != nil {
+			stack.GitConfig.Authentication = nil
+		}
+		if stack.AutoUpdate != nil {
+			stack.AutoUpdate.Webhook = ""
+		}
+	}),
+}
+
+// redactObject returns a function removing the credentials of the JSON representation of an object
+func redactObject[T any](redact func(object *T)) func(data json.RawMessage) (json.RawMessage, error) {
+	return func(data json.RawMessage) (json.RawMessage, error) {
+		if data == nil {
+			return nil, nil
+		}
+
+		var object T
+		err := json.Unmarshal(data, &object)
+		if err != nil {
+			return nil, err
+		}
+
+		redact(&object)
+
+		return json.Marshal(object)
+	}
+}
+
+// streamedEvent returns the event as it can be streamed, without the objects that may hold credentials
+func streamedEvent(event events.Event) events.Event {
+	if streamedBuckets[event.Bucket] {
+		return event
+	}
+
+	redact, ok := redactedBuckets[event.Bucket]
+	if !ok {
+		event.Before = nil
+		event.After = nil
+
+		return event
+	}
+
+	var err error
+	event.Before, err = redact(event.Before)
+	if err != nil {
+		event.Before = nil
+	}
+
+	event.After, err = redact(event.After)
+	if err != nil {
+		event.After = nil
+	}
+
+	return event
+}
+
+// @id StoreEvents
+// @summary Stream the datastore changes
+// @description Streams the objects created, updated and deleted in the datastore as server-sent events.
+// @description The name of each event is the operation, its data is the JSON encoded event.
+// @description The objects are only included in the events of the buckets that hold no credentials:
+// @description backup_runs, edge_stack, edgegroups, endpoint_groups, endpoint_relations, resource_control, roles,
+// @description snapshot_history, tags, team_membership, teams and version. The objects of the endpoints and stacks buckets
+// @description are included without their TLS configuration, credentials, environment variables and webhook tokens.
+// @description Events are dropped when the client does not keep up.
+// @description **Access policy**: administrator
+// @tags store
+// @security ApiKeyAuth
+// @security jwt
+// @produce text/event-stream
+// @param bucket query string false "Only stream the events of this bucket"
+// @success 200 {object} events.Event "Success"
+// @failure 500 "Server error"
+// @router /store/events [get]
+func (handler *Handler) storeEvents(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	bucket, _ := request.RetrieveQueryParameter(r, "bucket", true)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return httperror.InternalServerError("Unable to stream the datastore changes", errors.New("streaming is not supported"))
+	}
+
+	subscription, unsubscribe := handler.eventBus.Subscribe(eventsBufferSize)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-keepAlive.C:
+			_, err := fmt.Fprint(w, ": keep-alive\n\n")
+			if err != nil {
+				return nil
+			}
+		case event, ok := <-subscription:
+			if !ok {
+				return nil
+			}
+
+			if bucket != "" && event.Bucket != bucket {
+				continue
+			}
+
+			data, err := json.Marshal(streamedEvent(event))
+			if err != nil {
+				continue
+			}
+
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Operation, data)
+			if err != nil {
+				return nil
+			}
+		}
+
+		flusher.Flush()
+	}
+}
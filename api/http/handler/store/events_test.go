@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/portainer/portainer/api/database/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_storeEvents(t *testing.T) {
+	is := assert.New(t)
+
+	bus := events.NewBus()
+	handler := &Handler{eventBus: bus}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/store/events", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.storeEvents(rr, req)
+	}()
+
+	for !bus.HasSubscribers() {
+		time.Sleep(time.Millisecond)
+	}
+
+	bus.Publish(events.Event{Bucket: "users", ID: "2", Operation: events.OperationUpdate, After: []byte(`{"Password":"secret"}`)})
+	bus.Publish(events.Event{Bucket: "backup_schedules", ID: "3", Operation: events.OperationCreate, After: []byte(`{"Password":"secret"}`)})
+	bus.Publish(events.Event{Bucket: "tags", ID: "4", Operation: events.OperationCreate, After: []byte(`{"Name":"production"}`)})
+
+	// wait for the events to be consumed before closing the stream
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	is.False(bus.HasSubscribers())
+	is.Equal("text/event-stream", rr.Header().Get("Content-Type"))
+
+	body := rr.Body.String()
+	is.True(strings.HasPrefix(body, "event: update\ndata: {\"Bucket\":\"users\",\"Id\":\"2\""), body)
+	is.Contains(body, `"Bucket":"backup_schedules","Id":"3"`)
+	is.NotContains(body, "secret", "the objects of the buckets not known to be safe must not be streamed")
+	is.Contains(body, `"After":{"Name":"production"}`)
+}
+
+func Test_storeEvents_BucketFilter(t *testing.T) {
+	is := assert.New(t)
+
+	bus := events.NewBus()
+	handler := &Handler{eventBus: bus}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/store/events?bucket=tags", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.storeEvents(rr, req)
+	}()
+
+	for !bus.HasSubscribers() {
+		time.Sleep(time.Millisecond)
+	}
+
+	bus.Publish(events.Event{Bucket: "endpoints", ID: "1", Operation: events.OperationCreate})
+	bus.Publish(events.Event{Bucket: "tags", ID: "2", Operation: events.OperationDelete})
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	body := rr.Body.String()
+	is.NotContains(body, "endpoints")
+	is.Contains(body, `"Bucket":"tags","Id":"2"`)
+}
+
+func Test_streamedEvent_RedactsTheCredentials(t *testing.T) {
+	is := assert.New(t)
+
+	event := streamedEvent(events.Event{
+		Bucket:    "endpoints",
+		ID:        "1",
+		Operation: events.OperationUpdate,
+		Before:    []byte(`{"Id":1,"Name":"production","EdgeKey":"edge-key","TLSConfig":{"TLS":true,"TLSKey":"/data/tls/key.pem"},"AzureCredentials":{"AuthenticationKey":"azure-key"}}`),
+		After:     []byte(`{"Id":1,"Name":"staging","EdgeKey":"edge-key"}`),
+	})
+
+	is.Contains(string(event.Before), `"Name":"production"`)
+	is.Contains(string(event.After), `"Name":"staging"`)
+	for _, secret := range []string{"edge-key", "key.pem", "azure-key"} {
+		is.NotContains(string(event.Before), secret)
+		is.NotContains(string(event.After), secret)
+	}
+
+	event = streamedEvent(events.Event{
+		Bucket:    "stacks",
+		ID:        "2",
+		Operation: events.OperationCreate,
+		After:     []byte(`{"Id":2,"Name":"web","Env":[{"name":"DB_PASSWORD","value":"stack-secret"}],"GitConfig":{"URL":"https://git.example.com/web.git","Authentication":{"Password":"git-secret"}},"AutoUpdate":{"Webhook":"webhook-token"}}`),
+	})
+
+	is.Nil(event.Before)
+	is.Contains(string(event.After), `"URL":"https://git.example.com/web.git"`)
+	for _, secret := range []string{"stack-secret", "git-secret", "webhook-token"} {
+		is.NotContains(string(event.After), secret)
+	}
+
+	event = streamedEvent(events.Event{Bucket: "endpoints", ID: "3", Operation: events.OperationCreate, After: []byte(`not json`)})
+	is.Nil(event.After, "the objects that cannot be redacted must not be streamed")
+}
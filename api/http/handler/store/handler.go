@@ -0,0 +1,41 @@
+package store
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/portainer/api/database/events"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/http/offlinegate"
+	"github.com/portainer/portainer/api/http/security"
+)
+
+// Handler is the HTTP handler used to handle datastore maintenance operations.
+type Handler struct {
+	*mux.Router
+	dataStore dataservices.DataStore
+	gate      *offlinegate.OfflineGate
+	eventBus  *events.Bus
+}
+
+// NewHandler creates a handler to manage datastore maintenance operations.
+func NewHandler(bouncer *security.RequestBouncer, dataStore dataservices.DataStore, gate *offlinegate.OfflineGate, eventBus *events.Bus) *Handler {
+	h := &Handler{
+		Router:    mux.NewRouter(),
+		dataStore: dataStore,
+		gate:      gate,
+		eventBus:  eventBus,
+	}
+
+	h.Handle("/store/integrity",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.integrityCheck))).Methods(http.MethodGet)
+	h.Handle("/store/integrity/repair",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.integrityRepair))).Methods(http.MethodPost)
+	h.Handle("/store/encryption/rotate",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.encryptionKeyRotate))).Methods(http.MethodPost)
+	h.Handle("/store/events",
+		bouncer.AdminAccess(httperror.LoggerHandler(h.storeEvents))).Methods(http.MethodGet)
+
+	return h
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"net/http"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/response"
+)
+
+// @id StoreIntegrityCheck
+// @summary Check the integrity of the datastore
+// @description Cross-checks the references between the objects of the datastore and reports the references to objects that no longer exist.
+// @description **Access policy**: administrator
+// @tags store
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @success 200 {object} portainer.StoreIntegrityReport "Success"
+// @failure 500 "Server error"
+// @router /store/integrity [get]
+func (handler *Handler) integrityCheck(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	report, err := handler.dataStore.CheckIntegrity()
+	if err != nil {
+		return httperror.InternalServerError("Unable to check the integrity of the datastore", err)
+	}
+
+	return response.JSON(w, report)
+}
+
+// @id StoreIntegrityRepair
+// @summary Repair the integrity of the datastore
+// @description Backs up the database, then repairs all the references to objects that no longer exist in a single transaction.
+// @description No change is made if any repair fails. The files of the deleted stacks are removed once the repairs are applied.
+// @description **Access policy**: administrator
+// @tags store
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @success 200 {object} portainer.StoreIntegrityReport "Success"
+// @failure 500 "Server error"
+// @router /store/integrity/repair [post]
+func (handler *Handler) integrityRepair(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	report, err := handler.dataStore.RepairIntegrity()
+	if err != nil {
+		return httperror.InternalServerError("Unable to repair the datastore", err)
+	}
+
+	return response.JSON(w, report)
+}
@@ -33,7 +33,7 @@ func Test_teamList(t *testing.T) {
 	jwtService, err := jwt.NewService("1h", store)
 	is.NoError(err, "Error initiating jwt service")
 	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
-	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService)
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
 
 	h := NewHandler(requestBouncer)
 	h.DataStore = store
@@ -26,6 +26,10 @@ var (
 
 func hideFields(user *portainer.User) {
 	user.Password = ""
+	user.MFA.TOTPSecret = ""
+	user.MFA.RecoveryCodes = nil
+	user.MFA.LastTimeStep = 0
+	user.PasswordHistory = nil
 }
 
 // Handler is the HTTP handler used to handle user operations.
@@ -36,6 +40,7 @@ type Handler struct {
 	demoService             *demo.Service
 	DataStore               dataservices.DataStore
 	CryptoService           portainer.CryptoService
+	JWTService              dataservices.JWTService
 	passwordStrengthChecker security.PasswordStrengthChecker
 }
 
@@ -69,11 +74,18 @@ func NewHandler(bouncer *security.RequestBouncer, rateLimiter *security.RateLimi
 	restrictedRouter.Handle("/users/{id}", httperror.LoggerHandler(h.userInspect)).Methods(http.MethodGet)
 	authenticatedRouter.Handle("/users/{id}", httperror.LoggerHandler(h.userUpdate)).Methods(http.MethodPut)
 	adminRouter.Handle("/users/{id}", httperror.LoggerHandler(h.userDelete)).Methods(http.MethodDelete)
+	adminRouter.Handle("/users/{id}/unlock", httperror.LoggerHandler(h.userUnlock)).Methods(http.MethodPost)
 	restrictedRouter.Handle("/users/{id}/tokens", httperror.LoggerHandler(h.userGetAccessTokens)).Methods(http.MethodGet)
 	restrictedRouter.Handle("/users/{id}/tokens", rateLimiter.LimitAccess(httperror.LoggerHandler(h.userCreateAccessToken))).Methods(http.MethodPost)
 	restrictedRouter.Handle("/users/{id}/tokens/{keyID}", httperror.LoggerHandler(h.userRemoveAccessToken)).Methods(http.MethodDelete)
+	restrictedRouter.Handle("/users/{id}/sessions", httperror.LoggerHandler(h.userGetSessions)).Methods(http.MethodGet)
+	restrictedRouter.Handle("/users/{id}/sessions/{sessionID}", httperror.LoggerHandler(h.userRevokeSession)).Methods(http.MethodDelete)
 	restrictedRouter.Handle("/users/{id}/memberships", httperror.LoggerHandler(h.userMemberships)).Methods(http.MethodGet)
 	authenticatedRouter.Handle("/users/{id}/passwd", rateLimiter.LimitAccess(httperror.LoggerHandler(h.userUpdatePassword))).Methods(http.MethodPut)
+	authenticatedRouter.Handle("/users/{id}/mfa", httperror.LoggerHandler(h.userMFAEnrol)).Methods(http.MethodPost)
+	authenticatedRouter.Handle("/users/{id}/mfa", httperror.LoggerHandler(h.userMFADisable)).Methods(http.MethodDelete)
+	authenticatedRouter.Handle("/users/{id}/mfa/confirm", rateLimiter.LimitAccess(httperror.LoggerHandler(h.userMFAConfirm))).Methods(http.MethodPost)
+	authenticatedRouter.Handle("/users/{id}/mfa/recovery_codes", rateLimiter.LimitAccess(httperror.LoggerHandler(h.userMFARecoveryCodes))).Methods(http.MethodPost)
 	publicRouter.Handle("/users/admin/check", httperror.LoggerHandler(h.adminCheck)).Methods(http.MethodGet)
 	publicRouter.Handle("/users/admin/init", httperror.LoggerHandler(h.adminInit)).Methods(http.MethodPost)
 
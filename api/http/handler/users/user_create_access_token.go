@@ -2,7 +2,9 @@ package users
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	httperror "github.com/portainer/libhttp/error"
@@ -15,6 +17,12 @@ import (
 
 type userAccessTokenCreatePayload struct {
 	Description string `validate:"required" example:"github-api-key" json:"description"`
+	// Unix timestamp (UTC) when the API key expires
+	ExpiresAt int64 `validate:"required" example:"1735689600" json:"expiresAt"`
+	// Environments the API key is restricted to
+	EndpointIDs []portainer.EndpointID `example:"12" json:"endpointIds"`
+	// Operations the API key is restricted to
+	Operations []portainer.APIKeyOperation `json:"operations"`
 }
 
 func (payload *userAccessTokenCreatePayload) Validate(r *http.Request) error {
@@ -27,9 +35,32 @@ func (payload *userAccessTokenCreatePayload) Validate(r *http.Request) error {
 	if govalidator.MinStringLength(payload.Description, "128") {
 		return errors.New("invalid description. cannot be longer than 128 characters")
 	}
+	if payload.ExpiresAt == 0 {
+		return errors.New("invalid expiry. cannot be empty")
+	}
+	if payload.ExpiresAt <= time.Now().Unix() {
+		return errors.New("invalid expiry. must be in the future")
+	}
+	for _, operation := range payload.Operations {
+		if !security.ValidateAPIKeyOperation(operation) {
+			return fmt.Errorf("invalid operation path %q. must start with / and can only end with **", operation.Path)
+		}
+	}
 	return nil
 }
 
+// scope returns the restrictions of the API key, nil when the API key carries the full rights of the user
+func (payload *userAccessTokenCreatePayload) scope() *portainer.APIKeyScope {
+	if len(payload.EndpointIDs) == 0 && len(payload.Operations) == 0 {
+		return nil
+	}
+
+	return &portainer.APIKeyScope{
+		EndpointIDs: payload.EndpointIDs,
+		Operations:  payload.Operations,
+	}
+}
+
 type accessTokenResponse struct {
 	RawAPIKey string           `json:"rawAPIKey"`
 	APIKey    portainer.APIKey `json:"apiKey"`
@@ -39,6 +70,9 @@ type accessTokenResponse struct {
 // @summary Generate an API key for a user
 // @description Generates an API key for a user.
 // @description Only the calling user can generate a token for themselves.
+// @description The API key expires and can be restricted to environments and to operations, such as {"method": "PUT", "path": "/stacks/*/git/redeploy"}.
+// @description An API key restricted to environments can only reach the routes of these environments and the routes allowed by its operations.
+// @description Expired API keys are rejected and periodically removed.
 // @description **Access policy**: restricted
 // @tags users
 // @security jwt
@@ -84,7 +118,7 @@ func (handler *Handler) userCreateAccessToken(w http.ResponseWriter, r *http.Req
 		return httperror.BadRequest("Unable to find a user", err)
 	}
 
-	rawAPIKey, apiKey, err := handler.apiKeyService.GenerateApiKey(*user, payload.Description)
+	rawAPIKey, apiKey, err := handler.apiKeyService.GenerateScopedApiKey(*user, payload.Description, payload.ExpiresAt, payload.scope())
 	if err != nil {
 		return httperror.InternalServerError("Internal Server Error", err)
 	}
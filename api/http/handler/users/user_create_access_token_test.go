@@ -37,7 +37,7 @@ func Test_userCreateAccessToken(t *testing.T) {
 	jwtService, err := jwt.NewService("1h", store)
 	is.NoError(err, "Error initiating jwt service")
 	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
-	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService)
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
 	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
 	passwordChecker := security.NewPasswordStrengthChecker(store.SettingsService)
 
@@ -49,7 +49,7 @@ func Test_userCreateAccessToken(t *testing.T) {
 	jwt, _ := jwtService.GenerateToken(&portainer.TokenData{ID: user.ID, Username: user.Username, Role: user.Role})
 
 	t.Run("standard user successfully generates API key", func(t *testing.T) {
-		data := userAccessTokenCreatePayload{Description: "test-token"}
+		data := userAccessTokenCreatePayload{Description: "test-token", ExpiresAt: time.Now().Add(time.Hour).Unix()}
 		payload, err := json.Marshal(data)
 		is.NoError(err)
 
@@ -71,8 +71,37 @@ func Test_userCreateAccessToken(t *testing.T) {
 		is.NotEmpty(resp.RawAPIKey)
 	})
 
+	t.Run("standard user successfully generates a scoped API key", func(t *testing.T) {
+		data := userAccessTokenCreatePayload{
+			Description: "ci-token",
+			ExpiresAt:   time.Now().Add(time.Hour).Unix(),
+			EndpointIDs: []portainer.EndpointID{12},
+			Operations:  []portainer.APIKeyOperation{{Method: http.MethodPut, Path: "/stacks/*/git/redeploy"}},
+		}
+		payload, err := json.Marshal(data)
+		is.NoError(err)
+
+		req := httptest.NewRequest(http.MethodPost, "/users/2/tokens", bytes.NewBuffer(payload))
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		is.Equal(http.StatusCreated, rr.Code)
+
+		var resp accessTokenResponse
+		err = json.NewDecoder(rr.Body).Decode(&resp)
+		is.NoError(err, "response should be json")
+		is.Equal(data.ExpiresAt, resp.APIKey.ExpiresAt)
+		is.Equal(&portainer.APIKeyScope{EndpointIDs: data.EndpointIDs, Operations: data.Operations}, resp.APIKey.Scope)
+
+		apiKey, err := apiKeyService.GetAPIKey(resp.APIKey.ID)
+		is.NoError(err)
+		is.Equal(resp.APIKey.Scope, apiKey.Scope)
+	})
+
 	t.Run("admin cannot generate API key for standard user", func(t *testing.T) {
-		data := userAccessTokenCreatePayload{Description: "test-token-admin"}
+		data := userAccessTokenCreatePayload{Description: "test-token-admin", ExpiresAt: time.Now().Add(time.Hour).Unix()}
 		payload, err := json.Marshal(data)
 		is.NoError(err)
 
@@ -92,7 +121,7 @@ func Test_userCreateAccessToken(t *testing.T) {
 		rawAPIKey, _, err := apiKeyService.GenerateApiKey(*user, "test-api-key")
 		is.NoError(err)
 
-		data := userAccessTokenCreatePayload{Description: "test-token-fails"}
+		data := userAccessTokenCreatePayload{Description: "test-token-fails", ExpiresAt: time.Now().Add(time.Hour).Unix()}
 		payload, err := json.Marshal(data)
 		is.NoError(err)
 
@@ -118,19 +147,23 @@ func Test_userAccessTokenCreatePayload(t *testing.T) {
 		shouldFail bool
 	}{
 		{
-			payload:    userAccessTokenCreatePayload{Description: "test-token"},
+			payload:    userAccessTokenCreatePayload{Description: "test-token", ExpiresAt: time.Now().Add(time.Hour).Unix()},
 			shouldFail: false,
 		},
+		{
+			payload:    userAccessTokenCreatePayload{Description: "test-token"},
+			shouldFail: true,
+		},
 		{
 			payload:    userAccessTokenCreatePayload{Description: ""},
 			shouldFail: true,
 		},
 		{
-			payload:    userAccessTokenCreatePayload{Description: "test token"},
+			payload:    userAccessTokenCreatePayload{Description: "test token", ExpiresAt: time.Now().Add(time.Hour).Unix()},
 			shouldFail: false,
 		},
 		{
-			payload:    userAccessTokenCreatePayload{Description: "test-token "},
+			payload:    userAccessTokenCreatePayload{Description: "test-token ", ExpiresAt: time.Now().Add(time.Hour).Unix()},
 			shouldFail: false,
 		},
 		{
@@ -144,6 +177,24 @@ this string is longer than 128 characters and hence this will fail.
 `},
 			shouldFail: true,
 		},
+		{
+			payload:    userAccessTokenCreatePayload{Description: "ci", ExpiresAt: time.Now().Add(time.Hour).Unix(), EndpointIDs: []portainer.EndpointID{12}},
+			shouldFail: false,
+		},
+		{
+			payload:    userAccessTokenCreatePayload{Description: "ci", EndpointIDs: []portainer.EndpointID{12}},
+			shouldFail: true,
+		},
+		{
+			payload:    userAccessTokenCreatePayload{Description: "ci", ExpiresAt: time.Now().Add(-time.Hour).Unix()},
+			shouldFail: true,
+		},
+		{
+			payload: userAccessTokenCreatePayload{Description: "ci", ExpiresAt: time.Now().Add(time.Hour).Unix(), Operations: []portainer.APIKeyOperation{
+				{Method: http.MethodPut, Path: "/stacks/**/redeploy"},
+			}},
+			shouldFail: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,299 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/mfa"
+)
+
+type userMFACodePayload struct {
+	// TOTP code of the authenticator application
+	Code string `example:"123456" validate:"required"`
+}
+
+func (payload *userMFACodePayload) Validate(r *http.Request) error {
+	if govalidator.IsNull(payload.Code) {
+		return errors.New("Invalid code")
+	}
+
+	return nil
+}
+
+type userMFADisablePayload struct {
+	// TOTP code of the authenticator application, required when users disable their own multi-factor authentication
+	Code string `example:"123456"`
+}
+
+func (payload *userMFADisablePayload) Validate(r *http.Request) error {
+	return nil
+}
+
+type userMFARecoveryCodesResponse struct {
+	// One-time recovery codes, they are only returned once
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// @id UserMFAEnrol
+// @summary Start the multi-factor authentication enrolment of a user
+// @description Generate a TOTP secret for the current user. The multi-factor authentication is enabled once
+// @description the enrolment is confirmed with a code.
+// @description **Access policy**: authenticated
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "User identifier"
+// @success 200 {object} mfa.Enrolment "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "User not found"
+// @failure 409 "The multi-factor authentication is already enabled"
+// @failure 500 "Server error"
+// @router /users/{id}/mfa [post]
+func (handler *Handler) userMFAEnrol(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	user, _, handlerErr := handler.mfaUser(r, false)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	enrolment, err := mfa.StartEnrolment(user)
+	if err == mfa.ErrAlreadyEnabled {
+		return httperror.NewError(http.StatusConflict, "The multi-factor authentication is already enabled", err)
+	} else if err != nil {
+		return httperror.InternalServerError("Unable to generate the TOTP secret", err)
+	}
+
+	err = handler.DataStore.User().UpdateUser(user.ID, user)
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	return response.JSON(w, enrolment)
+}
+
+// @id UserMFAConfirm
+// @summary Confirm the multi-factor authentication enrolment of a user
+// @description Enable the multi-factor authentication of the current user with a code of the secret generated by the enrolment.
+// @description The response holds the recovery codes of the user.
+// @description **Access policy**: authenticated
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "User identifier"
+// @param body body userMFACodePayload true "TOTP code"
+// @success 200 {object} userMFARecoveryCodesResponse "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "User not found"
+// @failure 409 "The multi-factor authentication is already enabled"
+// @failure 422 "Invalid code"
+// @failure 500 "Server error"
+// @router /users/{id}/mfa/confirm [post]
+func (handler *Handler) userMFAConfirm(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload userMFACodePayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	user, _, handlerErr := handler.mfaUser(r, false)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	var recoveryCodes []string
+	var codeErr error
+	now := time.Now()
+	_, err = handler.DataStore.User().UpdateUserFunc(user.ID, func(user *portainer.User) bool {
+		recoveryCodes, codeErr = mfa.ConfirmEnrolment(user, payload.Code, now)
+		return codeErr == nil
+	})
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	switch codeErr {
+	case nil:
+	case mfa.ErrAlreadyEnabled:
+		return httperror.NewError(http.StatusConflict, "The multi-factor authentication is already enabled", codeErr)
+	case mfa.ErrEnrolmentMissing:
+		return httperror.BadRequest("The multi-factor authentication enrolment has not been started", codeErr)
+	case mfa.ErrInvalidCode:
+		return httperror.NewError(http.StatusUnprocessableEntity, "Invalid code", codeErr)
+	default:
+		return httperror.InternalServerError("Unable to generate the recovery codes", codeErr)
+	}
+
+	return response.JSON(w, &userMFARecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// @id UserMFARecoveryCodes
+// @summary Regenerate the recovery codes of a user
+// @description Replace the recovery codes of the current user, the previous codes can no longer be used.
+// @description **Access policy**: authenticated
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @produce json
+// @param id path int true "User identifier"
+// @param body body userMFACodePayload true "TOTP code"
+// @success 200 {object} userMFARecoveryCodesResponse "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "User not found"
+// @failure 422 "Invalid code"
+// @failure 500 "Server error"
+// @router /users/{id}/mfa/recovery_codes [post]
+func (handler *Handler) userMFARecoveryCodes(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload userMFACodePayload
+	err := request.DecodeAndValidateJSONPayload(r, &payload)
+	if err != nil {
+		return httperror.BadRequest("Invalid request payload", err)
+	}
+
+	user, _, handlerErr := handler.mfaUser(r, false)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	var recoveryCodes []string
+	var codeErr, generateErr error
+	now := time.Now()
+	_, err = handler.DataStore.User().UpdateUserFunc(user.ID, func(user *portainer.User) bool {
+		codeErr = mfa.Verify(user, payload.Code, "", now)
+		if codeErr != nil {
+			return false
+		}
+
+		recoveryCodes, generateErr = mfa.RegenerateRecoveryCodes(user)
+		return generateErr == nil
+	})
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	if codeErr == mfa.ErrNotEnabled {
+		return httperror.BadRequest("The multi-factor authentication is not enabled", codeErr)
+	} else if codeErr != nil {
+		return httperror.NewError(http.StatusUnprocessableEntity, "Invalid code", codeErr)
+	}
+
+	if generateErr != nil {
+		return httperror.InternalServerError("Unable to generate the recovery codes", generateErr)
+	}
+
+	return response.JSON(w, &userMFARecoveryCodesResponse{RecoveryCodes: recoveryCodes})
+}
+
+// @id UserMFADisable
+// @summary Disable the multi-factor authentication of a user
+// @description Users can disable their own multi-factor authentication with a code, unless the settings make it mandatory.
+// @description Administrators can reset the multi-factor authentication of any other user, e.g. after the loss of a device.
+// @description **Access policy**: authenticated
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @accept json
+// @param id path int true "User identifier"
+// @param body body userMFADisablePayload false "TOTP code"
+// @success 204 "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "User not found"
+// @failure 422 "Invalid code"
+// @failure 500 "Server error"
+// @router /users/{id}/mfa [delete]
+func (handler *Handler) userMFADisable(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	var payload userMFADisablePayload
+	if r.ContentLength > 0 {
+		err := request.DecodeAndValidateJSONPayload(r, &payload)
+		if err != nil {
+			return httperror.BadRequest("Invalid request payload", err)
+		}
+	}
+
+	user, tokenData, handlerErr := handler.mfaUser(r, true)
+	if handlerErr != nil {
+		return handlerErr
+	}
+
+	checkCode := tokenData.ID == user.ID
+	if checkCode {
+		settings, err := handler.DataStore.Settings().Settings()
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve the settings from the database", err)
+		}
+
+		if mfa.IsMandatory(user, settings) {
+			return httperror.Forbidden("The multi-factor authentication is mandatory", httperrors.ErrUnauthorized)
+		}
+	}
+
+	var codeErr error
+	now := time.Now()
+	_, err := handler.DataStore.User().UpdateUserFunc(user.ID, func(user *portainer.User) bool {
+		if checkCode {
+			codeErr = mfa.Verify(user, payload.Code, "", now)
+			if codeErr != nil {
+				return false
+			}
+		}
+
+		mfa.Disable(user)
+		return true
+	})
+	if err != nil {
+		return httperror.InternalServerError("Unable to persist user changes inside the database", err)
+	}
+
+	if codeErr == mfa.ErrNotEnabled {
+		return httperror.BadRequest("The multi-factor authentication is not enabled", codeErr)
+	} else if codeErr != nil {
+		return httperror.NewError(http.StatusUnprocessableEntity, "Invalid code", codeErr)
+	}
+
+	return response.Empty(w)
+}
+
+// mfaUser returns the user of the route. Only the user itself can manage its multi-factor authentication,
+// administrators are also allowed when allowAdmin is true.
+func (handler *Handler) mfaUser(r *http.Request, allowAdmin bool) (*portainer.User, *portainer.TokenData, *httperror.HandlerError) {
+	userID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return nil, nil, httperror.BadRequest("Invalid user identifier route variable", err)
+	}
+
+	if handler.demoService.IsDemoUser(portainer.UserID(userID)) {
+		return nil, nil, httperror.Forbidden(httperrors.ErrNotAvailableInDemo.Error(), httperrors.ErrNotAvailableInDemo)
+	}
+
+	tokenData, err := security.RetrieveTokenData(r)
+	if err != nil {
+		return nil, nil, httperror.InternalServerError("Unable to retrieve user authentication token", err)
+	}
+
+	if tokenData.ID != portainer.UserID(userID) && !(allowAdmin && tokenData.Role == portainer.AdministratorRole) {
+		return nil, nil, httperror.Forbidden("Permission denied to manage the multi-factor authentication of the user", httperrors.ErrUnauthorized)
+	}
+
+	user, err := handler.DataStore.User().User(portainer.UserID(userID))
+	if handler.DataStore.IsErrObjectNotFound(err) {
+		return nil, nil, httperror.NotFound("Unable to find a user with the specified identifier inside the database", err)
+	} else if err != nil {
+		return nil, nil, httperror.InternalServerError("Unable to find a user with the specified identifier inside the database", err)
+	}
+
+	return user, tokenData, nil
+}
@@ -0,0 +1,136 @@
+package users
+
+import (
+	"net/http"
+	"time"
+
+	httperror "github.com/portainer/libhttp/error"
+	"github.com/portainer/libhttp/request"
+	"github.com/portainer/libhttp/response"
+	portainer "github.com/portainer/portainer/api"
+	bolterrors "github.com/portainer/portainer/api/dataservices/errors"
+	httperrors "github.com/portainer/portainer/api/http/errors"
+	"github.com/portainer/portainer/api/http/security"
+)
+
+type sessionResponse struct {
+	portainer.Session
+	// Whether the session is the one used by the request
+	Current bool `json:"Current" example:"true"`
+}
+
+// @id UserGetSessions
+// @summary Get the active sessions of a user
+// @description Gets the sessions opened by the authentications of a user that are neither expired nor revoked.
+// @description Only the calling user or admin can retrieve the sessions.
+// @description **Access policy**: authenticated
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @produce json
+// @param id path int true "User identifier"
+// @success 200 {array} sessionResponse "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "User not found"
+// @failure 500 "Server error"
+// @router /users/{id}/sessions [get]
+func (handler *Handler) userGetSessions(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	userID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid user identifier route variable", err)
+	}
+
+	tokenData, err := security.RetrieveTokenData(r)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve user authentication token", err)
+	}
+	if tokenData.Role != portainer.AdministratorRole && tokenData.ID != portainer.UserID(userID) {
+		return httperror.Forbidden("Permission denied to get user sessions", httperrors.ErrUnauthorized)
+	}
+
+	user, err := handler.DataStore.User().User(portainer.UserID(userID))
+	if err != nil {
+		if handler.DataStore.IsErrObjectNotFound(err) {
+			return httperror.NotFound("Unable to find a user with the specified identifier inside the database", err)
+		}
+		return httperror.InternalServerError("Unable to find a user with the specified identifier inside the database", err)
+	}
+
+	sessions, err := handler.DataStore.Session().SessionsByUserID(user.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve user sessions", err)
+	}
+
+	now := time.Now().Unix()
+	activeSessions := make([]sessionResponse, 0)
+	for _, session := range sessions {
+		// the tokens issued before TokenIssueAt are rejected, their sessions are over
+		if session.Revoked || session.ExpiresAt <= now || session.CreatedAt < user.TokenIssueAt {
+			continue
+		}
+
+		activeSessions = append(activeSessions, sessionResponse{
+			Session: session,
+			Current: session.ID == tokenData.SessionID,
+		})
+	}
+
+	return response.JSON(w, activeSessions)
+}
+
+// @id UserRevokeSession
+// @summary Revoke a session of a user
+// @description Revokes a session of a user, its token is rejected from now on.
+// @description Only the calling user or admin can revoke a session.
+// @description **Access policy**: authenticated
+// @tags users
+// @security ApiKeyAuth
+// @security jwt
+// @param id path int true "User identifier"
+// @param sessionID path string true "Session identifier"
+// @success 204 "Success"
+// @failure 400 "Invalid request"
+// @failure 403 "Permission denied"
+// @failure 404 "Not found"
+// @failure 500 "Server error"
+// @router /users/{id}/sessions/{sessionID} [delete]
+func (handler *Handler) userRevokeSession(w http.ResponseWriter, r *http.Request) *httperror.HandlerError {
+	userID, err := request.RetrieveNumericRouteVariableValue(r, "id")
+	if err != nil {
+		return httperror.BadRequest("Invalid user identifier route variable", err)
+	}
+
+	sessionID, err := request.RetrieveRouteVariableValue(r, "sessionID")
+	if err != nil {
+		return httperror.BadRequest("Invalid session identifier route variable", err)
+	}
+
+	tokenData, err := security.RetrieveTokenData(r)
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve user authentication token", err)
+	}
+	if tokenData.Role != portainer.AdministratorRole && tokenData.ID != portainer.UserID(userID) {
+		return httperror.Forbidden("Permission denied to revoke user sessions", httperrors.ErrUnauthorized)
+	}
+
+	session, err := handler.DataStore.Session().Session(sessionID)
+	if err != nil {
+		if handler.DataStore.IsErrObjectNotFound(err) {
+			return httperror.NotFound("Unable to find a session with the specified identifier inside the database", err)
+		}
+		return httperror.InternalServerError("Unable to find a session with the specified identifier inside the database", err)
+	}
+
+	// the session of another user is reported as missing to not disclose its existence
+	if session.UserID != portainer.UserID(userID) {
+		return httperror.NotFound("Unable to find a session with the specified identifier inside the database", bolterrors.ErrObjectNotFound)
+	}
+
+	err = handler.JWTService.RevokeSession(session.ID)
+	if err != nil {
+		return httperror.InternalServerError("Unable to revoke the session", err)
+	}
+
+	return response.Empty(w)
+}
@@ -0,0 +1,127 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_userSessions(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	// create admin and standard user(s)
+	adminUser := &portainer.User{Username: "admin", Role: portainer.AdministratorRole}
+	err := store.User().Create(adminUser)
+	is.NoError(err, "error creating admin user")
+
+	user := &portainer.User{Username: "standard", Role: portainer.StandardUserRole}
+	err = store.User().Create(user)
+	is.NoError(err, "error creating user")
+
+	otherUser := &portainer.User{Username: "other", Role: portainer.StandardUserRole}
+	err = store.User().Create(otherUser)
+	is.NoError(err, "error creating user")
+
+	// setup services
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err, "Error initiating jwt service")
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
+	passwordChecker := security.NewPasswordStrengthChecker(store.SettingsService)
+
+	h := NewHandler(requestBouncer, rateLimiter, apiKeyService, nil, passwordChecker)
+	h.DataStore = store
+	h.JWTService = jwtService
+
+	adminJWT, _ := jwtService.GenerateSessionToken(&portainer.TokenData{ID: adminUser.ID, Username: adminUser.Username, Role: adminUser.Role}, "admin-agent", "10.0.0.1")
+	userJWT, _ := jwtService.GenerateSessionToken(&portainer.TokenData{ID: user.ID, Username: user.Username, Role: user.Role}, "user-agent", "10.0.0.2")
+	otherJWT, _ := jwtService.GenerateSessionToken(&portainer.TokenData{ID: otherUser.ID, Username: otherUser.Username, Role: otherUser.Role}, "other-agent", "10.0.0.3")
+
+	listSessions := func(userID portainer.UserID, token string) (int, []sessionResponse) {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d/sessions", userID), nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		var sessions []sessionResponse
+		if rr.Code == http.StatusOK {
+			err := json.NewDecoder(rr.Body).Decode(&sessions)
+			is.NoError(err, "response should be list json")
+		}
+
+		return rr.Code, sessions
+	}
+
+	revokeSession := func(userID portainer.UserID, sessionID, token string) int {
+		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/users/%d/sessions/%s", userID, sessionID), nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	t.Run("standard user can list their own sessions", func(t *testing.T) {
+		code, sessions := listSessions(user.ID, userJWT)
+		is.Equal(http.StatusOK, code)
+		is.Len(sessions, 1)
+		if len(sessions) == 1 {
+			is.Equal("user-agent", sessions[0].UserAgent)
+			is.Equal("10.0.0.2", sessions[0].IPAddress)
+			is.True(sessions[0].Current)
+		}
+	})
+
+	t.Run("standard user cannot list the sessions of another user", func(t *testing.T) {
+		code, _ := listSessions(otherUser.ID, userJWT)
+		is.Equal(http.StatusForbidden, code)
+	})
+
+	t.Run("standard user cannot revoke the session of another user", func(t *testing.T) {
+		_, sessions := listSessions(otherUser.ID, adminJWT)
+		is.Len(sessions, 1)
+		is.False(sessions[0].Current)
+
+		is.Equal(http.StatusForbidden, revokeSession(otherUser.ID, sessions[0].ID, userJWT))
+		is.Equal(http.StatusNotFound, revokeSession(user.ID, sessions[0].ID, userJWT))
+	})
+
+	t.Run("admin can revoke the session of another user", func(t *testing.T) {
+		_, sessions := listSessions(otherUser.ID, adminJWT)
+		is.Len(sessions, 1)
+
+		is.Equal(http.StatusNoContent, revokeSession(otherUser.ID, sessions[0].ID, adminJWT))
+
+		code, _ := listSessions(otherUser.ID, otherJWT)
+		is.Equal(http.StatusUnauthorized, code, "the token of the revoked session must be rejected")
+
+		_, sessions = listSessions(otherUser.ID, adminJWT)
+		is.Len(sessions, 0, "a revoked session must not be listed")
+	})
+
+	t.Run("standard user can revoke their own session", func(t *testing.T) {
+		_, sessions := listSessions(user.ID, userJWT)
+		is.Len(sessions, 1)
+
+		is.Equal(http.StatusNoContent, revokeSession(user.ID, sessions[0].ID, userJWT))
+
+		code, _ := listSessions(user.ID, userJWT)
+		is.Equal(http.StatusUnauthorized, code)
+	})
+}
@@ -12,6 +12,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/passwordpolicy"
 )
 
 type userUpdatePayload struct {
@@ -100,10 +101,20 @@ func (handler *Handler) userUpdate(w http.ResponseWriter, r *http.Request) *http
 	}
 
 	if payload.Password != "" {
-		user.Password, err = handler.CryptoService.Hash(payload.Password)
+		settings, err := handler.DataStore.Settings().Settings()
+		if err != nil {
+			return httperror.InternalServerError("Unable to retrieve settings from the database", err)
+		}
+
+		if tokenData.ID == user.ID && passwordpolicy.IsReused(handler.CryptoService, user, settings.InternalAuthSettings, payload.Password) {
+			return httperror.BadRequest("Password was used recently", errors.New("The new password matches one of the last passwords of the user"))
+		}
+
+		hash, err := handler.CryptoService.Hash(payload.Password)
 		if err != nil {
 			return httperror.InternalServerError("Unable to hash user password", errCryptoHashFailure)
 		}
+		passwordpolicy.SetPassword(user, hash, settings.InternalAuthSettings, time.Now())
 		user.TokenIssueAt = time.Now().Unix()
 	}
 
@@ -122,5 +133,7 @@ func (handler *Handler) userUpdate(w http.ResponseWriter, r *http.Request) *http
 
 	// remove all of the users persisted API keys
 	handler.apiKeyService.InvalidateUserKeyCache(user.ID)
+
+	hideFields(user)
 	return response.JSON(w, user)
 }
@@ -12,6 +12,7 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/internal/passwordpolicy"
 )
 
 type userUpdatePasswordPayload struct {
@@ -34,6 +35,7 @@ func (payload *userUpdatePasswordPayload) Validate(r *http.Request) error {
 // @id UserUpdatePassword
 // @summary Update password for a user
 // @description Update password for the specified user.
+// @description The new password cannot match one of the last passwords of the user kept by the password history.
 // @description **Access policy**: authenticated
 // @tags users
 // @security ApiKeyAuth
@@ -89,10 +91,20 @@ func (handler *Handler) userUpdatePassword(w http.ResponseWriter, r *http.Reques
 		return httperror.BadRequest("Password does not meet the requirements", nil)
 	}
 
-	user.Password, err = handler.CryptoService.Hash(payload.NewPassword)
+	settings, err := handler.DataStore.Settings().Settings()
+	if err != nil {
+		return httperror.InternalServerError("Unable to retrieve settings from the database", err)
+	}
+
+	if passwordpolicy.IsReused(handler.CryptoService, user, settings.InternalAuthSettings, payload.NewPassword) {
+		return httperror.BadRequest("Password was used recently", errors.New("The new password matches one of the last passwords of the user"))
+	}
+
+	hash, err := handler.CryptoService.Hash(payload.NewPassword)
 	if err != nil {
 		return httperror.InternalServerError("Unable to hash user password", errCryptoHashFailure)
 	}
+	passwordpolicy.SetPassword(user, hash, settings.InternalAuthSettings, time.Now())
 
 	user.TokenIssueAt = time.Now().Unix()
 
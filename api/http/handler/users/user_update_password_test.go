@@ -0,0 +1,131 @@
+package users
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/crypto"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/demo"
+	"github.com/portainer/portainer/api/http/security"
+	"github.com/portainer/portainer/api/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_userUpdatePassword_shouldRejectTheRecentPasswords(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	settings, err := store.Settings().Settings()
+	is.NoError(err)
+	settings.InternalAuthSettings.RequiredPasswordLength = 6
+	settings.InternalAuthSettings.PasswordHistorySize = 2
+	err = store.Settings().UpdateSettings(settings)
+	is.NoError(err)
+
+	cryptoService := &crypto.Service{}
+	password, err := cryptoService.Hash("first-password")
+	is.NoError(err)
+
+	user := &portainer.User{ID: 2, Username: "standard", Role: portainer.StandardUserRole, Password: password}
+	err = store.User().Create(user)
+	is.NoError(err, "error creating user")
+
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err, "Error initiating jwt service")
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
+	passwordChecker := security.NewPasswordStrengthChecker(store.SettingsService)
+
+	h := NewHandler(requestBouncer, rateLimiter, apiKeyService, demo.NewService(), passwordChecker)
+	h.DataStore = store
+	h.CryptoService = cryptoService
+
+	updatePassword := func(current, new string) int {
+		// a new token is required after each change, the password change revokes the previous ones
+		token, err := jwtService.GenerateToken(&portainer.TokenData{ID: user.ID, Username: user.Username, Role: user.Role})
+		is.NoError(err)
+
+		body := fmt.Sprintf(`{"Password":%q,"NewPassword":%q}`, current, new)
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/users/%d/passwd", user.ID), bytes.NewBufferString(body))
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	is.Equal(http.StatusBadRequest, updatePassword("first-password", "first-password"))
+	is.Equal(http.StatusNoContent, updatePassword("first-password", "second-password"))
+	is.Equal(http.StatusBadRequest, updatePassword("second-password", "first-password"))
+	is.Equal(http.StatusNoContent, updatePassword("second-password", "third-password"))
+	is.Equal(http.StatusNoContent, updatePassword("third-password", "first-password"))
+
+	user, err = store.User().User(user.ID)
+	is.NoError(err)
+	is.Len(user.PasswordHistory, 1)
+	is.NotZero(user.PasswordChangedAt)
+}
+
+func Test_userUnlock(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	adminUser := &portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}
+	err := store.User().Create(adminUser)
+	is.NoError(err, "error creating admin user")
+
+	user := &portainer.User{ID: 2, Username: "standard", Role: portainer.StandardUserRole, FailedLoginAttempts: 5, LockedAt: time.Now().Unix()}
+	err = store.User().Create(user)
+	is.NoError(err, "error creating user")
+
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err, "Error initiating jwt service")
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
+	passwordChecker := security.NewPasswordStrengthChecker(store.SettingsService)
+
+	h := NewHandler(requestBouncer, rateLimiter, apiKeyService, nil, passwordChecker)
+	h.DataStore = store
+
+	adminJWT, _ := jwtService.GenerateToken(&portainer.TokenData{ID: adminUser.ID, Username: adminUser.Username, Role: adminUser.Role})
+	jwt, _ := jwtService.GenerateToken(&portainer.TokenData{ID: user.ID, Username: user.Username, Role: user.Role})
+
+	t.Run("standard user cannot unlock a user", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/users/2/unlock", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		is.Equal(http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("admin unlocks a user", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/users/2/unlock", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", adminJWT))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		is.Equal(http.StatusNoContent, rr.Code)
+
+		user, err := store.User().User(user.ID)
+		is.NoError(err)
+		is.Zero(user.FailedLoginAttempts)
+		is.Zero(user.LockedAt)
+	})
+}
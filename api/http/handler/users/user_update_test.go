@@ -1,6 +1,8 @@
 package users
 
 import (
+	"bytes"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -8,7 +10,9 @@ import (
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/crypto"
 	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/demo"
 	"github.com/portainer/portainer/api/http/security"
 	"github.com/portainer/portainer/api/jwt"
 	"github.com/stretchr/testify/assert"
@@ -29,7 +33,7 @@ func Test_updateUserRemovesAccessTokens(t *testing.T) {
 	jwtService, err := jwt.NewService("1h", store)
 	is.NoError(err, "Error initiating jwt service")
 	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
-	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService)
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
 	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
 	passwordChecker := security.NewPasswordStrengthChecker(store.SettingsService)
 
@@ -55,3 +59,64 @@ func Test_updateUserRemovesAccessTokens(t *testing.T) {
 		is.Equal(0, len(keys))
 	})
 }
+
+func Test_updateUserRejectsReusedPassword(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	settings, err := store.Settings().Settings()
+	is.NoError(err)
+	settings.InternalAuthSettings.PasswordHistorySize = 2
+	err = store.Settings().UpdateSettings(settings)
+	is.NoError(err)
+
+	cryptoService := &crypto.Service{}
+	password, err := cryptoService.Hash("first-password")
+	is.NoError(err)
+
+	adminUser := &portainer.User{ID: 1, Username: "admin", Role: portainer.AdministratorRole}
+	err = store.User().Create(adminUser)
+	is.NoError(err, "error creating admin user")
+
+	user := &portainer.User{ID: 2, Username: "standard", Role: portainer.StandardUserRole, Password: password}
+	err = store.User().Create(user)
+	is.NoError(err, "error creating user")
+
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err, "Error initiating jwt service")
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	requestBouncer := security.NewRequestBouncer(store, jwtService, apiKeyService, nil)
+	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
+	passwordChecker := security.NewPasswordStrengthChecker(store.SettingsService)
+
+	h := NewHandler(requestBouncer, rateLimiter, apiKeyService, demo.NewService(), passwordChecker)
+	h.DataStore = store
+	h.CryptoService = cryptoService
+
+	updatePassword := func(tokenUser *portainer.User, password string) int {
+		// a new token is required after each change, the password change revokes the previous ones
+		token, err := jwtService.GenerateToken(&portainer.TokenData{ID: tokenUser.ID, Username: tokenUser.Username, Role: tokenUser.Role})
+		is.NoError(err)
+
+		body := fmt.Sprintf(`{"Password":%q}`, password)
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/users/%d", user.ID), bytes.NewBufferString(body))
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	t.Run("user cannot reuse a recent password", func(t *testing.T) {
+		is.Equal(http.StatusBadRequest, updatePassword(user, "first-password"))
+		is.Equal(http.StatusOK, updatePassword(user, "second-password"))
+		is.Equal(http.StatusBadRequest, updatePassword(user, "first-password"))
+	})
+
+	t.Run("admin can reset the password of a user", func(t *testing.T) {
+		is.Equal(http.StatusOK, updatePassword(adminUser, "first-password"))
+	})
+}
@@ -49,6 +49,12 @@ func WithEndpoint(endpointService dataservices.EndpointService, endpointIDParam
 	}
 }
 
+// StoreEndpoint returns a copy of the request holding the environment(endpoint) in its context, so that the handlers
+// down the chain can use it without reading it again from the database
+func StoreEndpoint(request *http.Request, endpoint *portainer.Endpoint) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), contextEndpoint, endpoint))
+}
+
 func FetchEndpoint(request *http.Request) (*portainer.Endpoint, error) {
 	contextData := request.Context().Value(contextEndpoint)
 	if contextData == nil {
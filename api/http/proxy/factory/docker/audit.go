@@ -0,0 +1,25 @@
+package docker
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/portainer/portainer/api/audit"
+)
+
+// auditResource returns the Docker resource targeted by a request, from its path stripped of the API version
+func auditResource(method, requestPath string) audit.Resource {
+	resource := audit.ParseResource(method, requestPath)
+
+	// image names can contain slashes, e.g. /images/portainer/agent:latest/push
+	if resource.Type == "images" && resource.ID != "" {
+		name := strings.TrimPrefix(requestPath, "/images/")
+		if method == http.MethodPost {
+			name = strings.TrimSuffix(name, "/"+resource.Operation)
+		}
+
+		resource.ID = name
+	}
+
+	return resource
+}
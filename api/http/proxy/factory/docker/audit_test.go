@@ -0,0 +1,27 @@
+package docker
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/portainer/portainer/api/audit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_auditResource(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected audit.Resource
+	}{
+		{http.MethodPost, "/containers/3bf1c4c5e2a1/restart", audit.Resource{Type: "containers", ID: "3bf1c4c5e2a1", Operation: "restart"}},
+		{http.MethodPost, "/images/create", audit.Resource{Type: "images", Operation: "create"}},
+		{http.MethodPost, "/images/portainer/agent:latest/push", audit.Resource{Type: "images", ID: "portainer/agent:latest", Operation: "push"}},
+		{http.MethodDelete, "/images/portainer/agent:latest", audit.Resource{Type: "images", ID: "portainer/agent:latest", Operation: "delete"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, auditResource(test.method, test.path), "%s %s", test.method, test.path)
+	}
+}
@@ -0,0 +1,270 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/middlewares"
+	"github.com/portainer/portainer/api/http/proxy/factory/utils"
+)
+
+type offlineContextKey int
+
+const offlineSnapshotKey offlineContextKey = iota
+
+type offlineErrorResponse struct {
+	Message string `json:"message"`
+}
+
+// offlineSnapshot returns the latest snapshot of the environment(endpoint) when it is marked as down,
+// nil otherwise. The status is the one of the environment(endpoint) loaded by the proxy handler for the request,
+// the environment(endpoint) of the transport is used when the request does not hold one.
+// Edge environments(endpoints) are never considered offline as their status is not maintained by the snapshot service.
+func (transport *Transport) offlineSnapshot(request *http.Request) *portainer.DockerSnapshot {
+	endpoint, err := middlewares.FetchEndpoint(request)
+	if err != nil || endpoint.ID != transport.endpoint.ID {
+		endpoint = transport.endpoint
+	}
+
+	if endpoint.Type == portainer.EdgeAgentOnDockerEnvironment || endpoint.Status != portainer.EndpointStatusDown || len(endpoint.Snapshots) == 0 {
+		return nil
+	}
+
+	return &endpoint.Snapshots[len(endpoint.Snapshots)-1]
+}
+
+// withOfflineSnapshot marks the request to be answered from the snapshot instead of the Docker API
+func withOfflineSnapshot(request *http.Request, snapshot *portainer.DockerSnapshot) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), offlineSnapshotKey, snapshot))
+}
+
+func offlineSnapshotFromRequest(request *http.Request) (*portainer.DockerSnapshot, bool) {
+	snapshot, ok := request.Context().Value(offlineSnapshotKey).(*portainer.DockerSnapshot)
+	return snapshot, ok
+}
+
+// isReadOnlyRequest returns true when the request does not modify the environment(endpoint)
+func isReadOnlyRequest(request *http.Request) bool {
+	return request.Method == http.MethodGet || request.Method == http.MethodHead
+}
+
+// writeOfflineResponse creates a response flagged as stale, holding data coming from the snapshot.
+// The response to a HEAD request only holds the headers.
+func writeOfflineResponse(request *http.Request, snapshot *portainer.DockerSnapshot, data interface{}, statusCode int) (*http.Response, error) {
+	response := &http.Response{
+		Request: request,
+		Header:  http.Header{},
+	}
+	response.Header.Set("Content-Type", "application/json")
+	response.Header.Set(portainer.PortainerStaleSnapshotHeader, strconv.FormatInt(snapshot.Time, 10))
+
+	err := utils.RewriteResponse(response, data, statusCode)
+	if err != nil {
+		return response, err
+	}
+
+	if request.Method == http.MethodHead {
+		response.Body = http.NoBody
+	}
+
+	return response, nil
+}
+
+// writeOfflineErrorResponse creates an error response for a request that cannot be served while the environment(endpoint) is down
+func writeOfflineErrorResponse(request *http.Request, snapshot *portainer.DockerSnapshot, message string) (*http.Response, error) {
+	return writeOfflineResponse(request, snapshot, offlineErrorResponse{Message: message}, http.StatusServiceUnavailable)
+}
+
+// executeOfflineRequest answers a read-only Docker API request using the snapshot of the environment(endpoint).
+// Only containers, images, volumes, networks, info and version are available.
+func executeOfflineRequest(request *http.Request, snapshot *portainer.DockerSnapshot) (*http.Response, error) {
+	if !isReadOnlyRequest(request) {
+		return writeOfflineErrorResponse(request, snapshot, "the environment is unreachable, only read operations are available")
+	}
+
+	raw := snapshot.SnapshotRaw
+	requestPath := request.URL.Path
+
+	switch {
+	case requestPath == "/info":
+		return writeOfflineResponse(request, snapshot, raw.Info, http.StatusOK)
+
+	case requestPath == "/version":
+		return writeOfflineResponse(request, snapshot, raw.Version, http.StatusOK)
+
+	case requestPath == "/containers/json":
+		return writeOfflineResponse(request, snapshot, filterOfflineContainers(request, raw.Containers), http.StatusOK)
+
+	case matchPath("/containers/*/json", requestPath):
+		containerID := path.Base(path.Dir(requestPath))
+		for _, container := range raw.Containers {
+			if matchContainer(container, containerID) {
+				return writeOfflineResponse(request, snapshot, containerInspectFromSummary(container), http.StatusOK)
+			}
+		}
+
+		return writeOfflineNotFoundResponse(request, snapshot, "container", containerID)
+
+	case requestPath == "/images/json":
+		return writeOfflineResponse(request, snapshot, raw.Images, http.StatusOK)
+
+	case strings.HasPrefix(requestPath, "/images/") && strings.HasSuffix(requestPath, "/json"):
+		// image names can contain slashes, e.g. /images/portainer/portainer-ce:latest/json
+		imageName := strings.TrimSuffix(strings.TrimPrefix(requestPath, "/images/"), "/json")
+		for _, image := range raw.Images {
+			if matchImage(image, imageName) {
+				return writeOfflineResponse(request, snapshot, imageInspectFromSummary(image), http.StatusOK)
+			}
+		}
+
+		return writeOfflineNotFoundResponse(request, snapshot, "image", imageName)
+
+	case requestPath == "/volumes":
+		return writeOfflineResponse(request, snapshot, raw.Volumes, http.StatusOK)
+
+	case matchPath("/volumes/*", requestPath):
+		volumeName := path.Base(requestPath)
+		for _, volume := range raw.Volumes.Volumes {
+			if volume != nil && volume.Name == volumeName {
+				return writeOfflineResponse(request, snapshot, volume, http.StatusOK)
+			}
+		}
+
+		return writeOfflineNotFoundResponse(request, snapshot, "volume", volumeName)
+
+	case requestPath == "/networks":
+		return writeOfflineResponse(request, snapshot, raw.Networks, http.StatusOK)
+
+	case matchPath("/networks/*", requestPath):
+		networkID := path.Base(requestPath)
+		for _, network := range raw.Networks {
+			if network.Name == networkID || strings.HasPrefix(network.ID, networkID) {
+				return writeOfflineResponse(request, snapshot, network, http.StatusOK)
+			}
+		}
+
+		return writeOfflineNotFoundResponse(request, snapshot, "network", networkID)
+	}
+
+	return writeOfflineErrorResponse(request, snapshot, "the environment is unreachable, this operation is not available")
+}
+
+func writeOfflineNotFoundResponse(request *http.Request, snapshot *portainer.DockerSnapshot, resourceType, resourceID string) (*http.Response, error) {
+	return writeOfflineResponse(request, snapshot, offlineErrorResponse{Message: fmt.Sprintf("No such %s: %s", resourceType, resourceID)}, http.StatusNotFound)
+}
+
+func matchPath(pattern, requestPath string) bool {
+	match, _ := path.Match(pattern, requestPath)
+	return match
+}
+
+// filterOfflineContainers mimics the all query parameter of the ContainerList operation,
+// the snapshot holding both running and stopped containers
+func filterOfflineContainers(request *http.Request, containers []types.Container) []types.Container {
+	all, _ := strconv.ParseBool(request.URL.Query().Get("all"))
+	if all {
+		return containers
+	}
+
+	runningContainers := make([]types.Container, 0)
+	for _, container := range containers {
+		if container.State == "running" {
+			runningContainers = append(runningContainers, container)
+		}
+	}
+
+	return runningContainers
+}
+
+func matchContainer(container types.Container, containerID string) bool {
+	if strings.HasPrefix(container.ID, containerID) {
+		return true
+	}
+
+	for _, name := range container.Names {
+		if strings.TrimPrefix(name, "/") == strings.TrimPrefix(containerID, "/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchImage(image types.ImageSummary, imageName string) bool {
+	if image.ID == imageName || strings.HasPrefix(strings.TrimPrefix(image.ID, "sha256:"), imageName) {
+		return true
+	}
+
+	for _, tag := range image.RepoTags {
+		if tag == imageName || tag == imageName+":latest" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containerInspectFromSummary builds a ContainerInspect response from the information available
+// in the ContainerList response stored in the snapshot
+func containerInspectFromSummary(summary types.Container) types.ContainerJSON {
+	name := ""
+	if len(summary.Names) > 0 {
+		name = summary.Names[0]
+	}
+
+	networks := map[string]*network.EndpointSettings{}
+	if summary.NetworkSettings != nil {
+		networks = summary.NetworkSettings.Networks
+	}
+
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:      summary.ID,
+			Name:    name,
+			Created: time.Unix(summary.Created, 0).UTC().Format(time.RFC3339Nano),
+			Image:   summary.ImageID,
+			State: &types.ContainerState{
+				Status:  summary.State,
+				Running: summary.State == "running",
+				Paused:  summary.State == "paused",
+			},
+			HostConfig: &container.HostConfig{
+				NetworkMode: container.NetworkMode(summary.HostConfig.NetworkMode),
+			},
+		},
+		Mounts: summary.Mounts,
+		Config: &container.Config{
+			Image:  summary.Image,
+			Labels: summary.Labels,
+		},
+		NetworkSettings: &types.NetworkSettings{
+			Networks: networks,
+		},
+	}
+}
+
+// imageInspectFromSummary builds an ImageInspect response from the information available
+// in the ImageList response stored in the snapshot
+func imageInspectFromSummary(summary types.ImageSummary) types.ImageInspect {
+	return types.ImageInspect{
+		ID:          summary.ID,
+		RepoTags:    summary.RepoTags,
+		RepoDigests: summary.RepoDigests,
+		Parent:      summary.ParentID,
+		Created:     time.Unix(summary.Created, 0).UTC().Format(time.RFC3339Nano),
+		Size:        summary.Size,
+		VirtualSize: summary.VirtualSize,
+		Config: &container.Config{
+			Labels: summary.Labels,
+		},
+	}
+}
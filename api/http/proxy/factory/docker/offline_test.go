@@ -0,0 +1,122 @@
+package docker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/http/middlewares"
+	"github.com/portainer/portainer/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func newOfflineSnapshot() *portainer.DockerSnapshot {
+	return &portainer.DockerSnapshot{
+		Time: 1667300000,
+		SnapshotRaw: portainer.DockerSnapshotRaw{
+			Containers: []types.Container{
+				{ID: "aaaa1111", Names: []string{"/web"}, State: "running"},
+				{ID: "bbbb2222", Names: []string{"/job"}, State: "exited"},
+			},
+			Images: []types.ImageSummary{
+				{ID: "sha256:cccc3333", RepoTags: []string{"portainer/portainer-ce:latest"}},
+			},
+			Info: types.Info{Name: "docker-host"},
+		},
+	}
+}
+
+func Test_executeOfflineRequest(t *testing.T) {
+	snapshot := newOfflineSnapshot()
+
+	tests := []struct {
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{http.MethodGet, "/containers/json", http.StatusOK, `"Id":"aaaa1111"`},
+		{http.MethodGet, "/containers/json?all=1", http.StatusOK, `"Id":"bbbb2222"`},
+		{http.MethodGet, "/containers/web/json", http.StatusOK, `"Id":"aaaa1111"`},
+		{http.MethodGet, "/containers/bbbb/json", http.StatusOK, `"Status":"exited"`},
+		{http.MethodGet, "/containers/unknown/json", http.StatusNotFound, "No such container: unknown"},
+		{http.MethodGet, "/images/portainer/portainer-ce/json", http.StatusOK, `"Id":"sha256:cccc3333"`},
+		{http.MethodGet, "/info", http.StatusOK, `"Name":"docker-host"`},
+		{http.MethodGet, "/containers/aaaa1111/logs", http.StatusServiceUnavailable, "this operation is not available"},
+		{http.MethodPost, "/containers/aaaa1111/start", http.StatusServiceUnavailable, "only read operations are available"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path, func(t *testing.T) {
+			is := assert.New(t)
+
+			response, err := executeOfflineRequest(httptest.NewRequest(test.method, test.path, nil), snapshot)
+			is.NoError(err)
+			is.Equal(test.expectedStatus, response.StatusCode)
+			is.Equal("1667300000", response.Header.Get(portainer.PortainerStaleSnapshotHeader))
+
+			var body json.RawMessage
+			is.NoError(json.NewDecoder(response.Body).Decode(&body))
+			is.Contains(string(body), test.expectedBody)
+		})
+	}
+
+	response, err := executeOfflineRequest(httptest.NewRequest(http.MethodGet, "/containers/json", nil), snapshot)
+	assert.NoError(t, err)
+
+	var containers []types.Container
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&containers))
+	assert.Len(t, containers, 1, "stopped containers should only be listed with all=1")
+
+	response, err = executeOfflineRequest(httptest.NewRequest(http.MethodHead, "/containers/json", nil), snapshot)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, http.NoBody, response.Body, "the response to a HEAD request should not have a body")
+}
+
+func TestTransport_offlineSnapshot(t *testing.T) {
+	is := assert.New(t)
+
+	up := portainer.Endpoint{ID: 1, Type: portainer.DockerEnvironment, Status: portainer.EndpointStatusUp, Snapshots: []portainer.DockerSnapshot{*newOfflineSnapshot()}}
+	down := up
+	down.Status = portainer.EndpointStatusDown
+
+	// the transport does not read the environment from the database
+	transport := &Transport{endpoint: &up}
+
+	request := httptest.NewRequest(http.MethodGet, "/containers/json", nil)
+	is.Nil(transport.offlineSnapshot(request))
+	is.NotNil(transport.offlineSnapshot(middlewares.StoreEndpoint(request, &down)), "the status of the environment of the request should be used")
+
+	transport.endpoint = &down
+	is.NotNil(transport.offlineSnapshot(request))
+	is.Nil(transport.offlineSnapshot(middlewares.StoreEndpoint(request, &up)))
+
+	edge := down
+	edge.Type = portainer.EdgeAgentOnDockerEnvironment
+	is.Nil(transport.offlineSnapshot(middlewares.StoreEndpoint(request, &edge)), "edge environments should never be offline")
+}
+
+func TestTransport_ProxyDockerRequest_rejectsWritesWhenOffline(t *testing.T) {
+	is := assert.New(t)
+
+	endpoint := portainer.Endpoint{
+		ID:        1,
+		Type:      portainer.DockerEnvironment,
+		Status:    portainer.EndpointStatusDown,
+		Snapshots: []portainer.DockerSnapshot{*newOfflineSnapshot()},
+	}
+
+	transport := &Transport{
+		endpoint:  &endpoint,
+		dataStore: testhelpers.NewDatastore(testhelpers.WithEndpoints([]portainer.Endpoint{endpoint})),
+	}
+
+	response, err := transport.ProxyDockerRequest(httptest.NewRequest(http.MethodPost, "/v1.41/containers/create", nil))
+	is.NoError(err)
+	is.Equal(http.StatusServiceUnavailable, response.StatusCode)
+	is.NotEmpty(response.Header.Get(portainer.PortainerStaleSnapshotHeader))
+}
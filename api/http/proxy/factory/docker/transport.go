@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/audit"
 	"github.com/portainer/portainer/api/dataservices"
 	dataerrors "github.com/portainer/portainer/api/dataservices/errors"
 	"github.com/portainer/portainer/api/docker"
@@ -89,6 +90,8 @@ func (transport *Transport) ProxyDockerRequest(request *http.Request) (*http.Res
 	requestPath := apiVersionRe.ReplaceAllString(request.URL.Path, "")
 	request.URL.Path = requestPath
 
+	audit.SetResource(request, auditResource(request.Method, requestPath))
+
 	if transport.endpoint.Type == portainer.AgentOnDockerEnvironment || transport.endpoint.Type == portainer.EdgeAgentOnDockerEnvironment {
 		signature, err := transport.signatureService.CreateSignature(portainer.PortainerAgentSignatureMessage)
 		if err != nil {
@@ -99,6 +102,14 @@ func (transport *Transport) ProxyDockerRequest(request *http.Request) (*http.Res
 		request.Header.Set(portainer.PortainerAgentSignatureHeader, signature)
 	}
 
+	if snapshot := transport.offlineSnapshot(request); snapshot != nil {
+		if !isReadOnlyRequest(request) {
+			return writeOfflineErrorResponse(request, snapshot, "the environment is unreachable, only read operations are available")
+		}
+
+		request = withOfflineSnapshot(request, snapshot)
+	}
+
 	switch {
 	case strings.HasPrefix(requestPath, "/configs"):
 		return transport.proxyConfigRequest(request)
@@ -130,6 +141,10 @@ func (transport *Transport) ProxyDockerRequest(request *http.Request) (*http.Res
 }
 
 func (transport *Transport) executeDockerRequest(request *http.Request) (*http.Response, error) {
+	if snapshot, ok := offlineSnapshotFromRequest(request); ok {
+		return executeOfflineRequest(request, snapshot)
+	}
+
 	response, err := transport.HTTPTransport.RoundTrip(request)
 
 	if transport.endpoint.Type != portainer.EdgeAgentOnDockerEnvironment {
@@ -0,0 +1,51 @@
+package kubernetes
+
+import (
+	"strings"
+
+	"github.com/portainer/portainer/api/audit"
+)
+
+// auditResource returns the Kubernetes resource targeted by a request. The resource type is the
+// resource kind and the identifier of a namespaced resource is prefixed with its namespace.
+func auditResource(method, requestPath string) audit.Resource {
+	segments := strings.Split(strings.Trim(requestPath, "/"), "/")
+
+	// strip the API group and version: /api/v1 or /apis/{group}/{version}
+	if len(segments) > 0 && segments[0] == "kubernetes" {
+		segments = segments[1:]
+	}
+
+	switch {
+	case len(segments) >= 2 && segments[0] == "api":
+		segments = segments[2:]
+	case len(segments) >= 3 && segments[0] == "apis":
+		segments = segments[3:]
+	}
+
+	resource := audit.Resource{Operation: audit.Operation(method)}
+	if len(segments) == 0 {
+		return resource
+	}
+
+	namespace := ""
+	if segments[0] == "namespaces" && len(segments) >= 3 {
+		namespace = segments[1]
+		segments = segments[2:]
+	}
+
+	resource.Type = segments[0]
+	if len(segments) > 1 {
+		resource.ID = segments[1]
+		if namespace != "" {
+			resource.ID = namespace + "/" + segments[1]
+		}
+	}
+
+	// sub-resources such as scale or status
+	if len(segments) > 2 {
+		resource.Operation = segments[2]
+	}
+
+	return resource
+}
@@ -0,0 +1,28 @@
+package kubernetes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/portainer/portainer/api/audit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_auditResource(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected audit.Resource
+	}{
+		{http.MethodPost, "/kubernetes/api/v1/namespaces", audit.Resource{Type: "namespaces", Operation: "create"}},
+		{http.MethodDelete, "/api/v1/namespaces/default", audit.Resource{Type: "namespaces", ID: "default", Operation: "delete"}},
+		{http.MethodPost, "/apis/apps/v1/namespaces/default/deployments", audit.Resource{Type: "deployments", Operation: "create"}},
+		{http.MethodPatch, "/apis/apps/v1/namespaces/default/deployments/web/scale", audit.Resource{Type: "deployments", ID: "default/web", Operation: "scale"}},
+		{http.MethodDelete, "/api/v1/nodes/node-1", audit.Resource{Type: "nodes", ID: "node-1", Operation: "delete"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, auditResource(test.method, test.path), "%s %s", test.method, test.path)
+	}
+}
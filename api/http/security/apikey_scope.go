@@ -0,0 +1,141 @@
+package security
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+)
+
+// AuthorizedAPIKeyEndpoint returns true when an environment(endpoint) is in the scope of an API key.
+// A nil scope, the scope of the requests which are not authenticated with a scoped API key, authorizes every environment.
+func AuthorizedAPIKeyEndpoint(scope *portainer.APIKeyScope, endpointID portainer.EndpointID) bool {
+	if scope == nil || len(scope.EndpointIDs) == 0 {
+		return true
+	}
+
+	for _, id := range scope.EndpointIDs {
+		if id == endpointID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authorizedAPIKeyRequest returns true when a request is in the scope of an API key: its operation is one of the
+// operations of the scope and the environment(endpoint) of its path or of its endpointId query parameter is in the scope.
+// A scope restricted to environments(endpoints) without operations only authorizes the requests targeting one of them,
+// the routes without environment(endpoint), such as /users or /settings, have to be allowed by an operation.
+func authorizedAPIKeyRequest(scope *portainer.APIKeyScope, r *http.Request) bool {
+	if scope == nil {
+		return true
+	}
+
+	path := apiPath(r)
+
+	endpointID, ok := requestEndpointID(path, r)
+	if ok && !AuthorizedAPIKeyEndpoint(scope, endpointID) {
+		return false
+	}
+
+	if len(scope.Operations) == 0 {
+		return ok || len(scope.EndpointIDs) == 0
+	}
+
+	for _, operation := range scope.Operations {
+		if matchAPIKeyOperation(operation, r.Method, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAPIKeyOperation returns true when a method and a path match an operation. An empty method matches every method.
+func matchAPIKeyOperation(operation portainer.APIKeyOperation, method, path string) bool {
+	if operation.Method != "" && !strings.EqualFold(operation.Method, method) {
+		return false
+	}
+
+	patternSegments := strings.Split(strings.Trim(operation.Path, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, segment := range patternSegments {
+		if segment == "**" && i == len(patternSegments)-1 {
+			return true
+		}
+
+		if i >= len(pathSegments) || (segment != "*" && segment != pathSegments[i]) {
+			return false
+		}
+	}
+
+	return len(patternSegments) == len(pathSegments)
+}
+
+// ValidateAPIKeyOperation validates the path pattern of an operation, ** is only allowed as the last segment
+func ValidateAPIKeyOperation(operation portainer.APIKeyOperation) bool {
+	if !strings.HasPrefix(operation.Path, "/") {
+		return false
+	}
+
+	segments := strings.Split(strings.Trim(operation.Path, "/"), "/")
+	for i, segment := range segments {
+		if segment == "**" && i != len(segments)-1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// apiPath returns the path of a request relative to /api
+func apiPath(r *http.Request) string {
+	path := r.URL.Path
+	if path == "/api" || strings.HasPrefix(path, "/api/") {
+		return strings.TrimPrefix(path, "/api")
+	}
+
+	return path
+}
+
+// endpointRoutes are the routes whose segment following the route name is the identifier of an environment(endpoint)
+var endpointRoutes = map[string]bool{
+	"endpoints":  true,
+	"kubernetes": true,
+	"docker":     true,
+	"open_amt":   true,
+}
+
+// endpointQueryParameters are the query parameters holding the identifier of an environment(endpoint)
+var endpointQueryParameters = []string{"endpointId", "environmentId"}
+
+// requestEndpointID returns the environment(endpoint) targeted by a request, such as /endpoints/12/docker/containers/json,
+// /kubernetes/12/namespaces, /endpoint_groups/1/endpoints/12 or /stacks?endpointId=12
+func requestEndpointID(path string, r *http.Request) (portainer.EndpointID, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) > 1 && endpointRoutes[segments[0]] {
+		id, err := strconv.Atoi(segments[1])
+		if err == nil {
+			return portainer.EndpointID(id), true
+		}
+	}
+
+	if len(segments) > 3 && segments[0] == "endpoint_groups" && segments[2] == "endpoints" {
+		id, err := strconv.Atoi(segments[3])
+		if err == nil {
+			return portainer.EndpointID(id), true
+		}
+	}
+
+	for _, parameter := range endpointQueryParameters {
+		id, err := strconv.Atoi(r.URL.Query().Get(parameter))
+		if err == nil {
+			return portainer.EndpointID(id), true
+		}
+	}
+
+	return 0, false
+}
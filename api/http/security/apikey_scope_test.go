@@ -0,0 +1,157 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_matchAPIKeyOperation(t *testing.T) {
+	tests := []struct {
+		operation portainer.APIKeyOperation
+		method    string
+		path      string
+		match     bool
+	}{
+		{portainer.APIKeyOperation{Method: "PUT", Path: "/stacks/*/git/redeploy"}, http.MethodPut, "/stacks/3/git/redeploy", true},
+		{portainer.APIKeyOperation{Method: "put", Path: "/stacks/*/git/redeploy"}, http.MethodPut, "/stacks/3/git/redeploy", true},
+		{portainer.APIKeyOperation{Method: "PUT", Path: "/stacks/*/git/redeploy"}, http.MethodPost, "/stacks/3/git/redeploy", false},
+		{portainer.APIKeyOperation{Method: "PUT", Path: "/stacks/*/git/redeploy"}, http.MethodPut, "/stacks/3/git", false},
+		{portainer.APIKeyOperation{Method: "PUT", Path: "/stacks/*/git/redeploy"}, http.MethodPut, "/stacks/3/git/redeploy/now", false},
+		{portainer.APIKeyOperation{Path: "/stacks/*"}, http.MethodDelete, "/stacks/3", true},
+		{portainer.APIKeyOperation{Method: "GET", Path: "/endpoints/12/docker/**"}, http.MethodGet, "/endpoints/12/docker/containers/json", true},
+		{portainer.APIKeyOperation{Method: "GET", Path: "/endpoints/12/docker/**"}, http.MethodGet, "/endpoints/12/docker", true},
+		{portainer.APIKeyOperation{Method: "GET", Path: "/endpoints/12/docker/**"}, http.MethodGet, "/endpoints/12/kubernetes/api", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.match, matchAPIKeyOperation(test.operation, test.method, test.path), "%+v %s %s", test.operation, test.method, test.path)
+	}
+}
+
+func Test_requestEndpointID(t *testing.T) {
+	tests := []struct {
+		url        string
+		endpointID portainer.EndpointID
+		found      bool
+	}{
+		{"/api/endpoints/12/docker/containers/json", 12, true},
+		{"/api/kubernetes/13/namespaces", 13, true},
+		{"/api/docker/14/containers/abc/gpus", 14, true},
+		{"/api/open_amt/15/info", 15, true},
+		{"/api/endpoint_groups/1/endpoints/16", 16, true},
+		{"/api/stacks?endpointId=17", 17, true},
+		{"/api/websocket/exec?environmentId=18", 18, true},
+		{"/api/kubernetes/config", 0, false},
+		{"/api/users", 0, false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+
+		endpointID, found := requestEndpointID(apiPath(req), req)
+		assert.Equal(t, test.found, found, test.url)
+		assert.Equal(t, test.endpointID, endpointID, test.url)
+	}
+}
+
+func Test_AuthenticatedAccess_shouldEnforceTheScopeOfTheAPIKey(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	user := &portainer.User{ID: 2, Username: "ci", Role: portainer.AdministratorRole}
+	err := store.User().Create(user)
+	is.NoError(err, "error creating user")
+
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err, "Error initiating jwt service")
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := NewRequestBouncer(store, jwtService, apiKeyService, nil)
+
+	scope := &portainer.APIKeyScope{
+		EndpointIDs: []portainer.EndpointID{12},
+		Operations: []portainer.APIKeyOperation{
+			{Method: http.MethodPut, Path: "/stacks/*/git/redeploy"},
+			{Method: http.MethodGet, Path: "/kubernetes/*/namespaces"},
+		},
+	}
+	rawAPIKey, _, err := apiKeyService.GenerateScopedApiKey(*user, "ci", time.Now().Add(time.Hour).Unix(), scope)
+	is.NoError(err)
+
+	tests := []struct {
+		method         string
+		url            string
+		wantStatusCode int
+	}{
+		{http.MethodPut, "/api/stacks/3/git/redeploy?endpointId=12", http.StatusOK},
+		{http.MethodPut, "/api/stacks/3/git/redeploy?endpointId=13", http.StatusForbidden},
+		{http.MethodDelete, "/api/stacks/3?endpointId=12", http.StatusForbidden},
+		{http.MethodPut, "/api/endpoints/13/docker/stacks/3/git/redeploy", http.StatusForbidden},
+		{http.MethodGet, "/api/kubernetes/12/namespaces", http.StatusOK},
+		{http.MethodGet, "/api/kubernetes/13/namespaces", http.StatusForbidden},
+		{http.MethodGet, "/api/users", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.url, nil)
+		req.Header.Add("x-api-key", rawAPIKey)
+
+		rr := httptest.NewRecorder()
+		bouncer.AuthenticatedAccess(testHandler200).ServeHTTP(rr, req)
+		is.Equal(test.wantStatusCode, rr.Code, "%s %s", test.method, test.url)
+	}
+
+	t.Run("an API key restricted to environments only reaches the routes of these environments", func(t *testing.T) {
+		scope := &portainer.APIKeyScope{EndpointIDs: []portainer.EndpointID{12}}
+		rawAPIKey, _, err := apiKeyService.GenerateScopedApiKey(*user, "ci", time.Now().Add(time.Hour).Unix(), scope)
+		is.NoError(err)
+
+		tests := []struct {
+			url            string
+			wantStatusCode int
+		}{
+			{"/api/endpoints/12/docker/containers/json", http.StatusOK},
+			{"/api/stacks?endpointId=12", http.StatusOK},
+			{"/api/endpoints/13/docker/containers/json", http.StatusForbidden},
+			{"/api/kubernetes/config", http.StatusForbidden},
+			{"/api/users", http.StatusForbidden},
+			{"/api/settings", http.StatusForbidden},
+		}
+
+		for _, test := range tests {
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			req.Header.Add("x-api-key", rawAPIKey)
+
+			rr := httptest.NewRecorder()
+			bouncer.AuthenticatedAccess(testHandler200).ServeHTTP(rr, req)
+			is.Equal(test.wantStatusCode, rr.Code, test.url)
+		}
+	})
+
+	t.Run("AuthorizedEndpointOperation denies the environments out of the scope to an administrator", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		tokenData := &portainer.TokenData{ID: user.ID, Role: portainer.AdministratorRole, APIKeyScope: scope}
+		req = req.WithContext(StoreTokenData(req, tokenData))
+
+		is.NoError(bouncer.AuthorizedEndpointOperation(req, &portainer.Endpoint{ID: 12}))
+		is.Error(bouncer.AuthorizedEndpointOperation(req, &portainer.Endpoint{ID: 13}))
+	})
+}
+
+func Test_FilterEndpoints_shouldFilterTheEnvironmentsOutOfTheScopeOfTheAPIKey(t *testing.T) {
+	endpoints := []portainer.Endpoint{{ID: 11}, {ID: 12}}
+
+	context := &RestrictedRequestContext{IsAdmin: true, APIKeyScope: &portainer.APIKeyScope{EndpointIDs: []portainer.EndpointID{12}}}
+
+	assert.Equal(t, []portainer.Endpoint{{ID: 12}}, FilterEndpoints(endpoints, nil, context))
+}
@@ -10,6 +10,7 @@ import (
 	httperror "github.com/portainer/libhttp/error"
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/audit"
 	"github.com/portainer/portainer/api/dataservices"
 	httperrors "github.com/portainer/portainer/api/http/errors"
 )
@@ -20,6 +21,7 @@ type (
 		dataStore     dataservices.DataStore
 		jwtService    dataservices.JWTService
 		apiKeyService apikey.APIKeyService
+		auditService  *audit.Service
 	}
 
 	// RestrictedRequestContext is a data structure containing information
@@ -29,6 +31,8 @@ type (
 		IsTeamLeader    bool
 		UserID          portainer.UserID
 		UserMemberships []portainer.TeamMembership
+		// Restrictions of the API key authenticating the request, nil for the other authentication methods
+		APIKeyScope *portainer.APIKeyScope
 	}
 
 	// tokenLookup looks up a token in the request
@@ -38,11 +42,13 @@ type (
 const apiKeyHeader = "X-API-KEY"
 
 // NewRequestBouncer initializes a new RequestBouncer
-func NewRequestBouncer(dataStore dataservices.DataStore, jwtService dataservices.JWTService, apiKeyService apikey.APIKeyService) *RequestBouncer {
+// The operations of the authenticated chains are recorded in the audit log when auditService is not nil
+func NewRequestBouncer(dataStore dataservices.DataStore, jwtService dataservices.JWTService, apiKeyService apikey.APIKeyService, auditService *audit.Service) *RequestBouncer {
 	return &RequestBouncer{
 		dataStore:     dataStore,
 		jwtService:    jwtService,
 		apiKeyService: apiKeyService,
+		auditService:  auditService,
 	}
 }
 
@@ -53,6 +59,12 @@ func (bouncer *RequestBouncer) PublicAccess(h http.Handler) http.Handler {
 	return h
 }
 
+// AuditedAccess records the operations of public API environments(endpoints) in the audit log,
+// such as the authentication attempts. It must wrap the other security checks, including the rate limiter.
+func (bouncer *RequestBouncer) AuditedAccess(h http.Handler) http.Handler {
+	return bouncer.mwAudit(h)
+}
+
 // AdminAccess defines a security check for API environments(endpoints) that require an authorization check.
 // Authentication is required to access these environments(endpoints).
 // The administrator role is required to use these environments(endpoints).
@@ -112,6 +124,10 @@ func (bouncer *RequestBouncer) AuthorizedEndpointOperation(r *http.Request, endp
 		return err
 	}
 
+	if !AuthorizedAPIKeyEndpoint(tokenData.APIKeyScope, endpoint.ID) {
+		return httperrors.ErrEndpointAccessDenied
+	}
+
 	if tokenData.Role == portainer.AdministratorRole {
 		return nil
 	}
@@ -167,15 +183,24 @@ func (bouncer *RequestBouncer) AuthorizedEdgeEndpointOperation(r *http.Request,
 // mwAuthenticatedUser authenticates a request by
 // - adding a secure handlers to the response
 // - authenticating the request with a valid token
+// - recording the operations modifying a resource in the audit log
 func (bouncer *RequestBouncer) mwAuthenticatedUser(h http.Handler) http.Handler {
 	h = bouncer.mwAuthenticateFirst([]tokenLookup{
 		bouncer.JWTAuthLookup,
 		bouncer.apiKeyLookup,
 	}, h)
 	h = mwSecureHeaders(h)
+	h = bouncer.mwAudit(h)
 	return h
 }
 
+// mwAudit records the operations modifying a resource in the audit log
+func (bouncer *RequestBouncer) mwAudit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bouncer.auditService.Middleware(next).ServeHTTP(w, r)
+	})
+}
+
 // mwCheckPortainerAuthorizations will verify that the user has the required authorization to access
 // a specific API environment(endpoint).
 // If the administratorOnly flag is specified, this will prevent non-admin
@@ -227,6 +252,8 @@ func (bouncer *RequestBouncer) mwUpgradeToRestrictedRequest(next http.Handler) h
 			return
 		}
 
+		requestContext.APIKeyScope = tokenData.APIKeyScope
+
 		ctx := StoreRestrictedRequestContext(r, requestContext)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -278,6 +305,13 @@ func (bouncer *RequestBouncer) mwAuthenticateFirst(tokenLookups []tokenLookup, n
 			return
 		}
 
+		audit.SetUser(r, token.ID, token.Username)
+
+		if !authorizedAPIKeyRequest(token.APIKeyScope, r) {
+			httperror.WriteError(w, http.StatusForbidden, "Access denied", errors.New("The request is not in the scope of the API key"))
+			return
+		}
+
 		ctx := StoreTokenData(r, token)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -303,6 +337,7 @@ func (bouncer *RequestBouncer) JWTAuthLookup(r *http.Request) *portainer.TokenDa
 // - computing the digest of the raw api-key
 // - verifying it exists in cache/database
 // - matching the key to a user (ID, Role)
+// - verifying it is not expired
 // If the key is valid/verified, the last updated time of the key is updated.
 // Successful verification of the key will return a TokenData object - since the downstream handlers
 // utilise the token injected in the request context.
@@ -315,14 +350,16 @@ func (bouncer *RequestBouncer) apiKeyLookup(r *http.Request) *portainer.TokenDat
 	digest := bouncer.apiKeyService.HashRaw(rawAPIKey)
 
 	user, apiKey, err := bouncer.apiKeyService.GetDigestUserAndKey(digest)
-	if err != nil {
+	if err != nil || user.Disabled || apikey.IsExpired(apiKey, time.Now()) {
 		return nil
 	}
 
 	tokenData := &portainer.TokenData{
-		ID:       user.ID,
-		Username: user.Username,
-		Role:     user.Role,
+		ID:              user.ID,
+		Username:        user.Username,
+		Role:            user.Role,
+		APIKeyScope:     apiKey.Scope,
+		APIKeyExpiresAt: apiKey.ExpiresAt,
 	}
 	if _, err := bouncer.jwtService.GenerateToken(tokenData); err != nil {
 		return nil
@@ -332,6 +369,8 @@ func (bouncer *RequestBouncer) apiKeyLookup(r *http.Request) *portainer.TokenDat
 	apiKey.LastUsed = time.Now().UTC().Unix()
 	bouncer.apiKeyService.UpdateAPIKey(&apiKey)
 
+	audit.SetAPIKey(r, apiKey.ID)
+
 	return tokenData
 }
 
@@ -5,9 +5,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/audit"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/datastore"
 	httperrors "github.com/portainer/portainer/api/http/errors"
@@ -44,7 +46,7 @@ func Test_mwAuthenticateFirst(t *testing.T) {
 
 	apiKeyService := apikey.NewAPIKeyService(nil, nil)
 
-	bouncer := NewRequestBouncer(store, jwtService, apiKeyService)
+	bouncer := NewRequestBouncer(store, jwtService, apiKeyService, nil)
 
 	tests := []struct {
 		name                   string
@@ -271,7 +273,7 @@ func Test_apiKeyLookup(t *testing.T) {
 	jwtService, err := jwt.NewService("1h", store)
 	is.NoError(err, "Error initiating jwt service")
 	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
-	bouncer := NewRequestBouncer(store, jwtService, apiKeyService)
+	bouncer := NewRequestBouncer(store, jwtService, apiKeyService, nil)
 
 	t.Run("missing x-api-key header fails api-key lookup", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -332,4 +334,70 @@ func Test_apiKeyLookup(t *testing.T) {
 
 		is.True(apiKeyUpdated.LastUsed > apiKey.LastUsed)
 	})
+
+	t.Run("expired x-api-key header fails api-key lookup", func(t *testing.T) {
+		rawAPIKey, apiKey, err := apiKeyService.GenerateScopedApiKey(*user, "test", time.Now().Add(-time.Minute).Unix(), nil)
+		is.NoError(err)
+		defer apiKeyService.DeleteAPIKey(apiKey.ID)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Add("x-api-key", rawAPIKey)
+
+		token := bouncer.apiKeyLookup(req)
+		is.Nil(token)
+	})
+
+	t.Run("scoped x-api-key header succeeds api-key lookup with the scope of the key", func(t *testing.T) {
+		scope := &portainer.APIKeyScope{EndpointIDs: []portainer.EndpointID{12}}
+		expiresAt := time.Now().Add(time.Hour).Unix()
+		rawAPIKey, apiKey, err := apiKeyService.GenerateScopedApiKey(*user, "test", expiresAt, scope)
+		is.NoError(err)
+		defer apiKeyService.DeleteAPIKey(apiKey.ID)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Add("x-api-key", rawAPIKey)
+
+		token := bouncer.apiKeyLookup(req)
+
+		expectedToken := &portainer.TokenData{ID: user.ID, Username: user.Username, Role: portainer.StandardUserRole, APIKeyScope: scope, APIKeyExpiresAt: expiresAt}
+		is.Equal(expectedToken, token)
+	})
+}
+
+func Test_AdminAccess_shouldRecordAuditLog(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	user := &portainer.User{ID: 2, Username: "standard", Role: portainer.StandardUserRole}
+	err := store.User().Create(user)
+	is.NoError(err, "error creating user")
+
+	jwtService, err := jwt.NewService("1h", store)
+	is.NoError(err, "Error initiating jwt service")
+	apiKeyService := apikey.NewAPIKeyService(store.APIKeyRepository(), store.User())
+	bouncer := NewRequestBouncer(store, jwtService, apiKeyService, audit.NewService(store, t.TempDir()))
+
+	rawAPIKey, apiKey, err := apiKeyService.GenerateApiKey(*user, "test")
+	is.NoError(err)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/tags/3", nil)
+	req.Header.Add("x-api-key", rawAPIKey)
+
+	rr := httptest.NewRecorder()
+	bouncer.AdminAccess(testHandler200).ServeHTTP(rr, req)
+	is.Equal(http.StatusForbidden, rr.Code)
+
+	auditLogs, err := store.AuditLog().AuditLogs(nil)
+	is.NoError(err)
+	is.Len(auditLogs, 1)
+	is.Equal(user.ID, auditLogs[0].UserID)
+	is.Equal(user.Username, auditLogs[0].Username)
+	is.Equal(portainer.AuditLogAuthMethodAPIKey, auditLogs[0].AuthMethod)
+	is.Equal(apiKey.ID, auditLogs[0].APIKeyID)
+	is.Equal("tags", auditLogs[0].ResourceType)
+	is.Equal("3", auditLogs[0].ResourceID)
+	is.Equal("delete", auditLogs[0].Operation)
+	is.Equal(portainer.AuditLogOutcomeDenied, auditLogs[0].Outcome)
 }
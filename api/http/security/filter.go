@@ -86,9 +86,22 @@ func FilterRegistries(registries []portainer.Registry, user *portainer.User, tea
 
 // FilterEndpoints filters environments(endpoints) based on user role and team memberships.
 // Non administrator only have access to authorized environments(endpoints) (can be inherited via endpoint groups).
+// A request authenticated with a scoped API key only has access to the environments(endpoints) of the scope.
 func FilterEndpoints(endpoints []portainer.Endpoint, groups []portainer.EndpointGroup, context *RestrictedRequestContext) []portainer.Endpoint {
 	filteredEndpoints := endpoints
 
+	if context.APIKeyScope != nil && len(context.APIKeyScope.EndpointIDs) > 0 {
+		filteredEndpoints = make([]portainer.Endpoint, 0)
+
+		for _, endpoint := range endpoints {
+			if AuthorizedAPIKeyEndpoint(context.APIKeyScope, endpoint.ID) {
+				filteredEndpoints = append(filteredEndpoints, endpoint)
+			}
+		}
+
+		endpoints = filteredEndpoints
+	}
+
 	if !context.IsAdmin {
 		filteredEndpoints = make([]portainer.Endpoint, 0)
 
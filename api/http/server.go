@@ -11,11 +11,15 @@ import (
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/adminmonitor"
 	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/audit"
+	operations "github.com/portainer/portainer/api/backup"
 	"github.com/portainer/portainer/api/crypto"
+	"github.com/portainer/portainer/api/database/events"
 	"github.com/portainer/portainer/api/dataservices"
 	"github.com/portainer/portainer/api/demo"
 	"github.com/portainer/portainer/api/docker"
 	"github.com/portainer/portainer/api/http/handler"
+	"github.com/portainer/portainer/api/http/handler/auditlogs"
 	"github.com/portainer/portainer/api/http/handler/auth"
 	"github.com/portainer/portainer/api/http/handler/backup"
 	"github.com/portainer/portainer/api/http/handler/customtemplates"
@@ -39,10 +43,12 @@ import (
 	"github.com/portainer/portainer/api/http/handler/registries"
 	"github.com/portainer/portainer/api/http/handler/resourcecontrols"
 	"github.com/portainer/portainer/api/http/handler/roles"
+	"github.com/portainer/portainer/api/http/handler/scim"
 	"github.com/portainer/portainer/api/http/handler/settings"
 	sslhandler "github.com/portainer/portainer/api/http/handler/ssl"
 	"github.com/portainer/portainer/api/http/handler/stacks"
 	"github.com/portainer/portainer/api/http/handler/status"
+	"github.com/portainer/portainer/api/http/handler/store"
 	"github.com/portainer/portainer/api/http/handler/storybook"
 	"github.com/portainer/portainer/api/http/handler/tags"
 	"github.com/portainer/portainer/api/http/handler/teammemberships"
@@ -63,6 +69,7 @@ import (
 	"github.com/portainer/portainer/api/scheduler"
 	stackdeployer "github.com/portainer/portainer/api/stacks"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
@@ -81,12 +88,14 @@ type Server struct {
 	SnapshotService             portainer.SnapshotService
 	FileService                 portainer.FileService
 	DataStore                   dataservices.DataStore
+	EventBus                    *events.Bus
 	GitService                  portainer.GitService
 	OpenAMTService              portainer.OpenAMTService
 	APIKeyService               apikey.APIKeyService
 	JWTService                  dataservices.JWTService
 	LDAPService                 portainer.LDAPService
 	OAuthService                portainer.OAuthService
+	SAMLService                 portainer.SAMLService
 	SwarmStackManager           portainer.SwarmStackManager
 	ProxyManager                *proxy.Manager
 	KubernetesTokenCacheManager *kubernetes.TokenCacheManager
@@ -108,13 +117,19 @@ type Server struct {
 func (server *Server) Start() error {
 	kubernetesTokenCacheManager := server.KubernetesTokenCacheManager
 
-	requestBouncer := security.NewRequestBouncer(server.DataStore, server.JWTService, server.APIKeyService)
+	auditService := audit.NewService(server.DataStore, filepath.Join(server.FileService.GetDatastorePath(), "audit_forwarding"))
+	auditService.Start(server.Scheduler)
+
+	requestBouncer := security.NewRequestBouncer(server.DataStore, server.JWTService, server.APIKeyService, auditService)
 
 	rateLimiter := security.NewRateLimiter(10, 1*time.Second, 1*time.Hour)
 	offlineGate := offlinegate.NewOfflineGate()
 
 	passwordStrengthChecker := security.NewPasswordStrengthChecker(server.DataStore.Settings())
 
+	var auditLogHandler = auditlogs.NewHandler(requestBouncer)
+	auditLogHandler.DataStore = server.DataStore
+
 	var authHandler = auth.NewHandler(requestBouncer, rateLimiter, passwordStrengthChecker)
 	authHandler.DataStore = server.DataStore
 	authHandler.CryptoService = server.CryptoService
@@ -123,10 +138,18 @@ func (server *Server) Start() error {
 	authHandler.ProxyManager = server.ProxyManager
 	authHandler.KubernetesTokenCacheManager = kubernetesTokenCacheManager
 	authHandler.OAuthService = server.OAuthService
+	authHandler.SAMLService = server.SAMLService
+	authHandler.APIKeyService = server.APIKeyService
 
 	adminMonitor := adminmonitor.New(5*time.Minute, server.DataStore, server.ShutdownCtx)
 	adminMonitor.Start()
 
+	backupScheduleService := operations.NewScheduleService(server.Scheduler, server.DataStore, offlineGate, server.FileService.GetDatastorePath())
+	err := backupScheduleService.Start()
+	if err != nil {
+		return errors.Wrap(err, "failed to start the backup schedules")
+	}
+
 	var backupHandler = backup.NewHandler(
 		requestBouncer,
 		server.DataStore,
@@ -135,11 +158,18 @@ func (server *Server) Start() error {
 		server.ShutdownTrigger,
 		adminMonitor,
 		server.DemoService,
+		backupScheduleService,
 	)
+	backupHandler.ProxyManager = server.ProxyManager
+	backupHandler.AuthorizationService = server.AuthorizationService
+	backupHandler.APIKeyService = server.APIKeyService
 
 	var roleHandler = roles.NewHandler(requestBouncer)
 	roleHandler.DataStore = server.DataStore
 
+	var scimHandler = scim.NewHandler(requestBouncer, server.APIKeyService)
+	scimHandler.DataStore = server.DataStore
+
 	var customTemplatesHandler = customtemplates.NewHandler(requestBouncer)
 	customTemplatesHandler.DataStore = server.DataStore
 	customTemplatesHandler.FileService = server.FileService
@@ -217,6 +247,8 @@ func (server *Server) Start() error {
 	settingsHandler.FileService = server.FileService
 	settingsHandler.JWTService = server.JWTService
 	settingsHandler.LDAPService = server.LDAPService
+	settingsHandler.OAuthService = server.OAuthService
+	settingsHandler.SAMLService = server.SAMLService
 	settingsHandler.SnapshotService = server.SnapshotService
 
 	var sslHandler = sslhandler.NewHandler(requestBouncer)
@@ -254,6 +286,8 @@ func (server *Server) Start() error {
 
 	var statusHandler = status.NewHandler(requestBouncer, server.Status, server.DemoService)
 
+	var storeHandler = store.NewHandler(requestBouncer, server.DataStore, offlineGate, server.EventBus)
+
 	var templatesHandler = templates.NewHandler(requestBouncer)
 	templatesHandler.DataStore = server.DataStore
 	templatesHandler.FileService = server.FileService
@@ -265,6 +299,7 @@ func (server *Server) Start() error {
 	var userHandler = users.NewHandler(requestBouncer, rateLimiter, server.APIKeyService, server.DemoService, passwordStrengthChecker)
 	userHandler.DataStore = server.DataStore
 	userHandler.CryptoService = server.CryptoService
+	userHandler.JWTService = server.JWTService
 
 	var websocketHandler = websocket.NewHandler(server.KubernetesTokenCacheManager, requestBouncer)
 	websocketHandler.DataStore = server.DataStore
@@ -278,6 +313,8 @@ func (server *Server) Start() error {
 
 	server.Handler = &handler.Handler{
 		RoleHandler:               roleHandler,
+		SCIMHandler:               scimHandler,
+		AuditLogHandler:           auditLogHandler,
 		AuthHandler:               authHandler,
 		BackupHandler:             backupHandler,
 		CustomTemplatesHandler:    customTemplatesHandler,
@@ -304,6 +341,7 @@ func (server *Server) Start() error {
 		SettingsHandler:           settingsHandler,
 		SSLHandler:                sslHandler,
 		StatusHandler:             statusHandler,
+		StoreHandler:              storeHandler,
 		StackHandler:              stackHandler,
 		StorybookHandler:          storybookHandler,
 		TagHandler:                tagHandler,
@@ -0,0 +1,144 @@
+package mfa
+
+import (
+	"errors"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/crypto"
+)
+
+const (
+	// issuer is the name of the TOTP accounts in the authenticator applications
+	issuer = "Portainer"
+	// recoveryCodeCount is the number of recovery codes generated when the enrolment is confirmed
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrAlreadyEnabled   = errors.New("multi-factor authentication is already enabled")
+	ErrNotEnabled       = errors.New("multi-factor authentication is not enabled")
+	ErrEnrolmentMissing = errors.New("the multi-factor authentication enrolment has not been started")
+	ErrInvalidCode      = errors.New("invalid authentication code")
+)
+
+// Enrolment represents the TOTP secret to register in an authenticator application
+type Enrolment struct {
+	// Base32 encoded TOTP secret
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	// otpauth URI of the secret, to be displayed as a QR code
+	URI string `json:"uri" example:"otpauth://totp/Portainer:admin?secret=JBSWY3DPEHPK3PXP&issuer=Portainer"`
+}
+
+// IsRequired returns true when a user authenticating with a password must provide a TOTP or recovery code,
+// either because the user enabled the multi-factor authentication or because the settings make it mandatory
+func IsRequired(user *portainer.User, settings *portainer.Settings) bool {
+	switch {
+	case user.MFA.Enabled:
+		return true
+	case settings.MFARequirement == portainer.MFARequiredForEveryone:
+		return true
+	default:
+		return settings.MFARequirement == portainer.MFARequiredForAdministrators && user.Role == portainer.AdministratorRole
+	}
+}
+
+// IsMandatory returns true when the settings do not allow a user to disable the multi-factor authentication
+func IsMandatory(user *portainer.User, settings *portainer.Settings) bool {
+	return settings.MFARequirement == portainer.MFARequiredForEveryone ||
+		(settings.MFARequirement == portainer.MFARequiredForAdministrators && user.Role == portainer.AdministratorRole)
+}
+
+// StartEnrolment generates a new TOTP secret for a user, the multi-factor authentication
+// is enabled once the enrolment is confirmed with a code. The user must be saved by the caller.
+func StartEnrolment(user *portainer.User) (*Enrolment, error) {
+	if user.MFA.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+
+	secret, err := crypto.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	user.MFA = portainer.UserMFA{TOTPSecret: secret}
+
+	return &Enrolment{
+		Secret: secret,
+		URI:    crypto.TOTPKeyURI(issuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmEnrolment enables the multi-factor authentication of a user when the code matches the secret generated
+// by StartEnrolment. It returns the recovery codes of the user. The user must be saved by the caller.
+func ConfirmEnrolment(user *portainer.User, code string, now time.Time) ([]string, error) {
+	if user.MFA.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+
+	if user.MFA.TOTPSecret == "" {
+		return nil, ErrEnrolmentMissing
+	}
+
+	step, ok := crypto.ValidateTOTPCode(user.MFA.TOTPSecret, code, now, 0)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	user.MFA.Enabled = true
+	user.MFA.LastTimeStep = step
+
+	return RegenerateRecoveryCodes(user)
+}
+
+// Verify checks a TOTP code, or else a recovery code, of a user. The recovery code is consumed and the TOTP code
+// cannot be used again. The user must be saved by the caller.
+func Verify(user *portainer.User, code, recoveryCode string, now time.Time) error {
+	if !user.MFA.Enabled {
+		return ErrNotEnabled
+	}
+
+	if code != "" {
+		step, ok := crypto.ValidateTOTPCode(user.MFA.TOTPSecret, code, now, user.MFA.LastTimeStep)
+		if !ok {
+			return ErrInvalidCode
+		}
+
+		user.MFA.LastTimeStep = step
+		return nil
+	}
+
+	if recoveryCode == "" {
+		return ErrInvalidCode
+	}
+
+	hash := crypto.HashRecoveryCode(recoveryCode)
+	for i, recoveryCodeHash := range user.MFA.RecoveryCodes {
+		if recoveryCodeHash == hash {
+			user.MFA.RecoveryCodes = append(user.MFA.RecoveryCodes[:i], user.MFA.RecoveryCodes[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrInvalidCode
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user. The user must be saved by the caller.
+func RegenerateRecoveryCodes(user *portainer.User) ([]string, error) {
+	codes, err := crypto.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	user.MFA.RecoveryCodes = make([]string, len(codes))
+	for i, code := range codes {
+		user.MFA.RecoveryCodes[i] = crypto.HashRecoveryCode(code)
+	}
+
+	return codes, nil
+}
+
+// Disable removes the multi-factor authentication of a user. The user must be saved by the caller.
+func Disable(user *portainer.User) {
+	user.MFA = portainer.UserMFA{}
+}
@@ -0,0 +1,79 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrolmentAndVerification(t *testing.T) {
+	is := assert.New(t)
+
+	user := &portainer.User{ID: 1, Username: "admin"}
+	now := time.Unix(1700000000, 0)
+
+	_, err := ConfirmEnrolment(user, "123456", now)
+	is.ErrorIs(err, ErrEnrolmentMissing)
+
+	enrolment, err := StartEnrolment(user)
+	require.NoError(t, err)
+	is.Equal(enrolment.Secret, user.MFA.TOTPSecret)
+	is.Contains(enrolment.URI, "otpauth://totp/")
+	is.False(user.MFA.Enabled)
+
+	code, err := crypto.TOTPCode(enrolment.Secret, now)
+	require.NoError(t, err)
+
+	recoveryCodes, err := ConfirmEnrolment(user, code, now)
+	require.NoError(t, err)
+	is.True(user.MFA.Enabled)
+	is.Len(recoveryCodes, recoveryCodeCount)
+	is.NotContains(user.MFA.RecoveryCodes, recoveryCodes[0], "the recovery codes must be stored hashed")
+
+	_, err = StartEnrolment(user)
+	is.ErrorIs(err, ErrAlreadyEnabled)
+
+	is.ErrorIs(Verify(user, code, "", now), ErrInvalidCode, "a code cannot be used twice")
+
+	later := now.Add(30 * time.Second)
+	code, err = crypto.TOTPCode(enrolment.Secret, later)
+	require.NoError(t, err)
+	is.NoError(Verify(user, code, "", later))
+
+	is.NoError(Verify(user, "", recoveryCodes[0], later))
+	is.ErrorIs(Verify(user, "", recoveryCodes[0], later), ErrInvalidCode, "a recovery code cannot be used twice")
+	is.Len(user.MFA.RecoveryCodes, recoveryCodeCount-1)
+
+	Disable(user)
+	is.Equal(portainer.UserMFA{}, user.MFA)
+	is.ErrorIs(Verify(user, code, "", later), ErrNotEnabled)
+}
+
+func TestIsRequired(t *testing.T) {
+	admin := &portainer.User{Role: portainer.AdministratorRole}
+	standard := &portainer.User{Role: portainer.StandardUserRole}
+	enrolled := &portainer.User{Role: portainer.StandardUserRole, MFA: portainer.UserMFA{Enabled: true}}
+
+	tests := []struct {
+		requirement portainer.MFARequirement
+		user        *portainer.User
+		required    bool
+		mandatory   bool
+	}{
+		{portainer.MFANotRequired, admin, false, false},
+		{portainer.MFANotRequired, enrolled, true, false},
+		{portainer.MFARequiredForAdministrators, admin, true, true},
+		{portainer.MFARequiredForAdministrators, standard, false, false},
+		{portainer.MFARequiredForEveryone, standard, true, true},
+	}
+
+	for _, test := range tests {
+		settings := &portainer.Settings{MFARequirement: test.requirement}
+		assert.Equal(t, test.required, IsRequired(test.user, settings))
+		assert.Equal(t, test.mandatory, IsMandatory(test.user, settings))
+	}
+}
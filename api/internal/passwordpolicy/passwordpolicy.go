@@ -0,0 +1,112 @@
+package passwordpolicy
+
+import (
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+)
+
+// IsLocked returns true when a user account is locked after too many failed authentications.
+// The lock is lifted once the lockout duration has elapsed or when the lockout is disabled.
+// The default lockout duration applies when the lockout duration is not a positive duration.
+func IsLocked(user *portainer.User, settings portainer.InternalAuthSettings, now time.Time) bool {
+	if user.LockedAt == 0 || settings.MaxFailedAttempts <= 0 {
+		return false
+	}
+
+	duration, err := time.ParseDuration(settings.LockoutDuration)
+	if err != nil || duration <= 0 {
+		duration, _ = time.ParseDuration(portainer.DefaultLockoutDuration)
+	}
+
+	return now.Before(time.Unix(user.LockedAt, 0).Add(duration))
+}
+
+// RecordFailure counts a failed authentication of a user and locks the user account when the maximum
+// number of failed attempts is reached. It returns false when the user is unchanged. The user must be saved by the caller.
+func RecordFailure(user *portainer.User, settings portainer.InternalAuthSettings, now time.Time) bool {
+	if settings.MaxFailedAttempts <= 0 {
+		return false
+	}
+
+	if user.LockedAt != 0 && !IsLocked(user, settings, now) {
+		Unlock(user)
+	}
+
+	user.FailedLoginAttempts++
+	if user.FailedLoginAttempts >= settings.MaxFailedAttempts && user.LockedAt == 0 {
+		user.LockedAt = now.Unix()
+	}
+
+	return true
+}
+
+// RecordSuccess resets the failed authentications of a user and starts the password age of the users created
+// before the password expiry. It returns false when the user is unchanged. The user must be saved by the caller.
+func RecordSuccess(user *portainer.User, now time.Time) bool {
+	changed := user.FailedLoginAttempts != 0 || user.LockedAt != 0 || user.PasswordChangedAt == 0
+
+	Unlock(user)
+	if user.PasswordChangedAt == 0 {
+		user.PasswordChangedAt = now.Unix()
+	}
+
+	return changed
+}
+
+// Unlock resets the failed authentications of a user. The user must be saved by the caller.
+func Unlock(user *portainer.User) {
+	user.FailedLoginAttempts = 0
+	user.LockedAt = 0
+}
+
+// IsExpired returns true when the password of a user is older than the maximum password age
+func IsExpired(user *portainer.User, settings portainer.InternalAuthSettings, now time.Time) bool {
+	if settings.PasswordMaxAge == "" || user.PasswordChangedAt == 0 {
+		return false
+	}
+
+	maxAge, err := time.ParseDuration(settings.PasswordMaxAge)
+	if err != nil || maxAge <= 0 {
+		return false
+	}
+
+	return !now.Before(time.Unix(user.PasswordChangedAt, 0).Add(maxAge))
+}
+
+// IsReused returns true when a password matches the current password of a user or one of the previous passwords
+// kept by the password history
+func IsReused(cryptoService portainer.CryptoService, user *portainer.User, settings portainer.InternalAuthSettings, password string) bool {
+	if settings.PasswordHistorySize <= 0 {
+		return false
+	}
+
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > settings.PasswordHistorySize {
+		hashes = hashes[:settings.PasswordHistorySize]
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && cryptoService.CompareHashAndData(hash, password) == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetPassword replaces the password hash of a user, keeps the previous one in the password history
+// and restarts the password age. The user must be saved by the caller.
+func SetPassword(user *portainer.User, hash string, settings portainer.InternalAuthSettings, now time.Time) {
+	var history []string
+	if settings.PasswordHistorySize > 1 && user.Password != "" {
+		history = append([]string{user.Password}, user.PasswordHistory...)
+		if len(history) > settings.PasswordHistorySize-1 {
+			history = history[:settings.PasswordHistorySize-1]
+		}
+	}
+
+	user.Password = hash
+	user.PasswordHistory = history
+	user.PasswordChangedAt = now.Unix()
+}
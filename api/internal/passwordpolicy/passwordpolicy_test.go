@@ -0,0 +1,109 @@
+package passwordpolicy
+
+import (
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/crypto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RecordFailure_shouldLockTheAccountUntilTheLockoutDurationElapsed(t *testing.T) {
+	is := assert.New(t)
+
+	settings := portainer.InternalAuthSettings{MaxFailedAttempts: 3, LockoutDuration: "15m"}
+	user := &portainer.User{}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		is.True(RecordFailure(user, settings, now))
+		is.False(IsLocked(user, settings, now))
+	}
+
+	is.True(RecordFailure(user, settings, now))
+	is.True(IsLocked(user, settings, now))
+	is.True(IsLocked(user, settings, now.Add(14*time.Minute)))
+	is.False(IsLocked(user, settings, now.Add(15*time.Minute)))
+
+	// the failed attempts are counted again once the lock is lifted
+	is.True(RecordFailure(user, settings, now.Add(15*time.Minute)))
+	is.Equal(1, user.FailedLoginAttempts)
+	is.False(IsLocked(user, settings, now.Add(15*time.Minute)))
+}
+
+func Test_RecordFailure_shouldIgnoreFailuresWhenTheLockoutIsDisabled(t *testing.T) {
+	user := &portainer.User{}
+
+	assert.False(t, RecordFailure(user, portainer.InternalAuthSettings{}, time.Now()))
+	assert.Equal(t, 0, user.FailedLoginAttempts)
+
+	user.LockedAt = time.Now().Unix()
+	assert.False(t, IsLocked(user, portainer.InternalAuthSettings{}, time.Now()))
+}
+
+func Test_IsLocked_shouldUseTheDefaultLockoutDurationWhenInvalid(t *testing.T) {
+	is := assert.New(t)
+
+	now := time.Now()
+	user := &portainer.User{FailedLoginAttempts: 3, LockedAt: now.Unix()}
+
+	for _, lockoutDuration := range []string{"", "invalid", "-1h"} {
+		settings := portainer.InternalAuthSettings{MaxFailedAttempts: 3, LockoutDuration: lockoutDuration}
+
+		is.True(IsLocked(user, settings, now.Add(14*time.Minute)), lockoutDuration)
+		is.False(IsLocked(user, settings, now.Add(15*time.Minute)), lockoutDuration)
+	}
+}
+
+func Test_RecordSuccess(t *testing.T) {
+	is := assert.New(t)
+
+	now := time.Now()
+	user := &portainer.User{FailedLoginAttempts: 3, LockedAt: now.Unix()}
+
+	is.True(RecordSuccess(user, now))
+	is.Equal(0, user.FailedLoginAttempts)
+	is.Zero(user.LockedAt)
+	is.Equal(now.Unix(), user.PasswordChangedAt)
+
+	is.False(RecordSuccess(user, now.Add(time.Hour)))
+	is.Equal(now.Unix(), user.PasswordChangedAt)
+}
+
+func Test_IsExpired(t *testing.T) {
+	is := assert.New(t)
+
+	now := time.Now()
+	user := &portainer.User{PasswordChangedAt: now.Add(-48 * time.Hour).Unix()}
+
+	is.False(IsExpired(user, portainer.InternalAuthSettings{}, now))
+	is.False(IsExpired(user, portainer.InternalAuthSettings{PasswordMaxAge: "72h"}, now))
+	is.True(IsExpired(user, portainer.InternalAuthSettings{PasswordMaxAge: "24h"}, now))
+}
+
+func Test_SetPassword_shouldKeepThePasswordHistory(t *testing.T) {
+	is := assert.New(t)
+
+	cryptoService := &crypto.Service{}
+	settings := portainer.InternalAuthSettings{PasswordHistorySize: 3}
+	user := &portainer.User{}
+
+	for _, password := range []string{"first", "second", "third", "fourth"} {
+		hash, err := cryptoService.Hash(password)
+		require.NoError(t, err)
+
+		SetPassword(user, hash, settings, time.Now())
+	}
+
+	is.Len(user.PasswordHistory, 2)
+	is.False(IsReused(cryptoService, user, settings, "first"))
+	is.False(IsReused(cryptoService, user, settings, "fifth"))
+	for _, password := range []string{"second", "third", "fourth"} {
+		is.True(IsReused(cryptoService, user, settings, password), password)
+	}
+
+	is.False(IsReused(cryptoService, user, portainer.InternalAuthSettings{}, "fourth"))
+}
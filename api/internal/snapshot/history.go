@@ -0,0 +1,245 @@
+package snapshot
+
+import (
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/rs/zerolog/log"
+)
+
+// historyMaintenanceInterval is the minimum delay between two runs of the snapshot history retention policy
+const historyMaintenanceInterval = 1 * time.Hour
+
+// snapshotHistoryPolicy represents the parsed retention policy of the snapshot history
+type snapshotHistoryPolicy struct {
+	retention            time.Duration
+	downsampleAfter      time.Duration
+	downsampleResolution time.Duration
+}
+
+// parseSnapshotHistorySettings parses the snapshot history settings, falling back to the
+// default values for empty or invalid fields. A zero retention disables the snapshot history.
+func parseSnapshotHistorySettings(settings portainer.SnapshotHistorySettings) snapshotHistoryPolicy {
+	return snapshotHistoryPolicy{
+		retention:            parseHistoryDuration(settings.Retention, portainer.DefaultSnapshotHistoryRetention),
+		downsampleAfter:      parseHistoryDuration(settings.DownsampleAfter, portainer.DefaultSnapshotHistoryDownsampleAfter),
+		downsampleResolution: parseHistoryDuration(settings.DownsampleResolution, portainer.DefaultSnapshotHistoryDownsampleResolution),
+	}
+}
+
+func parseHistoryDuration(value, defaultValue string) time.Duration {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		duration, _ = time.ParseDuration(defaultValue)
+	}
+
+	return duration
+}
+
+func (service *Service) snapshotHistoryPolicy() (snapshotHistoryPolicy, error) {
+	settings, err := service.dataStore.Settings().Settings()
+	if err != nil {
+		return snapshotHistoryPolicy{}, err
+	}
+
+	return parseSnapshotHistorySettings(settings.SnapshotHistory), nil
+}
+
+// NewSnapshotHistoryEntry creates a snapshot history entry from the latest snapshot of an environment(endpoint).
+// It returns nil when the environment(endpoint) does not have any snapshot.
+func NewSnapshotHistoryEntry(endpoint *portainer.Endpoint) *portainer.SnapshotHistoryEntry {
+	if len(endpoint.Snapshots) > 0 {
+		snapshot := endpoint.Snapshots[len(endpoint.Snapshots)-1]
+
+		return &portainer.SnapshotHistoryEntry{
+			EndpointID: endpoint.ID,
+			Time:       snapshot.Time,
+			Metrics: map[portainer.SnapshotHistoryMetric]float64{
+				portainer.SnapshotHistoryMetricRunningContainers:   float64(snapshot.RunningContainerCount),
+				portainer.SnapshotHistoryMetricStoppedContainers:   float64(snapshot.StoppedContainerCount),
+				portainer.SnapshotHistoryMetricHealthyContainers:   float64(snapshot.HealthyContainerCount),
+				portainer.SnapshotHistoryMetricUnhealthyContainers: float64(snapshot.UnhealthyContainerCount),
+				portainer.SnapshotHistoryMetricCPU:                 float64(snapshot.TotalCPU),
+				portainer.SnapshotHistoryMetricMemory:              float64(snapshot.TotalMemory),
+				portainer.SnapshotHistoryMetricImages:              float64(snapshot.ImageCount),
+				portainer.SnapshotHistoryMetricVolumes:             float64(snapshot.VolumeCount),
+				portainer.SnapshotHistoryMetricNodes:               float64(snapshot.NodeCount),
+			},
+		}
+	}
+
+	if len(endpoint.Kubernetes.Snapshots) > 0 {
+		snapshot := endpoint.Kubernetes.Snapshots[len(endpoint.Kubernetes.Snapshots)-1]
+
+		return &portainer.SnapshotHistoryEntry{
+			EndpointID: endpoint.ID,
+			Time:       snapshot.Time,
+			Metrics: map[portainer.SnapshotHistoryMetric]float64{
+				portainer.SnapshotHistoryMetricCPU:    float64(snapshot.TotalCPU),
+				portainer.SnapshotHistoryMetricMemory: float64(snapshot.TotalMemory),
+				portainer.SnapshotHistoryMetricNodes:  float64(snapshot.NodeCount),
+			},
+		}
+	}
+
+	return nil
+}
+
+// recordSnapshotHistory appends the latest snapshot of an environment(endpoint) to the snapshot history
+func (service *Service) recordSnapshotHistory(endpoint *portainer.Endpoint) {
+	policy, err := service.snapshotHistoryPolicy()
+	if err != nil {
+		log.Debug().Err(err).Msg("unable to retrieve the snapshot history settings")
+		return
+	}
+
+	if policy.retention == 0 {
+		return
+	}
+
+	entry := NewSnapshotHistoryEntry(endpoint)
+	if entry == nil {
+		return
+	}
+
+	err = service.dataStore.SnapshotHistory().Create(entry)
+	if err != nil {
+		log.Debug().
+			Str("endpoint", endpoint.Name).
+			Err(err).
+			Msg("background schedule error (environment snapshot), unable to record snapshot history")
+	}
+}
+
+// maintainSnapshotHistory applies the retention policy to the snapshot history of every environment(endpoint):
+// expired entries are removed and entries older than the downsampling age are averaged per resolution period.
+func (service *Service) maintainSnapshotHistory(now time.Time) error {
+	policy, err := service.snapshotHistoryPolicy()
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := service.dataStore.Endpoint().Endpoints()
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		entries, err := service.dataStore.SnapshotHistory().EntriesByEndpointID(endpoint.ID, 0, now.Unix())
+		if err != nil {
+			return err
+		}
+
+		merged, obsolete := applySnapshotHistoryPolicy(entries, policy, now)
+
+		for i := range merged {
+			err = service.dataStore.SnapshotHistory().Create(&merged[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		err = service.dataStore.SnapshotHistory().DeleteEntries(obsolete)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applySnapshotHistoryPolicy computes the changes required to apply the retention policy to the
+// time ordered entries of a single environment(endpoint). It returns the new downsampled entries
+// and the entries to delete.
+func applySnapshotHistoryPolicy(entries []portainer.SnapshotHistoryEntry, policy snapshotHistoryPolicy, now time.Time) ([]portainer.SnapshotHistoryEntry, []portainer.SnapshotHistoryEntry) {
+	merged := []portainer.SnapshotHistoryEntry{}
+	obsolete := []portainer.SnapshotHistoryEntry{}
+
+	if policy.retention == 0 {
+		return merged, append(obsolete, entries...)
+	}
+
+	expiry := now.Add(-policy.retention).Unix()
+	downsampleBefore := now.Add(-policy.downsampleAfter).Unix()
+	resolution := int64(policy.downsampleResolution.Seconds())
+
+	var group []portainer.SnapshotHistoryEntry
+
+	flush := func() {
+		if len(group) > 1 || (len(group) == 1 && group[0].Resolution != resolution) {
+			merged = append(merged, averageEntries(group, resolution))
+
+			obsolete = append(obsolete, group...)
+		}
+
+		group = nil
+	}
+
+	for _, entry := range entries {
+		if entry.Time < expiry {
+			obsolete = append(obsolete, entry)
+			continue
+		}
+
+		if resolution <= 0 || entry.Time >= downsampleBefore {
+			continue
+		}
+
+		periodStart := entry.Time - entry.Time%resolution
+		// only complete periods are downsampled, so that later entries can still be merged in
+		if periodStart+resolution > downsampleBefore {
+			continue
+		}
+
+		if len(group) > 0 && group[0].Time-group[0].Time%resolution != periodStart {
+			flush()
+		}
+
+		group = append(group, entry)
+	}
+
+	flush()
+
+	return merged, obsolete
+}
+
+// averageEntries merges the entries of a single period into one entry holding the mean of each metric.
+// Downsampled entries are weighted by the number of snapshots they already average.
+func averageEntries(entries []portainer.SnapshotHistoryEntry, resolution int64) portainer.SnapshotHistoryEntry {
+	sums := map[portainer.SnapshotHistoryMetric]float64{}
+	counts := map[portainer.SnapshotHistoryMetric]float64{}
+	samples := 0
+
+	for _, entry := range entries {
+		weight := entrySamples(entry)
+		samples += weight
+
+		for metric, value := range entry.Metrics {
+			sums[metric] += value * float64(weight)
+			counts[metric] += float64(weight)
+		}
+	}
+
+	metrics := make(map[portainer.SnapshotHistoryMetric]float64, len(sums))
+	for metric, sum := range sums {
+		metrics[metric] = sum / counts[metric]
+	}
+
+	return portainer.SnapshotHistoryEntry{
+		EndpointID: entries[0].EndpointID,
+		Time:       entries[0].Time - entries[0].Time%resolution,
+		Resolution: resolution,
+		Samples:    samples,
+		Metrics:    metrics,
+	}
+}
+
+// entrySamples returns the number of snapshots averaged in an entry, a raw snapshot counting as one
+func entrySamples(entry portainer.SnapshotHistoryEntry) int {
+	if entry.Samples < 1 {
+		return 1
+	}
+
+	return entry.Samples
+}
@@ -0,0 +1,117 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseSnapshotHistorySettings(t *testing.T) {
+	is := assert.New(t)
+
+	policy := parseSnapshotHistorySettings(portainer.SnapshotHistorySettings{Retention: "48h", DownsampleAfter: "invalid"})
+	is.Equal(48*time.Hour, policy.retention)
+	is.Equal(24*time.Hour, policy.downsampleAfter)
+	is.Equal(1*time.Hour, policy.downsampleResolution)
+
+	policy = parseSnapshotHistorySettings(portainer.SnapshotHistorySettings{Retention: "0"})
+	is.Equal(time.Duration(0), policy.retention)
+}
+
+func Test_applySnapshotHistoryPolicy(t *testing.T) {
+	is := assert.New(t)
+
+	now := time.Unix(100*3600, 0)
+	policy := snapshotHistoryPolicy{
+		retention:            48 * time.Hour,
+		downsampleAfter:      24 * time.Hour,
+		downsampleResolution: time.Hour,
+	}
+
+	entry := func(id int, hoursAgo float64, cpu float64) portainer.SnapshotHistoryEntry {
+		return portainer.SnapshotHistoryEntry{
+			ID:         portainer.SnapshotHistoryEntryID(id),
+			EndpointID: 1,
+			Time:       now.Add(-time.Duration(hoursAgo * float64(time.Hour))).Unix(),
+			Metrics:    map[portainer.SnapshotHistoryMetric]float64{portainer.SnapshotHistoryMetricCPU: cpu},
+		}
+	}
+
+	downsampled := entry(4, 29, 8)
+	downsampled.Resolution = 3600
+
+	entries := []portainer.SnapshotHistoryEntry{
+		entry(1, 50, 1),    // expired
+		entry(2, 30, 2),    // downsampled with 3
+		entry(3, 29.5, 4),  // downsampled with 2
+		downsampled,        // already downsampled, left untouched
+		entry(5, 27.5, 6),  // alone in its period, downsampled
+		entry(6, 23.75, 6), // within the downsampling age
+		entry(7, 1, 6),     // recent
+	}
+
+	merged, obsolete := applySnapshotHistoryPolicy(entries, policy, now)
+
+	is.ElementsMatch([]portainer.SnapshotHistoryEntryID{1, 2, 3, 5}, entryIDs(obsolete))
+	is.Len(merged, 2)
+
+	is.Equal(now.Add(-30*time.Hour).Unix(), merged[0].Time)
+	is.Equal(int64(3600), merged[0].Resolution)
+	is.Equal(2, merged[0].Samples)
+	is.Equal(float64(3), merged[0].Metrics[portainer.SnapshotHistoryMetricCPU])
+
+	is.Equal(now.Add(-28*time.Hour).Unix(), merged[1].Time)
+	is.Equal(1, merged[1].Samples)
+	is.Equal(float64(6), merged[1].Metrics[portainer.SnapshotHistoryMetricCPU])
+}
+
+func Test_averageEntries_weightsDownsampledEntries(t *testing.T) {
+	is := assert.New(t)
+
+	entries := []portainer.SnapshotHistoryEntry{
+		{Time: 3600, Resolution: 3600, Samples: 3, Metrics: map[portainer.SnapshotHistoryMetric]float64{portainer.SnapshotHistoryMetricCPU: 2}},
+		{Time: 4000, Metrics: map[portainer.SnapshotHistoryMetric]float64{portainer.SnapshotHistoryMetricCPU: 6}},
+	}
+
+	merged := averageEntries(entries, 3600)
+
+	is.Equal(4, merged.Samples)
+	is.Equal(float64(3), merged.Metrics[portainer.SnapshotHistoryMetricCPU])
+}
+
+func Test_applySnapshotHistoryPolicy_disabled(t *testing.T) {
+	is := assert.New(t)
+
+	entries := []portainer.SnapshotHistoryEntry{{ID: 1, Time: time.Now().Unix()}}
+
+	merged, obsolete := applySnapshotHistoryPolicy(entries, snapshotHistoryPolicy{}, time.Now())
+
+	is.Empty(merged)
+	is.Equal([]portainer.SnapshotHistoryEntryID{1}, entryIDs(obsolete))
+}
+
+func entryIDs(entries []portainer.SnapshotHistoryEntry) []portainer.SnapshotHistoryEntryID {
+	IDs := []portainer.SnapshotHistoryEntryID{}
+	for _, entry := range entries {
+		IDs = append(IDs, entry.ID)
+	}
+
+	return IDs
+}
+
+func Test_NewSnapshotHistoryEntry(t *testing.T) {
+	is := assert.New(t)
+
+	is.Nil(NewSnapshotHistoryEntry(&portainer.Endpoint{ID: 1}))
+
+	entry := NewSnapshotHistoryEntry(&portainer.Endpoint{
+		ID:        1,
+		Snapshots: []portainer.DockerSnapshot{{Time: 10, RunningContainerCount: 3, ImageCount: 5}},
+	})
+	is.Equal(portainer.EndpointID(1), entry.EndpointID)
+	is.Equal(int64(10), entry.Time)
+	is.Equal(float64(3), entry.Metrics[portainer.SnapshotHistoryMetricRunningContainers])
+	is.Equal(float64(5), entry.Metrics[portainer.SnapshotHistoryMetricImages])
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"sync"
 	"time"
 
 	portainer "github.com/portainer/portainer/api"
@@ -14,6 +15,17 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// DefaultMaxConcurrency is the default number of environments(endpoints) snapshotted in parallel
+	DefaultMaxConcurrency = 10
+	// DefaultSnapshotTimeout is the default time allowed for the snapshot of a single environment(endpoint)
+	DefaultSnapshotTimeout = 2 * time.Minute
+	// maxBackoff caps the delay between two snapshot attempts of an unreachable environment(endpoint)
+	maxBackoff = 1 * time.Hour
+)
+
+var errSnapshotTimeout = errors.New("environment snapshot timed out")
+
 // Service repesents a service to manage environment(endpoint) snapshots.
 // It provides an interface to start background snapshots as well as
 // specific Docker/Kubernetes environment(endpoint) snapshot methods.
@@ -21,25 +33,53 @@ type Service struct {
 	dataStore                 dataservices.DataStore
 	snapshotIntervalCh        chan time.Duration
 	snapshotIntervalInSeconds float64
+	maxConcurrency            int
+	snapshotTimeout           time.Duration
 	dockerSnapshotter         portainer.DockerSnapshotter
 	kubernetesSnapshotter     portainer.KubernetesSnapshotter
 	shutdownCtx               context.Context
+
+	// lastHistoryMaintenance is the last time the snapshot history retention policy was applied
+	lastHistoryMaintenance time.Time
+
+	// schedules keeps track of the next snapshot time of each environment(endpoint)
+	schedules   map[portainer.EndpointID]*endpointSchedule
+	schedulesMu sync.Mutex
+}
+
+// endpointSchedule represents the snapshot schedule of a single environment(endpoint)
+type endpointSchedule struct {
+	nextSnapshot        time.Time
+	consecutiveFailures int
+	// timedOut is set while a timed out snapshot has not returned, the environment is skipped until it does
+	timedOut bool
 }
 
 // NewService creates a new instance of a service
-func NewService(snapshotIntervalFromFlag string, dataStore dataservices.DataStore, dockerSnapshotter portainer.DockerSnapshotter, kubernetesSnapshotter portainer.KubernetesSnapshotter, shutdownCtx context.Context) (*Service, error) {
+func NewService(snapshotIntervalFromFlag string, maxConcurrency int, snapshotTimeout time.Duration, dataStore dataservices.DataStore, dockerSnapshotter portainer.DockerSnapshotter, kubernetesSnapshotter portainer.KubernetesSnapshotter, shutdownCtx context.Context) (*Service, error) {
 	interval, err := parseSnapshotFrequency(snapshotIntervalFromFlag, dataStore)
 	if err != nil {
 		return nil, err
 	}
 
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+
+	if snapshotTimeout <= 0 {
+		snapshotTimeout = DefaultSnapshotTimeout
+	}
+
 	return &Service{
 		dataStore:                 dataStore,
 		snapshotIntervalCh:        make(chan time.Duration),
 		snapshotIntervalInSeconds: interval,
+		maxConcurrency:            maxConcurrency,
+		snapshotTimeout:           snapshotTimeout,
 		dockerSnapshotter:         dockerSnapshotter,
 		kubernetesSnapshotter:     kubernetesSnapshotter,
 		shutdownCtx:               shutdownCtx,
+		schedules:                 make(map[portainer.EndpointID]*endpointSchedule),
 	}, nil
 }
 
@@ -89,8 +129,25 @@ func SupportDirectSnapshot(endpoint *portainer.Endpoint) bool {
 }
 
 // SnapshotEndpoint will create a snapshot of the environment(endpoint) based on the environment(endpoint) type.
-// If the snapshot is a success, it will be associated to the environment(endpoint).
+// If the snapshot is a success, it will be associated to the environment(endpoint), the raw resources of a
+// Kubernetes snapshot are saved and the snapshot is added to the snapshot history.
 func (service *Service) SnapshotEndpoint(endpoint *portainer.Endpoint) error {
+	err := service.snapshotEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+
+	err = service.saveKubernetesSnapshotRaw(endpoint)
+	if err != nil {
+		return err
+	}
+
+	service.recordSnapshotHistory(endpoint)
+
+	return nil
+}
+
+func (service *Service) snapshotEndpoint(endpoint *portainer.Endpoint) error {
 	if endpoint.Type == portainer.AgentOnDockerEnvironment || endpoint.Type == portainer.AgentOnKubernetesEnvironment {
 		var err error
 		var tlsConfig *tls.Config
@@ -132,6 +189,16 @@ func (service *Service) snapshotKubernetesEndpoint(endpoint *portainer.Endpoint)
 	return nil
 }
 
+// saveKubernetesSnapshotRaw saves the raw resources of the Kubernetes snapshot of an environment(endpoint),
+// which are not part of the environment record
+func (service *Service) saveKubernetesSnapshotRaw(endpoint *portainer.Endpoint) error {
+	if len(endpoint.Kubernetes.Snapshots) == 0 {
+		return nil
+	}
+
+	return service.dataStore.KubernetesSnapshotRaw().UpdateSnapshotRaw(endpoint.ID, &endpoint.Kubernetes.Snapshots[0].SnapshotRaw)
+}
+
 func (service *Service) snapshotDockerEndpoint(endpoint *portainer.Endpoint) error {
 	snapshot, err := service.dockerSnapshotter.CreateSnapshot(endpoint)
 	if err != nil {
@@ -146,79 +213,332 @@ func (service *Service) snapshotDockerEndpoint(endpoint *portainer.Endpoint) err
 }
 
 func (service *Service) startSnapshotLoop() {
-	ticker := time.NewTicker(time.Duration(service.snapshotIntervalInSeconds) * time.Second)
-
-	err := service.snapshotEndpoints()
-	if err != nil {
-		log.Error().Err(err).Msg("background schedule error (environment snapshot)")
-	}
+	timer := time.NewTimer(0)
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
 			err := service.snapshotEndpoints()
 			if err != nil {
 				log.Error().Err(err).Msg("background schedule error (environment snapshot)")
 			}
+
+			if time.Since(service.lastHistoryMaintenance) >= historyMaintenanceInterval {
+				err = service.maintainSnapshotHistory(time.Now())
+				if err != nil {
+					log.Error().Err(err).Msg("background schedule error (snapshot history maintenance)")
+				}
+
+				service.lastHistoryMaintenance = time.Now()
+			}
+
+			timer.Reset(service.nextWakeUp(time.Now()))
 		case <-service.shutdownCtx.Done():
 			log.Debug().Msg("shutting down snapshotting")
-			ticker.Stop()
+			timer.Stop()
 			return
 		case interval := <-service.snapshotIntervalCh:
-			ticker.Reset(interval)
+			service.snapshotIntervalInSeconds = interval.Seconds()
+
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(service.nextWakeUp(time.Now()))
 		}
 	}
 }
 
+// snapshotEndpoints snapshots every environment(endpoint) that is due, using a pool
+// of at most maxConcurrency workers so that an unreachable environment does not
+// hold up the others. A timed out snapshot is left to return outside of the pool.
 func (service *Service) snapshotEndpoints() error {
 	endpoints, err := service.dataStore.Endpoint().Endpoints()
 	if err != nil {
 		return err
 	}
 
+	now := time.Now()
+	dueEndpoints := service.dueEndpoints(endpoints, now)
+
+	jobs := make(chan portainer.Endpoint)
+
+	var wg sync.WaitGroup
+	for i := 0; i < service.maxConcurrency && i < len(dueEndpoints); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for endpoint := range jobs {
+				done, snapshotError := service.snapshotEndpointWithTimeout(&endpoint)
+				service.persistEndpointSnapshot(endpoint, snapshotError)
+
+				if snapshotError == errSnapshotTimeout {
+					service.waitForTimedOutSnapshot(endpoint.ID, done)
+				}
+			}
+		}()
+	}
+
+	for _, endpoint := range dueEndpoints {
+		select {
+		case jobs <- endpoint:
+		case <-service.shutdownCtx.Done():
+			close(jobs)
+			wg.Wait()
+
+			return nil
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return nil
+}
+
+// dueEndpoints returns the environments(endpoints) whose next snapshot time has been reached.
+// It also drops the schedules of environments that no longer exist.
+func (service *Service) dueEndpoints(endpoints []portainer.Endpoint, now time.Time) []portainer.Endpoint {
+	service.schedulesMu.Lock()
+	defer service.schedulesMu.Unlock()
+
+	existing := make(map[portainer.EndpointID]bool, len(endpoints))
+	dueEndpoints := make([]portainer.Endpoint, 0)
+
 	for _, endpoint := range endpoints {
 		if !SupportDirectSnapshot(&endpoint) {
 			continue
 		}
 
-		snapshotError := service.SnapshotEndpoint(&endpoint)
+		existing[endpoint.ID] = true
 
-		latestEndpointReference, err := service.dataStore.Endpoint().Endpoint(endpoint.ID)
-		if latestEndpointReference == nil {
-			log.Debug().
-				Str("endpoint", endpoint.Name).
-				Str("URL", endpoint.URL).Err(err).
-				Msg("background schedule error (environment snapshot), environment not found inside the database anymore")
+		schedule, ok := service.schedules[endpoint.ID]
+		if !ok {
+			schedule = &endpointSchedule{}
+			service.schedules[endpoint.ID] = schedule
+		}
+
+		if !schedule.timedOut && !schedule.nextSnapshot.After(now) {
+			dueEndpoints = append(dueEndpoints, endpoint)
+		}
+	}
 
+	for endpointID := range service.schedules {
+		if !existing[endpointID] {
+			delete(service.schedules, endpointID)
+		}
+	}
+
+	return dueEndpoints
+}
+
+// waitForTimedOutSnapshot skips an environment(endpoint) until its timed out snapshot returns, so that
+// an unreachable environment never has more than one snapshot running and does not keep a worker
+func (service *Service) waitForTimedOutSnapshot(endpointID portainer.EndpointID, done <-chan struct{}) {
+	service.setTimedOut(endpointID, true)
+
+	go func() {
+		select {
+		case <-done:
+			service.setTimedOut(endpointID, false)
+		case <-service.shutdownCtx.Done():
+		}
+	}()
+}
+
+func (service *Service) setTimedOut(endpointID portainer.EndpointID, timedOut bool) {
+	service.schedulesMu.Lock()
+	defer service.schedulesMu.Unlock()
+
+	schedule, ok := service.schedules[endpointID]
+	if !ok {
+		// the schedule of a removed environment is not recreated
+		if !timedOut {
+			return
+		}
+
+		schedule = &endpointSchedule{}
+		service.schedules[endpointID] = schedule
+	}
+
+	schedule.timedOut = timedOut
+}
+
+// nextWakeUp returns the delay until the next environment(endpoint) snapshot is due.
+// It never exceeds the global snapshot interval so that new environments are picked up.
+// The environments skipped until their timed out snapshot returns are not taken into account.
+func (service *Service) nextWakeUp(now time.Time) time.Duration {
+	service.schedulesMu.Lock()
+	defer service.schedulesMu.Unlock()
+
+	delay := service.globalInterval()
+	for _, schedule := range service.schedules {
+		if schedule.timedOut {
 			continue
 		}
 
-		latestEndpointReference.Status = portainer.EndpointStatusUp
-		if snapshotError != nil {
-			log.Debug().
-				Str("endpoint", endpoint.Name).
-				Str("URL", endpoint.URL).Err(err).
-				Msg("background schedule error (environment snapshot), unable to create snapshot")
+		if d := schedule.nextSnapshot.Sub(now); d < delay {
+			delay = d
+		}
+	}
+
+	if delay < time.Second {
+		delay = time.Second
+	}
+
+	return delay
+}
+
+func (service *Service) globalInterval() time.Duration {
+	return time.Duration(service.snapshotIntervalInSeconds) * time.Second
+}
+
+// endpointInterval returns the snapshot interval of an environment(endpoint),
+// falling back to the global snapshot interval when it does not override it.
+func (service *Service) endpointInterval(endpoint *portainer.Endpoint) time.Duration {
+	if endpoint.SnapshotInterval != "" {
+		interval, err := time.ParseDuration(endpoint.SnapshotInterval)
+		if err == nil && interval > 0 {
+			return interval
+		}
+
+		log.Debug().
+			Str("endpoint", endpoint.Name).
+			Str("interval", endpoint.SnapshotInterval).
+			Msg("invalid environment snapshot interval, using the global snapshot interval")
+	}
+
+	return service.globalInterval()
+}
+
+// reschedule computes the next snapshot time of an environment(endpoint). Environments that
+// failed to be snapshotted are retried with an exponential backoff capped at maxBackoff.
+func (service *Service) reschedule(endpoint *portainer.Endpoint, snapshotError error, now time.Time) {
+	service.schedulesMu.Lock()
+	defer service.schedulesMu.Unlock()
+
+	schedule, ok := service.schedules[endpoint.ID]
+	if !ok {
+		schedule = &endpointSchedule{}
+		service.schedules[endpoint.ID] = schedule
+	}
+
+	if snapshotError == nil {
+		schedule.consecutiveFailures = 0
+	} else {
+		schedule.consecutiveFailures++
+	}
+
+	schedule.nextSnapshot = now.Add(backoffDelay(service.endpointInterval(endpoint), schedule.consecutiveFailures))
+}
 
-			latestEndpointReference.Status = portainer.EndpointStatusDown
+// backoffDelay returns the delay before the next snapshot attempt after a number of consecutive failures.
+func backoffDelay(interval time.Duration, consecutiveFailures int) time.Duration {
+	if interval >= maxBackoff {
+		return interval
+	}
+
+	delay := interval
+	for i := 1; i < consecutiveFailures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+
+	return delay
+}
+
+// snapshotEndpointWithTimeout runs the snapshot of an environment(endpoint) and stops waiting for it after snapshotTimeout.
+// The snapshot is taken on a copy of the environment so that a late result cannot be persisted.
+// The returned channel is closed once the snapshot has actually returned.
+func (service *Service) snapshotEndpointWithTimeout(endpoint *portainer.Endpoint) (<-chan struct{}, error) {
+	snapshotEndpoint := *endpoint
+	result := make(chan error, 1)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		result <- service.snapshotEndpoint(&snapshotEndpoint)
+	}()
+
+	timer := time.NewTimer(service.snapshotTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-result:
+		endpoint.Snapshots = snapshotEndpoint.Snapshots
+		endpoint.Kubernetes.Snapshots = snapshotEndpoint.Kubernetes.Snapshots
+		endpoint.Agent.Version = snapshotEndpoint.Agent.Version
+
+		return done, err
+	case <-timer.C:
+		return done, errSnapshotTimeout
+	}
+}
+
+// persistEndpointSnapshot saves the outcome of the snapshot of an environment(endpoint)
+func (service *Service) persistEndpointSnapshot(endpoint portainer.Endpoint, snapshotError error) {
+	service.reschedule(&endpoint, snapshotError, time.Now())
+
+	latestEndpointReference, err := service.dataStore.Endpoint().Endpoint(endpoint.ID)
+	if latestEndpointReference == nil {
+		log.Debug().
+			Str("endpoint", endpoint.Name).
+			Str("URL", endpoint.URL).Err(err).
+			Msg("background schedule error (environment snapshot), environment not found inside the database anymore")
+
+		return
+	}
+
+	if snapshotError != nil {
+		log.Debug().
+			Str("endpoint", endpoint.Name).
+			Str("URL", endpoint.URL).Err(snapshotError).
+			Msg("background schedule error (environment snapshot), unable to create snapshot")
+
+		// nothing changed, avoid rewriting the environment
+		if latestEndpointReference.Status == portainer.EndpointStatusDown {
+			return
 		}
 
+		latestEndpointReference.Status = portainer.EndpointStatusDown
+	} else {
+		latestEndpointReference.Status = portainer.EndpointStatusUp
 		latestEndpointReference.Snapshots = endpoint.Snapshots
 		latestEndpointReference.Kubernetes.Snapshots = endpoint.Kubernetes.Snapshots
 		latestEndpointReference.Agent.Version = endpoint.Agent.Version
+	}
 
-		err = service.dataStore.Endpoint().UpdateEndpoint(latestEndpointReference.ID, latestEndpointReference)
-		if err != nil {
-			log.Debug().
-				Str("endpoint", endpoint.Name).
-				Str("URL", endpoint.URL).Err(err).
-				Msg("background schedule error (environment snapshot), unable to update environment")
+	err = service.dataStore.Endpoint().UpdateEndpointStatus(latestEndpointReference.ID, latestEndpointReference)
+	if err != nil {
+		log.Debug().
+			Str("endpoint", endpoint.Name).
+			Str("URL", endpoint.URL).Err(err).
+			Msg("background schedule error (environment snapshot), unable to update environment")
 
-			continue
-		}
+		return
 	}
 
-	return nil
+	if snapshotError != nil {
+		return
+	}
+
+	err = service.saveKubernetesSnapshotRaw(&endpoint)
+	if err != nil {
+		log.Debug().
+			Str("endpoint", endpoint.Name).
+			Str("URL", endpoint.URL).Err(err).
+			Msg("background schedule error (environment snapshot), unable to save the raw Kubernetes snapshot")
+	}
+
+	service.recordSnapshotHistory(latestEndpointReference)
 }
 
 // FetchDockerID fetches info.Swarm.Cluster.ID if environment(endpoint) is swarm and info.ID otherwise
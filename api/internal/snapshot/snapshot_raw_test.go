@@ -0,0 +1,81 @@
+package snapshot_test
+
+import (
+	"context"
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/portainer/portainer/api/internal/snapshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type kubernetesSnapshotter struct{}
+
+func (snapshotter *kubernetesSnapshotter) CreateSnapshot(endpoint *portainer.Endpoint) (*portainer.KubernetesSnapshot, error) {
+	return &portainer.KubernetesSnapshot{
+		Time:        10,
+		NodeCount:   1,
+		SnapshotRaw: portainer.KubernetesSnapshotRaw{Pods: []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "web"}}}},
+	}, nil
+}
+
+func Test_SnapshotEndpoint_shouldStoreTheRawKubernetesSnapshotApart(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	service, err := snapshot.NewService("5m", 1, 0, store, nil, &kubernetesSnapshotter{}, context.Background())
+	require.NoError(t, err)
+
+	endpoint := &portainer.Endpoint{ID: 1, Type: portainer.KubernetesLocalEnvironment}
+	err = store.Endpoint().Create(endpoint)
+	require.NoError(t, err)
+
+	err = service.SnapshotEndpoint(endpoint)
+	require.NoError(t, err)
+
+	err = store.Endpoint().UpdateEndpoint(endpoint.ID, endpoint)
+	require.NoError(t, err)
+
+	stored, err := store.Endpoint().Endpoint(endpoint.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.Kubernetes.Snapshots, 1)
+	is.Equal(1, stored.Kubernetes.Snapshots[0].NodeCount)
+	is.Empty(stored.Kubernetes.Snapshots[0].SnapshotRaw.Pods, "the raw resources should not be stored in the environment")
+
+	snapshotRaw, err := store.KubernetesSnapshotRaw().SnapshotRaw(endpoint.ID)
+	require.NoError(t, err)
+	require.Len(t, snapshotRaw.Pods, 1)
+	is.Equal("web", snapshotRaw.Pods[0].Name)
+}
+
+func Test_SnapshotEndpoint_shouldRecordTheSnapshotHistory(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	service, err := snapshot.NewService("5m", 1, 0, store, nil, &kubernetesSnapshotter{}, context.Background())
+	require.NoError(t, err)
+
+	// the snapshots taken outside of the background loop, such as the snapshots of the Edge environments
+	// or the snapshots requested through the API, are part of the history
+	endpoint := &portainer.Endpoint{ID: 1, Type: portainer.EdgeAgentOnKubernetesEnvironment}
+	err = store.Endpoint().Create(endpoint)
+	require.NoError(t, err)
+
+	err = service.SnapshotEndpoint(endpoint)
+	require.NoError(t, err)
+
+	entries, err := store.SnapshotHistory().EntriesByEndpointID(endpoint.ID, 0, 100)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	is.Equal(int64(10), entries[0].Time)
+	is.Equal(float64(1), entries[0].Metrics[portainer.SnapshotHistoryMetricNodes])
+}
@@ -0,0 +1,300 @@
+package snapshot
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	i "github.com/portainer/portainer/api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_backoffDelay(t *testing.T) {
+	tests := []struct {
+		interval            time.Duration
+		consecutiveFailures int
+		expected            time.Duration
+	}{
+		{interval: 5 * time.Minute, consecutiveFailures: 0, expected: 5 * time.Minute},
+		{interval: 5 * time.Minute, consecutiveFailures: 1, expected: 5 * time.Minute},
+		{interval: 5 * time.Minute, consecutiveFailures: 2, expected: 10 * time.Minute},
+		{interval: 5 * time.Minute, consecutiveFailures: 3, expected: 20 * time.Minute},
+		{interval: 5 * time.Minute, consecutiveFailures: 5, expected: maxBackoff},
+		{interval: 5 * time.Minute, consecutiveFailures: 100, expected: maxBackoff},
+		{interval: 2 * time.Hour, consecutiveFailures: 3, expected: 2 * time.Hour},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, backoffDelay(test.interval, test.consecutiveFailures))
+	}
+}
+
+func Test_endpointInterval(t *testing.T) {
+	service := &Service{snapshotIntervalInSeconds: 300}
+
+	assert.Equal(t, 5*time.Minute, service.endpointInterval(&portainer.Endpoint{}))
+	assert.Equal(t, 30*time.Second, service.endpointInterval(&portainer.Endpoint{SnapshotInterval: "30s"}))
+	assert.Equal(t, 5*time.Minute, service.endpointInterval(&portainer.Endpoint{SnapshotInterval: "invalid"}))
+}
+
+func Test_dueEndpoints(t *testing.T) {
+	is := assert.New(t)
+
+	now := time.Now()
+	service := &Service{
+		snapshotIntervalInSeconds: 300,
+		schedules: map[portainer.EndpointID]*endpointSchedule{
+			1: {nextSnapshot: now.Add(time.Minute)},
+			2: {nextSnapshot: now.Add(-time.Minute)},
+			9: {nextSnapshot: now.Add(-time.Minute)},
+		},
+	}
+
+	endpoints := []portainer.Endpoint{
+		{ID: 1, Type: portainer.DockerEnvironment},
+		{ID: 2, Type: portainer.AgentOnDockerEnvironment},
+		{ID: 3, Type: portainer.KubernetesLocalEnvironment},
+		{ID: 4, Type: portainer.EdgeAgentOnDockerEnvironment},
+	}
+
+	due := service.dueEndpoints(endpoints, now)
+
+	dueIDs := []portainer.EndpointID{}
+	for _, endpoint := range due {
+		dueIDs = append(dueIDs, endpoint.ID)
+	}
+
+	is.ElementsMatch([]portainer.EndpointID{2, 3}, dueIDs)
+	is.NotContains(service.schedules, portainer.EndpointID(9), "schedules of removed environments should be dropped")
+
+	service.reschedule(&endpoints[1], errSnapshotTimeout, now)
+	service.reschedule(&endpoints[1], errSnapshotTimeout, now)
+	is.Equal(now.Add(10*time.Minute), service.schedules[2].nextSnapshot)
+
+	service.reschedule(&endpoints[1], nil, now)
+	service.reschedule(&endpoints[2], nil, now)
+	is.Equal(now.Add(5*time.Minute), service.schedules[2].nextSnapshot)
+	is.Equal(1*time.Minute, service.nextWakeUp(now))
+}
+
+type blockingDockerSnapshotter struct {
+	release chan struct{}
+	calls   int32
+}
+
+func (snapshotter *blockingDockerSnapshotter) CreateSnapshot(endpoint *portainer.Endpoint) (*portainer.DockerSnapshot, error) {
+	atomic.AddInt32(&snapshotter.calls, 1)
+	<-snapshotter.release
+
+	return &portainer.DockerSnapshot{Time: 1}, nil
+}
+
+func Test_snapshotEndpointWithTimeout(t *testing.T) {
+	is := assert.New(t)
+
+	snapshotter := &blockingDockerSnapshotter{release: make(chan struct{})}
+	service := &Service{snapshotTimeout: 10 * time.Millisecond, dockerSnapshotter: snapshotter}
+
+	endpoint := &portainer.Endpoint{ID: 1, Type: portainer.DockerEnvironment}
+
+	done, err := service.snapshotEndpointWithTimeout(endpoint)
+	is.Equal(errSnapshotTimeout, err)
+	is.Empty(endpoint.Snapshots, "a late snapshot must not be applied")
+
+	select {
+	case <-done:
+		t.Fatal("the snapshot should still be running")
+	default:
+	}
+
+	close(snapshotter.release)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the snapshot should report when it returns")
+	}
+	is.Empty(endpoint.Snapshots, "a late snapshot must not be applied")
+}
+
+func Test_snapshotEndpoints_shouldNotWaitForTheTimedOutSnapshots(t *testing.T) {
+	is := assert.New(t)
+
+	snapshotter := &blockingDockerSnapshotter{release: make(chan struct{})}
+	endpoints := []portainer.Endpoint{
+		{ID: 1, Type: portainer.DockerEnvironment},
+		{ID: 2, Type: portainer.DockerEnvironment},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	service := &Service{
+		dataStore: i.NewDatastore(
+			i.WithEndpoints(endpoints),
+			i.WithSettingsService(&portainer.Settings{SnapshotHistory: portainer.SnapshotHistorySettings{Retention: "0"}}),
+		),
+		snapshotIntervalInSeconds: 300,
+		maxConcurrency:            1,
+		snapshotTimeout:           10 * time.Millisecond,
+		dockerSnapshotter:         snapshotter,
+		shutdownCtx:               ctx,
+		schedules:                 make(map[portainer.EndpointID]*endpointSchedule),
+	}
+
+	snapshotEndpoints := func() {
+		cycleDone := make(chan struct{})
+		go func() {
+			defer close(cycleDone)
+			is.NoError(service.snapshotEndpoints())
+		}()
+
+		select {
+		case <-cycleDone:
+		case <-time.After(time.Second):
+			t.Fatal("the snapshot cycle should not wait for the timed out snapshots")
+		}
+	}
+
+	due := func() {
+		service.schedulesMu.Lock()
+		defer service.schedulesMu.Unlock()
+
+		for _, schedule := range service.schedules {
+			schedule.nextSnapshot = time.Time{}
+		}
+	}
+
+	snapshotEndpoints()
+	is.EqualValues(2, atomic.LoadInt32(&snapshotter.calls), "a timed out snapshot should not hold up the other environments")
+
+	due()
+	snapshotEndpoints()
+	is.EqualValues(2, atomic.LoadInt32(&snapshotter.calls), "an environment should be skipped until its timed out snapshot returns")
+
+	close(snapshotter.release)
+
+	is.Eventually(func() bool {
+		service.schedulesMu.Lock()
+		defer service.schedulesMu.Unlock()
+
+		return !service.schedules[1].timedOut && !service.schedules[2].timedOut
+	}, time.Second, time.Millisecond)
+
+	due()
+	snapshotEndpoints()
+	is.EqualValues(4, atomic.LoadInt32(&snapshotter.calls))
+}
+
+// countingDataStore counts the reads of the environments(endpoints), each snapshot cycle reads them once
+type countingDataStore struct {
+	dataservices.DataStore
+	endpoint *countingEndpointService
+}
+
+func (store *countingDataStore) Endpoint() dataservices.EndpointService {
+	return store.endpoint
+}
+
+type countingEndpointService struct {
+	dataservices.EndpointService
+	calls int32
+}
+
+func (service *countingEndpointService) Endpoints() ([]portainer.Endpoint, error) {
+	atomic.AddInt32(&service.calls, 1)
+
+	return service.EndpointService.Endpoints()
+}
+
+func Test_startSnapshotLoop_shouldNotSpinWhileASnapshotHasTimedOut(t *testing.T) {
+	is := assert.New(t)
+
+	snapshotter := &blockingDockerSnapshotter{release: make(chan struct{})}
+	defer close(snapshotter.release)
+
+	// the backoff of the environment is shorter than its hung snapshot
+	dataStore := i.NewDatastore(
+		i.WithEndpoints([]portainer.Endpoint{{ID: 1, Type: portainer.DockerEnvironment, SnapshotInterval: "1s"}}),
+		i.WithSettingsService(&portainer.Settings{SnapshotHistory: portainer.SnapshotHistorySettings{Retention: "0"}}),
+	)
+	endpointService := &countingEndpointService{EndpointService: dataStore.Endpoint()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	service := &Service{
+		dataStore:                 &countingDataStore{DataStore: dataStore, endpoint: endpointService},
+		snapshotIntervalCh:        make(chan time.Duration),
+		snapshotIntervalInSeconds: 300,
+		maxConcurrency:            1,
+		snapshotTimeout:           10 * time.Millisecond,
+		dockerSnapshotter:         snapshotter,
+		shutdownCtx:               ctx,
+		lastHistoryMaintenance:    time.Now(),
+		schedules:                 make(map[portainer.EndpointID]*endpointSchedule),
+	}
+
+	go service.startSnapshotLoop()
+
+	is.Eventually(func() bool {
+		service.schedulesMu.Lock()
+		defer service.schedulesMu.Unlock()
+
+		schedule, ok := service.schedules[1]
+
+		return ok && schedule.timedOut
+	}, time.Second, time.Millisecond)
+
+	time.Sleep(1500 * time.Millisecond)
+
+	is.EqualValues(1, atomic.LoadInt32(&snapshotter.calls))
+	is.EqualValues(1, atomic.LoadInt32(&endpointService.calls), "the loop should not wake up for an environment whose snapshot has timed out")
+}
+
+func Test_SetSnapshotInterval_shouldKeepTheDueSnapshots(t *testing.T) {
+	is := assert.New(t)
+
+	snapshotter := &blockingDockerSnapshotter{release: make(chan struct{})}
+	close(snapshotter.release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	service := &Service{
+		dataStore: i.NewDatastore(
+			i.WithEndpoints([]portainer.Endpoint{{ID: 1, Type: portainer.DockerEnvironment, SnapshotInterval: "1h"}}),
+			i.WithSettingsService(&portainer.Settings{SnapshotHistory: portainer.SnapshotHistorySettings{Retention: "0"}}),
+		),
+		snapshotIntervalCh:        make(chan time.Duration),
+		snapshotIntervalInSeconds: 300,
+		maxConcurrency:            1,
+		snapshotTimeout:           time.Second,
+		dockerSnapshotter:         snapshotter,
+		shutdownCtx:               ctx,
+		lastHistoryMaintenance:    time.Now(),
+		schedules:                 make(map[portainer.EndpointID]*endpointSchedule),
+	}
+
+	go service.startSnapshotLoop()
+
+	is.Eventually(func() bool {
+		return atomic.LoadInt32(&snapshotter.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	// the loop is back waiting once it receives the interval
+	is.NoError(service.SetSnapshotInterval("5m"))
+
+	service.schedulesMu.Lock()
+	service.schedules[1].nextSnapshot = time.Now()
+	service.schedulesMu.Unlock()
+
+	is.NoError(service.SetSnapshotInterval("10m"))
+
+	is.Eventually(func() bool {
+		return atomic.LoadInt32(&snapshotter.calls) == 2
+	}, 2*time.Second, 10*time.Millisecond, "a due environment should not wait for the new snapshot interval")
+}
@@ -9,6 +9,9 @@ import (
 )
 
 type testDatastore struct {
+	auditLog                dataservices.AuditLogService
+	backupRun               dataservices.BackupRunService
+	backupSchedule          dataservices.BackupScheduleService
 	customTemplate          dataservices.CustomTemplateService
 	edgeGroup               dataservices.EdgeGroupService
 	edgeJob                 dataservices.EdgeJobService
@@ -19,12 +22,15 @@ type testDatastore struct {
 	endpointRelation        dataservices.EndpointRelationService
 	fdoProfile              dataservices.FDOProfileService
 	helmUserRepository      dataservices.HelmUserRepositoryService
+	kubernetesSnapshotRaw   dataservices.KubernetesSnapshotRawService
 	registry                dataservices.RegistryService
 	resourceControl         dataservices.ResourceControlService
 	apiKeyRepositoryService dataservices.APIKeyRepository
 	role                    dataservices.RoleService
 	sslSettings             dataservices.SSLSettingsService
+	session                 dataservices.SessionService
 	settings                dataservices.SettingsService
+	snapshotHistory         dataservices.SnapshotHistoryService
 	stack                   dataservices.StackService
 	tag                     dataservices.TagService
 	teamMembership          dataservices.TeamMembershipService
@@ -42,6 +48,9 @@ func (d *testDatastore) Close() error                                       { re
 func (d *testDatastore) CheckCurrentEdition() error                         { return nil }
 func (d *testDatastore) MigrateData() error                                 { return nil }
 func (d *testDatastore) Rollback(force bool) error                          { return nil }
+func (d *testDatastore) AuditLog() dataservices.AuditLogService             { return d.auditLog }
+func (d *testDatastore) BackupRun() dataservices.BackupRunService           { return d.backupRun }
+func (d *testDatastore) BackupSchedule() dataservices.BackupScheduleService { return d.backupSchedule }
 func (d *testDatastore) CustomTemplate() dataservices.CustomTemplateService { return d.customTemplate }
 func (d *testDatastore) EdgeGroup() dataservices.EdgeGroupService           { return d.edgeGroup }
 func (d *testDatastore) EdgeJob() dataservices.EdgeJobService               { return d.edgeJob }
@@ -60,6 +69,9 @@ func (d *testDatastore) EndpointRelation() dataservices.EndpointRelationService
 func (d *testDatastore) HelmUserRepository() dataservices.HelmUserRepositoryService {
 	return d.helmUserRepository
 }
+func (d *testDatastore) KubernetesSnapshotRaw() dataservices.KubernetesSnapshotRawService {
+	return d.kubernetesSnapshotRaw
+}
 func (d *testDatastore) Registry() dataservices.RegistryService { return d.registry }
 func (d *testDatastore) ResourceControl() dataservices.ResourceControlService {
 	return d.resourceControl
@@ -68,7 +80,11 @@ func (d *testDatastore) Role() dataservices.RoleService { return d.role }
 func (d *testDatastore) APIKeyRepository() dataservices.APIKeyRepository {
 	return d.apiKeyRepositoryService
 }
-func (d *testDatastore) Settings() dataservices.SettingsService             { return d.settings }
+func (d *testDatastore) Session() dataservices.SessionService   { return d.session }
+func (d *testDatastore) Settings() dataservices.SettingsService { return d.settings }
+func (d *testDatastore) SnapshotHistory() dataservices.SnapshotHistoryService {
+	return d.snapshotHistory
+}
 func (d *testDatastore) SSLSettings() dataservices.SSLSettingsService       { return d.sslSettings }
 func (d *testDatastore) Stack() dataservices.StackService                   { return d.stack }
 func (d *testDatastore) Tag() dataservices.TagService                       { return d.tag }
@@ -86,6 +102,22 @@ func (d *testDatastore) IsErrObjectNotFound(e error) bool {
 func (d *testDatastore) Export(filename string) (err error) {
 	return nil
 }
+
+func (d *testDatastore) CheckIntegrity() (*portainer.StoreIntegrityReport, error) {
+	return &portainer.StoreIntegrityReport{}, nil
+}
+
+func (d *testDatastore) RepairIntegrity() (*portainer.StoreIntegrityReport, error) {
+	return &portainer.StoreIntegrityReport{}, nil
+}
+
+func (d *testDatastore) RotateEncryptionKey(currentKey, newKey []byte) error {
+	return nil
+}
+func (d *testDatastore) MergeBuckets(filename string, buckets []string) (map[string][]int, error) {
+	return map[string][]int{}, nil
+}
+
 func (d *testDatastore) Import(filename string) (err error) {
 	return nil
 }
@@ -95,7 +127,8 @@ type datastoreOption = func(d *testDatastore)
 // NewDatastore creates new instance of testDatastore.
 // Will apply options before returning, opts will be applied from left to right.
 func NewDatastore(options ...datastoreOption) *testDatastore {
-	d := testDatastore{}
+	// the JWT service loads the revoked sessions when it is created
+	d := testDatastore{session: &stubSessionService{}}
 	for _, o := range options {
 		o(&d)
 	}
@@ -126,6 +159,24 @@ func WithSettingsService(settings *portainer.Settings) datastoreOption {
 	}
 }
 
+type stubSessionService struct {
+	sessions []portainer.Session
+}
+
+func (s *stubSessionService) BucketName() string { return "sessions" }
+func (s *stubSessionService) Session(ID string) (*portainer.Session, error) {
+	return nil, errors.ErrObjectNotFound
+}
+func (s *stubSessionService) Sessions(filter func(session *portainer.Session) bool) ([]portainer.Session, error) {
+	return s.sessions, nil
+}
+func (s *stubSessionService) SessionsByUserID(userID portainer.UserID) ([]portainer.Session, error) {
+	return s.sessions, nil
+}
+func (s *stubSessionService) Create(session *portainer.Session) error        { return nil }
+func (s *stubSessionService) UpdateSession(session *portainer.Session) error { return nil }
+func (s *stubSessionService) DeleteSession(ID string) error                  { return nil }
+
 type stubUserService struct {
 	users []portainer.User
 }
@@ -140,6 +191,9 @@ func (s *stubUserService) UsersByRole(role portainer.UserRole) ([]portainer.User
 func (s *stubUserService) Create(user *portainer.User) error                          { return nil }
 func (s *stubUserService) UpdateUser(ID portainer.UserID, user *portainer.User) error { return nil }
 func (s *stubUserService) DeleteUser(ID portainer.UserID) error                       { return nil }
+func (s *stubUserService) UpdateUserFunc(ID portainer.UserID, updateFunc func(user *portainer.User) bool) (*portainer.User, error) {
+	return nil, nil
+}
 
 // WithUsers testDatastore option that will instruct testDatastore to return provided users
 func WithUsers(us []portainer.User) datastoreOption {
@@ -249,6 +303,10 @@ func (s *stubEndpointService) UpdateEndpoint(ID portainer.EndpointID, endpoint *
 	return nil
 }
 
+func (s *stubEndpointService) UpdateEndpointStatus(ID portainer.EndpointID, endpoint *portainer.Endpoint) error {
+	return s.UpdateEndpoint(ID, endpoint)
+}
+
 func (s *stubEndpointService) DeleteEndpoint(ID portainer.EndpointID) error {
 	endpoints := []portainer.Endpoint{}
 
@@ -22,6 +22,7 @@ type Service struct {
 	secrets            map[scope][]byte
 	userSessionTimeout time.Duration
 	dataStore          dataservices.DataStore
+	sessions           *sessionCache
 }
 
 type claims struct {
@@ -30,6 +31,9 @@ type claims struct {
 	Role                int    `json:"role"`
 	Scope               scope  `json:"scope"`
 	ForceChangePassword bool   `json:"forceChangePassword"`
+	// the restrictions and the expiry of the API key the token was generated from, so that the token cannot exceed them
+	APIKeyScope     *portainer.APIKeyScope `json:"apiKeyScope,omitempty"`
+	APIKeyExpiresAt int64                  `json:"apiKeyExpiresAt,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -41,6 +45,7 @@ var (
 const (
 	defaultScope    = scope("default")
 	kubeConfigScope = scope("kubeconfig")
+	preAuthScope    = scope("preauth")
 )
 
 // NewService initializes a new service. It will generate a random key that will be used to sign JWT tokens.
@@ -55,6 +60,11 @@ func NewService(userSessionDuration string, dataStore dataservices.DataStore) (*
 		return nil, errSecretGeneration
 	}
 
+	preAuthSecret := securecookie.GenerateRandomKey(32)
+	if preAuthSecret == nil {
+		return nil, errSecretGeneration
+	}
+
 	kubeSecret, err := getOrCreateKubeSecret(dataStore)
 	if err != nil {
 		return nil, err
@@ -64,10 +74,18 @@ func NewService(userSessionDuration string, dataStore dataservices.DataStore) (*
 		map[scope][]byte{
 			defaultScope:    secret,
 			kubeConfigScope: kubeSecret,
+			preAuthScope:    preAuthSecret,
 		},
 		userSessionTimeout,
 		dataStore,
+		newSessionCache(),
+	}
+
+	err = service.loadRevokedSessions()
+	if err != nil {
+		return nil, err
 	}
+
 	return service, nil
 }
 
@@ -101,19 +119,18 @@ func (service *Service) GenerateToken(data *portainer.TokenData) (string, error)
 	return service.generateSignedToken(data, service.defaultExpireAt(), defaultScope)
 }
 
-// GenerateTokenForOAuth generates a new JWT token for OAuth login
-// token expiry time response from OAuth provider is considered
-func (service *Service) GenerateTokenForOAuth(data *portainer.TokenData, expiryTime *time.Time) (string, error) {
-	expireAt := service.defaultExpireAt()
-	if expiryTime != nil && !expiryTime.IsZero() {
-		expireAt = expiryTime.Unix()
-	}
-	return service.generateSignedToken(data, expireAt, defaultScope)
-}
-
 // ParseAndVerifyToken parses a JWT token and verify its validity. It returns an error if token is invalid.
+// The pre-authentication tokens are rejected.
 func (service *Service) ParseAndVerifyToken(token string) (*portainer.TokenData, error) {
 	scope := parseScope(token)
+	if scope == preAuthScope {
+		return nil, errInvalidJWTToken
+	}
+
+	return service.parseAndVerifyToken(token, scope)
+}
+
+func (service *Service) parseAndVerifyToken(token string, scope scope) (*portainer.TokenData, error) {
 	secret := service.secrets[scope]
 	parsedToken, err := jwt.ParseWithClaims(token, &claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -130,15 +147,38 @@ func (service *Service) ParseAndVerifyToken(token string) (*portainer.TokenData,
 			if err != nil {
 				return nil, errInvalidJWTToken
 			}
-			if user.TokenIssueAt > cl.StandardClaims.IssuedAt {
+			if user.Disabled || user.TokenIssueAt > cl.StandardClaims.IssuedAt {
 				return nil, errInvalidJWTToken
 			}
 
-			return &portainer.TokenData{
-				ID:       portainer.UserID(cl.UserID),
-				Username: cl.Username,
-				Role:     portainer.UserRole(cl.Role),
-			}, nil
+			if cl.StandardClaims.Id != "" && service.sessions.isRevoked(cl.StandardClaims.Id) {
+				return nil, errInvalidJWTToken
+			}
+
+			if cl.APIKeyExpiresAt != 0 && cl.APIKeyExpiresAt <= time.Now().Unix() {
+				return nil, errInvalidJWTToken
+			}
+
+			tokenData := &portainer.TokenData{
+				ID:              portainer.UserID(cl.UserID),
+				Username:        cl.Username,
+				Role:            portainer.UserRole(cl.Role),
+				APIKeyScope:     cl.APIKeyScope,
+				APIKeyExpiresAt: cl.APIKeyExpiresAt,
+			}
+
+			// the pre-authentication token carries the password check result to the final token
+			if scope == preAuthScope {
+				tokenData.ForceChangePassword = cl.ForceChangePassword
+			}
+
+			// only the tokens issued at login are recorded as sessions
+			if cl.StandardClaims.Id != "" {
+				tokenData.SessionID = cl.StandardClaims.Id
+				service.sessionSeen(cl.StandardClaims.Id)
+			}
+
+			return tokenData, nil
 		}
 	}
 	return nil, errInvalidJWTToken
@@ -149,8 +189,8 @@ func parseScope(token string) scope {
 	unverifiedToken, _, _ := new(jwt.Parser).ParseUnverified(token, &claims{})
 	if unverifiedToken != nil {
 		if cl, ok := unverifiedToken.Claims.(*claims); ok {
-			if cl.Scope == kubeConfigScope {
-				return kubeConfigScope
+			if cl.Scope == kubeConfigScope || cl.Scope == preAuthScope {
+				return cl.Scope
 			}
 		}
 	}
@@ -164,15 +204,21 @@ func (service *Service) SetUserSessionDuration(userSessionDuration time.Duration
 }
 
 func (service *Service) generateSignedToken(data *portainer.TokenData, expiresAt int64, scope scope) (string, error) {
+	return service.generateSignedTokenWithID(data, expiresAt, scope, "")
+}
+
+// generateSignedTokenWithID generates a token carrying the JWT identifier ID, only the session tokens have one.
+// The session of data is ignored, so that the tokens generated from the token of a session are not bound to it.
+// A token generated from a request authenticated with an API key carries its scope and never outlives it.
+func (service *Service) generateSignedTokenWithID(data *portainer.TokenData, expiresAt int64, scope scope, ID string) (string, error) {
 	secret, found := service.secrets[scope]
 	if !found {
 		return "", fmt.Errorf("invalid scope: %v", scope)
 	}
 
-	if _, ok := os.LookupEnv("DOCKER_EXTENSION"); ok {
-		// Set expiration to 99 years for docker desktop extension.
-		log.Info().Msg("detected docker desktop extension mode")
-		expiresAt = time.Now().Add(time.Hour * 8760 * 99).Unix()
+	expiresAt = tokenExpireAt(expiresAt)
+	if data.APIKeyExpiresAt != 0 && (expiresAt == 0 || data.APIKeyExpiresAt < expiresAt) {
+		expiresAt = data.APIKeyExpiresAt
 	}
 
 	cl := claims{
@@ -181,7 +227,10 @@ func (service *Service) generateSignedToken(data *portainer.TokenData, expiresAt
 		Role:                int(data.Role),
 		Scope:               scope,
 		ForceChangePassword: data.ForceChangePassword,
+		APIKeyScope:         data.APIKeyScope,
+		APIKeyExpiresAt:     data.APIKeyExpiresAt,
 		StandardClaims: jwt.StandardClaims{
+			Id:        ID,
 			ExpiresAt: expiresAt,
 			IssuedAt:  time.Now().Unix(),
 		},
@@ -195,3 +244,14 @@ func (service *Service) generateSignedToken(data *portainer.TokenData, expiresAt
 
 	return signedToken, nil
 }
+
+// tokenExpireAt returns the expiration time of a token, it is overridden for the docker desktop extension
+func tokenExpireAt(expiresAt int64) int64 {
+	if _, ok := os.LookupEnv("DOCKER_EXTENSION"); ok {
+		// Set expiration to 99 years for docker desktop extension.
+		log.Info().Msg("detected docker desktop extension mode")
+		return time.Now().Add(time.Hour * 8760 * 99).Unix()
+	}
+
+	return expiresAt
+}
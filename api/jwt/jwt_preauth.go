@@ -0,0 +1,25 @@
+package jwt
+
+import (
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+)
+
+// preAuthTokenExpiry is the time given to a user to complete the multi-factor authentication
+const preAuthTokenExpiry = 5 * time.Minute
+
+// GeneratePreAuthToken generates a short-lived token proving that a user passed the first authentication step.
+// It cannot be used to access the API, only to complete the multi-factor authentication.
+func (service *Service) GeneratePreAuthToken(data *portainer.TokenData) (string, error) {
+	return service.generateSignedToken(data, time.Now().Add(preAuthTokenExpiry).Unix(), preAuthScope)
+}
+
+// ParsePreAuthToken parses a pre-authentication token and verify its validity. It returns an error if the token is invalid.
+func (service *Service) ParsePreAuthToken(token string) (*portainer.TokenData, error) {
+	if parseScope(token) != preAuthScope {
+		return nil, errInvalidJWTToken
+	}
+
+	return service.parseAndVerifyToken(token, preAuthScope)
+}
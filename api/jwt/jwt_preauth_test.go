@@ -0,0 +1,42 @@
+package jwt
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_GeneratePreAuthToken(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	err := store.User().Create(&portainer.User{ID: 1, Username: "Joe", Role: portainer.AdministratorRole})
+	is.NoError(err)
+
+	service, err := NewService("24h", store)
+	is.NoError(err)
+
+	tokenData := &portainer.TokenData{ID: 1, Username: "Joe", Role: portainer.AdministratorRole, ForceChangePassword: true}
+
+	preAuthToken, err := service.GeneratePreAuthToken(tokenData)
+	is.NoError(err)
+
+	_, err = service.ParseAndVerifyToken(preAuthToken)
+	is.Error(err, "a pre-authentication token must not be accepted as a session token")
+
+	parsed, err := service.ParsePreAuthToken(preAuthToken)
+	is.NoError(err)
+	is.Equal(tokenData.ID, parsed.ID)
+	is.Equal(tokenData.Username, parsed.Username)
+	is.True(parsed.ForceChangePassword)
+
+	token, err := service.GenerateToken(&portainer.TokenData{ID: 1, Username: "Joe", Role: portainer.AdministratorRole})
+	is.NoError(err)
+
+	_, err = service.ParsePreAuthToken(token)
+	is.Error(err, "a session token must not be accepted as a pre-authentication token")
+}
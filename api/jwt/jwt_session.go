@@ -0,0 +1,221 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"sync"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/scheduler"
+
+	"github.com/gorilla/securecookie"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sessionLastSeenInterval is the minimum delay between two updates of the last seen time of a session
+	sessionLastSeenInterval = time.Minute
+	// sessionPurgeInterval is the delay between two purges of the expired sessions
+	sessionPurgeInterval = time.Hour
+)
+
+// sessionCache keeps the revoked sessions in memory so that they can be rejected without reading the database.
+// A revoked session is kept until its token expires.
+type sessionCache struct {
+	mu       sync.Mutex
+	revoked  map[string]int64
+	lastSeen map[string]int64
+	// updateMu serializes the updates of the session records so that a last seen update cannot undo a revocation
+	updateMu sync.Mutex
+}
+
+func newSessionCache() *sessionCache {
+	return &sessionCache{
+		revoked:  make(map[string]int64),
+		lastSeen: make(map[string]int64),
+	}
+}
+
+func (cache *sessionCache) isRevoked(ID string) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	_, revoked := cache.revoked[ID]
+	return revoked
+}
+
+func (cache *sessionCache) revoke(ID string, expiresAt int64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.revoked[ID] = expiresAt
+	delete(cache.lastSeen, ID)
+}
+
+// seen records a use of the session and returns true when its last seen time must be persisted
+func (cache *sessionCache) seen(ID string, now int64) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if now-cache.lastSeen[ID] < int64(sessionLastSeenInterval.Seconds()) {
+		return false
+	}
+
+	cache.lastSeen[ID] = now
+	return true
+}
+
+func (cache *sessionCache) forget(ID string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	delete(cache.revoked, ID)
+	delete(cache.lastSeen, ID)
+}
+
+func (cache *sessionCache) forgetExpired(now int64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for ID, expiresAt := range cache.revoked {
+		if expiresAt <= now {
+			delete(cache.revoked, ID)
+		}
+	}
+}
+
+// loadRevokedSessions fills the cache with the revoked sessions whose token has not expired yet
+func (service *Service) loadRevokedSessions() error {
+	now := time.Now().Unix()
+
+	sessions, err := service.dataStore.Session().Sessions(func(session *portainer.Session) bool {
+		return session.Revoked && session.ExpiresAt > now
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		service.sessions.revoke(session.ID, session.ExpiresAt)
+	}
+
+	return nil
+}
+
+// GenerateSessionToken generates a new JWT token and records it as a session of the user.
+func (service *Service) GenerateSessionToken(data *portainer.TokenData, userAgent, ipAddress string) (string, error) {
+	ID := securecookie.GenerateRandomKey(16)
+	if ID == nil {
+		return "", errSecretGeneration
+	}
+
+	now := time.Now().Unix()
+	session := &portainer.Session{
+		ID:        base64.RawURLEncoding.EncodeToString(ID),
+		UserID:    data.ID,
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: tokenExpireAt(service.defaultExpireAt()),
+	}
+
+	token, err := service.generateSignedTokenWithID(data, session.ExpiresAt, defaultScope, session.ID)
+	if err != nil {
+		return "", err
+	}
+
+	err = service.dataStore.Session().Create(session)
+	if err != nil {
+		return "", err
+	}
+
+	service.sessions.seen(session.ID, now)
+
+	return token, nil
+}
+
+// RevokeSession revokes a session, its token is rejected from now on.
+func (service *Service) RevokeSession(ID string) error {
+	service.sessions.updateMu.Lock()
+	defer service.sessions.updateMu.Unlock()
+
+	session, err := service.dataStore.Session().Session(ID)
+	if err != nil {
+		return err
+	}
+
+	session.Revoked = true
+	err = service.dataStore.Session().UpdateSession(session)
+	if err != nil {
+		return err
+	}
+
+	service.sessions.revoke(session.ID, session.ExpiresAt)
+
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions whose token expired before now.
+func (service *Service) DeleteExpiredSessions(now time.Time) error {
+	sessions, err := service.dataStore.Session().Sessions(func(session *portainer.Session) bool {
+		return session.ExpiresAt <= now.Unix()
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		err := service.dataStore.Session().DeleteSession(session.ID)
+		if err != nil {
+			return err
+		}
+
+		service.sessions.forget(session.ID)
+	}
+
+	service.sessions.forgetExpired(now.Unix())
+
+	return nil
+}
+
+// sessionSeen updates the last seen time of a session, at most once every sessionLastSeenInterval
+func (service *Service) sessionSeen(ID string) {
+	now := time.Now().Unix()
+	if !service.sessions.seen(ID, now) {
+		return
+	}
+
+	service.sessions.updateMu.Lock()
+	defer service.sessions.updateMu.Unlock()
+
+	session, err := service.dataStore.Session().Session(ID)
+	if err != nil {
+		log.Debug().Err(err).Str("session", ID).Msg("unable to retrieve the session")
+		return
+	}
+
+	if session.Revoked {
+		return
+	}
+
+	session.LastSeen = now
+	err = service.dataStore.Session().UpdateSession(session)
+	if err != nil {
+		log.Debug().Err(err).Str("session", ID).Msg("unable to update the session")
+	}
+}
+
+// StartExpiredSessionsPurge schedules the deletion of the expired sessions.
+// The expired tokens are rejected on use, the purge only removes their sessions from the database.
+func StartExpiredSessionsPurge(scheduler *scheduler.Scheduler, service dataservices.JWTService) {
+	scheduler.StartJobEvery(sessionPurgeInterval, func() error {
+		err := service.DeleteExpiredSessions(time.Now())
+		if err != nil {
+			log.Error().Err(err).Msg("unable to delete the expired sessions")
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_GenerateSessionToken(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	err := store.User().Create(&portainer.User{ID: 1, Username: "Joe", Role: portainer.AdministratorRole})
+	is.NoError(err)
+
+	service, err := NewService("24h", store)
+	is.NoError(err)
+
+	token, err := service.GenerateSessionToken(&portainer.TokenData{ID: 1, Username: "Joe", Role: portainer.AdministratorRole}, "curl/7.79.1", "10.0.0.1")
+	is.NoError(err)
+
+	parsed, err := service.ParseAndVerifyToken(token)
+	is.NoError(err)
+	is.NotEmpty(parsed.SessionID)
+
+	kubeconfigToken, err := service.GenerateTokenForKubeconfig(parsed)
+	is.NoError(err)
+
+	session, err := store.Session().Session(parsed.SessionID)
+	is.NoError(err)
+	is.Equal(portainer.UserID(1), session.UserID)
+	is.Equal("curl/7.79.1", session.UserAgent)
+	is.Equal("10.0.0.1", session.IPAddress)
+	is.False(session.Revoked)
+
+	err = service.RevokeSession(parsed.SessionID)
+	is.NoError(err)
+
+	_, err = service.ParseAndVerifyToken(token)
+	is.Error(err, "the token of a revoked session must be rejected")
+
+	kubeconfig, err := service.ParseAndVerifyToken(kubeconfigToken)
+	is.NoError(err, "a kubeconfig token generated from a session must not be bound to the session")
+	is.Empty(kubeconfig.SessionID)
+
+	restarted, err := NewService("24h", store)
+	is.NoError(err)
+
+	_, err = restarted.ParseAndVerifyToken(token)
+	is.Error(err, "the revoked sessions must be loaded when the service is created")
+
+	other, err := service.GenerateSessionToken(&portainer.TokenData{ID: 1, Username: "Joe", Role: portainer.AdministratorRole}, "", "")
+	is.NoError(err)
+
+	_, err = service.ParseAndVerifyToken(other)
+	is.NoError(err, "revoking a session must not affect the other sessions of the user")
+}
+
+func TestService_DeleteExpiredSessions(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, false)
+	defer teardown()
+
+	now := time.Now()
+
+	err := store.Session().Create(&portainer.Session{ID: "expired", UserID: 1, ExpiresAt: now.Add(-time.Minute).Unix(), Revoked: true})
+	is.NoError(err)
+	err = store.Session().Create(&portainer.Session{ID: "active", UserID: 1, ExpiresAt: now.Add(time.Hour).Unix()})
+	is.NoError(err)
+
+	service, err := NewService("24h", store)
+	is.NoError(err)
+
+	err = service.DeleteExpiredSessions(now)
+	is.NoError(err)
+
+	sessions, err := store.Session().SessionsByUserID(1)
+	is.NoError(err)
+	is.Len(sessions, 1)
+	is.Equal("active", sessions[0].ID)
+}
@@ -55,3 +55,33 @@ func TestGenerateSignedToken_InvalidScope(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "invalid scope: testing", err.Error())
 }
+
+func TestGenerateSignedToken_APIKey(t *testing.T) {
+	dataStore := i.NewDatastore(i.WithSettingsService(&portainer.Settings{}))
+	svc, err := NewService("24h", dataStore)
+	assert.NoError(t, err, "failed to create a copy of service")
+
+	token := &portainer.TokenData{
+		Username:        "Joe",
+		ID:              1,
+		Role:            1,
+		APIKeyScope:     &portainer.APIKeyScope{EndpointIDs: []portainer.EndpointID{12}},
+		APIKeyExpiresAt: time.Now().Add(30 * time.Minute).Unix(),
+	}
+
+	for _, expiresAt := range []int64{0, time.Now().Add(1 * time.Hour).Unix()} {
+		generatedToken, err := svc.generateSignedToken(token, expiresAt, kubeConfigScope)
+		assert.NoError(t, err, "failed to generate a signed token")
+
+		parsedToken, err := jwt.ParseWithClaims(generatedToken, &claims{}, func(token *jwt.Token) (interface{}, error) {
+			return svc.secrets[kubeConfigScope], nil
+		})
+		assert.NoError(t, err, "failed to parse generated token")
+
+		tokenClaims, ok := parsedToken.Claims.(*claims)
+		assert.Equal(t, true, ok, "failed to claims out of generated ticket")
+
+		assert.Equal(t, token.APIKeyExpiresAt, tokenClaims.ExpiresAt)
+		assert.Equal(t, token.APIKeyScope, tokenClaims.APIKeyScope)
+	}
+}
@@ -2,12 +2,15 @@ package kubernetes
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	portainer "github.com/portainer/portainer/api"
 	"github.com/portainer/portainer/api/kubernetes/cli"
 
 	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -51,6 +54,36 @@ func snapshot(cli *kubernetes.Clientset, endpoint *portainer.Endpoint) (*portain
 		log.Warn().Str("endpoint", endpoint.Name).Err(err).Msg("unable to snapshot cluster nodes")
 	}
 
+	err = snapshotWorkloads(snapshot, cli)
+	if err != nil {
+		log.Warn().Str("endpoint", endpoint.Name).Err(err).Msg("unable to snapshot cluster workloads")
+	}
+
+	err = snapshotPods(snapshot, cli)
+	if err != nil {
+		log.Warn().Str("endpoint", endpoint.Name).Err(err).Msg("unable to snapshot cluster pods")
+	}
+
+	err = snapshotPersistentVolumeClaims(snapshot, cli)
+	if err != nil {
+		log.Warn().Str("endpoint", endpoint.Name).Err(err).Msg("unable to snapshot cluster persistent volume claims")
+	}
+
+	err = snapshotServices(snapshot, cli)
+	if err != nil {
+		log.Warn().Str("endpoint", endpoint.Name).Err(err).Msg("unable to snapshot cluster services")
+	}
+
+	err = snapshotIngresses(snapshot, cli)
+	if err != nil {
+		log.Warn().Str("endpoint", endpoint.Name).Err(err).Msg("unable to snapshot cluster ingresses")
+	}
+
+	err = snapshotNamespaces(snapshot, cli)
+	if err != nil {
+		log.Warn().Str("endpoint", endpoint.Name).Err(err).Msg("unable to snapshot cluster namespaces")
+	}
+
 	snapshot.Time = time.Now().Unix()
 	return snapshot, nil
 }
@@ -65,20 +98,217 @@ func snapshotVersion(snapshot *portainer.KubernetesSnapshot, cli *kubernetes.Cli
 	return nil
 }
 
-func snapshotNodes(snapshot *portainer.KubernetesSnapshot, cli *kubernetes.Clientset) error {
+func snapshotNodes(snapshot *portainer.KubernetesSnapshot, cli kubernetes.Interface) error {
 	nodeList, err := cli.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
 		return err
 	}
 
-	var totalCPUs, totalMemory int64
+	var totalCPUs, totalMemory, allocatableMilliCPU, allocatableMemory int64
 	for _, node := range nodeList.Items {
 		totalCPUs += node.Status.Capacity.Cpu().Value()
 		totalMemory += node.Status.Capacity.Memory().Value()
+		allocatableMilliCPU += node.Status.Allocatable.Cpu().MilliValue()
+		allocatableMemory += node.Status.Allocatable.Memory().Value()
 	}
 
 	snapshot.TotalCPU = totalCPUs
 	snapshot.TotalMemory = totalMemory
+	snapshot.AllocatableCPU = milliToCores(allocatableMilliCPU)
+	snapshot.AllocatableMemory = allocatableMemory
 	snapshot.NodeCount = len(nodeList.Items)
+	snapshot.SnapshotRaw.Nodes = nodeList.Items
+	return nil
+}
+
+func snapshotWorkloads(snapshot *portainer.KubernetesSnapshot, cli kubernetes.Interface) error {
+	deployments, err := cli.AppsV1().Deployments("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	statefulSets, err := cli.AppsV1().StatefulSets("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	daemonSets, err := cli.AppsV1().DaemonSets("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	snapshot.DeploymentCount = len(deployments.Items)
+	snapshot.StatefulSetCount = len(statefulSets.Items)
+	snapshot.DaemonSetCount = len(daemonSets.Items)
+	snapshot.SnapshotRaw.Deployments = deployments.Items
+	snapshot.SnapshotRaw.StatefulSets = statefulSets.Items
+	snapshot.SnapshotRaw.DaemonSets = daemonSets.Items
+	return nil
+}
+
+func snapshotPods(snapshot *portainer.KubernetesSnapshot, cli kubernetes.Interface) error {
+	pods, err := cli.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	pendingPods, runningPods, succeededPods, failedPods, unknownPods := 0, 0, 0, 0, 0
+	for _, pod := range pods.Items {
+		switch pod.Status.Phase {
+		case v1.PodPending:
+			pendingPods++
+		case v1.PodRunning:
+			runningPods++
+		case v1.PodSucceeded:
+			succeededPods++
+		case v1.PodFailed:
+			failedPods++
+		default:
+			unknownPods++
+		}
+	}
+
+	snapshot.PendingPodCount = pendingPods
+	snapshot.RunningPodCount = runningPods
+	snapshot.SucceededPodCount = succeededPods
+	snapshot.FailedPodCount = failedPods
+	snapshot.UnknownPodCount = unknownPods
+	snapshot.SnapshotRaw.Pods = pods.Items
+	return nil
+}
+
+func snapshotPersistentVolumeClaims(snapshot *portainer.KubernetesSnapshot, cli kubernetes.Interface) error {
+	claims, err := cli.CoreV1().PersistentVolumeClaims("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	var boundCapacity int64
+	for _, claim := range claims.Items {
+		if claim.Status.Phase == v1.ClaimBound {
+			boundCapacity += claim.Status.Capacity.Storage().Value()
+		}
+	}
+
+	snapshot.PersistentVolumeClaimCount = len(claims.Items)
+	snapshot.BoundStorageCapacity = boundCapacity
+	snapshot.SnapshotRaw.PersistentVolumeClaims = claims.Items
 	return nil
 }
+
+func snapshotServices(snapshot *portainer.KubernetesSnapshot, cli kubernetes.Interface) error {
+	services, err := cli.CoreV1().Services("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	snapshot.ServiceCount = len(services.Items)
+	snapshot.SnapshotRaw.Services = services.Items
+	return nil
+}
+
+func snapshotIngresses(snapshot *portainer.KubernetesSnapshot, cli kubernetes.Interface) error {
+	ingresses, err := cli.NetworkingV1().Ingresses("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	snapshot.IngressCount = len(ingresses.Items)
+	snapshot.SnapshotRaw.Ingresses = ingresses.Items
+	return nil
+}
+
+// snapshotNamespaces computes the requested and allocatable resources of each namespace.
+// It relies on the pods gathered by snapshotPods and must therefore run after it.
+func snapshotNamespaces(snapshot *portainer.KubernetesSnapshot, cli kubernetes.Interface) error {
+	namespaces, err := cli.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	quotas, err := cli.CoreV1().ResourceQuotas("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	namespaceSnapshots := make(map[string]*portainer.KubernetesNamespaceSnapshot, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		namespaceSnapshots[namespace.Name] = &portainer.KubernetesNamespaceSnapshot{Name: namespace.Name}
+	}
+
+	// the CPU values are summed in millicores to avoid rounding errors
+	requestedMilliCPU := make(map[string]int64, len(namespaces.Items))
+	allocatableMilliCPU := make(map[string]int64, len(namespaces.Items))
+
+	for _, pod := range snapshot.SnapshotRaw.Pods {
+		namespaceSnapshot, ok := namespaceSnapshots[pod.Namespace]
+		if !ok {
+			continue
+		}
+
+		namespaceSnapshot.PodCount++
+
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+
+		for _, container := range pod.Spec.Containers {
+			requestedMilliCPU[pod.Namespace] += container.Resources.Requests.Cpu().MilliValue()
+			namespaceSnapshot.RequestedMemory += container.Resources.Requests.Memory().Value()
+		}
+	}
+
+	for _, quota := range quotas.Items {
+		namespaceSnapshot, ok := namespaceSnapshots[quota.Namespace]
+		if !ok {
+			continue
+		}
+
+		if cpu, ok := quotaHardLimit(quota, v1.ResourceRequestsCPU, v1.ResourceCPU); ok {
+			allocatableMilliCPU[quota.Namespace] = minQuota(allocatableMilliCPU[quota.Namespace], cpu.MilliValue())
+		}
+
+		if memory, ok := quotaHardLimit(quota, v1.ResourceRequestsMemory, v1.ResourceMemory); ok {
+			namespaceSnapshot.AllocatableMemory = minQuota(namespaceSnapshot.AllocatableMemory, memory.Value())
+		}
+	}
+
+	snapshot.Namespaces = make([]portainer.KubernetesNamespaceSnapshot, 0, len(namespaceSnapshots))
+	for name, namespaceSnapshot := range namespaceSnapshots {
+		namespaceSnapshot.RequestedCPU = milliToCores(requestedMilliCPU[name])
+		namespaceSnapshot.AllocatableCPU = milliToCores(allocatableMilliCPU[name])
+		snapshot.Namespaces = append(snapshot.Namespaces, *namespaceSnapshot)
+	}
+
+	sort.Slice(snapshot.Namespaces, func(i, j int) bool {
+		return snapshot.Namespaces[i].Name < snapshot.Namespaces[j].Name
+	})
+
+	snapshot.SnapshotRaw.Namespaces = namespaces.Items
+	return nil
+}
+
+// quotaHardLimit returns the first hard limit of the quota defined for one of the resource names
+func quotaHardLimit(quota v1.ResourceQuota, names ...v1.ResourceName) (resource.Quantity, bool) {
+	for _, name := range names {
+		if quantity, ok := quota.Spec.Hard[name]; ok {
+			return quantity, true
+		}
+	}
+
+	return resource.Quantity{}, false
+}
+
+// minQuota returns the most restrictive of two quota limits, zero meaning that no limit is set yet.
+// The most restrictive quota applies when several quotas are defined in the same namespace.
+func minQuota(current, limit int64) int64 {
+	if current == 0 || limit < current {
+		return limit
+	}
+
+	return current
+}
+
+func milliToCores(milliCPU int64) float64 {
+	return float64(milliCPU) / 1000
+}
@@ -0,0 +1,104 @@
+package kubernetes
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newPod(namespace, name string, phase v1.PodPhase, cpu, memory string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name: "main",
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{
+							v1.ResourceCPU:    resource.MustParse(cpu),
+							v1.ResourceMemory: resource.MustParse(memory),
+						},
+					},
+				},
+			},
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func Test_snapshotPodsAndNamespaces(t *testing.T) {
+	is := assert.New(t)
+
+	cli := kfake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		newPod("default", "web", v1.PodRunning, "250m", "64Mi"),
+		newPod("default", "job", v1.PodSucceeded, "1", "1Gi"),
+		newPod("team-a", "api", v1.PodRunning, "500m", "128Mi"),
+		newPod("team-a", "worker", v1.PodPending, "100m", "32Mi"),
+		&v1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "large"},
+			Spec:       v1.ResourceQuotaSpec{Hard: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("4"), v1.ResourceMemory: resource.MustParse("8Gi")}},
+		},
+		&v1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "small"},
+			Spec:       v1.ResourceQuotaSpec{Hard: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")}},
+		},
+	)
+
+	snapshot := &portainer.KubernetesSnapshot{}
+
+	is.NoError(snapshotPods(snapshot, cli))
+	is.Equal(2, snapshot.RunningPodCount)
+	is.Equal(1, snapshot.PendingPodCount)
+	is.Equal(1, snapshot.SucceededPodCount)
+	is.Equal(0, snapshot.FailedPodCount)
+	is.Len(snapshot.SnapshotRaw.Pods, 4)
+
+	is.NoError(snapshotNamespaces(snapshot, cli))
+	is.Equal([]portainer.KubernetesNamespaceSnapshot{
+		{
+			Name:            "default",
+			PodCount:        2,
+			RequestedCPU:    0.25,
+			RequestedMemory: 64 * 1024 * 1024,
+		},
+		{
+			Name:              "team-a",
+			PodCount:          2,
+			RequestedCPU:      0.6,
+			RequestedMemory:   160 * 1024 * 1024,
+			AllocatableCPU:    2,
+			AllocatableMemory: 8 * 1024 * 1024 * 1024,
+		},
+	}, snapshot.Namespaces)
+}
+
+func Test_snapshotPersistentVolumeClaims(t *testing.T) {
+	is := assert.New(t)
+
+	cli := kfake.NewSimpleClientset(
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "data"},
+			Status: v1.PersistentVolumeClaimStatus{
+				Phase:    v1.ClaimBound,
+				Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")},
+			},
+		},
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pending"},
+			Status:     v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+		},
+	)
+
+	snapshot := &portainer.KubernetesSnapshot{}
+
+	is.NoError(snapshotPersistentVolumeClaims(snapshot, cli))
+	is.Equal(2, snapshot.PersistentVolumeClaimCount)
+	is.Equal(int64(10*1024*1024*1024), snapshot.BoundStorageCapacity)
+}
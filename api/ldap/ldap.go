@@ -1,8 +1,12 @@
 package ldap
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net"
 	"strings"
+	"sync"
+	"time"
 
 	ldap "github.com/go-ldap/ldap/v3"
 	"github.com/pkg/errors"
@@ -17,16 +21,88 @@ var (
 	errUserNotFound = errors.New("User not found or too many entries returned")
 )
 
+const (
+	// dialTimeout is the time given to a LDAP server to accept a connection before the next server is tried
+	dialTimeout = 5 * time.Second
+	// deadServerDuration is the time a LDAP server which refused a connection is tried after the other servers
+	deadServerDuration = time.Minute
+	// maxNestedGroupDepth is the number of levels of groups of groups retrieved for a user
+	maxNestedGroupDepth = 10
+	// matchingRuleInChain is the Active Directory LDAP_MATCHING_RULE_IN_CHAIN rule, which walks the chain of ancestry
+	matchingRuleInChain = "1.2.840.113556.1.4.1941"
+)
+
 // Service represents a service used to authenticate users against a LDAP/AD.
-type Service struct{}
+type Service struct {
+	mu          sync.Mutex
+	deadServers map[string]time.Time
+}
 
-func createConnection(settings *portainer.LDAPSettings) (*ldap.Conn, error) {
-	conn, err := createConnectionForURL(settings.URL, settings)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed creating LDAP connection")
+// createConnection connects to the first LDAP server accepting a connection. The servers which recently refused
+// a connection are tried last, so that a server which is down does not delay every request.
+func (service *Service) createConnection(settings *portainer.LDAPSettings) (*ldap.Conn, error) {
+	var lastErr error
+
+	for _, url := range service.orderServers(settingsURLs(settings)) {
+		conn, err := createConnectionForURL(url, settings)
+		if err == nil {
+			service.markServer(url, true)
+			return conn, nil
+		}
+
+		service.markServer(url, false)
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no LDAP server URL")
+	}
+
+	return nil, errors.Wrap(lastErr, "failed creating LDAP connection")
+}
+
+func settingsURLs(settings *portainer.LDAPSettings) []string {
+	if len(settings.URLs) > 0 {
+		return settings.URLs
+	}
+
+	return []string{settings.URL}
+}
+
+// orderServers moves the servers which recently refused a connection after the other servers, keeping their order
+func (service *Service) orderServers(urls []string) []string {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	alive := make([]string, 0, len(urls))
+	dead := []string{}
+
+	for _, url := range urls {
+		if deadSince, ok := service.deadServers[url]; ok && time.Since(deadSince) < deadServerDuration {
+			dead = append(dead, url)
+			continue
+		}
+
+		alive = append(alive, url)
+	}
+
+	return append(alive, dead...)
+}
+
+func (service *Service) markServer(url string, alive bool) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if alive {
+		delete(service.deadServers, url)
+		return
 	}
 
-	return conn, nil
+	if service.deadServers == nil {
+		service.deadServers = make(map[string]time.Time)
+	}
+
+	service.deadServers[url] = time.Now()
 }
 
 func createConnectionForURL(url string, settings *portainer.LDAPSettings) (*ldap.Conn, error) {
@@ -38,29 +114,51 @@ func createConnectionForURL(url string, settings *portainer.LDAPSettings) (*ldap
 		config.ServerName = strings.Split(url, ":")[0]
 
 		if settings.TLSConfig.TLS {
-			return ldap.DialTLS("tcp", url, config)
+			c, err := tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", url, config)
+			if err != nil {
+				return nil, err
+			}
+
+			return startConnection(c, true), nil
 		}
 
-		conn, err := ldap.Dial("tcp", url)
+		conn, err := dial(url)
 		if err != nil {
 			return nil, err
 		}
 
 		err = conn.StartTLS(config)
 		if err != nil {
+			conn.Close()
 			return nil, err
 		}
 
 		return conn, nil
 	}
 
-	return ldap.Dial("tcp", url)
+	return dial(url)
+}
+
+func dial(url string) (*ldap.Conn, error) {
+	c, err := net.DialTimeout("tcp", url, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return startConnection(c, false), nil
+}
+
+func startConnection(c net.Conn, isTLS bool) *ldap.Conn {
+	conn := ldap.NewConn(c, isTLS)
+	conn.Start()
+
+	return conn
 }
 
 // AuthenticateUser is used to authenticate a user against a LDAP/AD.
-func (*Service) AuthenticateUser(username, password string, settings *portainer.LDAPSettings) error {
+func (service *Service) AuthenticateUser(username, password string, settings *portainer.LDAPSettings) error {
 
-	connection, err := createConnection(settings)
+	connection, err := service.createConnection(settings)
 	if err != nil {
 		return err
 	}
@@ -87,8 +185,8 @@ func (*Service) AuthenticateUser(username, password string, settings *portainer.
 }
 
 // GetUserGroups is used to retrieve user groups from LDAP/AD.
-func (*Service) GetUserGroups(username string, settings *portainer.LDAPSettings) ([]string, error) {
-	connection, err := createConnection(settings)
+func (service *Service) GetUserGroups(username string, settings *portainer.LDAPSettings) ([]string, error) {
+	connection, err := service.createConnection(settings)
 	if err != nil {
 		return nil, err
 	}
@@ -112,8 +210,8 @@ func (*Service) GetUserGroups(username string, settings *portainer.LDAPSettings)
 }
 
 // SearchUsers searches for users with the specified settings
-func (*Service) SearchUsers(settings *portainer.LDAPSettings) ([]string, error) {
-	connection, err := createConnection(settings)
+func (service *Service) SearchUsers(settings *portainer.LDAPSettings) ([]portainer.LDAPUser, error) {
+	connection, err := service.createConnection(settings)
 	if err != nil {
 		return nil, err
 	}
@@ -126,7 +224,7 @@ func (*Service) SearchUsers(settings *portainer.LDAPSettings) ([]string, error)
 		}
 	}
 
-	users := map[string]bool{}
+	users := map[string]string{}
 
 	for _, searchSettings := range settings.SearchSettings {
 		searchRequest := ldap.NewSearchRequest(
@@ -145,24 +243,24 @@ func (*Service) SearchUsers(settings *portainer.LDAPSettings) ([]string, error)
 		for _, user := range sr.Entries {
 			username := user.GetAttributeValue(searchSettings.UserNameAttribute)
 			if username != "" {
-				users[username] = true
+				users[username] = user.DN
 			}
 		}
 	}
 
-	usersList := []string{}
-	for user := range users {
-		usersList = append(usersList, user)
+	usersList := []portainer.LDAPUser{}
+	for username, dn := range users {
+		usersList = append(usersList, portainer.LDAPUser{Name: username, DN: dn})
 	}
 
 	return usersList, nil
 }
 
 // SearchGroups searches for groups with the specified settings
-func (*Service) SearchGroups(settings *portainer.LDAPSettings) ([]portainer.LDAPUser, error) {
+func (service *Service) SearchGroups(settings *portainer.LDAPSettings) ([]portainer.LDAPUser, error) {
 	type groupSet map[string]bool
 
-	connection, err := createConnection(settings)
+	connection, err := service.createConnection(settings)
 	if err != nil {
 		return nil, err
 	}
@@ -191,14 +289,34 @@ func (*Service) SearchGroups(settings *portainer.LDAPSettings) ([]portainer.LDAP
 			return nil, err
 		}
 
+		memberGroups := map[string][]*ldap.Entry{}
 		for _, entry := range sr.Entries {
-			members := entry.GetAttributeValues(searchSettings.GroupAttribute)
-			for _, username := range members {
-				_, ok := userGroups[username]
-				if !ok {
-					userGroups[username] = groupSet{}
-				}
-				userGroups[username][entry.GetAttributeValue("cn")] = true
+			for _, member := range entry.GetAttributeValues(searchSettings.GroupAttribute) {
+				memberGroups[member] = append(memberGroups[member], entry)
+			}
+		}
+
+		// the groups nested in a group are members of the group, the groups of a group are found by its DN
+		groupsByDN := map[string][]*ldap.Entry{}
+		for member, groups := range memberGroups {
+			key := strings.ToLower(member)
+			groupsByDN[key] = append(groupsByDN[key], groups...)
+		}
+
+		for username, groups := range memberGroups {
+			if searchSettings.NestedGroups {
+				groups = expandNestedGroups(groups, func(groupDN string) []*ldap.Entry {
+					return groupsByDN[strings.ToLower(groupDN)]
+				})
+			}
+
+			_, ok := userGroups[username]
+			if !ok {
+				userGroups[username] = groupSet{}
+			}
+
+			for _, group := range groups {
+				userGroups[username][group.GetAttributeValue("cn")] = true
 			}
 		}
 	}
@@ -258,25 +376,32 @@ func searchUser(username string, conn *ldap.Conn, settings []portainer.LDAPSearc
 // Get a list of group names for specified user from LDAP/AD
 func getGroupsByUser(userDN string, conn *ldap.Conn, settings []portainer.LDAPGroupSearchSettings) []string {
 	groups := make([]string, 0)
-	userDNEscaped := ldap.EscapeFilter(userDN)
 
 	for _, searchSettings := range settings {
-		searchRequest := ldap.NewSearchRequest(
-			searchSettings.GroupBaseDN,
-			ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-			fmt.Sprintf("(&%s(%s=%s))", searchSettings.GroupFilter, searchSettings.GroupAttribute, userDNEscaped),
-			[]string{"cn"},
-			nil,
-		)
+		attribute := searchSettings.GroupAttribute
+		if searchSettings.NestedGroups && searchSettings.MatchingRuleInChain {
+			attribute += ":" + matchingRuleInChain + ":"
+		}
 
 		// Deliberately skip errors on the search request so that we can jump to other search settings
 		// if any issue arise with the current one.
-		sr, err := conn.Search(searchRequest)
+		entries, err := searchGroupsByMember(userDN, attribute, conn, searchSettings)
 		if err != nil {
 			continue
 		}
 
-		for _, entry := range sr.Entries {
+		if searchSettings.NestedGroups && !searchSettings.MatchingRuleInChain {
+			entries = expandNestedGroups(entries, func(groupDN string) []*ldap.Entry {
+				parents, err := searchGroupsByMember(groupDN, searchSettings.GroupAttribute, conn, searchSettings)
+				if err != nil {
+					return nil
+				}
+
+				return parents
+			})
+		}
+
+		for _, entry := range entries {
 			for _, attr := range entry.Attributes {
 				groups = append(groups, attr.Values[0])
 			}
@@ -286,11 +411,58 @@ func getGroupsByUser(userDN string, conn *ldap.Conn, settings []portainer.LDAPGr
 	return groups
 }
 
+// searchGroupsByMember returns the groups with the member in their attribute
+func searchGroupsByMember(memberDN, attribute string, conn *ldap.Conn, settings portainer.LDAPGroupSearchSettings) ([]*ldap.Entry, error) {
+	searchRequest := ldap.NewSearchRequest(
+		settings.GroupBaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&%s(%s=%s))", settings.GroupFilter, attribute, ldap.EscapeFilter(memberDN)),
+		[]string{"cn"},
+		nil,
+	)
+
+	sr, err := conn.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return sr.Entries, nil
+}
+
+// expandNestedGroups returns the groups and the groups they are members of, up to maxNestedGroupDepth levels.
+// The groups are only visited once, so that membership cycles are not followed.
+func expandNestedGroups(groups []*ldap.Entry, parentGroups func(groupDN string) []*ldap.Entry) []*ldap.Entry {
+	visited := make(map[string]bool)
+	expanded := make([]*ldap.Entry, 0, len(groups))
+
+	level := groups
+	for depth := 0; depth <= maxNestedGroupDepth && len(level) > 0; depth++ {
+		next := []*ldap.Entry{}
+
+		for _, group := range level {
+			dn := strings.ToLower(group.DN)
+			if visited[dn] {
+				continue
+			}
+			visited[dn] = true
+
+			expanded = append(expanded, group)
+			if depth < maxNestedGroupDepth {
+				next = append(next, parentGroups(group.DN)...)
+			}
+		}
+
+		level = next
+	}
+
+	return expanded
+}
+
 // TestConnectivity is used to test a connection against the LDAP server using the credentials
 // specified in the LDAPSettings.
-func (*Service) TestConnectivity(settings *portainer.LDAPSettings) error {
+func (service *Service) TestConnectivity(settings *portainer.LDAPSettings) error {
 
-	connection, err := createConnection(settings)
+	connection, err := service.createConnection(settings)
 	if err != nil {
 		return err
 	}
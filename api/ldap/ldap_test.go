@@ -0,0 +1,197 @@
+package ldap
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/ldap/ldaptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	readerDN = "cn=reader,dc=example,dc=org"
+	aliceDN  = "uid=alice,ou=users,dc=example,dc=org"
+)
+
+func group(name string, members ...string) ldaptest.Entry {
+	return ldaptest.Entry{
+		DN:         "cn=" + name + ",ou=groups,dc=example,dc=org",
+		Attributes: map[string][]string{"cn": {name}, "objectClass": {"groupOfNames"}, "member": members},
+	}
+}
+
+func groupDN(name string) string {
+	return "cn=" + name + ",ou=groups,dc=example,dc=org"
+}
+
+func directoryEntries() []ldaptest.Entry {
+	return []ldaptest.Entry{
+		{DN: readerDN, Attributes: map[string][]string{"cn": {"reader"}, "userPassword": {"reader-password"}}},
+		{DN: aliceDN, Attributes: map[string][]string{"uid": {"alice"}, "objectClass": {"inetOrgPerson"}, "userPassword": {"alice-password"}}},
+		group("developers", aliceDN),
+		group("engineering", groupDN("developers")),
+		group("staff", groupDN("engineering")),
+		// cycle between two groups
+		group("cycle-a", groupDN("staff"), groupDN("cycle-b")),
+		group("cycle-b", groupDN("cycle-a")),
+		group("unrelated", readerDN),
+	}
+}
+
+func newSettings(urls ...string) *portainer.LDAPSettings {
+	return &portainer.LDAPSettings{
+		ReaderDN: readerDN,
+		Password: "reader-password",
+		URLs:     urls,
+		SearchSettings: []portainer.LDAPSearchSettings{
+			{BaseDN: "ou=users,dc=example,dc=org", Filter: "(objectClass=inetOrgPerson)", UserNameAttribute: "uid"},
+		},
+		GroupSearchSettings: []portainer.LDAPGroupSearchSettings{
+			{GroupBaseDN: "ou=groups,dc=example,dc=org", GroupFilter: "(objectClass=groupOfNames)", GroupAttribute: "member"},
+		},
+	}
+}
+
+// closedAddress returns an address which refuses the connections
+func closedAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := listener.Addr().String()
+	listener.Close()
+
+	return addr
+}
+
+func Test_GetUserGroups(t *testing.T) {
+	srv := ldaptest.RunServer(directoryEntries())
+	defer srv.Close()
+
+	service := &Service{}
+
+	t.Run("returns the direct groups only by default", func(t *testing.T) {
+		groups, err := service.GetUserGroups("alice", newSettings(srv.Addr))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"developers"}, groups)
+	})
+
+	nested := []string{"developers", "engineering", "staff", "cycle-a", "cycle-b"}
+
+	t.Run("returns the nested groups with one search per group", func(t *testing.T) {
+		settings := newSettings(srv.Addr)
+		settings.GroupSearchSettings[0].NestedGroups = true
+
+		groups, err := service.GetUserGroups("alice", settings)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, nested, groups)
+	})
+
+	t.Run("returns the nested groups with the matching rule in chain", func(t *testing.T) {
+		settings := newSettings(srv.Addr)
+		settings.GroupSearchSettings[0].NestedGroups = true
+		settings.GroupSearchSettings[0].MatchingRuleInChain = true
+
+		searches := srv.Searches()
+
+		groups, err := service.GetUserGroups("alice", settings)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, nested, groups)
+		assert.Equal(t, 2, srv.Searches()-searches, "the user and its groups should be found with a search each")
+	})
+}
+
+func Test_GetUserGroups_depthLimit(t *testing.T) {
+	entries := directoryEntries()
+
+	member := aliceDN
+	for i := 0; i <= maxNestedGroupDepth+1; i++ {
+		name := "level-" + string(rune('a'+i))
+		entries = append(entries, group(name, member))
+		member = groupDN(name)
+	}
+
+	srv := ldaptest.RunServer(entries)
+	defer srv.Close()
+
+	settings := newSettings(srv.Addr)
+	settings.GroupSearchSettings[0].NestedGroups = true
+
+	groups, err := (&Service{}).GetUserGroups("alice", settings)
+	require.NoError(t, err)
+
+	assert.Contains(t, groups, "level-"+string(rune('a'+maxNestedGroupDepth)))
+	assert.NotContains(t, groups, "level-"+string(rune('a'+maxNestedGroupDepth+1)))
+}
+
+func Test_SearchGroups_nestedGroups(t *testing.T) {
+	srv := ldaptest.RunServer(directoryEntries())
+	defer srv.Close()
+
+	settings := newSettings(srv.Addr)
+	settings.GroupSearchSettings[0].NestedGroups = true
+
+	users, err := (&Service{}).SearchGroups(settings)
+	require.NoError(t, err)
+
+	for _, user := range users {
+		if user.Name == aliceDN {
+			assert.ElementsMatch(t, []string{"developers", "engineering", "staff", "cycle-a", "cycle-b"}, user.Groups)
+			return
+		}
+	}
+
+	t.Fatal("the user is missing from the groups")
+}
+
+func Test_CreateConnection_failover(t *testing.T) {
+	srv := ldaptest.RunServer(directoryEntries())
+	defer srv.Close()
+
+	deadAddr := closedAddress(t)
+	service := &Service{}
+	settings := newSettings(deadAddr, srv.Addr)
+
+	err := service.AuthenticateUser("alice", "alice-password", settings)
+	require.NoError(t, err)
+	assert.Contains(t, service.deadServers, deadAddr)
+
+	assert.Equal(t, []string{srv.Addr, deadAddr}, service.orderServers(settings.URLs), "a dead server should be tried last")
+
+	service.deadServers[deadAddr] = time.Now().Add(-deadServerDuration)
+	assert.Equal(t, []string{deadAddr, srv.Addr}, service.orderServers(settings.URLs), "a server should be tried in order once its dead time is over")
+
+	t.Run("tries the dead servers when the other servers fail", func(t *testing.T) {
+		service.deadServers[srv.Addr] = time.Now()
+
+		err := service.TestConnectivity(newSettings(srv.Addr))
+		require.NoError(t, err)
+		assert.NotContains(t, service.deadServers, srv.Addr, "a server accepting a connection should no longer be dead")
+	})
+
+	t.Run("fails when no server accepts the connection", func(t *testing.T) {
+		err := service.TestConnectivity(newSettings(deadAddr, closedAddress(t)))
+		assert.Error(t, err)
+	})
+
+	t.Run("uses the URL when there are no URLs", func(t *testing.T) {
+		settings := newSettings()
+		settings.URL = srv.Addr
+
+		assert.NoError(t, service.TestConnectivity(settings))
+	})
+}
+
+func Test_AuthenticateUser(t *testing.T) {
+	srv := ldaptest.RunServer(directoryEntries())
+	defer srv.Close()
+
+	service := &Service{}
+
+	assert.NoError(t, service.AuthenticateUser("alice", "alice-password", newSettings(srv.Addr)))
+	assert.Error(t, service.AuthenticateUser("alice", "wrong-password", newSettings(srv.Addr)))
+	assert.Error(t, service.AuthenticateUser("bob", "alice-password", newSettings(srv.Addr)))
+}
@@ -0,0 +1,354 @@
+package ldaptest
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+const (
+	applicationBindRequest       = 0
+	applicationBindResponse      = 1
+	applicationUnbindRequest     = 2
+	applicationSearchRequest     = 3
+	applicationSearchResultEntry = 4
+	applicationSearchResultDone  = 5
+
+	filterAnd             = 0
+	filterOr              = 1
+	filterNot             = 2
+	filterEqualityMatch   = 3
+	filterPresent         = 7
+	filterExtensibleMatch = 9
+
+	resultSuccess            = 0
+	resultProtocolError      = 2
+	resultInvalidCredentials = 49
+	resultUnwillingToPerform = 53
+
+	matchingRuleInChain = "1.2.840.113556.1.4.1941"
+	passwordAttribute   = "userPassword"
+)
+
+// Entry is an entry of the directory of the server
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Server is a barebones in-process LDAP server which can be used to test the LDAP service. It supports simple binds
+// with the userPassword attribute of the entries, and subtree searches with and, or, not, equality, presence
+// and the LDAP_MATCHING_RULE_IN_CHAIN extensible match filters.
+type Server struct {
+	// Addr is the host:port address of the server
+	Addr string
+
+	listener    net.Listener
+	entries     []Entry
+	searches    int64
+	wg          sync.WaitGroup
+	mu          sync.Mutex
+	connections map[net.Conn]bool
+}
+
+// RunServer starts a LDAP server serving the entries
+func RunServer(entries []Entry) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	s := &Server{
+		Addr:        listener.Addr().String(),
+		listener:    listener,
+		entries:     entries,
+		connections: make(map[net.Conn]bool),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// Close stops the server and closes its connections, the address of the server refuses the connections afterwards
+func (s *Server) Close() {
+	s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.connections {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Searches returns the number of search requests received by the server
+func (s *Server) Searches() int {
+	return int(atomic.LoadInt64(&s.searches))
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.connections[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+
+		s.mu.Lock()
+		delete(s.connections, conn)
+		s.mu.Unlock()
+	}()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		messageID, _ := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		var responses []*ber.Packet
+		switch request.Tag {
+		case applicationBindRequest:
+			responses = []*ber.Packet{s.bind(request)}
+		case applicationSearchRequest:
+			atomic.AddInt64(&s.searches, 1)
+			responses = s.search(request)
+		case applicationUnbindRequest:
+			return
+		default:
+			// the responses of the other operations are tagged with the tag following the tag of their request
+			responses = []*ber.Packet{result(request.Tag+1, resultUnwillingToPerform, "operation not supported")}
+		}
+
+		for _, response := range responses {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+			envelope.AppendChild(response)
+
+			_, err := conn.Write(envelope.Bytes())
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) bind(request *ber.Packet) *ber.Packet {
+	if len(request.Children) < 3 {
+		return result(applicationBindResponse, resultProtocolError, "invalid bind request")
+	}
+
+	dn := request.Children[1].Data.String()
+	password := request.Children[2].Data.String()
+
+	if dn == "" && password == "" {
+		return result(applicationBindResponse, resultSuccess, "")
+	}
+
+	entry := s.entry(dn)
+	if entry == nil || password == "" || !contains(entry.Attributes[passwordAttribute], password) {
+		return result(applicationBindResponse, resultInvalidCredentials, "invalid credentials")
+	}
+
+	return result(applicationBindResponse, resultSuccess, "")
+}
+
+func (s *Server) search(request *ber.Packet) []*ber.Packet {
+	if len(request.Children) < 8 {
+		return []*ber.Packet{result(applicationSearchResultDone, resultProtocolError, "invalid search request")}
+	}
+
+	baseDN := strings.ToLower(request.Children[0].Data.String())
+	filter := request.Children[6]
+
+	attributes := []string{}
+	for _, attribute := range request.Children[7].Children {
+		attributes = append(attributes, attribute.Data.String())
+	}
+
+	responses := []*ber.Packet{}
+	for i := range s.entries {
+		entry := &s.entries[i]
+
+		dn := strings.ToLower(entry.DN)
+		if baseDN != "" && dn != baseDN && !strings.HasSuffix(dn, ","+baseDN) {
+			continue
+		}
+
+		if !s.match(entry, filter) {
+			continue
+		}
+
+		responses = append(responses, searchResultEntry(entry, attributes))
+	}
+
+	return append(responses, result(applicationSearchResultDone, resultSuccess, ""))
+}
+
+func (s *Server) match(entry *Entry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case filterAnd:
+		for _, child := range filter.Children {
+			if !s.match(entry, child) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, child := range filter.Children {
+			if s.match(entry, child) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return len(filter.Children) == 1 && !s.match(entry, filter.Children[0])
+	case filterEqualityMatch:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		return containsFold(attributeValues(entry, filter.Children[0].Data.String()), filter.Children[1].Data.String())
+	case filterPresent:
+		return len(attributeValues(entry, filter.Data.String())) > 0
+	case filterExtensibleMatch:
+		var rule, attribute, value string
+		for _, child := range filter.Children {
+			switch child.Tag {
+			case 1:
+				rule = child.Data.String()
+			case 2:
+				attribute = child.Data.String()
+			case 3:
+				value = child.Data.String()
+			}
+		}
+
+		if rule != matchingRuleInChain {
+			return false
+		}
+
+		return s.inChain(entry, attribute, value, map[string]bool{})
+	}
+
+	return false
+}
+
+// inChain reports whether the value is in the attribute of the entry or in the attribute of the entries it references
+func (s *Server) inChain(entry *Entry, attribute, value string, visited map[string]bool) bool {
+	dn := strings.ToLower(entry.DN)
+	if visited[dn] {
+		return false
+	}
+	visited[dn] = true
+
+	for _, member := range attributeValues(entry, attribute) {
+		if strings.EqualFold(member, value) {
+			return true
+		}
+
+		nested := s.entry(member)
+		if nested != nil && s.inChain(nested, attribute, value, visited) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) entry(dn string) *Entry {
+	for i := range s.entries {
+		if strings.EqualFold(s.entries[i].DN, dn) {
+			return &s.entries[i]
+		}
+	}
+
+	return nil
+}
+
+func attributeValues(entry *Entry, name string) []string {
+	for attribute, values := range entry.Attributes {
+		if strings.EqualFold(attribute, name) {
+			return values
+		}
+	}
+
+	return nil
+}
+
+func searchResultEntry(entry *Entry, attributes []string) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, applicationSearchResultEntry, nil, "Search Result Entry")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "DN"))
+
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range entry.Attributes {
+		if name == passwordAttribute || (len(attributes) > 0 && !containsFold(attributes, name)) {
+			continue
+		}
+
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+
+		list.AppendChild(attribute)
+	}
+	packet.AppendChild(list)
+
+	return packet
+}
+
+func result(application ber.Tag, code int64, message string) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, application, nil, "Result")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "Diagnostic Message"))
+
+	return packet
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,276 @@
+package ldap
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/apikey"
+	"github.com/portainer/portainer/api/dataservices"
+	"github.com/portainer/portainer/api/scheduler"
+	"github.com/rs/zerolog/log"
+)
+
+// groupSyncCheckInterval is the delay between two checks of the synchronisation interval of the settings
+const groupSyncCheckInterval = time.Minute
+
+// GroupSyncSummary represents the changes made by a synchronisation of the team memberships with the LDAP groups
+type GroupSyncSummary struct {
+	// Number of users found in the directory
+	DirectoryUsers int
+	// Number of memberships added to the teams named after the LDAP groups
+	MembershipsAdded int
+	// Number of memberships removed from the teams named after the LDAP groups
+	MembershipsRemoved int
+	// Number of users disabled because they are missing from the directory
+	UsersDisabled int
+	// Number of disabled users enabled again because they are back in the directory
+	UsersEnabled int
+}
+
+// GroupSyncService periodically reconciles the team memberships of the LDAP users with their LDAP groups
+type GroupSyncService struct {
+	dataStore     dataservices.DataStore
+	ldapService   portainer.LDAPService
+	apiKeyService apikey.APIKeyService
+	mu            sync.Mutex
+	lastSync      time.Time
+}
+
+// NewGroupSyncService returns a new LDAP group synchronisation service
+func NewGroupSyncService(dataStore dataservices.DataStore, ldapService portainer.LDAPService, apiKeyService apikey.APIKeyService) *GroupSyncService {
+	return &GroupSyncService{
+		dataStore:     dataStore,
+		ldapService:   ldapService,
+		apiKeyService: apiKeyService,
+	}
+}
+
+// Start schedules the synchronisation, it runs at the interval of the LDAP settings
+func (service *GroupSyncService) Start(scheduler *scheduler.Scheduler) {
+	scheduler.StartJobEvery(groupSyncCheckInterval, func() error {
+		service.syncIfDue(time.Now())
+		return nil
+	})
+}
+
+func (service *GroupSyncService) syncIfDue(now time.Time) {
+	settings, err := service.dataStore.Settings().Settings()
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to retrieve the settings")
+		return
+	}
+
+	if settings.AuthenticationMethod != portainer.AuthenticationLDAP || settings.LDAPSettings.GroupSync.Interval == "" {
+		return
+	}
+
+	interval, err := time.ParseDuration(settings.LDAPSettings.GroupSync.Interval)
+	if err != nil || interval <= 0 {
+		return
+	}
+
+	if !service.mu.TryLock() {
+		return
+	}
+	defer service.mu.Unlock()
+
+	if now.Sub(service.lastSync) < interval {
+		return
+	}
+	service.lastSync = now
+
+	summary, err := service.sync(settings)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to synchronize the team memberships with the LDAP groups")
+		return
+	}
+
+	log.Info().
+		Int("directory_users", summary.DirectoryUsers).
+		Int("memberships_added", summary.MembershipsAdded).
+		Int("memberships_removed", summary.MembershipsRemoved).
+		Int("users_disabled", summary.UsersDisabled).
+		Int("users_enabled", summary.UsersEnabled).
+		Msg("LDAP group synchronisation completed")
+}
+
+// Sync reconciles the team memberships of the LDAP users with their LDAP groups. Only the memberships of the teams
+// named after a LDAP group are managed. The users missing from the directory are disabled when the settings
+// ask for it, the initial administrator is never changed since it always authenticates with its password.
+func (service *GroupSyncService) Sync() (*GroupSyncSummary, error) {
+	settings, err := service.dataStore.Settings().Settings()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the settings")
+	}
+
+	if settings.AuthenticationMethod != portainer.AuthenticationLDAP {
+		return nil, errors.New("LDAP authentication is not enabled")
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	return service.sync(settings)
+}
+
+func (service *GroupSyncService) sync(settings *portainer.Settings) (*GroupSyncSummary, error) {
+	directoryUsers, err := service.ldapService.SearchUsers(&settings.LDAPSettings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search the LDAP users")
+	}
+
+	directoryGroups, err := service.ldapService.SearchGroups(&settings.LDAPSettings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search the LDAP groups")
+	}
+
+	// the members of the groups are either the DN or the name of the users, depending on the group attribute
+	groupsByMember := make(map[string][]string)
+	groupNames := make(map[string]bool)
+	for _, member := range directoryGroups {
+		key := strings.ToLower(member.Name)
+		groupsByMember[key] = append(groupsByMember[key], member.Groups...)
+
+		for _, group := range member.Groups {
+			groupNames[strings.ToLower(group)] = true
+		}
+	}
+
+	usersByName := make(map[string]portainer.LDAPUser)
+	for _, user := range directoryUsers {
+		usersByName[strings.ToLower(user.Name)] = user
+	}
+
+	teams, err := service.dataStore.Team().Teams()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the teams")
+	}
+
+	teamsByName := make(map[string]portainer.TeamID)
+	managedTeams := make(map[portainer.TeamID]bool)
+	for _, team := range teams {
+		name := strings.ToLower(team.Name)
+		teamsByName[name] = team.ID
+		managedTeams[team.ID] = groupNames[name]
+	}
+
+	users, err := service.dataStore.User().Users()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the users")
+	}
+
+	summary := &GroupSyncSummary{DirectoryUsers: len(directoryUsers)}
+
+	for i := range users {
+		user := &users[i]
+		if user.ID == 1 {
+			continue
+		}
+
+		directoryUser, found := usersByName[strings.ToLower(user.Username)]
+		if !found {
+			// an empty search result is more likely a misconfiguration than an empty directory
+			if settings.LDAPSettings.GroupSync.DisableMissingUsers && !user.Disabled && len(directoryUsers) > 0 {
+				err := service.setDisabled(user, true)
+				if err != nil {
+					return summary, err
+				}
+				summary.UsersDisabled++
+			}
+
+			continue
+		}
+
+		// the users disabled by another source, such as SCIM, are not enabled again by the directory
+		if user.Disabled && user.DisabledBy == portainer.UserDisabledByLDAP {
+			err := service.setDisabled(user, false)
+			if err != nil {
+				return summary, err
+			}
+			summary.UsersEnabled++
+		}
+
+		teamIDs := make(map[portainer.TeamID]bool)
+		for _, key := range []string{strings.ToLower(directoryUser.DN), strings.ToLower(directoryUser.Name)} {
+			for _, group := range groupsByMember[key] {
+				if teamID, ok := teamsByName[strings.ToLower(group)]; ok {
+					teamIDs[teamID] = true
+				}
+			}
+		}
+
+		added, removed, err := service.reconcileMemberships(user.ID, teamIDs, managedTeams)
+		summary.MembershipsAdded += added
+		summary.MembershipsRemoved += removed
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	return summary, nil
+}
+
+// reconcileMemberships adds the user to the given teams and removes it from the other managed teams
+func (service *GroupSyncService) reconcileMemberships(userID portainer.UserID, teamIDs, managedTeams map[portainer.TeamID]bool) (added int, removed int, err error) {
+	memberships, err := service.dataStore.TeamMembership().TeamMembershipsByUserID(userID)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to retrieve the team memberships of the user %d", userID)
+	}
+
+	for _, membership := range memberships {
+		if teamIDs[membership.TeamID] {
+			delete(teamIDs, membership.TeamID)
+			continue
+		}
+
+		if !managedTeams[membership.TeamID] {
+			continue
+		}
+
+		err := service.dataStore.TeamMembership().DeleteTeamMembership(membership.ID)
+		if err != nil {
+			return added, removed, errors.Wrapf(err, "failed to remove the user %d from the team %d", userID, membership.TeamID)
+		}
+		removed++
+	}
+
+	for teamID := range teamIDs {
+		membership := &portainer.TeamMembership{
+			UserID: userID,
+			TeamID: teamID,
+			Role:   portainer.TeamMember,
+		}
+
+		err := service.dataStore.TeamMembership().Create(membership)
+		if err != nil {
+			return added, removed, errors.Wrapf(err, "failed to add the user %d to the team %d", userID, teamID)
+		}
+		added++
+	}
+
+	return added, removed, nil
+}
+
+// setDisabled disables or enables a user, the sessions and the cached API keys of a disabled user are revoked
+func (service *GroupSyncService) setDisabled(user *portainer.User, disabled bool) error {
+	user.Disabled = disabled
+	user.DisabledBy = ""
+	if disabled {
+		user.DisabledBy = portainer.UserDisabledByLDAP
+		user.TokenIssueAt = time.Now().Unix()
+	}
+
+	err := service.dataStore.User().UpdateUser(user.ID, user)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update the user %d", user.ID)
+	}
+
+	if service.apiKeyService != nil {
+		service.apiKeyService.InvalidateUserKeyCache(user.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package ldap
+
+import (
+	"testing"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/datastore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type directoryMock struct {
+	portainer.LDAPService
+	users  []portainer.LDAPUser
+	groups []portainer.LDAPUser
+}
+
+func (directory *directoryMock) SearchUsers(settings *portainer.LDAPSettings) ([]portainer.LDAPUser, error) {
+	return directory.users, nil
+}
+
+func (directory *directoryMock) SearchGroups(settings *portainer.LDAPSettings) ([]portainer.LDAPUser, error) {
+	return directory.groups, nil
+}
+
+func Test_GroupSyncService_Sync(t *testing.T) {
+	is := assert.New(t)
+
+	_, store, teardown := datastore.MustNewTestStore(t, true, true)
+	defer teardown()
+
+	settings, err := store.Settings().Settings()
+	require.NoError(t, err)
+	settings.AuthenticationMethod = portainer.AuthenticationLDAP
+	settings.LDAPSettings.GroupSync.DisableMissingUsers = true
+	require.NoError(t, store.Settings().UpdateSettings(settings))
+
+	users := []*portainer.User{
+		{ID: 1, Username: "admin", Role: portainer.AdministratorRole},
+		{ID: 2, Username: "alice", Role: portainer.StandardUserRole},
+		{ID: 3, Username: "bob", Role: portainer.StandardUserRole},
+		{ID: 4, Username: "carol", Role: portainer.StandardUserRole, Disabled: true, DisabledBy: portainer.UserDisabledByLDAP},
+		{ID: 5, Username: "dave", Role: portainer.StandardUserRole, Disabled: true, DisabledBy: portainer.UserDisabledBySCIM},
+	}
+	for _, user := range users {
+		require.NoError(t, store.User().Create(user))
+	}
+
+	developers := &portainer.Team{Name: "developers"}
+	operators := &portainer.Team{Name: "operators"}
+	local := &portainer.Team{Name: "local"}
+	for _, team := range []*portainer.Team{developers, operators, local} {
+		require.NoError(t, store.Team().Create(team))
+	}
+
+	memberships := []*portainer.TeamMembership{
+		{UserID: 2, TeamID: operators.ID, Role: portainer.TeamMember},
+		{UserID: 2, TeamID: local.ID, Role: portainer.TeamMember},
+		{UserID: 3, TeamID: developers.ID, Role: portainer.TeamMember},
+	}
+	for _, membership := range memberships {
+		require.NoError(t, store.TeamMembership().Create(membership))
+	}
+
+	directory := &directoryMock{
+		users: []portainer.LDAPUser{
+			{Name: "Alice", DN: "cn=alice,dc=example,dc=org"},
+			{Name: "carol", DN: "cn=carol,dc=example,dc=org"},
+			{Name: "dave", DN: "cn=dave,dc=example,dc=org"},
+		},
+		groups: []portainer.LDAPUser{
+			{Name: "cn=alice,dc=example,dc=org", Groups: []string{"Developers"}},
+			{Name: "carol", Groups: []string{"operators", "unmapped"}},
+			{Name: "cn=bob,dc=example,dc=org", Groups: []string{"operators"}},
+		},
+	}
+
+	service := NewGroupSyncService(store, directory, nil)
+
+	summary, err := service.Sync()
+	require.NoError(t, err)
+	is.Equal(&GroupSyncSummary{DirectoryUsers: 3, MembershipsAdded: 2, MembershipsRemoved: 1, UsersDisabled: 1, UsersEnabled: 1}, summary)
+
+	teamsOf := func(userID portainer.UserID) []portainer.TeamID {
+		memberships, err := store.TeamMembership().TeamMembershipsByUserID(userID)
+		require.NoError(t, err)
+
+		teamIDs := []portainer.TeamID{}
+		for _, membership := range memberships {
+			teamIDs = append(teamIDs, membership.TeamID)
+		}
+
+		return teamIDs
+	}
+
+	is.ElementsMatch([]portainer.TeamID{developers.ID, local.ID}, teamsOf(2), "the memberships of the teams which are not LDAP groups should be kept")
+	is.ElementsMatch([]portainer.TeamID{operators.ID}, teamsOf(4))
+
+	bob, err := store.User().User(3)
+	require.NoError(t, err)
+	is.True(bob.Disabled)
+	is.Equal(portainer.UserDisabledByLDAP, bob.DisabledBy)
+	is.NotZero(bob.TokenIssueAt)
+	is.ElementsMatch([]portainer.TeamID{developers.ID}, teamsOf(3), "the memberships of a missing user should not be changed")
+
+	carol, err := store.User().User(4)
+	require.NoError(t, err)
+	is.False(carol.Disabled)
+	is.Empty(carol.DisabledBy)
+
+	dave, err := store.User().User(5)
+	require.NoError(t, err)
+	is.True(dave.Disabled, "a user deactivated through SCIM should not be enabled again by the directory")
+	is.Equal(portainer.UserDisabledBySCIM, dave.DisabledBy)
+
+	admin, err := store.User().User(1)
+	require.NoError(t, err)
+	is.False(admin.Disabled, "the initial administrator should never be disabled")
+
+	summary, err = service.Sync()
+	require.NoError(t, err)
+	is.Equal(&GroupSyncSummary{DirectoryUsers: 3}, summary, "a second synchronisation should not change anything")
+
+	t.Run("does not disable users when the directory returns no users", func(t *testing.T) {
+		service := NewGroupSyncService(store, &directoryMock{}, nil)
+
+		summary, err := service.Sync()
+		require.NoError(t, err)
+		is.Zero(summary.UsersDisabled)
+	})
+}
@@ -0,0 +1,203 @@
+package oauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// minKeyRefreshInterval limits the refreshes of the keys triggered by ID tokens signed with an unknown key
+const minKeyRefreshInterval = time.Minute
+
+var errUnknownKey = errors.New("the ID token is signed with an unknown key")
+
+// jsonWebKey is a public key of a JSON Web Key Set, as defined by RFC 7517
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keySet caches the signing keys of an issuer. The keys are fetched again once they expire, or when
+// a token is signed with an unknown key, which happens after a key rotation.
+type keySet struct {
+	uri       string
+	client    *http.Client
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+func newKeySet(uri string, client *http.Client) *keySet {
+	return &keySet{
+		uri:    uri,
+		client: client,
+		keys:   make(map[string]interface{}),
+	}
+}
+
+// key returns the public key with the given identifier. The identifier can be empty when the set has a single key.
+func (set *keySet) key(kid string) (interface{}, error) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	key, found := set.lookup(kid)
+	if found && time.Since(set.fetchedAt) < providerCacheDuration {
+		return key, nil
+	}
+
+	if !found && !set.fetchedAt.IsZero() && time.Since(set.fetchedAt) < minKeyRefreshInterval {
+		return nil, errUnknownKey
+	}
+
+	err := set.refresh()
+	if err != nil {
+		if found {
+			log.Warn().Err(err).Str("uri", set.uri).Msg("unable to refresh the OIDC keys, using the cached keys")
+
+			return key, nil
+		}
+
+		return nil, err
+	}
+
+	key, found = set.lookup(kid)
+	if !found {
+		return nil, errUnknownKey
+	}
+
+	return key, nil
+}
+
+func (set *keySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(set.keys) == 1 {
+		for _, key := range set.keys {
+			return key, true
+		}
+	}
+
+	key, ok := set.keys[kid]
+	return key, ok
+}
+
+func (set *keySet) refresh() error {
+	resp, err := set.client.Get(set.uri)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch the JWKS")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to fetch the JWKS, unexpected status code %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&jwks)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode the JWKS")
+	}
+
+	keys := make(map[string]interface{})
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Debug().Err(err).Str("kid", jwk.Kid).Msg("ignoring an invalid OIDC key")
+
+			continue
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	set.keys = keys
+	set.fetchedAt = time.Now()
+
+	return nil
+}
+
+func (jwk *jsonWebKey) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+		}
+
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("the EC point is not on the curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -8,32 +8,77 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	portainer "github.com/portainer/portainer/api"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/securecookie"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/oauth2"
 )
 
 // Service represents a service used to authenticate users against an authorization server
-type Service struct{}
+type Service struct {
+	client    *http.Client
+	mu        sync.Mutex
+	logins    *securecookie.SecureCookie
+	providers map[string]*provider
+}
 
 // NewService returns a pointer to a new instance of this service
 func NewService() *Service {
-	return &Service{}
+	logins := securecookie.New(securecookie.GenerateRandomKey(32), securecookie.GenerateRandomKey(32))
+	logins.MaxAge(int(loginExpiry.Seconds()))
+
+	return &Service{
+		client:    &http.Client{Timeout: 10 * time.Second},
+		logins:    logins,
+		providers: make(map[string]*provider),
+	}
 }
 
 // Authenticate takes an access code and exchanges it for an access token from portainer OAuthSettings token environment(endpoint).
-// On success, it will then return the username and token expiry time associated to authenticated user by fetching this information
-// from the resource server and matching it with the user identifier setting.
-func (*Service) Authenticate(code string, configuration *portainer.OAuthSettings) (string, error) {
+// On success, it will then return the username and the groups associated to authenticated user by fetching this information
+// from the resource server and matching it with the user identifier and group claim settings.
+// OpenID Connect providers use the state and the sealed login returned by LoginURL.
+func (service *Service) Authenticate(code, state, login string, configuration *portainer.OAuthSettings) (*portainer.OAuthInfo, error) {
+	var resource map[string]interface{}
+	var err error
+
+	if configuration.OIDC {
+		resource, err = service.authenticateOIDC(code, state, login, configuration)
+	} else {
+		resource, err = authenticateOAuth(code, configuration)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := getUsername(resource, configuration)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed retrieving username")
+
+		return nil, err
+	}
+
+	return &portainer.OAuthInfo{
+		Username: username,
+		Groups:   getGroups(resource, configuration.TeamMemberships.GroupClaimName),
+	}, nil
+}
+
+// authenticateOAuth exchanges the access code for an access token and returns the claims of the ID token merged
+// with the user resource
+func authenticateOAuth(code string, configuration *portainer.OAuthSettings) (map[string]interface{}, error) {
 	token, err := getOAuthToken(code, configuration)
 	if err != nil {
 		log.Debug().Err(err).Msg("failed retrieving oauth token")
 
-		return "", err
+		return nil, err
 	}
 
 	idToken, err := getIdToken(token)
@@ -45,19 +90,10 @@ func (*Service) Authenticate(code string, configuration *portainer.OAuthSettings
 	if err != nil {
 		log.Debug().Err(err).Msg("failed retrieving resource")
 
-		return "", err
-	}
-
-	resource = mergeSecondIntoFirst(idToken, resource)
-
-	username, err := getUsername(resource, configuration)
-	if err != nil {
-		log.Debug().Err(err).Msg("failed retrieving username")
-
-		return "", err
+		return nil, err
 	}
 
-	return username, nil
+	return mergeSecondIntoFirst(idToken, resource), nil
 }
 
 // mergeSecondIntoFirst merges the overlap map into the base overwriting any existing values.
@@ -22,3 +22,28 @@ func getUsername(datamap map[string]interface{}, configuration *portainer.OAuthS
 
 	return "", errors.New("failed to extract username from oauth resource")
 }
+
+// getGroups returns the groups of a claim holding a group or a list of groups
+func getGroups(datamap map[string]interface{}, claimName string) []string {
+	if claimName == "" {
+		return nil
+	}
+
+	switch claim := datamap[claimName].(type) {
+	case string:
+		if claim != "" {
+			return []string{claim}
+		}
+	case []interface{}:
+		groups := make([]string, 0, len(claim))
+		for _, group := range claim {
+			if group, ok := group.(string); ok && group != "" {
+				groups = append(groups, group)
+			}
+		}
+
+		return groups
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	portaineree "github.com/portainer/portainer/api"
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_getUsername(t *testing.T) {
@@ -78,3 +79,26 @@ func Test_getUsername(t *testing.T) {
 		}
 	})
 }
+
+func Test_getGroups(t *testing.T) {
+	datamap := map[string]interface{}{
+		"group":  "admins",
+		"groups": []interface{}{"dev", "", 1.0, "ops"},
+		"empty":  "",
+	}
+
+	tests := []struct {
+		claimName string
+		want      []string
+	}{
+		{claimName: "", want: nil},
+		{claimName: "missing", want: nil},
+		{claimName: "empty", want: nil},
+		{claimName: "group", want: []string{"admins"}},
+		{claimName: "groups", want: []string{"dev", "ops"}},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, getGroups(datamap, tc.claimName), "claim %s", tc.claimName)
+	}
+}
@@ -120,7 +120,7 @@ func Test_Authenticate(t *testing.T) {
 		srv, config := oauthtest.RunOAuthServer(code, &portainer.OAuthSettings{})
 		defer srv.Close()
 
-		_, err := authService.Authenticate(code, config)
+		_, err := authService.Authenticate(code, "", "", config)
 		if err == nil {
 			t.Error("Authenticate should fail to extract username from resource if incorrect UserIdentifier provided")
 		}
@@ -131,15 +131,29 @@ func Test_Authenticate(t *testing.T) {
 		srv, config := oauthtest.RunOAuthServer(code, config)
 		defer srv.Close()
 
-		username, err := authService.Authenticate(code, config)
+		info, err := authService.Authenticate(code, "", "", config)
 		if err != nil {
-			t.Errorf("Authenticate should succeed to extract username from resource if correct UserIdentifier provided; UserIdentifier=%s", config.UserIdentifier)
+			t.Fatalf("Authenticate should succeed to extract username from resource if correct UserIdentifier provided; UserIdentifier=%s", config.UserIdentifier)
 		}
 
 		want := "test-oauth-user"
-		if username != want {
-			t.Errorf("Authenticate should return correct username; got=%s, want=%s", username, want)
+		if info.Username != want {
+			t.Errorf("Authenticate should return correct username; got=%s, want=%s", info.Username, want)
 		}
 	})
 
+	t.Run("should return the groups of the group claim", func(t *testing.T) {
+		config := &portainer.OAuthSettings{UserIdentifier: "username"}
+		config.TeamMemberships.GroupClaimName = "groups"
+		srv, config := oauthtest.RunOAuthServer(code, config)
+		defer srv.Close()
+
+		info, err := authService.Authenticate(code, "", "", config)
+		if err != nil {
+			t.Fatalf("Authenticate should succeed; err=%s", err)
+		}
+
+		assert.Equal(t, []string{"testing"}, info.Groups)
+	})
+
 }
@@ -0,0 +1,247 @@
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/mux"
+)
+
+// OIDCServer is a barebones OpenID Connect provider which can be used to test the discovery,
+// the ID token validation and the PKCE authorization code flow
+type OIDCServer struct {
+	*httptest.Server
+	// ClientID is the audience of the ID tokens
+	ClientID string
+	// Subject is the subject of the ID tokens and of the userinfo
+	Subject string
+	// Username is returned as the preferred_username claim of the userinfo
+	Username string
+	// ModifyIDToken is called with the claims of each ID token before it is signed
+	ModifyIDToken func(claims jwt.MapClaims)
+	// SigningKey overrides the key of the server to sign the ID tokens, with the key identifier of the server
+	SigningKey *rsa.PrivateKey
+
+	mu             sync.Mutex
+	key            *rsa.PrivateKey
+	kid            string
+	keyCount       int
+	authorizations map[string]authorization
+}
+
+// authorization is an authorization request waiting for its code to be exchanged
+type authorization struct {
+	nonce         string
+	codeChallenge string
+}
+
+// RunOIDCServer starts an OpenID Connect provider issuing ID tokens to the client
+func RunOIDCServer(clientID string) *OIDCServer {
+	s := &OIDCServer{
+		ClientID:       clientID,
+		Subject:        "248289761001",
+		Username:       "test-oidc-user",
+		authorizations: make(map[string]authorization),
+	}
+	s.RotateKey()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/.well-known/openid-configuration", s.discovery).Methods(http.MethodGet)
+	router.HandleFunc("/jwks", s.jwks).Methods(http.MethodGet)
+	router.HandleFunc("/authorize", s.authorize).Methods(http.MethodGet)
+	router.HandleFunc("/token", s.token).Methods(http.MethodPost)
+	router.HandleFunc("/userinfo", s.userinfo).Methods(http.MethodGet)
+
+	s.Server = httptest.NewServer(router)
+
+	return s
+}
+
+// RotateKey replaces the signing key of the server, the previous key is no longer published
+func (s *OIDCServer) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keyCount++
+	s.key = key
+	s.kid = fmt.Sprintf("key-%d", s.keyCount)
+}
+
+// Authorize follows the authorization request of a login URL like a browser of a logged in user would,
+// it returns the code and the state sent back to the redirect URI
+func (s *OIDCServer) Authorize(loginURL string) (code string, state string, err error) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(loginURL)
+	if err != nil {
+		return "", "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		return "", "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+
+	return location.Query().Get("code"), location.Query().Get("state"), nil
+}
+
+func (s *OIDCServer) discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"userinfo_endpoint":                     s.URL + "/userinfo",
+		"jwks_uri":                              s.URL + "/jwks",
+		"end_session_endpoint":                  s.URL + "/logout",
+		"response_types_supported":              []string{"code"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *OIDCServer) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	key, kid := s.key, s.kid
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+		},
+	})
+}
+
+func (s *OIDCServer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != s.ClientID || query.Get("response_type") != "code" || !strings.Contains(query.Get("scope"), "openid") {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "PKCE is required", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+
+	s.mu.Lock()
+	s.authorizations[code] = authorization{nonce: query.Get("nonce"), codeChallenge: query.Get("code_challenge")}
+	s.mu.Unlock()
+
+	location := fmt.Sprintf("%s?code=%s&state=%s", query.Get("redirect_uri"), code, url.QueryEscape(query.Get("state")))
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+func (s *OIDCServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	authorization, ok := s.authorizations[r.FormValue("code")]
+	delete(s.authorizations, r.FormValue("code"))
+	key, kid := s.key, s.kid
+	s.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != authorization.codeChallenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   s.URL,
+		"sub":   s.Subject,
+		"aud":   s.ClientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": authorization.nonce,
+	}
+
+	if s.ModifyIDToken != nil {
+		s.ModifyIDToken(claims)
+	}
+
+	if s.SigningKey != nil {
+		key = s.SigningKey
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	idToken, err := token.SignedString(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"access_token": AccessToken,
+		"id_token":     idToken,
+	})
+}
+
+func (s *OIDCServer) userinfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+AccessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sub":                s.Subject,
+		"preferred_username": s.Username,
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,329 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// providerCacheDuration is the time the discovery document and the keys of an issuer are cached
+	providerCacheDuration = time.Hour
+	// loginExpiry is the time given to a user to log in on the identity provider
+	loginExpiry = 10 * time.Minute
+	// loginName is the name under which the logins are sealed
+	loginName = "portainer_oauth_login"
+	// clockSkew is the tolerance applied to the time claims of the ID tokens
+	clockSkew = time.Minute
+)
+
+var idTokenSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// providerMetadata is the part of the OpenID Connect discovery document used by Portainer
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// provider holds the discovery document and the signing keys of an issuer
+type provider struct {
+	metadata     providerMetadata
+	keys         *keySet
+	discoveredAt time.Time
+}
+
+// pendingLogin holds the secrets of an authorization request until its authorization code is exchanged. It is sealed
+// and kept by the browser which started the login, so that no login is kept in memory before it is completed.
+type pendingLogin struct {
+	State        string
+	CodeVerifier string
+	Nonce        string
+}
+
+// Discover fills the endpoints of an OpenID Connect configuration from the discovery document of its issuer.
+// The logout URI is only filled when it is empty.
+func (service *Service) Discover(configuration *portainer.OAuthSettings) error {
+	provider, err := service.provider(configuration.IssuerURL)
+	if err != nil {
+		return err
+	}
+
+	configuration.AuthorizationURI = provider.metadata.AuthorizationEndpoint
+	configuration.AccessTokenURI = provider.metadata.TokenEndpoint
+	configuration.ResourceURI = provider.metadata.UserinfoEndpoint
+	if configuration.LogoutURI == "" {
+		configuration.LogoutURI = provider.metadata.EndSessionEndpoint
+	}
+
+	return nil
+}
+
+// LoginURL returns the URL of the authorization request of an OpenID Connect login, along with the sealed login
+// to keep in the browser until the authorization code returned with the state is exchanged by Authenticate.
+// The request uses PKCE and a nonce, both generated by the server and only readable by it in the sealed login.
+func (service *Service) LoginURL(state string, configuration *portainer.OAuthSettings) (string, string, error) {
+	if !configuration.OIDC {
+		return "", "", errors.New("the OAuth provider is not an OpenID Connect provider")
+	}
+
+	if state == "" {
+		return "", "", errors.New("the state is required")
+	}
+
+	provider, err := service.provider(configuration.IssuerURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	codeVerifier, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	login, err := service.logins.Encode(loginName, pendingLogin{
+		State:        state,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+	})
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to seal the login")
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	options := []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	}
+
+	if !configuration.SSO {
+		options = append(options, oauth2.SetAuthURLParam("prompt", "login"))
+	}
+
+	return buildOIDCConfig(configuration, provider).AuthCodeURL(state, options...), login, nil
+}
+
+// authenticateOIDC exchanges the authorization code with the PKCE verifier of the login, then validates the ID token
+// and returns its claims merged with the userinfo of the user
+func (service *Service) authenticateOIDC(code, state, sealedLogin string, configuration *portainer.OAuthSettings) (map[string]interface{}, error) {
+	var login pendingLogin
+	err := service.logins.Decode(loginName, sealedLogin, &login)
+	if err != nil {
+		return nil, errors.New("unknown or expired OAuth login")
+	}
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(login.State)) != 1 {
+		return nil, errors.New("the OAuth state does not match the login")
+	}
+
+	provider, err := service.provider(configuration.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	unescapedCode, err := url.QueryUnescape(code)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, service.client)
+	token, err := buildOIDCConfig(configuration, provider).Exchange(ctx, unescapedCode, oauth2.SetAuthURLParam("code_verifier", login.CodeVerifier))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to exchange the authorization code")
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("the token response has no id_token")
+	}
+
+	claims, err := validateIDToken(rawIDToken, login.Nonce, configuration.ClientID, provider, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	resource := map[string]interface{}(claims)
+
+	if provider.metadata.UserinfoEndpoint != "" {
+		userinfoConfiguration := *configuration
+		userinfoConfiguration.ResourceURI = provider.metadata.UserinfoEndpoint
+
+		userinfo, err := getResource(token.AccessToken, &userinfoConfiguration)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to retrieve the userinfo")
+		}
+
+		if userinfo["sub"] != claims["sub"] {
+			return nil, errors.New("the userinfo subject does not match the ID token subject")
+		}
+
+		resource = mergeSecondIntoFirst(resource, userinfo)
+	}
+
+	return resource, nil
+}
+
+// validateIDToken verifies the signature of an ID token against the keys of the issuer, then checks
+// its issuer, audience, expiry and nonce
+func validateIDToken(rawIDToken, nonce, clientID string, provider *provider, now time.Time) (jwt.MapClaims, error) {
+	parser := jwt.Parser{
+		ValidMethods:         idTokenSigningMethods,
+		SkipClaimsValidation: true,
+	}
+
+	token, err := parser.ParseWithClaims(rawIDToken, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return provider.keys.key(kid)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid id_token")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+
+	if !claims.VerifyIssuer(provider.metadata.Issuer, true) {
+		return nil, errors.New("invalid id_token issuer")
+	}
+
+	if !claims.VerifyAudience(clientID, true) {
+		return nil, errors.New("invalid id_token audience")
+	}
+
+	if audiences, ok := claims["aud"].([]interface{}); ok && len(audiences) > 1 && claims["azp"] != clientID {
+		return nil, errors.New("invalid id_token authorized party")
+	}
+
+	if !claims.VerifyExpiresAt(now.Add(-clockSkew).Unix(), true) {
+		return nil, errors.New("the id_token is expired")
+	}
+
+	if !claims.VerifyIssuedAt(now.Add(clockSkew).Unix(), false) || !claims.VerifyNotBefore(now.Add(clockSkew).Unix(), false) {
+		return nil, errors.New("the id_token is not valid yet")
+	}
+
+	tokenNonce, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, errors.New("invalid id_token nonce")
+	}
+
+	return claims, nil
+}
+
+// provider returns the cached discovery document and keys of an issuer, the document is fetched again once expired
+func (service *Service) provider(issuerURL string) (*provider, error) {
+	issuer := strings.TrimSuffix(issuerURL, "/")
+	if issuer == "" {
+		return nil, errors.New("the OpenID Connect issuer URL is required")
+	}
+
+	service.mu.Lock()
+	cached, ok := service.providers[issuer]
+	service.mu.Unlock()
+
+	if ok && time.Since(cached.discoveredAt) < providerCacheDuration {
+		return cached, nil
+	}
+
+	metadata, err := service.discover(issuer)
+	if err != nil {
+		if ok {
+			return cached, nil
+		}
+
+		return nil, err
+	}
+
+	p := &provider{metadata: *metadata, discoveredAt: time.Now()}
+	if ok && cached.metadata.JWKSURI == metadata.JWKSURI {
+		p.keys = cached.keys
+	} else {
+		p.keys = newKeySet(metadata.JWKSURI, service.client)
+	}
+
+	service.mu.Lock()
+	service.providers[issuer] = p
+	service.mu.Unlock()
+
+	return p, nil
+}
+
+func (service *Service) discover(issuer string) (*providerMetadata, error) {
+	resp, err := service.client.Get(issuer + discoveryPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the OpenID Connect discovery document")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to fetch the OpenID Connect discovery document, unexpected status code %d", resp.StatusCode)
+	}
+
+	var metadata providerMetadata
+	err = json.NewDecoder(resp.Body).Decode(&metadata)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode the OpenID Connect discovery document")
+	}
+
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+		return nil, errors.Errorf("the discovery document issuer %s does not match the issuer URL %s", metadata.Issuer, issuer)
+	}
+
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("the OpenID Connect discovery document is missing required endpoints")
+	}
+
+	return &metadata, nil
+}
+
+func buildOIDCConfig(configuration *portainer.OAuthSettings, provider *provider) *oauth2.Config {
+	scopes := []string{"openid"}
+	for _, scope := range strings.FieldsFunc(configuration.Scopes, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &oauth2.Config{
+		ClientID:     configuration.ClientID,
+		ClientSecret: configuration.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  provider.metadata.AuthorizationEndpoint,
+			TokenURL: provider.metadata.TokenEndpoint,
+		},
+		RedirectURL: configuration.RedirectURI,
+		Scopes:      scopes,
+	}
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
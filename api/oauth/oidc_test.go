@@ -0,0 +1,207 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/url"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/oauth/oauthtest"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOIDCSettings(srv *oauthtest.OIDCServer) *portainer.OAuthSettings {
+	return &portainer.OAuthSettings{
+		OIDC:           true,
+		IssuerURL:      srv.URL,
+		ClientID:       srv.ClientID,
+		ClientSecret:   "secret",
+		RedirectURI:    "http://portainer.local/",
+		UserIdentifier: "preferred_username",
+		Scopes:         "profile,email",
+	}
+}
+
+// login starts a login with the service and authorizes it on the server, it returns the code, the state and the sealed login
+func login(t *testing.T, service *Service, srv *oauthtest.OIDCServer, configuration *portainer.OAuthSettings, state string) (string, string, string) {
+	loginURL, sealedLogin, err := service.LoginURL(state, configuration)
+	require.NoError(t, err)
+
+	code, returnedState, err := srv.Authorize(loginURL)
+	require.NoError(t, err)
+	require.Equal(t, state, returnedState)
+
+	return code, returnedState, sealedLogin
+}
+
+func Test_Discover(t *testing.T) {
+	srv := oauthtest.RunOIDCServer("portainer")
+	defer srv.Close()
+
+	configuration := newOIDCSettings(srv)
+	err := NewService().Discover(configuration)
+	require.NoError(t, err)
+
+	assert.Equal(t, srv.URL+"/authorize", configuration.AuthorizationURI)
+	assert.Equal(t, srv.URL+"/token", configuration.AccessTokenURI)
+	assert.Equal(t, srv.URL+"/userinfo", configuration.ResourceURI)
+	assert.Equal(t, srv.URL+"/logout", configuration.LogoutURI)
+
+	configuration.IssuerURL = srv.URL + "/other"
+	assert.Error(t, NewService().Discover(configuration))
+}
+
+func Test_LoginURL(t *testing.T) {
+	srv := oauthtest.RunOIDCServer("portainer")
+	defer srv.Close()
+
+	loginURL, sealedLogin, err := NewService().LoginURL("state", newOIDCSettings(srv))
+	require.NoError(t, err)
+	assert.NotEmpty(t, sealedLogin)
+	assert.NotContains(t, sealedLogin, "state")
+
+	u, err := url.Parse(loginURL)
+	require.NoError(t, err)
+
+	query := u.Query()
+	assert.Equal(t, srv.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "state", query.Get("state"))
+	assert.Equal(t, "openid profile email", query.Get("scope"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.NotEmpty(t, query.Get("code_challenge"))
+	assert.NotEmpty(t, query.Get("nonce"))
+	assert.Equal(t, "login", query.Get("prompt"))
+}
+
+func Test_AuthenticateOIDC(t *testing.T) {
+	srv := oauthtest.RunOIDCServer("portainer")
+	defer srv.Close()
+
+	service := NewService()
+	configuration := newOIDCSettings(srv)
+
+	t.Run("succeeds with a valid code and state", func(t *testing.T) {
+		code, state, sealedLogin := login(t, service, srv, configuration, "state-1")
+
+		info, err := service.Authenticate(code, state, sealedLogin, configuration)
+		require.NoError(t, err)
+		assert.Equal(t, "test-oidc-user", info.Username)
+	})
+
+	t.Run("fails when the state is reused", func(t *testing.T) {
+		code, state, sealedLogin := login(t, service, srv, configuration, "state-2")
+
+		_, err := service.Authenticate(code, state, sealedLogin, configuration)
+		require.NoError(t, err)
+
+		_, err = service.Authenticate(code, state, sealedLogin, configuration)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails when the code verifier belongs to another login", func(t *testing.T) {
+		code, _, _ := login(t, service, srv, configuration, "state-3")
+		_, otherState, otherLogin := login(t, service, srv, configuration, "state-4")
+
+		_, err := service.Authenticate(code, otherState, otherLogin, configuration)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails when the state does not match the login", func(t *testing.T) {
+		code, _, sealedLogin := login(t, service, srv, configuration, "state-6")
+
+		_, err := service.Authenticate(code, "state-7", sealedLogin, configuration)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails without the login or with a login sealed by another service", func(t *testing.T) {
+		code, state, _ := login(t, service, srv, configuration, "state-8")
+
+		_, err := service.Authenticate(code, state, "", configuration)
+		assert.Error(t, err)
+
+		code, state, sealedLogin := login(t, NewService(), srv, configuration, "state-9")
+
+		_, err = service.Authenticate(code, state, sealedLogin, configuration)
+		assert.Error(t, err)
+	})
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	invalidTokens := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+		key    *rsa.PrivateKey
+	}{
+		{name: "an invalid signature", key: otherKey},
+		{name: "another audience", modify: func(claims jwt.MapClaims) { claims["aud"] = "other-client" }},
+		{name: "several audiences without authorized party", modify: func(claims jwt.MapClaims) { claims["aud"] = []string{"portainer", "other-client"} }},
+		{name: "another issuer", modify: func(claims jwt.MapClaims) { claims["iss"] = "https://other-issuer" }},
+		{name: "an expired token", modify: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "no expiry", modify: func(claims jwt.MapClaims) { delete(claims, "exp") }},
+		{name: "another nonce", modify: func(claims jwt.MapClaims) { claims["nonce"] = "replayed" }},
+		{name: "another subject than the userinfo", modify: func(claims jwt.MapClaims) { claims["sub"] = "other-subject" }},
+	}
+
+	for i, tc := range invalidTokens {
+		t.Run("fails with "+tc.name, func(t *testing.T) {
+			srv.ModifyIDToken = tc.modify
+			srv.SigningKey = tc.key
+			defer func() {
+				srv.ModifyIDToken = nil
+				srv.SigningKey = nil
+			}()
+
+			code, state, sealedLogin := login(t, service, srv, configuration, "invalid-"+string(rune('a'+i)))
+
+			_, err := service.Authenticate(code, state, sealedLogin, configuration)
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("succeeds with several audiences and the client as authorized party", func(t *testing.T) {
+		srv.ModifyIDToken = func(claims jwt.MapClaims) {
+			claims["aud"] = []string{"portainer", "other-client"}
+			claims["azp"] = "portainer"
+		}
+		defer func() { srv.ModifyIDToken = nil }()
+
+		code, state, sealedLogin := login(t, service, srv, configuration, "state-5")
+
+		_, err := service.Authenticate(code, state, sealedLogin, configuration)
+		assert.NoError(t, err)
+	})
+}
+
+func Test_AuthenticateOIDC_keyRotation(t *testing.T) {
+	srv := oauthtest.RunOIDCServer("portainer")
+	defer srv.Close()
+
+	service := NewService()
+	configuration := newOIDCSettings(srv)
+
+	code, state, sealedLogin := login(t, service, srv, configuration, "before-rotation")
+	_, err := service.Authenticate(code, state, sealedLogin, configuration)
+	require.NoError(t, err)
+
+	srv.RotateKey()
+
+	// the keys were just fetched, the refresh triggered by the unknown key is delayed
+	code, state, sealedLogin = login(t, service, srv, configuration, "rate-limited")
+	_, err = service.Authenticate(code, state, sealedLogin, configuration)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), errUnknownKey.Error())
+
+	provider, err := service.provider(configuration.IssuerURL)
+	require.NoError(t, err)
+	provider.keys.fetchedAt = provider.keys.fetchedAt.Add(-minKeyRefreshInterval)
+
+	code, state, sealedLogin = login(t, service, srv, configuration, "after-rotation")
+	_, err = service.Authenticate(code, state, sealedLogin, configuration)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,169 @@
+package saml
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	metadataNamespace = "urn:oasis:names:tc:SAML:2.0:metadata"
+	// maxMetadataSize limits the size of the metadata fetched from the identity provider
+	maxMetadataSize = 1 << 20
+)
+
+// idpEntityDescriptor is the part of the metadata of an identity provider used by Portainer
+type idpEntityDescriptor struct {
+	EntityID         string `xml:"entityID,attr"`
+	IDPSSODescriptor *struct {
+		KeyDescriptors []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+		SingleSignOnServices []struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		} `xml:"SingleSignOnService"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+// spEntityDescriptor is the metadata of Portainer as a service provider
+type spEntityDescriptor struct {
+	XMLName         xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID        string   `xml:"entityID,attr"`
+	SPSSODescriptor struct {
+		AuthnRequestsSigned        bool   `xml:"AuthnRequestsSigned,attr"`
+		WantAssertionsSigned       bool   `xml:"WantAssertionsSigned,attr"`
+		ProtocolSupportEnumeration string `xml:"protocolSupportEnumeration,attr"`
+		KeyDescriptor              struct {
+			Use     string `xml:"use,attr"`
+			KeyInfo struct {
+				XMLName     xml.Name `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo"`
+				Certificate string   `xml:"X509Data>X509Certificate"`
+			}
+		}
+		NameIDFormat             string
+		AssertionConsumerService struct {
+			Binding   string `xml:"Binding,attr"`
+			Location  string `xml:"Location,attr"`
+			Index     int    `xml:"index,attr"`
+			IsDefault bool   `xml:"isDefault,attr"`
+		}
+	}
+}
+
+// Metadata returns the metadata of Portainer as a service provider, to be imported by the identity provider
+func (service *Service) Metadata(settings *portainer.SAMLSettings) ([]byte, error) {
+	entityID, acsURL, err := serviceProviderURLs(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	_, certificate, err := parseKeyPair(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata spEntityDescriptor
+	metadata.EntityID = entityID
+
+	descriptor := &metadata.SPSSODescriptor
+	descriptor.AuthnRequestsSigned = true
+	descriptor.WantAssertionsSigned = true
+	descriptor.ProtocolSupportEnumeration = protocolNamespace
+	descriptor.KeyDescriptor.Use = "signing"
+	descriptor.KeyDescriptor.KeyInfo.Certificate = base64.StdEncoding.EncodeToString(certificate.Raw)
+	descriptor.NameIDFormat = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	descriptor.AssertionConsumerService.Binding = postBinding
+	descriptor.AssertionConsumerService.Location = acsURL
+	descriptor.AssertionConsumerService.IsDefault = true
+
+	data, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode the service provider metadata")
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+// fetchMetadata retrieves the metadata of the identity provider from its metadata URL
+func (service *Service) fetchMetadata(metadataURL string) ([]byte, error) {
+	resp, err := service.client.Get(metadataURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the identity provider metadata")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to fetch the identity provider metadata, unexpected status code %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+}
+
+// importIdPMetadata fills the entity ID, the single sign-on URL and the signing certificates of the identity provider
+// from its metadata, either an EntityDescriptor or an EntitiesDescriptor holding the identity provider
+func importIdPMetadata(data []byte, settings *portainer.SAMLSettings) error {
+	var document struct {
+		XMLName xml.Name
+		idpEntityDescriptor
+		EntityDescriptors []idpEntityDescriptor `xml:"EntityDescriptor"`
+	}
+
+	err := xml.Unmarshal(data, &document)
+	if err != nil {
+		return errors.Wrap(err, "invalid identity provider metadata")
+	}
+
+	if document.XMLName.Space != metadataNamespace {
+		return errors.New("invalid identity provider metadata, the document is not a SAML metadata document")
+	}
+
+	candidates := document.EntityDescriptors
+	if document.XMLName.Local == "EntityDescriptor" {
+		candidates = []idpEntityDescriptor{document.idpEntityDescriptor}
+	}
+
+	for _, entity := range candidates {
+		if entity.IDPSSODescriptor == nil {
+			continue
+		}
+
+		ssoURL := ""
+		for _, sso := range entity.IDPSSODescriptor.SingleSignOnServices {
+			if sso.Binding == redirectBinding {
+				ssoURL = sso.Location
+				break
+			}
+		}
+
+		if ssoURL == "" {
+			return errors.New("the identity provider has no single sign-on service with the HTTP-Redirect binding")
+		}
+
+		certificates := []string{}
+		for _, key := range entity.IDPSSODescriptor.KeyDescriptors {
+			if key.Use != "" && key.Use != "signing" {
+				continue
+			}
+
+			for _, certificate := range key.Certificates {
+				certificates = append(certificates, strings.Join(strings.Fields(certificate), ""))
+			}
+		}
+
+		settings.IdPEntityID = entity.EntityID
+		settings.IdPSSOURL = ssoURL
+		settings.IdPCertificates = certificates
+
+		return nil
+	}
+
+	return errors.New("the metadata does not describe an identity provider")
+}
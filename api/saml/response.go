@@ -0,0 +1,249 @@
+package saml
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/saml/xmldsig"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	successStatus = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearerMethod  = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+)
+
+// validateResponse verifies the signature of a response of the identity provider and the conditions of its assertion,
+// then returns the identity of the user and the identifier of the request. Either the response or its assertion must be
+// signed by the identity provider, and the response must answer an authentication request with the same relay state.
+func (service *Service) validateResponse(samlResponse, relayState string, settings *portainer.SAMLSettings, now time.Time) (*portainer.SAMLInfo, string, error) {
+	entityID, acsURL, err := serviceProviderURLs(settings)
+	if err != nil {
+		return nil, "", err
+	}
+
+	certificates, err := parseCertificates(settings.IdPCertificates)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(samlResponse), ""))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "invalid SAML response encoding")
+	}
+
+	response, err := xmldsig.Parse(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if response.Space != protocolNamespace || response.Local != "Response" {
+		return nil, "", errors.New("the document is not a SAML response")
+	}
+
+	responseSigned := true
+	err = xmldsig.Verify(response, certificates)
+	if errors.Is(err, xmldsig.ErrNoSignature) {
+		responseSigned = false
+	} else if err != nil {
+		return nil, "", errors.Wrap(err, "invalid SAML response signature")
+	}
+
+	if len(response.Elements(assertionNamespace, "EncryptedAssertion")) > 0 {
+		return nil, "", errors.New("encrypted assertions are not supported")
+	}
+
+	assertions := response.Elements(assertionNamespace, "Assertion")
+	if len(assertions) != 1 {
+		return nil, "", errors.New("the SAML response must hold exactly one assertion")
+	}
+	assertion := assertions[0]
+
+	// the assertion signature is checked when present, even when the response is signed
+	err = xmldsig.Verify(assertion, certificates)
+	if err != nil && (!responseSigned || !errors.Is(err, xmldsig.ErrNoSignature)) {
+		return nil, "", errors.Wrap(err, "invalid SAML assertion signature")
+	}
+
+	requestID := response.Attr("InResponseTo")
+	if requestID == "" {
+		return nil, "", errors.New("unsolicited SAML responses are not supported")
+	}
+
+	err = validateResponseElement(response, settings.IdPEntityID, acsURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = validateAssertion(assertion, requestID, settings.IdPEntityID, entityID, acsURL, now)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = service.answerRequest(requestID, relayState, now)
+	if err != nil {
+		return nil, "", err
+	}
+
+	info, err := identity(assertion, settings)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return info, requestID, nil
+}
+
+func validateResponseElement(response *xmldsig.Element, idpEntityID, acsURL string) error {
+	if response.Attr("Version") != "2.0" {
+		return errors.New("unsupported SAML response version")
+	}
+
+	if destination := response.Attr("Destination"); destination != "" && destination != acsURL {
+		return errors.New("the SAML response destination is not the assertion consumer service")
+	}
+
+	if issuer := response.Element(assertionNamespace, "Issuer"); issuer != nil && strings.TrimSpace(issuer.Text()) != idpEntityID {
+		return errors.New("invalid SAML response issuer")
+	}
+
+	status := response.Element(protocolNamespace, "Status")
+	if status == nil {
+		return errors.New("the SAML response has no status")
+	}
+
+	statusCode := status.Element(protocolNamespace, "StatusCode")
+	if statusCode == nil || statusCode.Attr("Value") != successStatus {
+		message := ""
+		if statusMessage := status.Element(protocolNamespace, "StatusMessage"); statusMessage != nil {
+			message = statusMessage.Text()
+		}
+
+		return errors.Errorf("the identity provider refused the authentication: %s", message)
+	}
+
+	return nil
+}
+
+func validateAssertion(assertion *xmldsig.Element, requestID, idpEntityID, entityID, acsURL string, now time.Time) error {
+	issuer := assertion.Element(assertionNamespace, "Issuer")
+	if issuer == nil || strings.TrimSpace(issuer.Text()) != idpEntityID {
+		return errors.New("invalid SAML assertion issuer")
+	}
+
+	subject := assertion.Element(assertionNamespace, "Subject")
+	if subject == nil {
+		return errors.New("the SAML assertion has no subject")
+	}
+
+	confirmed := false
+	for _, confirmation := range subject.Elements(assertionNamespace, "SubjectConfirmation") {
+		if confirmation.Attr("Method") != bearerMethod {
+			continue
+		}
+
+		data := confirmation.Element(assertionNamespace, "SubjectConfirmationData")
+		if data == nil || data.Attr("Recipient") != acsURL || data.Attr("InResponseTo") != requestID {
+			continue
+		}
+
+		notOnOrAfter, err := time.Parse(time.RFC3339, data.Attr("NotOnOrAfter"))
+		if err != nil || !now.Add(-clockSkew).Before(notOnOrAfter) {
+			continue
+		}
+
+		confirmed = true
+		break
+	}
+
+	if !confirmed {
+		return errors.New("the SAML assertion has no valid bearer subject confirmation")
+	}
+
+	conditions := assertion.Element(assertionNamespace, "Conditions")
+	if conditions == nil {
+		return errors.New("the SAML assertion has no conditions")
+	}
+
+	if notBefore := conditions.Attr("NotBefore"); notBefore != "" {
+		t, err := time.Parse(time.RFC3339, notBefore)
+		if err != nil || now.Add(clockSkew).Before(t) {
+			return errors.New("the SAML assertion is not valid yet")
+		}
+	}
+
+	if notOnOrAfter := conditions.Attr("NotOnOrAfter"); notOnOrAfter != "" {
+		t, err := time.Parse(time.RFC3339, notOnOrAfter)
+		if err != nil || !now.Add(-clockSkew).Before(t) {
+			return errors.New("the SAML assertion is expired")
+		}
+	}
+
+	restrictions := conditions.Elements(assertionNamespace, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return errors.New("the SAML assertion has no audience restriction")
+	}
+
+	for _, restriction := range restrictions {
+		found := false
+		for _, audience := range restriction.Elements(assertionNamespace, "Audience") {
+			if strings.TrimSpace(audience.Text()) == entityID {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return errors.New("the service provider is not an audience of the SAML assertion")
+		}
+	}
+
+	return nil
+}
+
+// identity returns the username and the groups of the user of an assertion
+func identity(assertion *xmldsig.Element, settings *portainer.SAMLSettings) (*portainer.SAMLInfo, error) {
+	info := &portainer.SAMLInfo{}
+
+	if settings.UsernameAttribute == "" {
+		subject := assertion.Element(assertionNamespace, "Subject")
+		if nameID := subject.Element(assertionNamespace, "NameID"); nameID != nil {
+			info.Username = strings.TrimSpace(nameID.Text())
+		}
+	} else if values := attributeValues(assertion, settings.UsernameAttribute); len(values) > 0 {
+		info.Username = values[0]
+	}
+
+	if info.Username == "" {
+		return nil, errors.New("the SAML assertion has no username")
+	}
+
+	if settings.TeamMemberships.GroupClaimName != "" {
+		info.Groups = attributeValues(assertion, settings.TeamMemberships.GroupClaimName)
+	}
+
+	return info, nil
+}
+
+// attributeValues returns the values of the attributes of an assertion matching a name or a friendly name
+func attributeValues(assertion *xmldsig.Element, name string) []string {
+	values := []string{}
+
+	for _, statement := range assertion.Elements(assertionNamespace, "AttributeStatement") {
+		for _, attribute := range statement.Elements(assertionNamespace, "Attribute") {
+			if attribute.Attr("Name") != name && attribute.Attr("FriendlyName") != name {
+				continue
+			}
+
+			for _, value := range attribute.Elements(assertionNamespace, "AttributeValue") {
+				if v := strings.TrimSpace(value.Text()); v != "" {
+					values = append(values, v)
+				}
+			}
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,417 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	protocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	assertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	redirectBinding    = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	postBinding        = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	rsaSHA256Algorithm = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+
+	// MetadataPath is the path of the service provider metadata, relative to the public URL of Portainer
+	MetadataPath = "api/auth/saml/metadata"
+	// ACSPath is the path of the assertion consumer service, relative to the public URL of Portainer
+	ACSPath = "api/auth/saml/acs"
+
+	// requestExpiry is the time given to a user to log in on the identity provider
+	requestExpiry = 10 * time.Minute
+	// codeExpiry is the time given to the frontend to redeem the code of an authenticated user
+	codeExpiry = time.Minute
+	// maxPendingLogins limits the memory used by the logins authenticated by the identity provider and not redeemed yet
+	maxPendingLogins = 10000
+	// requestNonceSize is the size of the random part of the authentication request identifiers
+	requestNonceSize = 16
+	// clockSkew is the tolerance applied to the time conditions of the assertions
+	clockSkew = time.Minute
+	// keyPairValidity is the validity of the certificate generated for the service provider
+	keyPairValidity = 10 * 365 * 24 * time.Hour
+)
+
+// pendingLogin is the identity of an authenticated user waiting for its code to be redeemed
+type pendingLogin struct {
+	info      portainer.SAMLInfo
+	requestID string
+	expiresAt time.Time
+}
+
+// Service represents a service used to authenticate users as a SAML 2.0 service provider.
+// The authentication requests are not kept in memory, their identifiers are signed with a secret of the service
+// along with their relay state and expiry. Only the requests answered by the identity provider are remembered,
+// until they expire, so that a response cannot be replayed.
+type Service struct {
+	client   *http.Client
+	secret   []byte
+	mu       sync.Mutex
+	answered map[string]time.Time
+	logins   map[string]pendingLogin
+}
+
+// NewService returns a pointer to a new instance of this service
+func NewService() *Service {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to generate the SAML request secret"))
+	}
+
+	return &Service{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		secret:   secret,
+		answered: make(map[string]time.Time),
+		logins:   make(map[string]pendingLogin),
+	}
+}
+
+// Configure imports the metadata of the identity provider, from its metadata URL or XML, and generates the key pair
+// of the service provider when it has none
+func (service *Service) Configure(settings *portainer.SAMLSettings) error {
+	_, _, err := serviceProviderURLs(settings)
+	if err != nil {
+		return err
+	}
+
+	var metadata []byte
+	if settings.IdPMetadataURL != "" {
+		metadata, err = service.fetchMetadata(settings.IdPMetadataURL)
+		if err != nil {
+			return err
+		}
+	} else if settings.IdPMetadataXML != "" {
+		metadata = []byte(settings.IdPMetadataXML)
+	}
+
+	if metadata != nil {
+		err := importIdPMetadata(metadata, settings)
+		if err != nil {
+			return err
+		}
+	}
+
+	if settings.IdPEntityID == "" || settings.IdPSSOURL == "" || len(settings.IdPCertificates) == 0 {
+		return errors.New("the entity ID, the single sign-on URL and the certificates of the identity provider are required")
+	}
+
+	_, err = parseCertificates(settings.IdPCertificates)
+	if err != nil {
+		return err
+	}
+
+	if settings.Certificate == "" || settings.PrivateKey == "" {
+		return generateKeyPair(settings)
+	}
+
+	_, _, err = parseKeyPair(settings)
+
+	return err
+}
+
+// LoginURL returns the URL of a signed authentication request with the HTTP-Redirect binding, along with the login
+// to keep in the browser until the code returned by Authenticate is redeemed. The identifier of the request is bound
+// to its relay state, so that the response of the identity provider is only accepted along with this relay state.
+func (service *Service) LoginURL(relayState string, settings *portainer.SAMLSettings) (string, string, error) {
+	if relayState == "" {
+		return "", "", errors.New("the relay state is required")
+	}
+
+	entityID, acsURL, err := serviceProviderURLs(settings)
+	if err != nil {
+		return "", "", err
+	}
+
+	key, _, err := parseKeyPair(settings)
+	if err != nil {
+		return "", "", err
+	}
+
+	id, err := service.requestID(relayState, time.Now().Add(requestExpiry))
+	if err != nil {
+		return "", "", err
+	}
+
+	forceAuthn := ""
+	if !settings.SSO {
+		forceAuthn = ` ForceAuthn="true"`
+	}
+
+	request := fmt.Sprintf(`<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s"%s>`+
+		`<saml:Issuer>%s</saml:Issuer>`+
+		`<samlp:NameIDPolicy AllowCreate="true"></samlp:NameIDPolicy>`+
+		`</samlp:AuthnRequest>`,
+		protocolNamespace, assertionNamespace, id, time.Now().UTC().Format(time.RFC3339), escape(settings.IdPSSOURL), escape(acsURL), postBinding, forceAuthn,
+		escape(entityID))
+
+	var deflated bytes.Buffer
+	writer, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return "", "", err
+	}
+	writer.Write([]byte(request))
+	writer.Close()
+
+	query := "SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString(deflated.Bytes())) +
+		"&RelayState=" + url.QueryEscape(relayState) +
+		"&SigAlg=" + url.QueryEscape(rsaSHA256Algorithm)
+
+	hashed := sha256.Sum256([]byte(query))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to sign the authentication request")
+	}
+
+	separator := "?"
+	if strings.Contains(settings.IdPSSOURL, "?") {
+		separator = "&"
+	}
+
+	return settings.IdPSSOURL + separator + query + "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature)), id, nil
+}
+
+// Authenticate validates the response of the identity provider to an authentication request started with LoginURL,
+// then returns a code which can be redeemed once for the identity of the user
+func (service *Service) Authenticate(samlResponse, relayState string, settings *portainer.SAMLSettings) (string, error) {
+	info, requestID, err := service.validateResponse(samlResponse, relayState, settings, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	code, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	now := time.Now()
+	for key, login := range service.logins {
+		if now.After(login.expiresAt) {
+			delete(service.logins, key)
+		}
+	}
+
+	if len(service.logins) >= maxPendingLogins {
+		return "", errors.New("too many pending logins")
+	}
+
+	service.logins[code] = pendingLogin{info: *info, requestID: requestID, expiresAt: now.Add(codeExpiry)}
+
+	return code, nil
+}
+
+// Redeem returns the identity of the user authenticated with the code, a code can only be redeemed once and only
+// with the login returned by LoginURL to the browser which started the login
+func (service *Service) Redeem(code, login string) (*portainer.SAMLInfo, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	pending, ok := service.logins[code]
+	delete(service.logins, code)
+
+	if !ok || time.Now().After(pending.expiresAt) {
+		return nil, errors.New("unknown or expired SAML code")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(pending.requestID), []byte(login)) != 1 {
+		return nil, errors.New("the SAML code belongs to another login")
+	}
+
+	return &pending.info, nil
+}
+
+// requestID returns the identifier of an authentication request: a random nonce and the expiry of the request,
+// signed along with its relay state
+func (service *Service) requestID(relayState string, expiresAt time.Time) (string, error) {
+	payload := make([]byte, requestNonceSize+8)
+	_, err := rand.Read(payload[:requestNonceSize])
+	if err != nil {
+		return "", err
+	}
+	binary.BigEndian.PutUint64(payload[requestNonceSize:], uint64(expiresAt.Unix()))
+
+	return "id" + hex.EncodeToString(append(payload, service.requestMAC(payload, relayState)...)), nil
+}
+
+func (service *Service) requestMAC(payload []byte, relayState string) []byte {
+	mac := hmac.New(sha256.New, service.secret)
+	mac.Write(payload)
+	mac.Write([]byte(relayState))
+
+	return mac.Sum(nil)
+}
+
+// answerRequest verifies that an authentication request was issued by the service with the relay state and has not
+// expired, then remembers it until it expires, a request can only be answered once
+func (service *Service) answerRequest(id, relayState string, now time.Time) error {
+	raw, err := hex.DecodeString(strings.TrimPrefix(id, "id"))
+	if err != nil || !strings.HasPrefix(id, "id") || len(raw) != requestNonceSize+8+sha256.Size {
+		return errors.New("unknown SAML request")
+	}
+
+	payload, signature := raw[:requestNonceSize+8], raw[requestNonceSize+8:]
+	if !hmac.Equal(signature, service.requestMAC(payload, relayState)) {
+		return errors.New("the relay state does not match the SAML request")
+	}
+
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[requestNonceSize:])), 0)
+	if now.After(expiresAt) {
+		return errors.New("expired SAML request")
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	for answeredID, answeredExpiresAt := range service.answered {
+		if now.After(answeredExpiresAt) {
+			delete(service.answered, answeredID)
+		}
+	}
+
+	if _, ok := service.answered[id]; ok {
+		return errors.New("the SAML request was already answered")
+	}
+
+	service.answered[id] = expiresAt
+
+	return nil
+}
+
+// serviceProviderURLs returns the entity ID and the assertion consumer service URL of the service provider
+func serviceProviderURLs(settings *portainer.SAMLSettings) (string, string, error) {
+	u, err := url.Parse(settings.ServiceProviderURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", errors.New("the public URL of Portainer is required")
+	}
+
+	baseURL := strings.TrimSuffix(settings.ServiceProviderURL, "/") + "/"
+
+	entityID := settings.EntityID
+	if entityID == "" {
+		entityID = baseURL + MetadataPath
+	}
+
+	return entityID, baseURL + ACSPath, nil
+}
+
+func parseCertificates(certificates []string) ([]*x509.Certificate, error) {
+	parsed := make([]*x509.Certificate, 0, len(certificates))
+	for _, certificate := range certificates {
+		der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(certificate), ""))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid identity provider certificate")
+		}
+
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid identity provider certificate")
+		}
+
+		parsed = append(parsed, c)
+	}
+
+	return parsed, nil
+}
+
+func parseKeyPair(settings *portainer.SAMLSettings) (*rsa.PrivateKey, *x509.Certificate, error) {
+	keyBlock, _ := pem.Decode([]byte(settings.PrivateKey))
+	if keyBlock == nil {
+		return nil, nil, errors.New("invalid service provider private key")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid service provider private key")
+	}
+
+	certificateBlock, _ := pem.Decode([]byte(settings.Certificate))
+	if certificateBlock == nil {
+		return nil, nil, errors.New("invalid service provider certificate")
+	}
+
+	certificate, err := x509.ParseCertificate(certificateBlock.Bytes)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid service provider certificate")
+	}
+
+	if !key.PublicKey.Equal(certificate.PublicKey) {
+		return nil, nil, errors.New("the service provider certificate does not match its private key")
+	}
+
+	return key, certificate, nil
+}
+
+// generateKeyPair generates the RSA key and the self-signed certificate of the service provider
+func generateKeyPair(settings *portainer.SAMLSettings) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate the service provider key")
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: "Portainer SAML service provider"},
+		NotBefore:    time.Now().Add(-clockSkew),
+		NotAfter:     time.Now().Add(keyPairValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate the service provider certificate")
+	}
+
+	settings.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	settings.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+
+	return nil
+}
+
+// randomID returns a random identifier, starting with a letter as the xs:ID type requires
+func randomID() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "id" + hex.EncodeToString(b), nil
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+
+	return buf.String()
+}
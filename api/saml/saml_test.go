@@ -0,0 +1,288 @@
+package saml
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	portainer "github.com/portainer/portainer/api"
+	"github.com/portainer/portainer/api/saml/samltest"
+	"github.com/portainer/portainer/api/saml/xmldsig"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSAMLSettings(t *testing.T, service *Service, idp *samltest.IdP) *portainer.SAMLSettings {
+	settings := &portainer.SAMLSettings{
+		ServiceProviderURL: "https://portainer.example.com",
+		IdPMetadataURL:     idp.URL + "/metadata",
+		UsernameAttribute:  "uid",
+		TeamMemberships:    portainer.OAuthTeamMemberships{GroupClaimName: "groups"},
+	}
+
+	require.NoError(t, service.Configure(settings))
+
+	return settings
+}
+
+// login starts a login with the service and answers it with the identity provider, it returns the response,
+// the relay state and the login kept by the browser
+func login(t *testing.T, service *Service, idp *samltest.IdP, settings *portainer.SAMLSettings, relayState string) (string, string, string) {
+	loginURL, browserLogin, err := service.LoginURL(relayState, settings)
+	require.NoError(t, err)
+
+	samlResponse, returnedRelayState, err := idp.Respond(loginURL, settings.Certificate)
+	require.NoError(t, err)
+	require.Equal(t, relayState, returnedRelayState)
+
+	return samlResponse, returnedRelayState, browserLogin
+}
+
+func Test_Configure(t *testing.T) {
+	idp := samltest.RunIdP()
+	defer idp.Close()
+
+	service := NewService()
+
+	t.Run("imports the metadata from the URL and generates a key pair", func(t *testing.T) {
+		settings := newSAMLSettings(t, service, idp)
+
+		assert.Equal(t, idp.EntityID, settings.IdPEntityID)
+		assert.Equal(t, idp.URL+"/sso", settings.IdPSSOURL)
+		assert.Equal(t, []string{base64.StdEncoding.EncodeToString(idp.Certificate.Raw)}, settings.IdPCertificates)
+		assert.Contains(t, settings.Certificate, "BEGIN CERTIFICATE")
+		assert.Contains(t, settings.PrivateKey, "BEGIN RSA PRIVATE KEY")
+
+		certificate := settings.Certificate
+		require.NoError(t, service.Configure(settings))
+		assert.Equal(t, certificate, settings.Certificate, "the key pair should be kept")
+	})
+
+	t.Run("imports the metadata from the XML", func(t *testing.T) {
+		settings := &portainer.SAMLSettings{ServiceProviderURL: "https://portainer.example.com/", IdPMetadataXML: idp.Metadata()}
+
+		require.NoError(t, service.Configure(settings))
+		assert.Equal(t, idp.EntityID, settings.IdPEntityID)
+	})
+
+	t.Run("fails without the public URL of Portainer", func(t *testing.T) {
+		assert.Error(t, service.Configure(&portainer.SAMLSettings{IdPMetadataXML: idp.Metadata()}))
+	})
+
+	t.Run("fails without an identity provider", func(t *testing.T) {
+		assert.Error(t, service.Configure(&portainer.SAMLSettings{ServiceProviderURL: "https://portainer.example.com/"}))
+	})
+}
+
+func Test_Metadata(t *testing.T) {
+	idp := samltest.RunIdP()
+	defer idp.Close()
+
+	service := NewService()
+	settings := newSAMLSettings(t, service, idp)
+
+	metadata, err := service.Metadata(settings)
+	require.NoError(t, err)
+
+	document, err := xmldsig.Parse(metadata)
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://portainer.example.com/api/auth/saml/metadata", document.Attr("entityID"))
+
+	descriptor := document.Element(metadataNamespace, "SPSSODescriptor")
+	require.NotNil(t, descriptor)
+	assert.Equal(t, "true", descriptor.Attr("AuthnRequestsSigned"))
+
+	acs := descriptor.Element(metadataNamespace, "AssertionConsumerService")
+	require.NotNil(t, acs)
+	assert.Equal(t, "https://portainer.example.com/api/auth/saml/acs", acs.Attr("Location"))
+	assert.Equal(t, postBinding, acs.Attr("Binding"))
+
+	keyInfo := descriptor.Element(metadataNamespace, "KeyDescriptor").Element(xmldsig.Namespace, "KeyInfo")
+	require.NotNil(t, keyInfo)
+}
+
+func Test_LoginURL(t *testing.T) {
+	idp := samltest.RunIdP()
+	defer idp.Close()
+
+	service := NewService()
+	settings := newSAMLSettings(t, service, idp)
+
+	loginURL, browserLogin, err := service.LoginURL("relay-state", settings)
+	require.NoError(t, err)
+	assert.NotEmpty(t, browserLogin)
+
+	u, err := url.Parse(loginURL)
+	require.NoError(t, err)
+	assert.Equal(t, "relay-state", u.Query().Get("RelayState"))
+	assert.Equal(t, rsaSHA256Algorithm, u.Query().Get("SigAlg"))
+	assert.NotEmpty(t, u.Query().Get("Signature"))
+
+	_, _, err = service.LoginURL("", settings)
+	assert.Error(t, err)
+}
+
+func Test_Authenticate(t *testing.T) {
+	idp := samltest.RunIdP()
+	defer idp.Close()
+
+	service := NewService()
+	settings := newSAMLSettings(t, service, idp)
+
+	t.Run("succeeds with a signed assertion", func(t *testing.T) {
+		samlResponse, relayState, browserLogin := login(t, service, idp, settings, "state-1")
+
+		code, err := service.Authenticate(samlResponse, relayState, settings)
+		require.NoError(t, err)
+
+		info, err := service.Redeem(code, browserLogin)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", info.Username)
+		assert.ElementsMatch(t, []string{"developers", "operators"}, info.Groups)
+
+		_, err = service.Redeem(code, browserLogin)
+		assert.Error(t, err, "a code should only be redeemed once")
+	})
+
+	t.Run("fails to redeem a code with the login of another browser", func(t *testing.T) {
+		samlResponse, relayState, _ := login(t, service, idp, settings, "state-8")
+		_, _, otherLogin := login(t, service, idp, settings, "state-9")
+
+		code, err := service.Authenticate(samlResponse, relayState, settings)
+		require.NoError(t, err)
+
+		_, err = service.Redeem(code, otherLogin)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails with a response to a request of another service", func(t *testing.T) {
+		samlResponse, relayState, _ := login(t, NewService(), idp, settings, "state-10")
+
+		_, err := service.Authenticate(samlResponse, relayState, settings)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails with an expired request", func(t *testing.T) {
+		id, err := service.requestID("state-11", time.Now().Add(requestExpiry))
+		require.NoError(t, err)
+
+		err = service.answerRequest(id, "state-11", time.Now().Add(requestExpiry+time.Second))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "expired")
+
+		assert.NoError(t, service.answerRequest(id, "state-11", time.Now()))
+	})
+
+	t.Run("succeeds with a signed response and uses the NameID without username attribute", func(t *testing.T) {
+		idp.SignAssertion, idp.SignResponse = false, true
+		defer func() { idp.SignAssertion, idp.SignResponse = true, false }()
+
+		settings := *settings
+		settings.UsernameAttribute = ""
+
+		samlResponse, relayState, browserLogin := login(t, service, idp, &settings, "state-2")
+
+		code, err := service.Authenticate(samlResponse, relayState, &settings)
+		require.NoError(t, err)
+
+		info, err := service.Redeem(code, browserLogin)
+		require.NoError(t, err)
+		assert.Equal(t, "alice@example.com", info.Username)
+	})
+
+	t.Run("fails when the response is replayed", func(t *testing.T) {
+		samlResponse, relayState, _ := login(t, service, idp, settings, "state-3")
+
+		_, err := service.Authenticate(samlResponse, relayState, settings)
+		require.NoError(t, err)
+
+		_, err = service.Authenticate(samlResponse, relayState, settings)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails with another relay state", func(t *testing.T) {
+		samlResponse, _, _ := login(t, service, idp, settings, "state-4")
+
+		_, err := service.Authenticate(samlResponse, "other-state", settings)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails when nothing is signed", func(t *testing.T) {
+		idp.SignAssertion = false
+		defer func() { idp.SignAssertion = true }()
+
+		samlResponse, relayState, _ := login(t, service, idp, settings, "state-5")
+
+		_, err := service.Authenticate(samlResponse, relayState, settings)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails when the assertion is signed by another key", func(t *testing.T) {
+		otherIdP := samltest.RunIdP()
+		defer otherIdP.Close()
+
+		key, certificate := idp.Key, idp.Certificate
+		idp.Key, idp.Certificate = otherIdP.Key, otherIdP.Certificate
+		defer func() { idp.Key, idp.Certificate = key, certificate }()
+
+		samlResponse, relayState, _ := login(t, service, idp, settings, "state-6")
+
+		_, err := service.Authenticate(samlResponse, relayState, settings)
+		assert.Error(t, err)
+	})
+
+	t.Run("fails when the signed assertion is modified", func(t *testing.T) {
+		loginURL, _, err := service.LoginURL("state-7", settings)
+		require.NoError(t, err)
+
+		samlResponse, relayState, err := idp.Respond(loginURL, settings.Certificate)
+		require.NoError(t, err)
+
+		data, err := base64.StdEncoding.DecodeString(samlResponse)
+		require.NoError(t, err)
+		tampered := strings.Replace(string(data), ">alice<", ">admin<", 1)
+		require.NotEqual(t, string(data), tampered)
+
+		_, err = service.Authenticate(base64.StdEncoding.EncodeToString([]byte(tampered)), relayState, settings)
+		assert.Error(t, err)
+	})
+
+	invalidAssertions := []struct {
+		name   string
+		modify func(assertion *xmldsig.Element)
+	}{
+		{name: "another audience", modify: func(assertion *xmldsig.Element) {
+			audience := assertion.Element(assertionNamespace, "Conditions").Element(assertionNamespace, "AudienceRestriction").Element(assertionNamespace, "Audience")
+			audience.Children = []xmldsig.Node{xmldsig.Text("https://other-sp.example.com")}
+		}},
+		{name: "an expired assertion", modify: func(assertion *xmldsig.Element) {
+			assertion.Element(assertionNamespace, "Conditions").SetAttr("NotOnOrAfter", time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+		}},
+		{name: "an assertion not valid yet", modify: func(assertion *xmldsig.Element) {
+			assertion.Element(assertionNamespace, "Conditions").SetAttr("NotBefore", time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		}},
+		{name: "another issuer", modify: func(assertion *xmldsig.Element) {
+			assertion.Element(assertionNamespace, "Issuer").Children = []xmldsig.Node{xmldsig.Text("https://other-idp.example.com")}
+		}},
+		{name: "another recipient", modify: func(assertion *xmldsig.Element) {
+			assertion.Element(assertionNamespace, "Subject").Element(assertionNamespace, "SubjectConfirmation").
+				Element(assertionNamespace, "SubjectConfirmationData").SetAttr("Recipient", "https://other-sp.example.com/acs")
+		}},
+	}
+
+	for i, tc := range invalidAssertions {
+		t.Run("fails with "+tc.name, func(t *testing.T) {
+			idp.ModifyAssertion = tc.modify
+			defer func() { idp.ModifyAssertion = nil }()
+
+			samlResponse, relayState, _ := login(t, service, idp, settings, "invalid-"+string(rune('a'+i)))
+
+			_, err := service.Authenticate(samlResponse, relayState, settings)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package samltest
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/portainer/portainer/api/saml/xmldsig"
+)
+
+const (
+	protocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	assertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+)
+
+// IdP is a barebones SAML 2.0 identity provider which can be used to test the signed authentication requests,
+// the signatures and the conditions of the responses
+type IdP struct {
+	*httptest.Server
+	// EntityID is the issuer of the responses, the URL of the metadata of the identity provider
+	EntityID string
+	// Key and Certificate are the signing key pair of the identity provider
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+	// NameID is the subject of the assertions
+	NameID string
+	// Attributes are the attributes of the assertions
+	Attributes map[string][]string
+	// SignResponse and SignAssertion choose the signed elements of the responses
+	SignResponse  bool
+	SignAssertion bool
+	// ModifyAssertion is called with each assertion before it is signed
+	ModifyAssertion func(assertion *xmldsig.Element)
+}
+
+// authnRequest is the part of an authentication request used by the identity provider
+type authnRequest struct {
+	ID                          string `xml:"ID,attr"`
+	AssertionConsumerServiceURL string `xml:"AssertionConsumerServiceURL,attr"`
+	Issuer                      string `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+}
+
+// RunIdP starts an identity provider serving its metadata at /metadata, it signs the assertions by default
+func RunIdP() *IdP {
+	key, certificate := newKeyPair()
+
+	idp := &IdP{
+		Key:           key,
+		Certificate:   certificate,
+		NameID:        "alice@example.com",
+		Attributes:    map[string][]string{"uid": {"alice"}, "groups": {"developers", "operators"}},
+		SignAssertion: true,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metadata", idp.metadata)
+	idp.Server = httptest.NewServer(mux)
+	idp.EntityID = idp.URL + "/metadata"
+
+	return idp
+}
+
+// Metadata returns the metadata of the identity provider
+func (idp *IdP) Metadata() string {
+	return fmt.Sprintf(`<?xml version="1.0"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">
+  <md:IDPSSODescriptor protocolSupportEnumeration="%s">
+    <md:KeyDescriptor use="encryption">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>MIIBogus</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>
+        %s
+      </ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="%s/sso/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="%s/sso"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`, idp.EntityID, protocolNamespace, base64.StdEncoding.EncodeToString(idp.Certificate.Raw), idp.URL, idp.URL)
+}
+
+func (idp *IdP) metadata(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	io.WriteString(w, idp.Metadata())
+}
+
+// Respond authenticates the user of an authentication request like the identity provider would after a login.
+// The signature of the request is verified with the certificate of the service provider, then the base64 encoded
+// response and the relay state posted back to the assertion consumer service are returned.
+func (idp *IdP) Respond(loginURL string, spCertificatePEM string) (samlResponse string, relayState string, err error) {
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	if u.Scheme+"://"+u.Host+u.Path != idp.URL+"/sso" {
+		return "", "", fmt.Errorf("unexpected single sign-on URL %s", loginURL)
+	}
+
+	err = verifyRedirectSignature(u.RawQuery, spCertificatePEM)
+	if err != nil {
+		return "", "", err
+	}
+
+	query := u.Query()
+
+	deflated, err := base64.StdEncoding.DecodeString(query.Get("SAMLRequest"))
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		return "", "", err
+	}
+
+	var request authnRequest
+	err = xml.Unmarshal(data, &request)
+	if err != nil {
+		return "", "", err
+	}
+
+	response, err := idp.response(request)
+	if err != nil {
+		return "", "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(response), query.Get("RelayState"), nil
+}
+
+func (idp *IdP) response(request authnRequest) ([]byte, error) {
+	now := time.Now().UTC()
+	issueInstant := now.Format(time.RFC3339)
+	notOnOrAfter := now.Add(5 * time.Minute).Format(time.RFC3339)
+
+	var attributes strings.Builder
+	for name, values := range idp.Attributes {
+		attributes.WriteString(fmt.Sprintf(`<saml:Attribute Name="%s" NameFormat="urn:oasis:names:tc:SAML:2.0:attrname-format:basic">`, name))
+		for _, value := range values {
+			attributes.WriteString(fmt.Sprintf(`<saml:AttributeValue xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="xs:string">%s</saml:AttributeValue>`, escape(value)))
+		}
+		attributes.WriteString(`</saml:Attribute>`)
+	}
+
+	document := fmt.Sprintf(`<samlp:Response xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" InResponseTo="%s">
+  <saml:Issuer>%s</saml:Issuer>
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  <saml:Assertion ID="%s" Version="2.0" IssueInstant="%s">
+    <saml:Issuer>%s</saml:Issuer>
+    <saml:Subject>
+      <saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">%s</saml:NameID>
+      <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+        <saml:SubjectConfirmationData InResponseTo="%s" NotOnOrAfter="%s" Recipient="%s"/>
+      </saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="%s" NotOnOrAfter="%s">
+      <saml:AudienceRestriction><saml:Audience>%s</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AuthnStatement AuthnInstant="%s">
+      <saml:AuthnContext><saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport</saml:AuthnContextClassRef></saml:AuthnContext>
+    </saml:AuthnStatement>
+    <saml:AttributeStatement>%s</saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`,
+		protocolNamespace, assertionNamespace, randomID(), issueInstant, escape(request.AssertionConsumerServiceURL), escape(request.ID),
+		escape(idp.EntityID),
+		randomID(), issueInstant,
+		escape(idp.EntityID),
+		escape(idp.NameID),
+		escape(request.ID), notOnOrAfter, escape(request.AssertionConsumerServiceURL),
+		now.Add(-time.Minute).Format(time.RFC3339), notOnOrAfter,
+		escape(request.Issuer),
+		issueInstant,
+		attributes.String())
+
+	response, err := xmldsig.Parse([]byte(document))
+	if err != nil {
+		return nil, err
+	}
+
+	assertion := response.Element(assertionNamespace, "Assertion")
+	if idp.ModifyAssertion != nil {
+		idp.ModifyAssertion(assertion)
+	}
+
+	if idp.SignAssertion {
+		err := xmldsig.Sign(assertion, idp.Key, idp.Certificate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if idp.SignResponse {
+		err := xmldsig.Sign(response, idp.Key, idp.Certificate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response.Bytes(), nil
+}
+
+// verifyRedirectSignature verifies the signature of a request with the HTTP-Redirect binding, computed over
+// the query parameters as they were sent
+func verifyRedirectSignature(rawQuery string, certificatePEM string) error {
+	block, _ := pem.Decode([]byte(certificatePEM))
+	if block == nil {
+		return fmt.Errorf("invalid service provider certificate")
+	}
+
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	signed := []string{}
+	var signature []byte
+	for _, parameter := range strings.Split(rawQuery, "&") {
+		switch {
+		case strings.HasPrefix(parameter, "SAMLRequest="), strings.HasPrefix(parameter, "RelayState="), strings.HasPrefix(parameter, "SigAlg="):
+			signed = append(signed, parameter)
+		case strings.HasPrefix(parameter, "Signature="):
+			value, err := url.QueryUnescape(strings.TrimPrefix(parameter, "Signature="))
+			if err != nil {
+				return err
+			}
+
+			signature, err = base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(signed) != 3 || signature == nil {
+		return fmt.Errorf("the authentication request is not signed")
+	}
+
+	hashed := sha256.Sum256([]byte(strings.Join(signed, "&")))
+
+	return rsa.VerifyPKCS1v15(certificate.PublicKey.(*rsa.PublicKey), crypto.SHA256, hashed[:], signature)
+}
+
+func newKeyPair() (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "samltest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+
+	return key, certificate
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return "_" + hex.EncodeToString(b)
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+
+	return buf.String()
+}
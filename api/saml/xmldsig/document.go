@@ -0,0 +1,385 @@
+package xmldsig
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// Element is an element of a XML document. The prefixes and the namespace declarations of the document
+// are kept as written, since they are part of the canonical form of the element.
+type Element struct {
+	// Prefix is the prefix of the name of the element, empty for the default namespace
+	Prefix string
+	// Local is the local name of the element
+	Local string
+	// Space is the namespace URI of the element
+	Space string
+	// Attrs are the attributes of the element, including the namespace declarations
+	Attrs []Attr
+	// Children are the child elements and the text of the element, either *Element or Text
+	Children []Node
+	// Parent is the parent element, nil for the root element
+	Parent *Element
+
+	// scope holds the namespaces in scope for the element, by prefix
+	scope map[string]string
+}
+
+// Attr is an attribute of an element
+type Attr struct {
+	Prefix string
+	Local  string
+	Space  string
+	Value  string
+}
+
+// Node is either an *Element or a Text
+type Node interface{}
+
+// Text is the text content of an element
+type Text string
+
+// isNamespaceDeclaration reports whether the attribute declares a namespace
+func (attr Attr) isNamespaceDeclaration() bool {
+	return attr.Prefix == "xmlns" || (attr.Prefix == "" && attr.Local == "xmlns")
+}
+
+// Parse parses a XML document and returns its root element. The comments and processing instructions are
+// dropped, and the documents with a DTD are refused.
+func Parse(data []byte) (*Element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var root, current *Element
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid XML document")
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if current == nil && root != nil {
+				return nil, errors.New("invalid XML document, several root elements")
+			}
+
+			element := newElement(t, current)
+			if current == nil {
+				root = element
+			} else {
+				current.Children = append(current.Children, element)
+			}
+			current = element
+
+		case xml.EndElement:
+			if current == nil || t.Name.Space != current.Prefix || t.Name.Local != current.Local {
+				return nil, errors.New("invalid XML document, mismatched end element")
+			}
+			current = current.Parent
+
+		case xml.CharData:
+			if current != nil {
+				current.Children = append(current.Children, Text(t))
+			} else if len(bytes.TrimSpace(t)) > 0 {
+				return nil, errors.New("invalid XML document, text outside of the root element")
+			}
+
+		case xml.Directive:
+			return nil, errors.New("invalid XML document, DTDs are not supported")
+		}
+	}
+
+	if root == nil || current != nil {
+		return nil, errors.New("invalid XML document, unexpected end of document")
+	}
+
+	return root, nil
+}
+
+func newElement(start xml.StartElement, parent *Element) *Element {
+	element := &Element{
+		Prefix: start.Name.Space,
+		Local:  start.Name.Local,
+		Parent: parent,
+	}
+
+	for _, attr := range start.Attr {
+		element.Attrs = append(element.Attrs, Attr{Prefix: attr.Name.Space, Local: attr.Name.Local, Value: attr.Value})
+	}
+
+	element.resolve()
+
+	return element
+}
+
+// resolve computes the namespaces in scope for the element and resolves the namespaces of its name and attributes
+func (e *Element) resolve() {
+	inherited := map[string]string{"xml": xmlNamespace}
+	if e.Parent != nil {
+		inherited = e.Parent.scope
+	}
+
+	// the scope of the parent is shared until the element declares a namespace
+	e.scope = inherited
+	copied := false
+	for _, attr := range e.Attrs {
+		if !attr.isNamespaceDeclaration() {
+			continue
+		}
+
+		if !copied {
+			e.scope = make(map[string]string, len(inherited)+1)
+			for prefix, uri := range inherited {
+				e.scope[prefix] = uri
+			}
+			copied = true
+		}
+
+		if attr.Prefix == "xmlns" {
+			e.scope[attr.Local] = attr.Value
+		} else {
+			e.scope[""] = attr.Value
+		}
+	}
+
+	e.Space = e.scope[e.Prefix]
+	for i, attr := range e.Attrs {
+		if attr.Prefix != "" && !attr.isNamespaceDeclaration() {
+			e.Attrs[i].Space = e.scope[attr.Prefix]
+		}
+	}
+
+	for _, child := range e.Children {
+		if element, ok := child.(*Element); ok {
+			element.resolve()
+		}
+	}
+}
+
+// SetParent attaches the element under a new parent and resolves its namespaces in the scope of the parent
+func (e *Element) SetParent(parent *Element) {
+	e.Parent = parent
+	e.resolve()
+}
+
+// Attr returns the value of an attribute without namespace
+func (e *Element) Attr(local string) string {
+	for _, attr := range e.Attrs {
+		if attr.Prefix == "" && attr.Local == local {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
+
+// SetAttr sets the value of an attribute without namespace
+func (e *Element) SetAttr(local, value string) {
+	for i, attr := range e.Attrs {
+		if attr.Prefix == "" && attr.Local == local {
+			e.Attrs[i].Value = value
+			return
+		}
+	}
+
+	e.Attrs = append(e.Attrs, Attr{Local: local, Value: value})
+}
+
+// Elements returns the child elements with the namespace and the local name
+func (e *Element) Elements(space, local string) []*Element {
+	elements := []*Element{}
+	for _, child := range e.Children {
+		if element, ok := child.(*Element); ok && element.Space == space && element.Local == local {
+			elements = append(elements, element)
+		}
+	}
+
+	return elements
+}
+
+// Element returns the first child element with the namespace and the local name, nil when there is none
+func (e *Element) Element(space, local string) *Element {
+	elements := e.Elements(space, local)
+	if len(elements) == 0 {
+		return nil
+	}
+
+	return elements[0]
+}
+
+// Text returns the concatenated text of the element, without the text of its child elements
+func (e *Element) Text() string {
+	var text strings.Builder
+	for _, child := range e.Children {
+		if t, ok := child.(Text); ok {
+			text.WriteString(string(t))
+		}
+	}
+
+	return text.String()
+}
+
+// Bytes serializes the element with its namespace declarations as written
+func (e *Element) Bytes() []byte {
+	var buf bytes.Buffer
+	e.write(&buf)
+
+	return buf.Bytes()
+}
+
+func (e *Element) write(buf *bytes.Buffer) {
+	buf.WriteString("<" + e.qualifiedName())
+	for _, attr := range e.Attrs {
+		buf.WriteString(" " + qualifiedName(attr.Prefix, attr.Local) + `="` + escapeAttr(attr.Value) + `"`)
+	}
+	buf.WriteString(">")
+
+	for _, child := range e.Children {
+		switch c := child.(type) {
+		case *Element:
+			c.write(buf)
+		case Text:
+			buf.WriteString(escapeText(string(c)))
+		}
+	}
+
+	buf.WriteString("</" + e.qualifiedName() + ">")
+}
+
+func (e *Element) qualifiedName() string {
+	return qualifiedName(e.Prefix, e.Local)
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+
+	return prefix + ":" + local
+}
+
+// Canonicalize returns the exclusive canonical form, without comments, of the element. The excluded element is left
+// out of the canonical form, which is used for enveloped signatures. The namespaces of the inclusive prefixes are
+// rendered like the inclusive canonicalization does, "#default" designates the default namespace.
+func Canonicalize(e *Element, excluded *Element, inclusivePrefixes []string) []byte {
+	inclusive := make(map[string]bool)
+	for _, prefix := range inclusivePrefixes {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		inclusive[prefix] = true
+	}
+
+	var buf bytes.Buffer
+	canonicalize(&buf, e, excluded, inclusive, map[string]string{})
+
+	return buf.Bytes()
+}
+
+func canonicalize(buf *bytes.Buffer, e *Element, excluded *Element, inclusive map[string]bool, rendered map[string]string) {
+	// the namespaces visibly utilized by the element are rendered, unless an output ancestor already rendered them
+	utilized := map[string]bool{e.Prefix: true}
+	for _, attr := range e.Attrs {
+		if attr.Prefix != "" && !attr.isNamespaceDeclaration() {
+			utilized[attr.Prefix] = true
+		}
+	}
+	for prefix := range inclusive {
+		if _, ok := e.scope[prefix]; ok {
+			utilized[prefix] = true
+		}
+	}
+
+	prefixes := []string{}
+	for prefix := range utilized {
+		if prefix == "xml" {
+			continue
+		}
+
+		uri, inScope := e.scope[prefix]
+		if !inScope && prefix != "" {
+			continue
+		}
+
+		if renderedURI, ok := rendered[prefix]; (ok && renderedURI == uri) || (!ok && prefix == "" && uri == "") {
+			continue
+		}
+
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	childRendered := rendered
+	if len(prefixes) > 0 {
+		childRendered = make(map[string]string, len(rendered)+len(prefixes))
+		for prefix, uri := range rendered {
+			childRendered[prefix] = uri
+		}
+	}
+
+	buf.WriteString("<" + e.qualifiedName())
+
+	for _, prefix := range prefixes {
+		uri := e.scope[prefix]
+		childRendered[prefix] = uri
+
+		if prefix == "" {
+			buf.WriteString(` xmlns="` + escapeAttr(uri) + `"`)
+		} else {
+			buf.WriteString(" xmlns:" + prefix + `="` + escapeAttr(uri) + `"`)
+		}
+	}
+
+	attrs := []Attr{}
+	for _, attr := range e.Attrs {
+		if !attr.isNamespaceDeclaration() {
+			attrs = append(attrs, attr)
+		}
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].Space != attrs[j].Space {
+			return attrs[i].Space < attrs[j].Space
+		}
+		return attrs[i].Local < attrs[j].Local
+	})
+
+	for _, attr := range attrs {
+		buf.WriteString(" " + qualifiedName(attr.Prefix, attr.Local) + `="` + escapeAttr(attr.Value) + `"`)
+	}
+	buf.WriteString(">")
+
+	for _, child := range e.Children {
+		switch c := child.(type) {
+		case *Element:
+			if c != excluded {
+				canonicalize(buf, c, excluded, inclusive, childRendered)
+			}
+		case Text:
+			buf.WriteString(escapeText(string(c)))
+		}
+	}
+
+	buf.WriteString("</" + e.qualifiedName() + ">")
+}
+
+var (
+	textReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string {
+	return textReplacer.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrReplacer.Replace(s)
+}
@@ -0,0 +1,267 @@
+package xmldsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+
+	// registers the hash functions of the signature algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Namespace is the namespace of the XML signatures
+	Namespace = "http://www.w3.org/2000/09/xmldsig#"
+
+	excC14NAlgorithm       = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	envelopedSigAlgorithm  = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	rsaSHA256Algorithm     = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	rsaSHA512Algorithm     = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	ecdsaSHA256Algorithm   = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	sha256DigestAlgorithm  = "http://www.w3.org/2001/04/xmlenc#sha256"
+	sha512DigestAlgorithm  = "http://www.w3.org/2001/04/xmlenc#sha512"
+	inclusiveNamespacesTag = "InclusiveNamespaces"
+)
+
+var (
+	signatureHashes = map[string]crypto.Hash{
+		rsaSHA256Algorithm:   crypto.SHA256,
+		rsaSHA512Algorithm:   crypto.SHA512,
+		ecdsaSHA256Algorithm: crypto.SHA256,
+	}
+
+	digestHashes = map[string]crypto.Hash{
+		sha256DigestAlgorithm: crypto.SHA256,
+		sha512DigestAlgorithm: crypto.SHA512,
+	}
+
+	// ErrNoSignature is returned when the element has no signature
+	ErrNoSignature = errors.New("the element is not signed")
+)
+
+// Verify verifies the enveloped signature of the element with one of the certificates. The signature must be a
+// child of the element and its only reference must designate the element by its ID attribute, so that the
+// signed content is the element itself. The signatures using SHA-1 are refused.
+func Verify(e *Element, certificates []*x509.Certificate) error {
+	signatures := e.Elements(Namespace, "Signature")
+	if len(signatures) == 0 {
+		return ErrNoSignature
+	}
+	if len(signatures) > 1 {
+		return errors.New("the element has several signatures")
+	}
+	signature := signatures[0]
+
+	signedInfo := signature.Element(Namespace, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("the signature has no SignedInfo")
+	}
+
+	c14nMethod := signedInfo.Element(Namespace, "CanonicalizationMethod")
+	if c14nMethod == nil || c14nMethod.Attr("Algorithm") != excC14NAlgorithm {
+		return errors.New("unsupported signature canonicalization method")
+	}
+
+	signatureMethod := signedInfo.Element(Namespace, "SignatureMethod")
+	if signatureMethod == nil {
+		return errors.New("the signature has no SignatureMethod")
+	}
+
+	hash, ok := signatureHashes[signatureMethod.Attr("Algorithm")]
+	if !ok {
+		return errors.Errorf("unsupported signature method %s", signatureMethod.Attr("Algorithm"))
+	}
+
+	references := signedInfo.Elements(Namespace, "Reference")
+	if len(references) != 1 {
+		return errors.New("the signature must have exactly one reference")
+	}
+
+	err := verifyReference(e, signature, references[0])
+	if err != nil {
+		return err
+	}
+
+	signatureValue := signature.Element(Namespace, "SignatureValue")
+	if signatureValue == nil {
+		return errors.New("the signature has no SignatureValue")
+	}
+
+	value, err := decodeBase64(signatureValue.Text())
+	if err != nil {
+		return errors.Wrap(err, "invalid signature value")
+	}
+
+	h := hash.New()
+	h.Write(Canonicalize(signedInfo, nil, inclusivePrefixes(c14nMethod)))
+	hashed := h.Sum(nil)
+
+	for _, certificate := range certificates {
+		if verifySignatureValue(certificate.PublicKey, hash, hashed, value, signatureMethod.Attr("Algorithm")) {
+			return nil
+		}
+	}
+
+	return errors.New("invalid signature")
+}
+
+func verifyReference(e, signature, reference *Element) error {
+	id := e.Attr("ID")
+	if id == "" || reference.Attr("URI") != "#"+id {
+		return errors.New("the signature reference does not designate the signed element")
+	}
+
+	var prefixes []string
+	enveloped := false
+
+	if transforms := reference.Element(Namespace, "Transforms"); transforms != nil {
+		for _, transform := range transforms.Elements(Namespace, "Transform") {
+			switch transform.Attr("Algorithm") {
+			case envelopedSigAlgorithm:
+				enveloped = true
+			case excC14NAlgorithm:
+				prefixes = inclusivePrefixes(transform)
+			default:
+				return errors.Errorf("unsupported signature transform %s", transform.Attr("Algorithm"))
+			}
+		}
+	}
+
+	if !enveloped {
+		return errors.New("the signature is not an enveloped signature")
+	}
+
+	digestMethod := reference.Element(Namespace, "DigestMethod")
+	if digestMethod == nil {
+		return errors.New("the signature reference has no DigestMethod")
+	}
+
+	hash, ok := digestHashes[digestMethod.Attr("Algorithm")]
+	if !ok {
+		return errors.Errorf("unsupported digest method %s", digestMethod.Attr("Algorithm"))
+	}
+
+	digestValue := reference.Element(Namespace, "DigestValue")
+	if digestValue == nil {
+		return errors.New("the signature reference has no DigestValue")
+	}
+
+	expected, err := decodeBase64(digestValue.Text())
+	if err != nil {
+		return errors.Wrap(err, "invalid digest value")
+	}
+
+	h := hash.New()
+	h.Write(Canonicalize(e, signature, prefixes))
+
+	if subtle.ConstantTimeCompare(h.Sum(nil), expected) != 1 {
+		return errors.New("the digest of the signed element does not match")
+	}
+
+	return nil
+}
+
+func verifySignatureValue(publicKey interface{}, hash crypto.Hash, hashed, value []byte, algorithm string) bool {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		if algorithm == ecdsaSHA256Algorithm {
+			return false
+		}
+
+		return rsa.VerifyPKCS1v15(key, hash, hashed, value) == nil
+	case *ecdsa.PublicKey:
+		if algorithm != ecdsaSHA256Algorithm || len(value)%2 != 0 {
+			return false
+		}
+
+		// the XML signatures hold the concatenation of r and s instead of their ASN.1 sequence
+		r := new(big.Int).SetBytes(value[:len(value)/2])
+		s := new(big.Int).SetBytes(value[len(value)/2:])
+
+		return ecdsa.Verify(key, hashed, r, s)
+	}
+
+	return false
+}
+
+// inclusivePrefixes returns the prefix list of the InclusiveNamespaces child of a canonicalization method
+func inclusivePrefixes(method *Element) []string {
+	for _, child := range method.Children {
+		if element, ok := child.(*Element); ok && element.Local == inclusiveNamespacesTag && element.Space == excC14NAlgorithm {
+			return strings.Fields(element.Attr("PrefixList"))
+		}
+	}
+
+	return nil
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
+
+// Sign signs the element with an enveloped RSA-SHA256 signature holding the certificate. The signature is inserted
+// after the first child element when the element has an Issuer child, as the SAML schemas expect, first otherwise.
+func Sign(e *Element, key *rsa.PrivateKey, certificate *x509.Certificate) error {
+	id := e.Attr("ID")
+	if id == "" {
+		return errors.New("the element to sign has no ID attribute")
+	}
+
+	digest := crypto.SHA256.New()
+	digest.Write(Canonicalize(e, nil, nil))
+
+	signature, err := Parse([]byte(fmt.Sprintf(`<ds:Signature xmlns:ds="%s">`+
+		`<ds:SignedInfo>`+
+		`<ds:CanonicalizationMethod Algorithm="%s"></ds:CanonicalizationMethod>`+
+		`<ds:SignatureMethod Algorithm="%s"></ds:SignatureMethod>`+
+		`<ds:Reference URI="#%s">`+
+		`<ds:Transforms><ds:Transform Algorithm="%s"></ds:Transform><ds:Transform Algorithm="%s"></ds:Transform></ds:Transforms>`+
+		`<ds:DigestMethod Algorithm="%s"></ds:DigestMethod>`+
+		`<ds:DigestValue>%s</ds:DigestValue>`+
+		`</ds:Reference>`+
+		`</ds:SignedInfo>`+
+		`<ds:SignatureValue></ds:SignatureValue>`+
+		`<ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo>`+
+		`</ds:Signature>`,
+		Namespace, excC14NAlgorithm, rsaSHA256Algorithm, escapeAttr(id), envelopedSigAlgorithm, excC14NAlgorithm,
+		sha256DigestAlgorithm, base64.StdEncoding.EncodeToString(digest.Sum(nil)), base64.StdEncoding.EncodeToString(certificate.Raw))))
+	if err != nil {
+		return err
+	}
+
+	signed := crypto.SHA256.New()
+	signed.Write(Canonicalize(signature.Element(Namespace, "SignedInfo"), nil, nil))
+
+	value, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, signed.Sum(nil))
+	if err != nil {
+		return errors.Wrap(err, "failed to sign the element")
+	}
+
+	signatureValue := signature.Element(Namespace, "SignatureValue")
+	signatureValue.Children = []Node{Text(base64.StdEncoding.EncodeToString(value))}
+
+	position := 0
+	for i, child := range e.Children {
+		if element, ok := child.(*Element); ok {
+			if element.Local == "Issuer" {
+				position = i + 1
+			}
+			break
+		}
+	}
+
+	e.Children = append(e.Children[:position], append([]Node{signature}, e.Children[position:]...)...)
+	signature.SetParent(e)
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package xmldsig
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	samlpNamespace = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlNamespace  = "urn:oasis:names:tc:SAML:2.0:assertion"
+)
+
+const document = `<?xml version="1.0" encoding="UTF-8"?>
+<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" xmlns:unused="urn:unused" ID="_r" Version="2.0"><!-- comment --><saml:Issuer>idp</saml:Issuer><saml:Assertion xmlns:xs="http://www.w3.org/2001/XMLSchema" ID="_a" b="2" a="1"><saml:AttributeValue xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="xs:string">a &amp; b &lt; "c" &gt;</saml:AttributeValue><empty/></saml:Assertion></samlp:Response>`
+
+func newCertificate(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return key, certificate
+}
+
+func Test_Canonicalize(t *testing.T) {
+	root, err := Parse([]byte(document))
+	require.NoError(t, err)
+
+	assertion := root.Element(samlNamespace, "Assertion")
+	require.NotNil(t, assertion)
+
+	assert.Equal(t,
+		`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a" a="1" b="2">`+
+			`<saml:AttributeValue xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="xs:string">a &amp; b &lt; "c" &gt;</saml:AttributeValue>`+
+			`<empty></empty>`+
+			`</saml:Assertion>`,
+		string(Canonicalize(assertion, nil, nil)))
+
+	assert.Equal(t,
+		`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" xmlns:xs="http://www.w3.org/2001/XMLSchema" ID="_a" a="1" b="2">`+
+			`<saml:AttributeValue xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="xs:string">a &amp; b &lt; "c" &gt;</saml:AttributeValue>`+
+			`<empty></empty>`+
+			`</saml:Assertion>`,
+		string(Canonicalize(assertion, nil, []string{"xs"})),
+		"the namespaces of the inclusive prefixes should be rendered")
+
+	assert.Equal(t,
+		`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r" Version="2.0"><saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">idp</saml:Issuer></samlp:Response>`,
+		string(Canonicalize(root, assertion, nil)),
+		"the comments, the unused namespaces and the excluded element should be left out")
+}
+
+func Test_Canonicalize_defaultNamespace(t *testing.T) {
+	root, err := Parse([]byte(`<a xmlns="urn:a" xmlns:p="urn:p"><b xmlns=""><c p:attr="&#x9;v&#xA;" attr="x"/></b><d>text&#xD;</d></a>`))
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		`<a xmlns="urn:a"><b xmlns=""><c xmlns:p="urn:p" attr="x" p:attr="&#x9;v&#xA;"></c></b><d>text&#xD;</d></a>`,
+		string(Canonicalize(root, nil, nil)))
+
+	b := root.Elements("", "b")[0]
+	assert.Equal(t, `<b><c xmlns:p="urn:p" attr="x" p:attr="&#x9;v&#xA;"></c></b>`, string(Canonicalize(b, nil, nil)))
+	assert.Equal(t, "urn:a", root.Element("urn:a", "d").Space)
+}
+
+func Test_Parse_refusesDTD(t *testing.T) {
+	_, err := Parse([]byte(`<!DOCTYPE a [<!ENTITY e "entity">]><a>&e;</a>`))
+	assert.Error(t, err)
+}
+
+func Test_SignAndVerify(t *testing.T) {
+	key, certificate := newCertificate(t)
+	_, otherCertificate := newCertificate(t)
+
+	sign := func(t *testing.T) *Element {
+		root, err := Parse([]byte(document))
+		require.NoError(t, err)
+
+		assertion := root.Element(samlNamespace, "Assertion")
+		require.NoError(t, Sign(assertion, key, certificate))
+
+		// the signature is verified on the serialized document, like a response received from an identity provider
+		root, err = Parse(root.Bytes())
+		require.NoError(t, err)
+
+		return root
+	}
+
+	t.Run("succeeds with the certificate of the signer", func(t *testing.T) {
+		root := sign(t)
+		assertion := root.Element(samlNamespace, "Assertion")
+
+		assert.NoError(t, Verify(assertion, []*x509.Certificate{otherCertificate, certificate}))
+	})
+
+	t.Run("fails with another certificate", func(t *testing.T) {
+		root := sign(t)
+		assert.Error(t, Verify(root.Element(samlNamespace, "Assertion"), []*x509.Certificate{otherCertificate}))
+	})
+
+	t.Run("fails when the element is not signed", func(t *testing.T) {
+		root := sign(t)
+		assert.ErrorIs(t, Verify(root, []*x509.Certificate{certificate}), ErrNoSignature)
+	})
+
+	t.Run("fails when the signed content is modified", func(t *testing.T) {
+		root := sign(t)
+		assertion := root.Element(samlNamespace, "Assertion")
+		value := assertion.Element(samlNamespace, "AttributeValue")
+		value.Children = []Node{Text("admin")}
+
+		err := Verify(assertion, []*x509.Certificate{certificate})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "digest")
+	})
+
+	t.Run("fails when the reference designates another element", func(t *testing.T) {
+		root := sign(t)
+		assertion := root.Element(samlNamespace, "Assertion")
+		assertion.SetAttr("ID", "_other")
+
+		assert.Error(t, Verify(assertion, []*x509.Certificate{certificate}))
+	})
+
+	t.Run("fails when the signature is moved to another element", func(t *testing.T) {
+		root := sign(t)
+		assertion := root.Element(samlNamespace, "Assertion")
+
+		signature := assertion.Element(Namespace, "Signature")
+		root.Children = append(root.Children, signature)
+		root.SetAttr("ID", "_a")
+
+		assert.Error(t, Verify(root, []*x509.Certificate{certificate}))
+	})
+
+	t.Run("inserts the signature after the issuer", func(t *testing.T) {
+		root, err := Parse([]byte(document))
+		require.NoError(t, err)
+		require.NoError(t, Sign(root, key, certificate))
+
+		assert.True(t, strings.Contains(string(root.Bytes()), `</saml:Issuer><ds:Signature`))
+
+		root, err = Parse(root.Bytes())
+		require.NoError(t, err)
+		assert.NoError(t, Verify(root, []*x509.Certificate{certificate}))
+		assert.Equal(t, samlpNamespace, root.Space)
+	})
+}
@@ -82,6 +82,22 @@ func (s *Scheduler) StopJob(jobID string) error {
 // Returns job id that could be used to stop the given job.
 // When job run returns an error, that job won't be run again.
 func (s *Scheduler) StartJobEvery(duration time.Duration, job func() error) string {
+	return s.startJob(cron.Every(duration), job)
+}
+
+// StartCronJob schedules a new job at the times of a standard 5 fields cron expression.
+// Returns job id that could be used to stop the given job.
+// When job run returns an error, that job won't be run again.
+func (s *Scheduler) StartCronJob(cronExpression string, job func() error) (string, error) {
+	schedule, err := cron.ParseStandard(cronExpression)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid cron expression %q", cronExpression)
+	}
+
+	return s.startJob(schedule, job), nil
+}
+
+func (s *Scheduler) startJob(schedule cron.Schedule, job func() error) string {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	j := cron.FuncJob(func() {
@@ -91,7 +107,7 @@ func (s *Scheduler) StartJobEvery(duration time.Duration, job func() error) stri
 		}
 	})
 
-	entryID := s.crontab.Schedule(cron.Every(duration), j)
+	entryID := s.crontab.Schedule(schedule, j)
 
 	s.mu.Lock()
 	s.activeJobs[entryID] = cancel
@@ -105,3 +121,9 @@ func (s *Scheduler) StartJobEvery(duration time.Duration, job func() error) stri
 
 	return strconv.Itoa(int(entryID))
 }
+
+// ValidateCronExpression returns an error when cronExpression is not a valid standard 5 fields cron expression
+func ValidateCronExpression(cronExpression string) error {
+	_, err := cron.ParseStandard(cronExpression)
+	return err
+}
@@ -118,3 +118,17 @@ func Test_StartJobEvery_Concurrently(t *testing.T) {
 
 	<-ctx.Done()
 }
+
+func Test_StartCronJob(t *testing.T) {
+	s := NewScheduler(context.Background())
+	defer s.Shutdown()
+
+	_, err := s.StartCronJob("not a cron expression", func() error { return nil })
+	assert.Error(t, err)
+
+	jobID, err := s.StartCronJob("0 2 * * *", func() error { return nil })
+	assert.NoError(t, err)
+	assert.NotEmpty(t, jobID)
+
+	assert.NoError(t, s.StopJob(jobID))
+}
@@ -40,6 +40,10 @@ export function PublicSettingsViewModel(settings) {
 
 export function InternalAuthSettingsViewModel(data) {
   this.RequiredPasswordLength = data.RequiredPasswordLength;
+  this.MaxFailedAttempts = data.MaxFailedAttempts;
+  this.LockoutDuration = data.LockoutDuration;
+  this.PasswordMaxAge = data.PasswordMaxAge;
+  this.PasswordHistorySize = data.PasswordHistorySize;
 }
 
 export function LDAPSettingsViewModel(data) {
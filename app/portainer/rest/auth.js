@@ -8,7 +8,10 @@ angular.module('portainer.app').factory('Auth', [
       {},
       {
         login: { method: 'POST', ignoreLoadingBar: true },
+        loginMFA: { method: 'POST', params: { action: 'mfa' }, ignoreLoadingBar: true },
+        enrolMFA: { method: 'POST', url: API_ENDPOINT_AUTH + '/mfa/enrol', ignoreLoadingBar: true },
         logout: { method: 'POST', params: { action: 'logout' }, ignoreLoadingBar: true },
+        validateSAML: { method: 'POST', url: API_ENDPOINT_AUTH + '/saml/validate', ignoreLoadingBar: true },
       }
     );
   },
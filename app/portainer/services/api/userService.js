@@ -110,9 +110,9 @@ export function UserService($q, Users, TeamService, TeamMembershipService) {
     return deferred.promise;
   };
 
-  service.createAccessToken = function (id, description) {
+  service.createAccessToken = function (id, description, expiresAt) {
     const deferred = $q.defer();
-    const payload = { description };
+    const payload = { description, expiresAt };
     Users.createAccessToken({ id }, payload)
       .$promise.then((data) => {
         deferred.resolve(data);
@@ -22,7 +22,10 @@ angular.module('portainer.app').factory('Authentication', [
 
     service.init = init;
     service.OAuthLogin = OAuthLogin;
+    service.SAMLLogin = SAMLLogin;
     service.login = login;
+    service.loginMFA = loginMFA;
+    service.enrolMFA = enrolMFA;
     service.logout = logout;
     service.isAuthenticated = isAuthenticated;
     service.getUserDetails = getUserDetails;
@@ -64,8 +67,8 @@ angular.module('portainer.app').factory('Authentication', [
       return $async(initAsync);
     }
 
-    async function OAuthLoginAsync(code) {
-      const response = await OAuth.validate({ code: code }).$promise;
+    async function OAuthLoginAsync(code, state) {
+      const response = await OAuth.validate({ code: code, state: state }).$promise;
       const jwt = setJWTFromResponse(response);
       await setUser(jwt);
     }
@@ -77,20 +80,61 @@ angular.module('portainer.app').factory('Authentication', [
       return response.jwt;
     }
 
-    function OAuthLogin(code) {
-      return $async(OAuthLoginAsync, code);
+    function OAuthLogin(code, state) {
+      return $async(OAuthLoginAsync, code, state);
     }
 
+    async function SAMLLoginAsync(code) {
+      const response = await Auth.validateSAML({ code: code }).$promise;
+      const jwt = setJWTFromResponse(response);
+      await setUser(jwt);
+    }
+
+    function SAMLLogin(code) {
+      return $async(SAMLLoginAsync, code);
+    }
+
+    // when the multi-factor authentication is required, the login is completed with loginMFA
+    // and the result holds the pre-authentication token to send along with the code
     async function loginAsync(username, password) {
       const response = await Auth.login({ username: username, password: password }).$promise;
+      if (response.mfaRequired) {
+        return {
+          mfaRequired: true,
+          mfaEnrolmentRequired: !!response.mfaEnrolmentRequired,
+          preAuthToken: response.preAuthToken,
+        };
+      }
+
       const jwt = setJWTFromResponse(response);
       await setUser(jwt);
+      return { mfaRequired: false };
     }
 
     function login(username, password) {
       return $async(loginAsync, username, password);
     }
 
+    // returns the recovery codes of the user when the login confirms the enrolment
+    async function loginMFAAsync(preAuthToken, code, recoveryCode) {
+      const response = await Auth.loginMFA({ PreAuthToken: preAuthToken, Code: code, RecoveryCode: recoveryCode }).$promise;
+      const jwt = setJWTFromResponse(response);
+      await setUser(jwt);
+      return response.recoveryCodes || [];
+    }
+
+    function loginMFA(preAuthToken, code, recoveryCode) {
+      return $async(loginMFAAsync, preAuthToken, code, recoveryCode);
+    }
+
+    async function enrolMFAAsync(preAuthToken) {
+      return Auth.enrolMFA({ PreAuthToken: preAuthToken }).$promise;
+    }
+
+    function enrolMFA(preAuthToken) {
+      return $async(enrolMFAAsync, preAuthToken);
+    }
+
     function isAuthenticated() {
       var jwt = LocalStorage.getJWT();
       return !!jwt && !jwtHelper.isTokenExpired(jwt);
@@ -11,8 +11,8 @@ export default class CreateUserAccessTokenController {
     this.onError = this.onError.bind(this);
   }
 
-  async onSubmit(description) {
-    const accessToken = await this.UserService.createAccessToken(this.state.userId, description);
+  async onSubmit(description, expiresAt) {
+    const accessToken = await this.UserService.createAccessToken(this.state.userId, description, expiresAt);
     // Dispatch analytics event upon success accessToken generation
     this.$analytics.eventTrack('portainer-account-access-token-create', { category: 'portainer' });
     return accessToken;
@@ -47,6 +47,7 @@ class AuthenticationController {
       AuthenticationError: '',
       loginInProgress: true,
       OAuthProvider: '',
+      mfa: null,
     };
 
     this.checkForEndpointsAsync = this.checkForEndpointsAsync.bind(this);
@@ -54,6 +55,7 @@ class AuthenticationController {
 
     this.oAuthLoginAsync = this.oAuthLoginAsync.bind(this);
     this.internalLoginAsync = this.internalLoginAsync.bind(this);
+    this.authenticateMFAAsync = this.authenticateMFAAsync.bind(this);
 
     this.authenticateUserAsync = this.authenticateUserAsync.bind(this);
 
@@ -100,6 +102,8 @@ class AuthenticationController {
       return 'Google';
     } else if (LoginURI.indexOf('github.com') !== -1) {
       return 'Github';
+    } else if (LoginURI.indexOf('api/auth/saml/login') === 0) {
+      return 'SAML';
     }
     return 'OAuth';
   }
@@ -107,7 +111,8 @@ class AuthenticationController {
   generateState() {
     const uuid = uuidv4();
     this.LocalStorage.storeLoginStateUUID(uuid);
-    return '&state=' + uuid;
+    const separator = this.state.OAuthLoginURI && this.state.OAuthLoginURI.indexOf('?') === -1 ? '?' : '&';
+    return separator + 'state=' + uuid;
   }
 
   generateOAuthLoginURI() {
@@ -162,24 +167,101 @@ class AuthenticationController {
    * LOGIN METHODS SECTION
    */
 
-  async oAuthLoginAsync(code) {
+  async oAuthLoginAsync(code, state) {
     try {
-      await this.Authentication.OAuthLogin(code);
+      if (this.state.OAuthProvider === 'SAML') {
+        await this.Authentication.SAMLLogin(code);
+      } else {
+        await this.Authentication.OAuthLogin(code, state);
+      }
       this.URLHelper.cleanParameters();
     } catch (err) {
-      this.error(err, 'Unable to login via OAuth');
+      this.error(err, 'Unable to login via ' + this.state.OAuthProvider);
     }
   }
 
   async internalLoginAsync(username, password) {
-    await this.Authentication.login(username, password);
+    const result = await this.Authentication.login(username, password);
+    if (result && result.mfaRequired) {
+      return this.startMFA(result);
+    }
+
     await this.postLoginSteps();
   }
 
+  async startMFA({ preAuthToken, mfaEnrolmentRequired }) {
+    this.formValues.Password = '';
+    this.state.mfa = {
+      preAuthToken,
+      enrolmentRequired: mfaEnrolmentRequired,
+      enrolment: null,
+      useRecoveryCode: false,
+      code: '',
+      recoveryCode: '',
+      recoveryCodes: [],
+    };
+
+    if (mfaEnrolmentRequired) {
+      this.state.mfa.enrolment = await this.Authentication.enrolMFA(preAuthToken);
+    }
+
+    this.state.loginInProgress = false;
+  }
+
   /**
    * END LOGIN METHODS SECTION
    */
 
+  /**
+   * MULTI-FACTOR AUTHENTICATION SECTION
+   */
+
+  toggleRecoveryCode() {
+    this.state.mfa.useRecoveryCode = !this.state.mfa.useRecoveryCode;
+    this.state.mfa.code = '';
+    this.state.mfa.recoveryCode = '';
+  }
+
+  cancelMFA() {
+    this.state.mfa = null;
+    this.state.AuthenticationError = '';
+  }
+
+  async authenticateMFAAsync() {
+    const mfa = this.state.mfa;
+
+    try {
+      this.state.loginInProgress = true;
+      const code = mfa.useRecoveryCode ? '' : mfa.code;
+      const recoveryCode = mfa.useRecoveryCode ? mfa.recoveryCode : '';
+      const recoveryCodes = await this.Authentication.loginMFA(mfa.preAuthToken, code, recoveryCode);
+
+      // the recovery codes are only shown once, after the enrolment
+      if (recoveryCodes.length > 0) {
+        mfa.recoveryCodes = recoveryCodes;
+        this.state.loginInProgress = false;
+        return;
+      }
+
+      await this.postLoginSteps();
+    } catch (err) {
+      this.error(err, 'Unable to verify the code');
+    }
+  }
+
+  authenticateMFA() {
+    return this.$async(this.authenticateMFAAsync);
+  }
+
+  continueAfterRecoveryCodes() {
+    this.state.loginInProgress = true;
+    return this.$async(this.postLoginSteps);
+  }
+
+  /**
+   * END MULTI-FACTOR AUTHENTICATION SECTION
+   */
+
   /**
    * AUTHENTICATE USER SECTION
    */
@@ -209,7 +291,7 @@ class AuthenticationController {
    */
   async manageOauthCodeReturn(code, state) {
     if (this.hasValidState(state)) {
-      await this.oAuthLoginAsync(code);
+      await this.oAuthLoginAsync(code, state);
     } else {
       this.error(null, 'Invalid OAuth state, try again.');
     }
@@ -233,7 +315,7 @@ class AuthenticationController {
   async onInit() {
     try {
       const settings = await this.SettingsService.publicSettings();
-      this.state.showOAuthLogin = settings.AuthenticationMethod === 3;
+      this.state.showOAuthLogin = settings.AuthenticationMethod === 3 || settings.AuthenticationMethod === 4;
       this.state.showStandardLogin = !this.state.showOAuthLogin;
       this.state.OAuthLoginURI = settings.OAuthLoginURI;
       this.state.OAuthProvider = this.determineOauthProvider(settings.OAuthLoginURI);
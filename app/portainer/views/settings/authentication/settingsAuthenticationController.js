@@ -68,7 +68,7 @@ function SettingsAuthenticationController($q, $scope, $state, Notifications, Set
 
   $scope.onChangePasswordLength = function onChangePasswordLength(value) {
     $scope.$evalAsync(() => {
-      $scope.settings.InternalAuthSettings = { RequiredPasswordLength: value };
+      $scope.settings.InternalAuthSettings = { ...$scope.settings.InternalAuthSettings, RequiredPasswordLength: value };
     });
   };
 
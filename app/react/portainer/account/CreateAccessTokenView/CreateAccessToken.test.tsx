@@ -36,7 +36,7 @@ test('once the button is clicked, the access token is generated and displayed',
 
   userEvent.click(button);
 
-  expect(onSubmit).toHaveBeenCalledWith('description');
+  expect(onSubmit).toHaveBeenCalledWith('description', expect.any(Number));
   expect(onSubmit).toHaveBeenCalledTimes(1);
 
   await expect(queries.findByText('New access token')).resolves.toBeVisible();
@@ -9,7 +9,7 @@ import { FormSectionTitle } from '@@/form-components/FormSectionTitle';
 import { TextTip } from '@@/Tip/TextTip';
 import { Code } from '@@/Code';
 import { CopyButton } from '@@/buttons/CopyButton';
-import { Input } from '@@/form-components/Input';
+import { Input, Select } from '@@/form-components/Input';
 
 interface AccessTokenResponse {
   rawAPIKey: string;
@@ -17,12 +17,23 @@ interface AccessTokenResponse {
 
 export interface Props {
   // onSubmit dispatches a successful matomo analytics event
-  onSubmit: (description: string) => Promise<AccessTokenResponse>;
+  // expiresAt is the Unix timestamp (UTC) when the access token expires
+  onSubmit: (
+    description: string,
+    expiresAt: number
+  ) => Promise<AccessTokenResponse>;
 
   // onError is called when an error occurs; this is a callback to Notifications.error
   onError: (heading: string, err: unknown, message: string) => void;
 }
 
+const expiryOptions = [
+  { value: 7, label: '7 days' },
+  { value: 30, label: '30 days' },
+  { value: 90, label: '90 days' },
+  { value: 365, label: '1 year' },
+];
+
 export function CreateAccessToken({
   onSubmit,
   onError,
@@ -32,6 +43,7 @@ export function CreateAccessToken({
 
   const router = useRouter();
   const [description, setDescription] = useState('');
+  const [expiryDays, setExpiryDays] = useState(30);
   const [errorText, setErrorText] = useState('');
   const [isLoading, setIsLoading] = useState(false);
   const [accessToken, setAccessToken] = useState('');
@@ -49,7 +61,8 @@ export function CreateAccessToken({
 
     setIsLoading(true);
     try {
-      const response = await onSubmit(description);
+      const expiresAt = Math.floor(Date.now() / 1000) + expiryDays * 86400;
+      const response = await onSubmit(description, expiresAt);
       setAccessToken(response.rawAPIKey);
     } catch (err) {
       onError('Failure', err, 'Failed to generate access token');
@@ -73,6 +86,14 @@ export function CreateAccessToken({
               value={description}
             />
           </FormControl>
+          <FormControl inputId="expiry" label={t('Expiry')}>
+            <Select
+              id="expiry"
+              options={expiryOptions}
+              onChange={(e) => setExpiryDays(parseInt(e.target.value, 10))}
+              value={expiryDays}
+            />
+          </FormControl>
           <div className="row mt-5">
             <Button
               disabled={!!errorText || !!accessToken}
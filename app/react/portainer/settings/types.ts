@@ -97,7 +97,13 @@ export interface Settings {
   LogoURL: string;
   BlackListedLabels: Pair[];
   AuthenticationMethod: AuthenticationMethod;
-  InternalAuthSettings: { RequiredPasswordLength: number };
+  InternalAuthSettings: {
+    RequiredPasswordLength: number;
+    MaxFailedAttempts: number;
+    LockoutDuration: string;
+    PasswordMaxAge: string;
+    PasswordHistorySize: number;
+  };
   LDAPSettings: LDAPSettings;
   OAuthSettings: OAuthSettings;
   openAMTConfiguration: OpenAMTConfiguration;